@@ -3,6 +3,7 @@ package lxd
 import (
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/websocket"
 
@@ -67,6 +68,7 @@ type InstanceServer interface {
 	// Server functions
 	GetServer() (server *api.Server, ETag string, err error)
 	GetServerResources() (resources *api.Resources, err error)
+	GetMetadataConfiguration() (metadata *api.MetadataConfiguration, err error)
 	UpdateServer(server api.ServerPut, ETag string) (err error)
 	HasExtension(extension string) (exists bool)
 	RequireAuthenticated(authenticated bool)
@@ -79,6 +81,7 @@ type InstanceServer interface {
 	GetCertificates() (certificates []api.Certificate, err error)
 	GetCertificate(fingerprint string) (certificate *api.Certificate, ETag string, err error)
 	CreateCertificate(certificate api.CertificatesPost) (err error)
+	CreateCertificateToken(certificate api.CertificatesPost) (op Operation, err error)
 	UpdateCertificate(fingerprint string, certificate api.CertificatePut, ETag string) (err error)
 	DeleteCertificate(fingerprint string) (err error)
 
@@ -160,6 +163,9 @@ type InstanceServer interface {
 	GetInstanceFile(instanceName string, path string) (content io.ReadCloser, resp *InstanceFileResponse, err error)
 	CreateInstanceFile(instanceName string, path string, args InstanceFileArgs) (err error)
 	DeleteInstanceFile(instanceName string, path string) (err error)
+	GetInstanceFileArchive(instanceName string, path string) (content io.ReadCloser, err error)
+	CreateInstanceFileArchive(instanceName string, path string, content io.Reader) (err error)
+	GetInstanceFileSFTPConn(instanceName string) (conn io.ReadWriteCloser, err error)
 
 	GetInstanceSnapshotNames(instanceName string) (names []string, err error)
 	GetInstanceSnapshots(instanceName string) (snapshots []api.InstanceSnapshot, err error)
@@ -182,6 +188,11 @@ type InstanceServer interface {
 
 	GetInstanceState(name string) (state *api.InstanceState, ETag string, err error)
 	UpdateInstanceState(name string, state api.InstanceStatePut, ETag string) (op Operation, err error)
+	RunInstanceAgentCommand(name string, req api.InstanceAgentPost) (result *api.InstanceAgentResult, err error)
+	GetInstanceUEFIVars(name string) (content io.ReadCloser, err error)
+	UpdateInstanceUEFIVars(name string, content io.Reader) (err error)
+	RunInstanceUEFIVarsAction(name string, req api.InstanceUEFIVarsPost) (err error)
+	ValidateInstanceDevices(name string, req api.InstanceDevicesValidatePost) (result *api.InstanceDevicesValidate, err error)
 
 	GetInstanceLogfiles(name string) (logfiles []string, err error)
 	GetInstanceLogfile(name string, filename string) (content io.ReadCloser, err error)
@@ -255,12 +266,15 @@ type InstanceServer interface {
 	GetStoragePoolResources(name string) (resources *api.ResourcesStoragePool, err error)
 	CreateStoragePool(pool api.StoragePoolsPost) (err error)
 	UpdateStoragePool(name string, pool api.StoragePoolPut, ETag string) (err error)
+	UpdateStoragePoolPropagate(name string, pool api.StoragePoolPut, ETag string) (err error)
+	UpdateStoragePoolPropagateDryRun(name string, pool api.StoragePoolPut, ETag string) (volumes []string, err error)
 	DeleteStoragePool(name string) (err error)
 
 	// Storage volume functions ("storage" API extension)
 	GetStoragePoolVolumeNames(pool string) (names []string, err error)
 	GetStoragePoolVolumes(pool string) (volumes []api.StorageVolume, err error)
 	GetStoragePoolVolume(pool string, volType string, name string) (volume *api.StorageVolume, ETag string, err error)
+	GetStoragePoolVolumeState(pool string, volType string, name string) (state *api.StorageVolumeState, err error)
 	CreateStoragePoolVolume(pool string, volume api.StorageVolumesPost) (err error)
 	UpdateStoragePoolVolume(pool string, volType string, name string, volume api.StorageVolumePut, ETag string) (err error)
 	DeleteStoragePoolVolume(pool string, volType string, name string) (err error)
@@ -268,6 +282,8 @@ type InstanceServer interface {
 	CopyStoragePoolVolume(pool string, source InstanceServer, sourcePool string, volume api.StorageVolume, args *StoragePoolVolumeCopyArgs) (op RemoteOperation, err error)
 	MoveStoragePoolVolume(pool string, source InstanceServer, sourcePool string, volume api.StorageVolume, args *StoragePoolVolumeMoveArgs) (op RemoteOperation, err error)
 	MigrateStoragePoolVolume(pool string, volume api.StorageVolumePost) (op Operation, err error)
+	GetStoragePoolVolumeExportFile(pool string, volName string, req *BackupFileRequest) (resp *BackupFileResponse, err error)
+	CreateStoragePoolVolumeFromBackup(pool string, args StoragePoolVolumeBackupArgs) (op Operation, err error)
 
 	// Storage volume snapshot functions ("storage_api_volume_snapshots" API extension)
 	CreateStoragePoolVolumeSnapshot(pool string, volumeType string, volumeName string, snapshot api.StorageVolumeSnapshotsPost) (op Operation, err error)
@@ -286,6 +302,19 @@ type InstanceServer interface {
 	GetClusterMembers() (members []api.ClusterMember, err error)
 	GetClusterMember(name string) (member *api.ClusterMember, ETag string, err error)
 	RenameClusterMember(name string, member api.ClusterMemberPost) (err error)
+	UpdateClusterMember(name string, member api.ClusterMemberPut, ETag string) (err error)
+	GetClusterUpgrade() (upgrade *api.ClusterUpgrade, err error)
+	UpgradeClusterMember(name string) (err error)
+
+	// Warning functions ("warnings" API extension)
+	GetWarningUUIDs() (uuids []string, err error)
+	GetWarnings() (warnings []api.Warning, err error)
+	GetWarning(UUID string) (warning *api.Warning, ETag string, err error)
+	UpdateWarning(UUID string, warning api.WarningPut, ETag string) (err error)
+	DeleteWarning(UUID string) (err error)
+
+	// Audit log functions ("audit_log" API extension)
+	GetAuditEntries(args *AuditEntriesArgs) (entries []api.AuditEntry, err error)
 
 	// Internal functions (for internal use)
 	RawQuery(method string, path string, data interface{}, queryETag string) (resp *api.Response, ETag string, err error)
@@ -340,6 +369,9 @@ type ImageCreateArgs struct {
 
 	// Type of the image (container or virtual-machine)
 	Type string
+
+	// Upload the image in chunks of this size, resending chunks which failed (0 for a single request)
+	ChunkSize int64
 }
 
 // The ImageFileRequest struct is used for an image download request.
@@ -405,6 +437,9 @@ type StoragePoolVolumeCopyArgs struct {
 
 	// API extension: storage_api_volume_snapshots
 	VolumeOnly bool
+
+	// API extension: custom_volume_refresh
+	Refresh bool
 }
 
 // The StoragePoolVolumeMoveArgs struct is used to pass additional options
@@ -413,6 +448,37 @@ type StoragePoolVolumeMoveArgs struct {
 	StoragePoolVolumeCopyArgs
 }
 
+// The StoragePoolVolumeBackupArgs struct is used when creating a custom storage volume from an
+// exported tarball.
+type StoragePoolVolumeBackupArgs struct {
+	// The backup file
+	BackupFile io.Reader
+
+	// Name of the new volume (defaults to the exported one)
+	Name string
+}
+
+// The AuditEntriesArgs struct is used to filter the audit log entries.
+type AuditEntriesArgs struct {
+	// If set, only entries of this project are returned
+	Project string
+
+	// If set, only entries of this requestor are returned
+	Username string
+
+	// If set, only entries with this HTTP method are returned
+	Method string
+
+	// If set, only entries recorded at or after this time are returned
+	Since time.Time
+
+	// If set, only entries recorded before this time are returned
+	Until time.Time
+
+	// If set, only this many of the most recent entries are returned
+	Limit int
+}
+
 // The InstanceBackupArgs struct is used when creating a instance from a backup.
 type InstanceBackupArgs struct {
 	// The backup file
@@ -439,6 +505,10 @@ type InstanceCopyArgs struct {
 	// API extension: container_incremental_copy
 	// Perform an incremental copy
 	Refresh bool
+
+	// API extension: instance_copy_convert
+	// Convert a container into a virtual machine or the other way around
+	Convert bool
 }
 
 // The InstanceSnapshotCopyArgs struct is used to pass additional options during instance copy.
@@ -160,6 +160,10 @@ func lxdParseResponse(resp *http.Response) (*api.Response, string, error) {
 
 	// Handle errors
 	if response.Type == api.ErrorResponse {
+		if response.ErrorType != "" {
+			return nil, "", &api.TypedError{Code: response.Code, Type: response.ErrorType, Message: response.Error}
+		}
+
 		return nil, "", fmt.Errorf(response.Error)
 	}
 
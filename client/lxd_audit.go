@@ -0,0 +1,61 @@
+package lxd
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Audit log handling functions
+
+// GetAuditEntries returns the audit log entries matching the provided filters, oldest first
+func (r *ProtocolLXD) GetAuditEntries(args *AuditEntriesArgs) ([]api.AuditEntry, error) {
+	if !r.HasExtension("audit_log") {
+		return nil, fmt.Errorf("The server is missing the required \"audit_log\" API extension")
+	}
+
+	values := url.Values{}
+	if args != nil {
+		if args.Project != "" {
+			values.Set("project", args.Project)
+		}
+
+		if args.Username != "" {
+			values.Set("username", args.Username)
+		}
+
+		if args.Method != "" {
+			values.Set("method", args.Method)
+		}
+
+		if !args.Since.IsZero() {
+			values.Set("since", args.Since.Format(time.RFC3339))
+		}
+
+		if !args.Until.IsZero() {
+			values.Set("until", args.Until.Format(time.RFC3339))
+		}
+
+		if args.Limit > 0 {
+			values.Set("limit", strconv.Itoa(args.Limit))
+		}
+	}
+
+	path := "/audit"
+	if len(values) > 0 {
+		path += "?" + values.Encode()
+	}
+
+	entries := []api.AuditEntry{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", path, nil, "", &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -67,6 +67,21 @@ func (r *ProtocolLXD) CreateCertificate(certificate api.CertificatesPost) error
 	return nil
 }
 
+// CreateCertificateToken requests a certificate add token
+func (r *ProtocolLXD) CreateCertificateToken(certificate api.CertificatesPost) (Operation, error) {
+	if !r.HasExtension("certificate_token") {
+		return nil, fmt.Errorf("The server is missing the required \"certificate_token\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", "/certificates?token=true", certificate, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
 // UpdateCertificate updates the certificate definition
 func (r *ProtocolLXD) UpdateCertificate(fingerprint string, certificate api.CertificatePut, ETag string) error {
 	if !r.HasExtension("certificate_update") {
@@ -121,3 +121,46 @@ func (r *ProtocolLXD) RenameClusterMember(name string, member api.ClusterMemberP
 
 	return nil
 }
+
+// UpdateClusterMember updates information about the given member
+func (r *ProtocolLXD) UpdateClusterMember(name string, member api.ClusterMemberPut, ETag string) error {
+	if !r.HasExtension("clustering_roles_assign") {
+		return fmt.Errorf("The server is missing the required \"clustering_roles_assign\" API extension")
+	}
+
+	_, _, err := r.query("PUT", fmt.Sprintf("/cluster/members/%s", name), member, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetClusterUpgrade returns the version each member runs, for rolling upgrades
+func (r *ProtocolLXD) GetClusterUpgrade() (*api.ClusterUpgrade, error) {
+	if !r.HasExtension("clustering_upgrade") {
+		return nil, fmt.Errorf("The server is missing the required \"clustering_upgrade\" API extension")
+	}
+
+	upgrade := api.ClusterUpgrade{}
+	_, err := r.queryStruct("GET", "/cluster/upgrade", nil, "", &upgrade)
+	if err != nil {
+		return nil, err
+	}
+
+	return &upgrade, nil
+}
+
+// UpgradeClusterMember runs the update of the given out-of-date member
+func (r *ProtocolLXD) UpgradeClusterMember(name string) error {
+	if !r.HasExtension("clustering_upgrade") {
+		return fmt.Errorf("The server is missing the required \"clustering_upgrade\" API extension")
+	}
+
+	_, _, err := r.query("POST", "/cluster/upgrade", api.ClusterUpgradePost{ServerName: name}, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -342,7 +342,7 @@ func (r *ProtocolLXD) CopyContainer(source InstanceServer, container api.Contain
 	}
 
 	if req.Source.Live {
-		req.Source.Live = container.StatusCode == api.Running
+		req.Source.Live = container.StatusCode == api.Running || container.StatusCode == api.Ready
 	}
 
 	sourceInfo, err := source.GetConnectionInfo()
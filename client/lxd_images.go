@@ -1,6 +1,7 @@
 package lxd
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"fmt"
 	"io"
@@ -371,6 +372,10 @@ func (r *ProtocolLXD) CreateImage(image api.ImagesPost, args *ImageCreateArgs) (
 		return nil, fmt.Errorf("Metadata file is required")
 	}
 
+	if args.ChunkSize > 0 && !r.HasExtension("image_upload_resumable") {
+		return nil, fmt.Errorf("The server is missing the required \"image_upload_resumable\" API extension")
+	}
+
 	// Prepare the body
 	var body io.Reader
 	var contentType string
@@ -448,6 +453,19 @@ func (r *ProtocolLXD) CreateImage(image api.ImagesPost, args *ImageCreateArgs) (
 		contentType = w.FormDataContentType()
 	}
 
+	// Send the content ahead of the image creation request when uploading in chunks
+	var uploadID string
+	var uploadChecksum string
+	if args.ChunkSize > 0 {
+		var err error
+		uploadID, uploadChecksum, err = r.uploadImageChunks(body, args.ChunkSize)
+		if err != nil {
+			return nil, err
+		}
+
+		body = nil
+	}
+
 	// Prepare the HTTP request
 	reqURL, err := r.setQueryAttributes(fmt.Sprintf("%s/1.0/images", r.httpHost))
 	if err != nil {
@@ -461,6 +479,11 @@ func (r *ProtocolLXD) CreateImage(image api.ImagesPost, args *ImageCreateArgs) (
 
 	// Setup the headers
 	req.Header.Set("Content-Type", contentType)
+	if uploadID != "" {
+		req.Header.Set("X-LXD-upload", uploadID)
+		req.Header.Set("X-LXD-upload-sha256", uploadChecksum)
+	}
+
 	if image.Public {
 		req.Header.Set("X-LXD-public", "true")
 	}
@@ -513,6 +536,90 @@ func (r *ProtocolLXD) CreateImage(image api.ImagesPost, args *ImageCreateArgs) (
 	return &op, nil
 }
 
+// uploadImageChunks sends the content of an image upload in chunks, resending the chunks which
+// failed, and returns the upload ID along with the SHA256 of the content.
+func (r *ProtocolLXD) uploadImageChunks(body io.Reader, chunkSize int64) (string, string, error) {
+	upload := api.ImageUpload{}
+	_, err := r.queryStruct("POST", "/images/uploads", nil, "", &upload)
+	if err != nil {
+		return "", "", err
+	}
+
+	hash := sha256.New()
+	buf := make([]byte, chunkSize)
+	var offset int64
+
+	for {
+		n, err := io.ReadFull(body, buf)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return "", "", err
+		}
+
+		hash.Write(buf[:n])
+
+		// Each attempt restarts from the beginning of the chunk, the server drops anything past it.
+		for attempt := 1; ; attempt++ {
+			err = r.uploadImageChunk(upload.ID, offset, buf[:n])
+			if err == nil {
+				break
+			}
+
+			if attempt == 5 {
+				return "", "", fmt.Errorf("Failed to upload image chunk at offset %d: %v", offset, err)
+			}
+		}
+
+		offset += int64(n)
+	}
+
+	return upload.ID, fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// uploadImageChunk sends a single chunk of an image upload.
+func (r *ProtocolLXD) uploadImageChunk(id string, offset int64, chunk []byte) error {
+	reqURL, err := r.setQueryAttributes(fmt.Sprintf("%s/1.0/images/uploads/%s?offset=%d", r.httpHost, url.PathEscape(id), offset))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("PUT", reqURL, bytes.NewReader(chunk))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if r.httpUserAgent != "" {
+		req.Header.Set("User-Agent", r.httpUserAgent)
+	}
+
+	resp, err := r.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	response, _, err := lxdParseResponse(resp)
+	if err != nil {
+		return err
+	}
+
+	upload := api.ImageUpload{}
+	err = response.MetadataAsStruct(&upload)
+	if err != nil {
+		return err
+	}
+
+	if upload.Offset != offset+int64(len(chunk)) {
+		return fmt.Errorf("Server received %d bytes instead of %d", upload.Offset-offset, len(chunk))
+	}
+
+	return nil
+}
+
 // tryCopyImage iterates through the source server URLs until one lets it download the image
 func (r *ProtocolLXD) tryCopyImage(req api.ImagesPost, urls []string) (RemoteOperation, error) {
 	if len(urls) == 0 {
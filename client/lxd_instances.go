@@ -392,6 +392,17 @@ func (r *ProtocolLXD) CopyInstance(source InstanceServer, instance api.Instance,
 			}
 		}
 
+		if args.Convert {
+			if !r.HasExtension("instance_copy_convert") {
+				return nil, fmt.Errorf("The target server is missing the required \"instance_copy_convert\" API extension")
+			}
+
+			req.Type = api.InstanceTypeVM
+			if instance.Type == string(api.InstanceTypeVM) {
+				req.Type = api.InstanceTypeContainer
+			}
+		}
+
 		// Allow overriding the target name
 		if args.Name != "" {
 			req.Name = args.Name
@@ -401,10 +412,11 @@ func (r *ProtocolLXD) CopyInstance(source InstanceServer, instance api.Instance,
 		req.Source.InstanceOnly = args.InstanceOnly
 		req.Source.ContainerOnly = args.InstanceOnly // For legacy servers.
 		req.Source.Refresh = args.Refresh
+		req.Source.Convert = args.Convert
 	}
 
 	if req.Source.Live {
-		req.Source.Live = instance.StatusCode == api.Running
+		req.Source.Live = instance.StatusCode == api.Running || instance.StatusCode == api.Ready
 	}
 
 	sourceInfo, err := source.GetConnectionInfo()
@@ -452,6 +464,10 @@ func (r *ProtocolLXD) CopyInstance(source InstanceServer, instance api.Instance,
 		return &rop, nil
 	}
 
+	if req.Source.Convert {
+		return nil, fmt.Errorf("Instances can only be converted when copied within the same server")
+	}
+
 	// Source request
 	sourceReq := api.InstancePost{
 		Migration:     true,
@@ -1141,6 +1157,172 @@ func (r *ProtocolLXD) DeleteInstanceFile(instanceName string, filePath string) e
 	return nil
 }
 
+// instanceFileArchiveURL returns the URL of the tar stream of a path in the instance.
+func (r *ProtocolLXD) instanceFileArchiveURL(instanceName string, filePath string) (string, error) {
+	if !r.HasExtension("instance_file_archive") {
+		return "", fmt.Errorf("The server is missing the required \"instance_file_archive\" API extension")
+	}
+
+	var err error
+	var requestURL string
+
+	if r.IsAgent() {
+		requestURL, err = shared.URLEncode(
+			fmt.Sprintf("%s/1.0/files", r.httpHost),
+			map[string]string{"path": filePath, "archive": "1"})
+	} else {
+		var path string
+
+		path, _, err = r.instanceTypeToPath(api.InstanceTypeAny)
+		if err != nil {
+			return "", err
+		}
+
+		requestURL, err = shared.URLEncode(
+			fmt.Sprintf("%s/1.0%s/%s/files", r.httpHost, path, url.PathEscape(instanceName)),
+			map[string]string{"path": filePath, "archive": "1"})
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return r.setQueryAttributes(requestURL)
+}
+
+// GetInstanceFileArchive retrieves the tree at the path in the instance as a tar stream, whose
+// entry names are relative to the parent directory of the path.
+func (r *ProtocolLXD) GetInstanceFileArchive(instanceName string, filePath string) (io.ReadCloser, error) {
+	requestURL, err := r.instanceFileArchiveURL(instanceName, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the user agent
+	if r.httpUserAgent != "" {
+		req.Header.Set("User-Agent", r.httpUserAgent)
+	}
+
+	// Send the request
+	resp, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the return value for a cleaner error
+	if resp.StatusCode != http.StatusOK {
+		_, _, err := lxdParseResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resp.Body, nil
+}
+
+// CreateInstanceFileArchive extracts the tar stream into the existing directory at the path in
+// the instance.
+func (r *ProtocolLXD) CreateInstanceFileArchive(instanceName string, filePath string, content io.Reader) error {
+	requestURL, err := r.instanceFileArchiveURL(instanceName, filePath)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", requestURL, content)
+	if err != nil {
+		return err
+	}
+
+	// Set the user agent
+	if r.httpUserAgent != "" {
+		req.Header.Set("User-Agent", r.httpUserAgent)
+	}
+
+	req.Header.Set("Content-Type", "application/x-tar")
+
+	// Send the request
+	resp, err := r.do(req)
+	if err != nil {
+		return err
+	}
+
+	// Check the return value for a cleaner error
+	_, _, err = lxdParseResponse(resp)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetInstanceFileSFTPConn returns a connection to the SFTP server of the instance, which serves
+// the instance's filesystem.
+func (r *ProtocolLXD) GetInstanceFileSFTPConn(instanceName string) (io.ReadWriteCloser, error) {
+	if !r.HasExtension("instance_sftp") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_sftp\" API extension")
+	}
+
+	var requestURL string
+
+	if r.IsAgent() {
+		requestURL = fmt.Sprintf("%s/1.0/sftp", r.httpHost)
+	} else {
+		path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+		if err != nil {
+			return nil, err
+		}
+
+		requestURL = fmt.Sprintf("%s/1.0%s/%s/sftp", r.httpHost, path, url.PathEscape(instanceName))
+	}
+
+	requestURL, err := r.setQueryAttributes(requestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the user agent
+	if r.httpUserAgent != "" {
+		req.Header.Set("User-Agent", r.httpUserAgent)
+	}
+
+	req.Header.Set("Upgrade", "sftp")
+	req.Header.Set("Connection", "Upgrade")
+
+	// Send the request
+	resp, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the return value for a cleaner error
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		_, _, err := lxdParseResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("Unexpected status code %d while switching to SFTP", resp.StatusCode)
+	}
+
+	// The body of a response switching protocols is the connection itself.
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return nil, fmt.Errorf("The HTTP client doesn't support switching protocols")
+	}
+
+	return conn, nil
+}
+
 // GetInstanceSnapshotNames returns a list of snapshot names for the instance.
 func (r *ProtocolLXD) GetInstanceSnapshotNames(instanceName string) ([]string, error) {
 	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
@@ -1603,6 +1785,165 @@ func (r *ProtocolLXD) UpdateInstanceState(name string, state api.InstanceStatePu
 	return op, nil
 }
 
+// RunInstanceAgentCommand forwards a command to the agent of the virtual machine and returns its result.
+func (r *ProtocolLXD) RunInstanceAgentCommand(name string, req api.InstanceAgentPost) (*api.InstanceAgentResult, error) {
+	if !r.HasExtension("instance_agent_commands") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_agent_commands\" API extension")
+	}
+
+	var uri string
+
+	if r.IsAgent() {
+		uri = "/agent"
+	} else {
+		path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+		if err != nil {
+			return nil, err
+		}
+
+		uri = fmt.Sprintf("%s/%s/agent", path, url.PathEscape(name))
+	}
+
+	result := api.InstanceAgentResult{}
+
+	_, err := r.queryStruct("POST", uri, req, "", &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetInstanceUEFIVars returns the content of the UEFI variable store of a virtual machine.
+func (r *ProtocolLXD) GetInstanceUEFIVars(name string) (io.ReadCloser, error) {
+	if !r.HasExtension("instance_uefi_vars") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_uefi_vars\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepare the HTTP request
+	requestURL, err := r.setQueryAttributes(fmt.Sprintf("%s/1.0%s/%s/uefi-vars", r.httpHost, path, url.PathEscape(name)))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the user agent
+	if r.httpUserAgent != "" {
+		req.Header.Set("User-Agent", r.httpUserAgent)
+	}
+
+	// Send the request
+	resp, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the return value for a cleaner error
+	if resp.StatusCode != http.StatusOK {
+		_, _, err := lxdParseResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resp.Body, nil
+}
+
+// UpdateInstanceUEFIVars replaces the UEFI variable store of a stopped virtual machine.
+func (r *ProtocolLXD) UpdateInstanceUEFIVars(name string, content io.Reader) error {
+	if !r.HasExtension("instance_uefi_vars") {
+		return fmt.Errorf("The server is missing the required \"instance_uefi_vars\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return err
+	}
+
+	// Prepare the HTTP request
+	requestURL, err := r.setQueryAttributes(fmt.Sprintf("%s/1.0%s/%s/uefi-vars", r.httpHost, path, url.PathEscape(name)))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("PUT", requestURL, content)
+	if err != nil {
+		return err
+	}
+
+	// Set the user agent
+	if r.httpUserAgent != "" {
+		req.Header.Set("User-Agent", r.httpUserAgent)
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	// Send the request
+	resp, err := r.do(req)
+	if err != nil {
+		return err
+	}
+
+	// Check the return value for a cleaner error
+	_, _, err = lxdParseResponse(resp)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RunInstanceUEFIVarsAction resets the UEFI variable store of a stopped virtual machine or
+// enrolls custom Secure Boot keys in it.
+func (r *ProtocolLXD) RunInstanceUEFIVarsAction(name string, req api.InstanceUEFIVarsPost) error {
+	if !r.HasExtension("instance_uefi_vars") {
+		return fmt.Errorf("The server is missing the required \"instance_uefi_vars\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = r.query("POST", fmt.Sprintf("%s/%s/uefi-vars", path, url.PathEscape(name)), req, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ValidateInstanceDevices checks a proposed set of devices against the instance and returns the
+// changes applying it would make, without applying anything.
+func (r *ProtocolLXD) ValidateInstanceDevices(name string, req api.InstanceDevicesValidatePost) (*api.InstanceDevicesValidate, error) {
+	if !r.HasExtension("instance_devices_validate") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_devices_validate\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	result := api.InstanceDevicesValidate{}
+
+	_, err = r.queryStruct("POST", fmt.Sprintf("%s/%s/devices:validate", path, url.PathEscape(name)), req, "", &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
 // GetInstanceLogfiles returns a list of logfiles for the instance.
 func (r *ProtocolLXD) GetInstanceLogfiles(name string) ([]string, error) {
 	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
@@ -89,6 +89,23 @@ func (r *ProtocolLXD) GetServerResources() (*api.Resources, error) {
 	return &resources, nil
 }
 
+// GetMetadataConfiguration returns the documentation of the config keys known to the LXD server
+func (r *ProtocolLXD) GetMetadataConfiguration() (*api.MetadataConfiguration, error) {
+	if !r.HasExtension("metadata_configuration") {
+		return nil, fmt.Errorf("The server is missing the required \"metadata_configuration\" API extension")
+	}
+
+	metadata := api.MetadataConfiguration{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/metadata/configuration", nil, "", &metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadata, nil
+}
+
 // UseProject returns a client that will use a specific project.
 func (r *ProtocolLXD) UseProject(name string) InstanceServer {
 	return &ProtocolLXD{
@@ -102,6 +102,47 @@ func (r *ProtocolLXD) UpdateStoragePool(name string, pool api.StoragePoolPut, ET
 	return nil
 }
 
+// UpdateStoragePoolPropagate updates the pool to match the provided StoragePool struct and applies
+// the changed volume defaults to the custom volumes which didn't override them
+func (r *ProtocolLXD) UpdateStoragePoolPropagate(name string, pool api.StoragePoolPut, ETag string) error {
+	if !r.HasExtension("storage_volume_defaults_propagation") {
+		return fmt.Errorf("The server is missing the required \"storage_volume_defaults_propagation\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("PUT", fmt.Sprintf("/storage-pools/%s?propagate=true", url.PathEscape(name)), pool, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateStoragePoolPropagateDryRun returns the names of the custom volumes which
+// UpdateStoragePoolPropagate would change, without updating anything
+func (r *ProtocolLXD) UpdateStoragePoolPropagateDryRun(name string, pool api.StoragePoolPut, ETag string) ([]string, error) {
+	if !r.HasExtension("storage_volume_defaults_propagation") {
+		return nil, fmt.Errorf("The server is missing the required \"storage_volume_defaults_propagation\" API extension")
+	}
+
+	urls := []string{}
+
+	// Send the request
+	_, err := r.queryStruct("PUT", fmt.Sprintf("/storage-pools/%s?propagate=dry-run", url.PathEscape(name)), pool, ETag, &urls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse it
+	names := []string{}
+	for _, url := range urls {
+		fields := strings.Split(url, "/volumes/custom/")
+		names = append(names, fields[len(fields)-1])
+	}
+
+	return names, nil
+}
+
 // DeleteStoragePool deletes a storage pool
 func (r *ProtocolLXD) DeleteStoragePool(name string) error {
 	if !r.HasExtension("storage") {
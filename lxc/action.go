@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	lxd "github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/lxc/config"
 	"github.com/lxc/lxd/lxc/utils"
 	"github.com/lxc/lxd/shared"
@@ -97,11 +99,12 @@ func (c *cmdStop) Command() *cobra.Command {
 type cmdAction struct {
 	global *cmdGlobal
 
-	flagAll       bool
-	flagForce     bool
-	flagStateful  bool
-	flagStateless bool
-	flagTimeout   int
+	flagAll          bool
+	flagForce        bool
+	flagStateful     bool
+	flagStateless    bool
+	flagTimeout      int
+	flagWaitForReady bool
 }
 
 func (c *cmdAction) Command(action string) *cobra.Command {
@@ -121,6 +124,10 @@ func (c *cmdAction) Command(action string) *cobra.Command {
 		cmd.Flags().IntVar(&c.flagTimeout, "timeout", -1, i18n.G("Time to wait for the container before killing it")+"``")
 	}
 
+	if shared.StringInSlice(action, []string{"start", "restart"}) {
+		cmd.Flags().BoolVar(&c.flagWaitForReady, "wait-for-ready", false, i18n.G("Wait for the container to report being ready through /dev/lxd"))
+	}
+
 	return cmd
 }
 
@@ -198,6 +205,10 @@ func (c *cmdAction) doAction(action string, conf *config.Config, nameArg string)
 
 	progress.Done("")
 
+	if c.flagWaitForReady && shared.StringInSlice(action, []string{"start", "restart"}) {
+		return c.waitForReady(d, name)
+	}
+
 	return nil
 }
 
@@ -224,7 +235,7 @@ func (c *cmdAction) Run(cmd *cobra.Command, args []string) error {
 		for _, ct := range ctslist {
 			switch cmd.Name() {
 			case "start":
-				if ct.StatusCode == api.Running {
+				if ct.StatusCode == api.Running || ct.StatusCode == api.Ready {
 					continue
 				}
 			case "stop":
@@ -271,3 +282,22 @@ func (c *cmdAction) Run(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// waitForReady waits until the guest reports being ready through devlxd.
+func (c *cmdAction) waitForReady(d lxd.InstanceServer, name string) error {
+	for {
+		state, _, err := d.GetInstanceState(name)
+		if err != nil {
+			return err
+		}
+
+		switch state.StatusCode {
+		case api.Ready:
+			return nil
+		case api.Stopped, api.Error:
+			return fmt.Errorf(i18n.G("The container stopped before becoming ready"))
+		}
+
+		time.Sleep(time.Second)
+	}
+}
@@ -33,6 +33,10 @@ func (c *cmdCluster) Command() *cobra.Command {
 	clusterListCmd := cmdClusterList{global: c.global, cluster: c}
 	cmd.AddCommand(clusterListCmd.Command())
 
+	// Role
+	clusterRoleCmd := cmdClusterRole{global: c.global, cluster: c}
+	cmd.AddCommand(clusterRoleCmd.Command())
+
 	// Rename
 	clusterRenameCmd := cmdClusterRename{global: c.global, cluster: c}
 	cmd.AddCommand(clusterRenameCmd.Command())
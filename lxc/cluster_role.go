@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/lxc/lxd/shared"
+	cli "github.com/lxc/lxd/shared/cmd"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+type cmdClusterRole struct {
+	global  *cmdGlobal
+	cluster *cmdCluster
+}
+
+func (c *cmdClusterRole) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = i18n.G("role")
+	cmd.Short = i18n.G("Manage cluster roles")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Manage cluster roles`))
+
+	// Add
+	clusterRoleAddCmd := cmdClusterRoleAdd{global: c.global, cluster: c.cluster, clusterRole: c}
+	cmd.AddCommand(clusterRoleAddCmd.Command())
+
+	// Remove
+	clusterRoleRemoveCmd := cmdClusterRoleRemove{global: c.global, cluster: c.cluster, clusterRole: c}
+	cmd.AddCommand(clusterRoleRemoveCmd.Command())
+
+	return cmd
+}
+
+// Add
+type cmdClusterRoleAdd struct {
+	global      *cmdGlobal
+	cluster     *cmdCluster
+	clusterRole *cmdClusterRole
+}
+
+func (c *cmdClusterRoleAdd) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = i18n.G("add [<remote>:]<member> <role[,role...]>")
+	cmd.Short = i18n.G("Add roles to a cluster member")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Add roles to a cluster member`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdClusterRoleAdd) Run(cmd *cobra.Command, args []string) error {
+	// Sanity checks
+	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	if exit {
+		return err
+	}
+
+	// Parse remote
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	// Get the member information
+	member, etag, err := resource.server.GetClusterMember(resource.name)
+	if err != nil {
+		return err
+	}
+
+	memberWritable := member.Writable()
+	for _, role := range strings.Split(args[1], ",") {
+		if shared.StringInSlice(role, memberWritable.Roles) {
+			return fmt.Errorf(i18n.G("Member %q already has role %q"), resource.name, role)
+		}
+
+		memberWritable.Roles = append(memberWritable.Roles, role)
+	}
+
+	return resource.server.UpdateClusterMember(resource.name, memberWritable, etag)
+}
+
+// Remove
+type cmdClusterRoleRemove struct {
+	global      *cmdGlobal
+	cluster     *cmdCluster
+	clusterRole *cmdClusterRole
+}
+
+func (c *cmdClusterRoleRemove) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = i18n.G("remove [<remote>:]<member> <role[,role...]>")
+	cmd.Aliases = []string{"rm"}
+	cmd.Short = i18n.G("Remove roles from a cluster member")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Remove roles from a cluster member`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdClusterRoleRemove) Run(cmd *cobra.Command, args []string) error {
+	// Sanity checks
+	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	if exit {
+		return err
+	}
+
+	// Parse remote
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	// Get the member information
+	member, etag, err := resource.server.GetClusterMember(resource.name)
+	if err != nil {
+		return err
+	}
+
+	memberWritable := member.Writable()
+	roles := []string{}
+	removed := strings.Split(args[1], ",")
+	for _, role := range removed {
+		if !shared.StringInSlice(role, memberWritable.Roles) {
+			return fmt.Errorf(i18n.G("Member %q doesn't have role %q"), resource.name, role)
+		}
+	}
+
+	for _, role := range memberWritable.Roles {
+		if !shared.StringInSlice(role, removed) {
+			roles = append(roles, role)
+		}
+	}
+
+	memberWritable.Roles = roles
+
+	return resource.server.UpdateClusterMember(resource.name, memberWritable, etag)
+}
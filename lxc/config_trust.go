@@ -6,6 +6,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -49,6 +50,10 @@ type cmdConfigTrustAdd struct {
 	global      *cmdGlobal
 	config      *cmdConfig
 	configTrust *cmdConfigTrust
+
+	flagToken      bool
+	flagRestricted bool
+	flagProjects   string
 }
 
 func (c *cmdConfigTrustAdd) Command() *cobra.Command {
@@ -56,7 +61,13 @@ func (c *cmdConfigTrustAdd) Command() *cobra.Command {
 	cmd.Use = i18n.G("add [<remote>:] <cert>")
 	cmd.Short = i18n.G("Add new trusted clients")
 	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
-		`Add new trusted clients`))
+		`Add new trusted clients
+
+With --token, the argument is the name of the new client and a single-use
+token is printed. The client can then add itself with "lxc remote add <name> <token>".`))
+	cmd.Flags().BoolVar(&c.flagToken, "token", false, i18n.G("Generate a certificate add token for the named client"))
+	cmd.Flags().BoolVar(&c.flagRestricted, "restricted", false, i18n.G("Restrict the certificate to one or more projects"))
+	cmd.Flags().StringVar(&c.flagProjects, "projects", "", i18n.G("List of projects to restrict the certificate to")+"``")
 
 	cmd.RunE = c.Run
 
@@ -83,6 +94,31 @@ func (c *cmdConfigTrustAdd) Run(cmd *cobra.Command, args []string) error {
 
 	resource := resources[0]
 
+	// Generate a certificate add token
+	if c.flagToken {
+		cert := api.CertificatesPost{}
+		cert.Name = args[len(args)-1]
+		cert.Type = "client"
+		cert.Restricted = c.flagRestricted
+		if c.flagProjects != "" {
+			cert.Projects = strings.Split(c.flagProjects, ",")
+		}
+
+		op, err := resource.server.CreateCertificateToken(cert)
+		if err != nil {
+			return err
+		}
+
+		token, ok := op.Get().Metadata["token"].(string)
+		if !ok {
+			return fmt.Errorf(i18n.G("Failed to get the certificate add token"))
+		}
+
+		fmt.Printf(i18n.G("Client %s certificate add token:")+"\n", cert.Name)
+		fmt.Println(token)
+		return nil
+	}
+
 	// Add trust relationship
 	fname := args[len(args)-1]
 	x509Cert, err := shared.ReadCert(shared.HostPath(fname))
@@ -95,6 +131,10 @@ func (c *cmdConfigTrustAdd) Run(cmd *cobra.Command, args []string) error {
 	cert.Certificate = base64.StdEncoding.EncodeToString(x509Cert.Raw)
 	cert.Name = name
 	cert.Type = "client"
+	cert.Restricted = c.flagRestricted
+	if c.flagProjects != "" {
+		cert.Projects = strings.Split(c.flagProjects, ",")
+	}
 
 	return resource.server.CreateCertificate(cert)
 }
@@ -152,6 +192,11 @@ func (c *cmdConfigTrustList) Run(cmd *cobra.Command, args []string) error {
 	for _, cert := range trust {
 		fp := cert.Fingerprint[0:12]
 
+		projects := "-"
+		if cert.Restricted {
+			projects = strings.Join(cert.Projects, ", ")
+		}
+
 		certBlock, _ := pem.Decode([]byte(cert.Certificate))
 		if certBlock == nil {
 			return fmt.Errorf(i18n.G("Invalid certificate"))
@@ -165,7 +210,7 @@ func (c *cmdConfigTrustList) Run(cmd *cobra.Command, args []string) error {
 		const layout = "Jan 2, 2006 at 3:04pm (MST)"
 		issue := cert.NotBefore.Format(layout)
 		expiry := cert.NotAfter.Format(layout)
-		data = append(data, []string{fp, cert.Subject.CommonName, issue, expiry})
+		data = append(data, []string{fp, cert.Subject.CommonName, issue, expiry, projects})
 	}
 	sort.Sort(stringList(data))
 
@@ -174,6 +219,7 @@ func (c *cmdConfigTrustList) Run(cmd *cobra.Command, args []string) error {
 		i18n.G("COMMON NAME"),
 		i18n.G("ISSUE DATE"),
 		i18n.G("EXPIRY DATE"),
+		i18n.G("PROJECTS"),
 	}
 
 	return utils.RenderTable(c.flagFormat, header, data, trust)
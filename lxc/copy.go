@@ -31,6 +31,7 @@ type cmdCopy struct {
 	flagTarget        string
 	flagTargetProject string
 	flagRefresh       bool
+	flagConvert       bool
 }
 
 func (c *cmdCopy) Command() *cobra.Command {
@@ -55,6 +56,7 @@ func (c *cmdCopy) Command() *cobra.Command {
 	cmd.Flags().StringVar(&c.flagTargetProject, "target-project", "", i18n.G("Copy to a project different from the source")+"``")
 	cmd.Flags().BoolVar(&c.flagNoProfiles, "no-profiles", false, i18n.G("Create the container with no profiles applied"))
 	cmd.Flags().BoolVar(&c.flagRefresh, "refresh", false, i18n.G("Perform an incremental copy"))
+	cmd.Flags().BoolVar(&c.flagConvert, "convert", false, i18n.G("Convert a container into a virtual machine or a virtual machine into a container"))
 
 	return cmd
 }
@@ -164,6 +166,10 @@ func (c *cmdCopy) copyContainer(conf *config.Config, sourceResource string, dest
 			return fmt.Errorf(i18n.G("--refresh can only be used with containers"))
 		}
 
+		if c.flagConvert {
+			return fmt.Errorf(i18n.G("--convert can't be used with snapshots"))
+		}
+
 		// Copy of a snapshot into a new container
 		srcFields := strings.SplitN(sourceName, shared.SnapshotDelimiter, 2)
 		entry, _, err := source.GetInstanceSnapshot(srcFields[0], srcFields[1])
@@ -256,6 +262,7 @@ func (c *cmdCopy) copyContainer(conf *config.Config, sourceResource string, dest
 			InstanceOnly: containerOnly,
 			Mode:         mode,
 			Refresh:      c.flagRefresh,
+			Convert:      c.flagConvert,
 		}
 
 		// Copy of a container into a new container
@@ -264,7 +271,7 @@ func (c *cmdCopy) copyContainer(conf *config.Config, sourceResource string, dest
 			return err
 		}
 
-		if entry.StatusCode == api.Running && move && !stateful {
+		if (entry.StatusCode == api.Running || entry.StatusCode == api.Ready) && move && !stateful {
 			start = true
 		}
 
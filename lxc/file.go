@@ -291,7 +291,11 @@ func (c *cmdFilePull) Run(cmd *cobra.Command, args []string) error {
 					targetIsDir = true
 				}
 
-				err := c.file.recursivePullFile(resource.server, pathSpec[0], pathSpec[1], target)
+				if fileArchiveSupported && resource.server.HasExtension("instance_file_archive") {
+					err = c.file.archivePullFile(resource.server, pathSpec[0], pathSpec[1], target)
+				} else {
+					err = c.file.recursivePullFile(resource.server, pathSpec[0], pathSpec[1], target)
+				}
 				if err != nil {
 					return err
 				}
@@ -503,7 +507,11 @@ func (c *cmdFilePush) Run(cmd *cobra.Command, args []string) error {
 
 		// Transfer the files
 		for _, fname := range sourcefilenames {
-			err := c.file.recursivePushFile(resource.server, resource.name, fname, targetPath)
+			if fileArchiveSupported && resource.server.HasExtension("instance_file_archive") {
+				err = c.file.archivePushFile(resource.server, resource.name, fname, targetPath)
+			} else {
+				err = c.file.recursivePushFile(resource.server, resource.name, fname, targetPath)
+			}
 			if err != nil {
 				return err
 			}
@@ -821,6 +829,72 @@ func (c *cmdFile) recursivePushFile(d lxd.InstanceServer, container string, sour
 	return filepath.Walk(source, sendFile)
 }
 
+// archivePullFile pulls the tree at p into targetDir as a single tar stream.
+func (c *cmdFile) archivePullFile(d lxd.InstanceServer, container string, p string, targetDir string) error {
+	logger.Infof("Pulling %s from %s (archive)", targetDir, p)
+
+	buf, err := d.GetInstanceFileArchive(container, p)
+	if err != nil {
+		return err
+	}
+	defer buf.Close()
+
+	progress := utils.ProgressRenderer{
+		Format: fmt.Sprintf(i18n.G("Pulling %s from %s: %%s"), p, targetDir),
+		Quiet:  c.global.flagQuiet,
+	}
+
+	reader := &ioprogress.ProgressReader{
+		ReadCloser: buf,
+		Tracker: &ioprogress.ProgressTracker{
+			Handler: func(bytesReceived int64, speed int64) {
+				progress.UpdateProgress(ioprogress.ProgressData{
+					Text: fmt.Sprintf("%s (%s/s)",
+						units.GetByteSizeString(bytesReceived, 2),
+						units.GetByteSizeString(speed, 2))})
+			},
+		},
+	}
+
+	err = fileArchiveExtract(reader, targetDir)
+	progress.Done("")
+	return err
+}
+
+// archivePushFile pushes the tree at source into target as a single tar stream.
+func (c *cmdFile) archivePushFile(d lxd.InstanceServer, container string, source string, target string) error {
+	source = filepath.Clean(source)
+	logger.Infof("Pushing %s to %s (archive)", source, target)
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+
+	go func() {
+		pw.CloseWithError(fileArchiveWrite(pw, source))
+	}()
+
+	progress := utils.ProgressRenderer{
+		Format: fmt.Sprintf(i18n.G("Pushing %s to %s: %%s"), source, target),
+		Quiet:  c.global.flagQuiet,
+	}
+
+	reader := &ioprogress.ProgressReader{
+		ReadCloser: pr,
+		Tracker: &ioprogress.ProgressTracker{
+			Handler: func(bytesSent int64, speed int64) {
+				progress.UpdateProgress(ioprogress.ProgressData{
+					Text: fmt.Sprintf("%s (%s/s)",
+						units.GetByteSizeString(bytesSent, 2),
+						units.GetByteSizeString(speed, 2))})
+			},
+		},
+	}
+
+	err := d.CreateInstanceFileArchive(container, target, reader)
+	progress.Done("")
+	return err
+}
+
 func (c *cmdFile) recursiveMkdir(d lxd.InstanceServer, container string, p string, mode *os.FileMode, uid int64, gid int64) error {
 	/* special case, every container has a /, we don't need to do anything */
 	if p == "/" {
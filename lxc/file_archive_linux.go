@@ -0,0 +1,21 @@
+// +build linux
+
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/lxc/lxd/shared/filearchive"
+)
+
+// fileArchiveSupported is whether trees can be transferred as tar streams on this platform.
+const fileArchiveSupported = true
+
+func fileArchiveWrite(w io.Writer, path string) error {
+	return filearchive.Write(w, path)
+}
+
+func fileArchiveExtract(r io.Reader, path string) error {
+	return filearchive.Extract(r, path, os.Geteuid() == 0)
+}
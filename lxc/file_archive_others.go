@@ -0,0 +1,19 @@
+// +build !linux
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// fileArchiveSupported is whether trees can be transferred as tar streams on this platform.
+const fileArchiveSupported = false
+
+func fileArchiveWrite(w io.Writer, path string) error {
+	return fmt.Errorf("Archive transfers aren't supported on this platform")
+}
+
+func fileArchiveExtract(r io.Reader, path string) error {
+	return fmt.Errorf("Archive transfers aren't supported on this platform")
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -129,6 +130,26 @@ func (c *cmdInfo) renderGPU(gpu api.ResourcesGPUCard, prefix string, initial boo
 		fmt.Printf(prefix+"  "+i18n.G("UUID: %v")+"\n", gpu.Nvidia.UUID)
 	}
 
+	if len(gpu.Mdev) > 0 {
+		fmt.Printf(prefix + i18n.G("Mdev profiles:") + "\n")
+
+		keys := []string{}
+		for k := range gpu.Mdev {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			mdev := gpu.Mdev[k]
+			fmt.Printf(prefix+"  - "+i18n.G("%s (%s) (%d available)")+"\n", k, mdev.Name, mdev.Available)
+			if mdev.Description != "" {
+				for _, line := range strings.Split(mdev.Description, "\n") {
+					fmt.Printf(prefix+"      %s\n", line)
+				}
+			}
+		}
+	}
+
 	if gpu.SRIOV != nil {
 		fmt.Printf(prefix + i18n.G("SR-IOV information:") + "\n")
 		fmt.Printf(prefix+"  "+i18n.G("Current number of VFs: %d")+"\n", gpu.SRIOV.CurrentVFs)
@@ -266,6 +287,39 @@ func (c *cmdInfo) renderDisk(disk api.ResourcesStorageDisk, prefix string, initi
 	}
 }
 
+func (c *cmdInfo) renderUSB(usb api.ResourcesUSBDevice, prefix string) {
+	fmt.Printf(prefix+i18n.G("Vendor: %v (%v)")+"\n", usb.Vendor, usb.VendorID)
+	fmt.Printf(prefix+i18n.G("Product: %v (%v)")+"\n", usb.Product, usb.ProductID)
+	fmt.Printf(prefix+i18n.G("Bus Address: %v")+"\n", usb.BusAddress)
+	fmt.Printf(prefix+i18n.G("Device Address: %v")+"\n", usb.DeviceAddress)
+
+	if usb.Serial != "" {
+		fmt.Printf(prefix+i18n.G("Serial: %v")+"\n", usb.Serial)
+	}
+}
+
+func (c *cmdInfo) renderPCI(pci api.ResourcesPCIDevice, prefix string) {
+	fmt.Printf(prefix+i18n.G("Address: %v")+"\n", pci.PCIAddress)
+
+	if pci.Vendor != "" {
+		fmt.Printf(prefix+i18n.G("Vendor: %v (%v)")+"\n", pci.Vendor, pci.VendorID)
+	}
+
+	if pci.Product != "" {
+		fmt.Printf(prefix+i18n.G("Product: %v (%v)")+"\n", pci.Product, pci.ProductID)
+	}
+
+	if pci.Driver != "" {
+		fmt.Printf(prefix+i18n.G("Driver: %v (%v)")+"\n", pci.Driver, pci.DriverVersion)
+	}
+
+	fmt.Printf(prefix+i18n.G("NUMA node: %v")+"\n", pci.NUMANode)
+
+	if pci.IOMMUGroup != nil {
+		fmt.Printf(prefix+i18n.G("IOMMU group: %v")+"\n", *pci.IOMMUGroup)
+	}
+}
+
 func (c *cmdInfo) renderCPU(cpu api.ResourcesCPUSocket, prefix string) {
 	if cpu.Vendor != "" {
 		fmt.Printf(prefix+i18n.G("Vendor: %v")+"\n", cpu.Vendor)
@@ -334,6 +388,10 @@ func (c *cmdInfo) remoteInfo(d lxd.InstanceServer) error {
 			}
 		}
 
+		if resources.CPU.SEV != nil && resources.CPU.SEV.Supported {
+			fmt.Printf("  "+i18n.G("AMD SEV: %v (ES: %v, SNP: %v)")+"\n", resources.CPU.SEV.Supported, resources.CPU.SEV.ES, resources.CPU.SEV.SNP)
+		}
+
 		// Memory
 		fmt.Printf("\n" + i18n.G("Memory:") + "\n")
 		if resources.Memory.HugepagesTotal > 0 {
@@ -356,6 +414,9 @@ func (c *cmdInfo) remoteInfo(d lxd.InstanceServer) error {
 				fmt.Printf("      "+i18n.G("Free: %v")+"\n", units.GetByteSizeString(int64(node.Total-node.Used), 2))
 				fmt.Printf("      "+i18n.G("Used: %v")+"\n", units.GetByteSizeString(int64(node.Used), 2))
 				fmt.Printf("      "+i18n.G("Total: %v")+"\n", units.GetByteSizeString(int64(node.Total), 2))
+				if len(node.Distances) > 0 {
+					fmt.Printf("      "+i18n.G("Distances: %v")+"\n", node.Distances)
+				}
 			}
 		}
 
@@ -399,6 +460,30 @@ func (c *cmdInfo) remoteInfo(d lxd.InstanceServer) error {
 			}
 		}
 
+		// USB
+		if len(resources.USB.Devices) == 1 {
+			fmt.Printf("\n" + i18n.G("USB device:") + "\n")
+			c.renderUSB(resources.USB.Devices[0], "  ")
+		} else if len(resources.USB.Devices) > 1 {
+			fmt.Printf("\n" + i18n.G("USB devices:") + "\n")
+			for id, usb := range resources.USB.Devices {
+				fmt.Printf("  "+i18n.G("Device %d:")+"\n", id)
+				c.renderUSB(usb, "    ")
+			}
+		}
+
+		// PCI
+		if len(resources.PCI.Devices) == 1 {
+			fmt.Printf("\n" + i18n.G("PCI device:") + "\n")
+			c.renderPCI(resources.PCI.Devices[0], "  ")
+		} else if len(resources.PCI.Devices) > 1 {
+			fmt.Printf("\n" + i18n.G("PCI devices:") + "\n")
+			for id, pci := range resources.PCI.Devices {
+				fmt.Printf("  "+i18n.G("Device %d:")+"\n", id)
+				c.renderPCI(pci, "    ")
+			}
+		}
+
 		return nil
 	}
 
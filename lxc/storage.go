@@ -45,10 +45,18 @@ func (c *cmdStorage) Command() *cobra.Command {
 	storageEditCmd := cmdStorageEdit{global: c.global, storage: c}
 	cmd.AddCommand(storageEditCmd.Command())
 
+	// Export
+	storageExportCmd := cmdStorageExport{global: c.global, storage: c}
+	cmd.AddCommand(storageExportCmd.Command())
+
 	// Get
 	storageGetCmd := cmdStorageGet{global: c.global, storage: c}
 	cmd.AddCommand(storageGetCmd.Command())
 
+	// Import
+	storageImportCmd := cmdStorageImport{global: c.global, storage: c}
+	cmd.AddCommand(storageImportCmd.Command())
+
 	// Info
 	storageInfoCmd := cmdStorageInfo{global: c.global, storage: c}
 	cmd.AddCommand(storageInfoCmd.Command())
@@ -446,6 +454,7 @@ func (c *cmdStorageInfo) Run(cmd *cobra.Command, args []string) error {
 	descriptionstring := i18n.G("description")
 	totalspacestring := i18n.G("total space")
 	spaceusedstring := i18n.G("space used")
+	healthstring := i18n.G("health")
 
 	// Initialize the usedby map
 	poolusedby[usedbystring] = map[string][]string{}
@@ -468,6 +477,13 @@ func (c *cmdStorageInfo) Run(cmd *cobra.Command, args []string) error {
 	poolinfo[infostring][namestring] = pool.Name
 	poolinfo[infostring][driverstring] = pool.Driver
 	poolinfo[infostring][descriptionstring] = pool.Description
+	if pool.Health != "" {
+		poolinfo[infostring][healthstring] = pool.Health
+		if pool.HealthMessage != "" {
+			poolinfo[infostring][healthstring] = fmt.Sprintf("%s (%s)", pool.Health, pool.HealthMessage)
+		}
+	}
+
 	if c.flagBytes {
 		poolinfo[infostring][totalspacestring] = strconv.FormatUint(res.Space.Total, 10)
 		poolinfo[infostring][spaceusedstring] = strconv.FormatUint(res.Space.Used, 10)
@@ -573,6 +589,9 @@ func (c *cmdStorageList) Run(cmd *cobra.Command, args []string) error {
 type cmdStorageSet struct {
 	global  *cmdGlobal
 	storage *cmdStorage
+
+	flagPropagate bool
+	flagDryRun    bool
 }
 
 func (c *cmdStorageSet) Command() *cobra.Command {
@@ -583,9 +602,15 @@ func (c *cmdStorageSet) Command() *cobra.Command {
 		`Set storage pool configuration keys
 
 For backward compatibility, a single configuration key may still be set with:
-    lxc storage set [<remote>:]<pool> <key> <value>`))
+    lxc storage set [<remote>:]<pool> <key> <value>
+
+With --propagate, changes to the volume.* defaults also apply to the existing
+custom volumes which didn't override them. --dry-run lists those volumes
+without changing anything.`))
 
 	cmd.Flags().StringVar(&c.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.Flags().BoolVar(&c.flagPropagate, "propagate", false, i18n.G("Apply the new volume defaults to the existing volumes"))
+	cmd.Flags().BoolVar(&c.flagDryRun, "dry-run", false, i18n.G("List the volumes --propagate would change"))
 	cmd.RunE = c.Run
 
 	return cmd
@@ -611,8 +636,14 @@ func (c *cmdStorageSet) Run(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf(i18n.G("Missing pool name"))
 	}
 
+	// If a target member was specified, the member-specific config values are updated.
+	client := resource.server
+	if c.storage.flagTarget != "" {
+		client = client.UseTarget(c.storage.flagTarget)
+	}
+
 	// Get the pool entry
-	pool, etag, err := resource.server.GetStoragePool(resource.name)
+	pool, etag, err := client.GetStoragePool(resource.name)
 	if err != nil {
 		return err
 	}
@@ -628,7 +659,24 @@ func (c *cmdStorageSet) Run(cmd *cobra.Command, args []string) error {
 		pool.Config[k] = v
 	}
 
-	err = resource.server.UpdateStoragePool(resource.name, pool.Writable(), etag)
+	if c.flagDryRun {
+		volumes, err := client.UpdateStoragePoolPropagateDryRun(resource.name, pool.Writable(), etag)
+		if err != nil {
+			return err
+		}
+
+		for _, volume := range volumes {
+			fmt.Println(volume)
+		}
+
+		return nil
+	}
+
+	if c.flagPropagate {
+		return client.UpdateStoragePoolPropagate(resource.name, pool.Writable(), etag)
+	}
+
+	err = client.UpdateStoragePool(resource.name, pool.Writable(), etag)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxc/utils"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	cli "github.com/lxc/lxd/shared/cmd"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+// Export
+type cmdStorageExport struct {
+	global  *cmdGlobal
+	storage *cmdStorage
+}
+
+func (c *cmdStorageExport) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = i18n.G("export [<remote>:]<pool> <directory>")
+	cmd.Short = i18n.G("Export storage pools")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Export a storage pool with its custom volumes and instances
+
+The directory receives the pool definition in pool.yaml, a tarball for each
+custom volume in volumes/ and a backup tarball for each instance in
+instances/<project>/.`))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc storage export default /mnt/backups/default
+    Export the default pool and everything stored on it.`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdStorageExport) Run(cmd *cobra.Command, args []string) error {
+	// Sanity checks
+	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	if exit {
+		return err
+	}
+
+	// Parse remote
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+	client := resource.server
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing pool name"))
+	}
+
+	pool, _, err := client.GetStoragePool(resource.name)
+	if err != nil {
+		return err
+	}
+
+	target := shared.HostPath(args[1])
+	err = os.MkdirAll(target, 0700)
+	if err != nil {
+		return err
+	}
+
+	// Pool definition
+	data, err := yaml.Marshal(api.StoragePoolsPost{
+		StoragePoolPut: pool.StoragePoolPut,
+		Name:           pool.Name,
+		Driver:         pool.Driver,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(target, "pool.yaml"), data, 0600)
+	if err != nil {
+		return err
+	}
+
+	// Custom volumes
+	volumes, err := client.GetStoragePoolVolumes(pool.Name)
+	if err != nil {
+		return err
+	}
+
+	for _, volume := range volumes {
+		if volume.Type != "custom" || shared.IsSnapshot(volume.Name) {
+			continue
+		}
+
+		if volume.ContentType == "block" {
+			fmt.Printf(i18n.G("Skipping block volume %s")+"\n", volume.Name)
+			continue
+		}
+
+		err = c.exportVolume(client, pool.Name, volume.Name, filepath.Join(target, "volumes"))
+		if err != nil {
+			return errors.Wrapf(err, "Export volume %s", volume.Name)
+		}
+	}
+
+	// Instances
+	projects := []string{"default"}
+	if client.HasExtension("projects") {
+		projects, err = client.GetProjectNames()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, project := range projects {
+		projectClient := client.UseProject(project)
+
+		instances, err := projectClient.GetInstances(api.InstanceTypeAny)
+		if err != nil {
+			return err
+		}
+
+		for _, inst := range instances {
+			_, rootDisk, err := shared.GetRootDiskDevice(inst.ExpandedDevices)
+			if err != nil || rootDisk["pool"] != pool.Name {
+				continue
+			}
+
+			if inst.Type != string(api.InstanceTypeContainer) {
+				fmt.Printf(i18n.G("Skipping virtual machine %s")+"\n", inst.Name)
+				continue
+			}
+
+			err = c.exportInstance(projectClient, inst.Name, filepath.Join(target, "instances", project))
+			if err != nil {
+				return errors.Wrapf(err, "Export instance %s", inst.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *cmdStorageExport) exportVolume(client lxd.InstanceServer, pool string, name string, dir string) error {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	targetName := filepath.Join(dir, fmt.Sprintf("%s.tar.gz", name))
+	target, err := os.Create(targetName)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	progress := utils.ProgressRenderer{
+		Format: fmt.Sprintf(i18n.G("Exporting volume %s: %s"), name, "%s"),
+		Quiet:  c.global.flagQuiet,
+	}
+
+	req := lxd.BackupFileRequest{
+		BackupFile:      io.WriteSeeker(target),
+		ProgressHandler: progress.UpdateProgress,
+	}
+
+	_, err = client.GetStoragePoolVolumeExportFile(pool, name, &req)
+	if err != nil {
+		os.Remove(targetName)
+		progress.Done("")
+		return err
+	}
+
+	progress.Done("")
+	return nil
+}
+
+func (c *cmdStorageExport) exportInstance(client lxd.InstanceServer, name string, dir string) error {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	op, err := client.CreateInstanceBackup(name, api.InstanceBackupsPost{
+		ExpiresAt: time.Now().Add(24 * time.Hour),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = op.Wait()
+	if err != nil {
+		return err
+	}
+
+	backupName := strings.TrimPrefix(op.Get().Resources["backups"][0], "/1.0/backups/")
+	defer func() {
+		op, err := client.DeleteInstanceBackup(name, backupName)
+		if err == nil {
+			op.Wait()
+		}
+	}()
+
+	targetName := filepath.Join(dir, fmt.Sprintf("%s.tar.gz", name))
+	target, err := os.Create(targetName)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	progress := utils.ProgressRenderer{
+		Format: fmt.Sprintf(i18n.G("Exporting instance %s: %s"), name, "%s"),
+		Quiet:  c.global.flagQuiet,
+	}
+
+	req := lxd.BackupFileRequest{
+		BackupFile:      io.WriteSeeker(target),
+		ProgressHandler: progress.UpdateProgress,
+	}
+
+	_, err = client.GetInstanceBackupFile(name, backupName, &req)
+	if err != nil {
+		os.Remove(targetName)
+		progress.Done("")
+		return err
+	}
+
+	progress.Done("")
+	return nil
+}
+
+// Import
+type cmdStorageImport struct {
+	global  *cmdGlobal
+	storage *cmdStorage
+}
+
+func (c *cmdStorageImport) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = i18n.G("import [<remote>:]<pool> <directory> [key=value...]")
+	cmd.Short = i18n.G("Import storage pools")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Import a storage pool exported with "lxc storage export"
+
+The pool is created from the exported definition unless it already exists,
+dropping its "source" so that new storage gets allocated. Any key=value pair
+overrides the exported configuration. The custom volumes are then restored,
+followed by the instances.`))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc storage import default /mnt/backups/default source=/dev/sdb
+    Recreate the default pool on /dev/sdb from its export.`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdStorageImport) Run(cmd *cobra.Command, args []string) error {
+	// Sanity checks
+	exit, err := c.global.CheckArgs(cmd, args, 2, -1)
+	if exit {
+		return err
+	}
+
+	// Parse remote
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+	client := resource.server
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing pool name"))
+	}
+
+	source := shared.HostPath(args[1])
+
+	// Pool definition
+	data, err := ioutil.ReadFile(filepath.Join(source, "pool.yaml"))
+	if err != nil {
+		return err
+	}
+
+	pool := api.StoragePoolsPost{}
+	err = yaml.Unmarshal(data, &pool)
+	if err != nil {
+		return err
+	}
+
+	pool.Name = resource.name
+	if pool.Config == nil {
+		pool.Config = map[string]string{}
+	}
+
+	delete(pool.Config, "source")
+	for k := range pool.Config {
+		if strings.HasPrefix(k, "volatile.") {
+			delete(pool.Config, k)
+		}
+	}
+
+	for i := 2; i < len(args); i++ {
+		entry := strings.SplitN(args[i], "=", 2)
+		if len(entry) < 2 {
+			return fmt.Errorf(i18n.G("Bad key=value pair: %s"), entry)
+		}
+
+		pool.Config[entry[0]] = entry[1]
+	}
+
+	_, _, err = client.GetStoragePool(pool.Name)
+	if err != nil {
+		err = client.CreateStoragePool(pool)
+		if err != nil {
+			return err
+		}
+
+		if !c.global.flagQuiet {
+			fmt.Printf(i18n.G("Storage pool %s created")+"\n", pool.Name)
+		}
+	}
+
+	// Custom volumes
+	volumes, err := filepath.Glob(filepath.Join(source, "volumes", "*.tar.gz"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range volumes {
+		progress := utils.ProgressRenderer{
+			Format: fmt.Sprintf(i18n.G("Importing volume %s: %s"), strings.TrimSuffix(filepath.Base(path), ".tar.gz"), "%s"),
+			Quiet:  c.global.flagQuiet,
+		}
+
+		err = c.importFile(path, &progress, func(file io.Reader) (lxd.Operation, error) {
+			return client.CreateStoragePoolVolumeFromBackup(pool.Name, lxd.StoragePoolVolumeBackupArgs{BackupFile: file})
+		})
+		if err != nil {
+			return errors.Wrapf(err, "Import volume %s", path)
+		}
+	}
+
+	// Instances
+	instances, err := filepath.Glob(filepath.Join(source, "instances", "*", "*.tar.gz"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range instances {
+		project := filepath.Base(filepath.Dir(path))
+
+		progress := utils.ProgressRenderer{
+			Format: fmt.Sprintf(i18n.G("Importing instance %s: %s"), strings.TrimSuffix(filepath.Base(path), ".tar.gz"), "%s"),
+			Quiet:  c.global.flagQuiet,
+		}
+
+		err = c.importFile(path, &progress, func(file io.Reader) (lxd.Operation, error) {
+			return client.UseProject(project).CreateInstanceFromBackup(lxd.InstanceBackupArgs{BackupFile: file, PoolName: pool.Name})
+		})
+		if err != nil {
+			return errors.Wrapf(err, "Import instance %s", path)
+		}
+	}
+
+	return nil
+}
+
+func (c *cmdStorageImport) importFile(path string, progress *utils.ProgressRenderer, create func(io.Reader) (lxd.Operation, error)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	op, err := create(file)
+	if err != nil {
+		return err
+	}
+
+	err = utils.CancelableWait(op, progress)
+	if err != nil {
+		progress.Done("")
+		return err
+	}
+
+	progress.Done("")
+	return nil
+}
@@ -291,6 +291,7 @@ type cmdStorageVolumeCopy struct {
 
 	flagMode       string
 	flagVolumeOnly bool
+	flagRefresh    bool
 }
 
 func (c *cmdStorageVolumeCopy) Command() *cobra.Command {
@@ -304,6 +305,7 @@ func (c *cmdStorageVolumeCopy) Command() *cobra.Command {
 	cmd.Flags().StringVar(&c.flagMode, "mode", "pull", i18n.G("Transfer mode. One of pull (default), push or relay.")+"``")
 	cmd.Flags().StringVar(&c.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
 	cmd.Flags().BoolVar(&c.flagVolumeOnly, "volume-only", false, i18n.G("Copy the volume without its snapshots"))
+	cmd.Flags().BoolVar(&c.flagRefresh, "refresh", false, i18n.G("Perform an incremental copy"))
 	cmd.RunE = c.Run
 
 	return cmd
@@ -406,6 +408,7 @@ func (c *cmdStorageVolumeCopy) Run(cmd *cobra.Command, args []string) error {
 		args.Name = dstVolName
 		args.Mode = mode
 		args.VolumeOnly = c.flagVolumeOnly
+		args.Refresh = c.flagRefresh
 
 		if isSnapshot {
 			srcVol.Name = srcVolName
@@ -453,6 +456,8 @@ type cmdStorageVolumeCreate struct {
 	global        *cmdGlobal
 	storage       *cmdStorage
 	storageVolume *cmdStorageVolume
+
+	flagContentType string
 }
 
 func (c *cmdStorageVolumeCreate) Command() *cobra.Command {
@@ -463,6 +468,7 @@ func (c *cmdStorageVolumeCreate) Command() *cobra.Command {
 		`Create new custom storage volumes`))
 
 	cmd.Flags().StringVar(&c.storage.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.Flags().StringVar(&c.flagContentType, "type", "", i18n.G("Content type, block or filesystem")+"``")
 	cmd.RunE = c.Run
 
 	return cmd
@@ -496,6 +502,7 @@ func (c *cmdStorageVolumeCreate) Run(cmd *cobra.Command, args []string) error {
 	vol := api.StorageVolumesPost{}
 	vol.Name = volName
 	vol.Type = volType
+	vol.ContentType = c.flagContentType
 	vol.Config = map[string]string{}
 
 	for i := 2; i < len(args); i++ {
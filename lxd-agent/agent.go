@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var agentCmd = APIEndpoint{
+	Name: "agent",
+	Path: "agent",
+
+	Post: APIEndpointAction{Handler: agentPost},
+}
+
+// agentPost runs one of the commands forwarded by LXD through its instance agent API.
+func agentPost(d *Daemon, r *http.Request) response.Response {
+	req := api.InstanceAgentPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	switch req.Command {
+	case "ping":
+		return response.SyncResponse(true, api.InstanceAgentResult{Status: "ok"})
+	case "fsfreeze-status":
+		quiesceLock.Lock()
+		frozen := len(quiesceFrozen) > 0
+		quiesceLock.Unlock()
+
+		result := api.InstanceAgentResult{Status: "thawed"}
+		if frozen {
+			result.Status = "frozen"
+		}
+
+		return response.SyncResponse(true, result)
+	case "guest-exec":
+		return agentExec(r, req)
+	}
+
+	return response.BadRequest(fmt.Errorf("Unsupported agent command %q", req.Command))
+}
+
+// agentExec runs a command to completion and returns its output. The command is killed should
+// the request be cancelled.
+func agentExec(r *http.Request, req api.InstanceAgentPost) response.Response {
+	if len(req.Exec) == 0 {
+		return response.BadRequest(fmt.Errorf("A command line is required for guest-exec"))
+	}
+
+	cmd := exec.CommandContext(r.Context(), req.Exec[0], req.Exec[1:]...)
+	cmd.Dir = req.Cwd
+	cmd.Env = os.Environ()
+	for k, v := range req.Environment {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	exitErr, isExitErr := err.(*exec.ExitError)
+	if err != nil && !isExitErr {
+		return response.SmartError(err)
+	}
+
+	result := api.InstanceAgentResult{
+		Status: "exited",
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+	}
+
+	if isExitErr {
+		status, ok := exitErr.Sys().(syscall.WaitStatus)
+		if ok {
+			result.ExitCode = status.ExitStatus()
+		}
+	}
+
+	return response.SyncResponse(true, result)
+}
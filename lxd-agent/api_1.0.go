@@ -16,12 +16,15 @@ var api10Cmd = APIEndpoint{
 
 var api10 = []APIEndpoint{
 	api10Cmd,
+	agentCmd,
 	execCmd,
 	eventsCmd,
 	fileCmd,
 	operationsCmd,
 	operationCmd,
 	operationWebsocket,
+	quiesceCmd,
+	sftpCmd,
 	stateCmd,
 }
 
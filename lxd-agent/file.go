@@ -12,6 +12,7 @@ import (
 
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/filearchive"
 )
 
 var fileCmd = APIEndpoint{
@@ -29,6 +30,18 @@ func fileHandler(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(fmt.Errorf("missing path argument"))
 	}
 
+	// Whole trees are transferred as tar streams.
+	if shared.IsTrue(r.FormValue("archive")) {
+		switch r.Method {
+		case "GET":
+			return fileArchiveGet(path)
+		case "POST":
+			return fileArchivePost(path, r)
+		default:
+			return response.NotFound(fmt.Errorf("Method '%s' not found", r.Method))
+		}
+	}
+
 	switch r.Method {
 	case "GET":
 		return fileGet(path, r)
@@ -152,6 +165,25 @@ func filePost(path string, r *http.Request) response.Response {
 	return response.BadRequest(fmt.Errorf("Bad file type: %s", fType))
 }
 
+func fileArchiveGet(path string) response.Response {
+	headers := map[string]string{
+		"Content-Type": "application/x-tar",
+	}
+
+	return response.StreamResponse(headers, func(w io.Writer) error {
+		return filearchive.Write(w, path)
+	})
+}
+
+func fileArchivePost(path string, r *http.Request) response.Response {
+	err := filearchive.Extract(r.Body, path, true)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
 func fileDelete(path string, r *http.Request) response.Response {
 	err := os.Remove(path)
 	if err != nil {
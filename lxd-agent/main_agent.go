@@ -46,6 +46,12 @@ func (c *cmdAgent) Run(cmd *cobra.Command, args []string) error {
 	logger.Info("lxd-agent starting")
 	defer logger.Info("lxd-agent stopped")
 
+	// Apply the image templates.
+	err = templatesApply("files/")
+	if err != nil {
+		return errors.Wrap(err, "Failed to apply templates")
+	}
+
 	// Setup cloud-init.
 	if shared.PathExists("/etc/cloud") && !shared.PathExists("/var/lib/cloud/seed/nocloud-net") {
 		err := os.MkdirAll("/var/lib/cloud/seed/nocloud-net/", 0700)
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// quiesceHooksPath is where the guest can put the pre-freeze and post-thaw hooks.
+const quiesceHooksPath = "/etc/lxd-agent/hooks"
+
+// quiesceTimeout is how long filesystems remain frozen if LXD never asks for them to be thawed.
+const quiesceTimeout = 5 * time.Minute
+
+var quiesceCmd = APIEndpoint{
+	Name: "quiesce",
+	Path: "quiesce",
+
+	Post:   APIEndpointAction{Handler: quiescePost},
+	Delete: APIEndpointAction{Handler: quiesceDelete},
+}
+
+var quiesceLock sync.Mutex
+var quiesceFrozen []string
+var quiesceTimer *time.Timer
+
+// quiescePost runs the pre-freeze hook and freezes all writable filesystems.
+func quiescePost(d *Daemon, r *http.Request) response.Response {
+	quiesceLock.Lock()
+	defer quiesceLock.Unlock()
+
+	if len(quiesceFrozen) > 0 {
+		return response.BadRequest(fmt.Errorf("Filesystems are already frozen"))
+	}
+
+	err := quiesceRunHook("pre-freeze")
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	mounts, err := quiesceMounts()
+	if err != nil {
+		quiesceRunHook("post-thaw")
+		return response.SmartError(err)
+	}
+
+	// Freeze nested mounts before their parents.
+	for i := len(mounts) - 1; i >= 0; i-- {
+		_, err := shared.RunCommand("fsfreeze", "--freeze", mounts[i])
+		if err != nil {
+			quiesceThaw()
+			return response.SmartError(fmt.Errorf("Failed to freeze %q: %v", mounts[i], err))
+		}
+
+		quiesceFrozen = append(quiesceFrozen, mounts[i])
+	}
+
+	// Never leave the guest frozen should the host go away.
+	quiesceTimer = time.AfterFunc(quiesceTimeout, func() {
+		quiesceLock.Lock()
+		defer quiesceLock.Unlock()
+
+		logger.Warnf("Thawing filesystems after %v", quiesceTimeout)
+		quiesceThaw()
+	})
+
+	return response.EmptySyncResponse
+}
+
+// quiesceDelete thaws the frozen filesystems and runs the post-thaw hook.
+func quiesceDelete(d *Daemon, r *http.Request) response.Response {
+	quiesceLock.Lock()
+	defer quiesceLock.Unlock()
+
+	err := quiesceThaw()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+// quiesceThaw thaws the filesystems in the reverse order they were frozen in. Must be called with
+// quiesceLock held.
+func quiesceThaw() error {
+	if quiesceTimer != nil {
+		quiesceTimer.Stop()
+		quiesceTimer = nil
+	}
+
+	var thawErr error
+	for i := len(quiesceFrozen) - 1; i >= 0; i-- {
+		_, err := shared.RunCommand("fsfreeze", "--unfreeze", quiesceFrozen[i])
+		if err != nil && thawErr == nil {
+			thawErr = fmt.Errorf("Failed to thaw %q: %v", quiesceFrozen[i], err)
+		}
+	}
+
+	quiesceFrozen = nil
+
+	err := quiesceRunHook("post-thaw")
+	if err != nil && thawErr == nil {
+		thawErr = err
+	}
+
+	return thawErr
+}
+
+// quiesceRunHook runs the named hook if the guest provides one.
+func quiesceRunHook(name string) error {
+	path := fmt.Sprintf("%s/%s", quiesceHooksPath, name)
+	if !shared.PathExists(path) {
+		return nil
+	}
+
+	_, err := shared.RunCommand(path)
+	if err != nil {
+		return fmt.Errorf("Failed to run the %s hook: %v", name, err)
+	}
+
+	return nil
+}
+
+// quiesceMounts returns the mount points of the writable block-backed filesystems, in mount order.
+func quiesceMounts() ([]string, error) {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mounts := []string{}
+	devices := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "/dev/") {
+			continue
+		}
+
+		if shared.StringInSlice("ro", strings.Split(fields[3], ",")) {
+			continue
+		}
+
+		// Filesystems mounted in several places can only be frozen once.
+		if shared.StringInSlice(fields[0], devices) {
+			continue
+		}
+
+		devices = append(devices, fields[0])
+		mounts = append(mounts, fields[1])
+	}
+
+	return mounts, scanner.Err()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/pkg/sftp"
+
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+var sftpCmd = APIEndpoint{
+	Name: "sftp",
+	Path: "sftp",
+
+	Get: APIEndpointAction{Handler: sftpHandler},
+}
+
+func sftpHandler(d *Daemon, r *http.Request) response.Response {
+	return response.UpgradeResponse("sftp", func(conn net.Conn) {
+		defer conn.Close()
+
+		server, err := sftp.NewServer(conn)
+		if err != nil {
+			logger.Errorf("Failed to start SFTP server: %v", err)
+			return
+		}
+
+		err = server.Serve()
+		if err != nil && err != io.EOF {
+			logger.Errorf("SFTP server failed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// templatesApply writes the image templates rendered by LXD into the VM's filesystem.
+func templatesApply(path string) error {
+	// If there's no metadata, just return.
+	fname := filepath.Join(path, "metadata.yaml")
+	if !shared.PathExists(fname) {
+		return nil
+	}
+
+	// Parse the metadata.
+	content, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return errors.Wrap(err, "Failed to read metadata")
+	}
+
+	metadata := new(api.ImageMetadata)
+	err = yaml.Unmarshal(content, &metadata)
+	if err != nil {
+		return errors.Wrapf(err, "Could not parse %s", fname)
+	}
+
+	// Go through the templates.
+	for tplPath, tpl := range metadata.Templates {
+		fullpath := filepath.Join("/", strings.TrimLeft(tplPath, "/"))
+		if shared.PathExists(fullpath) && tpl.CreateOnly {
+			continue
+		}
+
+		// Create the directories leading to the file.
+		err = os.MkdirAll(filepath.Dir(fullpath), 0755)
+		if err != nil {
+			return err
+		}
+
+		// Copy the rendered content, keeping the mode of existing files.
+		mode := os.FileMode(0644)
+		fi, err := os.Stat(fullpath)
+		if err == nil {
+			mode = fi.Mode()
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(path, tpl.Template))
+		if err != nil {
+			return errors.Wrap(err, "Failed to read rendered template")
+		}
+
+		err = ioutil.WriteFile(fullpath, content, mode)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to write template %q", fullpath)
+		}
+	}
+
+	return nil
+}
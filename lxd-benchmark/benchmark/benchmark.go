@@ -35,18 +35,23 @@ func PrintServerInfo(c lxd.ContainerServer) error {
 	return nil
 }
 
-// LaunchContainers launches a set of containers.
-func LaunchContainers(c lxd.ContainerServer, count int, parallel int, image string, privileged bool, start bool, freeze bool) (time.Duration, error) {
+// LaunchContainers launches a set of containers, or virtual machines if vm is set, on the given
+// storage pool (the one of the default profile if empty).
+func LaunchContainers(c lxd.ContainerServer, count int, parallel int, image string, privileged bool, start bool, freeze bool, vm bool, pool string) (time.Duration, error) {
 	var duration time.Duration
 
+	if vm && privileged {
+		return duration, fmt.Errorf("Virtual machines can't be privileged")
+	}
+
 	batchSize, err := getBatchSize(parallel)
 	if err != nil {
 		return duration, err
 	}
 
-	printTestConfig(count, batchSize, image, privileged, freeze)
+	printTestConfig(count, batchSize, image, privileged, freeze, vm, pool)
 
-	fingerprint, err := ensureImage(c, image)
+	fingerprint, err := ensureImage(c, image, vm)
 	if err != nil {
 		return duration, err
 	}
@@ -56,7 +61,7 @@ func LaunchContainers(c lxd.ContainerServer, count int, parallel int, image stri
 
 		name := getContainerName(count, index)
 
-		err := createContainer(c, fingerprint, name, privileged)
+		err := createContainer(c, fingerprint, name, privileged, vm, pool)
 		if err != nil {
 			logf("Failed to launch container '%s': %s", name, err)
 			return
@@ -97,7 +102,7 @@ func CreateContainers(c lxd.ContainerServer, count int, parallel int, fingerprin
 
 		name := getContainerName(count, index)
 
-		err := createContainer(c, fingerprint, name, privileged)
+		err := createContainer(c, fingerprint, name, privileged, false, "")
 		if err != nil {
 			logf("Failed to launch container '%s': %s", name, err)
 			return
@@ -109,11 +114,11 @@ func CreateContainers(c lxd.ContainerServer, count int, parallel int, fingerprin
 	return duration, nil
 }
 
-// GetContainers returns containers created by the benchmark.
-func GetContainers(c lxd.ContainerServer) ([]api.Container, error) {
-	containers := []api.Container{}
+// GetContainers returns containers and virtual machines created by the benchmark.
+func GetContainers(c lxd.ContainerServer) ([]api.Instance, error) {
+	containers := []api.Instance{}
 
-	allContainers, err := c.GetContainers()
+	allContainers, err := c.GetInstances(api.InstanceTypeAny)
 	if err != nil {
 		return containers, err
 	}
@@ -128,7 +133,7 @@ func GetContainers(c lxd.ContainerServer) ([]api.Container, error) {
 }
 
 // StartContainers starts containers created by the benchmark.
-func StartContainers(c lxd.ContainerServer, containers []api.Container, parallel int) (time.Duration, error) {
+func StartContainers(c lxd.ContainerServer, containers []api.Instance, parallel int) (time.Duration, error) {
 	var duration time.Duration
 
 	batchSize, err := getBatchSize(parallel)
@@ -157,7 +162,7 @@ func StartContainers(c lxd.ContainerServer, containers []api.Container, parallel
 }
 
 // StopContainers stops containers created by the benchmark.
-func StopContainers(c lxd.ContainerServer, containers []api.Container, parallel int) (time.Duration, error) {
+func StopContainers(c lxd.ContainerServer, containers []api.Instance, parallel int) (time.Duration, error) {
 	var duration time.Duration
 
 	batchSize, err := getBatchSize(parallel)
@@ -186,7 +191,7 @@ func StopContainers(c lxd.ContainerServer, containers []api.Container, parallel
 }
 
 // DeleteContainers removes containers created by the benchmark.
-func DeleteContainers(c lxd.ContainerServer, containers []api.Container, parallel int) (time.Duration, error) {
+func DeleteContainers(c lxd.ContainerServer, containers []api.Instance, parallel int) (time.Duration, error) {
 	var duration time.Duration
 
 	batchSize, err := getBatchSize(parallel)
@@ -221,9 +226,14 @@ func DeleteContainers(c lxd.ContainerServer, containers []api.Container, paralle
 	return duration, nil
 }
 
-func ensureImage(c lxd.ContainerServer, image string) (string, error) {
+func ensureImage(c lxd.ContainerServer, image string, vm bool) (string, error) {
 	var fingerprint string
 
+	imageType := "container"
+	if vm {
+		imageType = "virtual-machine"
+	}
+
 	if strings.Contains(image, ":") {
 		defaultConfig := config.NewConfig("", true)
 		defaultConfig.UserAgent = version.UserAgent
@@ -243,7 +253,7 @@ func ensureImage(c lxd.ContainerServer, image string) (string, error) {
 			fingerprint = "default"
 		}
 
-		alias, _, err := imageServer.GetImageAlias(fingerprint)
+		alias, _, err := imageServer.GetImageAliasType(imageType, fingerprint)
 		if err == nil {
 			fingerprint = alias.Target
 		}
@@ -265,7 +275,7 @@ func ensureImage(c lxd.ContainerServer, image string) (string, error) {
 		}
 	} else {
 		fingerprint = image
-		alias, _, err := c.GetImageAlias(image)
+		alias, _, err := c.GetImageAliasType(imageType, image)
 		if err == nil {
 			fingerprint = alias.Target
 		} else {
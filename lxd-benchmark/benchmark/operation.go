@@ -5,23 +5,39 @@ import (
 	"github.com/lxc/lxd/shared/api"
 )
 
-func createContainer(c lxd.ContainerServer, fingerprint string, name string, privileged bool) error {
+func createContainer(c lxd.ContainerServer, fingerprint string, name string, privileged bool, vm bool, pool string) error {
 	config := map[string]string{}
 	if privileged {
 		config["security.privileged"] = "true"
 	}
 	config[userConfigKey] = "true"
 
-	req := api.ContainersPost{
+	req := api.InstancesPost{
 		Name: name,
-		Source: api.ContainerSource{
+		Source: api.InstanceSource{
 			Type:        "image",
 			Fingerprint: fingerprint,
 		},
+		Type: api.InstanceTypeContainer,
 	}
 	req.Config = config
 
-	op, err := c.CreateContainer(req)
+	if vm {
+		req.Type = api.InstanceTypeVM
+	}
+
+	// Override the root disk of the profile to use the requested pool
+	if pool != "" {
+		req.Devices = map[string]map[string]string{
+			"root": {
+				"type": "disk",
+				"path": "/",
+				"pool": pool,
+			},
+		}
+	}
+
+	op, err := c.CreateInstance(req)
 	if err != nil {
 		return err
 	}
@@ -30,8 +46,8 @@ func createContainer(c lxd.ContainerServer, fingerprint string, name string, pri
 }
 
 func startContainer(c lxd.ContainerServer, name string) error {
-	op, err := c.UpdateContainerState(
-		name, api.ContainerStatePut{Action: "start", Timeout: -1}, "")
+	op, err := c.UpdateInstanceState(
+		name, api.InstanceStatePut{Action: "start", Timeout: -1}, "")
 	if err != nil {
 		return err
 	}
@@ -40,8 +56,8 @@ func startContainer(c lxd.ContainerServer, name string) error {
 }
 
 func stopContainer(c lxd.ContainerServer, name string) error {
-	op, err := c.UpdateContainerState(
-		name, api.ContainerStatePut{Action: "stop", Timeout: -1, Force: true}, "")
+	op, err := c.UpdateInstanceState(
+		name, api.InstanceStatePut{Action: "stop", Timeout: -1, Force: true}, "")
 	if err != nil {
 		return err
 	}
@@ -50,8 +66,8 @@ func stopContainer(c lxd.ContainerServer, name string) error {
 }
 
 func freezeContainer(c lxd.ContainerServer, name string) error {
-	op, err := c.UpdateContainerState(
-		name, api.ContainerStatePut{Action: "freeze", Timeout: -1}, "")
+	op, err := c.UpdateInstanceState(
+		name, api.InstanceStatePut{Action: "freeze", Timeout: -1}, "")
 	if err != nil {
 		return err
 	}
@@ -60,7 +76,7 @@ func freezeContainer(c lxd.ContainerServer, name string) error {
 }
 
 func deleteContainer(c lxd.ContainerServer, name string) error {
-	op, err := c.DeleteContainer(name)
+	op, err := c.DeleteInstance(name)
 	if err != nil {
 		return err
 	}
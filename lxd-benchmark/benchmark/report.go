@@ -2,12 +2,21 @@ package benchmark
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"time"
 )
 
+// Report is a report file the benchmark results are added to.
+type Report interface {
+	Load() error
+	Write() error
+	AddRecord(label string, elapsed time.Duration) error
+}
+
 // Subset of JMeter CSV log format that are required by Jenkins performance
 // plugin
 // (see http://jmeter.apache.org/usermanual/listeners.html#csvlogformat)
@@ -93,3 +102,60 @@ func (r *CSVReport) addRecord(record []string) error {
 	r.records = append(r.records, record)
 	return nil
 }
+
+// JSONReport reads/writes a JSON report file.
+type JSONReport struct {
+	Filename string
+
+	records []JSONRecord
+}
+
+// JSONRecord is a record of a JSON report.
+type JSONRecord struct {
+	Timestamp int64  `json:"timestamp"` // in milliseconds since 1/1/1970
+	Elapsed   int64  `json:"elapsed"`   // in milliseconds
+	Label     string `json:"label"`
+}
+
+// Load reads current content of the filename and loads records.
+func (r *JSONReport) Load() error {
+	content, err := ioutil.ReadFile(r.Filename)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(content, &r.records)
+	if err != nil {
+		return err
+	}
+
+	logf("Loaded report file %s", r.Filename)
+	return nil
+}
+
+// Write writes current records to file.
+func (r *JSONReport) Write() error {
+	content, err := json.MarshalIndent(r.records, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(r.Filename, append(content, '\n'), 0640)
+	if err != nil {
+		return err
+	}
+
+	logf("Written report file %s", r.Filename)
+	return nil
+}
+
+// AddRecord adds a record to the report.
+func (r *JSONReport) AddRecord(label string, elapsed time.Duration) error {
+	r.records = append(r.records, JSONRecord{
+		Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+		Elapsed:   int64(elapsed / time.Millisecond),
+		Label:     label,
+	})
+
+	return nil
+}
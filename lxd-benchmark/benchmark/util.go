@@ -14,11 +14,14 @@ func logf(format string, args ...interface{}) {
 	fmt.Printf(fmt.Sprintf("[%s] %s\n", time.Now().Format(time.StampMilli), format), args...)
 }
 
-func printTestConfig(count int, batchSize int, image string, privileged bool, freeze bool) {
+func printTestConfig(count int, batchSize int, image string, privileged bool, freeze bool, vm bool, pool string) {
 	privilegedStr := "unprivileged"
 	if privileged {
 		privilegedStr = "privileged"
 	}
+	if vm {
+		privilegedStr = "virtual machine"
+	}
 	mode := "normal startup"
 	if freeze {
 		mode = "start and freeze"
@@ -31,6 +34,9 @@ func printTestConfig(count int, batchSize int, image string, privileged bool, fr
 	fmt.Printf("  Container mode: %s\n", privilegedStr)
 	fmt.Printf("  Startup mode: %s\n", mode)
 	fmt.Printf("  Image: %s\n", image)
+	if pool != "" {
+		fmt.Printf("  Storage pool: %s\n", pool)
+	}
 	fmt.Printf("  Batches: %d\n", batches)
 	fmt.Printf("  Batch size: %d\n", batchSize)
 	fmt.Printf("  Remainder: %d\n", remainder)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"time"
 
@@ -13,15 +14,23 @@ import (
 )
 
 type cmdGlobal struct {
-	flagHelp        bool
-	flagParallel    int
-	flagReportFile  string
-	flagReportLabel string
-	flagVersion     bool
+	flagHelp         bool
+	flagParallel     int
+	flagReportFile   string
+	flagReportFormat string
+	flagReportLabel  string
+	flagVersion      bool
 
 	srv            lxd.ContainerServer
-	report         *benchmark.CSVReport
+	report         benchmark.Report
 	reportDuration time.Duration
+	reportRecords  []reportRecord
+}
+
+// reportRecord is the result of a run on one of the storage pools of the matrix mode.
+type reportRecord struct {
+	pool     string
+	duration time.Duration
 }
 
 func (c *cmdGlobal) Run(cmd *cobra.Command, args []string) error {
@@ -37,7 +46,15 @@ func (c *cmdGlobal) Run(cmd *cobra.Command, args []string) error {
 
 	// Setup report handling
 	if c.flagReportFile != "" {
-		c.report = &benchmark.CSVReport{Filename: c.flagReportFile}
+		switch c.flagReportFormat {
+		case "csv":
+			c.report = &benchmark.CSVReport{Filename: c.flagReportFile}
+		case "json":
+			c.report = &benchmark.JSONReport{Filename: c.flagReportFile}
+		default:
+			return fmt.Errorf("Invalid report format %q", c.flagReportFormat)
+		}
+
 		if shared.PathExists(c.flagReportFile) {
 			err := c.report.Load()
 			if err != nil {
@@ -60,7 +77,13 @@ func (c *cmdGlobal) Teardown(cmd *cobra.Command, args []string) error {
 		label = c.flagReportLabel
 	}
 
-	c.report.AddRecord(label, c.reportDuration)
+	if len(c.reportRecords) == 0 {
+		c.report.AddRecord(label, c.reportDuration)
+	}
+
+	for _, record := range c.reportRecords {
+		c.report.AddRecord(fmt.Sprintf("%s/%s", label, record.pool), record.duration)
+	}
 
 	err := c.report.Write()
 	if err != nil {
@@ -83,7 +106,12 @@ func main() {
   compare performance on different servers or for performance tracking
   when doing changes to the LXD codebase.
 
-  A CSV report can be produced to be consumed by graphing software.
+  A CSV or JSON report can be produced to be consumed by graphing software.
+
+  Instances can be created on several storage pools in one go to compare
+  their performance. The batch is run on each of the pools in turn, its
+  instances being deleted before moving on to the next pool, and a record
+  is added to the report for each pool.
 `
 	app.Example = `  # Spawn 20 Ubuntu containers in batches of 4
   lxd-benchmark launch --count 20 --parallel 4
@@ -91,6 +119,9 @@ func main() {
   # Create 50 Alpine containers in batches of 10
   lxd-benchmark init --count 50 --parallel 10 images:alpine/edge
 
+  # Compare launching 10 Ubuntu virtual machines on two storage pools
+  lxd-benchmark launch --vm --count 10 --storage-pools zfs,lvm --report-file report.json --report-format json
+
   # Delete all test containers using dynamic batch size
   lxd-benchmark delete`
 	app.SilenceUsage = true
@@ -102,7 +133,8 @@ func main() {
 	app.PersistentFlags().BoolVar(&globalCmd.flagVersion, "version", false, "Print version number")
 	app.PersistentFlags().BoolVarP(&globalCmd.flagHelp, "help", "h", false, "Print help")
 	app.PersistentFlags().IntVarP(&globalCmd.flagParallel, "parallel", "P", -1, "Number of threads to use"+"``")
-	app.PersistentFlags().StringVar(&globalCmd.flagReportFile, "report-file", "", "Path to the CSV report file"+"``")
+	app.PersistentFlags().StringVar(&globalCmd.flagReportFile, "report-file", "", "Path to the report file"+"``")
+	app.PersistentFlags().StringVar(&globalCmd.flagReportFormat, "report-format", "csv", "Format of the report file (csv or json)"+"``")
 	app.PersistentFlags().StringVar(&globalCmd.flagReportLabel, "report-label", "", "Label for the new entry in the report [default=ACTION]"+"``")
 
 	// Version handling
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/spf13/cobra"
 
 	"github.com/lxc/lxd/lxd-benchmark/benchmark"
@@ -9,8 +11,10 @@ import (
 type cmdInit struct {
 	global *cmdGlobal
 
-	flagCount      int
-	flagPrivileged bool
+	flagCount        int
+	flagPrivileged   bool
+	flagVM           bool
+	flagStoragePools string
 }
 
 func (c *cmdInit) Command() *cobra.Command {
@@ -20,6 +24,8 @@ func (c *cmdInit) Command() *cobra.Command {
 	cmd.RunE = c.Run
 	cmd.Flags().IntVarP(&c.flagCount, "count", "C", 1, "Number of containers to create"+"``")
 	cmd.Flags().BoolVar(&c.flagPrivileged, "privileged", false, "Use privileged containers")
+	cmd.Flags().BoolVar(&c.flagVM, "vm", false, "Create virtual machines instead of containers")
+	cmd.Flags().StringVar(&c.flagStoragePools, "storage-pools", "", "Comma separated list of storage pools to run the batch on in turn"+"``")
 
 	return cmd
 }
@@ -31,13 +37,44 @@ func (c *cmdInit) Run(cmd *cobra.Command, args []string) error {
 		image = args[0]
 	}
 
-	// Run the test
-	duration, err := benchmark.LaunchContainers(c.global.srv, c.flagCount, c.global.flagParallel, image, c.flagPrivileged, false, false)
-	if err != nil {
-		return err
+	return c.launch(image, false, false)
+}
+
+// launch runs the test, once per storage pool when several are requested.
+func (c *cmdInit) launch(image string, start bool, freeze bool) error {
+	if c.flagStoragePools == "" {
+		duration, err := benchmark.LaunchContainers(c.global.srv, c.flagCount, c.global.flagParallel, image, c.flagPrivileged, start, freeze, c.flagVM, "")
+		if err != nil {
+			return err
+		}
+
+		c.global.reportDuration = duration
+
+		return nil
 	}
 
-	c.global.reportDuration = duration
+	pools := strings.Split(c.flagStoragePools, ",")
+	for i, pool := range pools {
+		duration, err := benchmark.LaunchContainers(c.global.srv, c.flagCount, c.global.flagParallel, image, c.flagPrivileged, start, freeze, c.flagVM, pool)
+		if err != nil {
+			return err
+		}
+
+		c.global.reportRecords = append(c.global.reportRecords, reportRecord{pool: pool, duration: duration})
+
+		// Make room for the instances of the next pool
+		if i < len(pools)-1 {
+			containers, err := benchmark.GetContainers(c.global.srv)
+			if err != nil {
+				return err
+			}
+
+			_, err = benchmark.DeleteContainers(c.global.srv, containers, c.global.flagParallel)
+			if err != nil {
+				return err
+			}
+		}
+	}
 
 	return nil
 }
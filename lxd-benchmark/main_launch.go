@@ -2,8 +2,6 @@ package main
 
 import (
 	"github.com/spf13/cobra"
-
-	"github.com/lxc/lxd/lxd-benchmark/benchmark"
 )
 
 type cmdLaunch struct {
@@ -31,13 +29,5 @@ func (c *cmdLaunch) Run(cmd *cobra.Command, args []string) error {
 		image = args[0]
 	}
 
-	// Run the test
-	duration, err := benchmark.LaunchContainers(c.global.srv, c.init.flagCount, c.global.flagParallel, image, c.init.flagPrivileged, true, c.flagFreeze)
-	if err != nil {
-		return err
-	}
-
-	c.global.reportDuration = duration
-
-	return nil
+	return c.init.launch(image, true, c.flagFreeze)
 }
@@ -12,6 +12,7 @@ import (
 	"golang.org/x/sys/unix"
 
 	"github.com/lxc/lxd/lxc/utils"
+	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/osarch"
 )
@@ -27,11 +28,12 @@ type cmdMigrate struct {
 	flagType        string
 	flagRsyncArgs   string
 	flagNoProfiles  bool
+	flagVM          bool
 }
 
 func (c *cmdMigrate) Command() *cobra.Command {
 	cmd := &cobra.Command{}
-	cmd.Use = "lxd-p2c <target URL> <container name> <filesystem root> [<filesystem mounts>...]"
+	cmd.Use = "lxd-p2c <target URL> <instance name> <filesystem root or disk> [<filesystem mounts>...]"
 	cmd.Short = "Physical to container migration tool"
 	cmd.Long = `Description:
   Physical to container migration tool
@@ -43,6 +45,11 @@ func (c *cmdMigrate) Command() *cobra.Command {
   additional mount you list, then transfer this through LXD's migration
   API to create a new container from it.
 
+  With --vm, the source is instead a block device or raw disk image which
+  is streamed to the LXD host as the root disk of a new virtual machine.
+  Blocks containing only zeros are skipped and the transfer is verified
+  using a SHA256 checksum. The disk must be bootable through UEFI.
+
   The same set of options as ` + "`lxc launch`" + ` are also supported.
 `
 	cmd.RunE = c.Run
@@ -54,6 +61,7 @@ func (c *cmdMigrate) Command() *cobra.Command {
 	cmd.Flags().StringVarP(&c.flagType, "type", "t", "", "Instance type to use for the container"+"``")
 	cmd.Flags().StringVar(&c.flagRsyncArgs, "rsync-args", "", "Extra arguments to pass to rsync"+"``")
 	cmd.Flags().BoolVar(&c.flagNoProfiles, "no-profiles", false, "Create the container with no profiles applied")
+	cmd.Flags().BoolVar(&c.flagVM, "vm", false, "Import a block device or disk image as a virtual machine")
 
 	return cmd
 }
@@ -69,9 +77,11 @@ func (c *cmdMigrate) Run(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("This tool must be run as root")
 	}
 
-	_, err := exec.LookPath("rsync")
-	if err != nil {
-		return err
+	if !c.flagVM {
+		_, err := exec.LookPath("rsync")
+		if err != nil {
+			return err
+		}
 	}
 
 	if c.flagNoProfiles && len(c.flagProfile) != 0 {
@@ -88,33 +98,45 @@ func (c *cmdMigrate) Run(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Missing required arguments")
 	}
 
-	// Get and sort the mounts
-	mounts := args[2:]
-	sort.Strings(mounts)
-
-	// Create the temporary directory to be used for the mounts
-	path, err := ioutil.TempDir("", "lxd-p2c_mount_")
-	if err != nil {
-		return err
-	}
+	var fullPath string
+	if c.flagVM {
+		if len(args) > 3 {
+			return fmt.Errorf("Filesystem mounts can't be specified alongside --vm")
+		}
 
-	// Automatically clean-up the temporary path on exit
-	defer func(path string) {
-		unix.Unmount(path, unix.MNT_DETACH)
-		os.Remove(path)
-	}(path)
+		fullPath = args[2]
+		if !shared.PathExists(fullPath) {
+			return fmt.Errorf("Disk %q doesn't exist", fullPath)
+		}
+	} else {
+		// Get and sort the mounts
+		mounts := args[2:]
+		sort.Strings(mounts)
+
+		// Create the temporary directory to be used for the mounts
+		path, err := ioutil.TempDir("", "lxd-p2c_mount_")
+		if err != nil {
+			return err
+		}
 
-	// Create the rootfs directory
-	fullPath := fmt.Sprintf("%s/rootfs", path)
-	err = os.Mkdir(fullPath, 0755)
-	if err != nil {
-		return err
-	}
+		// Automatically clean-up the temporary path on exit
+		defer func(path string) {
+			unix.Unmount(path, unix.MNT_DETACH)
+			os.Remove(path)
+		}(path)
+
+		// Create the rootfs directory
+		fullPath = fmt.Sprintf("%s/rootfs", path)
+		err = os.Mkdir(fullPath, 0755)
+		if err != nil {
+			return err
+		}
 
-	// Setup the source (mounts)
-	err = setupSource(fullPath, mounts)
-	if err != nil {
-		return fmt.Errorf("Failed to setup the source: %v", err)
+		// Setup the source (mounts)
+		err = setupSource(fullPath, mounts)
+		if err != nil {
+			return fmt.Errorf("Failed to setup the source: %v", err)
+		}
 	}
 
 	URL, err := parseURL(args[0])
@@ -128,14 +150,19 @@ func (c *cmdMigrate) Run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// Container creation request
-	apiArgs := api.ContainersPost{}
+	// Instance creation request
+	apiArgs := api.InstancesPost{}
 	apiArgs.Name = args[1]
-	apiArgs.Source = api.ContainerSource{
+	apiArgs.Type = api.InstanceTypeContainer
+	apiArgs.Source = api.InstanceSource{
 		Type: "migration",
 		Mode: "push",
 	}
 
+	if c.flagVM {
+		apiArgs.Type = api.InstanceTypeVM
+	}
+
 	// System architecture
 	architectureName, err := osarch.ArchitectureGetLocal()
 	if err != nil {
@@ -193,38 +220,42 @@ func (c *cmdMigrate) Run(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Check if the container already exists
-	_, _, err = dst.GetContainer(apiArgs.Name)
+	// Check if the instance already exists
+	_, _, err = dst.GetInstance(apiArgs.Name)
 	if err == nil {
-		return fmt.Errorf("Container '%s' already exists", apiArgs.Name)
+		return fmt.Errorf("Instance '%s' already exists", apiArgs.Name)
 	}
 
-	// Create the container
+	// Create the instance
 	success := false
-	op, err := dst.CreateContainer(apiArgs)
+	op, err := dst.CreateInstance(apiArgs)
 	if err != nil {
 		return err
 	}
 
 	defer func() {
 		if !success {
-			dst.DeleteContainer(apiArgs.Name)
+			dst.DeleteInstance(apiArgs.Name)
 		}
 	}()
 
-	progress := utils.ProgressRenderer{Format: "Transferring container: %s"}
+	progress := utils.ProgressRenderer{Format: "Transferring instance: %s"}
 	_, err = op.AddHandler(progress.UpdateOp)
 	if err != nil {
 		progress.Done("")
 		return err
 	}
 
-	err = transferRootfs(dst, op, fullPath, c.flagRsyncArgs)
+	if c.flagVM {
+		err = transferDisk(op, fullPath)
+	} else {
+		err = transferRootfs(dst, op, fullPath, c.flagRsyncArgs)
+	}
 	if err != nil {
 		return err
 	}
 
-	progress.Done(fmt.Sprintf("Container %s successfully created", apiArgs.Name))
+	progress.Done(fmt.Sprintf("Instance %s successfully created", apiArgs.Name))
 	success = true
 
 	return nil
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
+	"io"
 	"net/url"
+	"os"
 	"strings"
 
 	"golang.org/x/crypto/ssh/terminal"
@@ -80,6 +84,68 @@ func transferRootfs(dst lxd.ContainerServer, op lxd.Operation, rootfs string, rs
 	return nil
 }
 
+func transferDisk(op lxd.Operation, diskPath string) error {
+	opAPI := op.Get()
+
+	// Connect to the websockets
+	wsControl, err := op.GetWebsocket(opAPI.Metadata["control"].(string))
+	if err != nil {
+		return err
+	}
+
+	wsFs, err := op.GetWebsocket(opAPI.Metadata["fs"].(string))
+	if err != nil {
+		return err
+	}
+
+	abort := func(err error) error {
+		protoSendError(wsControl, err)
+		wsFs.Close()
+		return err
+	}
+
+	disk, err := os.Open(diskPath)
+	if err != nil {
+		return abort(err)
+	}
+	defer disk.Close()
+
+	// Send the disk, computing its checksum on the way
+	hash := sha256.New()
+	conn := &shared.WebsocketIO{Conn: wsFs}
+	_, err = io.Copy(conn, io.TeeReader(disk, hash))
+	if err != nil {
+		return abort(err)
+	}
+
+	err = conn.Close()
+	if err != nil {
+		return abort(err)
+	}
+
+	// Let the target verify what it received
+	success := true
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	err = migration.ProtoSend(wsControl, &migration.MigrationControl{Success: &success, Message: &checksum})
+	if err != nil {
+		return err
+	}
+
+	// Check the result
+	msg := migration.MigrationControl{}
+	err = migration.ProtoRecv(wsControl, &msg)
+	if err != nil {
+		wsControl.Close()
+		return err
+	}
+
+	if !*msg.Success {
+		return fmt.Errorf(*msg.Message)
+	}
+
+	return nil
+}
+
 func connectTarget(url string) (lxd.ContainerServer, error) {
 	// Generate a new client certificate for this
 	fmt.Println("Generating a temporary client certificate. This may take a minute...")
@@ -48,6 +48,10 @@ func RestServer(d *Daemon) *http.Server {
 		d.createCmd(mux, "internal", c)
 	}
 
+	for _, c := range apiStreams {
+		d.createCmd(mux, "streams", c)
+	}
+
 	mux.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		logger.Info("Sending top level 404", log.Ctx{"url": r.URL})
 		w.Header().Set("Content-Type", "application/json")
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"strings"
@@ -32,16 +33,20 @@ var api10Cmd = APIEndpoint{
 var api10 = []APIEndpoint{
 	api10Cmd,
 	api10ResourcesCmd,
+	auditCmd,
 	certificateCmd,
 	certificatesCmd,
 	clusterCmd,
 	clusterNodeCmd,
 	clusterNodesCmd,
+	clusterUpgradeCmd,
+	instanceAgentCmd,
 	instanceBackupCmd,
 	instanceBackupExportCmd,
 	instanceBackupsCmd,
 	instanceCmd,
 	instanceConsoleCmd,
+	instanceDevicesValidateCmd,
 	instanceExecCmd,
 	instanceFileCmd,
 	instanceLogCmd,
@@ -49,12 +54,18 @@ var api10 = []APIEndpoint{
 	instanceMetadataCmd,
 	instanceMetadataTemplatesCmd,
 	instancesCmd,
+	instanceSFTPCmd,
 	instanceSnapshotCmd,
 	instanceSnapshotsCmd,
 	instanceStateCmd,
+	instanceUEFIVarsCmd,
+	metadataConfigurationCmd,
+	metricsCmd,
 	eventsCmd,
 	imageAliasCmd,
 	imageAliasesCmd,
+	imageUploadsCmd,
+	imageUploadCmd,
 	imageCmd,
 	imageExportCmd,
 	imageRefreshCmd,
@@ -78,11 +89,15 @@ var api10 = []APIEndpoint{
 	storagePoolVolumesCmd,
 	storagePoolVolumeSnapshotsTypeCmd,
 	storagePoolVolumeSnapshotTypeCmd,
+	storagePoolVolumeTypeStateCmd,
 	storagePoolVolumesTypeCmd,
 	storagePoolVolumeTypeContainerCmd,
 	storagePoolVolumeTypeCustomCmd,
+	storagePoolVolumeTypeCustomExportCmd,
 	storagePoolVolumeTypeImageCmd,
 	storagePoolVolumeTypeVMCmd,
+	warningCmd,
+	warningsCmd,
 }
 
 func api10Get(d *Daemon, r *http.Request) response.Response {
@@ -131,13 +146,19 @@ func api10Get(d *Daemon, r *http.Request) response.Response {
 		return response.InternalError(err)
 	}
 
-	address, err := node.HTTPSAddress(d.db)
+	httpsAddresses, err := node.HTTPSAddresses(d.db)
 	if err != nil {
 		return response.InternalError(err)
 	}
-	addresses, err := util.ListenAddresses(address)
-	if err != nil {
-		return response.InternalError(err)
+
+	addresses := []string{}
+	for _, address := range httpsAddresses {
+		listenAddresses, err := util.ListenAddresses(address)
+		if err != nil {
+			return response.InternalError(err)
+		}
+
+		addresses = append(addresses, listenAddresses...)
 	}
 
 	clustered, err := cluster.Enabled(d.db)
@@ -239,9 +260,16 @@ func api10Get(d *Daemon, r *http.Request) response.Response {
 
 	fullSrv := api.Server{ServerUntrusted: srv}
 	fullSrv.Environment = env
-	fullSrv.Config, err = daemonConfigRender(d.State())
-	if err != nil {
-		return response.InternalError(err)
+
+	// Restricted clients don't get to see the server configuration.
+	restricted, _ := d.userRestricted(r)
+	if restricted {
+		fullSrv.Config = map[string]interface{}{}
+	} else {
+		fullSrv.Config, err = daemonConfigRender(d.State())
+		if err != nil {
+			return response.InternalError(err)
+		}
 	}
 
 	return response.SyncResponseETag(true, fullSrv, fullSrv.Config)
@@ -472,6 +500,8 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 	maasChanged := false
 	candidChanged := false
 	rbacChanged := false
+	bgpChanged := false
+	secretsChanged := false
 
 	for key := range clusterChanged {
 		switch key {
@@ -481,6 +511,13 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 			fallthrough
 		case "core.proxy_ignore_hosts":
 			daemonConfigSetProxy(d, clusterConfig)
+		case "core.https_trusted_proxy":
+			err := d.endpoints.NetworkUpdateTrustedProxy(clusterConfig.HTTPSTrustedProxy())
+			if err != nil {
+				return err
+			}
+		case "core.bgp_asn":
+			bgpChanged = true
 		case "maas.api.url":
 			fallthrough
 		case "maas.api.key":
@@ -515,6 +552,16 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 			fallthrough
 		case "rbac.expiry":
 			rbacChanged = true
+		case "secrets.backend":
+			fallthrough
+		case "secrets.vault.address":
+			fallthrough
+		case "secrets.vault.token":
+			fallthrough
+		case "secrets.vault.mount":
+			fallthrough
+		case "secrets.vault.path":
+			secretsChanged = true
 		}
 	}
 
@@ -528,6 +575,16 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 		maasChanged = true
 	}
 
+	_, ok = nodeChanged["core.bgp_address"]
+	if ok {
+		bgpChanged = true
+	}
+
+	_, ok = nodeChanged["core.bgp_routerid"]
+	if ok {
+		bgpChanged = true
+	}
+
 	value, ok := nodeChanged["core.https_address"]
 	if ok {
 		err := d.endpoints.NetworkUpdateAddress(value)
@@ -568,6 +625,17 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 		}
 	}
 
+	if bgpChanged {
+		address := nodeConfig.BGPAddress()
+		asn := clusterConfig.BGPASN()
+		routerID := net.ParseIP(nodeConfig.BGPRouterID())
+
+		err := d.bgp.Start(address, uint32(asn), routerID)
+		if err != nil {
+			return err
+		}
+	}
+
 	if maasChanged {
 		url, key := clusterConfig.MAASController()
 		machine := nodeConfig.MAASMachine()
@@ -577,6 +645,14 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 		}
 	}
 
+	if secretsChanged {
+		backendType, address, token, mount, path := clusterConfig.SecretsBackend()
+		err := d.setupSecretsBackend(backendType, address, token, mount, path)
+		if err != nil {
+			return err
+		}
+	}
+
 	if candidChanged {
 		apiURL, apiKey, expiry, domains := clusterConfig.CandidServer()
 		err := d.setupExternalAuthentication(apiURL, apiKey, expiry, domains)
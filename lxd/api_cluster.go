@@ -47,7 +47,16 @@ var clusterNodeCmd = APIEndpoint{
 
 	Delete: APIEndpointAction{Handler: clusterNodeDelete},
 	Get:    APIEndpointAction{Handler: clusterNodeGet, AccessHandler: AllowAuthenticated},
+	Patch:  APIEndpointAction{Handler: clusterNodePut},
 	Post:   APIEndpointAction{Handler: clusterNodePost},
+	Put:    APIEndpointAction{Handler: clusterNodePut},
+}
+
+var clusterUpgradeCmd = APIEndpoint{
+	Path: "cluster/upgrade",
+
+	Get:  APIEndpointAction{Handler: clusterUpgradeGet, AccessHandler: AllowAuthenticated},
+	Post: APIEndpointAction{Handler: clusterUpgradePost},
 }
 
 var internalClusterAcceptCmd = APIEndpoint{
@@ -409,13 +418,13 @@ func clusterPutJoin(d *Daemon, req api.ClusterPut) response.Response {
 		}
 
 		networks := []api.Network{}
-		networkNames, err := d.cluster.Networks()
+		networkNames, err := d.cluster.Networks("default")
 		if err != nil && err != db.ErrNoSuchObject {
 			return err
 		}
 
 		for _, name := range networkNames {
-			_, network, err := d.cluster.NetworkGet(name)
+			_, network, err := d.cluster.NetworkGet("default", name)
 			if err != nil {
 				return err
 			}
@@ -491,7 +500,7 @@ func clusterPutJoin(d *Daemon, req api.ClusterPut) response.Response {
 				return err
 			}
 
-			if !shared.StringInSlice(pool.Driver, []string{"ceph", "cephfs"}) {
+			if !shared.StringInSlice(pool.Driver, []string{"ceph", "cephfs", "iscsi"}) {
 				continue
 			}
 
@@ -710,7 +719,7 @@ func clusterInitMember(d, client lxd.InstanceServer, memberConfig []api.ClusterM
 
 		// Skip ceph pools since they have no node-specific key and
 		// don't need to be defined on joining nodes.
-		if shared.StringInSlice(pool.Driver, []string{"ceph", "cephfs"}) {
+		if shared.StringInSlice(pool.Driver, []string{"ceph", "cephfs", "iscsi"}) {
 			continue
 		}
 
@@ -876,6 +885,99 @@ func clusterNodeGet(d *Daemon, r *http.Request) response.Response {
 	return response.NotFound(fmt.Errorf("Member '%s' not found", name))
 }
 
+func clusterNodePut(d *Daemon, r *http.Request) response.Response {
+	name := mux.Vars(r)["name"]
+
+	nodes, err := cluster.List(d.State())
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	var member *api.ClusterMember
+	for i := range nodes {
+		if nodes[i].ServerName == name {
+			member = &nodes[i]
+			break
+		}
+	}
+
+	if member == nil {
+		return response.NotFound(fmt.Errorf("Member '%s' not found", name))
+	}
+
+	// Validate the ETag
+	err = util.EtagCheck(r, *member)
+	if err != nil {
+		return response.PreconditionFailed(err)
+	}
+
+	req := api.ClusterMemberPut{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = clusterValidateRoles(member.Roles, req.Roles)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	// The new roles are distributed to the members through the heartbeats.
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		node, err := tx.NodeByName(name)
+		if err != nil {
+			return err
+		}
+
+		for _, role := range db.ClusterRolesAssignable {
+			current := shared.StringInSlice(string(role), node.Roles)
+			wanted := shared.StringInSlice(string(role), req.Roles)
+
+			if wanted && !current {
+				err = tx.NodeAddRole(node.ID, role)
+			} else if current && !wanted {
+				err = tx.NodeRemoveRole(node.ID, role)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+// clusterValidateRoles checks that the requested roles are known and that
+// only roles which can be assigned through the API are being changed.
+func clusterValidateRoles(current []string, requested []string) error {
+	for _, role := range requested {
+		found := false
+		for _, known := range db.ClusterRoles {
+			if string(known) == role {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("Invalid cluster role %q", role)
+		}
+	}
+
+	database := string(db.ClusterRoleDatabase)
+	if shared.StringInSlice(database, current) != shared.StringInSlice(database, requested) {
+		return fmt.Errorf("The %q role is managed automatically and can't be changed", database)
+	}
+
+	return nil
+}
+
 func clusterNodePost(d *Daemon, r *http.Request) response.Response {
 	name := mux.Vars(r)["name"]
 
@@ -922,7 +1024,7 @@ func clusterNodeDelete(d *Daemon, r *http.Request) response.Response {
 			return response.SmartError(err)
 		}
 
-		networks, err := d.cluster.Networks()
+		networks, err := d.cluster.Networks("default")
 		if err != nil {
 			return response.SmartError(err)
 		}
@@ -979,6 +1081,73 @@ func clusterNodeDelete(d *Daemon, r *http.Request) response.Response {
 	return response.EmptySyncResponse
 }
 
+// Return the version each member runs, for rolling upgrades.
+func clusterUpgradeGet(d *Daemon, r *http.Request) response.Response {
+	status, err := cluster.UpgradeStatus(d.State())
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, status)
+}
+
+// Upgrade a single out-of-date member by running its LXD_CLUSTER_UPDATE
+// executable, so that members can be upgraded in a controlled order.
+func clusterUpgradePost(d *Daemon, r *http.Request) response.Response {
+	req := api.ClusterUpgradePost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if req.ServerName == "" {
+		return response.BadRequest(fmt.Errorf("No member specified"))
+	}
+
+	var localName string
+	var address string
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		localName, err = tx.NodeName()
+		if err != nil {
+			return err
+		}
+
+		node, err := tx.NodeByName(req.ServerName)
+		if err != nil {
+			return err
+		}
+
+		address = node.Address
+		return nil
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Have the member itself run its update.
+	if req.ServerName != localName {
+		client, err := cluster.Connect(address, d.endpoints.NetworkCert(), false)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		err = client.UpgradeClusterMember(req.ServerName)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		return response.EmptySyncResponse
+	}
+
+	err = cluster.UpdateMember(d.State())
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
 // This function is used to notify the leader that a node was removed, it will
 // decide whether to promote a new node as database node.
 func tryClusterRebalance(d *Daemon) error {
@@ -1242,7 +1411,7 @@ func clusterCheckStoragePoolsMatch(cluster *db.Cluster, reqPools []api.StoragePo
 			// Ignore missing ceph pools, since they'll be shared
 			// and we don't require them to be defined on the
 			// joining node.
-			if shared.StringInSlice(pool.Driver, []string{"ceph", "cephfs"}) {
+			if shared.StringInSlice(pool.Driver, []string{"ceph", "cephfs", "iscsi"}) {
 				continue
 			}
 
@@ -1253,7 +1422,7 @@ func clusterCheckStoragePoolsMatch(cluster *db.Cluster, reqPools []api.StoragePo
 }
 
 func clusterCheckNetworksMatch(cluster *db.Cluster, reqNetworks []api.Network) error {
-	networkNames, err := cluster.NetworksNotPending()
+	networkNames, err := cluster.NetworksNotPending("default")
 	if err != nil && err != db.ErrNoSuchObject {
 		return err
 	}
@@ -1264,7 +1433,7 @@ func clusterCheckNetworksMatch(cluster *db.Cluster, reqNetworks []api.Network) e
 				continue
 			}
 			found = true
-			_, network, err := cluster.NetworkGet(name)
+			_, network, err := cluster.NetworkGet("default", name)
 			if err != nil {
 				return err
 			}
@@ -395,6 +395,11 @@ func internalSQLExec(tx *sql.Tx, query string, result *internalSQLResult) error
 type internalImportPost struct {
 	Name  string `json:"name" yaml:"name"`
 	Force bool   `json:"force" yaml:"force"`
+
+	// What to do with the snapshots recorded in backup.yaml which don't exist on disk ("discard")
+	// and with those on disk which backup.yaml doesn't record ("delete" or "recover").
+	MissingSnapshots string `json:"missing_snapshots" yaml:"missing_snapshots"`
+	UnknownSnapshots string `json:"unknown_snapshots" yaml:"unknown_snapshots"`
 }
 
 func internalImport(d *Daemon, r *http.Request) response.Response {
@@ -550,55 +555,108 @@ func internalImport(d *Daemon, r *http.Request) response.Response {
 		_, _, poolName = initPool.GetContainerPoolInfo()
 	}
 
-	existingSnapshots := []*api.InstanceSnapshot{}
-	needForce := fmt.Errorf(`The snapshot does not exist on disk. Pass ` +
-		`"force" to discard non-existing snapshots`)
-
 	// Retrieve all snapshots that exist on disk.
 	onDiskSnapshots := []string{}
-	if len(backup.Snapshots) > 0 {
-		switch backup.Pool.Driver {
-		case "btrfs":
-			snapshotsDirPath := driver.GetSnapshotMountPoint(projectName, poolName, req.Name)
-			snapshotsDir, err := os.Open(snapshotsDirPath)
-			if err != nil {
-				return response.InternalError(err)
-			}
+	switch backup.Pool.Driver {
+	case "btrfs", "dir":
+		snapshotsDirPath := driver.GetSnapshotMountPoint(projectName, poolName, req.Name)
+		snapshotsDir, err := os.Open(snapshotsDirPath)
+		if err != nil && !os.IsNotExist(err) {
+			return response.InternalError(err)
+		}
+
+		if err == nil {
 			onDiskSnapshots, err = snapshotsDir.Readdirnames(-1)
-			if err != nil {
-				snapshotsDir.Close()
-				return response.InternalError(err)
-			}
 			snapshotsDir.Close()
-		case "dir":
-			snapshotsDirPath := driver.GetSnapshotMountPoint(projectName, poolName, req.Name)
-			snapshotsDir, err := os.Open(snapshotsDirPath)
 			if err != nil {
 				return response.InternalError(err)
 			}
-			onDiskSnapshots, err = snapshotsDir.Readdirnames(-1)
-			if err != nil {
-				snapshotsDir.Close()
+		}
+	case "lvm":
+		onDiskPoolName := backup.Pool.Config["lvm.vg_name"]
+		msg, err := shared.RunCommand("lvs", "-o", "lv_name",
+			onDiskPoolName, "--noheadings")
+		if err != nil {
+			return response.InternalError(err)
+		}
+
+		snaps := strings.Fields(msg)
+		prefix := fmt.Sprintf("containers_%s-", containerNameToLVName(project.Prefix(projectName, req.Name)))
+		for _, v := range snaps {
+			// ignore zombies
+			if strings.HasPrefix(v, prefix) {
+				onDiskSnapshots = append(onDiskSnapshots,
+					strings.Replace(v[len(prefix):], "--", "-", -1))
+			}
+		}
+	case "ceph":
+		clusterName := "ceph"
+		if backup.Pool.Config["ceph.cluster_name"] != "" {
+			clusterName = backup.Pool.Config["ceph.cluster_name"]
+		}
+
+		userName := "admin"
+		if backup.Pool.Config["ceph.user.name"] != "" {
+			userName = backup.Pool.Config["ceph.user.name"]
+		}
+
+		onDiskPoolName := backup.Pool.Config["ceph.osd.pool_name"]
+		snaps, err := cephRBDVolumeListSnapshots(clusterName,
+			onDiskPoolName, project.Prefix(projectName, req.Name),
+			storagePoolVolumeTypeNameContainer, userName)
+		if err != nil {
+			if err != db.ErrNoSuchObject {
 				return response.InternalError(err)
 			}
-			snapshotsDir.Close()
+		}
+
+		for _, v := range snaps {
+			// ignore zombies
+			if strings.HasPrefix(v, "snapshot_") {
+				onDiskSnapshots = append(onDiskSnapshots,
+					v[len("snapshot_"):])
+			}
+		}
+	case "zfs":
+		onDiskPoolName := backup.Pool.Config["zfs.pool_name"]
+		snaps, err := zfsPoolListSnapshots(onDiskPoolName,
+			fmt.Sprintf("containers/%s", project.Prefix(projectName, req.Name)))
+		if err != nil {
+			return response.InternalError(err)
+		}
+
+		for _, v := range snaps {
+			// ignore zombies
+			if strings.HasPrefix(v, "snapshot-") {
+				onDiskSnapshots = append(onDiskSnapshots,
+					v[len("snapshot-"):])
+			}
+		}
+	}
+
+	// Sort the snapshots recorded in backup.yaml into those still on disk and those missing.
+	existingSnapshots := []*api.InstanceSnapshot{}
+	missingSnapshots := []string{}
+	for _, snap := range backup.Snapshots {
+		exists := true
+
+		switch backup.Pool.Driver {
+		case "btrfs":
+			snpMntPt := driver.GetSnapshotMountPoint(projectName, backup.Pool.Name, snap.Name)
+			exists = shared.PathExists(snpMntPt) && isBtrfsSubVolume(snpMntPt)
+		case "dir":
+			snpMntPt := driver.GetSnapshotMountPoint(projectName, backup.Pool.Name, snap.Name)
+			exists = shared.PathExists(snpMntPt)
 		case "lvm":
-			onDiskPoolName := backup.Pool.Config["lvm.vg_name"]
-			msg, err := shared.RunCommand("lvs", "-o", "lv_name",
-				onDiskPoolName, "--noheadings")
+			ctName, csName, _ := shared.InstanceGetParentAndSnapshotName(snap.Name)
+			ctLvmName := containerNameToLVName(fmt.Sprintf("%s/%s", project.Prefix(projectName, ctName), csName))
+			ctLvName := getLVName(poolName,
+				storagePoolVolumeAPIEndpointContainers,
+				ctLvmName)
+			exists, err = storageLVExists(ctLvName)
 			if err != nil {
 				return response.InternalError(err)
 			}
-
-			snaps := strings.Fields(msg)
-			prefix := fmt.Sprintf("containers_%s-", project.Prefix(projectName, req.Name))
-			for _, v := range snaps {
-				// ignore zombies
-				if strings.HasPrefix(v, prefix) {
-					onDiskSnapshots = append(onDiskSnapshots,
-						v[len(prefix):])
-				}
-			}
 		case "ceph":
 			clusterName := "ceph"
 			if backup.Pool.Config["ceph.cluster_name"] != "" {
@@ -611,55 +669,35 @@ func internalImport(d *Daemon, r *http.Request) response.Response {
 			}
 
 			onDiskPoolName := backup.Pool.Config["ceph.osd.pool_name"]
-			snaps, err := cephRBDVolumeListSnapshots(clusterName,
-				onDiskPoolName, project.Prefix(projectName, req.Name),
-				storagePoolVolumeTypeNameContainer, userName)
-			if err != nil {
-				if err != db.ErrNoSuchObject {
-					return response.InternalError(err)
-				}
-			}
+			ctName, csName, _ := shared.InstanceGetParentAndSnapshotName(snap.Name)
+			ctName = project.Prefix(projectName, ctName)
+			snapshotName := fmt.Sprintf("snapshot_%s", csName)
 
-			for _, v := range snaps {
-				// ignore zombies
-				if strings.HasPrefix(v, "snapshot_") {
-					onDiskSnapshots = append(onDiskSnapshots,
-						v[len("snapshot_"):])
-				}
-			}
+			exists = cephRBDSnapshotExists(clusterName,
+				onDiskPoolName, ctName,
+				storagePoolVolumeTypeNameContainer,
+				snapshotName, userName)
 		case "zfs":
-			onDiskPoolName := backup.Pool.Config["zfs.pool_name"]
-			snaps, err := zfsPoolListSnapshots(onDiskPoolName,
-				fmt.Sprintf("containers/%s", project.Prefix(projectName, req.Name)))
-			if err != nil {
-				return response.InternalError(err)
-			}
-
-			for _, v := range snaps {
-				// ignore zombies
-				if strings.HasPrefix(v, "snapshot-") {
-					onDiskSnapshots = append(onDiskSnapshots,
-						v[len("snapshot-"):])
-				}
-			}
+			ctName, csName, _ := shared.InstanceGetParentAndSnapshotName(snap.Name)
+			snapshotName := fmt.Sprintf("snapshot-%s", csName)
 
+			exists = zfsFilesystemEntityExists(poolName,
+				fmt.Sprintf("containers/%s@%s", project.Prefix(projectName, ctName),
+					snapshotName))
 		}
-	}
 
-	if len(backup.Snapshots) != len(onDiskSnapshots) {
-		if !req.Force {
-			msg := `There are either snapshots that don't exist ` +
-				`on disk anymore or snapshots that are not ` +
-				`recorded in the "backup.yaml" file. Pass ` +
-				`"force" to remove them`
-			logger.Errorf(msg)
-			return response.InternalError(fmt.Errorf(msg))
+		if !exists {
+			_, snapOnlyName, _ := shared.InstanceGetParentAndSnapshotName(snap.Name)
+			missingSnapshots = append(missingSnapshots, snapOnlyName)
+			continue
 		}
+
+		existingSnapshots = append(existingSnapshots, snap)
 	}
 
-	// delete snapshots that do not exist in backup.yaml
-	od := ""
-	for _, od = range onDiskSnapshots {
+	// Find the snapshots on disk that backup.yaml doesn't know about.
+	unknownSnapshots := []string{}
+	for _, od := range onDiskSnapshots {
 		inBackupFile := false
 		for _, ib := range backup.Snapshots {
 			_, snapOnlyName, _ := shared.InstanceGetParentAndSnapshotName(ib.Name)
@@ -669,18 +707,81 @@ func internalImport(d *Daemon, r *http.Request) response.Response {
 			}
 		}
 
-		if inBackupFile {
-			continue
+		if !inBackupFile {
+			unknownSnapshots = append(unknownSnapshots, od)
 		}
+	}
+
+	// Force keeps its historical meaning of discarding whatever doesn't match.
+	missingAction := req.MissingSnapshots
+	if missingAction == "" && req.Force {
+		missingAction = "discard"
+	}
+
+	unknownAction := req.UnknownSnapshots
+	if unknownAction == "" && req.Force {
+		unknownAction = "delete"
+	}
+
+	if !shared.StringInSlice(missingAction, []string{"", "discard"}) {
+		return response.BadRequest(fmt.Errorf(`Invalid action "%s" for missing snapshots`, missingAction))
+	}
+
+	if !shared.StringInSlice(unknownAction, []string{"", "delete", "recover"}) {
+		return response.BadRequest(fmt.Errorf(`Invalid action "%s" for unknown snapshots`, unknownAction))
+	}
+
+	if len(missingSnapshots) > 0 && missingAction == "" {
+		return response.BadRequest(fmt.Errorf(`The snapshots "%s" are recorded `+
+			`in the "backup.yaml" file but don't exist on disk. Pass `+
+			`"--missing-snapshots=discard" to discard them`,
+			strings.Join(missingSnapshots, `", "`)))
+	}
+
+	if len(unknownSnapshots) > 0 && unknownAction == "" {
+		return response.BadRequest(fmt.Errorf(`The snapshots "%s" exist on `+
+			`disk but aren't recorded in the "backup.yaml" file. Pass `+
+			`"--unknown-snapshots=recover" to import them with the `+
+			`container's configuration or "--unknown-snapshots=delete" `+
+			`to delete them`, strings.Join(unknownSnapshots, `", "`)))
+	}
+
+	for _, name := range missingSnapshots {
+		logger.Warn("Discarding snapshot missing on disk", log.Ctx{"container": req.Name, "snapshot": name})
+	}
+
+	for _, od := range unknownSnapshots {
+		if unknownAction == "recover" {
+			logger.Warn("Recovering snapshot missing from backup.yaml", log.Ctx{"container": req.Name, "snapshot": od})
+
+			config := map[string]string{}
+			for k, v := range backup.Container.Config {
+				config[k] = v
+			}
 
-		if !req.Force {
-			msg := `There are snapshots that are not recorded in ` +
-				`the "backup.yaml" file. Pass "force" to ` +
-				`remove them`
-			logger.Errorf(msg)
-			return response.InternalError(fmt.Errorf(msg))
+			devices := map[string]map[string]string{}
+			for name, device := range backup.Container.Devices {
+				devices[name] = map[string]string{}
+				for k, v := range device {
+					devices[name][k] = v
+				}
+			}
+
+			existingSnapshots = append(existingSnapshots, &api.InstanceSnapshot{
+				InstanceSnapshotPut: api.InstanceSnapshotPut{
+					Architecture: backup.Container.Architecture,
+					Config:       config,
+					Devices:      devices,
+					Profiles:     backup.Container.Profiles,
+				},
+				Name: fmt.Sprintf("%s/%s", req.Name, od),
+			})
+
+			continue
 		}
 
+		logger.Warn("Deleting snapshot missing from backup.yaml", log.Ctx{"container": req.Name, "snapshot": od})
+
 		var err error
 		switch backup.Pool.Driver {
 		case "btrfs":
@@ -696,7 +797,7 @@ func internalImport(d *Daemon, r *http.Request) response.Response {
 			}
 			snapName := fmt.Sprintf("%s/%s", req.Name, od)
 			snapPath := driver.InstancePath(instancetype.Container, projectName, snapName, true)
-			err = lvmContainerDeleteInternal(projectName, poolName, req.Name,
+			err = lvmContainerDeleteInternal(projectName, poolName, snapName,
 				true, onDiskPoolName, snapPath)
 		case "ceph":
 			clusterName := "ceph"
@@ -724,89 +825,10 @@ func internalImport(d *Daemon, r *http.Request) response.Response {
 				onDiskPoolName)
 		}
 		if err != nil {
-			logger.Warnf(`Failed to delete snapshot`)
+			logger.Warn("Failed to delete snapshot", log.Ctx{"container": req.Name, "snapshot": od, "err": err})
 		}
 	}
 
-	for _, snap := range backup.Snapshots {
-		switch backup.Pool.Driver {
-		case "btrfs":
-			snpMntPt := driver.GetSnapshotMountPoint(projectName, backup.Pool.Name, snap.Name)
-			if !shared.PathExists(snpMntPt) || !isBtrfsSubVolume(snpMntPt) {
-				if req.Force {
-					continue
-				}
-				return response.BadRequest(needForce)
-			}
-		case "dir":
-			snpMntPt := driver.GetSnapshotMountPoint(projectName, backup.Pool.Name, snap.Name)
-			if !shared.PathExists(snpMntPt) {
-				if req.Force {
-					continue
-				}
-				return response.BadRequest(needForce)
-			}
-		case "lvm":
-			ctName, csName, _ := shared.InstanceGetParentAndSnapshotName(snap.Name)
-			ctLvmName := containerNameToLVName(fmt.Sprintf("%s/%s", project.Prefix(projectName, ctName), csName))
-			ctLvName := getLVName(poolName,
-				storagePoolVolumeAPIEndpointContainers,
-				ctLvmName)
-			exists, err := storageLVExists(ctLvName)
-			if err != nil {
-				return response.InternalError(err)
-			}
-
-			if !exists {
-				if req.Force {
-					continue
-				}
-				return response.BadRequest(needForce)
-			}
-		case "ceph":
-			clusterName := "ceph"
-			if backup.Pool.Config["ceph.cluster_name"] != "" {
-				clusterName = backup.Pool.Config["ceph.cluster_name"]
-			}
-
-			userName := "admin"
-			if backup.Pool.Config["ceph.user.name"] != "" {
-				userName = backup.Pool.Config["ceph.user.name"]
-			}
-
-			onDiskPoolName := backup.Pool.Config["ceph.osd.pool_name"]
-			ctName, csName, _ := shared.InstanceGetParentAndSnapshotName(snap.Name)
-			ctName = project.Prefix(projectName, ctName)
-			snapshotName := fmt.Sprintf("snapshot_%s", csName)
-
-			exists := cephRBDSnapshotExists(clusterName,
-				onDiskPoolName, ctName,
-				storagePoolVolumeTypeNameContainer,
-				snapshotName, userName)
-			if !exists {
-				if req.Force {
-					continue
-				}
-				return response.BadRequest(needForce)
-			}
-		case "zfs":
-			ctName, csName, _ := shared.InstanceGetParentAndSnapshotName(snap.Name)
-			snapshotName := fmt.Sprintf("snapshot-%s", csName)
-
-			exists := zfsFilesystemEntityExists(poolName,
-				fmt.Sprintf("containers/%s@%s", project.Prefix(projectName, ctName),
-					snapshotName))
-			if !exists {
-				if req.Force {
-					continue
-				}
-				return response.BadRequest(needForce)
-			}
-		}
-
-		existingSnapshots = append(existingSnapshots, snap)
-	}
-
 	// Check if a storage volume entry for the container already exists.
 	_, volume, ctVolErr := d.cluster.StoragePoolNodeVolumeGetType(
 		req.Name, storagePoolVolumeTypeContainer, poolID)
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var metadataConfigurationCmd = APIEndpoint{
+	Path: "metadata/configuration",
+
+	Get: APIEndpointAction{Handler: metadataConfigurationGet, AccessHandler: AllowAuthenticated},
+}
+
+func metadataConfigurationGet(d *Daemon, r *http.Request) response.Response {
+	return response.SyncResponse(true, api.MetadataConfiguration{Configs: configMetadata()})
+}
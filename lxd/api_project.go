@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
@@ -206,6 +207,7 @@ func projectGet(d *Daemon, r *http.Request) response.Response {
 		project.Description,
 		project.Config["features.images"],
 		project.Config["features.profiles"],
+		project.Config["features.networks"],
 	}
 
 	return response.SyncResponseETag(true, project, etag)
@@ -235,6 +237,7 @@ func projectPut(d *Daemon, r *http.Request) response.Response {
 		project.Description,
 		project.Config["features.images"],
 		project.Config["features.profiles"],
+		project.Config["features.networks"],
 	}
 	err = util.EtagCheck(r, etag)
 	if err != nil {
@@ -276,6 +279,7 @@ func projectPatch(d *Daemon, r *http.Request) response.Response {
 		project.Description,
 		project.Config["features.images"],
 		project.Config["features.profiles"],
+		project.Config["features.networks"],
 	}
 	err = util.EtagCheck(r, etag)
 	if err != nil {
@@ -316,13 +320,18 @@ func projectPatch(d *Daemon, r *http.Request) response.Response {
 		req.Config["features.images"] = project.Config["features.profiles"]
 	}
 
+	_, err = reqRaw.GetBool("features.networks")
+	if err != nil {
+		req.Config["features.networks"] = project.Config["features.networks"]
+	}
+
 	return projectChange(d, project, req)
 }
 
 // Common logic between PUT and PATCH.
 func projectChange(d *Daemon, project *api.Project, req api.ProjectPut) response.Response {
 	// Flag indicating if any feature has changed.
-	featuresChanged := req.Config["features.images"] != project.Config["features.images"] || req.Config["features.profiles"] != project.Config["features.profiles"]
+	featuresChanged := req.Config["features.images"] != project.Config["features.images"] || req.Config["features.profiles"] != project.Config["features.profiles"] || req.Config["features.networks"] != project.Config["features.networks"]
 
 	// Sanity checks
 	if project.Name == "default" && featuresChanged {
@@ -333,12 +342,45 @@ func projectChange(d *Daemon, project *api.Project, req api.ProjectPut) response
 		return response.BadRequest(fmt.Errorf("Features can only be changed on empty projects"))
 	}
 
+	// Changing the uid/gid range of a project would leave its containers with overlapping maps.
+	idmapChanged := req.Config["security.idmap.isolated"] != project.Config["security.idmap.isolated"] || req.Config["security.idmap.size"] != project.Config["security.idmap.size"]
+	if !projectIsEmpty(project) && idmapChanged {
+		return response.BadRequest(fmt.Errorf("The uid/gid isolation of a project can only be changed on empty projects"))
+	}
+
+	// Volatile keys are managed by LXD, only keep the current ones. The uid/gid range is
+	// allocated again on next use if changed.
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	for k := range req.Config {
+		if strings.HasPrefix(k, "volatile.") {
+			delete(req.Config, k)
+		}
+	}
+
 	// Validate the configuration
 	err := projectValidateConfig(req.Config)
 	if err != nil {
 		return response.BadRequest(err)
 	}
 
+	err = projectLimitsCheckProjectConfig(d.State(), project, req.Config)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	for k, v := range project.Config {
+		if strings.HasPrefix(k, "volatile.") {
+			req.Config[k] = v
+		}
+	}
+
+	if idmapChanged {
+		delete(req.Config, "volatile.idmap.base")
+	}
+
 	// Update the database entry
 	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
 		err := tx.ProjectUpdate(project.Name, req)
@@ -369,6 +411,11 @@ func projectChange(d *Daemon, project *api.Project, req api.ProjectPut) response
 		return response.SmartError(err)
 	}
 
+	// Reschedule the pruning of cached images, which may now be needed or not anymore.
+	if req.Config["images.remote_cache_expiry"] != project.Config["images.remote_cache_expiry"] && !d.os.MockMode {
+		d.taskPruneImages.Reset()
+	}
+
 	return response.EmptySyncResponse
 }
 
@@ -428,6 +475,9 @@ func projectPost(d *Daemon, r *http.Request) response.Response {
 			}
 		}
 
+		// Refresh the project names of restricted client certificates.
+		readSavedClientCAList(d)
+
 		return nil
 	}
 
@@ -476,6 +526,9 @@ func projectDelete(d *Daemon, r *http.Request) response.Response {
 		}
 	}
 
+	// Refresh the project names of restricted client certificates.
+	readSavedClientCAList(d)
+
 	return response.EmptySyncResponse
 }
 
@@ -495,6 +548,37 @@ func projectIsEmpty(project *api.Project) bool {
 var projectConfigKeys = map[string]func(value string) error{
 	"features.profiles": shared.IsBool,
 	"features.images":   shared.IsBool,
+	"features.networks": shared.IsBool,
+
+	"exec.record":        shared.IsBool,
+	"exec.record.stream": shared.IsBool,
+
+	"images.remote_cache_expiry": shared.IsInt64,
+
+	"limits.cpu":    projectLimitsValidate("limits.cpu"),
+	"limits.memory": projectLimitsValidate("limits.memory"),
+	"limits.disk":   projectLimitsValidate("limits.disk"),
+
+	"security.idmap.isolated": shared.IsBool,
+	"security.idmap.size":     projectValidateIdmapSize,
+}
+
+// projectValidateIdmapSize checks the size of a project's uid/gid range.
+func projectValidateIdmapSize(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	if size < 65536 {
+		return fmt.Errorf("The uid/gid range of a project must be at least 65536 long")
+	}
+
+	return nil
 }
 
 func projectValidateConfig(config map[string]string) error {
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db"
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/resources"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/units"
+)
+
+// projectLimitsKeys lists the project keys capping the total resources of the project's instances
+// and volumes.
+var projectLimitsKeys = []string{"limits.cpu", "limits.memory", "limits.disk"}
+
+// projectLimitsVolume is a custom storage volume counted against the limits of a project.
+type projectLimitsVolume struct {
+	pool   string
+	name   string
+	config map[string]string
+}
+
+// projectLimitsUsage holds the resources counted against each of the limits of a project.
+type projectLimitsUsage map[string]int64
+
+// projectLimitsCheckInstance checks that the limits of the project still hold once the instance is
+// created or updated with the given arguments.
+func projectLimitsCheckInstance(s *state.State, instType instancetype.Type, instName string, args db.InstanceArgs) error {
+	inst := db.Instance{
+		Project:  args.Project,
+		Name:     instName,
+		Type:     instType,
+		Config:   args.Config,
+		Devices:  args.Devices.CloneNative(),
+		Profiles: args.Profiles,
+	}
+
+	return projectLimitsCheck(s, args.Project, nil, &inst, nil)
+}
+
+// projectLimitsCheckVolume checks that the limits of the project still hold once the custom volume
+// is created or updated with the given config.
+func projectLimitsCheckVolume(s *state.State, projectName string, poolName string, volumeName string, config map[string]string) error {
+	return projectLimitsCheck(s, projectName, nil, nil, &projectLimitsVolume{pool: poolName, name: volumeName, config: config})
+}
+
+// projectLimitsCheckProjectConfig checks the limits of an updated project config against its
+// current instances and volumes.
+func projectLimitsCheckProjectConfig(s *state.State, project *api.Project, config map[string]string) error {
+	changed := false
+	for _, key := range projectLimitsKeys {
+		if config[key] != project.Config[key] {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return projectLimitsCheck(s, project.Name, config, nil, nil)
+}
+
+// projectLimitsCheck compares the totals of the project, with the instance or volume replaced, to
+// the limits of the project. The limits are taken from the given config if not nil.
+func projectLimitsCheck(s *state.State, projectName string, config map[string]string, newInst *db.Instance, newVol *projectLimitsVolume) error {
+	var insts []db.Instance
+
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		if config == nil {
+			project, err := tx.ProjectGet(projectName)
+			if err != nil {
+				return errors.Wrapf(err, "Fetch project %q", projectName)
+			}
+
+			config = project.Config
+		}
+
+		var err error
+		insts, err = tx.InstanceList(db.InstanceFilter{Project: projectName, Type: instancetype.Any})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	limits := map[string]int64{}
+	for _, key := range projectLimitsKeys {
+		if config[key] == "" {
+			continue
+		}
+
+		limit, err := projectLimitsParse(key, config[key])
+		if err != nil {
+			return err
+		}
+
+		limits[key] = limit
+	}
+
+	if len(limits) == 0 {
+		return nil
+	}
+
+	vols := []projectLimitsVolume{}
+	_, ok := limits["limits.disk"]
+	if ok {
+		vols, err = projectLimitsVolumes(s, projectName)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Usage of the current instances and volumes, and of those replaced by the change.
+	current := projectLimitsUsage{}
+	replaced := projectLimitsUsage{}
+	pools := map[string]map[string]string{}
+
+	for _, inst := range insts {
+		usage, err := projectLimitsInstanceUsage(s, limits, inst, pools)
+		if err != nil {
+			return err
+		}
+
+		current.add(usage)
+		if newInst != nil && inst.Name == newInst.Name {
+			replaced.add(usage)
+		}
+	}
+
+	for _, vol := range vols {
+		usage, err := projectLimitsVolumeUsage(s, limits, vol, pools)
+		if err != nil {
+			return err
+		}
+
+		current.add(usage)
+		if newVol != nil && vol.pool == newVol.pool && vol.name == newVol.name {
+			replaced.add(usage)
+		}
+	}
+
+	requested := projectLimitsUsage{}
+	if newInst != nil {
+		requested, err = projectLimitsInstanceUsage(s, limits, *newInst, pools)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Custom volumes are shared by all projects and only count against the default project.
+	if newVol != nil && projectName == "default" {
+		requested, err = projectLimitsVolumeUsage(s, limits, *newVol, pools)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, key := range projectLimitsKeys {
+		limit, ok := limits[key]
+		if !ok {
+			continue
+		}
+
+		total := current[key] - replaced[key] + requested[key]
+		if total <= limit {
+			continue
+		}
+
+		// Let changes through which don't increase the usage, so that over limit projects can
+		// still be cleaned up.
+		if (newInst != nil || newVol != nil) && requested[key] <= replaced[key] {
+			continue
+		}
+
+		return fmt.Errorf("The %s limit of project %q is %s, the total would be %s (currently %s)", key, projectName, projectLimitsFormat(key, limit), projectLimitsFormat(key, total), projectLimitsFormat(key, current[key]))
+	}
+
+	return nil
+}
+
+// add adds the values of the other usage to the usage.
+func (u projectLimitsUsage) add(other projectLimitsUsage) {
+	for key, value := range other {
+		u[key] += value
+	}
+}
+
+// projectLimitsParse parses the value of a limit key of a project.
+func projectLimitsParse(key string, value string) (int64, error) {
+	if key == "limits.cpu" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit < 1 {
+			return -1, fmt.Errorf("Invalid value for %s, must be a number of CPUs: %s", key, value)
+		}
+
+		return limit, nil
+	}
+
+	limit, err := units.ParseByteSizeString(value)
+	if err != nil || limit < 1 {
+		return -1, fmt.Errorf("Invalid value for %s, must be a size: %s", key, value)
+	}
+
+	return limit, nil
+}
+
+// projectLimitsFormat formats a value counted against a limit of a project.
+func projectLimitsFormat(key string, value int64) string {
+	if key == "limits.cpu" {
+		return fmt.Sprintf("%d", value)
+	}
+
+	return units.GetByteSizeString(value, 2)
+}
+
+// projectLimitsValidate checks the value of a limit key of a project.
+func projectLimitsValidate(key string) func(value string) error {
+	return func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		_, err := projectLimitsParse(key, value)
+		return err
+	}
+}
+
+// projectLimitsVolumes returns the custom volumes counted against the limits of the project.
+func projectLimitsVolumes(s *state.State, projectName string) ([]projectLimitsVolume, error) {
+	vols := []projectLimitsVolume{}
+
+	// Custom volumes are shared by all projects and only count against the default project.
+	if projectName != "default" {
+		return vols, nil
+	}
+
+	poolNames, err := s.Cluster.StoragePools()
+	if err != nil && err != db.ErrNoSuchObject {
+		return nil, err
+	}
+
+	for _, poolName := range poolNames {
+		poolID, err := s.Cluster.StoragePoolGetID(poolName)
+		if err != nil {
+			return nil, err
+		}
+
+		poolVols, err := s.Cluster.StoragePoolVolumesGet(projectName, poolID, []int{db.StoragePoolVolumeTypeCustom})
+		if err != nil && err != db.ErrNoSuchObject {
+			return nil, err
+		}
+
+		for _, vol := range poolVols {
+			if shared.IsSnapshot(vol.Name) {
+				continue
+			}
+
+			vols = append(vols, projectLimitsVolume{pool: poolName, name: vol.Name, config: vol.Config})
+		}
+	}
+
+	return vols, nil
+}
+
+// projectLimitsInstanceUsage returns the resources of the instance counted against the limits.
+func projectLimitsInstanceUsage(s *state.State, limits map[string]int64, inst db.Instance, pools map[string]map[string]string) (projectLimitsUsage, error) {
+	profiles, err := s.Cluster.ProfilesGet(inst.Project, inst.Profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	config := db.ProfilesExpandConfig(inst.Config, profiles)
+	devices := db.ProfilesExpandDevices(deviceConfig.NewDevices(inst.Devices), profiles)
+
+	// Virtual machines get a single CPU and 1GiB of memory by default.
+	if inst.Type == instancetype.VM {
+		if config["limits.cpu"] == "" {
+			config["limits.cpu"] = "1"
+		}
+
+		if config["limits.memory"] == "" {
+			config["limits.memory"] = "1GiB"
+		}
+	}
+
+	usage := projectLimitsUsage{}
+
+	_, ok := limits["limits.cpu"]
+	if ok {
+		value := config["limits.cpu"]
+		if value == "" {
+			return nil, fmt.Errorf("Instance %q must set limits.cpu, as its project has a limits.cpu limit", inst.Name)
+		}
+
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			cpus, err := resources.ParseCpuset(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Invalid limits.cpu of instance %q", inst.Name)
+			}
+
+			count = int64(len(cpus))
+		}
+
+		usage["limits.cpu"] = count
+	}
+
+	_, ok = limits["limits.memory"]
+	if ok {
+		value := config["limits.memory"]
+		if value == "" || strings.HasSuffix(value, "%") {
+			return nil, fmt.Errorf("Instance %q must set limits.memory to a size, as its project has a limits.memory limit", inst.Name)
+		}
+
+		memory, err := units.ParseByteSizeString(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid limits.memory of instance %q", inst.Name)
+		}
+
+		usage["limits.memory"] = memory
+	}
+
+	_, ok = limits["limits.disk"]
+	if ok {
+		for _, dev := range devices {
+			if dev["type"] != "disk" || !shared.IsRootDiskDevice(dev) {
+				continue
+			}
+
+			size, err := projectLimitsVolumeSize(s, dev["pool"], dev["size"], pools)
+			if err != nil {
+				return nil, err
+			}
+
+			if size == 0 {
+				return nil, fmt.Errorf("The root disk of instance %q must have a size, as its project has a limits.disk limit", inst.Name)
+			}
+
+			usage["limits.disk"] = size
+		}
+	}
+
+	return usage, nil
+}
+
+// projectLimitsVolumeUsage returns the resources of the custom volume counted against the limits.
+func projectLimitsVolumeUsage(s *state.State, limits map[string]int64, vol projectLimitsVolume, pools map[string]map[string]string) (projectLimitsUsage, error) {
+	usage := projectLimitsUsage{}
+
+	_, ok := limits["limits.disk"]
+	if !ok {
+		return usage, nil
+	}
+
+	size, err := projectLimitsVolumeSize(s, vol.pool, vol.config["size"], pools)
+	if err != nil {
+		return nil, err
+	}
+
+	if size == 0 {
+		return nil, fmt.Errorf("Storage volume %q must have a size, as its project has a limits.disk limit", vol.name)
+	}
+
+	usage["limits.disk"] = size
+
+	return usage, nil
+}
+
+// projectLimitsVolumeSize returns the size of a volume, falling back to the default volume size of
+// its pool. Zero is returned if neither is set.
+func projectLimitsVolumeSize(s *state.State, poolName string, size string, pools map[string]map[string]string) (int64, error) {
+	if size == "" && poolName != "" {
+		poolConfig, ok := pools[poolName]
+		if !ok {
+			_, pool, err := s.Cluster.StoragePoolGet(poolName)
+			if err != nil {
+				return -1, err
+			}
+
+			poolConfig = pool.Config
+			pools[poolName] = poolConfig
+		}
+
+		size = poolConfig["volume.size"]
+	}
+
+	if size == "" {
+		return 0, nil
+	}
+
+	return units.ParseByteSizeString(size)
+}
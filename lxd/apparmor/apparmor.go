@@ -482,15 +482,20 @@ const profileUnprivileged = `
   mount options=(ro,remount) /**,
 `
 
-type instance interface {
+// profiled is anything an AppArmor profile gets generated for.
+type profiled interface {
 	Project() string
 	Name() string
-	IsNesting() bool
 	DaemonState() *state.State
-	IsPrivileged() bool
 	ExpandedConfig() map[string]string
 }
 
+type instance interface {
+	profiled
+	IsNesting() bool
+	IsPrivileged() bool
+}
+
 func mkApparmorName(name string) string {
 	if len(name)+7 >= 253 {
 		hash := sha256.New()
@@ -513,14 +518,14 @@ func Namespace(c instance) string {
 }
 
 // ProfileFull returns the instance's apparmor profile.
-func ProfileFull(c instance) string {
+func ProfileFull(c profiled) string {
 	lxddir := shared.VarPath("")
 	lxddir = mkApparmorName(lxddir)
 	name := project.Prefix(c.Project(), c.Name())
 	return fmt.Sprintf("lxd-%s_<%s>", name, lxddir)
 }
 
-func profileShort(c instance) string {
+func profileShort(c profiled) string {
 	name := project.Prefix(c.Project(), c.Name())
 	return fmt.Sprintf("lxd-%s", name)
 }
@@ -600,14 +605,7 @@ func getAAProfileContent(c instance) string {
 		profile += strings.TrimLeft(profileUnprivileged, "\n")
 	}
 
-	// Append raw.apparmor
-	rawApparmor, ok := c.ExpandedConfig()["raw.apparmor"]
-	if ok {
-		profile += "\n  ### Configuration: raw.apparmor\n"
-		for _, line := range strings.Split(strings.Trim(rawApparmor, "\n"), "\n") {
-			profile += fmt.Sprintf("  %s\n", line)
-		}
-	}
+	profile += rawApparmor(c)
 
 	return fmt.Sprintf(`#include <tunables/global>
 profile "%s" flags=(attach_disconnected,mediate_deleted) {
@@ -616,7 +614,22 @@ profile "%s" flags=(attach_disconnected,mediate_deleted) {
 `, ProfileFull(c), strings.Trim(profile, "\n"))
 }
 
-func runApparmor(command string, c instance) error {
+// rawApparmor returns the profile lines coming from the instance's raw.apparmor.
+func rawApparmor(c profiled) string {
+	rawApparmor, ok := c.ExpandedConfig()["raw.apparmor"]
+	if !ok {
+		return ""
+	}
+
+	profile := "\n  ### Configuration: raw.apparmor\n"
+	for _, line := range strings.Split(strings.Trim(rawApparmor, "\n"), "\n") {
+		profile += fmt.Sprintf("  %s\n", line)
+	}
+
+	return profile
+}
+
+func runApparmor(command string, c profiled) error {
 	state := c.DaemonState()
 	if !state.OS.AppArmorAvailable {
 		return nil
@@ -682,6 +695,11 @@ func LoadProfile(c instance) error {
 		return err
 	}
 
+	return loadProfile(c, getAAProfileContent(c))
+}
+
+// loadProfile writes the given profile content for the instance and loads it.
+func loadProfile(c profiled, updated string) error {
 	/* In order to avoid forcing a profile parse (potentially slow) on
 	 * every container start, let's use apparmor's binary policy cache,
 	 * which checks mtime of the files to figure out if the policy needs to
@@ -699,8 +717,6 @@ func LoadProfile(c instance) error {
 		return err
 	}
 
-	updated := getAAProfileContent(c)
-
 	if string(content) != string(updated) {
 		if err := os.MkdirAll(path.Join(aaPath, "cache"), 0700); err != nil {
 			return err
@@ -747,7 +763,7 @@ func ParseProfile(c instance) error {
 }
 
 // DeleteProfile removes the policy from cache/disk.
-func DeleteProfile(c instance) {
+func DeleteProfile(c profiled) {
 	state := c.DaemonState()
 	if !state.OS.AppArmorAdmin {
 		return
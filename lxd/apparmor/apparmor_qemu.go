@@ -0,0 +1,133 @@
+package apparmor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const qemuProfileBase = `
+  #include <abstractions/base>
+  #include <abstractions/consoles>
+  #include <abstractions/nameservice>
+
+  capability dac_override,
+  capability dac_read_search,
+  capability ipc_lock,
+  capability setgid,
+  capability setuid,
+  capability sys_chroot,
+  capability sys_resource,
+
+  # Allow LXD to stop the VM
+  signal (receive) peer=unconfined,
+
+  # Devices
+  /dev/hugepages/** rw,
+  /dev/kvm rw,
+  /dev/net/tun rw,
+  /dev/ptmx rw,
+  /dev/sev rw,
+  /dev/tap* rw,
+  /dev/vfio/** rw,
+  /dev/vhost-net rw,
+  /dev/vhost-vsock rw,
+
+  # System information
+  @{PROC}/** r,
+  owner @{PROC}/@{pid}/task/@{tid}/comm rw,
+  /sys/bus/ r,
+  /sys/devices/** r,
+  /sys/kernel/mm/transparent_hugepage/** r,
+  /sys/module/vhost/** r,
+
+  # Firmware and ROMs shipped with QEMU
+  /usr/share/ovmf/** kr,
+  /usr/share/OVMF/** kr,
+  /usr/share/qemu/** kr,
+  /usr/share/seabios/** kr,
+`
+
+// QemuPaths lists what a virtual machine's QEMU process needs to reach on top of the instance's
+// own directories.
+type QemuPaths struct {
+	// Binary is the full path to the QEMU binary.
+	Binary string
+
+	// Firmware is the directory holding the EFI firmware.
+	Firmware string
+
+	// Disks are the disk images and block devices passed to the VM.
+	Disks []string
+}
+
+// qemuInstance is a virtual machine confined through AppArmor.
+type qemuInstance interface {
+	profiled
+	Path() string
+	LogPath() string
+	DevicesPath() string
+}
+
+// getQemuProfileContent generates the apparmor profile restricting QEMU to the VM's own paths.
+func getQemuProfileContent(c qemuInstance, paths QemuPaths) string {
+	profile := strings.TrimLeft(qemuProfileBase, "\n")
+
+	profile += "\n  # QEMU binary\n"
+	profile += fmt.Sprintf("  \"%s\" mrix,\n", paths.Binary)
+	if !strings.HasPrefix(paths.Binary, "/usr/") {
+		// Binaries shipped with their own libraries (e.g. in the snap).
+		profile += fmt.Sprintf("  \"%s/**\" mr,\n", filepath.Dir(filepath.Dir(paths.Binary)))
+	}
+
+	profile += "\n  # Instance paths\n"
+	profile += fmt.Sprintf("  \"%s/**\" rwk,\n", c.Path())
+	profile += fmt.Sprintf("  \"%s/**\" rwk,\n", c.LogPath())
+	profile += fmt.Sprintf("  \"%s/**\" rwk,\n", c.DevicesPath())
+
+	if paths.Firmware != "" {
+		profile += fmt.Sprintf("  \"%s/**\" kr,\n", paths.Firmware)
+	}
+
+	if len(paths.Disks) > 0 {
+		profile += "\n  # Disks\n"
+		for _, disk := range paths.Disks {
+			profile += fmt.Sprintf("  \"%s\" rwk,\n", disk)
+
+			// Rules apply to the resolved path of symlinks (e.g. /dev/zvol/).
+			target, err := filepath.EvalSymlinks(disk)
+			if err == nil && target != disk {
+				profile += fmt.Sprintf("  \"%s\" rwk,\n", target)
+			}
+		}
+	}
+
+	profile += rawApparmor(c)
+
+	return fmt.Sprintf(`#include <tunables/global>
+profile "%s" flags=(attach_disconnected,mediate_deleted) {
+%s
+}
+`, ProfileFull(c), strings.Trim(profile, "\n"))
+}
+
+// LoadQemuProfile writes and loads the profile confining the virtual machine's QEMU process.
+func LoadQemuProfile(c qemuInstance, paths QemuPaths) error {
+	state := c.DaemonState()
+	if !state.OS.AppArmorAdmin {
+		return nil
+	}
+
+	return loadProfile(c, getQemuProfileContent(c, paths))
+}
+
+// UnloadQemuProfile unloads the virtual machine's profile from the kernel. This does not delete
+// the policy from disk or cache.
+func UnloadQemuProfile(c qemuInstance) error {
+	state := c.DaemonState()
+	if !state.OS.AppArmorAdmin {
+		return nil
+	}
+
+	return runApparmor(cmdUnload, c)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+var auditCmd = APIEndpoint{
+	Path: "audit",
+
+	Get: APIEndpointAction{Handler: auditGet},
+}
+
+func auditGet(d *Daemon, r *http.Request) response.Response {
+	filter := db.AuditFilter{
+		Project:  queryParam(r, "project"),
+		Username: queryParam(r, "username"),
+		Method:   strings.ToUpper(queryParam(r, "method")),
+	}
+
+	var err error
+	for key, dest := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := queryParam(r, key)
+		if value == "" {
+			continue
+		}
+
+		*dest, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return response.BadRequest(fmt.Errorf("Invalid %q time %q: %v", key, value, err))
+		}
+	}
+
+	limit := queryParam(r, "limit")
+	if limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 0 {
+			return response.BadRequest(fmt.Errorf("Invalid limit %q", limit))
+		}
+	}
+
+	entries := []api.AuditEntry{}
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		dbEntries, err := tx.AuditEntries(filter)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range dbEntries {
+			entries = append(entries, api.AuditEntry{
+				ID:       e.ID,
+				Date:     e.Date,
+				Location: e.Location,
+				Method:   e.Method,
+				URL:      e.URL,
+				Project:  e.Project,
+				Requestor: api.AuditRequestor{
+					Protocol: e.Protocol,
+					Username: e.Username,
+					Address:  e.Address,
+				},
+				StatusCode: e.StatusCode,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, entries)
+}
+
+// auditResponseWriter records the status code of the response to a request,
+// for the audit log.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (w *auditResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *auditResponseWriter) Write(p []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush passes flushes through, for streamed responses.
+func (w *auditResponseWriter) Flush() {
+	flusher, ok := w.ResponseWriter.(http.Flusher)
+	if ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack passes hijacking through, for websockets and upgraded connections
+// which are recorded as switching protocols.
+func (w *auditResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("Webserver doesn't support hijacking")
+	}
+
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusSwitchingProtocols
+	}
+
+	return hijacker.Hijack()
+}
+
+// auditRequired returns whether the request changes state and needs to be
+// recorded in the audit log. Requests forwarded by other cluster members are
+// recorded by the member they were sent to.
+func auditRequired(r *http.Request, version string, protocol string) bool {
+	if version != "1.0" || protocol == "cluster" {
+		return false
+	}
+
+	return r.Method != "GET" && r.Method != "HEAD" && r.Method != "OPTIONS"
+}
+
+// auditRecord records the request in the audit log, in the background so
+// that the response isn't delayed.
+func auditRecord(d *Daemon, r *http.Request, protocol string, username string, statusCode int) {
+	entry := db.AuditEntry{
+		Method:     r.Method,
+		URL:        r.URL.Path,
+		Project:    projectParam(r),
+		Protocol:   protocol,
+		Username:   username,
+		Address:    r.RemoteAddr,
+		StatusCode: statusCode,
+	}
+
+	go func() {
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			entry.Location, err = tx.NodeName()
+			if err != nil {
+				return err
+			}
+
+			return tx.AuditEntryCreate(entry)
+		})
+		if err != nil {
+			logger.Error("Failed to record audit log entry", log.Ctx{"method": entry.Method, "url": entry.URL, "err": err})
+		}
+	}()
+}
+
+// pruneAuditLogTask removes the audit log entries older than the configured
+// retention.
+func pruneAuditLogTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			config, err := cluster.ConfigLoad(tx)
+			if err != nil {
+				return err
+			}
+
+			retention := config.AuditRetention()
+			if retention <= 0 {
+				return nil
+			}
+
+			return tx.AuditEntriesPrune(time.Now().Add(-retention))
+		})
+		if err != nil {
+			logger.Error("Failed to prune the audit log", log.Ctx{"err": err})
+		}
+	}
+
+	return f, task.Daily()
+}
@@ -0,0 +1,357 @@
+package bgp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	bgpAPI "github.com/osrg/gobgp/api"
+	bgpServer "github.com/osrg/gobgp/pkg/server"
+)
+
+// Server represents an embedded BGP speaker announcing routes to its peers.
+type Server struct {
+	bgp *bgpServer.BgpServer
+	mu  sync.Mutex
+
+	address  string
+	asn      uint32
+	routerID net.IP
+
+	// Peers and prefixes are kept around so they can be re-applied when the listener is restarted.
+	peers    map[string]peer
+	prefixes map[string]prefix
+}
+
+type peer struct {
+	address  net.IP
+	asn      uint32
+	password string
+	count    int
+}
+
+type prefix struct {
+	owner   string
+	subnet  net.IPNet
+	nexthop net.IP
+	uuid    []byte
+}
+
+// NewServer returns a new, not yet started, BGP server.
+func NewServer() *Server {
+	return &Server{
+		peers:    map[string]peer{},
+		prefixes: map[string]prefix{},
+	}
+}
+
+// Start configures and starts the BGP speaker on the given "host:port" address.
+// An empty address stops the speaker.
+func (s *Server) Start(address string, asn uint32, routerID net.IP) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Nothing to do if nothing changed.
+	if s.address == address && s.asn == asn && s.routerID.Equal(routerID) {
+		return nil
+	}
+
+	err := s.stop()
+	if err != nil {
+		return err
+	}
+
+	if address == "" {
+		return nil
+	}
+
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("Invalid BGP address %q: %v", address, err)
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("Invalid BGP port %q: %v", portStr, err)
+	}
+
+	if asn == 0 {
+		return fmt.Errorf("A BGP ASN must be configured to start the BGP server")
+	}
+
+	if routerID == nil || routerID.To4() == nil {
+		return fmt.Errorf("A valid IPv4 router ID must be configured to start the BGP server")
+	}
+
+	s.bgp = bgpServer.NewBgpServer()
+	go s.bgp.Serve()
+
+	listenAddresses := []string{}
+	if host != "" {
+		listenAddresses = append(listenAddresses, host)
+	}
+
+	err = s.bgp.StartBgp(context.Background(), &bgpAPI.StartBgpRequest{
+		Global: &bgpAPI.Global{
+			As:              asn,
+			RouterId:        routerID.String(),
+			ListenPort:      int32(port),
+			ListenAddresses: listenAddresses,
+		},
+	})
+	if err != nil {
+		s.bgp.Stop()
+		s.bgp = nil
+		return fmt.Errorf("Failed to start BGP server: %v", err)
+	}
+
+	s.address = address
+	s.asn = asn
+	s.routerID = routerID
+
+	// Restore the existing peers and prefixes.
+	for _, p := range s.peers {
+		err := s.addPeer(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	for key, p := range s.prefixes {
+		p.uuid, err = s.addPrefix(p)
+		if err != nil {
+			return err
+		}
+
+		s.prefixes[key] = p
+	}
+
+	return nil
+}
+
+// Stop stops the BGP speaker, the peers and prefixes are retained for a later start.
+func (s *Server) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stop()
+}
+
+func (s *Server) stop() error {
+	if s.bgp == nil {
+		return nil
+	}
+
+	err := s.bgp.StopBgp(context.Background(), &bgpAPI.StopBgpRequest{})
+	if err != nil {
+		return fmt.Errorf("Failed to stop BGP server: %v", err)
+	}
+
+	s.bgp.Stop()
+	s.bgp = nil
+	s.address = ""
+	s.asn = 0
+	s.routerID = nil
+
+	return nil
+}
+
+// AddPeer adds a new BGP peer. The same peer can be added multiple times (e.g. by multiple networks)
+// and is only removed once all of its users have removed it.
+func (s *Server) AddPeer(address net.IP, asn uint32, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.peers[address.String()]
+	if ok {
+		if p.asn != asn || p.password != password {
+			return fmt.Errorf("BGP peer %q is already configured with a different ASN or password", address.String())
+		}
+
+		p.count++
+		s.peers[address.String()] = p
+		return nil
+	}
+
+	p = peer{address: address, asn: asn, password: password, count: 1}
+	if s.bgp != nil {
+		err := s.addPeer(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.peers[address.String()] = p
+	return nil
+}
+
+func (s *Server) addPeer(p peer) error {
+	families := []*bgpAPI.AfiSafi{}
+	for _, afi := range []bgpAPI.Family_Afi{bgpAPI.Family_AFI_IP, bgpAPI.Family_AFI_IP6} {
+		families = append(families, &bgpAPI.AfiSafi{
+			Config: &bgpAPI.AfiSafiConfig{
+				Family:  &bgpAPI.Family{Afi: afi, Safi: bgpAPI.Family_SAFI_UNICAST},
+				Enabled: true,
+			},
+		})
+	}
+
+	err := s.bgp.AddPeer(context.Background(), &bgpAPI.AddPeerRequest{
+		Peer: &bgpAPI.Peer{
+			Conf: &bgpAPI.PeerConf{
+				NeighborAddress: p.address.String(),
+				PeerAs:          p.asn,
+				AuthPassword:    p.password,
+			},
+			AfiSafis: families,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to add BGP peer %q: %v", p.address.String(), err)
+	}
+
+	return nil
+}
+
+// RemovePeer removes a BGP peer once its last user is gone.
+func (s *Server) RemovePeer(address net.IP) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.peers[address.String()]
+	if !ok {
+		return nil
+	}
+
+	if p.count > 1 {
+		p.count--
+		s.peers[address.String()] = p
+		return nil
+	}
+
+	if s.bgp != nil {
+		err := s.bgp.DeletePeer(context.Background(), &bgpAPI.DeletePeerRequest{Address: address.String()})
+		if err != nil {
+			return fmt.Errorf("Failed to remove BGP peer %q: %v", address.String(), err)
+		}
+	}
+
+	delete(s.peers, address.String())
+	return nil
+}
+
+// AddPrefix announces a subnet reachable through the given next-hop. A nil next-hop means
+// the address used for the BGP session is used. The owner is used to later withdraw the prefix.
+func (s *Server) AddPrefix(subnet net.IPNet, nexthop net.IP, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := fmt.Sprintf("%s/%s", owner, subnet.String())
+	_, ok := s.prefixes[key]
+	if ok {
+		return nil
+	}
+
+	p := prefix{owner: owner, subnet: subnet, nexthop: nexthop}
+	if s.bgp != nil {
+		var err error
+		p.uuid, err = s.addPrefix(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.prefixes[key] = p
+	return nil
+}
+
+func (s *Server) addPrefix(p prefix) ([]byte, error) {
+	prefixLen, _ := p.subnet.Mask.Size()
+	nlri, err := ptypes.MarshalAny(&bgpAPI.IPAddressPrefix{
+		Prefix:    p.subnet.IP.String(),
+		PrefixLen: uint32(prefixLen),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	origin, err := ptypes.MarshalAny(&bgpAPI.OriginAttribute{Origin: 0})
+	if err != nil {
+		return nil, err
+	}
+
+	family := &bgpAPI.Family{Afi: bgpAPI.Family_AFI_IP, Safi: bgpAPI.Family_SAFI_UNICAST}
+	attrs := []*any.Any{origin}
+
+	if p.subnet.IP.To4() != nil {
+		nexthop := "0.0.0.0"
+		if p.nexthop != nil {
+			nexthop = p.nexthop.String()
+		}
+
+		attr, err := ptypes.MarshalAny(&bgpAPI.NextHopAttribute{NextHop: nexthop})
+		if err != nil {
+			return nil, err
+		}
+
+		attrs = append(attrs, attr)
+	} else {
+		family.Afi = bgpAPI.Family_AFI_IP6
+
+		nexthop := "::"
+		if p.nexthop != nil {
+			nexthop = p.nexthop.String()
+		}
+
+		attr, err := ptypes.MarshalAny(&bgpAPI.MpReachNLRIAttribute{
+			Family:   family,
+			NextHops: []string{nexthop},
+			Nlris:    []*any.Any{nlri},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		attrs = append(attrs, attr)
+	}
+
+	resp, err := s.bgp.AddPath(context.Background(), &bgpAPI.AddPathRequest{
+		Path: &bgpAPI.Path{
+			Family: family,
+			Nlri:   nlri,
+			Pattrs: attrs,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to announce BGP prefix %q: %v", p.subnet.String(), err)
+	}
+
+	return resp.Uuid, nil
+}
+
+// RemovePrefixByOwner withdraws all the prefixes announced by the given owner.
+func (s *Server) RemovePrefixByOwner(owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, p := range s.prefixes {
+		if p.owner != owner {
+			continue
+		}
+
+		if s.bgp != nil && p.uuid != nil {
+			err := s.bgp.DeletePath(context.Background(), &bgpAPI.DeletePathRequest{Uuid: p.uuid})
+			if err != nil {
+				return fmt.Errorf("Failed to withdraw BGP prefix %q: %v", p.subnet.String(), err)
+			}
+		}
+
+		delete(s.prefixes, key)
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
@@ -15,7 +16,11 @@ import (
 	lxd "github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/secrets"
+	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -52,10 +57,16 @@ func certificatesGet(d *Daemon, r *http.Request) response.Response {
 			return response.SmartError(err)
 		}
 		for _, baseCert := range baseCerts {
+			if !certificateVisible(d, r, baseCert.Fingerprint) {
+				continue
+			}
+
 			resp := api.Certificate{}
 			resp.Fingerprint = baseCert.Fingerprint
 			resp.Certificate = baseCert.Certificate
 			resp.Name = baseCert.Name
+			resp.Restricted = baseCert.Restricted
+			resp.Projects = baseCert.Projects
 			if baseCert.Type == 1 {
 				resp.Type = "client"
 			} else {
@@ -68,6 +79,10 @@ func certificatesGet(d *Daemon, r *http.Request) response.Response {
 
 	body := []string{}
 	for _, cert := range d.clientCerts {
+		if !certificateVisible(d, r, shared.CertFingerprint(&cert)) {
+			continue
+		}
+
 		fingerprint := fmt.Sprintf("/%s/certificates/%s", version.APIVersion, shared.CertFingerprint(&cert))
 		body = append(body, fingerprint)
 	}
@@ -75,8 +90,21 @@ func certificatesGet(d *Daemon, r *http.Request) response.Response {
 	return response.SyncResponse(true, body)
 }
 
+// certificateVisible returns whether the certificate with the given fingerprint may be seen by the
+// requestor. Restricted clients only get to see their own certificate.
+func certificateVisible(d *Daemon, r *http.Request, fingerprint string) bool {
+	restricted, _ := d.userRestricted(r)
+	if !restricted {
+		return true
+	}
+
+	username, _ := r.Context().Value("username").(string)
+	return username == fingerprint
+}
+
 func readSavedClientCAList(d *Daemon) {
 	d.clientCerts = map[string]x509.Certificate{}
+	d.clientCertProjects = map[string][]string{}
 
 	dbCerts, err := d.cluster.CertificatesGet()
 	if err != nil {
@@ -98,6 +126,10 @@ func readSavedClientCAList(d *Daemon) {
 		}
 
 		d.clientCerts[shared.CertFingerprint(cert)] = *cert
+
+		if dbCert.Restricted {
+			d.clientCertProjects[shared.CertFingerprint(cert)] = dbCert.Projects
+		}
 	}
 }
 
@@ -119,11 +151,27 @@ func certificatesPost(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
-	if (!trusted || (protocol == "candid" && !d.userIsAdmin(r))) && util.PasswordCheck(secret, req.Password) != nil {
-		if req.Password != "" {
-			logger.Warn("Bad trust password", log.Ctx{"url": r.URL.RequestURI(), "ip": r.RemoteAddr})
+	restricted, _ := d.userRestricted(r)
+	admin := trusted && !restricted && (protocol != "candid" || d.userIsAdmin(r))
+
+	// Handle requests for a certificate add token.
+	if shared.IsTrue(queryParam(r, "token")) {
+		if !admin {
+			return response.Forbidden(nil)
+		}
+
+		return certificateTokenCreate(d, req)
+	}
+
+	var tokenReq *api.CertificatePut
+	if !admin && util.PasswordCheck(secret, req.Password) != nil {
+		tokenReq = certificateTokenValid(d.State(), req.Password)
+		if tokenReq == nil {
+			if req.Password != "" {
+				logger.Warn("Bad trust password", log.Ctx{"url": r.URL.RequestURI(), "ip": r.RemoteAddr})
+			}
+			return response.Forbidden(nil)
 		}
-		return response.Forbidden(nil)
 	}
 
 	if req.Type != "client" {
@@ -160,6 +208,17 @@ func certificatesPost(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(fmt.Errorf("Can't use TLS data on non-TLS link"))
 	}
 
+	// Clients added through a token get the name and restrictions the token was issued for.
+	if tokenReq != nil {
+		name = tokenReq.Name
+		req.Restricted = tokenReq.Restricted
+		req.Projects = tokenReq.Projects
+	}
+
+	if !req.Restricted {
+		req.Projects = nil
+	}
+
 	fingerprint := shared.CertFingerprint(cert)
 
 	if d.clientCerts == nil {
@@ -186,6 +245,8 @@ func certificatesPost(d *Daemon, r *http.Request) response.Response {
 			Type:        1,
 			Name:        name,
 			Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+			Restricted:  req.Restricted,
+			Projects:    req.Projects,
 		}
 
 		err = d.cluster.CertSave(&dbCert)
@@ -199,14 +260,16 @@ func certificatesPost(d *Daemon, r *http.Request) response.Response {
 		if err != nil {
 			return response.SmartError(err)
 		}
-		req := api.CertificatesPost{
+		notifyReq := api.CertificatesPost{
 			Certificate: base64.StdEncoding.EncodeToString(cert.Raw),
 		}
-		req.Name = name
-		req.Type = "client"
+		notifyReq.Name = name
+		notifyReq.Type = "client"
+		notifyReq.Restricted = req.Restricted
+		notifyReq.Projects = req.Projects
 
 		err = notifier(func(client lxd.InstanceServer) error {
-			return client.CreateCertificate(req)
+			return client.CreateCertificate(notifyReq)
 		})
 		if err != nil {
 			return response.SmartError(err)
@@ -215,9 +278,152 @@ func certificatesPost(d *Daemon, r *http.Request) response.Response {
 
 	d.clientCerts[shared.CertFingerprint(cert)] = *cert
 
+	if d.clientCertProjects == nil {
+		d.clientCertProjects = map[string][]string{}
+	}
+
+	if req.Restricted {
+		d.clientCertProjects[shared.CertFingerprint(cert)] = req.Projects
+	}
+
 	return response.SyncResponseLocation(true, nil, fmt.Sprintf("/%s/certificates/%s", version.APIVersion, fingerprint))
 }
 
+// certificateTokenCreate creates a single-use token allowing a new client to add itself to the
+// trust store.
+func certificateTokenCreate(d *Daemon, req api.CertificatesPost) response.Response {
+	if req.Name == "" {
+		return response.BadRequest(fmt.Errorf("A client name must be provided"))
+	}
+
+	if req.Type != "client" {
+		return response.BadRequest(fmt.Errorf("Unknown request type %s", req.Type))
+	}
+
+	// Get the addresses the client can reach us on.
+	httpsAddresses, err := node.HTTPSAddresses(d.db)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	addresses := []string{}
+	for _, address := range httpsAddresses {
+		listenAddresses, err := util.ListenAddresses(address)
+		if err != nil {
+			return response.InternalError(err)
+		}
+
+		addresses = append(addresses, listenAddresses...)
+	}
+
+	if len(addresses) < 1 {
+		return response.BadRequest(fmt.Errorf("The server isn't listening on the network"))
+	}
+
+	fingerprint, err := shared.CertFingerprintStr(string(d.endpoints.NetworkPublicKey()))
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	secret, err := shared.RandomCryptoString()
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	token := api.CertificateAddToken{
+		ClientName:  req.Name,
+		Fingerprint: fingerprint,
+		Addresses:   addresses,
+		Secret:      secret,
+	}
+
+	if !req.Restricted {
+		req.Projects = nil
+	}
+
+	meta := shared.Jmap{}
+	meta["request"] = req.CertificatePut
+	meta["token"] = token.String()
+
+	// Revoking the token removes its secret.
+	s := d.State()
+	onCancel := func(op *operations.Operation) error {
+		return s.Secrets.Delete(certificateTokenSecretName(secret))
+	}
+
+	op, err := operations.OperationCreate(s, "", operations.OperationClassToken, db.OperationCertificateAddToken, nil, meta, nil, onCancel, nil)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	// Store the token in the secrets backend, where it can be checked by any cluster member
+	// sharing the backend.
+	entry, err := json.Marshal(certificateTokenEntry{Request: req.CertificatePut, Operation: op.ID()})
+	if err != nil {
+		op.Cancel()
+		return response.InternalError(err)
+	}
+
+	err = s.Secrets.Put(certificateTokenSecretName(secret), entry)
+	if err != nil {
+		op.Cancel()
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
+}
+
+// certificateTokenEntry is the content of the secret stored for a certificate add token.
+type certificateTokenEntry struct {
+	Request   api.CertificatePut `json:"request"`
+	Operation string             `json:"operation"`
+}
+
+// certificateTokenSecretName returns the name of the secret of a certificate add token. It's
+// derived from a hash of the token secret so that the secret itself isn't stored.
+func certificateTokenSecretName(secret string) string {
+	return fmt.Sprintf("certificate-tokens/%x", sha256.Sum256([]byte(secret)))
+}
+
+// certificateTokenValid returns the request of the certificate add token matching the given secret,
+// or nil if there is none. Tokens are single-use so a matching one is removed and its operation
+// cancelled if it runs on this member.
+func certificateTokenValid(s *state.State, secret string) *api.CertificatePut {
+	if secret == "" {
+		return nil
+	}
+
+	name := certificateTokenSecretName(secret)
+	content, err := s.Secrets.Get(name)
+	if err != nil {
+		if err != secrets.ErrNotFound {
+			logger.Warn("Failed to look up certificate add token", log.Ctx{"err": err})
+		}
+
+		return nil
+	}
+
+	entry := certificateTokenEntry{}
+	err = json.Unmarshal(content, &entry)
+	if err != nil {
+		logger.Warn("Invalid certificate add token", log.Ctx{"err": err})
+		return nil
+	}
+
+	err = s.Secrets.Delete(name)
+	if err != nil {
+		logger.Warn("Failed to remove certificate add token", log.Ctx{"err": err})
+		return nil
+	}
+
+	op, err := operations.OperationGetInternal(entry.Operation)
+	if err == nil && op.Status() == api.Running {
+		op.Cancel()
+	}
+
+	return &entry.Request
+}
+
 func certificateGet(d *Daemon, r *http.Request) response.Response {
 	fingerprint := mux.Vars(r)["fingerprint"]
 
@@ -226,6 +432,10 @@ func certificateGet(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	if !certificateVisible(d, r, cert.Fingerprint) {
+		return response.NotFound(nil)
+	}
+
 	return response.SyncResponseETag(true, cert, cert)
 }
 
@@ -240,6 +450,8 @@ func doCertificateGet(db *db.Cluster, fingerprint string) (api.Certificate, erro
 	resp.Fingerprint = dbCertInfo.Fingerprint
 	resp.Certificate = dbCertInfo.Certificate
 	resp.Name = dbCertInfo.Name
+	resp.Restricted = dbCertInfo.Restricted
+	resp.Projects = dbCertInfo.Projects
 	if dbCertInfo.Type == 1 {
 		resp.Type = "client"
 	} else {
@@ -268,7 +480,7 @@ func certificatePut(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
-	return doCertificateUpdate(d, fingerprint, req)
+	return doCertificateUpdate(d, r, fingerprint, req)
 }
 
 func certificatePatch(d *Daemon, r *http.Request) response.Response {
@@ -303,15 +515,65 @@ func certificatePatch(d *Daemon, r *http.Request) response.Response {
 		req.Type = value
 	}
 
-	return doCertificateUpdate(d, fingerprint, req.Writable())
+	// Get restricted
+	restricted, err := reqRaw.GetBool("restricted")
+	if err == nil {
+		req.Restricted = restricted
+	}
+
+	// Get projects
+	_, ok := reqRaw["projects"]
+	if ok {
+		projects, ok := reqRaw["projects"].([]interface{})
+		if !ok {
+			return response.BadRequest(fmt.Errorf("Invalid projects list"))
+		}
+
+		req.Projects = []string{}
+		for _, project := range projects {
+			name, ok := project.(string)
+			if !ok {
+				return response.BadRequest(fmt.Errorf("Invalid projects list"))
+			}
+
+			req.Projects = append(req.Projects, name)
+		}
+	}
+
+	return doCertificateUpdate(d, r, fingerprint, req.Writable())
 }
 
-func doCertificateUpdate(d *Daemon, fingerprint string, req api.CertificatePut) response.Response {
+func doCertificateUpdate(d *Daemon, r *http.Request, fingerprint string, req api.CertificatePut) response.Response {
+	// Other nodes only need to refresh their cache.
+	if isClusterNotification(r) {
+		readSavedClientCAList(d)
+		return response.EmptySyncResponse
+	}
+
 	if req.Type != "client" {
 		return response.BadRequest(fmt.Errorf("Unknown request type %s", req.Type))
 	}
 
-	err := d.cluster.CertUpdate(fingerprint, req.Name, 1)
+	if !req.Restricted {
+		req.Projects = nil
+	}
+
+	err := d.cluster.CertUpdate(fingerprint, req.Name, 1, req.Restricted, req.Projects)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	readSavedClientCAList(d)
+
+	// Notify other nodes so that the new restrictions apply cluster-wide.
+	notifier, err := cluster.NewNotifier(d.State(), d.endpoints.NetworkCert(), cluster.NotifyAlive)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = notifier(func(client lxd.InstanceServer) error {
+		return client.UpdateCertificate(fingerprint, req, "")
+	})
 	if err != nil {
 		return response.SmartError(err)
 	}
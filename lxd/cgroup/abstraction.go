@@ -2,6 +2,8 @@ package cgroup
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 // CGroup represents the main cgroup abstraction.
@@ -144,16 +146,65 @@ func (cg *CGroup) SetMemorySwapMax(max string) error {
 	return ErrUnknownVersion
 }
 
+// SetMemorySwapLimit sets the swap limit matching the memory limit, depending on whether swap may
+// be used. Swap is accounted along with memory on cgroup1, so memory plus swap is then capped to the
+// memory limit, swap being turned off through swappiness otherwise. It's accounted on its own on
+// cgroup2, where it's left unlimited when allowed and disabled otherwise.
+func (cg *CGroup) SetMemorySwapLimit(memory string, allowed bool) error {
+	version := cgControllers["memory"]
+	switch version {
+	case Unavailable:
+		return ErrControllerMissing
+	case V1:
+		if !allowed {
+			return nil
+		}
+
+		return cg.SetMemorySwapMax(memory)
+	case V2:
+		if !allowed {
+			return cg.SetMemorySwapMax("0")
+		}
+
+		return cg.SetMemorySwapMax("-1")
+	}
+	return ErrUnknownVersion
+}
+
 // GetCPUAcctUsage returns the total CPU time in ns used by processes
 func (cg *CGroup) GetCPUAcctUsage() (string, error) {
 	version := cgControllers["cpuacct"]
+	if version == Unavailable {
+		version = cgControllers["cpu"]
+	}
+
 	switch version {
 	case Unavailable:
 		return "", ErrControllerMissing
 	case V1:
 		return cg.rw.Get(version, "cpuacct", "cpuacct.usage")
 	case V2:
-		return "", ErrControllerMissing
+		stats, err := cg.rw.Get(version, "cpu", "cpu.stat")
+		if err != nil {
+			return "", err
+		}
+
+		// The usage is reported in usec by cpu.stat.
+		for _, line := range strings.Split(stats, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 || fields[0] != "usage_usec" {
+				continue
+			}
+
+			usage, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return "", err
+			}
+
+			return fmt.Sprintf("%d", usage*1000), nil
+		}
+
+		return "", fmt.Errorf("No CPU usage in cpu.stat")
 	}
 	return "", ErrUnknownVersion
 }
@@ -233,13 +284,35 @@ func (cg *CGroup) GetMemorySwapUsage() (string, error) {
 func (cg *CGroup) GetBlkioWeight() (string, error) {
 	// Confirm we have the controller
 	version := cgControllers["blkio"]
+	if version == Unavailable {
+		version = cgControllers["io"]
+	}
+
 	switch version {
 	case Unavailable:
 		return "", ErrControllerMissing
 	case V1:
 		return cg.rw.Get(version, "blkio", "blkio.weight")
 	case V2:
-		return "", ErrControllerMissing
+		value, err := cg.rw.Get(version, "io", "io.weight")
+		if err != nil {
+			return "", err
+		}
+
+		// Only the default weight is of interest, per device ones aren't set by LXD.
+		for _, line := range strings.Split(value, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "default" {
+				weight, err := strconv.Atoi(fields[1])
+				if err != nil {
+					return "", err
+				}
+
+				return fmt.Sprintf("%d", IOWeightToBlkioWeight(weight)), nil
+			}
+		}
+
+		return "", fmt.Errorf("No default weight in io.weight")
 	}
 	return "", ErrUnknownVersion
 }
@@ -247,13 +320,22 @@ func (cg *CGroup) GetBlkioWeight() (string, error) {
 // SetBlkioWeight set the currently allowed range of weights
 func (cg *CGroup) SetBlkioWeight(value string) error {
 	version := cgControllers["blkio"]
+	if version == Unavailable {
+		version = cgControllers["io"]
+	}
+
 	switch version {
 	case Unavailable:
 		return ErrControllerMissing
 	case V1:
 		return cg.rw.Set(version, "blkio", "blkio.weight", value)
 	case V2:
-		return ErrControllerMissing
+		weight, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+
+		return cg.rw.Set(version, "io", "io.weight", fmt.Sprintf("default %d", BlkioWeightToIOWeight(weight)))
 	}
 	return ErrUnknownVersion
 
@@ -269,52 +351,96 @@ func (cg *CGroup) SetCPUShare(value string) error {
 	case V1:
 		return cg.rw.Set(version, "cpu", "cpu.shares", value)
 	case V2:
-		return ErrControllerMissing
+		shares, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		return cg.rw.Set(version, "cpu", "cpu.weight", fmt.Sprintf("%d", cpuSharesToWeight(shares)))
 	}
 	return ErrUnknownVersion
 }
 
-// SetCPUCfsPeriod sets the duration in ms for each scheduling period
-func (cg *CGroup) SetCPUCfsPeriod(value string) error {
+// SetCPUCfsLimit sets the max time in us that the group can run for during each period of the
+// given duration in us, a quota of -1 meaning no limit
+func (cg *CGroup) SetCPUCfsLimit(quota string, period string) error {
 	//Confirm we have the controller
 	version := cgControllers["cpu"]
 	switch version {
 	case Unavailable:
 		return ErrControllerMissing
 	case V1:
-		return cg.rw.Set(version, "cpu", "cpu.cfs_period_us", value)
+		err := cg.rw.Set(version, "cpu", "cpu.cfs_period_us", period)
+		if err != nil {
+			return err
+		}
+
+		return cg.rw.Set(version, "cpu", "cpu.cfs_quota_us", quota)
 	case V2:
-		return ErrControllerMissing
+		// Both are set at once through cpu.max.
+		if quota == "-1" {
+			quota = "max"
+		}
+
+		return cg.rw.Set(version, "cpu", "cpu.max", fmt.Sprintf("%s %s", quota, period))
 	}
 	return ErrUnknownVersion
 }
 
-// SetCPUCfsQuota sets the max time in ms during each cfs_period_us that
-// the current group can run for
-func (cg *CGroup) SetCPUCfsQuota(value string) error {
-	//Confirm we have the controller
-	version := cgControllers["cpu"]
+// SetNetIfPrio sets the priority for the process
+func (cg *CGroup) SetNetIfPrio(value string) error {
+	version := cgControllers["net_prio"]
 	switch version {
 	case Unavailable:
 		return ErrControllerMissing
 	case V1:
-		return cg.rw.Set(version, "cpu", "cpu.cfs_quota_us", value)
+		return cg.rw.Set(version, "net_prio", "net_prio.ifpriomap", value)
 	case V2:
 		return ErrControllerMissing
 	}
 	return ErrUnknownVersion
 }
 
-// SetNetIfPrio sets the priority for the process
-func (cg *CGroup) SetNetIfPrio(value string) error {
-	version := cgControllers["net_prio"]
+// SetCPUSetMems sets the NUMA nodes the processes may allocate memory from
+func (cg *CGroup) SetCPUSetMems(value string) error {
+	version := cgControllers["cpuset"]
 	switch version {
 	case Unavailable:
 		return ErrControllerMissing
 	case V1:
-		return cg.rw.Set(version, "net_prio", "net_prio.ifpriomap", value)
+		fallthrough
 	case V2:
-		return ErrControllerMissing
+		return cg.rw.Set(version, "cpuset", "cpuset.mems", value)
 	}
 	return ErrUnknownVersion
 }
+
+// cpuSharesToWeight converts cgroup1 CPU shares (1024 by default) to a cgroup2 CPU weight (1 to
+// 10000, 100 by default).
+func cpuSharesToWeight(shares int64) int64 {
+	return cgClamp(shares*100/1024, 1, 10000)
+}
+
+// BlkioWeightToIOWeight converts a cgroup1 blkio weight (10 to 1000, 500 by default) to a cgroup2
+// IO weight (1 to 10000, 100 by default).
+func BlkioWeightToIOWeight(weight int) int {
+	return int(cgClamp(int64(weight)*100/500, 1, 10000))
+}
+
+// IOWeightToBlkioWeight converts a cgroup2 IO weight to a cgroup1 blkio weight.
+func IOWeightToBlkioWeight(weight int) int {
+	return int(cgClamp(int64(weight)*500/100, 10, 1000))
+}
+
+// cgClamp returns value bounded to the [min, max] range.
+func cgClamp(value int64, min int64, max int64) int64 {
+	if value < min {
+		return min
+	}
+
+	if value > max {
+		return max
+	}
+
+	return value
+}
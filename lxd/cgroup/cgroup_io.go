@@ -0,0 +1,122 @@
+package cgroup
+
+import (
+	"strconv"
+	"strings"
+)
+
+// IOStats represents the IO counters of a cgroup for a block device.
+type IOStats struct {
+	ReadBytes  uint64
+	ReadOps    uint64
+	WriteBytes uint64
+	WriteOps   uint64
+}
+
+// GetIOStats returns the IO counters of the cgroup, keyed by the "major:minor" number of the block
+// devices the IO was done on.
+func (cg *CGroup) GetIOStats() (map[string]*IOStats, error) {
+	version := cgControllers["blkio"]
+	if version == Unavailable {
+		version = cgControllers["io"]
+	}
+
+	switch version {
+	case Unavailable:
+		return nil, ErrControllerMissing
+	case V1:
+		stats := map[string]*IOStats{}
+
+		bytes, err := cg.rw.Get(version, "blkio", "blkio.throttle.io_service_bytes")
+		if err != nil {
+			return nil, err
+		}
+
+		parseIOStatsV1(stats, bytes, func(s *IOStats) (*uint64, *uint64) { return &s.ReadBytes, &s.WriteBytes })
+
+		ops, err := cg.rw.Get(version, "blkio", "blkio.throttle.io_serviced")
+		if err != nil {
+			return nil, err
+		}
+
+		parseIOStatsV1(stats, ops, func(s *IOStats) (*uint64, *uint64) { return &s.ReadOps, &s.WriteOps })
+
+		return stats, nil
+	case V2:
+		value, err := cg.rw.Get(version, "io", "io.stat")
+		if err != nil {
+			return nil, err
+		}
+
+		return parseIOStatsV2(value), nil
+	}
+
+	return nil, ErrUnknownVersion
+}
+
+// parseIOStatsV1 parses blkio throttle files made of "<major>:<minor> <operation> <value>" lines
+// and stores the read and write values in the counters returned by fields.
+func parseIOStatsV1(stats map[string]*IOStats, value string, fields func(s *IOStats) (*uint64, *uint64)) {
+	for _, line := range strings.Split(value, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) != 3 {
+			continue // Skips the "Total" line.
+		}
+
+		n, err := strconv.ParseUint(parts[2], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if stats[parts[0]] == nil {
+			stats[parts[0]] = &IOStats{}
+		}
+
+		read, write := fields(stats[parts[0]])
+		switch parts[1] {
+		case "Read":
+			*read = n
+		case "Write":
+			*write = n
+		}
+	}
+}
+
+// parseIOStatsV2 parses io.stat, made of "<major>:<minor> rbytes=<n> wbytes=<n> rios=<n> ..." lines.
+func parseIOStatsV2(value string) map[string]*IOStats {
+	stats := map[string]*IOStats{}
+	for _, line := range strings.Split(value, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			continue
+		}
+
+		s := &IOStats{}
+		for _, part := range parts[1:] {
+			fields := strings.SplitN(part, "=", 2)
+			if len(fields) != 2 {
+				continue
+			}
+
+			n, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				continue
+			}
+
+			switch fields[0] {
+			case "rbytes":
+				s.ReadBytes = n
+			case "wbytes":
+				s.WriteBytes = n
+			case "rios":
+				s.ReadOps = n
+			case "wios":
+				s.WriteOps = n
+			}
+		}
+
+		stats[parts[0]] = s
+	}
+
+	return stats
+}
@@ -126,6 +126,11 @@ func (info *Info) SupportsVersion(resource Resource) (Backend, bool) {
 			return V1, ok
 		}
 
+		val, ok = cgControllers["io"]
+		if ok && val == V2 {
+			return V2, ok
+		}
+
 		return Unavailable, false
 	case BlkioWeight:
 		val, ok := cgControllers["blkio.weight"]
@@ -133,28 +138,31 @@ func (info *Info) SupportsVersion(resource Resource) (Backend, bool) {
 			return V1, ok
 		}
 
-		return Unavailable, false
-	case CPU:
-		val, ok := cgControllers["cpu"]
-		if ok && val == V1 {
-			return V1, ok
+		val, ok = cgControllers["io.weight"]
+		if ok && val == V2 {
+			return V2, ok
 		}
 
 		return Unavailable, false
+	case CPU:
+		val, ok := cgControllers["cpu"]
+		return val, ok
 	case CPUAcct:
 		val, ok := cgControllers["cpuacct"]
 		if ok && val == V1 {
 			return V1, ok
 		}
 
-		return Unavailable, false
-	case CPUSet:
-		val, ok := cgControllers["cpuset"]
-		if ok && val == V1 {
-			return V1, ok
+		// The CPU usage is part of the cpu controller with cgroup2.
+		val, ok = cgControllers["cpu"]
+		if ok && val == V2 {
+			return V2, ok
 		}
 
 		return Unavailable, false
+	case CPUSet:
+		val, ok := cgControllers["cpuset"]
+		return val, ok
 	case Devices:
 		val, ok := cgControllers["devices"]
 		return val, ok
@@ -214,16 +222,28 @@ func (info *Info) SupportsVersion(resource Resource) (Backend, bool) {
 		return Unavailable, false
 	case Pids:
 		val, ok := cgControllers["pids"]
-		if ok && val == V1 {
-			return V1, ok
-		}
-
-		return Unavailable, false
+		return val, ok
 	}
 
 	return Unavailable, false
 }
 
+// KeyVersion returns the cgroup hierarchy of the controller of a cgroup key (e.g. "io.max"). On a
+// pure cgroup2 layout, all keys are handled through cgroup2, including those of controllers which
+// aren't listed in cgroup.controllers such as devices.
+func (info *Info) KeyVersion(key string) Backend {
+	if info.Layout == CgroupsUnified {
+		return V2
+	}
+
+	val, ok := cgControllers[strings.SplitN(key, ".", 2)[0]]
+	if !ok {
+		return V1
+	}
+
+	return val
+}
+
 // Supports indicates whether or not a given resource is controllable.
 func (info *Info) Supports(resource Resource, cgroup *CGroup) bool {
 	val, ok := info.SupportsVersion(resource)
@@ -305,6 +325,7 @@ func init() {
 
 	hasV1 := false
 	hasV2 := false
+	unifiedPath := ""
 	// Go through the file line by line.
 	scanSelfCg := bufio.NewScanner(selfCg)
 	for scanSelfCg.Scan() {
@@ -344,12 +365,17 @@ func init() {
 		if err == nil {
 			// Record the fact that V2 is present at all.
 			cgControllers["unified"] = V2
+			unifiedPath = filepath.Dir(controllers.Name())
 
+			// The controllers are listed on a single space separated line.
 			scanControllers := bufio.NewScanner(controllers)
 			for scanControllers.Scan() {
-				line := strings.TrimSpace(scanSelfCg.Text())
-				cgControllers[line] = V2
+				for _, controller := range strings.Fields(scanControllers.Text()) {
+					cgControllers[controller] = V2
+				}
 			}
+
+			controllers.Close()
 			hasV2 = true
 		}
 	}
@@ -362,20 +388,26 @@ func init() {
 
 	val, ok = cgControllers["memory"]
 	if ok && val == V1 && shared.PathExists("/sys/fs/cgroup/memory/memory.memsw.limit_in_bytes") {
-		cgControllers["memory.memsw.limit_in_bytes"] = V2
+		cgControllers["memory.memsw.limit_in_bytes"] = V1
 	}
 
+	// Check for additional unified cgroup features
 	val, ok = cgControllers["memory"]
 	if ok && val == V2 {
-		if shared.PathExists("/sys/fs/cgroup/memory/memory.swap.max") {
+		if cgV2HasKey(unifiedPath, "memory.swap.max") {
 			cgControllers["memory.swap.max"] = V2
 		}
 
-		if shared.PathExists("/sys/fs/cgroup/memory/memory.swap.current") {
+		if cgV2HasKey(unifiedPath, "memory.swap.current") {
 			cgControllers["memory.swap.current"] = V2
 		}
 	}
 
+	val, ok = cgControllers["io"]
+	if ok && val == V2 && cgV2HasKey(unifiedPath, "io.weight") {
+		cgControllers["io.weight"] = V2
+	}
+
 	if hasV1 && hasV2 {
 		cgLayout = CgroupsHybrid
 	} else if hasV1 {
@@ -384,3 +416,18 @@ func init() {
 		cgLayout = CgroupsUnified
 	}
 }
+
+// cgV2HasKey checks whether a cgroup2 key exists in the cgroup at path or, as the root cgroup lacks
+// most keys, in one of its children.
+func cgV2HasKey(path string, key string) bool {
+	if path == "" {
+		return false
+	}
+
+	if shared.PathExists(filepath.Join(path, key)) {
+		return true
+	}
+
+	matches, err := filepath.Glob(filepath.Join(path, "*", key))
+	return err == nil && len(matches) > 0
+}
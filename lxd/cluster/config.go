@@ -7,12 +7,16 @@ import (
 	"io"
 	"os/exec"
 	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/scrypt"
 
 	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/endpoints"
+	"github.com/lxc/lxd/lxd/secrets"
+	"github.com/lxc/lxd/shared"
 	"github.com/pkg/errors"
 )
 
@@ -59,6 +63,18 @@ func (c *Config) HTTPSAllowedCredentials() bool {
 	return c.m.GetBool("core.https_allowed_credentials")
 }
 
+// HTTPSTrustedProxy returns the addresses and subnets of the trusted reverse proxies.
+func (c *Config) HTTPSTrustedProxy() string {
+	return c.m.GetString("core.https_trusted_proxy")
+}
+
+// AuditRetention returns how long entries are kept in the audit log, zero
+// meaning forever.
+func (c *Config) AuditRetention() time.Duration {
+	n := c.m.GetInt64("core.audit_retention")
+	return time.Duration(n) * 24 * time.Hour
+}
+
 // TrustPassword returns the LXD trust password for authenticating clients.
 func (c *Config) TrustPassword() string {
 	return c.m.GetString("core.trust_password")
@@ -95,6 +111,12 @@ func (c *Config) RemoteCacheExpiry() int64 {
 	return c.m.GetInt64("images.remote_cache_expiry")
 }
 
+// RemoteCachePinned returns the cached remote images which never expire, as a list of
+// "<alias or fingerprint>[@<server>]" entries.
+func (c *Config) RemoteCachePinned() []string {
+	return remoteCachePinnedEntries(c.m.GetString("images.remote_cache_pinned"))
+}
+
 // ProxyHTTPS returns the configured HTTPS proxy, if any.
 func (c *Config) ProxyHTTPS() string {
 	return c.m.GetString("core.proxy_https")
@@ -117,6 +139,21 @@ func (c *Config) MAASController() (string, string) {
 	return url, key
 }
 
+// SecretsBackend returns the type of the secrets backend along with the Vault address, token, KV
+// mount and path to use with the vault backend.
+func (c *Config) SecretsBackend() (string, string, string, string, string) {
+	return c.m.GetString("secrets.backend"),
+		c.m.GetString("secrets.vault.address"),
+		c.m.GetString("secrets.vault.token"),
+		c.m.GetString("secrets.vault.mount"),
+		c.m.GetString("secrets.vault.path")
+}
+
+// BGPASN returns the ASN the BGP server of each member uses.
+func (c *Config) BGPASN() int64 {
+	return c.m.GetInt64("core.bgp_asn")
+}
+
 // OfflineThreshold returns the configured heartbeat threshold, i.e. the
 // number of seconds before after which an unresponsive node is considered
 // offline..
@@ -130,6 +167,13 @@ func (c *Config) ImagesMinimalReplica() int64 {
 	return c.m.GetInt64("cluster.images_minimal_replica")
 }
 
+// UpgradeMode returns how out-of-date members are upgraded, either "auto" to
+// run LXD_CLUSTER_UPDATE as soon as they notice or "manual" to wait for an
+// explicit request.
+func (c *Config) UpgradeMode() string {
+	return c.m.GetString("cluster.upgrade_mode")
+}
+
 // Dump current configuration keys and their values. Keys with values matching
 // their defaults are omitted.
 func (c *Config) Dump() map[string]interface{} {
@@ -222,10 +266,14 @@ var ConfigSchema = config.Schema{
 	"backups.compression_algorithm":  {Default: "gzip", Validator: validateCompression},
 	"cluster.offline_threshold":      {Type: config.Int64, Default: offlineThresholdDefault(), Validator: offlineThresholdValidator},
 	"cluster.images_minimal_replica": {Type: config.Int64, Default: "3", Validator: imageMinimalReplicaValidator},
+	"cluster.upgrade_mode":           {Default: "auto", Validator: validateUpgradeMode},
+	"core.audit_retention":           {Type: config.Int64, Default: "30"},
+	"core.bgp_asn":                   {Type: config.Int64, Default: "0"},
 	"core.https_allowed_headers":     {},
 	"core.https_allowed_methods":     {},
 	"core.https_allowed_origin":      {},
 	"core.https_allowed_credentials": {Type: config.Bool},
+	"core.https_trusted_proxy":       {Validator: validateTrustedProxy},
 	"core.proxy_http":                {},
 	"core.proxy_https":               {},
 	"core.proxy_ignore_hosts":        {},
@@ -237,7 +285,9 @@ var ConfigSchema = config.Schema{
 	"images.auto_update_cached":      {Type: config.Bool, Default: "true"},
 	"images.auto_update_interval":    {Type: config.Int64, Default: "6"},
 	"images.compression_algorithm":   {Default: "gzip", Validator: validateCompression},
+	"images.mirror":                  {Type: config.Bool, Default: "false"},
 	"images.remote_cache_expiry":     {Type: config.Int64, Default: "10"},
+	"images.remote_cache_pinned":     {Validator: validateRemoteCachePinned},
 	"maas.api.key":                   {},
 	"maas.api.url":                   {},
 	"rbac.agent.url":                 {},
@@ -248,6 +298,13 @@ var ConfigSchema = config.Schema{
 	"rbac.api.key":                   {},
 	"rbac.api.url":                   {},
 	"rbac.expiry":                    {Type: config.Int64, Default: "3600"},
+	"secrets.backend":                {Default: secrets.TypeFile, Validator: validateSecretsBackend},
+	"secrets.vault.address":          {},
+	"secrets.vault.token":            {Hidden: true},
+	"secrets.vault.mount":            {Default: "secret"},
+	"secrets.vault.path":             {Default: "lxd"},
+	"tasks.backup_targets":           {},
+	"tasks.exec_whitelist":           {},
 
 	// Keys deprecated since the implementation of the storage api.
 	"storage.lvm_fstype":           {Setter: deprecatedStorage, Default: "ext4"},
@@ -260,6 +317,11 @@ var ConfigSchema = config.Schema{
 	"storage.zfs_use_refquota":     {Setter: deprecatedStorage, Type: config.Bool},
 }
 
+func validateTrustedProxy(value string) error {
+	_, err := endpoints.ParseTrustedProxies(value)
+	return err
+}
+
 func offlineThresholdDefault() string {
 	return strconv.Itoa(db.DefaultOfflineThreshold)
 }
@@ -316,6 +378,14 @@ func passwordSetter(value string) (string, error) {
 	return value, nil
 }
 
+func validateUpgradeMode(value string) error {
+	return shared.IsOneOf(value, []string{"auto", "manual"})
+}
+
+func validateSecretsBackend(value string) error {
+	return shared.IsOneOf(value, secrets.Types)
+}
+
 func validateCompression(value string) error {
 	if value == "none" {
 		return nil
@@ -330,6 +400,29 @@ func validateCompression(value string) error {
 	return err
 }
 
+func validateRemoteCachePinned(value string) error {
+	for _, entry := range remoteCachePinnedEntries(value) {
+		if strings.HasPrefix(entry, "@") || strings.HasSuffix(entry, "@") {
+			return fmt.Errorf("Invalid pinned image %q, must be an alias or fingerprint optionally followed by @<server>", entry)
+		}
+	}
+
+	return nil
+}
+
+// remoteCachePinnedEntries splits a comma separated list of pinned images.
+func remoteCachePinnedEntries(value string) []string {
+	entries := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
 func deprecatedStorage(value string) (string, error) {
 	if value == "" {
 		return "", nil
@@ -106,7 +106,7 @@ func ConnectIfVolumeIsRemote(cluster *db.Cluster, poolID int64, volumeName strin
 			return nil, err
 		}
 
-		if driver == "ceph" || driver == "cephfs" {
+		if driver == "ceph" || driver == "cephfs" || driver == "iscsi" {
 			return nil, nil
 		}
 
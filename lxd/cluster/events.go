@@ -14,13 +14,26 @@ import (
 	"github.com/lxc/lxd/shared/logger"
 )
 
-var listeners = map[string]*lxd.EventListener{}
+// eventsListener is an event listener connected to a cluster member.
+type eventsListener struct {
+	*lxd.EventListener
+
+	// Whether the listener gets all the cluster events relayed by an event
+	// hub, rather than only the local events of the member.
+	hub bool
+}
+
+var listeners = map[string]*eventsListener{}
 var listenersLock sync.Mutex
 
 // Events starts a task that continuously monitors the list of cluster nodes and
 // maintains a pool of websocket connections against all of them, in order to
 // get notified about events.
 //
+// When some members have the event-hub role, the other members only connect to
+// one of the hubs, which relays the events of the whole cluster, and fail over
+// to another hub if the connection is lost.
+//
 // Whenever an event is received the given callback is invoked.
 func Events(endpoints *endpoints.Endpoints, cluster *db.Cluster, f func(int64, api.Event)) (task.Func, task.Schedule) {
 	// Update our pool of event listeners. Since database queries are
@@ -73,48 +86,76 @@ func eventsUpdateListeners(endpoints *endpoints.Endpoints, cluster *db.Cluster,
 
 	address := endpoints.NetworkAddress()
 
-	addresses := make([]string, len(nodes))
-	for i, node := range nodes {
-		addresses[i] = node.Address
-
-		// Don't bother trying to connect to offline nodes, or to ourselves.
-		if node.IsOffline(offlineThreshold) || node.Address == address {
+	// Check whether we're an event hub and which other hubs are online.
+	localName := ""
+	isHub := false
+	hubs := []db.NodeInfo{}
+	for _, node := range nodes {
+		hasHubRole := shared.StringInSlice(string(db.ClusterRoleEventHub), node.Roles)
+		if node.Address == address {
+			localName = node.Name
+			isHub = hasHubRole
 			continue
 		}
 
-		listenersLock.Lock()
-		listener, ok := listeners[node.Address]
+		if hasHubRole && !node.IsOffline(offlineThreshold) {
+			hubs = append(hubs, node)
+		}
+	}
 
-		// The node has already a listener associated to it.
-		if ok {
-			// Double check that the listener is still
-			// connected. If it is, just move on, other
-			// we'll try to connect again.
-			if listeners[node.Address].IsActive() {
-				listenersLock.Unlock()
+	// Addresses of the listeners to keep.
+	keep := []string{}
+
+	if !isHub && len(hubs) > 0 {
+		hub := eventsUpdateHubListener(hubs, localName, endpoints.NetworkCert(), f)
+		if hub != "" {
+			keep = append(keep, hub)
+		}
+	} else {
+		for _, node := range nodes {
+			// Don't bother trying to connect to offline nodes, or to ourselves.
+			if node.IsOffline(offlineThreshold) || node.Address == address {
 				continue
 			}
 
-			delete(listeners, node.Address)
-		}
-		listenersLock.Unlock()
+			keep = append(keep, node.Address)
 
-		listener, err := eventsConnect(node.Address, endpoints.NetworkCert())
-		if err != nil {
-			logger.Warnf("Failed to get events from node %s: %v", node.Address, err)
-			continue
-		}
-		logger.Debugf("Listening for events on node %s", node.Address)
-		listener.AddHandler(nil, func(event api.Event) { f(node.ID, event) })
+			listenersLock.Lock()
+			listener, ok := listeners[node.Address]
 
-		listenersLock.Lock()
-		listeners[node.Address] = listener
-		listenersLock.Unlock()
+			// The node has already a listener associated to it.
+			if ok {
+				// Double check that the listener is still
+				// connected. If it is, just move on, other
+				// we'll try to connect again.
+				if listener.IsActive() && !listener.hub {
+					listenersLock.Unlock()
+					continue
+				}
+
+				listener.Disconnect()
+				delete(listeners, node.Address)
+			}
+			listenersLock.Unlock()
+
+			nodeListener, err := eventsConnect(node.Address, endpoints.NetworkCert(), false)
+			if err != nil {
+				logger.Warnf("Failed to get events from node %s: %v", node.Address, err)
+				continue
+			}
+			logger.Debugf("Listening for events on node %s", node.Address)
+			nodeID := node.ID
+			nodeListener.AddHandler(nil, func(event api.Event) { f(nodeID, event) })
+
+			listenersLock.Lock()
+			listeners[node.Address] = &eventsListener{EventListener: nodeListener}
+			listenersLock.Unlock()
+		}
 	}
 
 	listenersLock.Lock()
 	for address, listener := range listeners {
-		if !shared.StringInSlice(address, addresses) {
+		if !shared.StringInSlice(address, keep) {
 			listener.Disconnect()
 			delete(listeners, address)
 		}
@@ -122,9 +163,57 @@ func eventsUpdateListeners(endpoints *endpoints.Endpoints, cluster *db.Cluster,
 	listenersLock.Unlock()
 }
 
-// Establish a client connection to get events from the given node.
-func eventsConnect(address string, cert *shared.CertInfo) (*lxd.EventListener, error) {
-	client, err := Connect(address, cert, true)
+// eventsUpdateHubListener makes sure we're connected to one of the given event
+// hubs, keeping the current connection if still active and otherwise failing
+// over to the first hub we can connect to. Returns the hub address.
+func eventsUpdateHubListener(hubs []db.NodeInfo, localName string, cert *shared.CertInfo, f func(int64, api.Event)) string {
+	listenersLock.Lock()
+	for _, hub := range hubs {
+		listener, ok := listeners[hub.Address]
+		if ok && listener.hub && listener.IsActive() {
+			listenersLock.Unlock()
+			return hub.Address
+		}
+	}
+	listenersLock.Unlock()
+
+	for _, hub := range hubs {
+		hubListener, err := eventsConnect(hub.Address, cert, true)
+		if err != nil {
+			logger.Warnf("Failed to get events from event hub %s: %v", hub.Address, err)
+			continue
+		}
+		logger.Debugf("Listening for events on event hub %s", hub.Address)
+
+		hubID := hub.ID
+		hubListener.AddHandler(nil, func(event api.Event) {
+			// The hub relays our own events back to us.
+			if event.Location == localName {
+				return
+			}
+
+			f(hubID, event)
+		})
+
+		listenersLock.Lock()
+		old, ok := listeners[hub.Address]
+		if ok {
+			old.Disconnect()
+		}
+		listeners[hub.Address] = &eventsListener{EventListener: hubListener, hub: true}
+		listenersLock.Unlock()
+
+		return hub.Address
+	}
+
+	return ""
+}
+
+// Establish a client connection to get events from the given node. When
+// connecting to an event hub, the connection isn't marked as a cluster
+// notification so that the hub relays the events of the other members too.
+func eventsConnect(address string, cert *shared.CertInfo, hub bool) (*lxd.EventListener, error) {
+	client, err := Connect(address, cert, !hub)
 	if err != nil {
 		return nil, err
 	}
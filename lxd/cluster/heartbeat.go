@@ -25,6 +25,7 @@ type APIHeartbeatMember struct {
 	Raft          bool      // Deprecated, use non-zero RaftID instead to indicate raft node.
 	LastHeartbeat time.Time // Last time we received a successful response from node.
 	Online        bool      // Calculated from offline threshold and LastHeatbeat time.
+	Roles         []string  // Cluster roles assigned to the node.
 	updated       bool      // Has node been updated during this heartbeat run. Not sent to nodes.
 }
 
@@ -74,6 +75,7 @@ func (hbState *APIHeartbeat) Update(fullStateList bool, raftNodes []db.RaftNode,
 			Address:       node.Address,
 			LastHeartbeat: node.Heartbeat,
 			Online:        !node.Heartbeat.Before(time.Now().Add(-offlineThreshold)),
+			Roles:         node.Roles,
 		}
 
 		if raftNode, exists := raftNodeMap[member.Address]; exists {
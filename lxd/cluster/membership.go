@@ -397,7 +397,7 @@ func Join(state *state.State, gateway *Gateway, cert *shared.CertInfo, name stri
 				return errors.Wrap(err, "failed to get storage pool driver")
 			}
 
-			if shared.StringInSlice(driver, []string{"ceph", "cephfs"}) {
+			if shared.StringInSlice(driver, []string{"ceph", "cephfs", "iscsi"}) {
 				// For ceph pools we have to create volume
 				// entries for the joining node.
 				err := tx.StoragePoolNodeJoinCeph(id, node.ID)
@@ -514,18 +514,28 @@ func Rebalance(state *state.State, gateway *Gateway) (string, []db.RaftNode, err
 		if err != nil {
 			return errors.Wrap(err, "failed to get cluster nodes")
 		}
-		// Find a node that is not part of the raft cluster yet.
-		for _, node := range nodes {
-			if shared.StringInSlice(node.Address, currentRaftAddresses) {
-				continue // This is already a database node
+		// Find a node that is not part of the raft cluster yet, preferring
+		// the ones with the database-standby role.
+		for _, standbyOnly := range []bool{true, false} {
+			for _, node := range nodes {
+				if shared.StringInSlice(node.Address, currentRaftAddresses) {
+					continue // This is already a database node
+				}
+				if node.IsOffline(config.OfflineThreshold()) {
+					continue // This node is offline
+				}
+				if standbyOnly && !shared.StringInSlice(string(db.ClusterRoleDatabaseStandby), node.Roles) {
+					continue // Not a standby node
+				}
+				logger.Debugf(
+					"Found spare node %s (%s) to be promoted as database node", node.Name, node.Address)
+				address = node.Address
+				break
 			}
-			if node.IsOffline(config.OfflineThreshold()) {
-				continue // This node is offline
+
+			if address != "" {
+				break
 			}
-			logger.Debugf(
-				"Found spare node %s (%s) to be promoted as database node", node.Name, node.Address)
-			address = node.Address
-			break
 		}
 
 		return nil
@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/exec"
 
 	lxd "github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/pkg/errors"
 )
@@ -70,12 +73,19 @@ func MaybeUpdate(state *state.State) error {
 		return fmt.Errorf("Failed checking cluster update, state not initialised yet")
 	}
 
+	mode := ""
 	err = state.Cluster.Transaction(func(tx *db.ClusterTx) error {
 		outdated, err := tx.NodeIsOutdated()
 		if err != nil {
 			return err
 		}
 		shouldUpdate = outdated
+
+		config, err := ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+		mode = config.UpgradeMode()
 		return nil
 	})
 
@@ -89,9 +99,123 @@ func MaybeUpdate(state *state.State) error {
 		return nil
 	}
 
+	if mode == "manual" {
+		logger.Infof("Node is out-of-date with respect to other cluster nodes, waiting for an upgrade request")
+		return nil
+	}
+
 	return triggerUpdate()
 }
 
+// UpdateMember runs LXD_CLUSTER_UPDATE on this node if it's out-of-date,
+// regardless of the cluster.upgrade_mode setting. The update runs in the
+// background, as it's expected to restart LXD.
+func UpdateMember(state *state.State) error {
+	var outdated bool
+	err := state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		outdated, err = tx.NodeIsOutdated()
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to check if this node is out-of-date")
+	}
+
+	if !outdated {
+		return fmt.Errorf("The member is already up-to-date")
+	}
+
+	updateExecutable := os.Getenv("LXD_CLUSTER_UPDATE")
+	if updateExecutable == "" {
+		return fmt.Errorf("No LXD_CLUSTER_UPDATE variable set on the member")
+	}
+
+	logger.Infof("Triggering requested cluster update using: %s", updateExecutable)
+
+	cmd := exec.Command(updateExecutable)
+	err = cmd.Start()
+	if err != nil {
+		return errors.Wrap(err, "Failed to start the cluster update")
+	}
+
+	go func() {
+		err := cmd.Wait()
+		if err != nil {
+			logger.Errorf("Cluster upgrade failed: '%v'", err.Error())
+		}
+	}()
+
+	return nil
+}
+
+// UpgradeStatus returns the version each member of the cluster runs, compared
+// to the most recent one.
+func UpgradeStatus(state *state.State) (*api.ClusterUpgrade, error) {
+	status := &api.ClusterUpgrade{}
+
+	var nodes []db.NodeInfo
+	err := state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		nodes, err = tx.Nodes()
+		if err != nil {
+			return err
+		}
+
+		config, err := ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+		status.Mode = config.UpgradeMode()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the most recent version, which the other members need to catch up with.
+	version := [2]int{}
+	for _, node := range nodes {
+		n, err := util.CompareVersions(node.Version(), version)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Inconsistent version of member %s", node.Name)
+		}
+
+		if n == 1 {
+			version = node.Version()
+		}
+	}
+
+	status.Schema = version[0]
+	status.APIExtensions = version[1]
+
+	outdated := 0
+	for _, node := range nodes {
+		if node.Version() != version {
+			outdated++
+		}
+	}
+
+	for _, node := range nodes {
+		member := api.ClusterUpgradeMember{
+			ServerName:    node.Name,
+			Schema:        node.Schema,
+			APIExtensions: node.APIExtensions,
+		}
+
+		if node.Version() != version {
+			member.Status = "outdated"
+		} else if outdated > 0 {
+			member.Status = "blocked"
+		} else {
+			member.Status = "up-to-date"
+		}
+
+		status.Members = append(status.Members, member)
+	}
+
+	return status, nil
+}
+
 func triggerUpdate() error {
 	logger.Infof("Node is out-of-date with respect to other cluster nodes")
 
@@ -10,6 +10,7 @@ import (
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/state"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -109,3 +110,70 @@ func TestMaybeUpdate_NothingToDo(t *testing.T) {
 	_, err = os.Stat(stamp)
 	require.True(t, os.IsNotExist(err))
 }
+
+// In manual upgrade mode, out-of-date nodes wait for an explicit request.
+func TestMaybeUpdate_Manual(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	// Create a stub upgrade script that just touches a stamp file.
+	stamp := filepath.Join(dir, "stamp")
+	script := filepath.Join(dir, "cluster-upgrade")
+	data := []byte(fmt.Sprintf("#!/bin/sh\ntouch %s\n", stamp))
+	err = ioutil.WriteFile(script, data, 0755)
+	require.NoError(t, err)
+
+	state, cleanup := state.NewTestState(t)
+	defer cleanup()
+
+	state.Node.Transaction(func(tx *db.NodeTx) error {
+		nodes := []db.RaftNode{
+			{ID: 1, Address: "0.0.0.0:666"},
+			{ID: 2, Address: "1.2.3.4:666"},
+		}
+		err := tx.RaftNodesReplace(nodes)
+		require.NoError(t, err)
+		return nil
+	})
+
+	state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		id, err := tx.NodeAdd("buzz", "1.2.3.4:666")
+		require.NoError(t, err)
+
+		node, err := tx.NodeByName("buzz")
+		require.NoError(t, err)
+
+		version := node.Version()
+		version[0]++
+
+		err = tx.NodeUpdateVersion(id, version)
+		require.NoError(t, err)
+
+		config, err := cluster.ConfigLoad(tx)
+		require.NoError(t, err)
+
+		_, err = config.Patch(map[string]interface{}{"cluster.upgrade_mode": "manual"})
+		require.NoError(t, err)
+
+		return nil
+	})
+
+	os.Setenv("LXD_CLUSTER_UPDATE", script)
+	defer os.Unsetenv("LXD_CLUSTER_UPDATE")
+
+	cluster.MaybeUpdate(state)
+
+	_, err = os.Stat(stamp)
+	require.True(t, os.IsNotExist(err))
+
+	// The upgraded node is blocked, waiting for the out-of-date one.
+	status, err := cluster.UpgradeStatus(state)
+	require.NoError(t, err)
+	assert.Equal(t, "manual", status.Mode)
+	require.Len(t, status.Members, 2)
+	assert.Equal(t, "outdated", status.Members[0].Status)
+	assert.Equal(t, "blocked", status.Members[1].Status)
+	assert.Equal(t, status.Members[1].Schema, status.Schema)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os/exec"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// clusterRoleHooks are run when a role is assigned to (enabled) or removed
+// from the local cluster member.
+var clusterRoleHooks = map[db.ClusterRole]func(d *Daemon, enabled bool) error{
+	db.ClusterRoleOVNChassis: clusterRoleOVNChassis,
+}
+
+// clusterRolesRefresh compares the roles distributed through the heartbeat
+// for the local member with the ones last applied and runs the hooks of the
+// roles which changed.
+func (d *Daemon) clusterRolesRefresh(roles []string) {
+	first := d.clusterRoles == nil
+	if roles == nil {
+		roles = []string{}
+	}
+
+	for role, hook := range clusterRoleHooks {
+		wanted := shared.StringInSlice(string(role), roles)
+		current := shared.StringInSlice(string(role), d.clusterRoles)
+
+		// On the first heartbeat only react to assigned roles, we don't
+		// want to stop services which weren't started by us.
+		if wanted == current || (first && !wanted) {
+			continue
+		}
+
+		logger.Info("Cluster role changed", log.Ctx{"role": role, "enabled": wanted})
+		err := hook(d, wanted)
+		if err != nil {
+			logger.Error("Failed to apply cluster role change", log.Ctx{"role": role, "enabled": wanted, "err": err})
+		}
+	}
+
+	d.clusterRoles = roles
+}
+
+// clusterRoleOVNChassis starts or stops the local OVN controller.
+func clusterRoleOVNChassis(d *Daemon, enabled bool) error {
+	_, err := exec.LookPath("ovn-controller")
+	if err != nil {
+		if enabled {
+			logger.Warn("The ovn-chassis role is assigned but ovn-controller isn't installed")
+		}
+
+		return nil
+	}
+
+	action := "stop"
+	if enabled {
+		action = "start"
+	}
+
+	_, err = shared.RunCommand("systemctl", action, "ovn-controller")
+	return err
+}
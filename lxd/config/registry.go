@@ -0,0 +1,188 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Entity identifies the kind of object whose config keys are registered.
+type Entity string
+
+// Entities with registered config keys.
+const (
+	EntityInstance    Entity = "instance"
+	EntityNetwork     Entity = "network"
+	EntityStoragePool Entity = "storage-pool"
+)
+
+// The registry holds the schema of the config keys of each entity, along with the keys which were
+// renamed. Unlike the server schemas, these are only used for validation and documentation: the
+// default of a key documents the behavior when it's unset and is never applied.
+//
+// The name of a key may contain "*" segments, matching any single segment, or end with a "*"
+// segment, matching any number of them.
+var registry = struct {
+	mu      sync.RWMutex
+	schemas map[Entity]Schema
+	renames map[Entity]map[string]string
+}{
+	schemas: map[Entity]Schema{},
+	renames: map[Entity]map[string]string{},
+}
+
+// Register adds the given keys to the schema of the entity.
+func Register(entity Entity, schema Schema) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if registry.schemas[entity] == nil {
+		registry.schemas[entity] = Schema{}
+	}
+
+	for name, key := range schema {
+		registry.schemas[entity][name] = key
+	}
+}
+
+// RegisterRename records that a config key of the entity was renamed. The old name is then
+// translated to the new one by Translate.
+func RegisterRename(entity Entity, oldName string, newName string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if registry.renames[entity] == nil {
+		registry.renames[entity] = map[string]string{}
+	}
+
+	registry.renames[entity][oldName] = newName
+}
+
+// Registered returns a copy of the schema of the entity.
+func Registered(entity Entity) Schema {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	schema := Schema{}
+	for name, key := range registry.schemas[entity] {
+		schema[name] = key
+	}
+
+	return schema
+}
+
+// Renames returns the renamed keys of the entity, mapping their old name to the new one.
+func Renames(entity Entity) map[string]string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	renames := map[string]string{}
+	for oldName, newName := range registry.renames[entity] {
+		renames[oldName] = newName
+	}
+
+	return renames
+}
+
+// Translate replaces the renamed keys of the config by their new name, and returns the old names
+// found, sorted. If both names are set, the value of the new one is kept.
+func Translate(entity Entity, config map[string]string) []string {
+	translated := []string{}
+	for oldName, newName := range Renames(entity) {
+		value, ok := config[oldName]
+		if !ok {
+			continue
+		}
+
+		delete(config, oldName)
+		_, ok = config[newName]
+		if !ok {
+			config[newName] = value
+		}
+
+		translated = append(translated, oldName)
+	}
+
+	sort.Strings(translated)
+	return translated
+}
+
+// Lookup returns the registered key of the entity matching the given name.
+func Lookup(entity Entity, name string) (Key, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	schema := registry.schemas[entity]
+	key, ok := schema[name]
+	if ok {
+		return key, true
+	}
+
+	// Prefer the most specific of the matching patterns.
+	best := ""
+	for pattern := range schema {
+		if strings.Contains(pattern, "*") && keyMatches(pattern, name) && len(pattern) > len(best) {
+			best = pattern
+		}
+	}
+
+	if best == "" {
+		return Key{}, false
+	}
+
+	return schema[best], true
+}
+
+// Validate checks the value of a config key of the entity against the type and validator of its
+// registered key. Unsetting a key is always valid.
+func Validate(entity Entity, name string, value string) error {
+	key, ok := Lookup(entity, name)
+	if !ok {
+		return fmt.Errorf("Unknown configuration key: %s", name)
+	}
+
+	if value == "" {
+		return nil
+	}
+
+	return key.validate(value)
+}
+
+// TypeName returns the name of the type of the key, as documented.
+func (v *Key) TypeName() string {
+	switch v.Type {
+	case Bool:
+		return "bool"
+	case Int64:
+		return "integer"
+	default:
+		return "string"
+	}
+}
+
+// keyMatches returns whether the name matches the key pattern.
+func keyMatches(pattern string, name string) bool {
+	patternFields := strings.Split(pattern, ".")
+	nameFields := strings.Split(name, ".")
+
+	for i, field := range patternFields {
+		if field == "*" && i == len(patternFields)-1 {
+			return len(nameFields) >= len(patternFields) && nameFields[i] != ""
+		}
+
+		if i >= len(nameFields) {
+			return false
+		}
+
+		if field != "*" && field != nameFields[i] {
+			return false
+		}
+
+		if field == "*" && nameFields[i] == "" {
+			return false
+		}
+	}
+
+	return len(nameFields) == len(patternFields)
+}
@@ -0,0 +1,71 @@
+package config_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/config"
+)
+
+func TestRegistry_Lookup(t *testing.T) {
+	config.Register("test-lookup", config.Schema{
+		"foo":         {},
+		"user.*":      {Description: "user"},
+		"tasks.*.bar": {Description: "task"},
+		"tasks.*":     {Description: "tasks"},
+	})
+
+	cases := map[string]string{
+		"user.x":        "user",
+		"user.x.y":      "user",
+		"tasks.a.bar":   "task",
+		"tasks.a":       "tasks",
+		"tasks.a.bar.b": "tasks",
+	}
+
+	for name, description := range cases {
+		key, ok := config.Lookup("test-lookup", name)
+		assert.True(t, ok, name)
+		assert.Equal(t, description, key.Description, name)
+	}
+
+	for _, name := range []string{"bar", "user", "user.", "foo.x"} {
+		_, ok := config.Lookup("test-lookup", name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestRegistry_Validate(t *testing.T) {
+	config.Register("test-validate", config.Schema{
+		"foo": {Type: config.Bool},
+		"bar": {Type: config.Int64, Validator: func(value string) error {
+			if value == "0" {
+				return fmt.Errorf("zero")
+			}
+
+			return nil
+		}},
+	})
+
+	assert.NoError(t, config.Validate("test-validate", "foo", "true"))
+	assert.NoError(t, config.Validate("test-validate", "foo", ""))
+	assert.Error(t, config.Validate("test-validate", "foo", "maybe"))
+	assert.NoError(t, config.Validate("test-validate", "bar", "1"))
+	assert.Error(t, config.Validate("test-validate", "bar", "0"))
+	assert.Error(t, config.Validate("test-validate", "bar", "x"))
+	assert.Error(t, config.Validate("test-validate", "baz", "x"))
+}
+
+func TestRegistry_Translate(t *testing.T) {
+	config.RegisterRename("test-translate", "old.a", "new.a")
+	config.RegisterRename("test-translate", "old.b", "new.b")
+
+	values := map[string]string{"old.a": "1", "old.b": "2", "new.b": "3", "other": "4"}
+	translated := config.Translate("test-translate", values)
+	assert.Equal(t, []string{"old.a", "old.b"}, translated)
+	assert.Equal(t, map[string]string{"new.a": "1", "new.b": "3", "other": "4"}, values)
+
+	assert.Equal(t, []string{}, config.Translate("test-translate", nil))
+}
@@ -56,10 +56,11 @@ func (s Schema) assertKeyType(name string, code Type) {
 // Key defines the type of the value of a particular config key, along with
 // other knobs such as default, validator, etc.
 type Key struct {
-	Type       Type   // Type of the value. It defaults to String.
-	Default    string // If the key is not set in a Map, use this value instead.
-	Hidden     bool   // Hide this key when dumping the object.
-	Deprecated string // Optional message to set if this config value is deprecated.
+	Type        Type   // Type of the value. It defaults to String.
+	Default     string // If the key is not set in a Map, use this value instead.
+	Hidden      bool   // Hide this key when dumping the object.
+	Deprecated  string // Optional message to set if this config value is deprecated.
+	Description string // Optional documentation of the key.
 
 	// Optional function used to validate the values. It's called by Map
 	// all the times the value associated with this Key is going to be
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/lxc/lxd/lxd/config"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+func init() {
+	configRegister(config.EntityInstance, instanceConfigDocs, shared.KnownInstanceConfigKeys)
+	configRegister(config.EntityNetwork, networkConfigDocs, networkConfigKeys)
+	configRegister(config.EntityStoragePool, storagePoolConfigDocs, storagePoolConfigKeys)
+
+	// Renamed keys are translated when set through the API, and migrated in the database by
+	// the patches running patchConfigRenamedKeys. Add a new such patch with each rename.
+	config.RegisterRename(config.EntityInstance, "security.syscalls.whitelist", "security.syscalls.allow")
+	config.RegisterRename(config.EntityInstance, "security.syscalls.blacklist", "security.syscalls.deny")
+	config.RegisterRename(config.EntityInstance, "security.syscalls.blacklist_default", "security.syscalls.deny_default")
+	config.RegisterRename(config.EntityInstance, "security.syscalls.blacklist_compat", "security.syscalls.deny_compat")
+}
+
+// configRegister registers the documented config keys of the entity along with their validators.
+// Keys with a validator are registered even when undocumented.
+func configRegister(entity config.Entity, docs config.Schema, validators map[string]func(value string) error) {
+	schema := config.Schema{}
+	for name, key := range docs {
+		key.Validator = validators[name]
+		schema[name] = key
+	}
+
+	for name, validator := range validators {
+		_, ok := schema[name]
+		if !ok {
+			schema[name] = config.Key{Validator: validator}
+		}
+	}
+
+	config.Register(entity, schema)
+}
+
+// configTranslateKeys replaces the renamed keys of the config of the entity by their new name.
+func configTranslateKeys(entity config.Entity, conf map[string]string) {
+	renames := config.Renames(entity)
+	for _, key := range config.Translate(entity, conf) {
+		logger.Warn("Translated renamed configuration key", log.Ctx{"entity": entity, "key": key, "new": renames[key]})
+	}
+}
+
+// configMetadata returns the documentation of the registered config keys.
+func configMetadata() map[string]map[string]api.MetadataConfigurationKey {
+	configs := map[string]map[string]api.MetadataConfigurationKey{}
+	for _, entity := range []config.Entity{config.EntityInstance, config.EntityNetwork, config.EntityStoragePool} {
+		keys := map[string]api.MetadataConfigurationKey{}
+		for name, key := range config.Registered(entity) {
+			keys[name] = api.MetadataConfigurationKey{
+				Type:        key.TypeName(),
+				Default:     key.Default,
+				Description: key.Description,
+			}
+		}
+
+		for oldName, newName := range config.Renames(entity) {
+			key, ok := keys[newName]
+			if !ok {
+				continue
+			}
+
+			key.RenamedFrom = append(key.RenamedFrom, oldName)
+			sort.Strings(key.RenamedFrom)
+			keys[newName] = key
+		}
+
+		configs[string(entity)] = keys
+	}
+
+	return configs
+}
+
+// The documentation of the keys mirrors the tables of the doc directory.
+
+// instanceConfigDocs documents the instance config keys.
+var instanceConfigDocs = config.Schema{
+	"boot.after":                                {Description: "Comma separated list of instances (in the same project) to start before this one when LXD starts"},
+	"boot.autostart":                            {Type: config.Bool, Description: "Always start the instance when LXD starts (if not set, restore last state)"},
+	"boot.autostart.delay":                      {Type: config.Int64, Default: "0", Description: "Number of seconds to wait after the instance started before starting the next one"},
+	"boot.autostart.priority":                   {Type: config.Int64, Default: "0", Description: "What order to start the instances in (starting with highest)"},
+	"boot.host_shutdown_timeout":                {Type: config.Int64, Default: "30", Description: "Seconds to wait for instance to shutdown before it is force stopped"},
+	"boot.stop.priority":                        {Type: config.Int64, Default: "0", Description: "What order to shutdown the instances (starting with highest)"},
+	"cluster.affinity":                          {Description: "Comma separated list of placement groups, instances of a group are kept on the same cluster node, those of a group prefixed with `!` are spread on different nodes"},
+	"cloud-init.network-config":                 {Default: "DHCP on eth0", Description: "Cloud-init network-config, content is used as seed value (takes precedence over user.network-config)"},
+	"cloud-init.user-data":                      {Default: "#cloud-config", Description: "Cloud-init user-data, content is used as seed value (takes precedence over user.user-data)"},
+	"cloud-init.vendor-data":                    {Default: "#cloud-config", Description: "Cloud-init vendor-data, content is used as seed value (takes precedence over user.vendor-data)"},
+	"environment.*":                             {Description: "key/value environment variables to export to the instance and set on exec"},
+	"limits.cpu":                                {Description: "Number or range of CPUs to expose to the instance"},
+	"limits.cpu.nodes":                          {Description: "NUMA nodes (e.g. 0-1) whose CPUs and memory the instance is restricted to"},
+	"limits.cpu.allowance":                      {Default: "100%", Description: "How much of the CPU can be used. Can be a percentage (e.g. 50%) for a soft limit or hard a chunk of time (25ms/100ms)"},
+	"limits.cpu.priority":                       {Type: config.Int64, Default: "10 (maximum)", Description: "CPU scheduling priority compared to other instances sharing the same CPUs (overcommit) (integer between 0 and 10)"},
+	"limits.disk.priority":                      {Type: config.Int64, Default: "5 (medium)", Description: "When under load, how much priority to give to the instance's I/O requests (integer between 0 and 10)"},
+	"limits.kernel.*":                           {Description: "This limits kernel resources per instance (e.g. number of open files)"},
+	"limits.memory":                             {Description: "Percentage of the host's memory or fixed value in bytes (various suffixes supported, see below)"},
+	"limits.memory.enforce":                     {Default: "hard", Description: "If hard, instance can't exceed its memory limit. If soft, the instance can exceed its memory limit when extra host memory is available"},
+	"limits.memory.hugepages":                   {Type: config.Bool, Default: "false", Description: "Controls whether to back the instance using preallocated hugepages rather than regular system memory"},
+	"limits.memory.swap":                        {Type: config.Bool, Default: "true", Description: "Whether to allow some of the instance's memory to be swapped out to disk"},
+	"limits.memory.swap.priority":               {Type: config.Int64, Default: "10 (maximum)", Description: "The higher this is set, the least likely the instance is to be swapped to disk (integer between 0 and 10)"},
+	"limits.network.priority":                   {Type: config.Int64, Default: "0 (minimum)", Description: "When under load, how much priority to give to the instance's network requests (integer between 0 and 10)"},
+	"limits.processes":                          {Type: config.Int64, Description: "Maximum number of processes that can run in the instance"},
+	"linux.kernel_modules":                      {Description: "Comma separated list of kernel modules to load before starting the instance"},
+	"linux.sysctl.*":                            {Description: "Namespaced sysctls to set in the instance at start (e.g. net.ipv4.ip_forward)"},
+	"migration.criu.lazy_pages":                 {Type: config.Bool, Default: "false", Description: "Transfer the memory of the instance after it's restored on the target (post-copy) during live migration"},
+	"migration.criu.tcp_established":            {Type: config.Bool, Default: "false", Description: "Checkpoint and restore established TCP connections of the instance"},
+	"migration.incremental.memory":              {Type: config.Bool, Default: "false", Description: "Incremental memory transfer of the instance's memory to reduce downtime"},
+	"migration.incremental.memory.bandwidth":    {Description: "Bandwidth cap (in bytes per second, supports units) of the memory transfer"},
+	"migration.incremental.memory.downtime":     {Type: config.Int64, Description: "Target downtime (in milliseconds) after which the final memory transfer happens"},
+	"migration.incremental.memory.goal":         {Type: config.Int64, Default: "70", Description: "Percentage of memory to have in sync before stopping the instance"},
+	"migration.incremental.memory.iterations":   {Type: config.Int64, Default: "10", Description: "Maximum number of transfer operations to go through before stopping the instance"},
+	"migration.stateful":                        {Type: config.Bool, Default: "false", Description: "Allow for stateful stop/start and snapshots (the config drive is then exposed as a read-only disk)"},
+	"nvidia.driver.capabilities":                {Default: "compute,utility", Description: "What driver capabilities the instance needs (sets libnvidia-container NVIDIA_DRIVER_CAPABILITIES)"},
+	"nvidia.runtime":                            {Type: config.Bool, Default: "false", Description: "Pass the host NVIDIA and CUDA runtime libraries into the instance"},
+	"nvidia.require.cuda":                       {Description: "Version expression for the required CUDA version (sets libnvidia-container NVIDIA_REQUIRE_CUDA)"},
+	"nvidia.require.driver":                     {Description: "Version expression for the required driver version (sets libnvidia-container NVIDIA_REQUIRE_DRIVER)"},
+	"raw.apparmor":                              {Description: "Apparmor profile entries to be appended to the generated profile"},
+	"raw.idmap":                                 {Description: "Raw idmap configuration (e.g. \"both 1000 1000\")"},
+	"raw.lxc":                                   {Description: "Raw LXC configuration to be appended to the generated one"},
+	"raw.qemu":                                  {Description: "Raw Qemu configuration to be appended to the generated command line"},
+	"raw.qemu.conf":                             {Description: "Addition/override to the generated qemu.conf file (see [Override QEMU configuration](#override-qemu-configuration))"},
+	"raw.seccomp":                               {Description: "Raw Seccomp configuration"},
+	"security.devlxd":                           {Type: config.Bool, Default: "true", Description: "Controls the presence of /dev/lxd in the instance"},
+	"security.apparmor":                         {Type: config.Bool, Default: "true", Description: "Controls whether QEMU is confined by a per-instance AppArmor profile"},
+	"security.devlxd.images":                    {Type: config.Bool, Default: "false", Description: "Controls the availability of the /1.0/images API over devlxd"},
+	"security.idmap.base":                       {Type: config.Int64, Description: "The base host ID to use for the allocation (overrides auto-detection)"},
+	"security.idmap.isolated":                   {Type: config.Bool, Default: "false", Description: "Use an idmap for this instance that is unique among instances with isolated set"},
+	"security.idmap.size":                       {Type: config.Int64, Description: "The size of the idmap to use"},
+	"security.nesting":                          {Type: config.Bool, Default: "false", Description: "Support running lxd (nested) inside the instance"},
+	"security.privileged":                       {Type: config.Bool, Default: "false", Description: "Runs the instance in privileged mode"},
+	"security.protection.delete":                {Type: config.Bool, Default: "false", Description: "Prevents the instance from being deleted"},
+	"security.protection.shift":                 {Type: config.Bool, Default: "false", Description: "Prevents the instance's filesystem from being uid/gid shifted on startup"},
+	"security.secureboot":                       {Type: config.Bool, Default: "true", Description: "Controls whether UEFI secure boot is enabled with the default Microsoft keys"},
+	"security.sev":                              {Type: config.Bool, Default: "false", Description: "Enables AMD SEV memory encryption for the instance"},
+	"security.sev.policy":                       {Type: config.Int64, Default: "0x1", Description: "AMD SEV guest policy passed to QEMU (setting bit 2 requires SEV-ES)"},
+	"security.syscalls.allow":                   {Description: "A '\\n' separated list of syscalls to allow (mutually exclusive with security.syscalls.deny*)"},
+	"security.syscalls.deny":                    {Description: "A '\\n' separated list of syscalls to deny"},
+	"security.syscalls.deny_compat":             {Type: config.Bool, Default: "false", Description: "On x86_64 this enables blocking of compat_* syscalls, it is a no-op on other arches"},
+	"security.syscalls.deny_default":            {Type: config.Bool, Default: "true", Description: "Enables the default syscall deny list"},
+	"security.syscalls.intercept.bpf":           {Type: config.Bool, Default: "false", Description: "Handles the `bpf` system call"},
+	"security.syscalls.intercept.bpf.devices":   {Type: config.Bool, Default: "false", Description: "Allows the management of device cgroup programs through the `bpf` system call"},
+	"security.syscalls.intercept.mknod":         {Type: config.Bool, Default: "false", Description: "Handles the `mknod` and `mknodat` system calls (allows creation of a limited subset of char/block devices)"},
+	"security.syscalls.intercept.mount":         {Type: config.Bool, Default: "false", Description: "Handles the `mount` system call"},
+	"security.syscalls.intercept.mount.allowed": {Description: "Specify a comma-separated list of filesystems that are safe to mount for processes inside the instance"},
+	"security.syscalls.intercept.mount.fuse":    {Description: "Whether to redirect mounts of a given filesystem to their fuse implemenation (e.g. ext4=fuse2fs)"},
+	"security.syscalls.intercept.mount.shift":   {Type: config.Bool, Default: "false", Description: "Whether to mount shiftfs on top of filesystems handled through mount syscall interception"},
+	"security.syscalls.intercept.setxattr":      {Type: config.Bool, Default: "false", Description: "Handles the `setxattr` system call (allows setting a limited subset of restricted extended attributes)"},
+	"snapshots.schedule":                        {Description: "Cron expression (`<minute> <hour> <dom> <month> <dow>`), or a comma separated list of schedule aliases `<@hourly> <@daily> <@midnight> <@weekly> <@monthly> <@annually> <@yearly>`"},
+	"snapshots.schedule.stopped":                {Type: config.Bool, Default: "false", Description: "Controls whether or not stopped instances are to be snapshoted automatically"},
+	"snapshots.pattern":                         {Default: "snap%d", Description: "Pongo2 template string which represents the snapshot name (used for scheduled snapshots and unnamed snapshots)"},
+	"snapshots.expiry":                          {Description: "Controls when snapshots are to be deleted (expects expression like `1M 2H 3d 4w 5m 6y`)"},
+	"snapshots.quiesce":                         {Type: config.Bool, Default: "false", Description: "Controls whether the filesystems of running virtual machines are frozen through the agent while snapshotting"},
+	"tasks.*.action":                            {Description: "What the task does (snapshot, backup or exec)"},
+	"tasks.*.command":                           {Description: "Command run by exec tasks (must be listed in tasks.exec_whitelist)"},
+	"tasks.*.retain":                            {Type: config.Int64, Description: "Number of snapshots or backups made by the task to keep (all if unset)"},
+	"tasks.*.schedule":                          {Description: "Cron expression (`<minute> <hour> <dom> <month> <dow>`), or a comma separated list of schedule aliases `<@hourly> <@daily> <@midnight> <@weekly> <@monthly> <@annually> <@yearly>`"},
+	"tasks.*.target":                            {Description: "Directory to export the backups of backup tasks to (must be listed in tasks.backup_targets)"},
+	"user.*":                                    {Description: "Free form user key/value storage (can be used in search)"},
+	"volatile.apply_template":                   {Description: "The name of a template hook which should be triggered upon next startup"},
+	"volatile.base_image":                       {Description: "The hash of the image the instance was created from, if any"},
+	"volatile.idmap.base":                       {Type: config.Int64, Description: "The first id in the instance's primary idmap range"},
+	"volatile.idmap.current":                    {Description: "The idmap currently in use by the instance"},
+	"volatile.idmap.next":                       {Description: "The idmap to use next time the instance starts"},
+	"volatile.last_state.idmap":                 {Description: "Serialized instance uid/gid map"},
+	"volatile.last_state.power":                 {Description: "Instance state as of last host shutdown"},
+	"volatile.uuid":                             {Description: "Instance UUID, kept across renames and moves (snapshots have their own)"},
+	"volatile.vm.uuid":                          {Description: "Virtual machine UUID"},
+	"volatile.tasks.*.last_run":                 {Description: "When the task last ran"},
+	"volatile.tasks.*.last_status":              {Description: "Result of the last task run (\"Success\" or the error)"},
+	"volatile.*.apply_quota":                    {Description: "Disk quota to be applied on next instance start"},
+	"volatile.*.ceph_rbd":                       {Description: "RBD device path for Ceph disk devices"},
+	"volatile.*.host_name":                      {Description: "Network device name on the host"},
+	"volatile.*.hwaddr":                         {Description: "Network device MAC address (when no hwaddr property is set on the device itself)"},
+	"volatile.*.last_state.created":             {Description: "Whether or not the network device physical device was created (\"true\" or \"false\")"},
+	"volatile.*.last_state.mtu":                 {Description: "Network device original MTU used when moving a physical device into an instance"},
+	"volatile.*.last_state.hwaddr":              {Description: "Network device original MAC used when moving a physical device into an instance"},
+	"volatile.*.last_state.vf.id":               {Description: "SR-IOV Virtual function ID used when moving a VF into an instance"},
+	"volatile.*.last_state.vf.hwaddr":           {Description: "SR-IOV Virtual function original MAC used when moving a VF into an instance"},
+	"volatile.*.last_state.vf.vlan":             {Description: "SR-IOV Virtual function original VLAN used when moving a VF into an instance"},
+	"volatile.*.last_state.vf.spoofcheck":       {Description: "SR-IOV Virtual function original spoof check setting used when moving a VF into an instance"},
+}
+
+// networkConfigDocs documents the network config keys.
+var networkConfigDocs = config.Schema{
+	"bgp.peers.*.address":        {Description: "Peer address (IPv4 or IPv6)"},
+	"bgp.peers.*.asn":            {Type: config.Int64, Description: "Peer AS number"},
+	"bgp.peers.*.password":       {Description: "Peer session password (optional)"},
+	"bgp.ipv4.nexthop":           {Default: "local address", Description: "Override the IPv4 next-hop for announced prefixes"},
+	"bgp.ipv6.nexthop":           {Default: "local address", Description: "Override the IPv6 next-hop for announced prefixes"},
+	"bridge.driver":              {Default: "native", Description: "Bridge driver (\"native\" or \"openvswitch\")"},
+	"bridge.external_interfaces": {Description: "Comma separate list of unconfigured network interfaces to include in the bridge"},
+	"bridge.hwaddr":              {Description: "MAC address for the bridge"},
+	"bridge.mode":                {Default: "standard", Description: "Bridge operation mode (\"standard\" or \"fan\")"},
+	"bridge.mtu":                 {Type: config.Int64, Default: "1500", Description: "Bridge MTU (default varies if tunnel or fan setup)"},
+	"dns.domain":                 {Default: "lxd", Description: "Domain to advertise to DHCP clients and use for DNS resolution"},
+	"dns.mode":                   {Default: "managed", Description: "DNS registration mode (\"none\" for no DNS record, \"managed\" for LXD generated static records, \"managed-native\" for the same through LXD's native DHCP and DNS server instead of dnsmasq or \"dynamic\" for client generated records)"},
+	"fan.overlay_subnet":         {Default: "240.0.0.0/8", Description: "Subnet to use as the overlay for the FAN (CIDR notation)"},
+	"fan.type":                   {Default: "vxlan", Description: "The tunneling type for the FAN (\"vxlan\" or \"ipip\")"},
+	"fan.underlay_subnet":        {Default: "default gateway subnet", Description: "Subnet to use as the underlay for the FAN (CIDR notation)"},
+	"ipv4.address":               {Default: "random unused subnet", Description: "IPv4 address for the bridge (CIDR notation). Use \"none\" to turn off IPv4 or \"auto\" to generate a new one"},
+	"ipv4.dhcp":                  {Type: config.Bool, Default: "true", Description: "Whether to allocate addresses using DHCP"},
+	"ipv4.dhcp.expiry":           {Default: "1h", Description: "When to expire DHCP leases"},
+	"ipv4.dhcp.gateway":          {Default: "ipv4.address", Description: "Address of the gateway for the subnet"},
+	"ipv4.dhcp.ranges":           {Default: "all addresses", Description: "Comma separated list of IP ranges to use for DHCP (FIRST-LAST format)"},
+	"ipv4.firewall":              {Type: config.Bool, Default: "true", Description: "Whether to generate filtering firewall rules for this network"},
+	"ipv4.nat":                   {Type: config.Bool, Default: "false", Description: "Whether to NAT (will default to true if unset and a random ipv4.address is generated)"},
+	"ipv4.nat.order":             {Default: "before", Description: "Whether to add the required NAT rules before or after any pre-existing rules"},
+	"ipv4.nat.address":           {Description: "The source address used for outbound traffic from the bridge"},
+	"ipv4.routes":                {Description: "Comma separated list of additional IPv4 CIDR subnets to route to the bridge"},
+	"ipv4.routing":               {Type: config.Bool, Default: "true", Description: "Whether to route traffic in and out of the bridge"},
+	"ipv6.address":               {Default: "random unused subnet", Description: "IPv6 address for the bridge (CIDR notation). Use \"none\" to turn off IPv6 or \"auto\" to generate a new one"},
+	"ipv6.dhcp":                  {Type: config.Bool, Default: "true", Description: "Whether to provide additional network configuration over DHCP"},
+	"ipv6.dhcp.expiry":           {Default: "1h", Description: "When to expire DHCP leases"},
+	"ipv6.dhcp.ranges":           {Default: "all addresses", Description: "Comma separated list of IPv6 ranges to use for DHCP (FIRST-LAST format)"},
+	"ipv6.dhcp.stateful":         {Type: config.Bool, Default: "false", Description: "Whether to allocate addresses using DHCP"},
+	"ipv6.firewall":              {Type: config.Bool, Default: "true", Description: "Whether to generate filtering firewall rules for this network"},
+	"ipv6.nat":                   {Type: config.Bool, Default: "false", Description: "Whether to NAT (will default to true if unset and a random ipv6.address is generated)"},
+	"ipv6.nat.order":             {Default: "before", Description: "Whether to add the required NAT rules before or after any pre-existing rules"},
+	"ipv6.nat.address":           {Description: "The source address used for outbound traffic from the bridge"},
+	"ipv6.ra.default_route":      {Type: config.Bool, Default: "true", Description: "Whether to advertise the bridge as the default router"},
+	"ipv6.ra.dns":                {Description: "Comma separated list of DNS servers to advertise to the instances"},
+	"ipv6.ra.mtu":                {Type: config.Int64, Description: "MTU to advertise to the instances (at least 1280)"},
+	"ipv6.routes":                {Description: "Comma separated list of additional IPv6 CIDR subnets to route to the bridge"},
+	"ipv6.routing":               {Type: config.Bool, Default: "true", Description: "Whether to route traffic in and out of the bridge"},
+	"raw.dnsmasq":                {Description: "Additional dnsmasq configuration to append to the configuration"},
+	"tunnel.*.group":             {Default: "239.0.0.1", Description: "Multicast address for vxlan (used if local and remote aren't set)"},
+	"tunnel.*.id":                {Type: config.Int64, Default: "0", Description: "Specific tunnel ID to use for the vxlan tunnel"},
+	"tunnel.*.interface":         {Description: "Specific host interface to use for the tunnel"},
+	"tunnel.*.local":             {Description: "Local address for the tunnel (not necessary for multicast vxlan)"},
+	"tunnel.*.port":              {Type: config.Int64, Default: "0", Description: "Specific port to use for the vxlan tunnel"},
+	"tunnel.*.protocol":          {Description: "Tunneling protocol (\"vxlan\" or \"gre\")"},
+	"tunnel.*.remote":            {Description: "Remote address for the tunnel (not necessary for multicast vxlan)"},
+	"tunnel.*.ttl":               {Type: config.Int64, Default: "1", Description: "Specific TTL to use for multicast routing topologies"},
+}
+
+// storagePoolConfigDocs documents the storage pool config keys.
+var storagePoolConfigDocs = config.Schema{
+	"size":                        {Default: "0", Description: "Size of the storage pool in bytes (suffixes supported). (Currently valid for loop based pools and zfs.)"},
+	"size.grow_max":               {Description: "Size up to which the pool is automatically grown (unlimited if unset)"},
+	"size.grow_step":              {Default: "5GiB", Description: "Size added to the pool each time it is automatically grown"},
+	"size.grow_threshold":         {Type: config.Int64, Description: "Percentage of used space above which the pool is automatically grown (disabled if unset)"},
+	"source":                      {Description: "Path to block device or loop file or filesystem entry (comma separated iSCSI portals for iscsi)"},
+	"btrfs.mount_options":         {Default: "user_subvol_rm_allowed", Description: "Mount options for block devices"},
+	"ceph.cluster_name":           {Default: "ceph", Description: "Name of the ceph cluster in which to create new storage pools."},
+	"ceph.osd.force_reuse":        {Type: config.Bool, Default: "false", Description: "Force using an osd storage pool that is already in use by another LXD instance."},
+	"ceph.osd.pg_num":             {Default: "32", Description: "Number of placement groups for the osd storage pool."},
+	"ceph.osd.pool_name":          {Default: "name of the pool", Description: "Name of the osd storage pool."},
+	"ceph.osd.data_pool_name":     {Description: "Name of the osd data pool, such as an erasure coded pool."},
+	"ceph.rbd.clone_copy":         {Default: "true", Description: "Whether to use RBD lightweight clones rather than full dataset copies."},
+	"ceph.user.name":              {Default: "admin", Description: "The ceph user to use when creating storage pools and volumes."},
+	"cephfs.cluster_name":         {Default: "ceph", Description: "Name of the ceph cluster in which to create new storage pools."},
+	"cephfs.path":                 {Default: "/", Description: "The base path for the CEPHFS mount"},
+	"cephfs.user.name":            {Default: "admin", Description: "The ceph user to use when creating storage pools and volumes."},
+	"iscsi.multipath":             {Type: config.Bool, Default: "true", Description: "Whether to log into all the portals and combine the paths with dm-multipath."},
+	"iscsi.target.host":           {Description: "SSH destination of the target host running targetcli (the local host if unset)."},
+	"iscsi.target.iqn":            {Default: "iqn.2020-03.org.linuxcontainers.lxd:name of the pool", Description: "Prefix of the IQNs of the targets exporting the volumes."},
+	"iscsi.target.path":           {Default: "/var/lib/lxd-iscsi/name of the pool", Description: "Directory holding the backing files of the volumes on the target host."},
+	"lvm.thinpool_name":           {Default: "LXDThinPool", Description: "Thin pool where images and containers are created."},
+	"lvm.use_thinpool":            {Type: config.Bool, Default: "true", Description: "Whether the storage pool uses a thinpool for logical volumes."},
+	"lvm.vg_name":                 {Default: "name of the pool", Description: "Name of the volume group to create."},
+	"rsync.bwlimit":               {Default: "0 (no limit)", Description: "Specifies the upper limit to be placed on the socket I/O whenever rsync has to be used to transfer storage entities."},
+	"rsync.compression":           {Type: config.Bool, Default: "true", Description: "Whether to use compression while migrating storage pools."},
+	"volatile.initial_source":     {Description: "Records the actual source passed during creating (e.g. /dev/sdb)."},
+	"volatile.pool.pristine":      {Default: "true", Description: "Whether the pool has been empty on creation time."},
+	"volume.block.filesystem":     {Default: "ext4", Description: "Filesystem to use for new volumes"},
+	"volume.block.mount_options":  {Default: "discard", Description: "Mount options for block devices"},
+	"volume.security.encryption":  {Type: config.Bool, Default: "false", Description: "Encrypt new block volumes with LUKS"},
+	"volume.size":                 {Default: "unlimited (10GB for block)", Description: "Default volume size"},
+	"volume.zfs.block_mode":       {Type: config.Bool, Default: "false", Description: "Whether to back new container volumes with a formatted zvol rather than a dataset"},
+	"volume.zfs.remove_snapshots": {Type: config.Bool, Default: "false", Description: "Remove snapshots as needed"},
+	"volume.zfs.use_refquota":     {Type: config.Bool, Default: "false", Description: "Use refquota instead of quota for space."},
+	"zfs.clone_copy":              {Type: config.Bool, Default: "true", Description: "Whether to use ZFS lightweight clones rather than full dataset copies."},
+	"zfs.pool_name":               {Default: "name of the pool", Description: "Name of the zpool"},
+}
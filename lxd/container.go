@@ -3,14 +3,18 @@ package main
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"math/rand"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/pborman/uuid"
 	"github.com/pkg/errors"
 	lxc "gopkg.in/lxc/go-lxc.v2"
 	cron "gopkg.in/robfig/cron.v2"
@@ -18,6 +22,7 @@ import (
 	"github.com/flosch/pongo2"
 	"github.com/lxc/lxd/lxd/backup"
 	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/device"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
@@ -621,20 +626,54 @@ func instanceCreateAsCopy(s *state.State, args db.InstanceArgs, sourceInst insta
 }
 
 func instanceCreateAsSnapshot(s *state.State, args db.InstanceArgs, sourceInstance instance.Instance, op *operations.Operation) (instance.Instance, error) {
-	if sourceInstance.Type() != instancetype.Container {
-		return nil, fmt.Errorf("Instance is not container type")
-	}
-
 	if sourceInstance.Type() != args.Type {
 		return nil, fmt.Errorf("Source instance and snapshot instance types do not match")
 	}
 
+	// Quiesce running virtual machines so their filesystems are consistent in the snapshot.
+	// Stateful snapshots don't need it as the VM stays paused while the snapshot is taken, and
+	// restoring them would bring back the frozen filesystems.
+	if sourceInstance.Type() == instancetype.VM && sourceInstance.IsRunning() && shared.IsTrue(sourceInstance.ExpandedConfig()["snapshots.quiesce"]) && !args.Stateful {
+		vm := sourceInstance.(*qemu.Qemu)
+		err := vm.FreezeFilesystems()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to quiesce the instance's filesystems")
+		}
+
+		defer func() {
+			err := vm.ThawFilesystems()
+			if err != nil {
+				logger.Error("Failed to thaw the instance's filesystems", log.Ctx{"err": err, "instance": sourceInstance.Name(), "project": sourceInstance.Project()})
+			}
+		}()
+	}
+
 	// Deal with state.
 	if args.Stateful {
 		if !sourceInstance.IsRunning() {
 			return nil, fmt.Errorf("Unable to create a stateful snapshot. The instance isn't running")
 		}
 
+		if sourceInstance.Type() == instancetype.VM {
+			// The VM is paused until the snapshot is done so that its disks match its memory.
+			vm := sourceInstance.(*qemu.Qemu)
+			err := vm.SaveState()
+			if err != nil {
+				os.RemoveAll(sourceInstance.StatePath())
+				vm.Unfreeze()
+				return nil, errors.Wrap(err, "Failed to save the instance's state")
+			}
+
+			defer func() {
+				err := vm.Unfreeze()
+				if err != nil {
+					logger.Error("Failed to resume the instance", log.Ctx{"err": err, "instance": sourceInstance.Name(), "project": sourceInstance.Project()})
+				}
+			}()
+		}
+	}
+
+	if args.Stateful && sourceInstance.Type() == instancetype.Container {
 		_, err := exec.LookPath("criu")
 		if err != nil {
 			return nil, fmt.Errorf("Unable to create a stateful snapshot. CRIU isn't installed")
@@ -674,6 +713,15 @@ func instanceCreateAsSnapshot(s *state.State, args db.InstanceArgs, sourceInstan
 		}
 	}
 
+	// Snapshots get their own UUID rather than the one of their parent.
+	config := make(map[string]string, len(args.Config))
+	for key, value := range args.Config {
+		config[key] = value
+	}
+
+	config["volatile.uuid"] = uuid.New()
+	args.Config = config
+
 	// Create the snapshot.
 	inst, err := instanceCreateInternal(s, args)
 	if err != nil {
@@ -765,10 +813,17 @@ func instanceCreateInternal(s *state.State, args db.InstanceArgs) (instance.Inst
 		args.Config = map[string]string{}
 	}
 
+	configTranslateKeys(config.EntityInstance, args.Config)
+
 	if args.BaseImage != "" {
 		args.Config["volatile.base_image"] = args.BaseImage
 	}
 
+	// The UUID is kept across renames and moves, copies don't carry the volatile keys over.
+	if args.Config["volatile.uuid"] == "" {
+		args.Config["volatile.uuid"] = uuid.New()
+	}
+
 	if args.Devices == nil {
 		args.Devices = deviceConfig.Devices{}
 	}
@@ -794,6 +849,11 @@ func instanceCreateInternal(s *state.State, args db.InstanceArgs) (instance.Inst
 		return nil, err
 	}
 
+	err = instance.ValidBootAfter(s, args.Project, args.Name, args.Config)
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate container devices with the supplied container name and devices.
 	err = instanceValidDevices(s, s.Cluster, args.Type, args.Name, args.Devices, false)
 	if err != nil {
@@ -829,6 +889,14 @@ func instanceCreateInternal(s *state.State, args db.InstanceArgs) (instance.Inst
 		checkedProfiles = append(checkedProfiles, profile)
 	}
 
+	// Check the aggregate limits of the project.
+	if !args.Snapshot {
+		err = projectLimitsCheckInstance(s, args.Type, args.Name, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if args.CreationDate.IsZero() {
 		args.CreationDate = time.Now().UTC()
 	}
@@ -1043,6 +1111,33 @@ func instanceConfigureInternal(state *state.State, c instance.Instance) error {
 	return nil
 }
 
+// instanceOpQueued wraps the run function of an operation on an instance so that it first waits for
+// the other operations on the instance to be done.
+func instanceOpQueued(project string, name string, run func(op *operations.Operation) error) func(op *operations.Operation) error {
+	return func(op *operations.Operation) error {
+		err := op.WaitInstanceLock(project, name)
+		if err != nil {
+			return err
+		}
+
+		return run(op)
+	}
+}
+
+// instanceOpExclusive wraps the run function of an operation on an instance so that it fails if
+// another operation is running on the instance. Requests check operations.InstanceBusy beforehand
+// to report the conflict without creating an operation.
+func instanceOpExclusive(project string, name string, run func(op *operations.Operation) error) func(op *operations.Operation) error {
+	return func(op *operations.Operation) error {
+		err := op.LockInstance(project, name)
+		if err != nil {
+			return err
+		}
+
+		return run(op)
+	}
+}
+
 func instanceLoadByProject(s *state.State, project string) ([]instance.Instance, error) {
 	// Get all the containers
 	var cts []db.Instance
@@ -1224,29 +1319,14 @@ func autoCreateContainerSnapshotsTask(d *Daemon) (task.Func, task.Schedule) {
 				continue
 			}
 
-			// Extend our schedule to one that is accepted by the used cron parser
-			sched, err := cron.Parse(fmt.Sprintf("* %s", schedule))
-			if err != nil {
-				continue
-			}
-
-			// Check if it's time to snapshot
-			now := time.Now()
-
 			// Truncate the time now back to the start of the minute, before passing to
 			// the cron scheduler, as it will add 1s to the scheduled time and we don't
 			// want the next scheduled time to roll over to the next minute and break
-			// the time comparison below.
-			now = now.Truncate(time.Minute)
+			// the time comparison.
+			now := time.Now().Truncate(time.Minute)
 
-			// Calculate the next scheduled time based on the snapshots.schedule
-			// pattern and the time now.
-			next := sched.Next(now)
-
-			// Ignore everything that is more precise than minutes.
-			next = next.Truncate(time.Minute)
-
-			if !now.Equal(next) {
+			// Check if it's time to snapshot
+			if !instanceScheduledNow(c, schedule, now) {
 				continue
 			}
 
@@ -1297,9 +1377,78 @@ func autoCreateContainerSnapshotsTask(d *Daemon) (task.Func, task.Schedule) {
 	return f, schedule
 }
 
+// snapshotScheduleJitter is the maximum delay of a scheduled snapshot after the start of its minute.
+const snapshotScheduleJitter = 50 * time.Second
+
+// instanceScheduleSpecs returns the cron expressions of a schedule, which is either a single
+// expression or a comma separated list of aliases. The aliases run at a minute derived from the
+// project and name of the instance, so that they don't all fire at the same time.
+func instanceScheduleSpecs(inst instance.Instance, schedule string) []string {
+	if !strings.Contains(schedule, "@") {
+		return []string{schedule}
+	}
+
+	h := fnv.New32a()
+	io.WriteString(h, inst.Project())
+	io.WriteString(h, inst.Name())
+	minute := h.Sum32() % 60
+
+	specs := []string{}
+	for _, alias := range strings.Split(schedule, ",") {
+		switch strings.TrimSpace(alias) {
+		case "@hourly":
+			specs = append(specs, fmt.Sprintf("%d * * * *", minute))
+		case "@daily", "@midnight":
+			specs = append(specs, fmt.Sprintf("%d 0 * * *", minute))
+		case "@weekly":
+			specs = append(specs, fmt.Sprintf("%d 0 * * 0", minute))
+		case "@monthly":
+			specs = append(specs, fmt.Sprintf("%d 0 1 * *", minute))
+		case "@annually", "@yearly":
+			specs = append(specs, fmt.Sprintf("%d 0 1 1 *", minute))
+		}
+	}
+
+	return specs
+}
+
+// instanceScheduledNow returns whether the schedule of an instance is due at the given minute.
+func instanceScheduledNow(inst instance.Instance, schedule string, now time.Time) bool {
+	for _, spec := range instanceScheduleSpecs(inst, schedule) {
+		// Extend our schedule to one that is accepted by the used cron parser
+		sched, err := cron.Parse(fmt.Sprintf("* %s", spec))
+		if err != nil {
+			continue
+		}
+
+		// Ignore everything that is more precise than minutes.
+		if now.Equal(sched.Next(now).Truncate(time.Minute)) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func autoCreateContainerSnapshots(ctx context.Context, d *Daemon, instances []instance.Instance) error {
+	// Spread the snapshots randomly over the minute rather than starting them all at once, to
+	// avoid hitting the storage of big hosts with all of them at the same time.
+	start := time.Now()
+	delays := make([]time.Duration, len(instances))
+	for i := range delays {
+		delays[i] = time.Duration(rand.Int63n(int64(snapshotScheduleJitter)))
+	}
+
+	sort.Sort(snapshotJitter{instances: instances, delays: delays})
+
 	// Make the snapshots
-	for _, c := range instances {
+	for i, c := range instances {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(start.Add(delays[i]))):
+		}
+
 		ch := make(chan error)
 		go func() {
 			snapshotName, err := containerDetermineNextSnapshotName(d, c, "snap%d")
@@ -1349,6 +1498,25 @@ func autoCreateContainerSnapshots(ctx context.Context, d *Daemon, instances []in
 	return nil
 }
 
+// snapshotJitter sorts the scheduled instances by the delay of their snapshot.
+type snapshotJitter struct {
+	instances []instance.Instance
+	delays    []time.Duration
+}
+
+func (s snapshotJitter) Len() int {
+	return len(s.instances)
+}
+
+func (s snapshotJitter) Less(i, j int) bool {
+	return s.delays[i] < s.delays[j]
+}
+
+func (s snapshotJitter) Swap(i, j int) {
+	s.instances[i], s.instances[j] = s.instances[j], s.instances[i]
+	s.delays[i], s.delays[j] = s.delays[j], s.delays[i]
+}
+
 func pruneExpiredContainerSnapshotsTask(d *Daemon) (task.Func, task.Schedule) {
 	f := func(ctx context.Context) {
 		// Load all local instances
@@ -1438,8 +1606,16 @@ func containerDetermineNextSnapshotName(d *Daemon, c instance.Instance, defaultP
 		pattern = defaultPattern
 	}
 
+	now := time.Now()
 	pattern, err = shared.RenderTemplate(pattern, pongo2.Context{
-		"creation_date": time.Now(),
+		"creation_date":   now,
+		"creation_year":   now.Format("2006"),
+		"creation_month":  now.Format("01"),
+		"creation_day":    now.Format("02"),
+		"creation_hour":   now.Format("15"),
+		"creation_minute": now.Format("04"),
+		"instance":        c.Name(),
+		"project":         c.Project(),
 	})
 	if err != nil {
 		return "", err
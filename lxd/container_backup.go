@@ -184,7 +184,7 @@ func containerBackupsPost(d *Daemon, r *http.Request) response.Response {
 	resources["backups"] = []string{req.Name}
 
 	op, err := operations.OperationCreate(d.State(), project, operations.OperationClassTask,
-		db.OperationBackupCreate, resources, nil, backup, nil, nil)
+		db.OperationBackupCreate, resources, nil, instanceOpQueued(project, name, backup), nil, nil)
 	if err != nil {
 		return response.InternalError(err)
 	}
@@ -37,10 +37,15 @@ func containerDelete(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(fmt.Errorf("container is running"))
 	}
 
-	rmct := func(op *operations.Operation) error {
-		return c.Delete()
+	busy := operations.InstanceBusy(project, name)
+	if busy != nil {
+		return busy.Response()
 	}
 
+	rmct := instanceOpExclusive(project, name, func(op *operations.Operation) error {
+		return c.Delete()
+	})
+
 	resources := map[string][]string{}
 	resources["containers"] = []string{name}
 
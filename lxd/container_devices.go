@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/device"
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// containerDevicesValidatePost validates a proposed set of devices for an instance and reports
+// the changes applying it would make, without applying anything.
+func containerDevicesValidatePost(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	resp, err := ForwardedResponseIfContainerIsRemote(d, r, project, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+	if resp != nil {
+		return resp
+	}
+
+	req := api.InstanceDevicesValidatePost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	inst, err := instance.LoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	newDevices := deviceConfig.NewDevices(req.Devices)
+	err = instance.ValidDevices(d.State(), d.cluster, inst.Type(), inst.Name(), newDevices, true)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	return response.SyncResponse(true, instanceDevicesDiff(d.State(), inst, newDevices))
+}
+
+// instanceDevicesDiff returns the changes that replacing the expanded devices of the instance
+// with newDevices would make, using the same rules as instance updates.
+func instanceDevicesDiff(s *state.State, inst instance.Instance, newDevices deviceConfig.Devices) *api.InstanceDevicesValidate {
+	oldDevices := inst.ExpandedDevices()
+
+	removeDevices, addDevices, updateDevices, _ := oldDevices.Update(newDevices, func(oldDevice deviceConfig.Device, newDevice deviceConfig.Device) []string {
+		if oldDevice["type"] != newDevice["type"] || oldDevice["nictype"] != newDevice["nictype"] {
+			return []string{} // Device types aren't the same, so this cannot be an update.
+		}
+
+		dev, err := device.New(inst, s, "", newDevice, nil, nil)
+		if err != nil {
+			return []string{} // Couldn't create Device, so this cannot be an update.
+		}
+
+		_, updateFields := dev.CanHotPlug()
+		return updateFields
+	})
+
+	// Devices which can't be hot plugged can only be added or removed while stopped.
+	restartRequired := map[string]bool{}
+	canHotPlug := func(name string, config deviceConfig.Device) bool {
+		dev, err := device.New(inst, s, name, config, nil, nil)
+		if err != nil {
+			return false
+		}
+
+		canHotPlug, _ := dev.CanHotPlug()
+		return canHotPlug
+	}
+
+	result := api.InstanceDevicesValidate{
+		Added:           []string{},
+		Removed:         []string{},
+		Updated:         []string{},
+		RestartRequired: []string{},
+	}
+
+	for name, config := range removeDevices {
+		result.Removed = append(result.Removed, name)
+		if inst.IsRunning() && !canHotPlug(name, config) {
+			restartRequired[name] = true
+		}
+	}
+
+	for name, config := range addDevices {
+		result.Added = append(result.Added, name)
+		if inst.IsRunning() && !canHotPlug(name, config) {
+			restartRequired[name] = true
+		}
+	}
+
+	for name := range updateDevices {
+		result.Updated = append(result.Updated, name)
+	}
+
+	for name := range restartRequired {
+		result.RestartRequired = append(result.RestartRequired, name)
+	}
+
+	sort.Strings(result.Added)
+	sort.Strings(result.Removed)
+	sort.Strings(result.Updated)
+	sort.Strings(result.RestartRequired)
+
+	return &result
+}
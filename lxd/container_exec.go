@@ -47,6 +47,7 @@ type execWs struct {
 	uid              uint32
 	gid              uint32
 	cwd              string
+	recorder         *execRecorder
 }
 
 func (s *execWs) Metadata() interface{} {
@@ -111,7 +112,11 @@ func (s *execWs) Connect(op *operations.Operation, r *http.Request, w http.Respo
 func (s *execWs) Do(op *operations.Operation) error {
 	<-s.allConnected
 
-	var err error
+	err := s.recorder.Start(op.ID(), true, s.width, s.height)
+	if err != nil {
+		return err
+	}
+
 	var ttys []*os.File
 	var ptys []*os.File
 
@@ -249,7 +254,7 @@ func (s *execWs) Do(op *operations.Operation) error {
 			s.connsLock.Unlock()
 
 			logger.Debugf("Started mirroring websocket")
-			readDone, writeDone := netutils.WebsocketExecMirror(conn, ptys[0], ptys[0], attachedChildIsDead, int(ptys[0].Fd()))
+			readDone, writeDone := netutils.WebsocketExecMirror(conn, ptys[0], s.recorder.Wrap(ptys[0]), attachedChildIsDead, int(ptys[0].Fd()))
 
 			<-readDone
 			<-writeDone
@@ -275,7 +280,7 @@ func (s *execWs) Do(op *operations.Operation) error {
 					conn := s.conns[i]
 					s.connsLock.Unlock()
 
-					<-shared.WebsocketSendStream(conn, ptys[i], -1)
+					<-shared.WebsocketSendStream(conn, s.recorder.Wrap(ptys[i]), -1)
 					ptys[i].Close()
 					wgEOF.Done()
 				}
@@ -308,6 +313,8 @@ func (s *execWs) Do(op *operations.Operation) error {
 			pty.Close()
 		}
 
+		s.recorder.Finish(cmdResult)
+
 		metadata := shared.Jmap{"return": cmdResult}
 		err = op.UpdateMetadata(metadata)
 		if err != nil {
@@ -319,6 +326,7 @@ func (s *execWs) Do(op *operations.Operation) error {
 
 	cmd, err := s.instance.Exec(s.command, s.env, stdin, stdout, stderr, s.cwd, s.uid, s.gid)
 	if err != nil {
+		s.recorder.Finish(-1)
 		return err
 	}
 
@@ -329,6 +337,7 @@ func (s *execWs) Do(op *operations.Operation) error {
 
 	exitCode, err := cmd.Wait()
 	if err != nil {
+		s.recorder.Finish(-1)
 		return err
 	}
 
@@ -429,6 +438,12 @@ func containerExecPost(d *Daemon, r *http.Request) response.Response {
 		env["LANG"] = "C.UTF-8"
 	}
 
+	// Setup session recording if enabled for the project
+	recorder, err := execRecorderNew(d, r, inst, post)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
 	if post.WaitForWS {
 		ws := &execWs{}
 		ws.fds = map[int]string{}
@@ -472,6 +487,7 @@ func containerExecPost(d *Daemon, r *http.Request) response.Response {
 		ws.cwd = post.Cwd
 		ws.uid = post.User
 		ws.gid = post.Group
+		ws.recorder = recorder
 
 		resources := map[string][]string{}
 		resources["containers"] = []string{ws.instance.Name()}
@@ -487,6 +503,11 @@ func containerExecPost(d *Daemon, r *http.Request) response.Response {
 	run := func(op *operations.Operation) error {
 		metadata := shared.Jmap{}
 
+		err := recorder.Start(op.ID(), false, 0, 0)
+		if err != nil {
+			return err
+		}
+
 		if post.RecordOutput {
 			// Prepare stdout and stderr recording
 			stdout, err := os.OpenFile(filepath.Join(inst.LogPath(), fmt.Sprintf("exec_%s.stdout", op.ID())), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
@@ -504,14 +525,18 @@ func containerExecPost(d *Daemon, r *http.Request) response.Response {
 			// Run the command
 			cmd, err := inst.Exec(post.Command, env, nil, stdout, stderr, post.Cwd, post.User, post.Group)
 			if err != nil {
+				recorder.Finish(-1)
 				return err
 			}
 
 			exitCode, err := cmd.Wait()
 			if err != nil {
+				recorder.Finish(-1)
 				return err
 			}
 
+			recorder.Finish(exitCode)
+
 			// Update metadata with the right URLs
 			metadata["return"] = exitCode
 			metadata["output"] = shared.Jmap{
@@ -521,14 +546,18 @@ func containerExecPost(d *Daemon, r *http.Request) response.Response {
 		} else {
 			cmd, err := inst.Exec(post.Command, env, nil, nil, nil, post.Cwd, post.User, post.Group)
 			if err != nil {
+				recorder.Finish(-1)
 				return err
 			}
 
 			exitCode, err := cmd.Wait()
 			if err != nil {
+				recorder.Finish(-1)
 				return err
 			}
 
+			recorder.Finish(exitCode)
+
 			metadata["return"] = exitCode
 		}
 
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// execAuditLog is the name of the per-instance log file exec sessions are recorded to.
+const execAuditLog = "exec_audit.log"
+
+// Serializes writes to the audit logs.
+var execAuditLock sync.Mutex
+
+// execRecord is the audit entry written for every recorded exec session.
+type execRecord struct {
+	ID          string    `json:"id"`
+	Project     string    `json:"project"`
+	Instance    string    `json:"instance"`
+	Command     []string  `json:"command"`
+	User        uint32    `json:"user"`
+	Group       uint32    `json:"group"`
+	Cwd         string    `json:"cwd"`
+	Interactive bool      `json:"interactive"`
+	Requestor   string    `json:"requestor"`
+	Source      string    `json:"source"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	ExitCode    int       `json:"exit_code"`
+	Recording   string    `json:"recording,omitempty"`
+}
+
+// execRecorder keeps track of a single exec session for auditing purposes.
+// All its functions are no-ops when called on a nil recorder, which is what
+// execRecorderNew returns when recording isn't enabled for the project.
+type execRecorder struct {
+	inst   instance.Instance
+	stream bool
+	record execRecord
+	cast   *asciicastWriter
+	done   bool
+}
+
+// execRecorderNew returns a recorder for the exec request if recording is
+// enabled for the instance's project through "exec.record".
+func execRecorderNew(d *Daemon, r *http.Request, inst instance.Instance, post api.InstanceExecPost) (*execRecorder, error) {
+	var project *api.Project
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		project, err = tx.ProjectGet(inst.Project())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !shared.IsTrue(project.Config["exec.record"]) {
+		return nil, nil
+	}
+
+	requestor, _ := r.Context().Value("username").(string)
+
+	return &execRecorder{
+		inst:   inst,
+		stream: shared.IsTrue(project.Config["exec.record.stream"]),
+		record: execRecord{
+			Project:     inst.Project(),
+			Instance:    inst.Name(),
+			Command:     post.Command,
+			User:        post.User,
+			Group:       post.Group,
+			Cwd:         post.Cwd,
+			Interactive: post.Interactive,
+			Requestor:   requestor,
+			Source:      r.RemoteAddr,
+		},
+	}, nil
+}
+
+// Start marks the beginning of the session. If stream is true and stream
+// recording is enabled, the session output is recorded in asciicast format.
+func (r *execRecorder) Start(opID string, stream bool, width int, height int) error {
+	if r == nil {
+		return nil
+	}
+
+	r.record.ID = opID
+	r.record.StartedAt = time.Now().UTC()
+
+	if !stream || !r.stream {
+		return nil
+	}
+
+	path := filepath.Join(r.inst.LogPath(), fmt.Sprintf("exec_%s.cast", opID))
+	cast, err := asciicastWriterNew(path, r.record.StartedAt, width, height, strings.Join(r.record.Command, " "))
+	if err != nil {
+		return err
+	}
+
+	r.cast = cast
+	r.record.Recording = filepath.Base(path)
+
+	return nil
+}
+
+// Wrap returns a reader recording everything read from rc into the session
+// stream, if recorded.
+func (r *execRecorder) Wrap(rc io.ReadCloser) io.ReadCloser {
+	if r == nil || r.cast == nil {
+		return rc
+	}
+
+	return &execRecordReader{ReadCloser: rc, cast: r.cast}
+}
+
+// Finish writes the audit entry of the session. It may safely be called more
+// than once, only the first call is recorded.
+func (r *execRecorder) Finish(exitCode int) {
+	if r == nil || r.done {
+		return
+	}
+
+	r.done = true
+	r.record.FinishedAt = time.Now().UTC()
+	r.record.ExitCode = exitCode
+
+	if r.cast != nil {
+		r.cast.Close()
+	}
+
+	logger.Info("Exec session finished", log.Ctx{"project": r.record.Project, "instance": r.record.Instance, "command": r.record.Command, "requestor": r.record.Requestor, "source": r.record.Source, "exitCode": exitCode})
+
+	err := r.write()
+	if err != nil {
+		logger.Error("Failed to record exec session", log.Ctx{"project": r.record.Project, "instance": r.record.Instance, "err": err})
+	}
+}
+
+func (r *execRecorder) write() error {
+	data, err := json.Marshal(r.record)
+	if err != nil {
+		return err
+	}
+
+	execAuditLock.Lock()
+	defer execAuditLock.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(r.inst.LogPath(), execAuditLog), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// execRecordReader copies everything read into an asciicast stream.
+type execRecordReader struct {
+	io.ReadCloser
+	cast *asciicastWriter
+}
+
+func (r *execRecordReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.cast.Output(p[:n])
+	}
+
+	return n, err
+}
+
+// asciicastWriter writes a terminal session in the asciicast v2 format.
+type asciicastWriter struct {
+	f     *os.File
+	start time.Time
+	lock  sync.Mutex
+}
+
+func asciicastWriterNew(path string, start time.Time, width int, height int, command string) (*asciicastWriter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if width <= 0 || height <= 0 {
+		width = 80
+		height = 24
+	}
+
+	header, err := json.Marshal(map[string]interface{}{
+		"version":   2,
+		"width":     width,
+		"height":    height,
+		"timestamp": start.Unix(),
+		"command":   command,
+	})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	_, err = f.Write(append(header, '\n'))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &asciicastWriter{f: f, start: start}, nil
+}
+
+// Output records an output event.
+func (w *asciicastWriter) Output(data []byte) {
+	event, err := json.Marshal([]interface{}{time.Since(w.start).Seconds(), "o", string(data)})
+	if err != nil {
+		return
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.f.Write(append(event, '\n'))
+}
+
+// Close closes the underlying file.
+func (w *asciicastWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.f.Close()
+}
@@ -42,6 +42,18 @@ func containerFileHandler(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(fmt.Errorf("missing path argument"))
 	}
 
+	// Whole trees are transferred as tar streams.
+	if shared.IsTrue(r.FormValue("archive")) {
+		switch r.Method {
+		case "GET":
+			return containerFileArchiveGet(c, path)
+		case "POST":
+			return containerFileArchivePost(c, path, r)
+		default:
+			return response.NotFound(fmt.Errorf("Method '%s' not found", r.Method))
+		}
+	}
+
 	switch r.Method {
 	case "GET":
 		return containerFileGet(c, path, r)
@@ -160,3 +172,22 @@ func containerFileDelete(c instance.Instance, path string, r *http.Request) resp
 
 	return response.EmptySyncResponse
 }
+
+func containerFileArchiveGet(c instance.Instance, path string) response.Response {
+	headers := map[string]string{
+		"Content-Type": "application/x-tar",
+	}
+
+	return response.StreamResponse(headers, func(w io.Writer) error {
+		return c.FilePullArchive(path, w)
+	})
+}
+
+func containerFileArchivePost(c instance.Instance, path string, r *http.Request) response.Response {
+	err := c.FilePushArchive(r.Body, path)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
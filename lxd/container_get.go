@@ -4,8 +4,10 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared/api"
 )
 
 func containerGet(d *Daemon, r *http.Request) response.Response {
@@ -36,5 +38,19 @@ func containerGet(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
+	// With recursion=2, report where each expanded config key and device comes from.
+	if r.FormValue("recursion") == "2" {
+		inst, ok := state.(*api.Instance)
+		if ok {
+			profiles, err := d.cluster.ProfilesGet(project, c.Profiles())
+			if err != nil {
+				return response.SmartError(err)
+			}
+
+			inst.ExpandedConfigOrigin = db.ProfilesExpandConfigOrigin(c.LocalConfig(), profiles)
+			inst.ExpandedDevicesOrigin = db.ProfilesExpandDevicesOrigin(c.LocalDevices(), profiles)
+		}
+	}
+
 	return response.SyncResponseETag(true, state, etag)
 }
@@ -48,6 +48,7 @@ import (
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/containerwriter"
+	"github.com/lxc/lxd/shared/filearchive"
 	"github.com/lxc/lxd/shared/idmap"
 	log "github.com/lxc/lxd/shared/log15"
 	"github.com/lxc/lxd/shared/logger"
@@ -113,6 +114,12 @@ func lxcSetConfigItem(c *lxc.Container, key string, value string) error {
 		}
 	}
 
+	if strings.HasPrefix(key, "lxc.sysctl.") {
+		if !util.RuntimeLiblxcVersionAtLeast(3, 1, 0) {
+			return fmt.Errorf(`Sysctls require liblxc >= 3.1`)
+		}
+	}
+
 	err := c.SetConfigItem(key, value)
 	if err != nil {
 		return fmt.Errorf("Failed to set LXC config: %s=%s", key, value)
@@ -121,6 +128,17 @@ func lxcSetConfigItem(c *lxc.Container, key string, value string) error {
 	return nil
 }
 
+// statusCode returns the status of the container, reporting running containers whose guest
+// signaled readiness through devlxd as ready.
+func (c *containerLXC) statusCode(state lxc.State) api.StatusCode {
+	statusCode := lxcStatusCode(state)
+	if statusCode == api.Running && shared.IsTrue(c.localConfig["volatile.last_state.ready"]) {
+		return api.Ready
+	}
+
+	return statusCode
+}
+
 func lxcStatusCode(state lxc.State) api.StatusCode {
 	return map[int]api.StatusCode{
 		1: api.Stopped,
@@ -232,7 +250,7 @@ func containerLXCCreate(s *state.State, args db.InstanceArgs) (instance.Instance
 	}
 
 	// Create a new database entry for the container's storage volume
-	_, err = s.Cluster.StoragePoolVolumeCreate(args.Project, args.Name, "", storagePoolVolumeTypeContainer, c.IsSnapshot(), poolID, volumeConfig)
+	_, err = s.Cluster.StoragePoolVolumeCreate(args.Project, args.Name, "", storagePoolVolumeTypeContainer, c.IsSnapshot(), poolID, volumeConfig, db.StoragePoolVolumeContentTypeFS)
 	if err != nil {
 		c.Delete()
 		return nil, err
@@ -264,6 +282,7 @@ func containerLXCCreate(s *state.State, args db.InstanceArgs) (instance.Instance
 	if !c.IsPrivileged() {
 		idmap, base, err = findIdmap(
 			s,
+			args.Project,
 			args.Name,
 			c.expandedConfig["security.idmap.isolated"],
 			c.expandedConfig["security.idmap.base"],
@@ -500,7 +519,10 @@ func idmapSize(state *state.State, isolatedStr string, size string) (int64, erro
 
 var idmapLock sync.Mutex
 
-func findIdmap(state *state.State, cName string, isolatedStr string, configBase string, configSize string, rawIdmap string) (*idmap.IdmapSet, int64, error) {
+// idmapProjectSize is the default size of the uid/gid range of projects with security.idmap.isolated.
+const idmapProjectSize = 65536
+
+func findIdmap(state *state.State, cProject string, cName string, isolatedStr string, configBase string, configSize string, rawIdmap string) (*idmap.IdmapSet, int64, error) {
 	isolated := false
 	if shared.IsTrue(isolatedStr) {
 		isolated = true
@@ -511,7 +533,38 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 		return nil, 0, err
 	}
 
+	mkIdmap := func(offset int64, size int64) (*idmap.IdmapSet, error) {
+		set := &idmap.IdmapSet{Idmap: []idmap.IdmapEntry{
+			{Isuid: true, Nsid: 0, Hostid: offset, Maprange: size},
+			{Isgid: true, Nsid: 0, Hostid: offset, Maprange: size},
+		}}
+
+		for _, ent := range rawMaps {
+			err := set.AddSafe(ent)
+			if err != nil && err == idmap.ErrHostIdIsSubId {
+				return nil, err
+			}
+		}
+
+		return set, nil
+	}
+
 	if !isolated {
+		// Containers of projects with their own range share it.
+		offset, size, err := idmapProjectRange(state, cProject)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if size > 0 {
+			set, err := mkIdmap(offset, size)
+			if err != nil && err == idmap.ErrHostIdIsSubId {
+				return nil, 0, err
+			}
+
+			return set, offset, nil
+		}
+
 		newIdmapset := idmap.IdmapSet{Idmap: make([]idmap.IdmapEntry, len(state.OS.IdmapSet.Idmap))}
 		copy(newIdmapset.Idmap, state.OS.IdmapSet.Idmap)
 
@@ -530,22 +583,6 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 		return nil, 0, err
 	}
 
-	mkIdmap := func(offset int64, size int64) (*idmap.IdmapSet, error) {
-		set := &idmap.IdmapSet{Idmap: []idmap.IdmapEntry{
-			{Isuid: true, Nsid: 0, Hostid: offset, Maprange: size},
-			{Isgid: true, Nsid: 0, Hostid: offset, Maprange: size},
-		}}
-
-		for _, ent := range rawMaps {
-			err := set.AddSafe(ent)
-			if err != nil && err == idmap.ErrHostIdIsSubId {
-				return nil, err
-			}
-		}
-
-		return set, nil
-	}
-
 	if configBase != "" {
 		offset, err := strconv.ParseInt(configBase, 10, 64)
 		if err != nil {
@@ -563,11 +600,100 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 	idmapLock.Lock()
 	defer idmapLock.Unlock()
 
-	cts, err := instanceLoadAll(state)
+	offset, err := idmapAllocate(state, cName, size)
 	if err != nil {
 		return nil, 0, err
 	}
 
+	set, err := mkIdmap(offset, size)
+	if err != nil && err == idmap.ErrHostIdIsSubId {
+		return nil, 0, err
+	}
+
+	return set, offset, nil
+}
+
+// idmapProjectRange returns the uid/gid range dedicated to a project with security.idmap.isolated,
+// allocating it on first use and recording its base in the project's volatile.idmap.base key. A
+// zero size is returned for projects using the default range.
+func idmapProjectRange(state *state.State, projectName string) (int64, int64, error) {
+	var project *api.Project
+	err := state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		project, err = tx.ProjectGet(projectName)
+		return err
+	})
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "Failed to load project %q", projectName)
+	}
+
+	if !shared.IsTrue(project.Config["security.idmap.isolated"]) {
+		return 0, 0, nil
+	}
+
+	size, err := idmapProjectConfigSize(project.Config)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if project.Config["volatile.idmap.base"] != "" {
+		offset, err := strconv.ParseInt(project.Config["volatile.idmap.base"], 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		return offset, size, nil
+	}
+
+	idmapLock.Lock()
+	defer idmapLock.Unlock()
+
+	offset, err := idmapAllocate(state, "", size)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		// Reload the project, another member may have allocated its range meanwhile.
+		project, err := tx.ProjectGet(projectName)
+		if err != nil {
+			return err
+		}
+
+		if project.Config["volatile.idmap.base"] != "" {
+			offset, err = strconv.ParseInt(project.Config["volatile.idmap.base"], 10, 64)
+			return err
+		}
+
+		project.Config["volatile.idmap.base"] = fmt.Sprintf("%d", offset)
+		return tx.ProjectUpdate(projectName, project.Writable())
+	})
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "Failed to record the uid/gid range of project %q", projectName)
+	}
+
+	return offset, size, nil
+}
+
+// idmapProjectConfigSize returns the size of the uid/gid range of a project with
+// security.idmap.isolated.
+func idmapProjectConfigSize(config map[string]string) (int64, error) {
+	if config["security.idmap.size"] == "" {
+		return idmapProjectSize, nil
+	}
+
+	return strconv.ParseInt(config["security.idmap.size"], 10, 64)
+}
+
+// idmapAllocate finds the first free host uid/gid range of the given size, not overlapping with
+// the ranges of the containers using security.idmap.isolated (other than cName) nor with the ones
+// of projects using security.idmap.isolated. Must be called with idmapLock held.
+func idmapAllocate(state *state.State, cName string, size int64) (int64, error) {
+	cts, err := instanceLoadAll(state)
+	if err != nil {
+		return 0, err
+	}
+
 	offset := state.OS.IdmapSet.Idmap[0].Hostid + 65536
 
 	mapentries := idmap.ByHostid{}
@@ -595,18 +721,46 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 		if container.ExpandedConfig()["volatile.idmap.base"] != "" {
 			cBase, err = strconv.ParseInt(container.ExpandedConfig()["volatile.idmap.base"], 10, 64)
 			if err != nil {
-				return nil, 0, err
+				return 0, err
 			}
 		}
 
 		cSize, err := idmapSize(state, container.ExpandedConfig()["security.idmap.isolated"], container.ExpandedConfig()["security.idmap.size"])
 		if err != nil {
-			return nil, 0, err
+			return 0, err
 		}
 
 		mapentries = append(mapentries, &idmap.IdmapEntry{Hostid: int64(cBase), Maprange: cSize})
 	}
 
+	var projects []api.Project
+	err = state.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		projects, err = tx.ProjectList(db.ProjectFilter{})
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, project := range projects {
+		if !shared.IsTrue(project.Config["security.idmap.isolated"]) || project.Config["volatile.idmap.base"] == "" {
+			continue
+		}
+
+		pBase, err := strconv.ParseInt(project.Config["volatile.idmap.base"], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+
+		pSize, err := idmapProjectConfigSize(project.Config)
+		if err != nil {
+			return 0, err
+		}
+
+		mapentries = append(mapentries, &idmap.IdmapEntry{Hostid: pBase, Maprange: pSize})
+	}
+
 	sort.Sort(mapentries)
 
 	for i := range mapentries {
@@ -616,12 +770,7 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 				continue
 			}
 
-			set, err := mkIdmap(offset, size)
-			if err != nil && err == idmap.ErrHostIdIsSubId {
-				return nil, 0, err
-			}
-
-			return set, offset, nil
+			return offset, nil
 		}
 
 		if mapentries[i-1].Hostid+mapentries[i-1].Maprange > offset {
@@ -631,26 +780,16 @@ func findIdmap(state *state.State, cName string, isolatedStr string, configBase
 
 		offset = mapentries[i-1].Hostid + mapentries[i-1].Maprange
 		if offset+size < mapentries[i].Hostid {
-			set, err := mkIdmap(offset, size)
-			if err != nil && err == idmap.ErrHostIdIsSubId {
-				return nil, 0, err
-			}
-
-			return set, offset, nil
+			return offset, nil
 		}
 		offset = mapentries[i].Hostid + mapentries[i].Maprange
 	}
 
 	if offset+size < state.OS.IdmapSet.Idmap[0].Hostid+state.OS.IdmapSet.Idmap[0].Maprange {
-		set, err := mkIdmap(offset, size)
-		if err != nil && err == idmap.ErrHostIdIsSubId {
-			return nil, 0, err
-		}
-
-		return set, offset, nil
+		return offset, nil
 	}
 
-	return nil, 0, fmt.Errorf("Not enough uid/gid available for the container")
+	return 0, fmt.Errorf("Not enough uid/gid available for the container")
 }
 
 func (c *containerLXC) init() error {
@@ -1050,9 +1189,20 @@ func (c *containerLXC) initLXC(config bool) error {
 			return fmt.Errorf("The NVIDIA container tools couldn't be found")
 		}
 
-		err = lxcSetConfigItem(cc, "lxc.environment", "NVIDIA_VISIBLE_DEVICES=none")
-		if err != nil {
-			return err
+		// MIG GPU devices set the visible devices themselves.
+		hasMig := false
+		for _, dev := range c.expandedDevices {
+			if dev["type"] == "gpu" && dev["gputype"] == "mig" {
+				hasMig = true
+				break
+			}
+		}
+
+		if !hasMig {
+			err = lxcSetConfigItem(cc, "lxc.environment", "NVIDIA_VISIBLE_DEVICES=none")
+			if err != nil {
+				return err
+			}
 		}
 
 		nvidiaDriver := c.expandedConfig["nvidia.driver.capabilities"]
@@ -1125,21 +1275,18 @@ func (c *containerLXC) initLXC(config bool) error {
 					return err
 				}
 			} else {
-				if c.state.OS.CGInfo.Supports(cgroup.MemorySwap, cg) && (memorySwap == "" || shared.IsTrue(memorySwap)) {
-					err = cg.SetMemoryMaxUsage(fmt.Sprintf("%d", valueInt))
-					if err != nil {
-						return err
-					}
-					err = cg.SetMemorySwapMax(fmt.Sprintf("%d", valueInt))
-					if err != nil {
-						return err
-					}
-				} else {
-					err = cg.SetMemoryMaxUsage(fmt.Sprintf("%d", valueInt))
+				err = cg.SetMemoryMaxUsage(fmt.Sprintf("%d", valueInt))
+				if err != nil {
+					return err
+				}
+
+				if c.state.OS.CGInfo.Supports(cgroup.MemorySwap, cg) {
+					err = cg.SetMemorySwapLimit(fmt.Sprintf("%d", valueInt), memorySwap == "" || shared.IsTrue(memorySwap))
 					if err != nil {
 						return err
 					}
 				}
+
 				// Set soft limit to value 10% less than hard limit
 				err = cg.SetMemorySoftLimit(fmt.Sprintf("%.0f", float64(valueInt)*0.9))
 				if err != nil {
@@ -1185,18 +1332,20 @@ func (c *containerLXC) initLXC(config bool) error {
 			}
 		}
 
-		if cpuCfsPeriod != "-1" {
-			err = cg.SetCPUCfsPeriod(cpuCfsPeriod)
+		if cpuCfsQuota != "-1" {
+			err = cg.SetCPUCfsLimit(cpuCfsQuota, cpuCfsPeriod)
 			if err != nil {
 				return err
 			}
 		}
+	}
 
-		if cpuCfsQuota != "-1" {
-			err = cg.SetCPUCfsQuota(cpuCfsQuota)
-			if err != nil {
-				return err
-			}
+	// NUMA memory placement
+	cpuNodes := c.expandedConfig["limits.cpu.nodes"]
+	if cpuNodes != "" && c.state.OS.CGInfo.Supports(cgroup.CPUSet, cg) {
+		err = cg.SetCPUSetMems(cpuNodes)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -1228,6 +1377,17 @@ func (c *containerLXC) initLXC(config bool) error {
 		}
 	}
 
+	// Setup sysctls
+	for k, v := range c.expandedConfig {
+		if strings.HasPrefix(k, "linux.sysctl.") {
+			sysctlKey := fmt.Sprintf("lxc.sysctl.%s", strings.TrimPrefix(k, "linux.sysctl."))
+			err = lxcSetConfigItem(cc, sysctlKey, v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	// Setup shmounts
 	if c.state.OS.LXCFeatures["mount_injection_file"] {
 		err = lxcSetConfigItem(cc, "lxc.mount.auto", fmt.Sprintf("shmounts:%s:/dev/.lxd-mounts", c.ShmountsPath()))
@@ -1620,8 +1780,14 @@ func (c *containerLXC) deviceHandleMounts(mounts []deviceConfig.MountEntryItem)
 				}
 			}
 
+			// Dynamic shifting is only needed for unprivileged containers, in which case the
+			// shiftfs mount is setup through the container's mount namespace by forkmount.
 			shiftfs := false
-			if mount.OwnerShift == deviceConfig.MountOwnerShiftDynamic {
+			if mount.OwnerShift == deviceConfig.MountOwnerShiftDynamic && !c.IsPrivileged() {
+				if !c.state.OS.Shiftfs {
+					return fmt.Errorf("shiftfs is required but isn't supported on system")
+				}
+
 				shiftfs = true
 			}
 
@@ -1769,6 +1935,14 @@ func (c *containerLXC) DeviceEventHandler(runConf *deviceConfig.RunConfig) error
 		}
 	}
 
+	// Apply network interface changes if requested.
+	if len(runConf.NetworkInterface) > 0 {
+		err := c.deviceUpdateNetworkInterface(runConf.NetworkInterface)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Run any post hooks requested.
 	err := c.runHooks(runConf.PostHooks)
 	if err != nil {
@@ -2007,6 +2181,14 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 		}
 	}
 
+	// Wait for the guest to report being ready again
+	if c.localConfig["volatile.last_state.ready"] != "" {
+		err = c.VolatileSet(map[string]string{"volatile.last_state.ready": ""})
+		if err != nil {
+			return "", postStartHooks, errors.Wrapf(err, "Reset volatile.last_state.ready config key on container %q (id %d)", c.name, c.id)
+		}
+	}
+
 	// Generate the Seccomp profile
 	if err := seccomp.CreateProfile(c); err != nil {
 		return "", postStartHooks, err
@@ -2034,6 +2216,7 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 
 	// Create the devices
 	nicID := -1
+	nvidiaDevices := []string{}
 
 	// Setup devices in sorted order, this ensures that device mounts are added in path order.
 	for _, dev := range c.expandedDevices.Sorted() {
@@ -2102,7 +2285,12 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 		// Pass any cgroups rules into LXC.
 		if len(runConf.CGroups) > 0 {
 			for _, rule := range runConf.CGroups {
-				err = lxcSetConfigItem(c.c, fmt.Sprintf("lxc.cgroup.%s", rule.Key), rule.Value)
+				lxcKey := fmt.Sprintf("lxc.cgroup.%s", rule.Key)
+				if c.state.OS.CGInfo.KeyVersion(rule.Key) == cgroup.V2 {
+					lxcKey = fmt.Sprintf("lxc.cgroup2.%s", rule.Key)
+				}
+
+				err = lxcSetConfigItem(c.c, lxcKey, rule.Value)
 				if err != nil {
 					return "", postStartHooks, errors.Wrapf(err, "Failed to setup device cgroup '%s'", dev.Name)
 				}
@@ -2145,6 +2333,19 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 			}
 		}
 
+		// Pass any GPU environment variables into LXC.
+		for _, gpuItem := range runConf.GPUDevice {
+			switch gpuItem.Key {
+			case "environment":
+				err = lxcSetConfigItem(c.c, "lxc.environment", gpuItem.Value)
+				if err != nil {
+					return "", postStartHooks, errors.Wrapf(err, "Failed to setup device environment '%s'", dev.Name)
+				}
+			case "mig":
+				nvidiaDevices = append(nvidiaDevices, gpuItem.Value)
+			}
+		}
+
 		// Pass any network setup config into LXC.
 		if len(runConf.NetworkInterface) > 0 {
 			// Increment nicID so that LXC network index is unique per device.
@@ -2169,6 +2370,14 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 		}
 	}
 
+	// Let the NVIDIA runtime expose the requested MIG instances.
+	if len(nvidiaDevices) > 0 {
+		err = lxcSetConfigItem(c.c, "lxc.environment", fmt.Sprintf("NVIDIA_VISIBLE_DEVICES=%s", strings.Join(nvidiaDevices, ",")))
+		if err != nil {
+			return "", postStartHooks, errors.Wrapf(err, "Failed to setup NVIDIA visible devices")
+		}
+	}
+
 	// Rotate the log file
 	logfile := c.LogFilePath()
 	if shared.PathExists(logfile) {
@@ -2268,6 +2477,34 @@ func (c *containerLXC) detachInterfaceRename(netns string, ifName string, hostNa
 	return nil
 }
 
+// deviceUpdateNetworkInterface applies changed settings to the named interface inside the running
+// container. Only the mtu setting can currently be changed.
+func (c *containerLXC) deviceUpdateNetworkInterface(items []deviceConfig.RunConfigItem) error {
+	ifName := ""
+	mtu := ""
+	for _, item := range items {
+		switch item.Key {
+		case "name":
+			ifName = item.Value
+		case "mtu":
+			mtu = item.Value
+		}
+	}
+
+	if ifName == "" {
+		return fmt.Errorf("Missing interface name")
+	}
+
+	if mtu != "" {
+		_, err := shared.RunCommand(c.state.OS.ExecPath, "forknet", "set-mtu", fmt.Sprintf("%d", c.InitPID()), ifName, mtu)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to set the MTU of %q", ifName)
+		}
+	}
+
+	return nil
+}
+
 func (c *containerLXC) Start(stateful bool) error {
 	var ctxMap log.Ctx
 
@@ -3003,6 +3240,7 @@ func (c *containerLXC) Render() (interface{}, interface{}, error) {
 			LastUsedAt:      c.lastUsedDate,
 			Name:            strings.SplitN(c.name, "/", 2)[1],
 			Stateful:        c.stateful,
+			UUID:            c.localConfig["volatile.uuid"],
 		}
 		ct.Architecture = architectureName
 		ct.Config = c.localConfig
@@ -3022,7 +3260,7 @@ func (c *containerLXC) Render() (interface{}, interface{}, error) {
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "Get container stated")
 	}
-	statusCode := lxcStatusCode(cState)
+	statusCode := c.statusCode(cState)
 
 	ct := api.Instance{
 		ExpandedConfig:  c.expandedConfig,
@@ -3032,6 +3270,7 @@ func (c *containerLXC) Render() (interface{}, interface{}, error) {
 		StatusCode:      statusCode,
 		Location:        c.node,
 		Type:            c.Type().String(),
+		UUID:            c.localConfig["volatile.uuid"],
 	}
 
 	ct.Description = c.description
@@ -3110,7 +3349,7 @@ func (c *containerLXC) RenderState() (*api.InstanceState, error) {
 	if err != nil {
 		return nil, err
 	}
-	statusCode := lxcStatusCode(cState)
+	statusCode := c.statusCode(cState)
 	status := api.InstanceState{
 		Status:     statusCode.String(),
 		StatusCode: statusCode,
@@ -3310,10 +3549,17 @@ func (c *containerLXC) Restore(sourceContainer instance.Instance, stateful bool)
 		}
 	}
 
-	// Restore the configuration.
+	// Restore the configuration, the container keeps its own UUID.
+	config := make(map[string]string, len(sourceContainer.LocalConfig()))
+	for key, value := range sourceContainer.LocalConfig() {
+		config[key] = value
+	}
+
+	config["volatile.uuid"] = c.localConfig["volatile.uuid"]
+
 	args := db.InstanceArgs{
 		Architecture: sourceContainer.Architecture(),
-		Config:       sourceContainer.LocalConfig(),
+		Config:       config,
 		Description:  sourceContainer.Description(),
 		Devices:      sourceContainer.LocalDevices(),
 		Ephemeral:    sourceContainer.IsEphemeral(),
@@ -3556,11 +3802,11 @@ func (c *containerLXC) Delete() error {
 			}
 		}
 
-		// Delete the MAAS entry.
+		// Delete the MAAS entry. The storage is already gone at this point so a failure
+		// doesn't stop the deletion, a leftover entry gets updated if the name is reused.
 		err = c.maasDelete()
 		if err != nil {
 			logger.Error("Failed deleting container MAAS record", log.Ctx{"name": c.Name(), "err": err})
-			return err
 		}
 
 		// Remove devices from container.
@@ -4095,6 +4341,13 @@ func (c *containerLXC) Update(args db.InstanceArgs, userRequested bool) error {
 		return errors.Wrap(err, "Invalid expanded config")
 	}
 
+	if c.expandedConfig["boot.after"] != oldExpandedConfig["boot.after"] {
+		err = instance.ValidBootAfter(c.state, c.project, c.name, c.expandedConfig)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Do full expanded validation of the devices diff.
 	err = instanceValidDevices(c.state, c.state.Cluster, c.Type(), c.Name(), c.expandedDevices, true)
 	if err != nil {
@@ -4132,6 +4385,7 @@ func (c *containerLXC) Update(args db.InstanceArgs, userRequested bool) error {
 			// update the idmap
 			idmap, base, err = findIdmap(
 				c.state,
+				c.Project(),
 				c.Name(),
 				c.expandedConfig["security.idmap.isolated"],
 				c.expandedConfig["security.idmap.base"],
@@ -4240,7 +4494,7 @@ func (c *containerLXC) Update(args db.InstanceArgs, userRequested bool) error {
 					priority = 10
 				}
 
-				cg.SetBlkioWeight(fmt.Sprintf("%d", priority))
+				err = cg.SetBlkioWeight(fmt.Sprintf("%d", priority))
 				if err != nil {
 					return err
 				}
@@ -4337,19 +4591,14 @@ func (c *containerLXC) Update(args db.InstanceArgs, userRequested bool) error {
 						return err
 					}
 				} else {
-					if c.state.OS.CGInfo.Supports(cgroup.MemorySwap, cg) && (memorySwap == "" || shared.IsTrue(memorySwap)) {
-						err = cg.SetMemoryMaxUsage(memory)
-						if err != nil {
-							revertMemory()
-							return err
-						}
-						err = cg.SetMemorySwapMax(memory)
-						if err != nil {
-							revertMemory()
-							return err
-						}
-					} else {
-						err = cg.SetMemoryMaxUsage(memory)
+					err = cg.SetMemoryMaxUsage(memory)
+					if err != nil {
+						revertMemory()
+						return err
+					}
+
+					if c.state.OS.CGInfo.Supports(cgroup.MemorySwap, cg) {
+						err = cg.SetMemorySwapLimit(memory, memorySwap == "" || shared.IsTrue(memorySwap))
 						if err != nil {
 							revertMemory()
 							return err
@@ -4402,6 +4651,29 @@ func (c *containerLXC) Update(args db.InstanceArgs, userRequested bool) error {
 					return err
 				}
 			} else if key == "limits.cpu" {
+				// Trigger a scheduler re-run
+				cgroup.TaskSchedulerTrigger("container", c.name, "changed")
+			} else if key == "limits.cpu.nodes" {
+				// Skip if no cpuset CGroup
+				if !c.state.OS.CGInfo.Supports(cgroup.CPUSet, cg) {
+					continue
+				}
+
+				// An empty value inherits the NUMA nodes of the parent with cgroup2.
+				mems := value
+				version, _ := c.state.OS.CGInfo.SupportsVersion(cgroup.CPUSet)
+				if mems == "" && version == cgroup.V1 {
+					mems, err = cGroupGet("cpuset", "/lxc", "cpuset.mems")
+					if err != nil {
+						return err
+					}
+				}
+
+				err = cg.SetCPUSetMems(mems)
+				if err != nil {
+					return err
+				}
+
 				// Trigger a scheduler re-run
 				cgroup.TaskSchedulerTrigger("container", c.name, "changed")
 			} else if key == "limits.cpu.priority" || key == "limits.cpu.allowance" {
@@ -4419,12 +4691,8 @@ func (c *containerLXC) Update(args db.InstanceArgs, userRequested bool) error {
 				if err != nil {
 					return err
 				}
-				err = cg.SetCPUCfsPeriod(cpuCfsPeriod)
-				if err != nil {
-					return err
-				}
 
-				err = cg.SetCPUCfsQuota(cpuCfsQuota)
+				err = cg.SetCPUCfsLimit(cpuCfsQuota, cpuCfsPeriod)
 				if err != nil {
 					return err
 				}
@@ -4906,6 +5174,52 @@ type CriuMigrationArgs struct {
 	dumpDir      string
 	preDumpDir   string
 	features     lxc.CriuFeatures
+
+	// Options which liblxc doesn't expose, passed to CRIU through a configuration file.
+	tcpEstablished bool
+	lazyPages      bool
+	pageServerPort int
+}
+
+// criuEnvLock protects the CRIU_CONFIG_FILE environment variable which in-process liblxc calls
+// pass on to CRIU.
+var criuEnvLock sync.RWMutex
+
+// criuConfigFile writes the CRIU configuration file holding the options of args, returning an
+// empty path if there are none.
+func criuConfigFile(args *CriuMigrationArgs) (string, error) {
+	options := []string{}
+	if args.tcpEstablished {
+		options = append(options, "tcp-established")
+	}
+
+	if args.lazyPages {
+		options = append(options, "lazy-pages")
+
+		// Dumps serve the memory pages from a page server, restores fetch them from the
+		// lazy-pages daemon.
+		if args.cmd == lxc.MIGRATE_DUMP {
+			options = append(options, "address 127.0.0.1", fmt.Sprintf("port %d", args.pageServerPort))
+		}
+	}
+
+	if len(options) == 0 {
+		return "", nil
+	}
+
+	f, err := ioutil.TempFile("", "lxd_criu_")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(strings.Join(options, "\n") + "\n")
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
 }
 
 func (c *containerLXC) Migrate(args *CriuMigrationArgs) error {
@@ -4919,7 +5233,9 @@ func (c *containerLXC) Migrate(args *CriuMigrationArgs) error {
 		"actionscript": args.actionScript,
 		"predumpdir":   args.preDumpDir,
 		"features":     args.features,
-		"stop":         args.stop}
+		"stop":         args.stop,
+		"tcp":          args.tcpEstablished,
+		"lazypages":    args.lazyPages}
 
 	_, err := exec.LookPath("criu")
 	if err != nil {
@@ -4968,6 +5284,21 @@ func (c *containerLXC) Migrate(args *CriuMigrationArgs) error {
 		preservesInodes = false
 	}
 
+	// Stateful stop and snapshots follow the container's configuration while migrations use
+	// what was negotiated with the other side.
+	if args.function == "snapshot" {
+		args.tcpEstablished = shared.IsTrue(c.expandedConfig["migration.criu.tcp_established"])
+	}
+
+	configFile, err := criuConfigFile(args)
+	if err != nil {
+		return errors.Wrap(err, "Failed to write CRIU configuration")
+	}
+
+	if configFile != "" {
+		defer os.Remove(configFile)
+	}
+
 	finalStateDir := args.stateDir
 	var migrateErr error
 
@@ -5029,7 +5360,13 @@ func (c *containerLXC) Migrate(args *CriuMigrationArgs) error {
 			finalStateDir = fmt.Sprintf("%s/%s", args.stateDir, args.dumpDir)
 		}
 
-		_, migrateErr = shared.RunCommand(
+		var env []string
+		if configFile != "" {
+			env = append(os.Environ(), fmt.Sprintf("CRIU_CONFIG_FILE=%s", configFile))
+		}
+
+		_, _, migrateErr = shared.RunCommandSplit(
+			env,
 			c.state.OS.ExecPath,
 			"forkmigrate",
 			c.name,
@@ -5056,7 +5393,10 @@ func (c *containerLXC) Migrate(args *CriuMigrationArgs) error {
 		opts := lxc.MigrateOptions{
 			FeaturesToCheck: args.features,
 		}
+
+		criuEnvLock.RLock()
 		migrateErr = c.c.Migrate(args.cmd, opts)
+		criuEnvLock.RUnlock()
 		if migrateErr != nil {
 			logger.Info("CRIU feature check failed", ctxMap)
 			return migrateErr
@@ -5102,7 +5442,19 @@ func (c *containerLXC) Migrate(args *CriuMigrationArgs) error {
 			args.stop = false
 		}
 
-		migrateErr = c.c.Migrate(args.cmd, opts)
+		if configFile != "" {
+			// The environment is shared with the other CRIU calls, which have to wait for
+			// this one to complete (this includes serving the lazy pages).
+			criuEnvLock.Lock()
+			os.Setenv("CRIU_CONFIG_FILE", configFile)
+			migrateErr = c.c.Migrate(args.cmd, opts)
+			os.Unsetenv("CRIU_CONFIG_FILE")
+			criuEnvLock.Unlock()
+		} else {
+			criuEnvLock.RLock()
+			migrateErr = c.c.Migrate(args.cmd, opts)
+			criuEnvLock.RUnlock()
+		}
 	}
 
 	collectErr := collectCRIULogFile(c, finalStateDir, args.function, prettyCmd)
@@ -5196,6 +5548,9 @@ func (c *containerLXC) templateApplyNow(trigger string) error {
 		containerMeta["privileged"] = "false"
 	}
 
+	// Let the cloud-init.* keys override the user.* keys used by image templates.
+	templateConfig := shared.CloudInitTemplateConfig(c.expandedConfig)
+
 	// Go through the templates
 	for tplPath, tpl := range metadata.Templates {
 		var w *os.File
@@ -5256,7 +5611,7 @@ func (c *containerLXC) templateApplyNow(trigger string) error {
 		}
 
 		configGet := func(confKey, confDefault *pongo2.Value) *pongo2.Value {
-			val, ok := c.expandedConfig[confKey.String()]
+			val, ok := templateConfig[confKey.String()]
 			if !ok {
 				return confDefault
 			}
@@ -5268,7 +5623,7 @@ func (c *containerLXC) templateApplyNow(trigger string) error {
 		tplRender.ExecuteWriter(pongo2.Context{"trigger": trigger,
 			"path":       tplPath,
 			"container":  containerMeta,
-			"config":     c.expandedConfig,
+			"config":     templateConfig,
 			"devices":    c.expandedDevices,
 			"properties": tpl.Properties,
 			"config_get": configGet}, w)
@@ -5599,6 +5954,212 @@ func (c *containerLXC) FileRemove(path string) error {
 	return nil
 }
 
+// FilePullArchive writes the tree at srcpath in the container to w as a tar stream.
+func (c *containerLXC) FilePullArchive(srcpath string, w io.Writer) error {
+	idmapset, err := c.fileArchiveIdmap()
+	if err != nil {
+		return err
+	}
+
+	if idmapset == nil {
+		return c.fileArchive("archive", srcpath, nil, w)
+	}
+
+	// Unmap the uid and gid of the entries on the way out.
+	pr, pw := io.Pipe()
+	chShift := make(chan error, 1)
+	go func() {
+		err := filearchive.Shift(pr, w, idmapset.ShiftFromNs)
+		if err == nil {
+			_, err = io.Copy(ioutil.Discard, pr)
+		}
+
+		pr.CloseWithError(err)
+		chShift <- err
+	}()
+
+	err = c.fileArchive("archive", srcpath, nil, pw)
+	pw.Close()
+	shiftErr := <-chShift
+	if err != nil {
+		return err
+	}
+
+	return shiftErr
+}
+
+// FilePushArchive extracts the tar stream read from r into the existing directory dstpath in the
+// container.
+func (c *containerLXC) FilePushArchive(r io.Reader, dstpath string) error {
+	idmapset, err := c.fileArchiveIdmap()
+	if err != nil {
+		return err
+	}
+
+	if idmapset == nil {
+		return c.fileArchive("extract", dstpath, r, nil)
+	}
+
+	// Map the uid and gid of the entries on the way in.
+	pr, pw := io.Pipe()
+	chShift := make(chan error, 1)
+	go func() {
+		err := filearchive.Shift(r, pw, idmapset.ShiftIntoNs)
+		pw.CloseWithError(err)
+		chShift <- err
+	}()
+
+	err = c.fileArchive("extract", dstpath, pr, nil)
+	pr.Close()
+	shiftErr := <-chShift
+	if shiftErr != nil {
+		return shiftErr
+	}
+
+	return err
+}
+
+// FileSFTPConn returns a connection to an SFTP server serving the container's filesystem. The
+// server stops once the connection is closed.
+func (c *containerLXC) FileSFTPConn() (io.ReadWriteCloser, error) {
+	// Stopped containers are accessed without their user namespace, which would show the shifted
+	// ownership of their files.
+	if !c.IsRunning() {
+		idmapset, err := c.DiskIdmap()
+		if err != nil {
+			return nil, err
+		}
+
+		if idmapset != nil {
+			return nil, fmt.Errorf("SFTP requires unprivileged containers to be running")
+		}
+	}
+
+	var ourStart bool
+	var err error
+
+	// Setup container storage if needed
+	if !c.IsRunning() {
+		ourStart, err = c.mount()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cleanup := func() {
+		if ourStart {
+			c.unmount()
+		}
+	}
+
+	// The SFTP server uses one end of a socket pair as its stdin and stdout.
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	local := os.NewFile(uintptr(fds[0]), "sftp-lxd")
+	remote := os.NewFile(uintptr(fds[1]), "sftp-forkfile")
+	defer remote.Close()
+
+	conn, err := net.FileConn(local)
+	local.Close()
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	var stderr strings.Builder
+	cmd := exec.Command(
+		c.state.OS.ExecPath,
+		"forkfile",
+		"sftp",
+		c.RootfsPath(),
+		fmt.Sprintf("%d", c.InitPID()),
+	)
+	cmd.Stdin = remote
+	cmd.Stdout = remote
+	cmd.Stderr = &stderr
+
+	err = cmd.Start()
+	if err != nil {
+		conn.Close()
+		cleanup()
+		return nil, err
+	}
+
+	go func() {
+		err := cmd.Wait()
+		if err != nil {
+			logger.Warn("SFTP server failed", log.Ctx{"container": c.Name(), "err": err, "stderr": strings.TrimSpace(stderr.String())})
+		}
+
+		// Tear down container storage if needed
+		cleanup()
+	}()
+
+	return conn, nil
+}
+
+// fileArchiveIdmap returns the idmap the ownership of archived files needs shifting with, which is
+// only the case for stopped containers whose files are accessed without their user namespace.
+func (c *containerLXC) fileArchiveIdmap() (*idmap.IdmapSet, error) {
+	if c.IsRunning() {
+		return nil, nil
+	}
+
+	return c.DiskIdmap()
+}
+
+// fileArchive runs the forkfile archive or extract command against the path in the container.
+func (c *containerLXC) fileArchive(command string, path string, stdin io.Reader, stdout io.Writer) error {
+	var ourStart bool
+	var err error
+
+	// Setup container storage if needed
+	if !c.IsRunning() {
+		ourStart, err = c.mount()
+		if err != nil {
+			return err
+		}
+	}
+
+	var stderr strings.Builder
+	cmd := exec.Command(
+		c.state.OS.ExecPath,
+		"forkfile",
+		command,
+		c.RootfsPath(),
+		fmt.Sprintf("%d", c.InitPID()),
+		path,
+	)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+
+	// Tear down container storage if needed
+	if !c.IsRunning() && ourStart {
+		_, err := c.unmount()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return err
+		}
+
+		return fmt.Errorf("%s", strings.TrimPrefix(msg, "Error: "))
+	}
+
+	return nil
+}
+
 func (c *containerLXC) Console() (*os.File, chan error, error) {
 	chDisconnect := make(chan error, 1)
 
@@ -5827,6 +6388,58 @@ func (c *containerLXC) diskState() map[string]api.InstanceStateDisk {
 	return disk
 }
 
+// DiskIOStats returns the IO counters of the container's disk devices backed by a storage pool,
+// keyed by device name. They come from the blkio (or io) cgroup, which accounts the IO per block
+// device, so on pools where several volumes share a block device (such as dir or btrfs) the
+// counters of a volume include the container's IO to the other volumes on that device.
+func (c *containerLXC) DiskIOStats() (map[string]api.StorageVolumeStateIO, error) {
+	// Check that we're running
+	if !c.IsRunning() {
+		return nil, fmt.Errorf("The container isn't running")
+	}
+
+	cg, err := c.cgroup(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := cg.GetIOStats()
+	if err != nil {
+		return nil, err
+	}
+
+	disks := map[string]api.StorageVolumeStateIO{}
+	for _, dev := range c.expandedDevices.Sorted() {
+		if dev.Config["type"] != "disk" || dev.Config["pool"] == "" {
+			continue
+		}
+
+		path := c.RootfsPath()
+		if dev.Config["path"] != "/" {
+			path = storagePools.GetStoragePoolVolumeMountPoint(dev.Config["pool"], dev.Config["source"])
+		}
+
+		var st unix.Stat_t
+		err := unix.Stat(path, &st)
+		if err != nil {
+			continue
+		}
+
+		disk := api.StorageVolumeStateIO{}
+		devStats, ok := stats[fmt.Sprintf("%d:%d", unix.Major(uint64(st.Dev)), unix.Minor(uint64(st.Dev)))]
+		if ok {
+			disk.ReadBytes = devStats.ReadBytes
+			disk.ReadOps = devStats.ReadOps
+			disk.WriteBytes = devStats.WriteBytes
+			disk.WriteOps = devStats.WriteOps
+		}
+
+		disks[dev.Name] = disk
+	}
+
+	return disks, nil
+}
+
 func (c *containerLXC) memoryState() api.InstanceStateMemory {
 	memory := api.InstanceStateMemory{}
 	cg, err := c.cgroup(c.c)
@@ -6460,7 +7073,7 @@ func (c *containerLXC) fillNetworkDevice(name string, m deviceConfig.Device) (de
 		volatileName := c.localConfig[configKey]
 		if volatileName == "" {
 			// Generate a new interface name
-			volatileName, err := nextInterfaceName()
+			volatileName, err = nextInterfaceName()
 			if err != nil {
 				return nil, err
 			}
@@ -6474,6 +7087,7 @@ func (c *containerLXC) fillNetworkDevice(name string, m deviceConfig.Device) (de
 					return nil, err
 				}
 
+				volatileName = value
 				c.localConfig[configKey] = value
 				c.expandedConfig[configKey] = value
 			} else {
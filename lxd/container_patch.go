@@ -9,9 +9,11 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
@@ -51,6 +53,12 @@ func containerPatch(d *Daemon, r *http.Request) response.Response {
 		return response.PreconditionFailed(err)
 	}
 
+	// Patching doesn't go through an operation, fail if one is running on the instance.
+	busy := operations.InstanceBusy(project, name)
+	if busy != nil {
+		return busy.Response()
+	}
+
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		return response.InternalError(err)
@@ -69,6 +77,8 @@ func containerPatch(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	configTranslateKeys(config.EntityInstance, req.Config)
+
 	if req.Restore != "" {
 		return response.BadRequest(fmt.Errorf("Can't call PATCH in restore mode"))
 	}
@@ -131,6 +141,11 @@ func containerPatch(d *Daemon, r *http.Request) response.Response {
 		Project:      project,
 	}
 
+	err = projectLimitsCheckInstance(d.State(), c.Type(), c.Name(), args)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
 	err = c.Update(args, false)
 	if err != nil {
 		return response.SmartError(err)
@@ -178,7 +178,7 @@ func containerPost(d *Daemon, r *http.Request) response.Response {
 				return response.BadRequest(fmt.Errorf("Container is running"))
 			}
 
-			// Check if we are migrating a ceph-based container.
+			// Check if we are migrating a ceph or iscsi based container.
 			poolName, err := d.cluster.InstancePool(project, name)
 			if err != nil {
 				err = errors.Wrap(err, "Failed to fetch container's pool name")
@@ -193,10 +193,18 @@ func containerPost(d *Daemon, r *http.Request) response.Response {
 				return containerPostClusteringMigrateWithCeph(d, inst, project, name, req.Name, targetNode, instanceType)
 			}
 
-			// If this is not a ceph-based container, make sure
-			// that the source node is online, and we didn't get
-			// here only to handle the case where the container is
-			// ceph-based.
+			if pool.Driver == "iscsi" {
+				if req.Name != name {
+					return response.BadRequest(fmt.Errorf("Containers on iscsi storage pools can't be renamed while moved"))
+				}
+
+				return containerPostClusteringMigrateWithCeph(d, inst, project, name, req.Name, targetNode, instanceType)
+			}
+
+			// If this is not a ceph or iscsi based container, make
+			// sure that the source node is online, and we didn't
+			// get here only to handle the case where the container
+			// is on remote storage.
 			if sourceNodeOffline {
 				err := fmt.Errorf("The cluster member hosting the container is offline")
 				return response.SmartError(err)
@@ -205,6 +213,11 @@ func containerPost(d *Daemon, r *http.Request) response.Response {
 			return containerPostClusteringMigrate(d, inst, name, req.Name, targetNode)
 		}
 
+		busy := operations.InstanceBusy(project, name)
+		if busy != nil {
+			return busy.Response()
+		}
+
 		instanceOnly := req.InstanceOnly || req.ContainerOnly
 		ws, err := NewMigrationSource(inst, stateful, instanceOnly)
 		if err != nil {
@@ -215,9 +228,9 @@ func containerPost(d *Daemon, r *http.Request) response.Response {
 		resources["instances"] = []string{name}
 		resources["containers"] = resources["instances"]
 
-		run := func(op *operations.Operation) error {
+		run := instanceOpExclusive(project, name, func(op *operations.Operation) error {
 			return ws.Do(d.State(), op)
-		}
+		})
 
 		if req.Target != nil {
 			// Push mode
@@ -249,10 +262,15 @@ func containerPost(d *Daemon, r *http.Request) response.Response {
 		return response.Conflict(fmt.Errorf("Name '%s' already in use", req.Name))
 	}
 
-	run := func(*operations.Operation) error {
-		return inst.Rename(req.Name)
+	busy := operations.InstanceBusy(project, name)
+	if busy != nil {
+		return busy.Response()
 	}
 
+	run := instanceOpExclusive(project, name, func(*operations.Operation) error {
+		return inst.Rename(req.Name)
+	})
+
 	resources := map[string][]string{}
 	resources["instances"] = []string{name}
 	resources["containers"] = resources["instances"]
@@ -408,7 +426,7 @@ func containerPostClusteringMigrate(d *Daemon, c instance.Instance, oldName, new
 	return operations.OperationResponse(op)
 }
 
-// Special case migrating a container backed by ceph across two cluster nodes.
+// Special case migrating a container backed by ceph or iscsi across two cluster nodes.
 func containerPostClusteringMigrateWithCeph(d *Daemon, c instance.Instance, project, oldName, newName, newNode string, instanceType instancetype.Type) response.Response {
 	run := func(*operations.Operation) error {
 		// If source node is online (i.e. we're serving the request on
@@ -423,23 +441,35 @@ func containerPostClusteringMigrateWithCeph(d *Daemon, c instance.Instance, proj
 			if err != nil {
 				return errors.Wrap(err, "Failed to get source container's storage pool")
 			}
-			if pool.Driver != "ceph" {
-				return fmt.Errorf("Source container's storage pool is not of type ceph")
-			}
-			si, err := storagePoolVolumeContainerLoadInit(d.State(), c.Project(), c.Name())
-			if err != nil {
-				return errors.Wrap(err, "Failed to initialize source container's storage pool")
+			if pool.Driver == "iscsi" {
+				// Detach the iSCSI volume so that the target node can attach it.
+				pool, err := driver.GetPoolByInstance(d.State(), c)
+				if err != nil {
+					return errors.Wrap(err, "Failed to load source container's storage pool")
+				}
+
+				_, err = pool.UnmountInstance(c, nil)
+				if err != nil {
+					return errors.Wrap(err, "Failed to detach source container's iSCSI volume")
+				}
+			} else {
+				if pool.Driver != "ceph" {
+					return fmt.Errorf("Source container's storage pool is not of type ceph")
+				}
+				si, err := storagePoolVolumeContainerLoadInit(d.State(), c.Project(), c.Name())
+				if err != nil {
+					return errors.Wrap(err, "Failed to initialize source container's storage pool")
+				}
+				s, ok := si.(*storageCeph)
+				if !ok {
+					return fmt.Errorf("Unexpected source container storage backend")
+				}
+				err = cephRBDVolumeUnmap(s.ClusterName, s.OSDPoolName, c.Name(),
+					storagePoolVolumeTypeNameContainer, s.UserName, true)
+				if err != nil {
+					return errors.Wrap(err, "Failed to unmap source container's RBD volume")
+				}
 			}
-			s, ok := si.(*storageCeph)
-			if !ok {
-				return fmt.Errorf("Unexpected source container storage backend")
-			}
-			err = cephRBDVolumeUnmap(s.ClusterName, s.OSDPoolName, c.Name(),
-				storagePoolVolumeTypeNameContainer, s.UserName, true)
-			if err != nil {
-				return errors.Wrap(err, "Failed to unmap source container's RBD volume")
-			}
-
 		}
 
 		// Re-link the database entries against the new node name.
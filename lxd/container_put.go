@@ -7,6 +7,7 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance"
@@ -60,6 +61,8 @@ func containerPut(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	configTranslateKeys(config.EntityInstance, configRaw.Config)
+
 	architecture, err := osarch.ArchitectureId(configRaw.Architecture)
 	if err != nil {
 		architecture = 0
@@ -80,6 +83,11 @@ func containerPut(d *Daemon, r *http.Request) response.Response {
 				Project:      project,
 			}
 
+			err = projectLimitsCheckInstance(d.State(), c.Type(), c.Name(), args)
+			if err != nil {
+				return err
+			}
+
 			// FIXME: should set to true when not migrating
 			err = c.Update(args, false)
 			if err != nil {
@@ -92,16 +100,25 @@ func containerPut(d *Daemon, r *http.Request) response.Response {
 		opType = db.OperationContainerUpdate
 	} else {
 		// Snapshot Restore
-		do = func(op *operations.Operation) error {
-			return instanceSnapRestore(d.State(), project, name, configRaw.Restore, configRaw.Stateful)
+		busy := operations.InstanceBusy(project, name)
+		if busy != nil {
+			return busy.Response()
 		}
 
+		do = instanceOpExclusive(project, name, func(op *operations.Operation) error {
+			return instanceSnapRestore(d.State(), project, name, configRaw.Restore, configRaw.Stateful)
+		})
+
 		opType = db.OperationSnapshotRestore
 	}
 
 	resources := map[string][]string{}
 	resources["containers"] = []string{name}
 
+	if opType == db.OperationContainerUpdate {
+		do = instanceOpQueued(project, name, do)
+	}
+
 	op, err := operations.OperationCreate(d.State(), project, operations.OperationClassTask, opType, resources, nil, do, nil, nil)
 	if err != nil {
 		return response.InternalError(err)
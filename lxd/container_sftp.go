@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+func containerSFTPHandler(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	if strings.ToLower(r.Header.Get("Upgrade")) != "sftp" {
+		return response.BadRequest(fmt.Errorf("Missing or invalid upgrade header"))
+	}
+
+	// Connect to the SFTP server of the instance, through the member hosting it if remote.
+	var conn io.ReadWriteCloser
+
+	client, err := cluster.ConnectIfContainerIsRemote(d.cluster, project, name, d.endpoints.NetworkCert(), instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if client != nil {
+		conn, err = client.UseProject(project).GetInstanceFileSFTPConn(name)
+		if err != nil {
+			return response.SmartError(err)
+		}
+	} else {
+		inst, err := instance.LoadByProjectAndName(d.State(), project, name)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		conn, err = inst.FileSFTPConn()
+		if err != nil {
+			return response.SmartError(err)
+		}
+	}
+
+	return response.UpgradeResponse("sftp", func(clientConn net.Conn) {
+		sftpProxy(clientConn, conn)
+	})
+}
+
+// sftpProxy copies data between the client and the SFTP server until either side closes its
+// connection.
+func sftpProxy(clientConn io.ReadWriteCloser, serverConn io.ReadWriteCloser) {
+	var once sync.Once
+	closeAll := func() {
+		clientConn.Close()
+		serverConn.Close()
+	}
+
+	copy := func(dst io.Writer, src io.Reader) {
+		_, err := io.Copy(dst, src)
+		if err != nil {
+			logger.Debugf("SFTP connection closed: %v", err)
+		}
+
+		once.Do(closeAll)
+	}
+
+	go copy(serverConn, clientConn)
+	copy(clientConn, serverConn)
+}
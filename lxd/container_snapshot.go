@@ -176,7 +176,7 @@ func containerSnapshotsPost(d *Daemon, r *http.Request) response.Response {
 	resources["instances"] = []string{name}
 	resources["containers"] = resources["instances"]
 
-	op, err := operations.OperationCreate(d.State(), project, operations.OperationClassTask, db.OperationSnapshotCreate, resources, nil, snapshot, nil, nil)
+	op, err := operations.OperationCreate(d.State(), project, operations.OperationClassTask, db.OperationSnapshotCreate, resources, nil, instanceOpQueued(project, name, snapshot), nil, nil)
 	if err != nil {
 		return response.InternalError(err)
 	}
@@ -215,14 +215,14 @@ func containerSnapshotHandler(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
-	if inst.Type() != instancetype.Container {
-		return response.SmartError(fmt.Errorf("Instance is not container type"))
-	}
-
 	switch r.Method {
 	case "GET":
 		return snapshotGet(inst, snapshotName)
 	case "POST":
+		if inst.Type() != instancetype.Container {
+			return response.SmartError(fmt.Errorf("Instance is not container type"))
+		}
+
 		return snapshotPost(d, r, inst, containerName)
 	case "DELETE":
 		return snapshotDelete(inst, snapshotName)
@@ -298,8 +298,9 @@ func snapshotPut(d *Daemon, r *http.Request, sc instance.Instance, name string)
 	resources := map[string][]string{}
 	resources["containers"] = []string{name}
 
+	parentName, _, _ := shared.InstanceGetParentAndSnapshotName(sc.Name())
 	op, err := operations.OperationCreate(d.State(), sc.Project(), operations.OperationClassTask, opType, resources, nil,
-		do, nil, nil)
+		instanceOpQueued(sc.Project(), parentName, do), nil, nil)
 	if err != nil {
 		return response.InternalError(err)
 	}
@@ -422,7 +423,7 @@ func snapshotPost(d *Daemon, r *http.Request, sc instance.Instance, containerNam
 	resources := map[string][]string{}
 	resources["containers"] = []string{containerName}
 
-	op, err := operations.OperationCreate(d.State(), sc.Project(), operations.OperationClassTask, db.OperationSnapshotRename, resources, nil, rename, nil, nil)
+	op, err := operations.OperationCreate(d.State(), sc.Project(), operations.OperationClassTask, db.OperationSnapshotRename, resources, nil, instanceOpQueued(sc.Project(), containerName, rename), nil, nil)
 	if err != nil {
 		return response.InternalError(err)
 	}
@@ -438,7 +439,8 @@ func snapshotDelete(sc instance.Instance, name string) response.Response {
 	resources := map[string][]string{}
 	resources["containers"] = []string{sc.Name()}
 
-	op, err := operations.OperationCreate(sc.DaemonState(), sc.Project(), operations.OperationClassTask, db.OperationSnapshotDelete, resources, nil, remove, nil, nil)
+	parentName, _, _ := shared.InstanceGetParentAndSnapshotName(sc.Name())
+	op, err := operations.OperationCreate(sc.DaemonState(), sc.Project(), operations.OperationClassTask, db.OperationSnapshotDelete, resources, nil, instanceOpQueued(sc.Project(), parentName, remove), nil, nil)
 	if err != nil {
 		return response.InternalError(err)
 	}
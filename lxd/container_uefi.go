@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/instance/qemu"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// instanceUEFIVarsLoad loads the virtual machine targeted by a UEFI variables request, returning
+// a response instead if the request was forwarded or failed.
+func instanceUEFIVarsLoad(d *Daemon, r *http.Request) (*qemu.Qemu, response.Response) {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return nil, response.SmartError(err)
+	}
+
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a VM on a different node
+	resp, err := ForwardedResponseIfContainerIsRemote(d, r, project, name, instanceType)
+	if err != nil {
+		return nil, response.SmartError(err)
+	}
+	if resp != nil {
+		return nil, resp
+	}
+
+	inst, err := instance.LoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return nil, response.SmartError(err)
+	}
+
+	if inst.Type() != instancetype.VM {
+		return nil, response.BadRequest(fmt.Errorf("UEFI variables are only supported for virtual machines"))
+	}
+
+	return inst.(*qemu.Qemu), nil
+}
+
+func containerUEFIVarsGet(d *Daemon, r *http.Request) response.Response {
+	vm, resp := instanceUEFIVarsLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	path, err := vm.UEFIVarsPath()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	ent := response.FileResponseEntry{
+		Path:     path,
+		Filename: "qemu.nvram",
+	}
+
+	return response.FileResponse(r, []response.FileResponseEntry{ent}, nil, false)
+}
+
+func containerUEFIVarsPut(d *Daemon, r *http.Request) response.Response {
+	vm, resp := instanceUEFIVarsLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	err := vm.ImportUEFIVars(r.Body)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func containerUEFIVarsPost(d *Daemon, r *http.Request) response.Response {
+	vm, resp := instanceUEFIVarsLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	req := api.InstanceUEFIVarsPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	switch req.Action {
+	case "reset":
+		err = vm.ResetUEFIVars()
+	case "enroll":
+		err = vm.EnrollUEFIKeys(req.PK, req.KEK, req.DB)
+	default:
+		return response.BadRequest(fmt.Errorf("Unsupported UEFI variables action %q", req.Action))
+	}
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	return response.EmptySyncResponse
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 	"sort"
 	"strconv"
@@ -8,11 +9,16 @@ import (
 	"sync"
 	"time"
 
+	"github.com/lxc/lxd/lxd/backup"
+	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -68,6 +74,17 @@ var instanceFileCmd = APIEndpoint{
 	Delete: APIEndpointAction{Handler: containerFileHandler, AccessHandler: AllowProjectPermission("containers", "operate-containers")},
 }
 
+var instanceSFTPCmd = APIEndpoint{
+	Name: "instanceSFTP",
+	Path: "instances/{name}/sftp",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerSFTP", Path: "containers/{name}/sftp"},
+		{Name: "vmSFTP", Path: "virtual-machines/{name}/sftp"},
+	},
+
+	Get: APIEndpointAction{Handler: containerSFTPHandler, AccessHandler: AllowProjectPermission("containers", "operate-containers")},
+}
+
 var instanceSnapshotsCmd = APIEndpoint{
 	Name: "instanceSnapshots",
 	Path: "instances/{name}/snapshots",
@@ -118,6 +135,39 @@ var instanceExecCmd = APIEndpoint{
 	Post: APIEndpointAction{Handler: containerExecPost, AccessHandler: AllowProjectPermission("containers", "operate-containers")},
 }
 
+var instanceAgentCmd = APIEndpoint{
+	Name: "instanceAgent",
+	Path: "instances/{name}/agent",
+	Aliases: []APIEndpointAlias{
+		{Name: "vmAgent", Path: "virtual-machines/{name}/agent"},
+	},
+
+	Post: APIEndpointAction{Handler: containerAgentPost, AccessHandler: AllowProjectPermission("containers", "operate-containers")},
+}
+
+var instanceUEFIVarsCmd = APIEndpoint{
+	Name: "instanceUEFIVars",
+	Path: "instances/{name}/uefi-vars",
+	Aliases: []APIEndpointAlias{
+		{Name: "vmUEFIVars", Path: "virtual-machines/{name}/uefi-vars"},
+	},
+
+	Get:  APIEndpointAction{Handler: containerUEFIVarsGet, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+	Put:  APIEndpointAction{Handler: containerUEFIVarsPut, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+	Post: APIEndpointAction{Handler: containerUEFIVarsPost, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
+var instanceDevicesValidateCmd = APIEndpoint{
+	Name: "instanceDevicesValidate",
+	Path: "instances/{name}/devices:validate",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerDevicesValidate", Path: "containers/{name}/devices:validate"},
+		{Name: "vmDevicesValidate", Path: "virtual-machines/{name}/devices:validate"},
+	},
+
+	Post: APIEndpointAction{Handler: containerDevicesValidatePost, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
 var instanceMetadataCmd = APIEndpoint{
 	Name: "instanceMetadata",
 	Path: "instances/{name}/metadata",
@@ -218,34 +268,108 @@ func containersRestart(s *state.State) error {
 
 	sort.Sort(containerAutostartList(instances))
 
-	// Restart the instances
+	// Index the instances so that their boot.after dependencies can be started first.
+	local := map[string]instance.Instance{}
 	for _, c := range instances {
+		local[project.Prefix(c.Project(), c.Name())] = c
+	}
+
+	visited := map[string]bool{}
+
+	var startInstance func(c instance.Instance)
+	startInstance = func(c instance.Instance) {
+		key := project.Prefix(c.Project(), c.Name())
+		if visited[key] {
+			return
+		}
+
+		// Marked before handling the dependencies so that a cycle can't loop forever.
+		visited[key] = true
+
 		config := c.ExpandedConfig()
 		lastState := config["volatile.last_state.power"]
 
 		autoStart := config["boot.autostart"]
 		autoStartDelay := config["boot.autostart.delay"]
 
-		if shared.IsTrue(autoStart) || (autoStart == "" && lastState == "RUNNING") {
-			if c.IsRunning() {
+		if !shared.IsTrue(autoStart) && (autoStart != "" || lastState != "RUNNING") {
+			return
+		}
+
+		if c.IsRunning() {
+			return
+		}
+
+		for _, dep := range shared.InstanceBootAfter(config["boot.after"]) {
+			depInst, ok := local[project.Prefix(c.Project(), dep)]
+			if ok {
+				startInstance(depInst)
 				continue
 			}
 
-			err = c.Start(false)
+			err := containerWaitRemoteRunning(s, c.Project(), dep, containerBootAfterTimeout)
 			if err != nil {
-				logger.Errorf("Failed to start container '%s': %v", c.Name(), err)
+				logger.Warn("Starting instance without its boot.after dependency", log.Ctx{"project": c.Project(), "instance": c.Name(), "dependency": dep, "err": err})
 			}
+		}
 
-			autoStartDelayInt, err := strconv.Atoi(autoStartDelay)
-			if err == nil {
-				time.Sleep(time.Duration(autoStartDelayInt) * time.Second)
-			}
+		err := c.Start(false)
+		if err != nil {
+			logger.Errorf("Failed to start container '%s': %v", c.Name(), err)
+		}
+
+		autoStartDelayInt, err := strconv.Atoi(autoStartDelay)
+		if err == nil {
+			time.Sleep(time.Duration(autoStartDelayInt) * time.Second)
 		}
 	}
 
+	// Restart the instances
+	for _, c := range instances {
+		startInstance(c)
+	}
+
 	return nil
 }
 
+// containerBootAfterTimeout is how long to wait at startup for a boot.after dependency running on
+// another cluster member.
+const containerBootAfterTimeout = 5 * time.Minute
+
+// containerWaitRemoteRunning waits for an instance located on another cluster member to be
+// running, for at most the given time.
+func containerWaitRemoteRunning(s *state.State, projectName string, name string, timeout time.Duration) error {
+	client, err := cluster.ConnectIfContainerIsRemote(s.Cluster, projectName, name, s.Endpoints.NetworkCert(), instancetype.Any)
+	if err != nil {
+		return err
+	}
+
+	// Not a remote instance, it's either not meant to autostart or a snapshot.
+	if client == nil {
+		return nil
+	}
+
+	client = client.UseProject(projectName)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		state, _, err := client.GetInstanceState(name)
+		if err == nil && state.StatusCode == api.Running {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			if err != nil {
+				return err
+			}
+
+			return fmt.Errorf("Instance is still %s", strings.ToLower(state.Status))
+		}
+
+		time.Sleep(5 * time.Second)
+	}
+}
+
 func vmMonitor(s *state.State) error {
 	// Get all the instances
 	insts, err := instanceLoadNodeAll(s, instancetype.VM)
@@ -261,6 +385,27 @@ func vmMonitor(s *state.State) error {
 	return nil
 }
 
+// containerShutdownConfigFromBackup returns the shutdown related keys of the
+// instance's expanded config as recorded in its backup file. It's used to keep
+// honoring the shutdown order and timeouts when the database isn't available.
+func containerShutdownConfigFromBackup(projectName string, name string) map[string]string {
+	config := map[string]string{}
+
+	backupConf, err := backup.ParseInstanceConfigYamlFile(shared.VarPath("containers", project.Prefix(projectName, name), "backup.yaml"))
+	if err != nil || backupConf.Container == nil {
+		return config
+	}
+
+	for _, key := range []string{"boot.stop.priority", "boot.host_shutdown_timeout"} {
+		value, ok := backupConf.Container.ExpandedConfig[key]
+		if ok {
+			config[key] = value
+		}
+	}
+
+	return config
+}
+
 type containerStopList []instance.Instance
 
 func (slice containerStopList) Len() int {
@@ -330,12 +475,12 @@ func containersShutdown(s *state.State) error {
 			return err
 		}
 
-		for project, names := range cnames {
+		for projectName, names := range cnames {
 			for _, name := range names {
 				inst, err := instanceLoad(s, db.InstanceArgs{
-					Project: project,
+					Project: projectName,
 					Name:    name,
-					Config:  make(map[string]string),
+					Config:  containerShutdownConfigFromBackup(projectName, name),
 				}, nil)
 				if err != nil {
 					return err
@@ -390,7 +535,11 @@ func containersShutdown(s *state.State) error {
 			// Stop the instance
 			wg.Add(1)
 			go func(c instance.Instance, lastState string) {
-				c.Shutdown(time.Second * time.Duration(timeoutSeconds))
+				err := c.Shutdown(time.Second * time.Duration(timeoutSeconds))
+				if err != nil {
+					logger.Warn("Failed shutting down instance, forcefully stopping", log.Ctx{"project": c.Project(), "instance": c.Name(), "timeout": timeoutSeconds, "err": err})
+				}
+
 				c.Stop(false)
 				c.VolatileSet(map[string]string{"volatile.last_state.power": lastState})
 
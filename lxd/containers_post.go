@@ -169,7 +169,12 @@ func createFromMigration(d *Daemon, project string, req *api.InstancesPost) resp
 		return response.BadRequest(err)
 	}
 
-	if dbType != instancetype.Container {
+	if dbType == instancetype.VM {
+		// Virtual machines can only be imported from a disk pushed by an external tool.
+		if req.Source.Mode != "push" || req.Source.Refresh || req.Source.Live {
+			return response.BadRequest(fmt.Errorf("Virtual machines can only be imported in push mode"))
+		}
+	} else if dbType != instancetype.Container {
 		return response.BadRequest(fmt.Errorf("Instance type not container"))
 	}
 
@@ -235,6 +240,10 @@ func createFromMigration(d *Daemon, project string, req *api.InstancesPost) resp
 		args.Devices[localRootDiskDeviceKey]["pool"] = storagePool
 	}
 
+	if dbType == instancetype.VM {
+		return createFromVMImport(d, project, args, storagePool)
+	}
+
 	var inst instance.Instance
 
 	// Early check for refresh.
@@ -421,6 +430,51 @@ func createFromMigration(d *Daemon, project string, req *api.InstancesPost) resp
 	return operations.OperationResponse(op)
 }
 
+// createFromVMImport creates a new virtual machine whose root disk is streamed by an external tool.
+func createFromVMImport(d *Daemon, project string, args db.InstanceArgs, storagePool string) response.Response {
+	// Only the new storage layer can expose the VM disk.
+	_, err := storagePools.GetPoolByName(d.State(), storagePool)
+	if err != nil {
+		if err == storageDrivers.ErrUnknownDriver {
+			return response.BadRequest(fmt.Errorf("Storage pool %q doesn't support virtual machines", storagePool))
+		}
+
+		return response.InternalError(err)
+	}
+
+	inst, err := instanceCreateInternal(d.State(), args)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	sink, err := newVMImportSink(inst)
+	if err != nil {
+		inst.Delete()
+		return response.InternalError(err)
+	}
+
+	run := func(op *operations.Operation) error {
+		err := sink.Do(d.State(), op)
+		if err != nil {
+			inst.Delete()
+			return fmt.Errorf("Error transferring virtual machine disk: %s", err)
+		}
+
+		return nil
+	}
+
+	resources := map[string][]string{}
+	resources["instances"] = []string{args.Name}
+
+	op, err := operations.OperationCreate(d.State(), project, operations.OperationClassWebsocket, db.OperationContainerCreate, resources, sink.Metadata(), run, nil, sink.Connect)
+	if err != nil {
+		inst.Delete()
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
+}
+
 func createFromCopy(d *Daemon, project string, req *api.InstancesPost) response.Response {
 	if req.Source.Source == "" {
 		return response.BadRequest(fmt.Errorf("must specify a source container"))
@@ -437,6 +491,29 @@ func createFromCopy(d *Daemon, project string, req *api.InstancesPost) response.
 		return response.SmartError(err)
 	}
 
+	dbType, err := instancetype.New(string(req.Type))
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if dbType != instancetype.Any && dbType != source.Type() && !req.Source.Convert {
+		return response.BadRequest(fmt.Errorf("Instance type should not be specified or should match source type"))
+	}
+
+	// Converting requires the new type to be explicit.
+	convert := req.Source.Convert
+	if convert {
+		if dbType == instancetype.Any || dbType == source.Type() {
+			return response.BadRequest(fmt.Errorf("Converting requires a new instance type different from the source type"))
+		}
+
+		if shared.IsSnapshot(source.Name()) || req.Source.Refresh || req.Stateful {
+			return response.BadRequest(fmt.Errorf("Snapshots can't be converted and converted copies can't be refreshed or stateful"))
+		}
+	} else {
+		dbType = source.Type()
+	}
+
 	// Check if we need to redirect to migration
 	clustered, err := cluster.Enabled(d.db)
 	if err != nil {
@@ -455,6 +532,10 @@ func createFromCopy(d *Daemon, project string, req *api.InstancesPost) response.
 		}
 
 		if serverName != source.Location() {
+			if convert {
+				return response.BadRequest(fmt.Errorf("Converting requires the source instance to be on the target cluster member"))
+			}
+
 			// Check if we are copying from a ceph-based container.
 			_, rootDevice, _ := shared.GetRootDiskDevice(source.ExpandedDevices().CloneNative())
 			sourcePoolName := rootDevice["pool"]
@@ -495,6 +576,11 @@ func createFromCopy(d *Daemon, project string, req *api.InstancesPost) response.
 			continue
 		}
 
+		// The idmap of the container files doesn't apply to converted ones.
+		if convert && key == "volatile.last_state.idmap" {
+			continue
+		}
+
 		_, exists := req.Config[key]
 		if exists {
 			continue
@@ -545,21 +631,12 @@ func createFromCopy(d *Daemon, project string, req *api.InstancesPost) response.
 		}
 	}
 
-	dbType, err := instancetype.New(string(req.Type))
-	if err != nil {
-		return response.BadRequest(err)
-	}
-
-	if dbType != instancetype.Any && dbType != source.Type() {
-		return response.BadRequest(fmt.Errorf("Instance type should not be specified or should match source type"))
-	}
-
 	args := db.InstanceArgs{
 		Project:      targetProject,
 		Architecture: source.Architecture(),
 		BaseImage:    req.Source.BaseImage,
 		Config:       req.Config,
-		Type:         source.Type(),
+		Type:         dbType,
 		Description:  req.Description,
 		Devices:      deviceConfig.NewDevices(req.Devices),
 		Ephemeral:    req.Ephemeral,
@@ -569,6 +646,11 @@ func createFromCopy(d *Daemon, project string, req *api.InstancesPost) response.
 	}
 
 	run := func(op *operations.Operation) error {
+		if convert {
+			_, err := instanceCreateAsConversion(d.State(), args, source, op)
+			return err
+		}
+
 		instanceOnly := req.Source.InstanceOnly || req.Source.ContainerOnly
 		_, err := instanceCreateAsCopy(d.State(), args, source, instanceOnly, req.Source.Refresh, op)
 		if err != nil {
@@ -768,14 +850,36 @@ func containersPost(d *Daemon, r *http.Request) response.Response {
 
 	targetNode := queryParam(r, "target")
 	if targetNode == "" {
+		// Honor the affinity groups of the new instance, including
+		// those coming from its profiles.
+		affinity := ""
+		clustered, err := cluster.Enabled(d.db)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		if clustered {
+			profileNames := req.Profiles
+			if profileNames == nil {
+				profileNames = []string{"default"}
+			}
+
+			profiles, err := d.cluster.ProfilesGet(project, profileNames)
+			if err != nil {
+				return response.SmartError(err)
+			}
+
+			affinity = db.ProfilesExpandConfig(req.Config, profiles)["cluster.affinity"]
+		}
+
 		// If no target node was specified, pick the node with the
 		// least number of containers. If there's just one node, or if
 		// the selected node is the local one, this is effectively a
 		// no-op, since NodeWithLeastContainers() will return an empty
 		// string.
-		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
 			var err error
-			targetNode, err = tx.NodeWithLeastContainers()
+			targetNode, err = tx.NodeWithLeastContainersAffinity(project, affinity)
 			return err
 		})
 		if err != nil {
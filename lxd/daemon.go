@@ -8,6 +8,7 @@ import (
 	sqldriver "database/sql/driver"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -28,6 +29,7 @@ import (
 	"gopkg.in/macaroon-bakery.v2/bakery/identchecker"
 	"gopkg.in/macaroon-bakery.v2/httpbakery"
 
+	"github.com/lxc/lxd/lxd/bgp"
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/daemon"
 	"github.com/lxc/lxd/lxd/db"
@@ -41,11 +43,13 @@ import (
 	"github.com/lxc/lxd/lxd/rbac"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/seccomp"
+	"github.com/lxc/lxd/lxd/secrets"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/sys"
 	"github.com/lxc/lxd/lxd/task"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/idmap"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/version"
@@ -60,7 +64,9 @@ type Daemon struct {
 	db           *db.Node
 	firewall     firewall.Firewall
 	maas         *maas.Controller
+	bgp          *bgp.Server
 	rbac         *rbac.Server
+	secrets      secrets.Backend
 	cluster      *db.Cluster
 	setupChan    chan struct{} // Closed when basic Daemon setup is completed
 	readyChan    chan struct{} // Closed when LXD is fully ready
@@ -88,8 +94,14 @@ type Daemon struct {
 
 	externalAuth *externalAuth
 
+	// Projects of the restricted client certificates, by fingerprint.
+	clientCertProjects map[string][]string
+
 	// Stores last heartbeat node information to detect node changes.
 	lastNodeList *cluster.APIHeartbeat
+
+	// Cluster roles of the local member the daemon last reacted to.
+	clusterRoles []string
 }
 
 type externalAuth struct {
@@ -144,6 +156,7 @@ func NewDaemon(config *DaemonConfig, os *sys.OS) *Daemon {
 	devlxdEvents := events.NewServer(daemon.Debug, daemon.Verbose)
 
 	return &Daemon{
+		bgp:          bgp.NewServer(),
 		config:       config,
 		devlxdEvents: devlxdEvents,
 		events:       lxdEvents,
@@ -212,13 +225,27 @@ func AllowProjectPermission(feature string, permission string) func(d *Daemon, r
 
 		// Validate whether the user has the needed permission
 		if !d.userHasPermission(r, project, permission) {
-			return response.Forbidden(nil)
+			return response.Forbidden(errProjectRestricted)
 		}
 
 		return response.EmptySyncResponse
 	}
 }
 
+// errProjectRestricted is returned to the clients lacking access to the requested project.
+var errProjectRestricted = api.NewTypedError(http.StatusForbidden, api.ErrorTypeProjectRestricted, "not authorized")
+
+// AllowProjectAuthenticated is an AccessHandler which allows all authenticated requests, except
+// for restricted clients without access to the requested project.
+func AllowProjectAuthenticated(d *Daemon, r *http.Request) response.Response {
+	restricted, projects := d.userRestricted(r)
+	if restricted && !shared.StringInSlice(projectParam(r), projects) {
+		return response.Forbidden(errProjectRestricted)
+	}
+
+	return response.EmptySyncResponse
+}
+
 // Convenience function around Authenticate
 func (d *Daemon) checkTrustedClient(r *http.Request) error {
 	trusted, _, _, err := d.Authenticate(r)
@@ -335,7 +362,7 @@ func writeMacaroonsRequiredResponse(b *identchecker.Bakery, r *http.Request, w h
 
 // State creates a new State instance linked to our internal db and os.
 func (d *Daemon) State() *state.State {
-	return state.NewState(d.db, d.cluster, d.maas, d.os, d.endpoints, d.events, d.devlxdEvents, d.firewall, d.proxy)
+	return state.NewState(d.db, d.cluster, d.maas, d.os, d.endpoints, d.events, d.devlxdEvents, d.firewall, d.proxy, d.bgp, d.secrets)
 }
 
 // UnixSocket returns the full path to the unix.socket file that this daemon is
@@ -360,6 +387,9 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c APIEndpoint) {
 	route := restAPI.HandleFunc(uri, func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
+		// Use the client address given by trusted reverse proxies.
+		d.endpoints.NetworkRewriteForwarded(r)
+
 		if !(r.RemoteAddr == "@" && version == "internal") {
 			// Block public API requests until we're done with basic
 			// initialization tasks, such setting up the cluster database.
@@ -383,6 +413,21 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c APIEndpoint) {
 			}
 		}
 
+		// Record state-changing requests in the audit log once handled.
+		if auditRequired(r, version, protocol) {
+			aw := &auditResponseWriter{ResponseWriter: w}
+			w = aw
+
+			defer func() {
+				statusCode := aw.statusCode
+				if statusCode == 0 {
+					statusCode = http.StatusOK
+				}
+
+				auditRecord(d, r, protocol, username, statusCode)
+			}()
+		}
+
 		// Reject internal queries to remote, non-cluster, clients
 		if version == "internal" && !shared.StringInSlice(protocol, []string{"unix", "cluster"}) {
 			// Except for the initial cluster accept request (done over trusted TLS)
@@ -397,6 +442,7 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c APIEndpoint) {
 		if trusted {
 			logger.Debug("Handling", log.Ctx{"method": r.Method, "url": r.URL.RequestURI(), "ip": r.RemoteAddr, "user": username})
 			r = r.WithContext(context.WithValue(r.Context(), "username", username))
+			r = r.WithContext(context.WithValue(r.Context(), "protocol", protocol))
 		} else if untrustedOk && r.Header.Get("X-LXD-authenticated") == "" {
 			logger.Debug(fmt.Sprintf("Allowing untrusted %s", r.Method), log.Ctx{"url": r.URL.RequestURI(), "ip": r.RemoteAddr})
 		} else if derr, ok := err.(*bakery.DischargeRequiredError); ok {
@@ -641,6 +687,7 @@ func (d *Daemon) init() error {
 	lxcExtensions := []string{
 		"mount_injection_file",
 		"seccomp_notify",
+		"seccomp_proxy_send_notify_fd",
 		"network_ipvlan",
 		"network_l2proxy",
 		"network_gateway_device_route",
@@ -691,7 +738,7 @@ func (d *Daemon) init() error {
 		}
 	}
 
-	address, err := node.HTTPSAddress(d.db)
+	addresses, err := node.HTTPSAddresses(d.db)
 	if err != nil {
 		return errors.Wrap(err, "Failed to fetch node address")
 	}
@@ -714,7 +761,7 @@ func (d *Daemon) init() error {
 		RestServer:           RestServer(d),
 		DevLxdServer:         DevLxdServer(d),
 		LocalUnixSocketGroup: d.config.Group,
-		NetworkAddress:       address,
+		NetworkAddress:       strings.Join(addresses, ","),
 		ClusterAddress:       clusterAddress,
 		DebugAddress:         debugAddress,
 	}
@@ -789,6 +836,12 @@ func (d *Daemon) init() error {
 	}
 	d.gateway.Cluster = d.cluster
 
+	// Record any missing kernel features as warnings.
+	err = warningsRecordSystem(d)
+	if err != nil {
+		logger.Warn("Failed to record system warnings", log.Ctx{"err": err})
+	}
+
 	// This logic used to belong to patchUpdateFromV10, but has been moved
 	// here because it needs database access.
 	if shared.PathExists(shared.VarPath("lxc")) {
@@ -855,6 +908,18 @@ func (d *Daemon) init() error {
 	maasAPIKey := ""
 	maasMachine := ""
 
+	bgpAddress := ""
+	bgpRouterID := ""
+	bgpASN := int64(0)
+
+	secretsBackend := ""
+	secretsVaultAddress := ""
+	secretsVaultToken := ""
+	secretsVaultMount := ""
+	secretsVaultPath := ""
+
+	trustedProxy := ""
+
 	err = d.db.Transaction(func(tx *db.NodeTx) error {
 		config, err := node.ConfigLoad(tx)
 		if err != nil {
@@ -862,6 +927,8 @@ func (d *Daemon) init() error {
 		}
 
 		maasMachine = config.MAASMachine()
+		bgpAddress = config.BGPAddress()
+		bgpRouterID = config.BGPRouterID()
 		return nil
 	})
 	if err != nil {
@@ -882,6 +949,9 @@ func (d *Daemon) init() error {
 		candidAPIURL, candidAPIKey, candidExpiry, candidDomains = config.CandidServer()
 		maasAPIURL, maasAPIKey = config.MAASController()
 		rbacAPIURL, rbacAPIKey, rbacExpiry, rbacAgentURL, rbacAgentUsername, rbacAgentPrivateKey, rbacAgentPublicKey = config.RBACServer()
+		bgpASN = config.BGPASN()
+		secretsBackend, secretsVaultAddress, secretsVaultToken, secretsVaultMount, secretsVaultPath = config.SecretsBackend()
+		trustedProxy = config.HTTPSTrustedProxy()
 
 		return nil
 	})
@@ -889,6 +959,16 @@ func (d *Daemon) init() error {
 		return err
 	}
 
+	err = d.setupSecretsBackend(secretsBackend, secretsVaultAddress, secretsVaultToken, secretsVaultMount, secretsVaultPath)
+	if err != nil {
+		return err
+	}
+
+	err = d.endpoints.NetworkUpdateTrustedProxy(trustedProxy)
+	if err != nil {
+		return err
+	}
+
 	if rbacAPIURL != "" {
 		err = d.setupRBACServer(rbacAPIURL, rbacAPIKey, rbacExpiry, rbacAgentURL, rbacAgentUsername, rbacAgentPrivateKey, rbacAgentPublicKey)
 		if err != nil {
@@ -934,6 +1014,14 @@ func (d *Daemon) init() error {
 		// Read the trusted certificates
 		readSavedClientCAList(d)
 
+		// Start the BGP server
+		if bgpAddress != "" {
+			err = d.bgp.Start(bgpAddress, uint32(bgpASN), net.ParseIP(bgpRouterID))
+			if err != nil {
+				logger.Error("Failed to start the BGP server", log.Ctx{"err": err})
+			}
+		}
+
 		// Connect to MAAS
 		if maasAPIURL != "" {
 			go func() {
@@ -1019,6 +1107,21 @@ func (d *Daemon) Ready() error {
 
 		// Remove expired container snapshots (minutely)
 		d.tasks.Add(pruneExpiredContainerSnapshotsTask(d))
+
+		// Run the scheduled instance tasks (minutely check of configurable cron expressions)
+		d.tasks.Add(instanceTasksTask(d))
+
+		// Check storage pool health (every 5 minutes)
+		d.tasks.Add(storagePoolsHealthCheckTask(d))
+
+		// Grow the loop backed storage pools above their threshold (minutely)
+		d.tasks.Add(storagePoolsAutoGrowTask(d))
+
+		// Remove old resolved warnings (daily)
+		d.tasks.Add(pruneResolvedWarningsTask(d))
+
+		// Remove expired audit log entries (daily)
+		d.tasks.Add(pruneAuditLogTask(d))
 	}
 
 	// Start all background tasks
@@ -1081,6 +1184,10 @@ func (d *Daemon) Stop() error {
 		trackError(d.endpoints.Down())
 	}
 
+	if d.bgp != nil {
+		trackError(d.bgp.Stop())
+	}
+
 	trackError(d.tasks.Stop(3 * time.Second))        // Give tasks a bit of time to cleanup.
 	trackError(d.clusterTasks.Stop(3 * time.Second)) // Give tasks a bit of time to cleanup.
 
@@ -1291,7 +1398,25 @@ func (d *Daemon) setupRBACServer(rbacURL string, rbacKey string, rbacExpiry int6
 	return nil
 }
 
+// userRestricted returns whether the request comes from a restricted client certificate and if so,
+// the projects it has access to.
+func (d *Daemon) userRestricted(r *http.Request) (bool, []string) {
+	protocol, _ := r.Context().Value("protocol").(string)
+	if protocol != "tls" {
+		return false, nil
+	}
+
+	fingerprint, _ := r.Context().Value("username").(string)
+	projects, ok := d.clientCertProjects[fingerprint]
+	return ok, projects
+}
+
 func (d *Daemon) userIsAdmin(r *http.Request) bool {
+	restricted, _ := d.userRestricted(r)
+	if restricted {
+		return false
+	}
+
 	if d.externalAuth == nil || d.rbac == nil || r.RemoteAddr == "@" {
 		return true
 	}
@@ -1300,6 +1425,11 @@ func (d *Daemon) userIsAdmin(r *http.Request) bool {
 }
 
 func (d *Daemon) userHasPermission(r *http.Request, project string, permission string) bool {
+	restricted, projects := d.userRestricted(r)
+	if restricted {
+		return shared.StringInSlice(project, projects)
+	}
+
 	if d.externalAuth == nil || d.rbac == nil || r.RemoteAddr == "@" {
 		return true
 	}
@@ -1336,6 +1466,23 @@ func (d *Daemon) setupMAASController(server string, key string, machine string)
 	return nil
 }
 
+// setupSecretsBackend sets up the backend storing secrets such as volume encryption keys. The
+// file backend, storing them under the LXD directory, is used unless Vault is configured.
+func (d *Daemon) setupSecretsBackend(backendType string, address string, token string, mount string, path string) error {
+	if backendType != secrets.TypeVault {
+		d.secrets = secrets.NewFile(filepath.Join(d.os.VarDir, "secrets"))
+		return nil
+	}
+
+	backend, err := secrets.NewVault(address, token, mount, path)
+	if err != nil {
+		return err
+	}
+
+	d.secrets = backend
+	return nil
+}
+
 // Create a database connection and perform any updates needed.
 func initializeDbObject(d *Daemon) (*db.Dump, error) {
 	logger.Info("Initializing local database")
@@ -1424,6 +1571,12 @@ func (d *Daemon) NodeRefreshTask(heartbeatData *cluster.APIHeartbeat) {
 		}
 	}
 
+	// React to changes of the cluster roles assigned to this member.
+	member, ok := heartbeatData.Members[d.cluster.GetNodeID()]
+	if ok {
+		d.clusterRolesRefresh(member.Roles)
+	}
+
 	// Only refresh forkdns peers if the full state list has been generated.
 	if heartbeatData.FullStateList && len(heartbeatData.Members) > 0 {
 		for i, node := range heartbeatData.Members {
@@ -135,9 +135,9 @@ func daemonStorageValidate(s *state.State, target string) error {
 		return errors.Wrapf(err, "Unable to load storage pool \"%s\"", poolName)
 	}
 
-	// Validate pool driver (can't be CEPH or CEPHFS)
-	if dbPool.Driver == "ceph" || dbPool.Driver == "cephfs" {
-		return fmt.Errorf("Server storage volumes cannot be stored on Ceph")
+	// Validate pool driver (can't be CEPH, CEPHFS or iSCSI)
+	if dbPool.Driver == "ceph" || dbPool.Driver == "cephfs" || dbPool.Driver == "iscsi" {
+		return fmt.Errorf("Server storage volumes cannot be stored on remote storage pools")
 	}
 
 	// Confirm volume exists
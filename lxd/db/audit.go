@@ -0,0 +1,138 @@
+// +build linux,cgo,!agent
+
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db/query"
+)
+
+// AuditEntry holds information about a single API request recorded in the
+// audit log.
+type AuditEntry struct {
+	ID         int64     // Stable database identifier
+	Date       time.Time // Time the request was handled
+	Location   string    // Name of the node which handled the request
+	Method     string    // HTTP method of the request
+	URL        string    // Path of the request
+	Project    string    // Project the request applied to
+	Protocol   string    // Protocol the requestor authenticated with
+	Username   string    // Identity of the requestor (certificate fingerprint for TLS)
+	Address    string    // Address the request came from
+	StatusCode int       // HTTP status code of the response
+}
+
+// AuditFilter can be used to filter results yielded by AuditEntries.
+type AuditFilter struct {
+	Project  string    // If non-empty, return only entries of this project.
+	Username string    // If non-empty, return only entries of this requestor.
+	Method   string    // If non-empty, return only entries with this method.
+	Since    time.Time // If non-zero, return only entries recorded at or after this time.
+	Until    time.Time // If non-zero, return only entries recorded before this time.
+	Limit    int       // If non-zero, return only this many of the most recent entries.
+}
+
+// AuditEntries returns all audit log entries matching the given filter,
+// oldest first.
+func (c *ClusterTx) AuditEntries(filter AuditFilter) ([]AuditEntry, error) {
+	where := ""
+	args := []interface{}{}
+	addClause := func(clause string, arg interface{}) {
+		if where != "" {
+			where += " AND "
+		}
+
+		where += clause
+		args = append(args, arg)
+	}
+
+	if filter.Project != "" {
+		addClause("project = ?", filter.Project)
+	}
+
+	if filter.Username != "" {
+		addClause("username = ?", filter.Username)
+	}
+
+	if filter.Method != "" {
+		addClause("method = ?", filter.Method)
+	}
+
+	if !filter.Since.IsZero() {
+		addClause("date >= ?", filter.Since.UTC())
+	}
+
+	if !filter.Until.IsZero() {
+		addClause("date < ?", filter.Until.UTC())
+	}
+
+	entries := []AuditEntry{}
+	dest := func(i int) []interface{} {
+		entries = append(entries, AuditEntry{})
+		return []interface{}{
+			&entries[i].ID,
+			&entries[i].Date,
+			&entries[i].Location,
+			&entries[i].Method,
+			&entries[i].URL,
+			&entries[i].Project,
+			&entries[i].Protocol,
+			&entries[i].Username,
+			&entries[i].Address,
+			&entries[i].StatusCode,
+		}
+	}
+
+	sql := "SELECT id, date, location, method, url, project, protocol, username, address, status_code FROM audit_log "
+	if where != "" {
+		sql += fmt.Sprintf("WHERE %s ", where)
+	}
+
+	// The most recent entries are selected, but returned oldest first.
+	sql += "ORDER BY id DESC"
+	if filter.Limit > 0 {
+		sql += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	stmt, err := c.tx.Prepare(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch audit log entries")
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, nil
+}
+
+// AuditEntryCreate records a new entry in the audit log. The date of the
+// entry is ignored and set to the current time.
+func (c *ClusterTx) AuditEntryCreate(entry AuditEntry) error {
+	_, err := c.tx.Exec(`
+INSERT INTO audit_log (date, location, method, url, project, protocol, username, address, status_code)
+  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+`, time.Now().UTC(), entry.Location, entry.Method, entry.URL, entry.Project, entry.Protocol, entry.Username, entry.Address, entry.StatusCode)
+	if err != nil {
+		return errors.Wrap(err, "Failed to record audit log entry")
+	}
+
+	return nil
+}
+
+// AuditEntriesPrune removes all audit log entries recorded before the given
+// time.
+func (c *ClusterTx) AuditEntriesPrune(before time.Time) error {
+	_, err := c.tx.Exec("DELETE FROM audit_log WHERE date < ?", before.UTC())
+	return err
+}
@@ -0,0 +1,62 @@
+// +build linux,cgo,!agent
+
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Record, filter and prune audit log entries.
+func TestAuditEntries(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	entries := []db.AuditEntry{
+		{Location: "none", Method: "POST", URL: "/1.0/instances", Project: "default", Protocol: "tls", Username: "abcd", StatusCode: 202},
+		{Location: "none", Method: "PUT", URL: "/1.0", Project: "default", Protocol: "unix", Username: "root", StatusCode: 200},
+		{Location: "none", Method: "DELETE", URL: "/1.0/instances/c1", Project: "p1", Protocol: "tls", Username: "abcd", StatusCode: 404},
+	}
+
+	for _, entry := range entries {
+		err := tx.AuditEntryCreate(entry)
+		require.NoError(t, err)
+	}
+
+	all, err := tx.AuditEntries(db.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "POST", all[0].Method)
+	assert.Equal(t, 404, all[2].StatusCode)
+
+	filtered, err := tx.AuditEntries(db.AuditFilter{Username: "abcd"})
+	require.NoError(t, err)
+	assert.Len(t, filtered, 2)
+
+	filtered, err = tx.AuditEntries(db.AuditFilter{Project: "p1", Method: "DELETE"})
+	require.NoError(t, err)
+	require.Len(t, filtered, 1)
+	assert.Equal(t, "/1.0/instances/c1", filtered[0].URL)
+
+	// The limit keeps the most recent entries.
+	filtered, err = tx.AuditEntries(db.AuditFilter{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, filtered, 2)
+	assert.Equal(t, "PUT", filtered[0].Method)
+	assert.Equal(t, "DELETE", filtered[1].Method)
+
+	filtered, err = tx.AuditEntries(db.AuditFilter{Since: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Len(t, filtered, 0)
+
+	err = tx.AuditEntriesPrune(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	all, err = tx.AuditEntries(db.AuditFilter{})
+	require.NoError(t, err)
+	assert.Len(t, all, 0)
+}
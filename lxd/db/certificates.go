@@ -4,6 +4,9 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
+
+	"github.com/lxc/lxd/lxd/db/query"
 )
 
 // CertInfo is here to pass the certificates content
@@ -14,13 +17,15 @@ type CertInfo struct {
 	Type        int
 	Name        string
 	Certificate string
+	Restricted  bool
+	Projects    []string
 }
 
 // CertificatesGet returns all certificates from the DB as CertBaseInfo objects.
 func (c *Cluster) CertificatesGet() (certs []*CertInfo, err error) {
 	err = c.Transaction(func(tx *ClusterTx) error {
 		rows, err := tx.tx.Query(
-			"SELECT id, fingerprint, type, name, certificate FROM certificates",
+			"SELECT id, fingerprint, type, name, certificate, restricted FROM certificates",
 		)
 		if err != nil {
 			return err
@@ -36,11 +41,24 @@ func (c *Cluster) CertificatesGet() (certs []*CertInfo, err error) {
 				&cert.Type,
 				&cert.Name,
 				&cert.Certificate,
+				&cert.Restricted,
 			)
 			certs = append(certs, cert)
 		}
 
-		return rows.Err()
+		err = rows.Err()
+		if err != nil {
+			return err
+		}
+
+		for _, cert := range certs {
+			cert.Projects, err = certificateProjects(tx.tx, cert.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
 	})
 	if err != nil {
 		return certs, err
@@ -64,11 +82,12 @@ func (c *Cluster) CertificateGet(fingerprint string) (cert *CertInfo, err error)
 		&cert.Type,
 		&cert.Name,
 		&cert.Certificate,
+		&cert.Restricted,
 	}
 
 	query := `
 		SELECT
-			id, fingerprint, type, name, certificate
+			id, fingerprint, type, name, certificate, restricted
 		FROM
 			certificates
 		WHERE fingerprint LIKE ?`
@@ -81,9 +100,56 @@ func (c *Cluster) CertificateGet(fingerprint string) (cert *CertInfo, err error)
 		return nil, err
 	}
 
+	err = c.Transaction(func(tx *ClusterTx) error {
+		cert.Projects, err = certificateProjects(tx.tx, cert.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	return cert, err
 }
 
+// certificateProjects returns the names of the projects a certificate is restricted to.
+func certificateProjects(tx *sql.Tx, id int) ([]string, error) {
+	stmt := `
+SELECT projects.name FROM projects
+  JOIN certificates_projects ON certificates_projects.project_id = projects.id
+  WHERE certificates_projects.certificate_id = ?
+  ORDER BY projects.name`
+
+	return query.SelectStrings(tx, stmt, id)
+}
+
+// certificateProjectsSet replaces the list of projects a certificate is restricted to.
+func certificateProjectsSet(tx *sql.Tx, id int64, projects []string) error {
+	_, err := tx.Exec("DELETE FROM certificates_projects WHERE certificate_id=?", id)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range projects {
+		result, err := tx.Exec(`
+INSERT INTO certificates_projects (certificate_id, project_id)
+  SELECT ?, id FROM projects WHERE name = ?`, id, name)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n != 1 {
+			return fmt.Errorf("Project %q doesn't exist", name)
+		}
+	}
+
+	return nil
+}
+
 // CertSave stores a CertBaseInfo object in the db,
 // it will ignore the ID field from the CertInfo.
 func (c *Cluster) CertSave(cert *CertInfo) error {
@@ -93,23 +159,31 @@ func (c *Cluster) CertSave(cert *CertInfo) error {
 				fingerprint,
 				type,
 				name,
-				certificate
-			) VALUES (?, ?, ?, ?)`,
+				certificate,
+				restricted
+			) VALUES (?, ?, ?, ?, ?)`,
 		)
 		if err != nil {
 			return err
 		}
 		defer stmt.Close()
-		_, err = stmt.Exec(
+		result, err := stmt.Exec(
 			cert.Fingerprint,
 			cert.Type,
 			cert.Name,
 			cert.Certificate,
+			cert.Restricted,
 		)
 		if err != nil {
 			return err
 		}
-		return nil
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		return certificateProjectsSet(tx.tx, id, cert.Projects)
 	})
 	return err
 }
@@ -125,10 +199,23 @@ func (c *Cluster) CertDelete(fingerprint string) error {
 }
 
 // CertUpdate updates the certificate with the given fingerprint.
-func (c *Cluster) CertUpdate(fingerprint string, certName string, certType int) error {
+func (c *Cluster) CertUpdate(fingerprint string, certName string, certType int, restricted bool, projects []string) error {
 	err := c.Transaction(func(tx *ClusterTx) error {
-		_, err := tx.tx.Exec("UPDATE certificates SET name=?, type=? WHERE fingerprint=?", certName, certType, fingerprint)
-		return err
+		_, err := tx.tx.Exec("UPDATE certificates SET name=?, type=?, restricted=? WHERE fingerprint=?", certName, certType, restricted, fingerprint)
+		if err != nil {
+			return err
+		}
+
+		id, err := query.SelectIntegers(tx.tx, "SELECT id FROM certificates WHERE fingerprint=?", fingerprint)
+		if err != nil {
+			return err
+		}
+
+		if len(id) != 1 {
+			return ErrNoSuchObject
+		}
+
+		return certificateProjectsSet(tx.tx, int64(id[0]), projects)
 	})
 	return err
 }
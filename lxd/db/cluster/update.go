@@ -58,6 +58,202 @@ var updates = map[int]schema.Update{
 	20: updateFromV19,
 	21: updateFromV20,
 	22: updateFromV21,
+	23: updateFromV22,
+	24: updateFromV23,
+	25: updateFromV24,
+	26: updateFromV25,
+	27: updateFromV26,
+	28: updateFromV27,
+	29: updateFromV28,
+}
+
+// Add a project_id column to the networks table, so that projects with the
+// networks feature enabled can have their own networks. Existing networks
+// belong to the default project.
+func updateFromV28(tx *sql.Tx) error {
+	stmts := `
+CREATE TABLE new_networks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    project_id INTEGER NOT NULL DEFAULT 1,
+    name TEXT NOT NULL,
+    description TEXT,
+    state INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (project_id, name),
+    FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE
+);
+
+-- Create copy version of the tables that reference the networks table, which
+-- we are going to drop. The copy just have the data, without FOREIGN KEY
+-- references.
+CREATE TABLE networks_config_copy (
+    id INTEGER NOT NULL,
+    network_id INTEGER NOT NULL,
+    node_id INTEGER,
+    key TEXT NOT NULL,
+    value TEXT
+);
+INSERT INTO networks_config_copy SELECT * FROM networks_config;
+
+CREATE TABLE networks_nodes_copy (
+    id INTEGER NOT NULL,
+    network_id INTEGER NOT NULL,
+    node_id INTEGER NOT NULL
+);
+INSERT INTO networks_nodes_copy SELECT * FROM networks_nodes;
+
+-- Copy existing networks into the default project.
+INSERT INTO new_networks (id, project_id, name, description, state)
+  SELECT id, 1, name, description, state FROM networks;
+
+-- Drop the old table and rename the new one. This will trigger cascading
+-- deletes on the tables that reference the old table, but we have a copy of
+-- them that we will use for restoring.
+DROP TABLE networks;
+ALTER TABLE new_networks RENAME TO networks;
+
+INSERT INTO networks_config SELECT * FROM networks_config_copy;
+INSERT INTO networks_nodes SELECT * FROM networks_nodes_copy;
+
+DROP TABLE networks_config_copy;
+DROP TABLE networks_nodes_copy;
+
+DROP VIEW projects_used_by_ref;
+CREATE VIEW projects_used_by_ref (name,
+    value) AS
+  SELECT projects.name,
+    printf('/1.0/instances/%s?project=%s',
+    "instances".name,
+    projects.name)
+    FROM "instances" JOIN projects ON project_id=projects.id UNION
+  SELECT projects.name,
+    printf('/1.0/images/%s',
+    images.fingerprint)
+    FROM images JOIN projects ON project_id=projects.id UNION
+  SELECT projects.name,
+    printf('/1.0/profiles/%s?project=%s',
+    profiles.name,
+    projects.name)
+    FROM profiles JOIN projects ON project_id=projects.id UNION
+  SELECT projects.name,
+    printf('/1.0/networks/%s?project=%s',
+    networks.name,
+    projects.name)
+    FROM networks JOIN projects ON project_id=projects.id;
+`
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+// Give a UUID to existing instances, snapshots and storage volumes. The rows of a volume shared by
+// all nodes, such as a Ceph one, get the same UUID.
+func updateFromV27(tx *sql.Tx) error {
+	uuid := `lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+  substr(lower(hex(randomblob(2))), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) ||
+  substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6)))`
+
+	stmts := fmt.Sprintf(`
+INSERT INTO instances_config (instance_id, key, value)
+  SELECT id, 'volatile.uuid', %s FROM instances
+  WHERE id NOT IN (SELECT instance_id FROM instances_config WHERE key = 'volatile.uuid');
+INSERT INTO instances_snapshots_config (instance_snapshot_id, key, value)
+  SELECT id, 'volatile.uuid', %s FROM instances_snapshots
+  WHERE id NOT IN (SELECT instance_snapshot_id FROM instances_snapshots_config WHERE key = 'volatile.uuid');
+INSERT INTO storage_volumes_config (storage_volume_id, key, value)
+  SELECT id, 'volatile.uuid', %s FROM storage_volumes
+  WHERE id NOT IN (SELECT storage_volume_id FROM storage_volumes_config WHERE key = 'volatile.uuid');
+UPDATE storage_volumes_config SET value = (
+  SELECT first.value FROM storage_volumes_config AS first
+    JOIN storage_volumes AS first_volume ON first_volume.id = first.storage_volume_id
+    JOIN storage_volumes AS volume ON volume.id = storage_volumes_config.storage_volume_id
+  WHERE first.key = 'volatile.uuid'
+    AND first_volume.storage_pool_id = volume.storage_pool_id
+    AND first_volume.project_id = volume.project_id
+    AND first_volume.type = volume.type
+    AND first_volume.name = volume.name
+  ORDER BY first_volume.id LIMIT 1)
+WHERE key = 'volatile.uuid';
+`, uuid, uuid, uuid)
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+// Add audit_log table.
+func updateFromV26(tx *sql.Tx) error {
+	stmts := `
+CREATE TABLE audit_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	date DATETIME NOT NULL,
+	location TEXT NOT NULL,
+	method TEXT NOT NULL,
+	url TEXT NOT NULL,
+	project TEXT NOT NULL,
+	protocol TEXT NOT NULL,
+	username TEXT NOT NULL,
+	address TEXT NOT NULL,
+	status_code INTEGER NOT NULL
+);
+CREATE INDEX audit_log_date_idx ON audit_log (date);
+`
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+// Add pinned flag to images.
+func updateFromV25(tx *sql.Tx) error {
+	_, err := tx.Exec("ALTER TABLE images ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0;")
+	return err
+}
+
+// Add restricted flag and projects to certificates.
+func updateFromV24(tx *sql.Tx) error {
+	stmts := `
+ALTER TABLE certificates ADD COLUMN restricted INTEGER NOT NULL DEFAULT 0;
+CREATE TABLE certificates_projects (
+	certificate_id INTEGER NOT NULL,
+	project_id INTEGER NOT NULL,
+	FOREIGN KEY (certificate_id) REFERENCES certificates (id) ON DELETE CASCADE,
+	FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE,
+	UNIQUE (certificate_id, project_id)
+);
+`
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+// Add warnings table.
+func updateFromV23(tx *sql.Tx) error {
+	stmts := `
+CREATE TABLE warnings (
+	id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+	node_id INTEGER,
+	project_id INTEGER,
+	entity_type_code INTEGER,
+	entity_id INTEGER,
+	uuid TEXT NOT NULL,
+	type_code INTEGER NOT NULL,
+	status INTEGER NOT NULL,
+	first_seen_date DATETIME NOT NULL,
+	last_seen_date DATETIME NOT NULL,
+	updated_date DATETIME,
+	last_message TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	UNIQUE (uuid),
+	FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE,
+	FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+// Add content_type column to storage_volumes.
+func updateFromV22(tx *sql.Tx) error {
+	stmts := `
+ALTER TABLE storage_volumes ADD COLUMN content_type INTEGER NOT NULL DEFAULT 0;
+UPDATE storage_volumes SET content_type = 1 WHERE type = 3;
+`
+	_, err := tx.Exec(stmts)
+	return err
 }
 
 // Fix "images_profiles" table (missing UNIQUE)
@@ -620,3 +620,154 @@ VALUES (2, 'n2', '', '2.2.3.4:666', 1, 32, ?, 0)`, time.Now())
 	require.True(t, ok)
 	assert.Equal(t, sqliteErr.Code, sqlite3.ErrConstraint)
 }
+
+func TestUpdateFromV24(t *testing.T) {
+	schema := cluster.Schema()
+	db, err := schema.ExerciseUpdate(25, func(db *sql.DB) {
+		_, err := db.Exec(
+			"INSERT INTO certificates VALUES (1, 'abcd', 1, 'foo', 'cert')")
+		require.NoError(t, err)
+	})
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Existing certificates aren't restricted.
+	row := db.QueryRow("SELECT restricted FROM certificates WHERE id=1")
+	restricted := true
+	err = row.Scan(&restricted)
+	require.NoError(t, err)
+	assert.False(t, restricted)
+
+	_, err = db.Exec("INSERT INTO certificates_projects VALUES (1, 1)")
+	require.NoError(t, err)
+
+	// Deleting the certificate deletes its projects.
+	_, err = db.Exec("DELETE FROM certificates WHERE id=1")
+	require.NoError(t, err)
+
+	count := 0
+	row = db.QueryRow("SELECT count(*) FROM certificates_projects")
+	err = row.Scan(&count)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestUpdateFromV25(t *testing.T) {
+	schema := cluster.Schema()
+	db, err := schema.ExerciseUpdate(26, func(db *sql.DB) {
+		_, err := db.Exec(`
+INSERT INTO images (id, fingerprint, filename, size, architecture, upload_date, cached, project_id)
+VALUES (1, 'abcd', 'foo', 1, 1, 0, 1, 1)`)
+		require.NoError(t, err)
+	})
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Existing images aren't pinned.
+	row := db.QueryRow("SELECT pinned FROM images WHERE id=1")
+	pinned := true
+	err = row.Scan(&pinned)
+	require.NoError(t, err)
+	assert.False(t, pinned)
+}
+
+func TestUpdateFromV27(t *testing.T) {
+	schema := cluster.Schema()
+	db, err := schema.ExerciseUpdate(28, func(db *sql.DB) {
+		stmts := []string{
+			"INSERT INTO nodes (id, name, address, schema, api_extensions, arch) VALUES (1, 'n1', '1.2.3.4:666', 1, 32, 1)",
+			"INSERT INTO nodes (id, name, address, schema, api_extensions, arch) VALUES (2, 'n2', '5.6.7.8:666', 1, 32, 1)",
+			"INSERT INTO instances (id, node_id, name, architecture, type, project_id) VALUES (1, 1, 'c1', 1, 0, 1)",
+			"INSERT INTO instances_config (instance_id, key, value) VALUES (1, 'volatile.uuid', 'abcd')",
+			"INSERT INTO instances (id, node_id, name, architecture, type, project_id) VALUES (2, 1, 'c2', 1, 0, 1)",
+			"INSERT INTO instances_snapshots (id, instance_id, name) VALUES (1, 2, 'snap0')",
+			"INSERT INTO storage_pools (id, name, driver) VALUES (1, 'p1', 'ceph')",
+			"INSERT INTO storage_volumes (id, name, storage_pool_id, node_id, type, project_id) VALUES (1, 'v1', 1, 1, 2, 1)",
+			"INSERT INTO storage_volumes (id, name, storage_pool_id, node_id, type, project_id) VALUES (2, 'v1', 1, 2, 2, 1)",
+			"INSERT INTO storage_volumes (id, name, storage_pool_id, node_id, type, project_id) VALUES (3, 'v2', 1, 1, 2, 1)",
+		}
+
+		for _, stmt := range stmts {
+			_, err := db.Exec(stmt)
+			require.NoError(t, err)
+		}
+	})
+	require.NoError(t, err)
+	defer db.Close()
+
+	uuids := func(table string, column string) map[int]string {
+		rows, err := db.Query(fmt.Sprintf("SELECT %s, value FROM %s WHERE key = 'volatile.uuid'", column, table))
+		require.NoError(t, err)
+		defer rows.Close()
+
+		values := map[int]string{}
+		for rows.Next() {
+			var id int
+			var value string
+			require.NoError(t, rows.Scan(&id, &value))
+			values[id] = value
+		}
+
+		require.NoError(t, rows.Err())
+		return values
+	}
+
+	// Existing UUIDs are kept.
+	instances := uuids("instances_config", "instance_id")
+	require.Len(t, instances, 2)
+	assert.Equal(t, "abcd", instances[1])
+	assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", instances[2])
+
+	snapshots := uuids("instances_snapshots_config", "instance_snapshot_id")
+	require.Len(t, snapshots, 1)
+	assert.NotEqual(t, instances[2], snapshots[1])
+
+	// The rows of the volume on both nodes share its UUID.
+	volumes := uuids("storage_volumes_config", "storage_volume_id")
+	require.Len(t, volumes, 3)
+	assert.Equal(t, volumes[1], volumes[2])
+	assert.NotEqual(t, volumes[1], volumes[3])
+}
+
+func TestUpdateFromV28(t *testing.T) {
+	schema := cluster.Schema()
+	db, err := schema.ExerciseUpdate(29, func(db *sql.DB) {
+		stmts := []string{
+			"INSERT INTO nodes (id, name, address, schema, api_extensions, arch) VALUES (1, 'n1', '1.2.3.4:666', 1, 32, 1)",
+			"INSERT INTO networks (id, name, state) VALUES (1, 'lxdbr0', 1)",
+			"INSERT INTO networks_config (network_id, node_id, key, value) VALUES (1, NULL, 'ipv4.address', '10.0.0.1/24')",
+			"INSERT INTO networks_nodes (network_id, node_id) VALUES (1, 1)",
+			"INSERT INTO projects (id, name) VALUES (2, 'p1')",
+		}
+
+		for _, stmt := range stmts {
+			_, err := db.Exec(stmt)
+			require.NoError(t, err)
+		}
+	})
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Existing networks are moved to the default project, with their config and nodes.
+	var projectID int
+	err = db.QueryRow("SELECT project_id FROM networks WHERE name = 'lxdbr0'").Scan(&projectID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, projectID)
+
+	var value string
+	err = db.QueryRow("SELECT value FROM networks_config WHERE network_id = 1 AND key = 'ipv4.address'").Scan(&value)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1/24", value)
+
+	var nodeID int
+	err = db.QueryRow("SELECT node_id FROM networks_nodes WHERE network_id = 1").Scan(&nodeID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, nodeID)
+
+	// Network names are unique within a project only.
+	_, err = db.Exec("INSERT INTO networks (project_id, name) VALUES (2, 'lxdbr0')")
+	require.NoError(t, err)
+
+	_, err = db.Exec("INSERT INTO networks (project_id, name) VALUES (1, 'lxdbr0')")
+	require.Error(t, err)
+}
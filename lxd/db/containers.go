@@ -442,10 +442,10 @@ func (c *ClusterTx) snapshotIDsAndNames(project, name string) (map[int]string, e
 // ContainerNodeMove changes the node associated with a container.
 //
 // It's meant to be used when moving a non-running container backed by ceph
-// from one cluster node to another.
+// or iscsi from one cluster node to another.
 func (c *ClusterTx) ContainerNodeMove(project, oldName, newName, newNode string) error {
-	// First check that the container to be moved is backed by a ceph
-	// volume.
+	// First check that the container to be moved is backed by a ceph or
+	// iscsi volume.
 	poolName, err := c.InstancePool(project, oldName)
 	if err != nil {
 		return errors.Wrap(err, "failed to get container's storage pool name")
@@ -458,8 +458,8 @@ func (c *ClusterTx) ContainerNodeMove(project, oldName, newName, newNode string)
 	if err != nil {
 		return errors.Wrap(err, "failed to get container's storage pool driver")
 	}
-	if poolDriver != "ceph" {
-		return fmt.Errorf("container's storage pool is not of type ceph")
+	if poolDriver != "ceph" && poolDriver != "iscsi" {
+		return fmt.Errorf("container's storage pool is not of type ceph or iscsi")
 	}
 
 	// Update the name of the container and of its snapshots, and the node
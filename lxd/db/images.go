@@ -88,48 +88,119 @@ SELECT fingerprint
 	return results, nil
 }
 
-// ImagesGetExpired returns the names of all images that have expired since the
-// given time.
-func (c *Cluster) ImagesGetExpired(expiry int64) ([]string, error) {
-	q := `SELECT fingerprint, last_use_date, upload_date FROM images WHERE cached=1`
+// ImagesGetExpired returns the fingerprints of the cached images of each project which expired,
+// given the expiry in days of the projects. Projects missing from the map use the default expiry,
+// an expiry of zero or less disables expiry and pinned images never expire.
+func (c *Cluster) ImagesGetExpired(expiry int64, projectsExpiry map[string]int64) (map[string][]string, error) {
+	q := `
+SELECT projects.name, images.fingerprint, images.last_use_date, images.upload_date
+  FROM images
+  JOIN projects ON projects.id = images.project_id
+ WHERE images.cached=1 AND images.pinned=0
+`
 
+	var projectStr string
 	var fpStr string
 	var useStr string
 	var uploadStr string
 
 	inargs := []interface{}{}
-	outfmt := []interface{}{fpStr, useStr, uploadStr}
+	outfmt := []interface{}{projectStr, fpStr, useStr, uploadStr}
 	dbResults, err := queryScan(c.db, q, inargs, outfmt)
 	if err != nil {
-		return []string{}, err
+		return nil, err
 	}
 
-	results := []string{}
+	results := map[string][]string{}
 	for _, r := range dbResults {
+		project := r[0].(string)
+
+		projectExpiry, ok := projectsExpiry[project]
+		if !ok {
+			projectExpiry = expiry
+		}
+
+		if projectExpiry <= 0 {
+			continue
+		}
+
 		// Figure out the expiry
-		timestamp := r[2]
-		if r[1] != "" {
-			timestamp = r[1]
+		timestamp := r[3]
+		if r[2] != "" {
+			timestamp = r[2]
 		}
 
 		var imageExpiry time.Time
 		err = imageExpiry.UnmarshalText([]byte(timestamp.(string)))
 		if err != nil {
-			return []string{}, err
+			return nil, err
 		}
-		imageExpiry = imageExpiry.Add(time.Duration(expiry*24) * time.Hour)
+		imageExpiry = imageExpiry.Add(time.Duration(projectExpiry*24) * time.Hour)
 
 		// Check if expired
 		if imageExpiry.After(time.Now()) {
 			continue
 		}
 
-		results = append(results, r[0].(string))
+		results[project] = append(results[project], r[1].(string))
 	}
 
 	return results, nil
 }
 
+// ImageCached is a cached image along with the source it was downloaded from.
+type ImageCached struct {
+	ID          int
+	Project     string
+	Fingerprint string
+	Server      string
+	Alias       string
+	Pinned      bool
+}
+
+// ImagesGetCached returns all the cached images with their source.
+func (c *Cluster) ImagesGetCached() ([]ImageCached, error) {
+	q := `
+SELECT images.id, projects.name, images.fingerprint, coalesce(images_source.server, ''), coalesce(images_source.alias, ''), images.pinned
+  FROM images
+  JOIN projects ON projects.id = images.project_id
+  LEFT JOIN images_source ON images_source.image_id = images.id
+ WHERE images.cached=1
+`
+
+	images := []ImageCached{}
+	err := c.Transaction(func(tx *ClusterTx) error {
+		rows, err := tx.tx.Query(q)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			image := ImageCached{}
+			err := rows.Scan(&image.ID, &image.Project, &image.Fingerprint, &image.Server, &image.Alias, &image.Pinned)
+			if err != nil {
+				return err
+			}
+
+			images = append(images, image)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+// ImageSetPinned sets whether a cached image is pinned, pinned images never expire.
+func (c *Cluster) ImageSetPinned(id int, pinned bool) error {
+	err := exec(c.db, `UPDATE images SET pinned=? WHERE id=?`, pinned, id)
+	return err
+}
+
 // ImageSourceInsert inserts a new image source.
 func (c *Cluster) ImageSourceInsert(id int, server string, protocol string, certificate string, alias string) error {
 	stmt := `INSERT INTO images_source (image_id, server, protocol, certificate, alias) values (?, ?, ?, ?, ?)`
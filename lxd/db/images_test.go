@@ -39,3 +39,45 @@ func TestImageLocate(t *testing.T) {
 	require.Equal(t, "", address)
 	require.EqualError(t, err, "image not available on any online node")
 }
+
+func TestImagesGetExpired(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	err := cluster.ImageInsert(
+		"default", "abc", "x.gz", 16, false, false, "amd64", time.Now(), time.Now(), map[string]string{}, "container")
+	require.NoError(t, err)
+
+	err = cluster.ImageLastAccessInit("abc")
+	require.NoError(t, err)
+
+	err = cluster.ImageLastAccessUpdate("abc", time.Now().Add(-5*24*time.Hour))
+	require.NoError(t, err)
+
+	images, err := cluster.ImagesGetExpired(10, nil)
+	require.NoError(t, err)
+	assert.Empty(t, images)
+
+	// The expiry of the project takes precedence.
+	images, err = cluster.ImagesGetExpired(10, map[string]int64{"default": 2})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"default": {"abc"}}, images)
+
+	images, err = cluster.ImagesGetExpired(2, map[string]int64{"default": 0})
+	require.NoError(t, err)
+	assert.Empty(t, images)
+
+	// Pinned images never expire.
+	cached, err := cluster.ImagesGetCached()
+	require.NoError(t, err)
+	require.Len(t, cached, 1)
+	assert.Equal(t, "abc", cached[0].Fingerprint)
+	assert.False(t, cached[0].Pinned)
+
+	err = cluster.ImageSetPinned(cached[0].ID, true)
+	require.NoError(t, err)
+
+	images, err = cluster.ImagesGetExpired(2, nil)
+	require.NoError(t, err)
+	assert.Empty(t, images)
+}
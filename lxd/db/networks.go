@@ -14,8 +14,11 @@ import (
 
 // NetworksNodeConfig returns a map associating each network name to its
 // node-specific config values (i.e. the ones where node_id is not NULL).
+//
+// Only the networks of the default project are returned, since the networks
+// of other projects are not spread across the cluster.
 func (c *ClusterTx) NetworksNodeConfig() (map[string]map[string]string, error) {
-	names, err := query.SelectStrings(c.tx, "SELECT name FROM networks")
+	names, err := query.SelectStrings(c.tx, "SELECT name FROM networks WHERE project_id=1")
 	if err != nil {
 		return nil, err
 	}
@@ -23,7 +26,7 @@ func (c *ClusterTx) NetworksNodeConfig() (map[string]map[string]string, error) {
 	for _, name := range names {
 		table := "networks_config JOIN networks ON networks.id=networks_config.network_id"
 		config, err := query.SelectConfig(
-			c.tx, table, "networks.name=? AND networks_config.node_id=?",
+			c.tx, table, "networks.project_id=1 AND networks.name=? AND networks_config.node_id=?",
 			name, c.nodeID)
 		if err != nil {
 			return nil, err
@@ -33,7 +36,8 @@ func (c *ClusterTx) NetworksNodeConfig() (map[string]map[string]string, error) {
 	return networks, nil
 }
 
-// NetworkIDsNotPending returns a map associating each network name to its ID.
+// NetworkIDsNotPending returns a map associating each network name of the
+// default project to its ID.
 //
 // Pending networks are skipped.
 func (c *ClusterTx) NetworkIDsNotPending() (map[string]int64, error) {
@@ -49,7 +53,7 @@ func (c *ClusterTx) NetworkIDsNotPending() (map[string]int64, error) {
 		return []interface{}{&networks[i].id, &networks[i].name}
 
 	}
-	stmt, err := c.tx.Prepare("SELECT id, name FROM networks WHERE NOT state=?")
+	stmt, err := c.tx.Prepare("SELECT id, name FROM networks WHERE project_id=1 AND NOT state=?")
 	if err != nil {
 		return nil, err
 	}
@@ -65,10 +69,15 @@ func (c *ClusterTx) NetworkIDsNotPending() (map[string]int64, error) {
 	return ids, nil
 }
 
-// NetworkID returns the ID of the network with the given name.
-func (c *ClusterTx) NetworkID(name string) (int64, error) {
-	stmt := "SELECT id FROM networks WHERE name=?"
-	ids, err := query.SelectIntegers(c.tx, stmt, name)
+// NetworkID returns the ID of the network with the given name in the given
+// project.
+func (c *ClusterTx) NetworkID(project string, name string) (int64, error) {
+	stmt := `
+SELECT networks.id FROM networks
+  JOIN projects ON projects.id = networks.project_id
+ WHERE projects.name = ? AND networks.name = ?
+`
+	ids, err := query.SelectIntegers(c.tx, stmt, project, name)
 	if err != nil {
 		return -1, err
 	}
@@ -146,8 +155,8 @@ WHERE networks.id = ? AND networks.state = ?
 	return configs, nil
 }
 
-// NetworkCreatePending creates a new pending network on the node with
-// the given name.
+// NetworkCreatePending creates a new pending network of the default project on
+// the node with the given name.
 func (c *ClusterTx) NetworkCreatePending(node, name string, conf map[string]string) error {
 	// First check if a network with the given name exists, and, if
 	// so, that it's in the pending state.
@@ -164,7 +173,7 @@ func (c *ClusterTx) NetworkCreatePending(node, name string, conf map[string]stri
 		}
 		return []interface{}{&network.id, &network.state}
 	}
-	stmt, err := c.tx.Prepare("SELECT id, state FROM networks WHERE name=?")
+	stmt, err := c.tx.Prepare("SELECT id, state FROM networks WHERE project_id=1 AND name=?")
 	if err != nil {
 		return err
 	}
@@ -225,18 +234,20 @@ func (c *ClusterTx) NetworkCreatePending(node, name string, conf map[string]stri
 	return nil
 }
 
-// NetworkCreated sets the state of the given network to "Created".
+// NetworkCreated sets the state of the given network of the default project to
+// "Created".
 func (c *ClusterTx) NetworkCreated(name string) error {
 	return c.networkState(name, networkCreated)
 }
 
-// NetworkErrored sets the state of the given network to "Errored".
+// NetworkErrored sets the state of the given network of the default project to
+// "Errored".
 func (c *ClusterTx) NetworkErrored(name string) error {
 	return c.networkState(name, networkErrored)
 }
 
 func (c *ClusterTx) networkState(name string, state int) error {
-	stmt := "UPDATE networks SET state=? WHERE name=?"
+	stmt := "UPDATE networks SET state=? WHERE project_id=1 AND name=?"
 	result, err := c.tx.Exec(stmt, state, name)
 	if err != nil {
 		return err
@@ -251,24 +262,28 @@ func (c *ClusterTx) networkState(name string, state int) error {
 	return nil
 }
 
-// Networks returns the names of existing networks.
-func (c *Cluster) Networks() ([]string, error) {
-	return c.networks("")
+// Networks returns the names of existing networks in the given project.
+func (c *Cluster) Networks(project string) ([]string, error) {
+	return c.networks(project, "")
 }
 
-// NetworksNotPending returns the names of all networks that are not
-// pending.
-func (c *Cluster) NetworksNotPending() ([]string, error) {
-	return c.networks("NOT state=?", networkPending)
+// NetworksNotPending returns the names of all networks in the given project
+// that are not pending.
+func (c *Cluster) NetworksNotPending(project string) ([]string, error) {
+	return c.networks(project, "NOT networks.state=?", networkPending)
 }
 
-// Get all networks matching the given WHERE filter (if given).
-func (c *Cluster) networks(where string, args ...interface{}) ([]string, error) {
-	q := "SELECT name FROM networks"
-	inargs := []interface{}{}
+// Get all networks of the given project matching the given WHERE filter (if
+// given).
+func (c *Cluster) networks(project string, where string, args ...interface{}) ([]string, error) {
+	q := `
+SELECT networks.name FROM networks
+  JOIN projects ON projects.id = networks.project_id
+ WHERE projects.name = ?`
+	inargs := []interface{}{project}
 
 	if where != "" {
-		q += fmt.Sprintf(" WHERE %s", where)
+		q += fmt.Sprintf(" AND %s", where)
 		for _, arg := range args {
 			inargs = append(inargs, arg)
 		}
@@ -296,14 +311,18 @@ const (
 	networkErrored            // Network creation failed on some nodes
 )
 
-// NetworkGet returns the network with the given name.
-func (c *Cluster) NetworkGet(name string) (int64, *api.Network, error) {
+// NetworkGet returns the network with the given name in the given project.
+func (c *Cluster) NetworkGet(project string, name string) (int64, *api.Network, error) {
 	description := sql.NullString{}
 	id := int64(-1)
 	state := 0
 
-	q := "SELECT id, description, state FROM networks WHERE name=?"
-	arg1 := []interface{}{name}
+	q := `
+SELECT networks.id, networks.description, networks.state FROM networks
+  JOIN projects ON projects.id = networks.project_id
+ WHERE projects.name = ? AND networks.name = ?
+`
+	arg1 := []interface{}{project, name}
 	arg2 := []interface{}{&id, &description, &state}
 	err := dbQueryRowScan(c.db, q, arg1, arg2)
 	if err != nil {
@@ -365,38 +384,45 @@ SELECT nodes.name FROM nodes
 }
 
 // NetworkGetInterface returns the network associated with the interface with
-// the given name.
-func (c *Cluster) NetworkGetInterface(devName string) (int64, *api.Network, error) {
+// the given name, along with the name of the project it belongs to.
+func (c *Cluster) NetworkGetInterface(devName string) (int64, string, *api.Network, error) {
 	id := int64(-1)
+	project := ""
 	name := ""
 	value := ""
 
-	q := "SELECT networks.id, networks.name, networks_config.value FROM networks LEFT JOIN networks_config ON networks.id=networks_config.network_id WHERE networks_config.key=\"bridge.external_interfaces\" AND networks_config.node_id=?"
+	q := `
+SELECT networks.id, projects.name, networks.name, networks_config.value FROM networks
+  JOIN projects ON projects.id = networks.project_id
+  LEFT JOIN networks_config ON networks.id = networks_config.network_id
+ WHERE networks_config.key = "bridge.external_interfaces" AND networks_config.node_id = ?
+`
 	arg1 := []interface{}{c.nodeID}
-	arg2 := []interface{}{id, name, value}
+	arg2 := []interface{}{id, project, name, value}
 	result, err := queryScan(c.db, q, arg1, arg2)
 	if err != nil {
-		return -1, nil, err
+		return -1, "", nil, err
 	}
 
 	for _, r := range result {
-		for _, entry := range strings.Split(r[2].(string), ",") {
+		for _, entry := range strings.Split(r[3].(string), ",") {
 			entry = strings.TrimSpace(entry)
 
 			if entry == devName {
 				id = r[0].(int64)
-				name = r[1].(string)
+				project = r[1].(string)
+				name = r[2].(string)
 			}
 		}
 	}
 
 	if id == -1 {
-		return -1, nil, fmt.Errorf("No network found for interface: %s", devName)
+		return -1, "", nil, fmt.Errorf("No network found for interface: %s", devName)
 	}
 
 	config, err := c.NetworkConfigGet(id)
 	if err != nil {
-		return -1, nil, err
+		return -1, "", nil, err
 	}
 
 	network := api.Network{
@@ -406,7 +432,7 @@ func (c *Cluster) NetworkGetInterface(devName string) (int64, *api.Network, erro
 	}
 	network.Config = config
 
-	return id, &network, nil
+	return id, project, &network, nil
 }
 
 // NetworkConfigGet returns the config map of the network with the given ID.
@@ -454,11 +480,15 @@ func (c *Cluster) NetworkConfigGet(id int64) (map[string]string, error) {
 	return config, nil
 }
 
-// NetworkCreate creates a new network.
-func (c *Cluster) NetworkCreate(name, description string, config map[string]string) (int64, error) {
+// NetworkCreate creates a new network in the given project.
+func (c *Cluster) NetworkCreate(project, name, description string, config map[string]string) (int64, error) {
 	var id int64
 	err := c.Transaction(func(tx *ClusterTx) error {
-		result, err := tx.tx.Exec("INSERT INTO networks (name, description, state) VALUES (?, ?, ?)", name, description, networkCreated)
+		stmt := `
+INSERT INTO networks (project_id, name, description, state)
+  VALUES ((SELECT id FROM projects WHERE name = ?), ?, ?, ?)
+`
+		result, err := tx.tx.Exec(stmt, project, name, description, networkCreated)
 		if err != nil {
 			return err
 		}
@@ -489,9 +519,9 @@ func (c *Cluster) NetworkCreate(name, description string, config map[string]stri
 	return id, err
 }
 
-// NetworkUpdate updates the network with the given name.
-func (c *Cluster) NetworkUpdate(name, description string, config map[string]string) error {
-	id, _, err := c.NetworkGet(name)
+// NetworkUpdate updates the network with the given name in the given project.
+func (c *Cluster) NetworkUpdate(project, name, description string, config map[string]string) error {
+	id, _, err := c.NetworkGet(project, name)
 	if err != nil {
 		return err
 	}
@@ -565,9 +595,9 @@ func NetworkConfigClear(tx *sql.Tx, networkID, nodeID int64) error {
 	return nil
 }
 
-// NetworkDelete deletes the network with the given name.
-func (c *Cluster) NetworkDelete(name string) error {
-	id, _, err := c.NetworkGet(name)
+// NetworkDelete deletes the network with the given name in the given project.
+func (c *Cluster) NetworkDelete(project, name string) error {
+	id, _, err := c.NetworkGet(project, name)
 	if err != nil {
 		return err
 	}
@@ -580,9 +610,9 @@ func (c *Cluster) NetworkDelete(name string) error {
 	return nil
 }
 
-// NetworkRename renames a network.
-func (c *Cluster) NetworkRename(oldName string, newName string) error {
-	id, _, err := c.NetworkGet(oldName)
+// NetworkRename renames a network of the given project.
+func (c *Cluster) NetworkRename(project string, oldName string, newName string) error {
+	id, _, err := c.NetworkGet(project, oldName)
 	if err != nil {
 		return err
 	}
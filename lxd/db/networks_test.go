@@ -15,7 +15,7 @@ func TestNetworksNodeConfigs(t *testing.T) {
 	cluster, cleanup := db.NewTestCluster(t)
 	defer cleanup()
 
-	_, err := cluster.NetworkCreate("lxdbr0", "", map[string]string{
+	_, err := cluster.NetworkCreate("default", "lxdbr0", "", map[string]string{
 		"dns.mode":                   "none",
 		"bridge.external_interfaces": "vlan0",
 	})
@@ -48,7 +48,7 @@ func TestNetworkCreatePending(t *testing.T) {
 	err = tx.NetworkCreatePending("buzz", "network1", config)
 	require.NoError(t, err)
 
-	networkID, err := tx.NetworkID("network1")
+	networkID, err := tx.NetworkID("default", "network1")
 	require.NoError(t, err)
 	assert.True(t, networkID > 0)
 
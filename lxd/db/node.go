@@ -11,6 +11,8 @@ import (
 	"github.com/lxc/lxd/lxd/db/cluster"
 	"github.com/lxc/lxd/lxd/db/query"
 	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/osarch"
 	"github.com/lxc/lxd/shared/version"
 	"github.com/pkg/errors"
@@ -22,9 +24,29 @@ type ClusterRole string
 // ClusterRoleDatabase represents the database role in a cluster.
 const ClusterRoleDatabase = ClusterRole("database")
 
+// ClusterRoleDatabaseStandby represents a member preferred for promotion to the database role.
+const ClusterRoleDatabaseStandby = ClusterRole("database-standby")
+
+// ClusterRoleOVNChassis represents a member running an OVN chassis (ovn-controller).
+const ClusterRoleOVNChassis = ClusterRole("ovn-chassis")
+
+// ClusterRoleEventHub represents a member relaying events for the rest of the cluster.
+const ClusterRoleEventHub = ClusterRole("event-hub")
+
 // ClusterRoles maps role ids into human-readable names.
 var ClusterRoles = map[int]ClusterRole{
 	0: ClusterRoleDatabase,
+	1: ClusterRoleDatabaseStandby,
+	2: ClusterRoleOVNChassis,
+	3: ClusterRoleEventHub,
+}
+
+// ClusterRolesAssignable lists the roles which can be assigned to members
+// through the API. The database role is managed automatically.
+var ClusterRolesAssignable = []ClusterRole{
+	ClusterRoleDatabaseStandby,
+	ClusterRoleOVNChassis,
+	ClusterRoleEventHub,
 }
 
 // NodeInfo holds information about a single LXD instance in a cluster.
@@ -580,6 +602,15 @@ func (c *ClusterTx) NodeOfflineThreshold() (time.Duration, error) {
 // the least number of containers (either already created or being created with
 // an operation).
 func (c *ClusterTx) NodeWithLeastContainers() (string, error) {
+	return c.NodeWithLeastContainersAffinity("", "")
+}
+
+// NodeWithLeastContainersAffinity is like NodeWithLeastContainers but also
+// honors the cluster.affinity config of an instance about to be created in the
+// given project. Nodes running an instance of one of its anti-affinity groups
+// are skipped, and if instances of its affinity groups already exist only the
+// nodes running them are considered.
+func (c *ClusterTx) NodeWithLeastContainersAffinity(project string, affinity string) (string, error) {
 	threshold, err := c.NodeOfflineThreshold()
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get offline threshold")
@@ -589,6 +620,16 @@ func (c *ClusterTx) NodeWithLeastContainers() (string, error) {
 		return "", errors.Wrap(err, "failed to get current nodes")
 	}
 
+	groups, antiGroups := shared.InstanceAffinityGroups(affinity)
+	affine := []string{}
+	antiAffine := []string{}
+	if len(groups) > 0 || len(antiGroups) > 0 {
+		affine, antiAffine, err = c.nodesByAffinity(project, groups, antiGroups)
+		if err != nil {
+			return "", errors.Wrap(err, "Failed to get instances affinity")
+		}
+	}
+
 	name := ""
 	containers := -1
 	for _, node := range nodes {
@@ -596,6 +637,14 @@ func (c *ClusterTx) NodeWithLeastContainers() (string, error) {
 			continue
 		}
 
+		if shared.StringInSlice(node.Name, antiAffine) {
+			continue
+		}
+
+		if len(affine) > 0 && !shared.StringInSlice(node.Name, affine) {
+			continue
+		}
+
 		// Fetch the number of containers already created on this node.
 		created, err := query.Count(c.tx, "instances", "node_id=?", node.ID)
 		if err != nil {
@@ -615,9 +664,71 @@ func (c *ClusterTx) NodeWithLeastContainers() (string, error) {
 			name = node.Name
 		}
 	}
+
+	if name == "" && (len(groups) > 0 || len(antiGroups) > 0) {
+		return "", fmt.Errorf("No online node satisfies the cluster.affinity %q", affinity)
+	}
+
 	return name, nil
 }
 
+// nodesByAffinity returns the names of the nodes running instances of the
+// given project which are members of one of the given affinity groups and the
+// names of the nodes running members of one of the given anti-affinity groups.
+// An instance is a member of all the groups listed in its cluster.affinity,
+// whether they're prefixed with "!" or not.
+func (c *ClusterTx) nodesByAffinity(project string, groups []string, antiGroups []string) ([]string, []string, error) {
+	instances, err := c.InstanceList(InstanceFilter{Project: project})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Load instances")
+	}
+
+	enabled, err := c.ProjectHasProfiles(project)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Check if project has profiles")
+	}
+
+	profilesProject := project
+	if !enabled {
+		profilesProject = "default"
+	}
+
+	profiles, err := c.ProfileList(ProfileFilter{Project: profilesProject})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Load profiles")
+	}
+
+	profilesByName := map[string]Profile{}
+	for _, profile := range profiles {
+		profilesByName[profile.Name] = profile
+	}
+
+	affine := []string{}
+	antiAffine := []string{}
+	for _, instance := range instances {
+		instanceProfiles := make([]api.Profile, len(instance.Profiles))
+		for i, name := range instance.Profiles {
+			profile := profilesByName[name]
+			instanceProfiles[i] = *ProfileToAPI(&profile)
+		}
+
+		config := ProfilesExpandConfig(instance.Config, instanceProfiles)
+		instanceGroups, instanceAntiGroups := shared.InstanceAffinityGroups(config["cluster.affinity"])
+
+		for _, group := range append(instanceGroups, instanceAntiGroups...) {
+			if shared.StringInSlice(group, groups) && !shared.StringInSlice(instance.Node, affine) {
+				affine = append(affine, instance.Node)
+			}
+
+			if shared.StringInSlice(group, antiGroups) && !shared.StringInSlice(instance.Node, antiAffine) {
+				antiAffine = append(antiAffine, instance.Node)
+			}
+		}
+	}
+
+	return affine, antiAffine, nil
+}
+
 // NodeUpdateVersion updates the schema and API version of the node with the
 // given id. This is used only in tests.
 func (c *ClusterTx) NodeUpdateVersion(id int64, version [2]int) error {
@@ -164,6 +164,35 @@ func TestNodeRemove(t *testing.T) {
 	assert.Equal(t, db.ErrNoSuchObject, err)
 }
 
+// Add and remove assignable roles.
+func TestNodeAddRemoveRole(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	id, err := tx.NodeAdd("buzz", "1.2.3.4:666")
+	require.NoError(t, err)
+
+	err = tx.NodeAddRole(id, db.ClusterRoleEventHub)
+	require.NoError(t, err)
+
+	err = tx.NodeAddRole(id, db.ClusterRoleOVNChassis)
+	require.NoError(t, err)
+
+	node, err := tx.NodeByName("buzz")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"event-hub", "ovn-chassis"}, node.Roles)
+
+	err = tx.NodeRemoveRole(id, db.ClusterRoleEventHub)
+	require.NoError(t, err)
+
+	node, err = tx.NodeByName("buzz")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ovn-chassis"}, node.Roles)
+
+	err = tx.NodeAddRole(id, db.ClusterRole("foo"))
+	assert.EqualError(t, err, "Invalid role: foo")
+}
+
 // Mark a node has pending.
 func TestNodePending(t *testing.T) {
 	tx, cleanup := db.NewTestClusterTx(t)
@@ -361,3 +390,46 @@ INSERT INTO operations (id, uuid, node_id, type, project_id) VALUES (1, 'abc', 1
 	require.NoError(t, err)
 	assert.Equal(t, "buzz", name)
 }
+
+// Nodes running members of an anti-affinity group are skipped, while nodes
+// running members of an affinity group are preferred.
+func TestNodeWithLeastContainersAffinity(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	id, err := tx.NodeAdd("buzz", "1.2.3.4:666")
+	require.NoError(t, err)
+
+	// Add a member of the "web" group to the default node (ID 1) and two
+	// containers, one being a member of the "db" group, to the new node.
+	_, err = tx.Tx().Exec(`
+INSERT INTO instances (id, node_id, name, architecture, type, project_id) VALUES (1, 1, 'web1', 1, 1, 1)
+`)
+	require.NoError(t, err)
+
+	_, err = tx.Tx().Exec(`
+INSERT INTO instances (id, node_id, name, architecture, type, project_id) VALUES (2, ?, 'db1', 1, 1, 1), (3, ?, 'foo', 1, 1, 1)
+`, id, id)
+	require.NoError(t, err)
+
+	_, err = tx.Tx().Exec(`
+INSERT INTO instances_config (instance_id, key, value) VALUES (1, 'cluster.affinity', '!web'), (2, 'cluster.affinity', 'db')
+`)
+	require.NoError(t, err)
+
+	name, err := tx.NodeWithLeastContainersAffinity("default", "")
+	require.NoError(t, err)
+	assert.Equal(t, "none", name)
+
+	name, err = tx.NodeWithLeastContainersAffinity("default", "!web")
+	require.NoError(t, err)
+	assert.Equal(t, "buzz", name)
+
+	name, err = tx.NodeWithLeastContainersAffinity("default", "db")
+	require.NoError(t, err)
+	assert.Equal(t, "buzz", name)
+
+	// There's no node left which isn't running a member of the group.
+	_, err = tx.NodeWithLeastContainersAffinity("default", "!web,!db")
+	assert.EqualError(t, err, `No online node satisfies the cluster.affinity "!web,!db"`)
+}
@@ -30,6 +30,17 @@ func (c *ClusterTx) OperationsUUIDs() ([]string, error) {
 	return query.SelectStrings(c.tx, stmt, c.nodeID)
 }
 
+// OperationsUUIDsByProject returns the UUIDs of all operations of the given project, on any node.
+func (c *ClusterTx) OperationsUUIDsByProject(project string) ([]string, error) {
+	stmt := `
+SELECT operations.uuid
+  FROM operations
+  JOIN projects ON projects.id = operations.project_id
+ WHERE projects.name = ?
+`
+	return query.SelectStrings(c.tx, stmt, project)
+}
+
 // OperationNodes returns a list of nodes that have running operations
 func (c *ClusterTx) OperationNodes(project string) ([]string, error) {
 	stmt := `
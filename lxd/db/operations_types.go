@@ -55,6 +55,8 @@ const (
 	OperationInstanceTypesUpdate
 	OperationBackupsExpire
 	OperationSnapshotsExpire
+	OperationCertificateAddToken
+	OperationInstanceTaskRun
 )
 
 // Description return a human-readable description of the operation type.
@@ -150,6 +152,10 @@ func (t OperationType) Description() string {
 		return "Cleaning up expired backups"
 	case OperationSnapshotsExpire:
 		return "Cleaning up expired snapshots"
+	case OperationCertificateAddToken:
+		return "Certificate add token"
+	case OperationInstanceTaskRun:
+		return "Running instance task"
 	default:
 		return "Executing operation"
 	}
@@ -190,6 +196,8 @@ func (t OperationType) Permission() string {
 		return "operate-containers"
 	case OperationSnapshotDelete:
 		return "operate-containers"
+	case OperationInstanceTaskRun:
+		return "operate-containers"
 
 	case OperationContainerCreate:
 		return "manage-containers"
@@ -378,6 +378,44 @@ func ProfilesExpandConfig(config map[string]string, profiles []api.Profile) map[
 	return expandedConfig
 }
 
+// ProfilesExpandConfigOrigin returns, for every key of the expanded config,
+// where its effective value comes from. That's either "local" or
+// "profile:<name>" for the last profile setting it.
+func ProfilesExpandConfigOrigin(config map[string]string, profiles []api.Profile) map[string]string {
+	origins := map[string]string{}
+
+	for _, profile := range profiles {
+		for k := range profile.Config {
+			origins[k] = fmt.Sprintf("profile:%s", profile.Name)
+		}
+	}
+
+	for k := range config {
+		origins[k] = "local"
+	}
+
+	return origins
+}
+
+// ProfilesExpandDevicesOrigin returns, for every device of the expanded
+// devices, where its effective definition comes from. That's either "local"
+// or "profile:<name>" for the last profile defining it.
+func ProfilesExpandDevicesOrigin(devices deviceConfig.Devices, profiles []api.Profile) map[string]string {
+	origins := map[string]string{}
+
+	for _, profile := range profiles {
+		for k := range profile.Devices {
+			origins[k] = fmt.Sprintf("profile:%s", profile.Name)
+		}
+	}
+
+	for k := range devices {
+		origins[k] = "local"
+	}
+
+	return origins
+}
+
 // ProfilesExpandDevices expands the given container devices with the devices
 // defined in the given profiles.
 func ProfilesExpandDevices(devices deviceConfig.Devices, profiles []api.Profile) deviceConfig.Devices {
@@ -53,6 +53,27 @@ func (c *ClusterTx) ProjectHasProfiles(name string) (bool, error) {
 	return projectHasProfiles(c.tx, name)
 }
 
+// ProjectHasNetworks is a helper to check if a project has the networks
+// feature enabled.
+func (c *ClusterTx) ProjectHasNetworks(name string) (bool, error) {
+	stmt := `
+SELECT projects_config.value
+  FROM projects_config
+  JOIN projects ON projects.id=projects_config.project_id
+ WHERE projects.name=? AND projects_config.key='features.networks'
+`
+	values, err := query.SelectStrings(c.tx, stmt, name)
+	if err != nil {
+		return false, errors.Wrap(err, "Fetch project config")
+	}
+
+	if len(values) == 0 {
+		return false, nil
+	}
+
+	return values[0] == "true", nil
+}
+
 // ProjectNames returns the names of all available projects.
 func (c *ClusterTx) ProjectNames() ([]string, error) {
 	stmt := "SELECT name FROM projects"
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/pborman/uuid"
 	"github.com/pkg/errors"
 
 	"github.com/lxc/lxd/lxd/db/query"
@@ -843,11 +844,21 @@ func (c *Cluster) StoragePoolVolumeGetType(project string, volumeName string, vo
 		return -1, nil, err
 	}
 
+	volumeContentType, err := c.StorageVolumeContentTypeGet(volumeID)
+	if err != nil {
+		return -1, nil, err
+	}
+
 	volumeTypeName, err := StoragePoolVolumeTypeToName(volumeType)
 	if err != nil {
 		return -1, nil, err
 	}
 
+	volumeContentTypeName, err := StoragePoolVolumeContentTypeToName(volumeContentType)
+	if err != nil {
+		return -1, nil, err
+	}
+
 	storageVolume := api.StorageVolume{
 		Type: volumeTypeName,
 	}
@@ -855,6 +866,8 @@ func (c *Cluster) StoragePoolVolumeGetType(project string, volumeName string, vo
 	storageVolume.Description = volumeDescription
 	storageVolume.Config = volumeConfig
 	storageVolume.Location = volumeNode
+	storageVolume.ContentType = volumeContentTypeName
+	storageVolume.UUID = volumeConfig["volatile.uuid"]
 
 	return volumeID, &storageVolume, nil
 }
@@ -949,7 +962,7 @@ func storagePoolVolumeReplicateIfCeph(tx *sql.Tx, volumeID int64, project, volum
 
 	// If this is a ceph volume, we want to duplicate the change across the
 	// the rows for all other nodes.
-	if driver == "ceph" || driver == "cephfs" {
+	if driver == "ceph" || driver == "cephfs" || driver == "iscsi" {
 		volumeIDs, err = storageVolumeIDsGet(tx, project, volumeName, volumeType, poolID)
 		if err != nil {
 			return err
@@ -967,10 +980,20 @@ func storagePoolVolumeReplicateIfCeph(tx *sql.Tx, volumeID int64, project, volum
 }
 
 // StoragePoolVolumeCreate creates a new storage volume attached to a given
-// storage pool.
-func (c *Cluster) StoragePoolVolumeCreate(project, volumeName, volumeDescription string, volumeType int, snapshot bool, poolID int64, volumeConfig map[string]string) (int64, error) {
+// storage pool. The volume is given a new UUID, shared by its entries on all
+// nodes.
+func (c *Cluster) StoragePoolVolumeCreate(project, volumeName, volumeDescription string, volumeType int, snapshot bool, poolID int64, volumeConfig map[string]string, contentType int) (int64, error) {
 	var thisVolumeID int64
 
+	// Copies and snapshots get their own UUID, the config of the source is left untouched.
+	config := make(map[string]string, len(volumeConfig)+1)
+	for key, value := range volumeConfig {
+		config[key] = value
+	}
+
+	config["volatile.uuid"] = uuid.New()
+	volumeConfig = config
+
 	err := c.Transaction(func(tx *ClusterTx) error {
 		nodeIDs := []int{int(c.nodeID)}
 		driver, err := storagePoolDriverGet(tx.tx, poolID)
@@ -978,7 +1001,7 @@ func (c *Cluster) StoragePoolVolumeCreate(project, volumeName, volumeDescription
 			return err
 		}
 		// If the driver is ceph, create a volume entry for each node.
-		if driver == "ceph" || driver == "cephfs" {
+		if driver == "ceph" || driver == "cephfs" || driver == "iscsi" {
 			nodeIDs, err = query.SelectIntegers(tx.tx, "SELECT id FROM nodes")
 			if err != nil {
 				return err
@@ -987,9 +1010,9 @@ func (c *Cluster) StoragePoolVolumeCreate(project, volumeName, volumeDescription
 
 		for _, nodeID := range nodeIDs {
 			result, err := tx.tx.Exec(`
-INSERT INTO storage_volumes (storage_pool_id, node_id, type, snapshot, name, description, project_id) VALUES (?, ?, ?, ?, ?, ?, (SELECT id FROM projects WHERE name = ?))
+INSERT INTO storage_volumes (storage_pool_id, node_id, type, snapshot, name, description, project_id, content_type) VALUES (?, ?, ?, ?, ?, ?, (SELECT id FROM projects WHERE name = ?), ?)
 `,
-				poolID, nodeID, volumeType, snapshot, volumeName, volumeDescription, project)
+				poolID, nodeID, volumeType, snapshot, volumeName, volumeDescription, project, contentType)
 			if err != nil {
 				return err
 			}
@@ -1073,6 +1096,18 @@ const (
 	StoragePoolVolumeTypeNameCustom    string = "custom"
 )
 
+// Content types of storage volumes.
+const (
+	StoragePoolVolumeContentTypeFS = iota
+	StoragePoolVolumeContentTypeBlock
+)
+
+// Content type names of storage volumes.
+const (
+	StoragePoolVolumeContentTypeNameFS    string = "filesystem"
+	StoragePoolVolumeContentTypeNameBlock string = "block"
+)
+
 // StoragePoolNodeConfigKeys lists all storage pool config keys which are
 // node-specific.
 var StoragePoolNodeConfigKeys = []string{
@@ -1101,6 +1136,19 @@ func StoragePoolVolumeTypeToName(volumeType int) (string, error) {
 	return "", fmt.Errorf("invalid storage volume type")
 }
 
+// StoragePoolVolumeContentTypeToName converts a volume content type integer code to
+// its human-readable name.
+func StoragePoolVolumeContentTypeToName(contentType int) (string, error) {
+	switch contentType {
+	case StoragePoolVolumeContentTypeFS:
+		return StoragePoolVolumeContentTypeNameFS, nil
+	case StoragePoolVolumeContentTypeBlock:
+		return StoragePoolVolumeContentTypeNameBlock, nil
+	}
+
+	return "", fmt.Errorf("Invalid storage volume content type")
+}
+
 // StoragePoolInsertZfsDriver replaces the driver of all storage pools without
 // a driver, setting it to 'zfs'.
 func (c *Cluster) StoragePoolInsertZfsDriver() error {
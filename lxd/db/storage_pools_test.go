@@ -167,16 +167,20 @@ func TestStoragePoolVolume_Ceph(t *testing.T) {
 	require.NoError(t, err)
 
 	config := map[string]string{"k": "v"}
-	volumeID, err := cluster.StoragePoolVolumeCreate("default", "v1", "", 1, false, poolID, config)
+	volumeID, err := cluster.StoragePoolVolumeCreate("default", "v1", "", 1, false, poolID, config, db.StoragePoolVolumeContentTypeFS)
 	require.NoError(t, err)
 
 	// The returned volume ID is the one of the volume created on the local
 	// node (node 1).
-	thisVolumeID, _, err := cluster.StoragePoolVolumeGetType("default", "v1", 1, poolID, 1)
+	thisVolumeID, thisVolume, err := cluster.StoragePoolVolumeGetType("default", "v1", 1, poolID, 1)
 	require.NoError(t, err)
 	assert.Equal(t, volumeID, thisVolumeID)
 
-	// Another volume was created for the second node.
+	// The volume was given a UUID.
+	require.NotEmpty(t, thisVolume.UUID)
+	config["volatile.uuid"] = thisVolume.UUID
+
+	// Another volume was created for the second node, with the same UUID.
 	_, volume, err := cluster.StoragePoolVolumeGetType("default", "v1", 1, poolID, 2)
 	require.NoError(t, err)
 	assert.NotNil(t, volume)
@@ -150,6 +150,24 @@ func (c *Cluster) StorageVolumeDescriptionGet(volumeID int64) (string, error) {
 	return description.String, nil
 }
 
+// StorageVolumeContentTypeGet gets the content type of a storage volume.
+func (c *Cluster) StorageVolumeContentTypeGet(volumeID int64) (int, error) {
+	contentType := -1
+	query := "SELECT content_type FROM storage_volumes WHERE id=?"
+	inargs := []interface{}{volumeID}
+	outargs := []interface{}{&contentType}
+
+	err := dbQueryRowScan(c.db, query, inargs, outargs)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return -1, ErrNoSuchObject
+		}
+		return -1, err
+	}
+
+	return contentType, nil
+}
+
 // StorageVolumeNextSnapshot returns the index the next snapshot of the storage
 // volume with the given name should have.
 //
@@ -189,10 +207,10 @@ func (c *Cluster) StorageVolumeNextSnapshot(name string, typ int) int {
 
 // StorageVolumeIsAvailable checks that if a custom volume available for being attached.
 //
-// Always return true for non-Ceph volumes.
+// Always return true for non-Ceph and non-iSCSI volumes.
 //
-// For Ceph volumes, return true if the volume is either not attached to any
-// other container, or attached to containers on this node.
+// For Ceph and iSCSI volumes, return true if the volume is either not attached
+// to any other container, or attached to containers on this node.
 func (c *Cluster) StorageVolumeIsAvailable(pool, volume string) (bool, error) {
 	isAvailable := false
 
@@ -207,7 +225,7 @@ func (c *Cluster) StorageVolumeIsAvailable(pool, volume string) (bool, error) {
 			return errors.Wrapf(err, "Fetch storage pool driver for %q", pool)
 		}
 
-		if driver != "ceph" {
+		if driver != "ceph" && driver != "iscsi" {
 			isAvailable = true
 			return nil
 		}
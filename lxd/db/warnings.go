@@ -0,0 +1,302 @@
+// +build linux,cgo,!agent
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db/query"
+)
+
+// Warning holds information about a single warning recorded in the database.
+type Warning struct {
+	ID             int64         // Stable database identifier
+	UUID           string        // User-visible identifier
+	Node           string        // Name of the node the warning relates to, empty if cluster-wide
+	Project        string        // Name of the project the warning relates to, empty if global
+	EntityTypeCode int           // Type of the entity the warning relates to
+	EntityID       int64         // ID of the entity the warning relates to
+	TypeCode       WarningType   // Type of the warning
+	Status         WarningStatus // Current status of the warning
+	FirstSeenDate  time.Time     // First time the warning was raised
+	LastSeenDate   time.Time     // Last time the warning was raised
+	UpdatedDate    time.Time     // Last time the warning status changed
+	LastMessage    string        // Message of the last occurrence
+	Count          int           // Number of occurrences
+}
+
+// WarningFilter can be used to filter results yielded by Warnings.
+type WarningFilter struct {
+	Project  string        // If non-empty, return only warnings of this project.
+	Node     string        // If non-empty, return only warnings of this node.
+	TypeCode WarningType   // If non-zero, return only warnings of this type.
+	Status   WarningStatus // If non-zero, return only warnings with this status.
+}
+
+// Warnings returns all warnings matching the given filter.
+func (c *ClusterTx) Warnings(filter WarningFilter) ([]Warning, error) {
+	where := ""
+	args := []interface{}{}
+	addClause := func(clause string, arg interface{}) {
+		if where != "" {
+			where += " AND "
+		}
+
+		where += clause
+		args = append(args, arg)
+	}
+
+	if filter.Project != "" {
+		addClause("projects.name = ?", filter.Project)
+	}
+
+	if filter.Node != "" {
+		addClause("nodes.name = ?", filter.Node)
+	}
+
+	if filter.TypeCode != WarningUndefined {
+		addClause("warnings.type_code = ?", filter.TypeCode)
+	}
+
+	if filter.Status != 0 {
+		addClause("warnings.status = ?", filter.Status)
+	}
+
+	return c.warnings(where, args...)
+}
+
+// WarningByUUID returns the warning with the given UUID.
+func (c *ClusterTx) WarningByUUID(uuid string) (Warning, error) {
+	null := Warning{}
+	warnings, err := c.warnings("warnings.uuid = ?", uuid)
+	if err != nil {
+		return null, err
+	}
+
+	switch len(warnings) {
+	case 0:
+		return null, ErrNoSuchObject
+	case 1:
+		return warnings[0], nil
+	default:
+		return null, fmt.Errorf("More than one warning matches")
+	}
+}
+
+// WarningUpsert records a new occurrence of a warning. If a matching warning
+// already exists its counter and message are updated and, if it had been
+// resolved, it's brought back to the "new" status.
+func (c *ClusterTx) WarningUpsert(node string, project string, entityTypeCode int, entityID int64, typeCode WarningType, message string) error {
+	var nodeID interface{}
+	if node != "" {
+		info, err := c.NodeByName(node)
+		if err != nil {
+			return errors.Wrap(err, "Fetch node ID")
+		}
+
+		nodeID = info.ID
+	}
+
+	var projectID interface{}
+	if project != "" {
+		id, err := c.ProjectID(project)
+		if err != nil {
+			return errors.Wrap(err, "Fetch project ID")
+		}
+
+		projectID = id
+	}
+
+	now := time.Now().UTC()
+
+	// Look for an existing warning about the same thing.
+	stmt := `
+SELECT id, status FROM warnings
+ WHERE IFNULL(node_id, -1) = IFNULL(?, -1) AND IFNULL(project_id, -1) = IFNULL(?, -1)
+   AND entity_type_code = ? AND entity_id = ? AND type_code = ?
+`
+	var id int64
+	var status WarningStatus
+	err := c.tx.QueryRow(stmt, nodeID, projectID, entityTypeCode, entityID, typeCode).Scan(&id, &status)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.Wrap(err, "Failed to look up existing warning")
+	}
+
+	if err == sql.ErrNoRows {
+		_, err = c.tx.Exec(`
+INSERT INTO warnings (node_id, project_id, entity_type_code, entity_id, uuid, type_code, status, first_seen_date, last_seen_date, updated_date, last_message, count)
+  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)
+`, nodeID, projectID, entityTypeCode, entityID, uuid.NewRandom().String(), typeCode, WarningStatusNew, now, now, now, message)
+		if err != nil {
+			return errors.Wrap(err, "Failed to create warning")
+		}
+
+		return nil
+	}
+
+	if status == WarningStatusResolved {
+		_, err = c.tx.Exec("UPDATE warnings SET status = ?, updated_date = ? WHERE id = ?", WarningStatusNew, now, id)
+		if err != nil {
+			return errors.Wrap(err, "Failed to reopen warning")
+		}
+	}
+
+	_, err = c.tx.Exec("UPDATE warnings SET last_seen_date = ?, last_message = ?, count = count + 1 WHERE id = ?", now, message, id)
+	if err != nil {
+		return errors.Wrap(err, "Failed to update warning")
+	}
+
+	return nil
+}
+
+// WarningStatusUpdate sets the status of the warning with the given UUID.
+func (c *ClusterTx) WarningStatusUpdate(uuid string, status WarningStatus) error {
+	result, err := c.tx.Exec("UPDATE warnings SET status = ?, updated_date = ? WHERE uuid = ?", status, time.Now().UTC(), uuid)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+// WarningsResolve marks all warnings of the given type on the given node as
+// resolved. If entityTypeCode is negative, warnings about any entity are
+// resolved.
+func (c *ClusterTx) WarningsResolve(node string, typeCode WarningType, entityTypeCode int, entityID int64) error {
+	stmt := `
+UPDATE warnings SET status = ?, updated_date = ?
+ WHERE type_code = ? AND status != ?
+   AND IFNULL(node_id, -1) = IFNULL((SELECT id FROM nodes WHERE name = ?), -1)
+`
+	args := []interface{}{WarningStatusResolved, time.Now().UTC(), typeCode, WarningStatusResolved, node}
+	if entityTypeCode >= 0 {
+		stmt += " AND entity_type_code = ? AND entity_id = ?"
+		args = append(args, entityTypeCode, entityID)
+	}
+
+	_, err := c.tx.Exec(stmt, args...)
+	return err
+}
+
+// WarningDelete removes the warning with the given UUID.
+func (c *ClusterTx) WarningDelete(uuid string) error {
+	result, err := c.tx.Exec("DELETE FROM warnings WHERE uuid = ?", uuid)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+// WarningsPruneResolved removes all resolved warnings that haven't been
+// updated since the given time.
+func (c *ClusterTx) WarningsPruneResolved(before time.Time) error {
+	_, err := c.tx.Exec("DELETE FROM warnings WHERE status = ? AND updated_date < ?", WarningStatusResolved, before.UTC())
+	return err
+}
+
+// WarningEntityURL returns the API URL of the entity the warning relates to,
+// or an empty string if the warning isn't about a specific entity.
+func (c *ClusterTx) WarningEntityURL(w Warning) (string, error) {
+	var table string
+	var format string
+
+	switch w.EntityTypeCode {
+	case WarningEntityTypeStoragePool:
+		table, format = "storage_pools", "/1.0/storage-pools/%s"
+	case WarningEntityTypeNetwork:
+		table, format = "networks", "/1.0/networks/%s"
+	case WarningEntityTypeInstance:
+		table, format = "instances", "/1.0/instances/%s"
+	default:
+		return "", nil
+	}
+
+	names, err := query.SelectStrings(c.tx, fmt.Sprintf("SELECT name FROM %s WHERE id = ?", table), w.EntityID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(names) != 1 {
+		return "", nil
+	}
+
+	url := fmt.Sprintf(format, names[0])
+	if w.EntityTypeCode == WarningEntityTypeInstance && w.Project != "" && w.Project != "default" {
+		url += fmt.Sprintf("?project=%s", w.Project)
+	}
+
+	return url, nil
+}
+
+// Returns all warnings in the cluster, filtered by the given clause.
+func (c *ClusterTx) warnings(where string, args ...interface{}) ([]Warning, error) {
+	warnings := []Warning{}
+	dest := func(i int) []interface{} {
+		warnings = append(warnings, Warning{})
+		return []interface{}{
+			&warnings[i].ID,
+			&warnings[i].UUID,
+			&warnings[i].Node,
+			&warnings[i].Project,
+			&warnings[i].EntityTypeCode,
+			&warnings[i].EntityID,
+			&warnings[i].TypeCode,
+			&warnings[i].Status,
+			&warnings[i].FirstSeenDate,
+			&warnings[i].LastSeenDate,
+			&warnings[i].UpdatedDate,
+			&warnings[i].LastMessage,
+			&warnings[i].Count,
+		}
+	}
+
+	sql := `
+SELECT warnings.id, warnings.uuid, IFNULL(nodes.name, ""), IFNULL(projects.name, ""),
+       warnings.entity_type_code, warnings.entity_id, warnings.type_code, warnings.status,
+       warnings.first_seen_date, warnings.last_seen_date, warnings.updated_date,
+       warnings.last_message, warnings.count
+  FROM warnings
+  LEFT OUTER JOIN nodes ON nodes.id = warnings.node_id
+  LEFT OUTER JOIN projects ON projects.id = warnings.project_id `
+	if where != "" {
+		sql += fmt.Sprintf("WHERE %s ", where)
+	}
+
+	sql += "ORDER BY warnings.id"
+	stmt, err := c.tx.Prepare(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch warnings")
+	}
+
+	return warnings, nil
+}
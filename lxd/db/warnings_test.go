@@ -0,0 +1,86 @@
+// +build linux,cgo,!agent
+
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Add, update, resolve and remove a warning.
+func TestWarning(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	err := tx.WarningUpsert("none", "", db.WarningEntityTypeNone, 0, db.WarningMissingCGroupBlkio, "first")
+	require.NoError(t, err)
+
+	warnings, err := tx.Warnings(db.WarningFilter{})
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "none", warnings[0].Node)
+	assert.Equal(t, db.WarningMissingCGroupBlkio, warnings[0].TypeCode)
+	assert.Equal(t, db.WarningStatusNew, warnings[0].Status)
+	assert.Equal(t, 1, warnings[0].Count)
+
+	// A second occurrence bumps the counter instead of creating a new entry.
+	err = tx.WarningUpsert("none", "", db.WarningEntityTypeNone, 0, db.WarningMissingCGroupBlkio, "second")
+	require.NoError(t, err)
+
+	warning, err := tx.WarningByUUID(warnings[0].UUID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, warning.Count)
+	assert.Equal(t, "second", warning.LastMessage)
+
+	err = tx.WarningStatusUpdate(warning.UUID, db.WarningStatusAcknowledged)
+	require.NoError(t, err)
+
+	warnings, err = tx.Warnings(db.WarningFilter{Status: db.WarningStatusAcknowledged})
+	require.NoError(t, err)
+	assert.Len(t, warnings, 1)
+
+	err = tx.WarningsResolve("none", db.WarningMissingCGroupBlkio, -1, 0)
+	require.NoError(t, err)
+
+	warning, err = tx.WarningByUUID(warning.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, db.WarningStatusResolved, warning.Status)
+
+	// A new occurrence of a resolved warning re-opens it.
+	err = tx.WarningUpsert("none", "", db.WarningEntityTypeNone, 0, db.WarningMissingCGroupBlkio, "third")
+	require.NoError(t, err)
+
+	warning, err = tx.WarningByUUID(warning.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, db.WarningStatusNew, warning.Status)
+	assert.Equal(t, 3, warning.Count)
+
+	err = tx.WarningDelete(warning.UUID)
+	require.NoError(t, err)
+
+	_, err = tx.WarningByUUID(warning.UUID)
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
+
+// Resolved warnings are pruned once old enough.
+func TestWarningsPruneResolved(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	err := tx.WarningUpsert("none", "default", db.WarningEntityTypeNone, 0, db.WarningAppArmorNotAvailable, "disabled")
+	require.NoError(t, err)
+
+	err = tx.WarningsResolve("none", db.WarningAppArmorNotAvailable, -1, 0)
+	require.NoError(t, err)
+
+	err = tx.WarningsPruneResolved(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	warnings, err := tx.Warnings(db.WarningFilter{Project: "default"})
+	require.NoError(t, err)
+	assert.Len(t, warnings, 0)
+}
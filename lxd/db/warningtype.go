@@ -0,0 +1,113 @@
+package db
+
+// WarningType is a numeric code indentifying the type of a warning.
+type WarningType int
+
+// Possible values for WarningType
+//
+// WARNING: The type codes are stored in the database, so this list of
+// definitions should be normally append-only. Any other change requires a
+// database update.
+const (
+	WarningUndefined WarningType = iota
+	WarningMissingCGroupBlkio
+	WarningMissingCGroupBlkioWeight
+	WarningMissingCGroupCPUController
+	WarningMissingCGroupCPUacctController
+	WarningMissingCGroupCPUsetController
+	WarningMissingCGroupDevicesController
+	WarningMissingCGroupFreezerController
+	WarningMissingCGroupMemoryController
+	WarningMissingCGroupNetPrioController
+	WarningMissingCGroupPidsController
+	WarningMissingCGroupMemorySwapAccounting
+	WarningAppArmorNotAvailable
+	WarningStoragePoolUnhealthy
+	WarningNetworkUnavailable
+)
+
+// WarningTypeNames associates a warning code to its name.
+var WarningTypeNames = map[WarningType]string{
+	WarningUndefined:                         "Undefined warning",
+	WarningMissingCGroupBlkio:                "Couldn't find the CGroup blkio",
+	WarningMissingCGroupBlkioWeight:          "Couldn't find the CGroup blkio.weight",
+	WarningMissingCGroupCPUController:        "Couldn't find the CGroup CPU controller",
+	WarningMissingCGroupCPUacctController:    "Couldn't find the CGroup CPUacct controller",
+	WarningMissingCGroupCPUsetController:     "Couldn't find the CGroup CPUset controller",
+	WarningMissingCGroupDevicesController:    "Couldn't find the CGroup devices controller",
+	WarningMissingCGroupFreezerController:    "Couldn't find the CGroup freezer controller",
+	WarningMissingCGroupMemoryController:     "Couldn't find the CGroup memory controller",
+	WarningMissingCGroupNetPrioController:    "Couldn't find the CGroup network priority controller",
+	WarningMissingCGroupPidsController:       "Couldn't find the CGroup pids controller",
+	WarningMissingCGroupMemorySwapAccounting: "Couldn't find the CGroup memory swap accounting",
+	WarningAppArmorNotAvailable:              "AppArmor support has been disabled",
+	WarningStoragePoolUnhealthy:              "Storage pool isn't healthy",
+	WarningNetworkUnavailable:                "Network unavailable",
+}
+
+// WarningSeverity indicates the importance of a warning.
+type WarningSeverity int
+
+// Possible values for WarningSeverity.
+const (
+	WarningSeverityLow WarningSeverity = iota + 1
+	WarningSeverityModerate
+	WarningSeverityHigh
+)
+
+// WarningSeverityNames associates a severity code to its name.
+var WarningSeverityNames = map[WarningSeverity]string{
+	WarningSeverityLow:      "low",
+	WarningSeverityModerate: "moderate",
+	WarningSeverityHigh:     "high",
+}
+
+// Severity returns the severity of the warning type.
+func (t WarningType) Severity() WarningSeverity {
+	switch t {
+	case WarningStoragePoolUnhealthy, WarningNetworkUnavailable:
+		return WarningSeverityHigh
+	case WarningMissingCGroupDevicesController, WarningAppArmorNotAvailable:
+		return WarningSeverityModerate
+	}
+
+	return WarningSeverityLow
+}
+
+// WarningStatus represents the state of a warning.
+type WarningStatus int
+
+// Possible values for WarningStatus.
+const (
+	WarningStatusNew WarningStatus = iota + 1
+	WarningStatusAcknowledged
+	WarningStatusResolved
+)
+
+// WarningStatusNames associates a status code to its name.
+var WarningStatusNames = map[WarningStatus]string{
+	WarningStatusNew:          "new",
+	WarningStatusAcknowledged: "acknowledged",
+	WarningStatusResolved:     "resolved",
+}
+
+// WarningStatusFromName returns the status code for the given name.
+func WarningStatusFromName(name string) (WarningStatus, bool) {
+	for code, statusName := range WarningStatusNames {
+		if statusName == name {
+			return code, true
+		}
+	}
+
+	return -1, false
+}
+
+// Entity types that warnings can refer to.
+//
+// WARNING: The type codes are stored in the database and should be append-only.
+const (
+	WarningEntityTypeNone = iota
+	WarningEntityTypeStoragePool
+	WarningEntityTypeNetwork
+	WarningEntityTypeInstance
+)
@@ -36,6 +36,8 @@ type RootFSEntryItem struct {
 type RunConfig struct {
 	RootFS           RootFSEntryItem  // RootFS to setup.
 	NetworkInterface []RunConfigItem  // Network interface configuration settings.
+	GPUDevice        []RunConfigItem  // GPU device configuration settings.
+	PCIDevice        []RunConfigItem  // PCI device configuration settings.
 	CGroups          []RunConfigItem  // Cgroup rules to setup.
 	Mounts           []MountEntryItem // Mounts to setup/remove.
 	Uevents          [][]string       // Uevents to inject.
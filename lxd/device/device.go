@@ -17,6 +17,7 @@ var devTypes = map[string]func(deviceConfig.Device) device{
 	"unix-char":  func(c deviceConfig.Device) device { return &unixCommon{} },
 	"unix-block": func(c deviceConfig.Device) device { return &unixCommon{} },
 	"disk":       func(c deviceConfig.Device) device { return &disk{} },
+	"pci":        func(c deviceConfig.Device) device { return &pci{} },
 	"none":       func(c deviceConfig.Device) device { return &none{} },
 }
 
@@ -11,8 +11,11 @@ import (
 
 	"golang.org/x/sys/unix"
 
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/secrets"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/idmap"
 )
 
 // StorageVolumeMount checks if storage volume is mounted and if not tries to mount it.
@@ -125,6 +128,76 @@ func DiskMount(srcPath string, dstPath string, readonly bool, recursive bool, pr
 	return nil
 }
 
+// idmapInstance is implemented by instances running with a uid/gid map.
+type idmapInstance interface {
+	CurrentIdmap() (*idmap.IdmapSet, error)
+}
+
+// validateDiskIdmap validates the raw.idmap property of disk devices, which uses the syntax of the
+// raw.idmap instance key.
+func validateDiskIdmap(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, err := instance.ParseRawIdmap(value)
+	return err
+}
+
+// diskMountIdmap returns the idmap of the user namespace used for the idmapped mount of a disk
+// device with the given raw.idmap, for an instance running with instIdmap (nil when privileged).
+// Each host ID of rawIdmap is mapped to the host ID its container ID is mapped to, so that
+// files owned by the host ID appear owned by the container ID inside the instance.
+func diskMountIdmap(rawIdmap string, instIdmap *idmap.IdmapSet) (*idmap.IdmapSet, error) {
+	rawMaps, err := instance.ParseRawIdmap(rawIdmap)
+	if err != nil {
+		return nil, err
+	}
+
+	// Returns the host ID the range of container IDs starting at nsid is mapped to.
+	shiftRange := func(nsid int64, size int64, isuid bool) (int64, error) {
+		if instIdmap == nil {
+			return nsid, nil
+		}
+
+		for _, e := range instIdmap.Idmap {
+			if (isuid && !e.Isuid) || (!isuid && !e.Isgid) {
+				continue
+			}
+
+			if nsid >= e.Nsid && nsid+size <= e.Nsid+e.Maprange {
+				return e.Hostid + nsid - e.Nsid, nil
+			}
+		}
+
+		return -1, fmt.Errorf("Container IDs %d-%d aren't mapped by the instance", nsid, nsid+size-1)
+	}
+
+	mountIdmap := &idmap.IdmapSet{}
+	for _, ent := range rawMaps {
+		for _, isuid := range []bool{true, false} {
+			if (isuid && !ent.Isuid) || (!isuid && !ent.Isgid) {
+				continue
+			}
+
+			hostid, err := shiftRange(ent.Nsid, ent.Maprange, isuid)
+			if err != nil {
+				return nil, err
+			}
+
+			mountIdmap.Idmap = append(mountIdmap.Idmap, idmap.IdmapEntry{
+				Isuid:    isuid,
+				Isgid:    !isuid,
+				Nsid:     ent.Hostid,
+				Hostid:   hostid,
+				Maprange: ent.Maprange,
+			})
+		}
+	}
+
+	return mountIdmap, nil
+}
+
 func diskCephRbdMap(clusterName string, userName string, poolName string, volumeName string) (string, error) {
 	devPath, err := shared.RunCommand(
 		"rbd",
@@ -184,7 +257,7 @@ again:
 	goto again
 }
 
-func cephFsConfig(clusterName string, userName string) ([]string, string, error) {
+func cephFsConfig(s *state.State, clusterName string, userName string) ([]string, string, error) {
 	// Parse the CEPH configuration
 	cephConf, err := os.Open(fmt.Sprintf("/etc/ceph/%s.conf", clusterName))
 	if err != nil {
@@ -223,7 +296,17 @@ func cephFsConfig(clusterName string, userName string) ([]string, string, error)
 	// Parse the CEPH keyring
 	cephKeyring, err := os.Open(fmt.Sprintf("/etc/ceph/%v.client.%v.keyring", clusterName, userName))
 	if err != nil {
-		return nil, "", err
+		// Fallback to the key stored in the secrets backend.
+		if !os.IsNotExist(err) || s == nil || s.Secrets == nil {
+			return nil, "", err
+		}
+
+		cephSecret, err := secrets.CephKey(s.Secrets, clusterName, userName)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return cephMon, cephSecret, nil
 	}
 
 	var cephSecret string
@@ -255,9 +338,9 @@ func cephFsConfig(clusterName string, userName string) ([]string, string, error)
 	return cephMon, cephSecret, nil
 }
 
-func diskCephfsOptions(clusterName string, userName string, fsName string, fsPath string) (string, string, error) {
+func diskCephfsOptions(s *state.State, clusterName string, userName string, fsName string, fsPath string) (string, string, error) {
 	// Get the credentials and host
-	monAddresses, secret, err := cephFsConfig(clusterName, userName)
+	monAddresses, secret, err := cephFsConfig(s, clusterName, userName)
 	if err != nil {
 		return "", "", err
 	}
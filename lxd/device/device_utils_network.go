@@ -13,7 +13,9 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/lxc/lxd/lxd/db"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
@@ -40,6 +42,46 @@ func NetworkGetDevMTU(devName string) (uint64, error) {
 	return mtu, nil
 }
 
+// networkUpdateMTU applies a changed mtu setting to a running NIC. The host side interface (if
+// hostName isn't empty) is updated directly and, for containers, the instance side interface is
+// updated through a device event. If mtu is empty, the MTU of the parent device is used instead.
+func networkUpdateMTU(inst Instance, name string, hostName string, parent string, mtu string) error {
+	var mtuInt uint64
+	var err error
+
+	if mtu != "" {
+		mtuInt, err = strconv.ParseUint(mtu, 10, 32)
+		if err != nil {
+			return fmt.Errorf("Invalid MTU specified: %v", err)
+		}
+	} else {
+		mtuInt, err = NetworkGetDevMTU(parent)
+		if err != nil {
+			return fmt.Errorf("Failed to get the parent MTU: %v", err)
+		}
+	}
+
+	if hostName != "" {
+		err = NetworkSetDevMTU(hostName, mtuInt)
+		if err != nil {
+			return fmt.Errorf("Failed to set the MTU: %v", err)
+		}
+	}
+
+	// VMs negotiate the MTU with their virtio NIC at boot, so only the host side can be changed.
+	if inst.Type() != instancetype.Container {
+		return nil
+	}
+
+	runConf := deviceConfig.RunConfig{}
+	runConf.NetworkInterface = []deviceConfig.RunConfigItem{
+		{Key: "name", Value: name},
+		{Key: "mtu", Value: fmt.Sprintf("%d", mtuInt)},
+	}
+
+	return inst.DeviceEventHandler(&runConf)
+}
+
 // NetworkSetDevMTU sets the MTU setting for a named network device if different from current.
 func NetworkSetDevMTU(devName string, mtu uint64) error {
 	curMTU, err := NetworkGetDevMTU(devName)
@@ -128,6 +170,44 @@ func NetworkGetHostDevice(parent string, vlan string) string {
 	return defaultVlan
 }
 
+// NetworkProject returns the project defining the managed network a NIC of an instance in the
+// given project uses as parent. The networks of projects with the networks feature enabled take
+// precedence, any other parent is looked up in the default project.
+func NetworkProject(s *state.State, instanceProject string, parent string) (string, error) {
+	if instanceProject == "default" || s == nil || s.Cluster == nil {
+		return "default", nil
+	}
+
+	found := false
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		enabled, err := tx.ProjectHasNetworks(instanceProject)
+		if err != nil || !enabled {
+			return err
+		}
+
+		_, err = tx.NetworkID(instanceProject, parent)
+		if err == db.ErrNoSuchObject {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		found = true
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if found {
+		return instanceProject, nil
+	}
+
+	return "default", nil
+}
+
 // NetworkRemoveInterface removes a network interface by name.
 func NetworkRemoveInterface(nic string) error {
 	_, err := shared.RunCommand("ip", "link", "del", "dev", nic)
@@ -381,6 +461,47 @@ func networkCreateTap(hostName string) error {
 	return nil
 }
 
+// networkOVSBridgeIsDPDK returns whether the bridge is an openvswitch bridge using the DPDK
+// userspace datapath.
+func networkOVSBridgeIsDPDK(netName string) bool {
+	if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/bridge", netName)) {
+		return false
+	}
+
+	datapath, err := shared.RunCommand("ovs-vsctl", "get", "bridge", netName, "datapath_type")
+	if err != nil {
+		return false
+	}
+
+	return strings.Trim(strings.TrimSpace(datapath), `"`) == "netdev"
+}
+
+// networkCreateVhostUserPort adds a vhost-user client port to an openvswitch DPDK bridge,
+// connecting to the vhost-user socket served by QEMU at socketPath.
+func networkCreateVhostUserPort(netName string, hostName string, socketPath string, mtu string) error {
+	args := []string{"add-port", netName, hostName, "--", "set", "Interface", hostName, "type=dpdkvhostuserclient", fmt.Sprintf("options:vhost-server-path=%s", socketPath)}
+	if mtu != "" {
+		args = append(args, fmt.Sprintf("mtu_request=%s", mtu))
+	}
+
+	_, err := shared.RunCommand("ovs-vsctl", args...)
+	if err != nil {
+		return fmt.Errorf("Failed to create the vhost-user port %s: %v", hostName, err)
+	}
+
+	return nil
+}
+
+// networkRemoveVhostUserPort removes a vhost-user port from an openvswitch DPDK bridge.
+func networkRemoveVhostUserPort(netName string, hostName string) error {
+	_, err := shared.RunCommand("ovs-vsctl", "--if-exists", "del-port", netName, hostName)
+	if err != nil {
+		return fmt.Errorf("Failed to remove the vhost-user port %s: %v", hostName, err)
+	}
+
+	return nil
+}
+
 // networkSetupHostVethDevice configures a nic device's host side veth settings.
 func networkSetupHostVethDevice(device deviceConfig.Device, oldDevice deviceConfig.Device, v map[string]string) error {
 	// If not configured, check if volatile data contains the most recently added host_name.
@@ -444,7 +565,8 @@ func networkSetVethRoutes(m deviceConfig.Device) error {
 	if m["ipv4.routes"] != "" {
 		for _, route := range strings.Split(m["ipv4.routes"], ",") {
 			route = strings.TrimSpace(route)
-			_, err := shared.RunCommand("ip", "-4", "route", "add", route, "dev", routeDev, "proto", "boot")
+			args := append([]string{"-4", "route", "add", route}, networkVethRouteVia(m, "ipv4.address")...)
+			_, err := shared.RunCommand("ip", append(args, "dev", routeDev, "proto", "boot")...)
 			if err != nil {
 				return err
 			}
@@ -455,7 +577,8 @@ func networkSetVethRoutes(m deviceConfig.Device) error {
 	if m["ipv6.routes"] != "" {
 		for _, route := range strings.Split(m["ipv6.routes"], ",") {
 			route = strings.TrimSpace(route)
-			_, err := shared.RunCommand("ip", "-6", "route", "add", route, "dev", routeDev, "proto", "boot")
+			args := append([]string{"-6", "route", "add", route}, networkVethRouteVia(m, "ipv6.address")...)
+			_, err := shared.RunCommand("ip", append(args, "dev", routeDev, "proto", "boot")...)
 			if err != nil {
 				return err
 			}
@@ -465,6 +588,17 @@ func networkSetVethRoutes(m deviceConfig.Device) error {
 	return nil
 }
 
+// networkVethRouteVia returns the "via" arguments making the static routes of a bridged or routed
+// nic use the instance's static address (the first one for routed nics) in the address config key
+// as next-hop. Without a static address the routes only point at the route device.
+func networkVethRouteVia(m deviceConfig.Device, key string) []string {
+	if m[key] == "" || !shared.StringInSlice(m["nictype"], []string{"bridged", "routed"}) {
+		return nil
+	}
+
+	return []string{"via", strings.TrimSpace(strings.Split(m[key], ",")[0])}
+}
+
 // networkRemoveVethRoutes removes any routes created for this device on the host that were first added
 // with networkSetVethRoutes(). Expects to be passed the device config from the oldExpandedDevices.
 func networkRemoveVethRoutes(m deviceConfig.Device) {
@@ -0,0 +1,285 @@
+package device
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+)
+
+// pciDevPath is the path where PCI devices can be enumerated.
+const pciDevPath = "/sys/bus/pci/devices"
+
+// pciAddressRegex matches a full PCI address (domain:bus:slot.function).
+var pciAddressRegex = regexp.MustCompile(`^[0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-7]$`)
+
+// pciValidAddress validates a full PCI address such as 0000:01:00.0.
+func pciValidAddress(value string) error {
+	if !pciAddressRegex.MatchString(value) {
+		return fmt.Errorf("Invalid PCI address %q, must be of the form 0000:01:00.0", value)
+	}
+
+	return nil
+}
+
+// pciDeviceDriver returns the name of the driver bound to the PCI device, empty if there is none.
+func pciDeviceDriver(pciAddress string) (string, error) {
+	driverPath, err := filepath.EvalSymlinks(filepath.Join(pciDevPath, pciAddress, "driver"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return filepath.Base(driverPath), nil
+}
+
+// pciDeviceIsBridge returns true if the PCI device is a PCI bridge, which can stay on the host
+// when the other devices of its IOMMU group are passed through.
+func pciDeviceIsBridge(pciAddress string) bool {
+	class, err := ioutil.ReadFile(filepath.Join(pciDevPath, pciAddress, "class"))
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(strings.TrimSpace(string(class)), "0x0604")
+}
+
+// pciIOMMUGroup returns the IOMMU group of the PCI device.
+func pciIOMMUGroup(pciAddress string) (string, error) {
+	groupPath, err := filepath.EvalSymlinks(filepath.Join(pciDevPath, pciAddress, "iommu_group"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("PCI device %s isn't in an IOMMU group, is the IOMMU enabled?", pciAddress)
+		}
+
+		return "", err
+	}
+
+	return filepath.Base(groupPath), nil
+}
+
+// pciIOMMUGroupDevices returns the addresses of the PCI devices in the IOMMU group.
+func pciIOMMUGroupDevices(group string) ([]string, error) {
+	ents, err := ioutil.ReadDir(filepath.Join("/sys/kernel/iommu_groups", group, "devices"))
+	if err != nil {
+		return nil, err
+	}
+
+	devices := []string{}
+	for _, ent := range ents {
+		devices = append(devices, ent.Name())
+	}
+
+	return devices, nil
+}
+
+// pciCheckIOMMUGroup checks that the devices sharing the IOMMU group of the PCI device passed
+// through by the instance device can be detached from the host and aren't used by another instance
+// or device. The extra devices are detached from the host along with it by the instance device.
+func pciCheckIOMMUGroup(s *state.State, inst Instance, devName string, address string, extraDevices []string) error {
+	group, err := pciIOMMUGroup(address)
+	if err != nil {
+		return err
+	}
+
+	groupDevices, err := pciIOMMUGroupDevices(group)
+	if err != nil {
+		return err
+	}
+
+	instances, err := InstanceLoadNodeAll(s)
+	if err != nil {
+		return err
+	}
+
+	// PCI devices of the group which this instance passes through itself.
+	ownDevices := append([]string{}, extraDevices...)
+
+	for _, other := range instances {
+		sameInstance := other.Project() == inst.Project() && other.Name() == inst.Name()
+		config := other.ExpandedConfig()
+
+		for otherName, devConfig := range other.ExpandedDevices() {
+			if sameInstance && otherName == devName {
+				continue
+			}
+
+			var addresses []string
+			switch devConfig["type"] {
+			case "pci":
+				if sameInstance {
+					ownDevices = append(ownDevices, devConfig["address"])
+					continue
+				}
+
+				// Devices of other instances only conflict while they're started.
+				addresses = []string{config[fmt.Sprintf("volatile.%s.last_state.pci.address", otherName)]}
+			case "gpu":
+				addresses = []string{devConfig["pci"]}
+			case "nic", "infiniband":
+				addresses = []string{pciNetworkDeviceAddress(devConfig["parent"])}
+
+				hostName := config[fmt.Sprintf("volatile.%s.host_name", otherName)]
+				if hostName != "" {
+					addresses = append(addresses, pciNetworkDeviceAddress(hostName))
+				}
+			}
+
+			for _, otherAddress := range addresses {
+				if otherAddress == "" || !shared.StringInSlice(otherAddress, groupDevices) {
+					continue
+				}
+
+				if sameInstance {
+					return fmt.Errorf("PCI device %s shares IOMMU group %s with device %q", address, group, otherName)
+				}
+
+				return fmt.Errorf("PCI device %s shares IOMMU group %s with device %q of instance %q", address, group, otherName, other.Name())
+			}
+		}
+	}
+
+	// All the other devices of the group must be detached from the host for VFIO to use it.
+	for _, groupDevice := range groupDevices {
+		if groupDevice == address || shared.StringInSlice(groupDevice, ownDevices) || pciDeviceIsBridge(groupDevice) {
+			continue
+		}
+
+		driver, err := pciDeviceDriver(groupDevice)
+		if err != nil {
+			return err
+		}
+
+		if !shared.StringInSlice(driver, []string{"", "vfio-pci", "pci-stub"}) {
+			return fmt.Errorf("PCI device %s shares IOMMU group %s with %s which is in use by the %q driver", address, group, groupDevice, driver)
+		}
+	}
+
+	return nil
+}
+
+// pciDeviceFunctions returns the addresses of the other functions of the multi-function PCI
+// device, such as the audio function of a GPU.
+func pciDeviceFunctions(pciAddress string) ([]string, error) {
+	ents, err := ioutil.ReadDir(pciDevPath)
+	if err != nil {
+		return nil, err
+	}
+
+	slot := strings.TrimSuffix(pciAddress, filepath.Ext(pciAddress))
+
+	functions := []string{}
+	for _, ent := range ents {
+		if ent.Name() == pciAddress || strings.TrimSuffix(ent.Name(), filepath.Ext(ent.Name())) != slot {
+			continue
+		}
+
+		functions = append(functions, ent.Name())
+	}
+
+	return functions, nil
+}
+
+// pciDeviceReset resets the PCI device through its sysfs reset file, if the kernel supports a
+// reset method for it. Cards without one are left as they are.
+func pciDeviceReset(pciAddress string) error {
+	resetPath := filepath.Join(pciDevPath, pciAddress, "reset")
+	if !shared.PathExists(resetPath) {
+		return nil
+	}
+
+	err := ioutil.WriteFile(resetPath, []byte("1"), 0200)
+	if err != nil {
+		return fmt.Errorf("Failed to reset PCI device %s: %v", pciAddress, err)
+	}
+
+	return nil
+}
+
+// pciNetworkDeviceAddress returns the PCI address of a host network interface, empty if it
+// isn't a PCI device.
+func pciNetworkDeviceAddress(ifName string) string {
+	devicePath, err := filepath.EvalSymlinks(fmt.Sprintf("/sys/class/net/%s/device", ifName))
+	if err != nil {
+		return ""
+	}
+
+	if !strings.HasPrefix(devicePath, "/sys/devices/pci") {
+		return ""
+	}
+
+	return filepath.Base(devicePath)
+}
+
+// pciDeviceBind binds the PCI device to the driver, unbinding it from its current one first.
+// An empty driver name leaves the device unbound. The driver override is kept for vfio-pci so
+// that no host driver grabs the device back while it's passed through.
+func pciDeviceBind(pciAddress string, driver string) error {
+	current, err := pciDeviceDriver(pciAddress)
+	if err != nil {
+		return err
+	}
+
+	if current == driver {
+		return nil
+	}
+
+	if current != "" {
+		err = ioutil.WriteFile(filepath.Join(pciDevPath, pciAddress, "driver", "unbind"), []byte(pciAddress), 0200)
+		if err != nil {
+			return fmt.Errorf("Failed to unbind PCI device %s from %q: %v", pciAddress, current, err)
+		}
+	}
+
+	// The override makes sure the device is only picked up by the requested driver.
+	override := driver
+	if override == "" {
+		override = "\n"
+	}
+
+	err = ioutil.WriteFile(filepath.Join(pciDevPath, pciAddress, "driver_override"), []byte(override), 0200)
+	if err != nil {
+		return fmt.Errorf("Failed to set the driver override of PCI device %s: %v", pciAddress, err)
+	}
+
+	if driver == "" {
+		return nil
+	}
+
+	err = ioutil.WriteFile("/sys/bus/pci/drivers_probe", []byte(pciAddress), 0200)
+	if err != nil {
+		return fmt.Errorf("Failed to bind PCI device %s to %q: %v", pciAddress, driver, err)
+	}
+
+	// Wait for the driver to pick up the device.
+	for i := 0; i < 10; i++ {
+		current, err = pciDeviceDriver(pciAddress)
+		if err == nil && current == driver {
+			break
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if current != driver {
+		return fmt.Errorf("Bind of PCI device %s to %q took too long", pciAddress, driver)
+	}
+
+	if driver != "vfio-pci" {
+		err = ioutil.WriteFile(filepath.Join(pciDevPath, pciAddress, "driver_override"), []byte("\n"), 0200)
+		if err != nil {
+			return fmt.Errorf("Failed to clear the driver override of PCI device %s: %v", pciAddress, err)
+		}
+	}
+
+	return nil
+}
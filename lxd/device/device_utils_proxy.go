@@ -15,8 +15,64 @@ type ProxyAddress struct {
 	Abstract bool
 }
 
-// ProxyParseAddr validates a proxy address and parses it into its constituent parts.
+// proxyPortBlock is a single port or port range as written in a proxy address.
+type proxyPortBlock struct {
+	first int64
+	count int64
+}
+
+// proxySplitAddrs splits a list of comma separated proxy addresses, a new address starts with each
+// entry carrying a connection type while the others are ports of the previous address.
+func proxySplitAddrs(addr string) []string {
+	addrs := []string{}
+	for _, entry := range strings.Split(addr, ",") {
+		if len(addrs) == 0 || shared.StringInSlice(strings.SplitN(entry, ":", 2)[0], []string{"tcp", "udp", "unix"}) {
+			addrs = append(addrs, entry)
+			continue
+		}
+
+		addrs[len(addrs)-1] = fmt.Sprintf("%s,%s", addrs[len(addrs)-1], entry)
+	}
+
+	return addrs
+}
+
+// ProxyParseAddr validates a proxy address, or a comma separated list of addresses of the same
+// connection type, and parses it into its constituent parts.
 func ProxyParseAddr(addr string) (*ProxyAddress, error) {
+	addrs := proxySplitAddrs(addr)
+	if len(addrs) == 1 {
+		return proxyParseAddr(addr)
+	}
+
+	var newProxyAddr *ProxyAddress
+	for _, entry := range addrs {
+		proxyAddr, err := proxyParseAddr(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		if proxyAddr.ConnType == "unix" {
+			return nil, fmt.Errorf("Multiple addresses aren't supported for unix sockets")
+		}
+
+		if newProxyAddr == nil {
+			newProxyAddr = proxyAddr
+			continue
+		}
+
+		if proxyAddr.ConnType != newProxyAddr.ConnType {
+			return nil, fmt.Errorf("All addresses must use the same connection type")
+		}
+
+		newProxyAddr.Addr = append(newProxyAddr.Addr, proxyAddr.Addr...)
+	}
+
+	return newProxyAddr, nil
+}
+
+// proxyParseAddr parses a single proxy address.
+func proxyParseAddr(addr string) (*ProxyAddress, error) {
 	// Split into <protocol> and <address>.
 	fields := strings.SplitN(addr, ":", 2)
 
@@ -71,3 +127,106 @@ func ProxyParseAddr(addr string) (*ProxyAddress, error) {
 
 	return newProxyAddr, nil
 }
+
+// proxyParsePortBlocks returns the ports and port ranges of a single proxy address in the order
+// they were given. Unix sockets count as a single port.
+func proxyParsePortBlocks(addr string) ([]proxyPortBlock, error) {
+	fields := strings.SplitN(addr, ":", 2)
+	if fields[0] == "unix" {
+		return []proxyPortBlock{{first: 0, count: 1}}, nil
+	}
+
+	_, port, err := net.SplitHostPort(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := []proxyPortBlock{}
+	for _, p := range strings.Split(port, ",") {
+		portFirst, portRange, err := networkParsePortRange(p)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, proxyPortBlock{first: portFirst, count: portRange})
+	}
+
+	return blocks, nil
+}
+
+// ProxyConnectAddrs returns the address to connect to for each of the addresses the listen value
+// expands to (in the order of ProxyAddress.Addr).
+//
+// When a listen address has as many ports as connect, they're mapped one to one. Otherwise the
+// ports and port ranges of both sides are paired in the order they were given. Within a pair the
+// listen ports are mapped in order onto the connect ports, with the last connect port of the pair
+// taking the remaining listen ports. Listen entries without a pair use the last connect port.
+func ProxyConnectAddrs(listen string, connect string) ([]string, error) {
+	if len(proxySplitAddrs(connect)) > 1 {
+		return nil, fmt.Errorf("Only a single connect address is supported")
+	}
+
+	connectAddr, err := ProxyParseAddr(connect)
+	if err != nil {
+		return nil, err
+	}
+
+	connectBlocks, err := proxyParsePortBlocks(connect)
+	if err != nil {
+		return nil, err
+	}
+
+	// Index of the first port of each connect entry in connectAddr.Addr.
+	connectStarts := make([]int64, len(connectBlocks))
+	for i := 1; i < len(connectBlocks); i++ {
+		connectStarts[i] = connectStarts[i-1] + connectBlocks[i-1].count
+	}
+
+	connectAddrs := []string{}
+	for _, addr := range proxySplitAddrs(listen) {
+		listenBlocks, err := proxyParsePortBlocks(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		listenCount := int64(0)
+		for _, block := range listenBlocks {
+			listenCount += block.count
+		}
+
+		if listenCount == int64(len(connectAddr.Addr)) {
+			connectAddrs = append(connectAddrs, connectAddr.Addr...)
+			continue
+		}
+
+		if len(connectBlocks) > len(listenBlocks) {
+			return nil, fmt.Errorf("Cannot map %d listen port entries onto %d connect port entries", len(listenBlocks), len(connectBlocks))
+		}
+
+		for i, listenBlock := range listenBlocks {
+			if i >= len(connectBlocks) {
+				for j := int64(0); j < listenBlock.count; j++ {
+					connectAddrs = append(connectAddrs, connectAddr.Addr[len(connectAddr.Addr)-1])
+				}
+
+				continue
+			}
+
+			c := i
+			if connectBlocks[c].count > listenBlock.count {
+				return nil, fmt.Errorf("Cannot map %d listen ports onto %d connect ports", listenBlock.count, connectBlocks[c].count)
+			}
+
+			for j := int64(0); j < listenBlock.count; j++ {
+				k := j
+				if k >= connectBlocks[c].count {
+					k = connectBlocks[c].count - 1
+				}
+
+				connectAddrs = append(connectAddrs, connectAddr.Addr[connectStarts[c]+k])
+			}
+		}
+	}
+
+	return connectAddrs, nil
+}
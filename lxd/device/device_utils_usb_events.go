@@ -2,6 +2,7 @@ package device
 
 import (
 	"fmt"
+	"io/ioutil"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -9,6 +10,7 @@ import (
 
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
 	log "github.com/lxc/lxd/shared/log15"
 	"github.com/lxc/lxd/shared/logger"
 )
@@ -20,6 +22,12 @@ type USBEvent struct {
 	Vendor  string
 	Product string
 
+	// USB class codes (two hex digits) of the device and of its interfaces, only known while
+	// the device is present on the system.
+	Class            string
+	InterfaceClasses []string
+
+	SysPath     string
 	Path        string
 	Major       uint32
 	Minor       uint32
@@ -93,8 +101,9 @@ func USBRunHandlers(state *state.State, event *USBEvent) {
 	}
 }
 
-// USBNewEvent instantiates a new USBEvent struct.
-func USBNewEvent(action string, vendor string, product string, major string, minor string, busnum string, devnum string, devname string, ueventParts []string, ueventLen int) (USBEvent, error) {
+// USBNewEvent instantiates a new USBEvent struct. The device and interface classes are read from
+// the sysfs path of the device when it exists.
+func USBNewEvent(action string, vendor string, product string, major string, minor string, busnum string, devnum string, devname string, sysPath string, ueventParts []string, ueventLen int) (USBEvent, error) {
 	majorInt, err := strconv.ParseUint(major, 10, 32)
 	if err != nil {
 		return USBEvent{}, err
@@ -123,14 +132,50 @@ func USBNewEvent(action string, vendor string, product string, major string, min
 		}
 	}
 
+	class, interfaceClasses := usbLoadClasses(sysPath)
+
 	return USBEvent{
-		action,
-		vendor,
-		product,
-		path,
-		uint32(majorInt),
-		uint32(minorInt),
-		ueventParts,
-		ueventLen,
+		Action:           action,
+		Vendor:           vendor,
+		Product:          product,
+		Class:            class,
+		InterfaceClasses: interfaceClasses,
+		SysPath:          sysPath,
+		Path:             path,
+		Major:            uint32(majorInt),
+		Minor:            uint32(minorInt),
+		UeventParts:      ueventParts,
+		UeventLen:        ueventLen,
 	}, nil
 }
+
+// usbLoadClasses reads the device class and the interface classes of a USB device from sysfs.
+func usbLoadClasses(sysPath string) (string, []string) {
+	interfaceClasses := []string{}
+	if sysPath == "" {
+		return "", interfaceClasses
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(sysPath, "bDeviceClass"))
+	if err != nil {
+		return "", interfaceClasses
+	}
+
+	class := strings.TrimSpace(string(content))
+
+	// Interfaces are named after the device bus path, e.g. "1-1:1.0" for device "1-1".
+	interfaces, _ := filepath.Glob(filepath.Join(sysPath, fmt.Sprintf("%s:*", filepath.Base(sysPath)), "bInterfaceClass"))
+	for _, iface := range interfaces {
+		content, err := ioutil.ReadFile(iface)
+		if err != nil {
+			continue
+		}
+
+		interfaceClass := strings.TrimSpace(string(content))
+		if !shared.StringInSlice(interfaceClass, interfaceClasses) {
+			interfaceClasses = append(interfaceClasses, interfaceClass)
+		}
+	}
+
+	return class, interfaceClasses
+}
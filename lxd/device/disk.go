@@ -19,6 +19,7 @@ import (
 	storagePools "github.com/lxc/lxd/lxd/storage"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/idmap"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/units"
 )
@@ -79,12 +80,15 @@ func (d *disk) validateConfig() error {
 		"pool":              shared.IsAny,
 		"propagation":       validatePropagation,
 		"raw.mount.options": shared.IsAny,
+		"raw.idmap":         validateDiskIdmap,
 		"ceph.cluster_name": shared.IsAny,
 		"ceph.user_name":    shared.IsAny,
+		"io.bus":            func(value string) error { return shared.IsOneOf(value, []string{"", "virtio-scsi", "nvme"}) },
+		"boot.priority":     shared.IsUint32,
 	}
 
-	// VMs can have a special cloud-init config drive attached with no path.
-	if d.instance.Type() != instancetype.VM || d.config["source"] != diskSourceCloudInit {
+	// VMs can have a special cloud-init config drive or a custom block volume attached with no path.
+	if d.instance.Type() != instancetype.VM || (d.config["source"] != diskSourceCloudInit && d.config["pool"] == "") {
 		rules["path"] = shared.IsNotEmpty
 	}
 
@@ -93,6 +97,28 @@ func (d *disk) validateConfig() error {
 		return err
 	}
 
+	if d.config["io.bus"] != "" && d.instance.Type() != instancetype.VM {
+		return fmt.Errorf("The \"io.bus\" property is only supported for virtual machines")
+	}
+
+	if d.config["raw.idmap"] != "" {
+		if d.instance.Type() != instancetype.Container {
+			return fmt.Errorf("The \"raw.idmap\" property is only supported for containers")
+		}
+
+		if d.config["path"] == "/" {
+			return fmt.Errorf("The \"raw.idmap\" property cannot be used with the root disk")
+		}
+
+		if shared.IsTrue(d.config["shift"]) {
+			return fmt.Errorf("The \"raw.idmap\" and \"shift\" properties cannot be used together")
+		}
+
+		if strings.HasPrefix(d.config["source"], "ceph:") || strings.HasPrefix(d.config["source"], "cephfs:") || (d.config["pool"] == "" && IsBlockdev(shared.HostPath(d.config["source"]))) {
+			return fmt.Errorf("The \"raw.idmap\" property is only supported for paths and custom volumes")
+		}
+	}
+
 	if d.config["required"] != "" && d.config["optional"] != "" {
 		return fmt.Errorf("Cannot use both \"required\" and deprecated \"optional\" properties at the same time")
 	}
@@ -128,7 +154,7 @@ func (d *disk) validateConfig() error {
 	// this can still be cleanly removed.
 	pathCount := 0
 	for _, devConfig := range d.instance.LocalDevices() {
-		if devConfig["type"] == "disk" && devConfig["path"] == d.config["path"] {
+		if devConfig["type"] == "disk" && devConfig["path"] != "" && devConfig["path"] == d.config["path"] {
 			pathCount++
 			if pathCount > 1 {
 				return fmt.Errorf("More than one disk device uses the same path: %s", d.config["path"])
@@ -154,7 +180,7 @@ func (d *disk) validateConfig() error {
 			return fmt.Errorf("Storage volumes cannot be specified as absolute paths")
 		}
 
-		_, err := d.state.Cluster.StoragePoolGetID(d.config["pool"])
+		poolID, err := d.state.Cluster.StoragePoolGetID(d.config["pool"])
 		if err != nil {
 			return fmt.Errorf("The \"%s\" storage pool doesn't exist", d.config["pool"])
 		}
@@ -171,6 +197,20 @@ func (d *disk) validateConfig() error {
 			if !isAvailable {
 				return fmt.Errorf("Storage volume %q is already attached to an instance on a different node", d.config["source"])
 			}
+
+			// Check the content type of the volume matches the instance type.
+			_, vol, err := d.state.Cluster.StoragePoolNodeVolumeGetTypeByProject("default", d.config["source"], db.StoragePoolVolumeTypeCustom, poolID)
+			if err != nil {
+				return fmt.Errorf("Failed loading custom volume: %v", err)
+			}
+
+			if d.instance.Type() == instancetype.Container && vol.ContentType == db.StoragePoolVolumeContentTypeNameBlock {
+				return fmt.Errorf("Custom block volumes cannot be attached to containers")
+			}
+
+			if d.instance.Type() == instancetype.VM && vol.ContentType != db.StoragePoolVolumeContentTypeNameBlock {
+				return fmt.Errorf("Only custom block volumes can be attached to virtual machines")
+			}
 		}
 	}
 
@@ -196,7 +236,13 @@ func (d *disk) validateEnvironment() error {
 // CanHotPlug returns whether the device can be managed whilst the instance is running, it also
 // returns a list of fields that can be updated without triggering a device remove & add.
 func (d *disk) CanHotPlug() (bool, []string) {
-	return true, []string{"limits.max", "limits.read", "limits.write", "size"}
+	// Additional disks cannot be hot plugged into virtual machines, their boot priority is
+	// applied on the next start.
+	if d.instance.Type() == instancetype.VM && !shared.IsRootDiskDevice(d.config) {
+		return false, []string{"boot.priority"}
+	}
+
+	return true, []string{"limits.max", "limits.read", "limits.write", "size", "boot.priority"}
 }
 
 // Start is run when the device is added to the instance.
@@ -288,8 +334,9 @@ func (d *disk) startContainer() (*deviceConfig.RunConfig, error) {
 		}
 
 		// If ownerShift is none and pool is specified then check whether the pool itself
-		// has owner shifting enabled, and if so enable shifting on this device too.
-		if ownerShift == deviceConfig.MountOwnerShiftNone && d.config["pool"] != "" {
+		// has owner shifting enabled, and if so enable shifting on this device too. Devices
+		// with their own idmap are already mapped by their idmapped mount.
+		if ownerShift == deviceConfig.MountOwnerShiftNone && d.config["pool"] != "" && d.config["raw.idmap"] == "" {
 			poolID, _, err := d.state.Cluster.StoragePoolGet(d.config["pool"])
 			if err != nil {
 				return nil, err
@@ -353,8 +400,15 @@ func (d *disk) startContainer() (*deviceConfig.RunConfig, error) {
 func (d *disk) startVM() (*deviceConfig.RunConfig, error) {
 	runConf := deviceConfig.RunConfig{}
 
+	// Disks can be presented to the guest on an alternative bus, this is passed as a "bus" option.
+	opts := []string{}
+	if d.config["io.bus"] != "" {
+		opts = append(opts, fmt.Sprintf("bus=%s", d.config["io.bus"]))
+	}
+
 	if shared.IsRootDiskDevice(d.config) {
 		runConf.RootFS.Path = d.config["path"]
+		runConf.RootFS.Opts = opts
 		return &runConf, nil
 	}
 
@@ -374,6 +428,34 @@ func (d *disk) startVM() (*deviceConfig.RunConfig, error) {
 		return &runConf, nil
 	}
 
+	// This is a custom block volume from a storage pool.
+	if d.config["pool"] != "" {
+		pool, err := storagePools.GetPoolByName(d.state, d.config["pool"])
+		if err != nil {
+			return nil, err
+		}
+
+		// Mount the volume, this unlocks encrypted volumes.
+		_, err = pool.MountCustomVolume(d.config["source"], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		diskPath, err := pool.GetCustomVolumeDisk(d.config["source"])
+		if err != nil {
+			return nil, err
+		}
+
+		runConf.Mounts = []deviceConfig.MountEntryItem{
+			{
+				DevPath:    diskPath,
+				TargetPath: d.name,
+				Opts:       opts,
+			},
+		}
+		return &runConf, nil
+	}
+
 	return nil, fmt.Errorf("Disk type not supported for VMs")
 }
 
@@ -393,7 +475,7 @@ func (d *disk) postStart() error {
 // Update applies configuration changes to a started device.
 func (d *disk) Update(oldDevices deviceConfig.Devices, isRunning bool) error {
 	if d.instance.Type() == instancetype.VM {
-		if shared.IsRootDiskDevice(d.config) {
+		if shared.IsRootDiskDevice(d.config) || d.config["pool"] != "" || d.config["source"] == diskSourceCloudInit {
 			return nil
 		}
 
@@ -482,10 +564,18 @@ func (d *disk) generateLimits(runConf *deviceConfig.RunConfig) error {
 				priority = 10
 			}
 
-			runConf.CGroups = append(runConf.CGroups, deviceConfig.RunConfigItem{
-				Key:   "blkio.weight",
-				Value: fmt.Sprintf("%d", priority),
-			})
+			version, _ := d.state.OS.CGInfo.SupportsVersion(cgroup.BlkioWeight)
+			if version == cgroup.V2 {
+				runConf.CGroups = append(runConf.CGroups, deviceConfig.RunConfigItem{
+					Key:   "io.weight",
+					Value: fmt.Sprintf("default %d", cgroup.BlkioWeightToIOWeight(priority)),
+				})
+			} else {
+				runConf.CGroups = append(runConf.CGroups, deviceConfig.RunConfigItem{
+					Key:   "blkio.weight",
+					Value: fmt.Sprintf("%d", priority),
+				})
+			}
 		} else {
 			return fmt.Errorf("Cannot apply limits.disk.priority as blkio.weight cgroup controller is missing")
 		}
@@ -513,6 +603,38 @@ func (d *disk) generateLimits(runConf *deviceConfig.RunConfig) error {
 			return err
 		}
 
+		// With cgroup2, all the limits of a block device are set at once through io.max.
+		version, _ := d.state.OS.CGInfo.SupportsVersion(cgroup.Blkio)
+		if version == cgroup.V2 {
+			for block, limit := range diskLimits {
+				limits := []string{}
+				if limit.readBps > 0 {
+					limits = append(limits, fmt.Sprintf("rbps=%d", limit.readBps))
+				}
+
+				if limit.readIops > 0 {
+					limits = append(limits, fmt.Sprintf("riops=%d", limit.readIops))
+				}
+
+				if limit.writeBps > 0 {
+					limits = append(limits, fmt.Sprintf("wbps=%d", limit.writeBps))
+				}
+
+				if limit.writeIops > 0 {
+					limits = append(limits, fmt.Sprintf("wiops=%d", limit.writeIops))
+				}
+
+				if len(limits) > 0 {
+					runConf.CGroups = append(runConf.CGroups, deviceConfig.RunConfigItem{
+						Key:   "io.max",
+						Value: fmt.Sprintf("%s %s", block, strings.Join(limits, " ")),
+					})
+				}
+			}
+
+			return nil
+		}
+
 		for block, limit := range diskLimits {
 			if limit.readBps > 0 {
 				runConf.CGroups = append(runConf.CGroups, deviceConfig.RunConfigItem{
@@ -582,7 +704,7 @@ func (d *disk) createDevice() (string, error) {
 			}
 
 			// Get the mount options.
-			mntSrcPath, fsOptions, fsErr := diskCephfsOptions(clusterName, userName, mdsName, mdsPath)
+			mntSrcPath, fsOptions, fsErr := diskCephfsOptions(d.state, clusterName, userName, mdsName, mdsPath)
 			if fsErr != nil {
 				return "", fsErr
 			}
@@ -725,7 +847,16 @@ func (d *disk) createDevice() (string, error) {
 		}
 	}
 
-	// Mount the fs.
+	// Mount the fs, through an idmapped mount if the device has its own idmap.
+	if d.config["raw.idmap"] != "" {
+		err := d.mountIdmapped(srcPath, devPath, isReadOnly, isRecursive)
+		if err != nil {
+			return "", err
+		}
+
+		return devPath, nil
+	}
+
 	err := DiskMount(srcPath, devPath, isReadOnly, isRecursive, d.config["propagation"], mntOptions, fsName)
 	if err != nil {
 		return "", err
@@ -734,10 +865,55 @@ func (d *disk) createDevice() (string, error) {
 	return devPath, nil
 }
 
+// mountIdmapped mounts srcPath onto devPath through an idmapped mount, so that the host IDs listed
+// in the raw.idmap property appear as their container IDs inside the container.
+func (d *disk) mountIdmapped(srcPath string, devPath string, readonly bool, recursive bool) error {
+	if d.config["raw.mount.options"] != "" {
+		return fmt.Errorf("The \"raw.mount.options\" property cannot be used with \"raw.idmap\"")
+	}
+
+	inst, ok := d.instance.(idmapInstance)
+	if !ok {
+		return fmt.Errorf("Instance doesn't have an idmap")
+	}
+
+	instIdmap, err := inst.CurrentIdmap()
+	if err != nil {
+		return err
+	}
+
+	mountIdmap, err := diskMountIdmap(d.config["raw.idmap"], instIdmap)
+	if err != nil {
+		return err
+	}
+
+	err = idmap.CreateIdmappedMount(srcPath, devPath, mountIdmap, recursive, readonly)
+	if err != nil {
+		return err
+	}
+
+	err = unix.Mount("", devPath, "", unix.MS_REC|unix.MS_SLAVE, "")
+	if err != nil {
+		unix.Unmount(devPath, unix.MNT_DETACH)
+		return fmt.Errorf("unable to make mount %s private: %s", devPath, err)
+	}
+
+	return nil
+}
+
 // Stop is run when the device is removed from the instance.
 func (d *disk) Stop() (*deviceConfig.RunConfig, error) {
 	if d.instance.Type() == instancetype.VM {
-		// Only root disks and cloud-init:config drives supported on VMs.
+		// Custom block volumes are unmounted once the VM is stopped.
+		if d.config["pool"] != "" && !shared.IsRootDiskDevice(d.config) {
+			runConf := deviceConfig.RunConfig{
+				PostHooks: []func() error{d.postStopVM},
+			}
+
+			return &runConf, nil
+		}
+
+		// Only root disks, cloud-init:config drives and custom block volumes supported on VMs.
 		if shared.IsRootDiskDevice(d.config) || d.config["source"] == diskSourceCloudInit {
 			return &deviceConfig.RunConfig{}, nil
 		}
@@ -804,6 +980,21 @@ func (d *disk) postStop() error {
 	return nil
 }
 
+// postStopVM is run after a custom block volume is removed from a VM.
+func (d *disk) postStopVM() error {
+	pool, err := storagePools.GetPoolByName(d.state, d.config["pool"])
+	if err != nil {
+		return err
+	}
+
+	_, err = pool.UnmountCustomVolume(d.config["source"], nil)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // getDiskLimits calculates Block I/O limits.
 func (d *disk) getDiskLimits() (map[string]diskBlockLimit, error) {
 	result := map[string]diskBlockLimit{}
@@ -1155,7 +1346,7 @@ func (d *disk) generateVMConfigDrive() (string, error) {
 	instanceConfig := d.instance.ExpandedConfig()
 
 	// Use an empty user-data file if no custom vendor-data supplied.
-	vendorData := instanceConfig["user.vendor-data"]
+	vendorData := shared.CloudInitConfig(instanceConfig, "vendor-data")
 	if vendorData == "" {
 		vendorData = "#cloud-config"
 	}
@@ -1166,7 +1357,7 @@ func (d *disk) generateVMConfigDrive() (string, error) {
 	}
 
 	// Use an empty user-data file if no custom user-data supplied.
-	userData := instanceConfig["user.user-data"]
+	userData := shared.CloudInitConfig(instanceConfig, "user-data")
 	if userData == "" {
 		userData = "#cloud-config"
 	}
@@ -1176,6 +1367,15 @@ func (d *disk) generateVMConfigDrive() (string, error) {
 		return "", err
 	}
 
+	// Only include a network-config file if custom network-config supplied.
+	networkConfig := shared.CloudInitConfig(instanceConfig, "network-config")
+	if networkConfig != "" {
+		err = ioutil.WriteFile(filepath.Join(scratchDir, "network-config"), []byte(networkConfig), 0400)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	// Append any custom meta-data to our predefined meta-data config.
 	metaData := fmt.Sprintf(`instance-id: %s
 local-hostname: %s
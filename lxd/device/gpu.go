@@ -9,16 +9,22 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/pborman/uuid"
 	"golang.org/x/sys/unix"
 
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/resources"
+	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
 )
 
 const gpuDRIDevPath = "/dev/dri"
 
+// gpuMPSPipeDirectory is the default location of the pipes of the CUDA MPS control daemon.
+const gpuMPSPipeDirectory = "/tmp/nvidia-mps"
+
 // Non-card devices such as {/dev/nvidiactl, /dev/nvidia-uvm, ...}
 type nvidiaNonCardDevice struct {
 	path  string
@@ -32,18 +38,47 @@ type gpu struct {
 
 // validateConfig checks the supplied config for correctness.
 func (d *gpu) validateConfig() error {
-	if d.instance.Type() != instancetype.Container {
+	// Mediated devices can only be used by virtual machines and MIG instances only by containers.
+	// Virtual machines get physical GPUs passed through with VFIO.
+	if d.config["gputype"] == "mdev" {
+		if d.instance.Type() != instancetype.VM {
+			return ErrUnsupportedDevType
+		}
+	} else if d.config["gputype"] == "mig" {
+		if d.instance.Type() != instancetype.Container {
+			return ErrUnsupportedDevType
+		}
+	} else if d.instance.Type() != instancetype.Container && d.instance.Type() != instancetype.VM {
 		return ErrUnsupportedDevType
 	}
 
 	rules := map[string]func(string) error{
+		"gputype": func(value string) error {
+			return shared.IsOneOf(value, []string{"", "physical", "mdev", "mig"})
+		},
+		"drm.nodes": func(value string) error {
+			return shared.IsOneOf(value, []string{"all", "render"})
+		},
 		"vendorid":  shared.IsDeviceID,
 		"productid": shared.IsDeviceID,
 		"id":        shared.IsAny,
 		"pci":       shared.IsAny,
+		"mdev":      shared.IsAny,
+		"mig.uuid":  shared.IsAny,
 		"uid":       unixValidUserID,
 		"gid":       unixValidUserID,
 		"mode":      unixValidOctalFileMode,
+
+		"mps":                shared.IsBool,
+		"mps.pipe_directory": shared.IsAny,
+
+		"romfile": func(value string) error {
+			if value != "" && !filepath.IsAbs(value) {
+				return fmt.Errorf("The romfile property must be an absolute path")
+			}
+
+			return nil
+		},
 	}
 
 	err := d.config.Validate(rules)
@@ -51,6 +86,50 @@ func (d *gpu) validateConfig() error {
 		return err
 	}
 
+	if d.config["gputype"] == "mdev" {
+		if d.config["pci"] == "" || d.config["mdev"] == "" {
+			return fmt.Errorf("Both pci and mdev must be set when gputype is mdev")
+		}
+	} else if d.config["mdev"] != "" {
+		return fmt.Errorf("The mdev property can only be used when gputype is mdev")
+	}
+
+	if d.config["gputype"] == "mig" {
+		if d.config["mig.uuid"] == "" {
+			return fmt.Errorf("The mig.uuid property must be set when gputype is mig")
+		}
+
+		if d.config["drm.nodes"] != "" {
+			return fmt.Errorf("The drm.nodes property cannot be used when gputype is mig")
+		}
+	} else if d.config["mig.uuid"] != "" {
+		return fmt.Errorf("The mig.uuid property can only be used when gputype is mig")
+	}
+
+	if d.isVFIO() {
+		if d.config["pci"] == "" {
+			return fmt.Errorf("The pci property must be set for physical GPUs of virtual machines")
+		}
+
+		for _, key := range []string{"drm.nodes", "mps", "uid", "gid", "mode"} {
+			if d.config[key] != "" {
+				return fmt.Errorf("The %s property cannot be used for physical GPUs of virtual machines", key)
+			}
+		}
+	} else if d.config["romfile"] != "" {
+		return fmt.Errorf("The romfile property can only be used for physical GPUs of virtual machines")
+	}
+
+	if d.config["mps.pipe_directory"] != "" {
+		if !shared.IsTrue(d.config["mps"]) {
+			return fmt.Errorf("The mps.pipe_directory property can only be used when mps is enabled")
+		}
+
+		if !filepath.IsAbs(d.config["mps.pipe_directory"]) {
+			return fmt.Errorf("The mps.pipe_directory property must be an absolute path")
+		}
+	}
+
 	if d.config["pci"] != "" && (d.config["id"] != "" || d.config["productid"] != "" || d.config["vendorid"] != "") {
 		return fmt.Errorf("Cannot use id, productid or vendorid when pci is set")
 	}
@@ -68,9 +147,42 @@ func (d *gpu) validateEnvironment() error {
 		return fmt.Errorf("Invalid PCI address (no device found): %s", d.config["pci"])
 	}
 
+	if d.config["gputype"] == "mdev" && !shared.PathExists(d.mdevTypePath()) {
+		return fmt.Errorf("Invalid mdev profile %q for GPU %s", d.config["mdev"], d.config["pci"])
+	}
+
+	// MIG instances are exposed by the NVIDIA container runtime rather than by LXD.
+	if d.config["gputype"] == "mig" && !shared.IsTrue(d.instance.ExpandedConfig()["nvidia.runtime"]) {
+		return fmt.Errorf("The NVIDIA runtime (nvidia.runtime) is required when gputype is mig")
+	}
+
+	if d.config["romfile"] != "" && !shared.PathExists(d.config["romfile"]) {
+		return fmt.Errorf("The VBIOS ROM file %q doesn't exist", d.config["romfile"])
+	}
+
+	if shared.IsTrue(d.config["mps"]) && !shared.PathExists(d.mpsPipeDirectory()) {
+		return fmt.Errorf("The CUDA MPS pipe directory %q doesn't exist, is the MPS control daemon running?", d.mpsPipeDirectory())
+	}
+
 	return nil
 }
 
+// isVFIO returns whether the device passes a physical GPU through to a virtual machine.
+func (d *gpu) isVFIO() bool {
+	return d.instance.Type() == instancetype.VM && shared.StringInSlice(d.config["gputype"], []string{"", "physical"})
+}
+
+// CanHotPlug returns whether the device can be managed whilst the instance is running. MIG
+// instances and MPS access are passed through the environment and so require a restart, as do
+// GPUs passed through to virtual machines.
+func (d *gpu) CanHotPlug() (bool, []string) {
+	if d.config["gputype"] == "mig" || shared.IsTrue(d.config["mps"]) || d.isVFIO() {
+		return false, []string{}
+	}
+
+	return true, []string{}
+}
+
 // Start is run when the device is added to the container.
 func (d *gpu) Start() (*deviceConfig.RunConfig, error) {
 	err := d.validateEnvironment()
@@ -78,7 +190,21 @@ func (d *gpu) Start() (*deviceConfig.RunConfig, error) {
 		return nil, err
 	}
 
+	if d.config["gputype"] == "mdev" {
+		return d.startMdev()
+	}
+
+	if d.isVFIO() {
+		return d.startVFIO()
+	}
+
 	runConf := deviceConfig.RunConfig{}
+	d.setupMPS(&runConf)
+
+	if d.config["gputype"] == "mig" {
+		return d.startMig(&runConf)
+	}
+
 	gpus, err := resources.GetGPU()
 	if err != nil {
 		return nil, err
@@ -97,7 +223,11 @@ func (d *gpu) Start() (*deviceConfig.RunConfig, error) {
 		if gpu.DRM != nil && (d.config["id"] == "" || fmt.Sprintf("%d", gpu.DRM.ID) == d.config["id"]) {
 			found = true
 
-			if gpu.DRM.CardName != "" && gpu.DRM.CardDevice != "" && shared.PathExists(filepath.Join(gpuDRIDevPath, gpu.DRM.CardName)) {
+			// Only the render node is needed for compute and rendering, it lets several
+			// instances share the card without giving them modesetting access.
+			renderOnly := d.config["drm.nodes"] == "render"
+
+			if !renderOnly && gpu.DRM.CardName != "" && gpu.DRM.CardDevice != "" && shared.PathExists(filepath.Join(gpuDRIDevPath, gpu.DRM.CardName)) {
 				path := filepath.Join(gpuDRIDevPath, gpu.DRM.CardName)
 				major, minor, err := d.deviceNumStringToUint32(gpu.DRM.CardDevice)
 				if err != nil {
@@ -123,7 +253,7 @@ func (d *gpu) Start() (*deviceConfig.RunConfig, error) {
 				}
 			}
 
-			if gpu.DRM.ControlName != "" && gpu.DRM.ControlDevice != "" && shared.PathExists(filepath.Join(gpuDRIDevPath, gpu.DRM.ControlName)) {
+			if !renderOnly && gpu.DRM.ControlName != "" && gpu.DRM.ControlDevice != "" && shared.PathExists(filepath.Join(gpuDRIDevPath, gpu.DRM.ControlName)) {
 				path := filepath.Join(gpuDRIDevPath, gpu.DRM.ControlName)
 				major, minor, err := d.deviceNumStringToUint32(gpu.DRM.ControlDevice)
 				if err != nil {
@@ -184,12 +314,182 @@ func (d *gpu) Start() (*deviceConfig.RunConfig, error) {
 	return &runConf, nil
 }
 
+// startMig exposes a NVIDIA MIG instance to the container. The device nodes are setup by the
+// NVIDIA container runtime, LXD only tells it which instances are visible.
+func (d *gpu) startMig(runConf *deviceConfig.RunConfig) (*deviceConfig.RunConfig, error) {
+	migUUID := d.config["mig.uuid"]
+	if !strings.HasPrefix(migUUID, "MIG-") {
+		migUUID = fmt.Sprintf("MIG-%s", migUUID)
+	}
+
+	runConf.GPUDevice = append(runConf.GPUDevice, deviceConfig.RunConfigItem{Key: "mig", Value: migUUID})
+
+	return runConf, nil
+}
+
+// mpsPipeDirectory returns the host path of the CUDA MPS pipe directory.
+func (d *gpu) mpsPipeDirectory() string {
+	if d.config["mps.pipe_directory"] != "" {
+		return d.config["mps.pipe_directory"]
+	}
+
+	return gpuMPSPipeDirectory
+}
+
+// setupMPS makes the host's CUDA MPS control daemon available in the container so that CUDA
+// clients of several instances time-share the GPU through a single MPS server.
+func (d *gpu) setupMPS(runConf *deviceConfig.RunConfig) {
+	if !shared.IsTrue(d.config["mps"]) {
+		return
+	}
+
+	pipeDir := d.mpsPipeDirectory()
+
+	runConf.Mounts = append(runConf.Mounts, deviceConfig.MountEntryItem{
+		DevPath:    pipeDir,
+		TargetPath: strings.TrimPrefix(pipeDir, "/"),
+		FSType:     "none",
+		Opts:       []string{"bind", "create=dir"},
+	})
+
+	runConf.GPUDevice = append(runConf.GPUDevice, deviceConfig.RunConfigItem{Key: "environment", Value: fmt.Sprintf("CUDA_MPS_PIPE_DIRECTORY=%s", pipeDir)})
+}
+
+// mdevTypePath returns the sysfs path of the mediated device type of the parent GPU.
+func (d *gpu) mdevTypePath() string {
+	return fmt.Sprintf("/sys/bus/pci/devices/%s/mdev_supported_types/%s", d.config["pci"], d.config["mdev"])
+}
+
+// startMdev creates the mediated device used by a virtual machine. The mdev UUID is recorded in
+// volatile config so the same device is re-created after a host reboot, when mediated devices are gone.
+func (d *gpu) startMdev() (*deviceConfig.RunConfig, error) {
+	saveData := make(map[string]string)
+	v := d.volatileGet()
+
+	mdevUUID := v["vgpu.uuid"]
+	if mdevUUID == "" {
+		mdevUUID = uuid.New()
+		saveData["vgpu.uuid"] = mdevUUID
+	}
+
+	if !shared.PathExists(fmt.Sprintf("/sys/bus/mdev/devices/%s", mdevUUID)) {
+		err := ioutil.WriteFile(filepath.Join(d.mdevTypePath(), "create"), []byte(mdevUUID), 0200)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create mdev %q of type %q: %v", mdevUUID, d.config["mdev"], err)
+		}
+	}
+
+	if len(saveData) > 0 {
+		err := d.volatileSet(saveData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	runConf := deviceConfig.RunConfig{}
+	runConf.GPUDevice = []deviceConfig.RunConfigItem{
+		{Key: "devName", Value: d.name},
+		{Key: "vgpu", Value: mdevUUID},
+	}
+
+	return &runConf, nil
+}
+
+// startVFIO binds the GPU and the other functions of the card, such as its audio controller, to
+// vfio-pci so that they are passed through to a virtual machine together. The host drivers are
+// recorded in volatile config so that they can be bound again on stop.
+func (d *gpu) startVFIO() (*deviceConfig.RunConfig, error) {
+	err := util.LoadModule("vfio-pci")
+	if err != nil {
+		return nil, fmt.Errorf("Error loading %q module: %v", "vfio-pci", err)
+	}
+
+	functions, err := pciDeviceFunctions(d.config["pci"])
+	if err != nil {
+		return nil, err
+	}
+
+	// Hold the reserved devices lock so that the IOMMU group can't be taken while it's checked.
+	reservedDevicesMutex.Lock()
+	defer reservedDevicesMutex.Unlock()
+
+	err = pciCheckIOMMUGroup(d.state, d.instance, d.name, d.config["pci"], functions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cards which the kernel can't reset may not work again after the instance restarts, as they
+	// are left in the state the guest driver put them in.
+	if !shared.PathExists(filepath.Join(pciDevPath, d.config["pci"], "reset")) {
+		logger.Warnf("GPU %s has no reset method, it may not be usable again until the host reboots", d.config["pci"])
+	}
+
+	// Records left over by an unclean stop are kept, as the card is then still bound to vfio-pci.
+	if d.volatileGet()["last_state.pci.address"] == "" {
+		driver, err := pciDeviceDriver(d.config["pci"])
+		if err != nil {
+			return nil, err
+		}
+
+		functionDrivers := []string{}
+		for _, function := range functions {
+			functionDriver, err := pciDeviceDriver(function)
+			if err != nil {
+				return nil, err
+			}
+
+			functionDrivers = append(functionDrivers, fmt.Sprintf("%s=%s", function, functionDriver))
+		}
+
+		err = d.volatileSet(map[string]string{
+			"last_state.pci.address":   d.config["pci"],
+			"last_state.pci.driver":    driver,
+			"last_state.pci.functions": strings.Join(functionDrivers, ","),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	runConf := deviceConfig.RunConfig{}
+	runConf.GPUDevice = []deviceConfig.RunConfigItem{
+		{Key: "devName", Value: d.name},
+		{Key: "pciSlotName", Value: d.config["pci"]},
+	}
+
+	// The other functions are unbound from the host first, as their host drivers (audio, USB)
+	// stop working once the GPU itself is bound to vfio-pci.
+	for _, function := range functions {
+		err = pciDeviceBind(function, "vfio-pci")
+		if err != nil {
+			return nil, err
+		}
+
+		runConf.GPUDevice = append(runConf.GPUDevice, deviceConfig.RunConfigItem{Key: "pciFunction", Value: function})
+	}
+
+	err = pciDeviceBind(d.config["pci"], "vfio-pci")
+	if err != nil {
+		return nil, err
+	}
+
+	if d.config["romfile"] != "" {
+		runConf.GPUDevice = append(runConf.GPUDevice, deviceConfig.RunConfigItem{Key: "romfile", Value: d.config["romfile"]})
+	}
+
+	return &runConf, nil
+}
+
 // Stop is run when the device is removed from the instance.
 func (d *gpu) Stop() (*deviceConfig.RunConfig, error) {
 	runConf := deviceConfig.RunConfig{
 		PostHooks: []func() error{d.postStop},
 	}
 
+	if d.config["gputype"] == "mdev" || d.isVFIO() {
+		return &runConf, nil
+	}
+
 	err := unixDeviceRemove(d.instance.DevicesPath(), "unix", d.name, "", &runConf)
 	if err != nil {
 		return nil, err
@@ -200,6 +500,24 @@ func (d *gpu) Stop() (*deviceConfig.RunConfig, error) {
 
 // postStop is run after the device is removed from the instance.
 func (d *gpu) postStop() error {
+	// Remove the mediated device, keeping its UUID around so it is re-created identically on next start.
+	if d.config["gputype"] == "mdev" {
+		mdevUUID := d.volatileGet()["vgpu.uuid"]
+		removePath := fmt.Sprintf("/sys/bus/mdev/devices/%s/remove", mdevUUID)
+		if mdevUUID != "" && shared.PathExists(removePath) {
+			err := ioutil.WriteFile(removePath, []byte("1"), 0200)
+			if err != nil {
+				return fmt.Errorf("Failed to remove mdev %q: %v", mdevUUID, err)
+			}
+		}
+
+		return nil
+	}
+
+	if d.isVFIO() {
+		return d.postStopVFIO()
+	}
+
 	// Remove host files for this device.
 	err := unixDeviceDeleteFiles(d.state, d.instance.DevicesPath(), "unix", d.name, "")
 	if err != nil {
@@ -209,6 +527,44 @@ func (d *gpu) postStop() error {
 	return nil
 }
 
+// postStopVFIO resets the GPU and gives it and the other functions of the card back to their host
+// drivers.
+func (d *gpu) postStopVFIO() error {
+	v := d.volatileGet()
+	if v["last_state.pci.address"] == "" {
+		return nil
+	}
+
+	// Reset the card so that the host driver doesn't find it in the state the guest left it in.
+	err := pciDeviceReset(v["last_state.pci.address"])
+	if err != nil {
+		return err
+	}
+
+	err = pciDeviceBind(v["last_state.pci.address"], v["last_state.pci.driver"])
+	if err != nil {
+		return err
+	}
+
+	for _, function := range strings.Split(v["last_state.pci.functions"], ",") {
+		fields := strings.SplitN(function, "=", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		err = pciDeviceBind(fields[0], fields[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	return d.volatileSet(map[string]string{
+		"last_state.pci.address":   "",
+		"last_state.pci.driver":    "",
+		"last_state.pci.functions": "",
+	})
+}
+
 // deviceNumStringToUint32 converts a device number string (major:minor) into separare major and
 // minor uint32s.
 func (d *gpu) deviceNumStringToUint32(devNum string) (uint32, uint32, error) {
@@ -12,6 +12,7 @@ import (
 	"math/rand"
 	"net"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -24,6 +25,7 @@ import (
 	"github.com/lxc/lxd/lxd/dnsmasq"
 	firewallConsts "github.com/lxc/lxd/lxd/firewall/consts"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
@@ -45,6 +47,9 @@ type dhcpRange struct {
 
 type nicBridged struct {
 	deviceCommon
+
+	network        string // Name of the parent network, as set in the device config.
+	networkProject string // Project the parent network is defined in.
 }
 
 // validateConfig checks the supplied config for correctness.
@@ -71,6 +76,7 @@ func (d *nicBridged) validateConfig() error {
 		"security.ipv6_filtering",
 		"maas.subnet.ipv4",
 		"maas.subnet.ipv6",
+		"boot.priority",
 	}
 	err := d.config.Validate(nicValidationRules(requiredFields, optionalFields))
 	if err != nil {
@@ -93,16 +99,41 @@ func (d *nicBridged) validateEnvironment() error {
 	return nil
 }
 
+// resolveParent replaces the parent in the device config with the name of the bridge on the host,
+// which is prefixed with the project name for the networks of projects with the networks feature
+// enabled. The name and project of the network are kept for the database lookups.
+func (d *nicBridged) resolveParent() error {
+	if d.network != "" || d.config["parent"] == "" {
+		return nil
+	}
+
+	networkProject, err := NetworkProject(d.state, d.instance.Project(), d.config["parent"])
+	if err != nil {
+		return err
+	}
+
+	d.network = d.config["parent"]
+	d.networkProject = networkProject
+	d.config["parent"] = project.Prefix(networkProject, d.network)
+
+	return nil
+}
+
 // CanHotPlug returns whether the device can be managed whilst the instance is running, it also
 // returns a list of fields that can be updated without triggering a device remove & add.
 func (d *nicBridged) CanHotPlug() (bool, []string) {
-	return true, []string{"limits.ingress", "limits.egress", "limits.max", "ipv4.routes", "ipv6.routes", "ipv4.address", "ipv6.address", "security.mac_filtering", "security.ipv4_filtering", "security.ipv6_filtering"}
+	return true, []string{"limits.ingress", "limits.egress", "limits.max", "ipv4.routes", "ipv6.routes", "ipv4.address", "ipv6.address", "security.mac_filtering", "security.ipv4_filtering", "security.ipv6_filtering", "mtu", "boot.priority"}
 }
 
 // Add is run when a device is added to an instance whether or not the instance is running.
 func (d *nicBridged) Add() error {
+	err := d.resolveParent()
+	if err != nil {
+		return err
+	}
+
 	// Rebuild dnsmasq entry if needed and reload.
-	err := d.rebuildDnsmasqEntry()
+	err = d.rebuildDnsmasqEntry()
 	if err != nil {
 		return err
 	}
@@ -112,7 +143,12 @@ func (d *nicBridged) Add() error {
 
 // Start is run when the device is added to a running instance or instance is starting up.
 func (d *nicBridged) Start() (*deviceConfig.RunConfig, error) {
-	err := d.validateEnvironment()
+	err := d.resolveParent()
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.validateEnvironment()
 	if err != nil {
 		return nil, err
 	}
@@ -123,6 +159,11 @@ func (d *nicBridged) Start() (*deviceConfig.RunConfig, error) {
 		saveData["host_name"] = NetworkRandomDevName("veth")
 	}
 
+	// VMs on openvswitch DPDK bridges are connected through vhost-user rather than a TAP.
+	if d.isVhostUser() {
+		return d.startVhostUser(saveData)
+	}
+
 	var peerName string // Only used with containers, empty for VMs.
 
 	// Create veth pair and configure the peer end with custom hwaddr and mtu if supplied.
@@ -180,6 +221,7 @@ func (d *nicBridged) Start() (*deviceConfig.RunConfig, error) {
 
 	if d.instance.Type() == instancetype.VM {
 		runConf.NetworkInterface = append(runConf.NetworkInterface,
+			deviceConfig.RunConfigItem{Key: "devName", Value: d.name},
 			deviceConfig.RunConfigItem{Key: "hwaddr", Value: d.config["hwaddr"]},
 		)
 	}
@@ -187,15 +229,136 @@ func (d *nicBridged) Start() (*deviceConfig.RunConfig, error) {
 	return &runConf, nil
 }
 
+// isVhostUser returns whether the device connects a VM to an openvswitch DPDK bridge through a
+// vhost-user port.
+func (d *nicBridged) isVhostUser() bool {
+	return d.instance.Type() == instancetype.VM && networkOVSBridgeIsDPDK(d.config["parent"])
+}
+
+// vhostUserSocketPath returns the path of the vhost-user socket served by QEMU for the device.
+func (d *nicBridged) vhostUserSocketPath() string {
+	return filepath.Join(d.instance.DevicesPath(), fmt.Sprintf("%s.vhost-user.sock", deviceNameEncode(d.name)))
+}
+
+// checkVhostUser checks that the device doesn't use properties which need a host side kernel
+// interface, which vhost-user ports don't have.
+func (d *nicBridged) checkVhostUser() error {
+	for _, key := range []string{"limits.ingress", "limits.egress", "limits.max", "ipv4.routes", "ipv6.routes"} {
+		if d.config[key] != "" {
+			return fmt.Errorf("The %q property isn't supported on DPDK bridges", key)
+		}
+	}
+
+	for _, key := range []string{"security.mac_filtering", "security.ipv4_filtering", "security.ipv6_filtering"} {
+		if shared.IsTrue(d.config[key]) {
+			return fmt.Errorf("The %q property isn't supported on DPDK bridges", key)
+		}
+	}
+
+	return nil
+}
+
+// startVhostUser adds a vhost-user port to the openvswitch DPDK bridge, connecting to the socket
+// QEMU serves for the device.
+func (d *nicBridged) startVhostUser(saveData map[string]string) (*deviceConfig.RunConfig, error) {
+	err := d.checkVhostUser()
+	if err != nil {
+		return nil, err
+	}
+
+	socketPath := d.vhostUserSocketPath()
+	if len(socketPath) >= 108 {
+		return nil, fmt.Errorf("The vhost-user socket path %q is too long", socketPath)
+	}
+
+	// Create the devices directory if missing.
+	if !shared.PathExists(d.instance.DevicesPath()) {
+		err := os.Mkdir(d.instance.DevicesPath(), 0711)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Remove any stale socket left by a previous run.
+	err = os.Remove(socketPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	err = networkCreateVhostUserPort(d.config["parent"], saveData["host_name"], socketPath, d.config["mtu"])
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.volatileSet(saveData)
+	if err != nil {
+		networkRemoveVhostUserPort(d.config["parent"], saveData["host_name"])
+		return nil, err
+	}
+
+	runConf := deviceConfig.RunConfig{}
+	runConf.NetworkInterface = []deviceConfig.RunConfigItem{
+		{Key: "name", Value: d.config["name"]},
+		{Key: "devName", Value: d.name},
+		{Key: "hwaddr", Value: d.config["hwaddr"]},
+		{Key: "vhostUserSocket", Value: socketPath},
+	}
+
+	return &runConf, nil
+}
+
+// updateVhostUser applies configuration changes to a running vhost-user port, which only
+// supports changing its MTU.
+func (d *nicBridged) updateVhostUser(oldConfig deviceConfig.Device, v map[string]string) error {
+	err := d.checkVhostUser()
+	if err != nil {
+		return err
+	}
+
+	if d.config["mtu"] == oldConfig["mtu"] {
+		return nil
+	}
+
+	mtu := d.config["mtu"]
+	if mtu == "" {
+		mtu = "[]"
+	}
+
+	_, err = shared.RunCommand("ovs-vsctl", "set", "Interface", v["host_name"], fmt.Sprintf("mtu_request=%s", mtu))
+	if err != nil {
+		return fmt.Errorf("Failed to set the MTU of the vhost-user port %s: %v", v["host_name"], err)
+	}
+
+	return nil
+}
+
 // Update applies configuration changes to a started device.
 func (d *nicBridged) Update(oldDevices deviceConfig.Devices, isRunning bool) error {
-	oldConfig := oldDevices[d.name]
+	err := d.resolveParent()
+	if err != nil {
+		return err
+	}
+
+	// The parent can't change while the device is started, so the old config uses the same bridge.
+	oldConfig := oldDevices[d.name].Clone()
+	oldConfig["parent"] = d.config["parent"]
 
 	// If an IPv6 address has changed, flush all existing IPv6 leases for instance so instance
 	// isn't allocated old IP. This is important with IPv6 because DHCPv6 supports multiple IP
 	// address allocation and would result in instance having leases for both old and new IPs.
-	if d.config["hwaddr"] != "" && d.config["ipv6.address"] != oldConfig["ipv6.address"] {
-		err := d.networkClearLease(d.instance.Name(), d.config["parent"], d.config["hwaddr"], clearLeaseIPv6Only)
+	// If an IPv4 address has changed, release the old IPv4 lease so that the reservation takes
+	// effect on the instance's next renewal without having to restart dnsmasq.
+	ipv4Changed := d.config["ipv4.address"] != oldConfig["ipv4.address"]
+	ipv6Changed := d.config["ipv6.address"] != oldConfig["ipv6.address"]
+	if d.config["hwaddr"] != "" && (ipv4Changed || ipv6Changed) {
+		clearMode := clearLeaseAll
+		if !ipv4Changed {
+			clearMode = clearLeaseIPv6Only
+		} else if !ipv6Changed {
+			clearMode = clearLeaseIPv4Only
+		}
+
+		err := d.networkClearLease(d.instance.Name(), d.config["parent"], d.config["hwaddr"], clearMode)
 		if err != nil {
 			return err
 		}
@@ -205,7 +368,12 @@ func (d *nicBridged) Update(oldDevices deviceConfig.Devices, isRunning bool) err
 
 	// If instance is running, apply host side limits and filters first before rebuilding
 	// dnsmasq config below so that existing config can be used as part of the filter removal.
-	if isRunning {
+	if isRunning && d.isVhostUser() {
+		err := d.updateVhostUser(oldConfig, v)
+		if err != nil {
+			return err
+		}
+	} else if isRunning {
 		err := d.validateEnvironment()
 		if err != nil {
 			return err
@@ -222,10 +390,18 @@ func (d *nicBridged) Update(oldDevices deviceConfig.Devices, isRunning bool) err
 		if err != nil {
 			return err
 		}
+
+		// Apply the new MTU to both ends of the veth pair (or the host side TAP for VMs).
+		if d.config["mtu"] != oldConfig["mtu"] {
+			err = networkUpdateMTU(d.instance, d.config["name"], v["host_name"], d.config["parent"], d.config["mtu"])
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	// Rebuild dnsmasq entry if needed and reload.
-	err := d.rebuildDnsmasqEntry()
+	err = d.rebuildDnsmasqEntry()
 	if err != nil {
 		return err
 	}
@@ -233,7 +409,7 @@ func (d *nicBridged) Update(oldDevices deviceConfig.Devices, isRunning bool) err
 	// If an IPv6 address has changed, if the instance is running we should bounce the host-side
 	// veth interface to give the instance a chance to detect the change and re-apply for an
 	// updated lease with new IP address.
-	if d.config["ipv6.address"] != oldConfig["ipv6.address"] && v["host_name"] != "" && shared.PathExists(fmt.Sprintf("/sys/class/net/%s", v["host_name"])) {
+	if ipv6Changed && v["host_name"] != "" && shared.PathExists(fmt.Sprintf("/sys/class/net/%s", v["host_name"])) {
 		_, err := shared.RunCommand("ip", "link", "set", v["host_name"], "down")
 		if err != nil {
 			return err
@@ -262,6 +438,11 @@ func (d *nicBridged) postStop() error {
 		"host_name": "",
 	})
 
+	err := d.resolveParent()
+	if err != nil {
+		return err
+	}
+
 	v := d.volatileGet()
 
 	if d.config["host_name"] == "" {
@@ -272,6 +453,23 @@ func (d *nicBridged) postStop() error {
 		d.config["hwaddr"] = v["hwaddr"]
 	}
 
+	// vhost-user ports have no host side interface, routes or filters.
+	if d.isVhostUser() {
+		if d.config["host_name"] != "" {
+			err := networkRemoveVhostUserPort(d.config["parent"], d.config["host_name"])
+			if err != nil {
+				return err
+			}
+		}
+
+		err := os.Remove(d.vhostUserSocketPath())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return nil
+	}
+
 	if d.config["host_name"] != "" && shared.PathExists(fmt.Sprintf("/sys/class/net/%s", d.config["host_name"])) {
 		// Removing host-side end of veth pair will delete the peer end too.
 		err := NetworkRemoveInterface(d.config["host_name"])
@@ -281,7 +479,7 @@ func (d *nicBridged) postStop() error {
 	}
 
 	networkRemoveVethRoutes(d.config)
-	err := d.removeFilters(d.config)
+	err = d.removeFilters(d.config)
 	if err != nil {
 		logger.Errorf("Failed to remove nic filters: %v", err)
 	}
@@ -291,7 +489,12 @@ func (d *nicBridged) postStop() error {
 
 // Remove is run when the device is removed from the instance or the instance is deleted.
 func (d *nicBridged) Remove() error {
-	err := d.networkClearLease(d.instance.Name(), d.config["parent"], d.config["hwaddr"], clearLeaseAll)
+	err := d.resolveParent()
+	if err != nil {
+		return err
+	}
+
+	err = d.networkClearLease(d.instance.Name(), d.config["parent"], d.config["hwaddr"], clearLeaseAll)
 	if err != nil {
 		return err
 	}
@@ -329,7 +532,7 @@ func (d *nicBridged) rebuildDnsmasqEntry() error {
 	dnsmasq.ConfigMutex.Lock()
 	defer dnsmasq.ConfigMutex.Unlock()
 
-	_, dbInfo, err := d.state.Cluster.NetworkGet(d.config["parent"])
+	_, dbInfo, err := d.state.Cluster.NetworkGet(d.networkProject, d.network)
 	if err != nil {
 		return err
 	}
@@ -534,21 +737,16 @@ func (d *nicBridged) setFilters() (err error) {
 	}
 
 	if shared.IsTrue(d.config["security.ipv6_filtering"]) {
-		// Check br_netfilter is loaded and enabled for IPv6.
-		sysctlPath := "net/bridge/bridge-nf-call-ip6tables"
-		sysctlVal, err := util.SysctlGet(sysctlPath)
+		// Check the firewall is able to filter IPv6 bridge traffic.
+		err := d.state.Firewall.VerifyIPv6Module()
 		if err != nil {
-			return fmt.Errorf("Error reading net sysctl %s: %v", sysctlPath, err)
-		}
-
-		if sysctlVal != "1\n" {
-			return fmt.Errorf("security.ipv6_filtering requires br_netfilter and sysctl net.bridge.bridge-nf-call-ip6tables=1")
+			return err
 		}
 	}
 
 	// Check if the parent is managed and load config. If parent is unmanaged continue anyway.
 	var IPv4, IPv6 net.IP
-	_, netInfo, err := d.state.Cluster.NetworkGet(d.config["parent"])
+	_, netInfo, err := d.state.Cluster.NetworkGet(d.networkProject, d.network)
 	if err != nil && err != db.ErrNoSuchObject {
 		return err
 	}
@@ -2,6 +2,7 @@ package device
 
 import (
 	"fmt"
+	"net"
 	"strings"
 
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
@@ -10,10 +11,22 @@ import (
 	"github.com/lxc/lxd/shared"
 )
 
+// ipvlanModes lists the supported IPVLAN modes, the first one being the default.
+var ipvlanModes = []string{"l3s", "l2", "l3"}
+
 type nicIPVLAN struct {
 	deviceCommon
 }
 
+// mode returns the configured IPVLAN mode.
+func (d *nicIPVLAN) mode() string {
+	if d.config["mode"] == "" {
+		return ipvlanModes[0]
+	}
+
+	return d.config["mode"]
+}
+
 func (d *nicIPVLAN) CanHotPlug() (bool, []string) {
 	return false, []string{}
 }
@@ -33,19 +46,36 @@ func (d *nicIPVLAN) validateConfig() error {
 	}
 
 	rules := nicValidationRules(requiredFields, optionalFields)
+	rules["mode"] = func(value string) error {
+		return shared.IsOneOf(value, ipvlanModes)
+	}
 	rules["ipv4.address"] = func(value string) error {
 		if value == "" {
 			return nil
 		}
 
-		return NetworkValidAddressV4List(value)
+		return d.validAddressList(value, NetworkValidAddressV4)
 	}
 	rules["ipv6.address"] = func(value string) error {
 		if value == "" {
 			return nil
 		}
 
-		return NetworkValidAddressV6List(value)
+		return d.validAddressList(value, NetworkValidAddressV6)
+	}
+	rules["ipv4.gateway"] = func(value string) error {
+		if value != "" && d.mode() != "l2" {
+			return fmt.Errorf("A gateway can only be set in l2 mode")
+		}
+
+		return NetworkValidAddressV4(value)
+	}
+	rules["ipv6.gateway"] = func(value string) error {
+		if value != "" && d.mode() != "l2" {
+			return fmt.Errorf("A gateway can only be set in l2 mode")
+		}
+
+		return NetworkValidAddressV6(value)
 	}
 
 	err := d.config.Validate(rules)
@@ -56,6 +86,33 @@ func (d *nicIPVLAN) validateConfig() error {
 	return nil
 }
 
+// validAddressList validates a comma delimited list of addresses. In l2 mode the addresses can
+// also be given in CIDR notation.
+func (d *nicIPVLAN) validAddressList(value string, validAddress func(value string) error) error {
+	for _, addr := range strings.Split(value, ",") {
+		addr = strings.TrimSpace(addr)
+
+		fields := strings.SplitN(addr, "/", 2)
+		if len(fields) == 2 {
+			if d.mode() != "l2" {
+				return fmt.Errorf("Addresses can only be given in CIDR notation in l2 mode: %s", addr)
+			}
+
+			_, _, err := net.ParseCIDR(addr)
+			if err != nil {
+				return fmt.Errorf("Invalid address: %s", addr)
+			}
+		}
+
+		err := validAddress(fields[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // validateEnvironment checks the runtime environment for correctness.
 func (d *nicIPVLAN) validateEnvironment() error {
 	if d.config["name"] == "" {
@@ -67,31 +124,40 @@ func (d *nicIPVLAN) validateEnvironment() error {
 	}
 
 	extensions := d.state.OS.LXCFeatures
-	if !extensions["network_ipvlan"] || !extensions["network_l2proxy"] || !extensions["network_gateway_device_route"] {
+	if !extensions["network_ipvlan"] {
+		return fmt.Errorf("Requires liblxc has following API extensions: network_ipvlan")
+	}
+
+	// In l2 mode the instance is directly on the parent's network, so nothing else is needed.
+	if d.mode() == "l2" {
+		return nil
+	}
+
+	if !extensions["network_l2proxy"] || !extensions["network_gateway_device_route"] {
 		return fmt.Errorf("Requires liblxc has following API extensions: network_ipvlan, network_l2proxy, network_gateway_device_route")
 	}
 
 	if d.config["ipv4.address"] != "" {
-		// Check necessary sysctls are configured for use with l2proxy parent in IPVLAN l3s mode.
+		// Check necessary sysctls are configured for use with l2proxy parent in IPVLAN l3 modes.
 		ipv4FwdPath := fmt.Sprintf("net/ipv4/conf/%s/forwarding", d.config["parent"])
 		sysctlVal, err := util.SysctlGet(ipv4FwdPath)
-		if err != nil || sysctlVal != "1\n" {
+		if err != nil {
 			return fmt.Errorf("Error reading net sysctl %s: %v", ipv4FwdPath, err)
 		}
 		if sysctlVal != "1\n" {
-			return fmt.Errorf("IPVLAN in L3S mode requires sysctl net.ipv4.conf.%s.forwarding=1", d.config["parent"])
+			return fmt.Errorf("IPVLAN in %s mode requires sysctl net.ipv4.conf.%s.forwarding=1", strings.ToUpper(d.mode()), d.config["parent"])
 		}
 	}
 
 	if d.config["ipv6.address"] != "" {
-		// Check necessary sysctls are configured for use with l2proxy parent in IPVLAN l3s mode.
+		// Check necessary sysctls are configured for use with l2proxy parent in IPVLAN l3 modes.
 		ipv6FwdPath := fmt.Sprintf("net/ipv6/conf/%s/forwarding", d.config["parent"])
 		sysctlVal, err := util.SysctlGet(ipv6FwdPath)
 		if err != nil {
 			return fmt.Errorf("Error reading net sysctl %s: %v", ipv6FwdPath, err)
 		}
 		if sysctlVal != "1\n" {
-			return fmt.Errorf("IPVLAN in L3S mode requires sysctl net.ipv6.conf.%s.forwarding=1", d.config["parent"])
+			return fmt.Errorf("IPVLAN in %s mode requires sysctl net.ipv6.conf.%s.forwarding=1", strings.ToUpper(d.mode()), d.config["parent"])
 		}
 
 		ipv6ProxyNdpPath := fmt.Sprintf("net/ipv6/conf/%s/proxy_ndp", d.config["parent"])
@@ -100,7 +166,7 @@ func (d *nicIPVLAN) validateEnvironment() error {
 			return fmt.Errorf("Error reading net sysctl %s: %v", ipv6ProxyNdpPath, err)
 		}
 		if sysctlVal != "1\n" {
-			return fmt.Errorf("IPVLAN in L3S mode requires sysctl net.ipv6.conf.%s.proxy_ndp=1", d.config["parent"])
+			return fmt.Errorf("IPVLAN in %s mode requires sysctl net.ipv6.conf.%s.proxy_ndp=1", strings.ToUpper(d.mode()), d.config["parent"])
 		}
 	}
 
@@ -132,7 +198,7 @@ func (d *nicIPVLAN) Start() (*deviceConfig.RunConfig, error) {
 	saveData["last_state.created"] = fmt.Sprintf("%t", statusDev != "existing")
 
 	// If we created a VLAN interface, we need to setup the sysctls on that interface.
-	if statusDev == "created" {
+	if statusDev == "created" && d.mode() != "l2" {
 		err := d.setupParentSysctls(parentName)
 		if err != nil {
 			return nil, err
@@ -144,43 +210,72 @@ func (d *nicIPVLAN) Start() (*deviceConfig.RunConfig, error) {
 		return nil, err
 	}
 
+	mode := d.mode()
+
 	runConf := deviceConfig.RunConfig{}
 	nic := []deviceConfig.RunConfigItem{
 		{Key: "name", Value: d.config["name"]},
 		{Key: "type", Value: "ipvlan"},
 		{Key: "flags", Value: "up"},
-		{Key: "ipvlan.mode", Value: "l3s"},
+		{Key: "ipvlan.mode", Value: mode},
 		{Key: "ipvlan.isolation", Value: "bridge"},
-		{Key: "l2proxy", Value: "1"},
 		{Key: "link", Value: parentName},
 	}
 
+	// In the l3 modes the parent answers ARP/NDP requests for the instance's addresses.
+	if mode != "l2" {
+		nic = append(nic, deviceConfig.RunConfigItem{Key: "l2proxy", Value: "1"})
+	}
+
 	if d.config["mtu"] != "" {
 		nic = append(nic, deviceConfig.RunConfigItem{Key: "mtu", Value: d.config["mtu"]})
 	}
 
+	// In the l3 modes the addresses are host routes and the gateway is the device itself, in l2
+	// mode they're on the parent's subnet and the gateway is optional.
 	if d.config["ipv4.address"] != "" {
 		for _, addr := range strings.Split(d.config["ipv4.address"], ",") {
-			addr = strings.TrimSpace(addr)
-			nic = append(nic, deviceConfig.RunConfigItem{Key: "ipv4.address", Value: fmt.Sprintf("%s/32", addr)})
+			nic = append(nic, deviceConfig.RunConfigItem{Key: "ipv4.address", Value: d.addressWithPrefix(addr, "32", "24")})
 		}
 
-		nic = append(nic, deviceConfig.RunConfigItem{Key: "ipv4.gateway", Value: "dev"})
+		if mode != "l2" {
+			nic = append(nic, deviceConfig.RunConfigItem{Key: "ipv4.gateway", Value: "dev"})
+		} else if d.config["ipv4.gateway"] != "" {
+			nic = append(nic, deviceConfig.RunConfigItem{Key: "ipv4.gateway", Value: d.config["ipv4.gateway"]})
+		}
 	}
 
 	if d.config["ipv6.address"] != "" {
 		for _, addr := range strings.Split(d.config["ipv6.address"], ",") {
-			addr = strings.TrimSpace(addr)
-			nic = append(nic, deviceConfig.RunConfigItem{Key: "ipv6.address", Value: fmt.Sprintf("%s/128", addr)})
+			nic = append(nic, deviceConfig.RunConfigItem{Key: "ipv6.address", Value: d.addressWithPrefix(addr, "128", "64")})
 		}
 
-		nic = append(nic, deviceConfig.RunConfigItem{Key: "ipv6.gateway", Value: "dev"})
+		if mode != "l2" {
+			nic = append(nic, deviceConfig.RunConfigItem{Key: "ipv6.gateway", Value: "dev"})
+		} else if d.config["ipv6.gateway"] != "" {
+			nic = append(nic, deviceConfig.RunConfigItem{Key: "ipv6.gateway", Value: d.config["ipv6.gateway"]})
+		}
 	}
 
 	runConf.NetworkInterface = nic
 	return &runConf, nil
 }
 
+// addressWithPrefix returns the address in CIDR notation, using the host prefix in the l3 modes and
+// the given l2 prefix in l2 mode if the address doesn't specify one.
+func (d *nicIPVLAN) addressWithPrefix(addr string, hostPrefix string, l2Prefix string) string {
+	addr = strings.TrimSpace(addr)
+	if strings.Contains(addr, "/") {
+		return addr
+	}
+
+	if d.mode() == "l2" {
+		return fmt.Sprintf("%s/%s", addr, l2Prefix)
+	}
+
+	return fmt.Sprintf("%s/%s", addr, hostPrefix)
+}
+
 // setupParentSysctls configures the required sysctls on the parent to allow l2proxy to work.
 // Because of our policy not to modify sysctls on existing interfaces, this should only be called
 // if we created the parent interface.
@@ -41,6 +41,12 @@ func (d *nicMACVLAN) validateEnvironment() error {
 	return nil
 }
 
+// CanHotPlug returns whether the device can be managed whilst the instance is running, it also
+// returns a list of fields that can be updated without triggering a device remove & add.
+func (d *nicMACVLAN) CanHotPlug() (bool, []string) {
+	return true, []string{"mtu"}
+}
+
 // Start is run when the device is added to a running instance or instance is starting up.
 func (d *nicMACVLAN) Start() (*deviceConfig.RunConfig, error) {
 	err := d.validateEnvironment()
@@ -115,6 +121,24 @@ func (d *nicMACVLAN) Start() (*deviceConfig.RunConfig, error) {
 	return &runConf, nil
 }
 
+// Update applies configuration changes to a started device.
+func (d *nicMACVLAN) Update(oldDevices deviceConfig.Devices, isRunning bool) error {
+	oldConfig := oldDevices[d.name]
+
+	if !isRunning || d.config["mtu"] == oldConfig["mtu"] {
+		return nil
+	}
+
+	err := d.validateEnvironment()
+	if err != nil {
+		return err
+	}
+
+	// The MACVLAN interface lives in the instance, so only its side has to be changed.
+	parentName := NetworkGetHostDevice(d.config["parent"], d.config["vlan"])
+	return networkUpdateMTU(d.instance, d.config["name"], "", parentName, d.config["mtu"])
+}
+
 // Stop is run when the device is removed from the instance.
 func (d *nicMACVLAN) Stop() (*deviceConfig.RunConfig, error) {
 	v := d.volatileGet()
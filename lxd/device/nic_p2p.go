@@ -14,7 +14,7 @@ type nicP2P struct {
 
 // validateConfig checks the supplied config for correctness.
 func (d *nicP2P) validateConfig() error {
-	if d.instance.Type() != instancetype.Container {
+	if d.instance.Type() != instancetype.Container && d.instance.Type() != instancetype.VM {
 		return ErrUnsupportedDevType
 	}
 
@@ -28,6 +28,7 @@ func (d *nicP2P) validateConfig() error {
 		"limits.max",
 		"ipv4.routes",
 		"ipv6.routes",
+		"boot.priority",
 	}
 	err := d.config.Validate(nicValidationRules([]string{}, optionalFields))
 	if err != nil {
@@ -49,7 +50,7 @@ func (d *nicP2P) validateEnvironment() error {
 // CanHotPlug returns whether the device can be managed whilst the instance is running, it also
 // returns a list of fields that can be updated without triggering a device remove & add.
 func (d *nicP2P) CanHotPlug() (bool, []string) {
-	return true, []string{"limits.ingress", "limits.egress", "limits.max", "ipv4.routes", "ipv6.routes"}
+	return true, []string{"limits.ingress", "limits.egress", "limits.max", "ipv4.routes", "ipv6.routes", "boot.priority"}
 }
 
 // Start is run when the device is added to a running instance or instance is starting up.
@@ -65,8 +66,16 @@ func (d *nicP2P) Start() (*deviceConfig.RunConfig, error) {
 		saveData["host_name"] = NetworkRandomDevName("veth")
 	}
 
+	var peerName string
+
 	// Create veth pair and configure the peer end with custom hwaddr and mtu if supplied.
-	peerName, err := networkCreateVethPair(saveData["host_name"], d.config)
+	if d.instance.Type() == instancetype.Container {
+		peerName, err = networkCreateVethPair(saveData["host_name"], d.config)
+	} else if d.instance.Type() == instancetype.VM {
+		peerName = saveData["host_name"] // VMs use the host_name to link to the TAP FD.
+		err = networkCreateTap(saveData["host_name"])
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -91,6 +100,13 @@ func (d *nicP2P) Start() (*deviceConfig.RunConfig, error) {
 		{Key: "link", Value: peerName},
 	}
 
+	if d.instance.Type() == instancetype.VM {
+		runConf.NetworkInterface = append(runConf.NetworkInterface,
+			deviceConfig.RunConfigItem{Key: "devName", Value: d.name},
+			deviceConfig.RunConfigItem{Key: "hwaddr", Value: d.config["hwaddr"]},
+		)
+	}
+
 	return &runConf, nil
 }
 
@@ -140,7 +156,7 @@ func (d *nicP2P) postStop() error {
 	}
 
 	if d.config["host_name"] != "" && shared.PathExists(fmt.Sprintf("/sys/class/net/%s", d.config["host_name"])) {
-		// Removing host-side end of veth pair will delete the peer end too.
+		// Removing host-side end of veth pair will delete the peer end too, VMs only have a TAP.
 		err := NetworkRemoveInterface(d.config["host_name"])
 		if err != nil {
 			return fmt.Errorf("Failed to remove interface %s: %s", d.config["host_name"], err)
@@ -2,6 +2,7 @@ package device
 
 import (
 	"fmt"
+	"net"
 	"strings"
 
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
@@ -18,7 +19,7 @@ type nicRouted struct {
 }
 
 func (d *nicRouted) CanHotPlug() (bool, []string) {
-	return false, []string{}
+	return false, []string{"ipv4.routes", "ipv6.routes"}
 }
 
 // validateConfig checks the supplied config for correctness.
@@ -35,6 +36,8 @@ func (d *nicRouted) validateConfig() error {
 		"hwaddr",
 		"host_name",
 		"vlan",
+		"ipv4.routes",
+		"ipv6.routes",
 	}
 
 	rules := nicValidationRules(requiredFields, optionalFields)
@@ -58,6 +61,15 @@ func (d *nicRouted) validateConfig() error {
 		return err
 	}
 
+	// The routes use the instance's address as next-hop.
+	if d.config["ipv4.routes"] != "" && d.config["ipv4.address"] == "" {
+		return fmt.Errorf("The ipv4.routes setting requires ipv4.address to be set")
+	}
+
+	if d.config["ipv6.routes"] != "" && d.config["ipv6.address"] == "" {
+		return fmt.Errorf("The ipv6.routes setting requires ipv6.address to be set")
+	}
+
 	return nil
 }
 
@@ -264,9 +276,75 @@ func (d *nicRouted) postStart() error {
 		}
 	}
 
+	// Add the static routes towards the instance.
+	if v["host_name"] != "" {
+		err := networkSetVethRoutes(d.routesConfig(d.config, v))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Announce the instance's addresses over BGP.
+	if d.state.BGP != nil {
+		for _, key := range []string{"ipv4.address", "ipv6.address"} {
+			if d.config[key] == "" {
+				continue
+			}
+
+			for _, addr := range strings.Split(d.config[key], ",") {
+				ip := net.ParseIP(strings.TrimSpace(addr))
+				if ip == nil {
+					continue
+				}
+
+				bits := 128
+				if ip.To4() != nil {
+					bits = 32
+				}
+
+				err := d.state.BGP.AddPrefix(net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil, d.bgpOwner())
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
 	return nil
 }
 
+// routesConfig returns a copy of the device config with the host side veth name set, as used to
+// add and remove the static routes of the device.
+func (d *nicRouted) routesConfig(config deviceConfig.Device, v map[string]string) deviceConfig.Device {
+	m := config.Clone()
+	if m["host_name"] == "" {
+		m["host_name"] = v["host_name"]
+	}
+
+	return m
+}
+
+// Update applies changes to the static routes of a started device.
+func (d *nicRouted) Update(oldDevices deviceConfig.Devices, isRunning bool) error {
+	if !isRunning {
+		return nil
+	}
+
+	v := d.volatileGet()
+	if v["host_name"] == "" {
+		return fmt.Errorf("Failed to find host side veth name for device \"%s\"", d.name)
+	}
+
+	networkRemoveVethRoutes(d.routesConfig(oldDevices[d.name], v))
+
+	return networkSetVethRoutes(d.routesConfig(d.config, v))
+}
+
+// bgpOwner returns the owner string used to track the prefixes announced for this device.
+func (d *nicRouted) bgpOwner() string {
+	return fmt.Sprintf("instance_%s_%s_%s", d.instance.Project(), d.instance.Name(), d.name)
+}
+
 // Stop is run when the device is removed from the instance.
 func (d *nicRouted) Stop() (*deviceConfig.RunConfig, error) {
 	runConf := deviceConfig.RunConfig{
@@ -285,6 +363,19 @@ func (d *nicRouted) postStop() error {
 
 	v := d.volatileGet()
 
+	// Remove the static routes, if the host side veth is still around.
+	if v["host_name"] != "" {
+		networkRemoveVethRoutes(d.routesConfig(d.config, v))
+	}
+
+	// Withdraw the instance's addresses from BGP.
+	if d.state.BGP != nil {
+		err := d.state.BGP.RemovePrefixByOwner(d.bgpOwner())
+		if err != nil {
+			return err
+		}
+	}
+
 	// This will delete the parent interface if we created it for VLAN parent.
 	if shared.IsTrue(v["last_state.created"]) {
 		parentName := NetworkGetHostDevice(d.config["parent"], d.config["vlan"])
@@ -0,0 +1,127 @@
+package device
+
+import (
+	"fmt"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+)
+
+type pci struct {
+	deviceCommon
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *pci) validateConfig() error {
+	if d.instance.Type() != instancetype.VM {
+		return ErrUnsupportedDevType
+	}
+
+	rules := map[string]func(string) error{
+		"address": pciValidAddress,
+	}
+
+	err := d.config.Validate(rules)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateEnvironment checks the runtime environment for correctness.
+func (d *pci) validateEnvironment() error {
+	if !shared.PathExists(fmt.Sprintf("%s/%s", pciDevPath, d.config["address"])) {
+		return fmt.Errorf("Invalid PCI address (no device found): %s", d.config["address"])
+	}
+
+	return nil
+}
+
+// CanHotPlug returns whether the device can be managed whilst the instance is running.
+func (d *pci) CanHotPlug() (bool, []string) {
+	return false, []string{}
+}
+
+// Start is run when the device is added to the instance.
+func (d *pci) Start() (*deviceConfig.RunConfig, error) {
+	err := d.validateEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	err = util.LoadModule("vfio-pci")
+	if err != nil {
+		return nil, fmt.Errorf("Error loading %q module: %v", "vfio-pci", err)
+	}
+
+	// Hold the reserved devices lock so that the IOMMU group can't be taken while it's checked.
+	reservedDevicesMutex.Lock()
+	defer reservedDevicesMutex.Unlock()
+
+	err = pciCheckIOMMUGroup(d.state, d.instance, d.name, d.config["address"], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := pciDeviceDriver(d.config["address"])
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the host driver before rebinding the device, so that it can be restored on stop. The
+	// address also marks the device as in use by this instance. A record left over by an unclean
+	// stop is kept, as the device is then still bound to vfio-pci.
+	if d.volatileGet()["last_state.pci.address"] == "" {
+		err = d.volatileSet(map[string]string{
+			"last_state.pci.address": d.config["address"],
+			"last_state.pci.driver":  driver,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = pciDeviceBind(d.config["address"], "vfio-pci")
+	if err != nil {
+		return nil, err
+	}
+
+	runConf := deviceConfig.RunConfig{}
+	runConf.PCIDevice = []deviceConfig.RunConfigItem{
+		{Key: "devName", Value: d.name},
+		{Key: "pciSlotName", Value: d.config["address"]},
+	}
+
+	return &runConf, nil
+}
+
+// Stop is run when the device is removed from the instance.
+func (d *pci) Stop() (*deviceConfig.RunConfig, error) {
+	runConf := deviceConfig.RunConfig{
+		PostHooks: []func() error{d.postStop},
+	}
+
+	return &runConf, nil
+}
+
+// postStop is run after the device is removed from the instance.
+func (d *pci) postStop() error {
+	v := d.volatileGet()
+	if v["last_state.pci.address"] == "" {
+		return nil
+	}
+
+	// Give the device back to its host driver.
+	err := pciDeviceBind(v["last_state.pci.address"], v["last_state.pci.driver"])
+	if err != nil {
+		return err
+	}
+
+	return d.volatileSet(map[string]string{
+		"last_state.pci.address": "",
+		"last_state.pci.driver":  "",
+	})
+}
@@ -88,9 +88,9 @@ func (d *proxy) validateConfig() error {
 		return err
 	}
 
-	if len(connectAddr.Addr) > len(listenAddr.Addr) {
-		// Cannot support single port -> multiple port
-		return fmt.Errorf("Cannot map a single port to multiple ports")
+	_, err = ProxyConnectAddrs(d.config["listen"], d.config["connect"])
+	if err != nil {
+		return err
 	}
 
 	if shared.IsTrue(d.config["proxy_protocol"]) && !strings.HasPrefix(d.config["connect"], "tcp") {
@@ -252,12 +252,12 @@ func (d *proxy) setupNAT() error {
 		return err
 	}
 
-	connectAddr, err := ProxyParseAddr(d.config["connect"])
+	connectAddrs, err := ProxyConnectAddrs(d.config["listen"], d.config["connect"])
 	if err != nil {
 		return err
 	}
 
-	address, _, err := net.SplitHostPort(connectAddr.Addr[0])
+	address, _, err := net.SplitHostPort(connectAddrs[0])
 	if err != nil {
 		return err
 	}
@@ -308,11 +308,10 @@ func (d *proxy) setupNAT() error {
 		if err != nil {
 			return err
 		}
-		var cPort string
-		if len(connectAddr.Addr) == 1 {
-			_, cPort, _ = net.SplitHostPort(connectAddr.Addr[0])
-		} else {
-			_, cPort, _ = net.SplitHostPort(connectAddr.Addr[i])
+
+		_, cPort, err := net.SplitHostPort(connectAddrs[i])
+		if err != nil {
+			return err
 		}
 
 		if IPv4Addr != nil {
@@ -5,7 +5,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
@@ -24,9 +26,32 @@ func usbIsOurDevice(config deviceConfig.Device, usb *USBEvent) bool {
 		return false
 	}
 
+	// The class of a removed device can't be read anymore, so let removal go through as it is
+	// a no-op for devices that weren't passed to the instance.
+	if config["class"] != "" && !(usb.Action == "remove" && usb.Class == "") {
+		class := strings.ToLower(config["class"])
+		if usb.Class != class && !shared.StringInSlice(class, usb.InterfaceClasses) {
+			return false
+		}
+	}
+
 	return true
 }
 
+// usbValidClass validates a USB class code (two hex digits, e.g. "03" for HID devices).
+func usbValidClass(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, err := strconv.ParseUint(value, 16, 8)
+	if err != nil || len(value) != 2 {
+		return fmt.Errorf("Invalid USB class code %q, must be two hexadecimal digits", value)
+	}
+
+	return nil
+}
+
 type usb struct {
 	deviceCommon
 }
@@ -50,6 +75,7 @@ func (d *usb) validateConfig() error {
 	rules := map[string]func(string) error{
 		"vendorid":  shared.IsDeviceID,
 		"productid": shared.IsDeviceID,
+		"class":     usbValidClass,
 		"uid":       unixValidUserID,
 		"gid":       unixValidUserID,
 		"mode":      unixValidOctalFileMode,
@@ -75,6 +101,19 @@ func (d *usb) Register() error {
 
 	// Handler for when a USB event occurs.
 	f := func(e USBEvent) (*deviceConfig.RunConfig, error) {
+		// The interfaces of a newly added device show up shortly after the device itself,
+		// so give them a chance to appear when matching on class.
+		if devConfig["class"] != "" && e.Action == "add" && len(e.InterfaceClasses) == 0 {
+			for i := 0; i < 10; i++ {
+				time.Sleep(100 * time.Millisecond)
+
+				e.Class, e.InterfaceClasses = usbLoadClasses(e.SysPath)
+				if len(e.InterfaceClasses) > 0 {
+					break
+				}
+			}
+		}
+
 		if !usbIsOurDevice(devConfig, &e) {
 			return nil, nil
 		}
@@ -208,6 +247,7 @@ func (d *usb) loadUsb() ([]USBEvent, error) {
 			values["busnum"],
 			values["devnum"],
 			values["devname"],
+			path.Join(usbDevPath, ent.Name()),
 			[]string{},
 			0,
 		)
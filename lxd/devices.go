@@ -15,6 +15,7 @@ import (
 	"github.com/lxc/lxd/lxd/device"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/resources"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/shared"
 	log "github.com/lxc/lxd/shared/log15"
@@ -167,6 +168,7 @@ func deviceNetlinkListener() (chan []string, chan []string, chan device.USBEvent
 					busnum,
 					devnum,
 					devname,
+					fmt.Sprintf("/sys%s", props["DEVPATH"]),
 					ueventParts[:len(ueventParts)-1],
 					ueventLen,
 				)
@@ -184,42 +186,6 @@ func deviceNetlinkListener() (chan []string, chan []string, chan device.USBEvent
 	return chCPU, chNetwork, chUSB, nil
 }
 
-func parseCpuset(cpu string) ([]int, error) {
-	cpus := []int{}
-	chunks := strings.Split(cpu, ",")
-	for _, chunk := range chunks {
-		if strings.Contains(chunk, "-") {
-			// Range
-			fields := strings.SplitN(chunk, "-", 2)
-			if len(fields) != 2 {
-				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
-			}
-
-			low, err := strconv.Atoi(fields[0])
-			if err != nil {
-				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
-			}
-
-			high, err := strconv.Atoi(fields[1])
-			if err != nil {
-				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
-			}
-
-			for i := low; i <= high; i++ {
-				cpus = append(cpus, i)
-			}
-		} else {
-			// Simple entry
-			nr, err := strconv.Atoi(chunk)
-			if err != nil {
-				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
-			}
-			cpus = append(cpus, nr)
-		}
-	}
-	return cpus, nil
-}
-
 func deviceTaskBalance(s *state.State) {
 	min := func(x, y int) int {
 		if x < y {
@@ -244,7 +210,7 @@ func deviceTaskBalance(s *state.State) {
 		}
 	}
 
-	effectiveCpusInt, err := parseCpuset(effectiveCpus)
+	effectiveCpusInt, err := resources.ParseCpuset(effectiveCpus)
 	if err != nil {
 		logger.Errorf("Error parsing effective CPU set")
 		return
@@ -261,7 +227,7 @@ func deviceTaskBalance(s *state.State) {
 		// File might exist even though there are no isolated cpus.
 		isolatedCpus := strings.TrimSpace(string(buf))
 		if isolatedCpus != "" {
-			isolatedCpusInt, err = parseCpuset(isolatedCpus)
+			isolatedCpusInt, err = resources.ParseCpuset(isolatedCpus)
 			if err != nil {
 				logger.Errorf("Error parsing isolated CPU set: %s", string(isolatedCpus))
 				return
@@ -284,14 +250,14 @@ func deviceTaskBalance(s *state.State) {
 	if err != nil && shared.PathExists("/sys/fs/cgroup/cpuset/lxc") {
 		logger.Warn("Error setting lxd's cpuset.cpus", log.Ctx{"err": err})
 	}
-	cpus, err := parseCpuset(effectiveCpus)
+	cpus, err := resources.ParseCpuset(effectiveCpus)
 	if err != nil {
 		logger.Error("Error parsing host's cpu set", log.Ctx{"cpuset": effectiveCpus, "err": err})
 		return
 	}
 
 	// Iterate through the instances
-	instances, err := instanceLoadNodeAll(s, instancetype.Container)
+	instances, err := instanceLoadNodeAll(s, instancetype.Any)
 	if err != nil {
 		logger.Error("Problem loading instances list", log.Ctx{"err": err})
 		return
@@ -299,25 +265,78 @@ func deviceTaskBalance(s *state.State) {
 
 	fixedInstances := map[int][]instance.Instance{}
 	balancedInstances := map[instance.Instance]int{}
+	nodeInstances := map[instance.Instance][]int{}
 	for _, c := range instances {
+		if !c.IsRunning() {
+			continue
+		}
+
 		conf := c.ExpandedConfig()
 		cpulimit, ok := conf["limits.cpu"]
+
+		// Virtual machines bind their own CPU threads, only account for the CPUs they're pinned to.
+		if c.Type() == instancetype.VM {
+			_, err := strconv.Atoi(cpulimit)
+			if !ok || cpulimit == "" || err == nil {
+				continue
+			}
+		}
+
+		// Restrict the instance to the CPUs of its NUMA nodes.
+		if conf["limits.cpu.nodes"] != "" {
+			nodes, err := resources.ParseCpuset(conf["limits.cpu.nodes"])
+			if err != nil {
+				continue
+			}
+
+			nodeCpus := []int{}
+			for _, node := range nodes {
+				ids, err := resources.GetNUMANodeCPUs(node)
+				if err != nil {
+					logger.Error("balance: Unable to get NUMA node CPUs", log.Ctx{"name": c.Name(), "node": node, "err": err})
+					continue
+				}
+
+				for _, id := range ids {
+					if shared.IntInSlice(id, cpus) {
+						nodeCpus = append(nodeCpus, id)
+					}
+				}
+			}
+
+			if len(nodeCpus) > 0 {
+				nodeInstances[c] = nodeCpus
+			}
+		}
+
 		if !ok || cpulimit == "" {
 			cpulimit = effectiveCpus
-		}
 
-		if !c.IsRunning() {
-			continue
+			nodeCpus, ok := nodeInstances[c]
+			if ok {
+				nodeCpusSlice := []string{}
+				for _, id := range nodeCpus {
+					nodeCpusSlice = append(nodeCpusSlice, fmt.Sprintf("%d", id))
+				}
+
+				cpulimit = strings.Join(nodeCpusSlice, ",")
+			}
 		}
 
 		count, err := strconv.Atoi(cpulimit)
 		if err == nil {
 			// Load-balance
-			count = min(count, len(cpus))
+			nodeCpus, ok := nodeInstances[c]
+			if ok {
+				count = min(count, len(nodeCpus))
+			} else {
+				count = min(count, len(cpus))
+			}
+
 			balancedInstances[c] = count
 		} else {
 			// Pinned
-			containerCpus, err := parseCpuset(cpulimit)
+			containerCpus, err := resources.ParseCpuset(cpulimit)
 			if err != nil {
 				return
 			}
@@ -374,11 +393,17 @@ func deviceTaskBalance(s *state.State) {
 	}
 
 	for ctn, count := range balancedInstances {
+		nodeCpus, restricted := nodeInstances[ctn]
+
 		sort.Sort(sortedUsage)
 		for _, cpu := range sortedUsage {
 			if count == 0 {
 				break
 			}
+
+			if restricted && !shared.IntInSlice(cpu.id, nodeCpus) {
+				continue
+			}
 			count -= 1
 
 			id := cpu.strId
@@ -395,7 +420,7 @@ func deviceTaskBalance(s *state.State) {
 	// Set the new pinning
 	for ctn, set := range pinning {
 		// Confirm the container didn't just stop
-		if !ctn.IsRunning() {
+		if !ctn.IsRunning() || ctn.Type() != instancetype.Container {
 			continue
 		}
 
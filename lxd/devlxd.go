@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -25,6 +26,7 @@ import (
 	"github.com/lxc/lxd/lxd/ucred"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/version"
 )
@@ -60,10 +62,60 @@ type devLxdHandler struct {
 	f func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse
 }
 
+var devlxdAPIGet = devLxdHandler{"/1.0", func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+	if r.Method == "PATCH" {
+		return devlxdAPIPatch(d, c, r)
+	}
+
+	state := api.Started.String()
+	if shared.IsTrue(c.LocalConfig()["volatile.last_state.ready"]) {
+		state = api.Ready.String()
+	}
+
+	return okResponse(shared.Jmap{"api_version": version.APIVersion, "state": state}, "json")
+}}
+
+// devlxdAPIPatch lets the guest signal whether it's ready, recording it in
+// volatile.last_state.ready until the instance stops.
+func devlxdAPIPatch(d *Daemon, c instance.Instance, r *http.Request) *devLxdResponse {
+	req := struct {
+		State string `json:"state"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return &devLxdResponse{"bad request", http.StatusBadRequest, "raw"}
+	}
+
+	ready := ""
+	switch req.State {
+	case api.Ready.String():
+		ready = "true"
+	case api.Started.String():
+	default:
+		return &devLxdResponse{fmt.Sprintf("invalid state %q", req.State), http.StatusBadRequest, "raw"}
+	}
+
+	if c.LocalConfig()["volatile.last_state.ready"] == ready {
+		return okResponse("", "raw")
+	}
+
+	err = c.VolatileSet(map[string]string{"volatile.last_state.ready": ready})
+	if err != nil {
+		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+	}
+
+	if ready != "" {
+		d.State().Events.SendLifecycle(c.Project(), "container-ready", fmt.Sprintf("/1.0/containers/%s", c.Name()), nil)
+	}
+
+	return okResponse("", "raw")
+}
+
 var devlxdConfigGet = devLxdHandler{"/1.0/config", func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
 	filtered := []string{}
 	for k := range c.ExpandedConfig() {
-		if strings.HasPrefix(k, "user.") {
+		if strings.HasPrefix(k, "user.") || strings.HasPrefix(k, "cloud-init.") {
 			filtered = append(filtered, fmt.Sprintf("/1.0/config/%s", k))
 		}
 	}
@@ -72,7 +124,7 @@ var devlxdConfigGet = devLxdHandler{"/1.0/config", func(d *Daemon, c instance.In
 
 var devlxdConfigKeyGet = devLxdHandler{"/1.0/config/{key}", func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
 	key := mux.Vars(r)["key"]
-	if !strings.HasPrefix(key, "user.") {
+	if !strings.HasPrefix(key, "user.") && !strings.HasPrefix(key, "cloud-init.") {
 		return &devLxdResponse{"not authorized", http.StatusForbidden, "raw"}
 	}
 
@@ -118,7 +170,7 @@ var devlxdEventsGet = devLxdHandler{"/1.0/events", func(d *Daemon, c instance.In
 	}
 	defer conn.Close() // This ensures the go routine below is ended when this function ends.
 
-	listener, err := d.devlxdEvents.AddListener(strconv.Itoa(c.ID()), conn, strings.Split(typeStr, ","), "", false)
+	listener, err := d.devlxdEvents.AddListener(strconv.Itoa(c.ID()), conn, strings.Split(typeStr, ","), "", false, false)
 	if err != nil {
 		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
 	}
@@ -164,9 +216,7 @@ var handlers = []devLxdHandler{
 	{"/", func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
 		return okResponse([]string{"/1.0"}, "json")
 	}},
-	{"/1.0", func(d *Daemon, c instance.Instance, w http.ResponseWriter, r *http.Request) *devLxdResponse {
-		return okResponse(shared.Jmap{"api_version": version.APIVersion}, "json")
-	}},
+	devlxdAPIGet,
 	devlxdConfigGet,
 	devlxdConfigKeyGet,
 	devlxdMetadataGet,
@@ -0,0 +1,108 @@
+package dhcpdns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigFile is the name of the file holding the configuration of the native DHCP and DNS
+// server, in the directory of the network.
+const ConfigFile = "dhcpdns.conf"
+
+// Config holds the settings of the native DHCP and DNS server of a network.
+type Config struct {
+	Interface string `json:"interface"`
+	Domain    string `json:"domain"`
+
+	// Address of the cluster DNS relay (forkdns) used for names which aren't known locally,
+	// along with the subnet whose reverse names it can answer.
+	DNSRelay       string `json:"dns_relay"`
+	DNSRelaySubnet string `json:"dns_relay_subnet"`
+
+	IPv4Address string   `json:"ipv4_address"` // Address of the bridge, in CIDR notation.
+	IPv4DHCP    bool     `json:"ipv4_dhcp"`
+	IPv4Gateway string   `json:"ipv4_gateway"`
+	IPv4Ranges  []string `json:"ipv4_ranges"` // FIRST-LAST address ranges.
+	IPv4Expiry  string   `json:"ipv4_expiry"`
+
+	// Router advertisements are sent whenever an IPv6 address is set. Instances configure
+	// themselves through SLAAC and, if IPv6SLAACNames is set, their names resolve to the EUI-64
+	// address derived from their MAC address.
+	IPv6Address        string   `json:"ipv6_address"` // Address of the bridge, in CIDR notation.
+	IPv6RADefaultRoute bool     `json:"ipv6_ra_default_route"`
+	IPv6RADNS          []string `json:"ipv6_ra_dns"`
+	IPv6RAMTU          uint32   `json:"ipv6_ra_mtu"`
+	IPv6SLAACNames     bool     `json:"ipv6_slaac_names"`
+}
+
+// WriteConfig writes the configuration to the given path.
+func WriteConfig(path string, config Config) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// LoadConfig reads the configuration from the given path.
+func LoadConfig(path string) (Config, error) {
+	config := Config{}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return config, fmt.Errorf("Failed to parse %q: %v", path, err)
+	}
+
+	return config, nil
+}
+
+// ParseExpiry parses a lease time in the format used by dnsmasq ("infinite", or a number of
+// seconds optionally suffixed with s, m, h, d or w). Infinite leases are returned as 0. Like
+// dnsmasq, lease times shorter than two minutes are raised to two minutes.
+func ParseExpiry(value string) (time.Duration, error) {
+	if value == "" {
+		return time.Hour, nil
+	}
+
+	if value == "infinite" {
+		return 0, nil
+	}
+
+	units := map[byte]time.Duration{
+		's': time.Second,
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+
+	unit := time.Second
+	number := value
+	multiplier, ok := units[value[len(value)-1]]
+	if ok {
+		unit = multiplier
+		number = value[:len(value)-1]
+	}
+
+	count, err := strconv.ParseUint(strings.TrimSpace(number), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid lease time %q", value)
+	}
+
+	expiry := time.Duration(count) * unit
+	if expiry < 2*time.Minute {
+		expiry = 2 * time.Minute
+	}
+
+	return expiry, nil
+}
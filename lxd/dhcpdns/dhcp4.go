@@ -0,0 +1,563 @@
+package dhcpdns
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// DHCPv4 message types (RFC 2132 option 53).
+const (
+	dhcpDiscover = 1
+	dhcpOffer    = 2
+	dhcpRequest  = 3
+	dhcpDecline  = 4
+	dhcpAck      = 5
+	dhcpNak      = 6
+	dhcpRelease  = 7
+	dhcpInform   = 8
+)
+
+// DHCPv4 options used by the server.
+const (
+	optSubnetMask    = 1
+	optRouter        = 3
+	optDNSServer     = 6
+	optHostname      = 12
+	optDomainName    = 15
+	optRequestedIP   = 50
+	optLeaseTime     = 51
+	optMessageType   = 53
+	optServerID      = 54
+	optRenewalTime   = 58
+	optRebindingTime = 59
+	optClientID      = 61
+	optEnd           = 255
+	optPad           = 0
+)
+
+// Addresses declined by a client (because they are already in use) aren't offered again for a while.
+const declineTimeout = 10 * time.Minute
+
+var dhcpMagicCookie = []byte{99, 130, 83, 99}
+
+// dhcpPacket is a decoded DHCPv4 message.
+type dhcpPacket struct {
+	op      byte
+	xid     []byte
+	flags   []byte
+	ciaddr  net.IP
+	yiaddr  net.IP
+	giaddr  net.IP
+	chaddr  net.HardwareAddr
+	options map[byte][]byte
+}
+
+// parseDHCPPacket decodes a DHCPv4 message from an Ethernet client.
+func parseDHCPPacket(data []byte) (*dhcpPacket, error) {
+	if len(data) < 240 {
+		return nil, fmt.Errorf("Packet too short (%d bytes)", len(data))
+	}
+
+	if data[1] != 1 || data[2] != 6 {
+		return nil, fmt.Errorf("Unsupported hardware type %d", data[1])
+	}
+
+	if !bytes.Equal(data[236:240], dhcpMagicCookie) {
+		return nil, fmt.Errorf("Missing DHCP magic cookie")
+	}
+
+	p := &dhcpPacket{
+		op:      data[0],
+		xid:     append([]byte{}, data[4:8]...),
+		flags:   append([]byte{}, data[10:12]...),
+		ciaddr:  net.IP(append([]byte{}, data[12:16]...)),
+		yiaddr:  net.IP(append([]byte{}, data[16:20]...)),
+		giaddr:  net.IP(append([]byte{}, data[24:28]...)),
+		chaddr:  net.HardwareAddr(append([]byte{}, data[28:34]...)),
+		options: map[byte][]byte{},
+	}
+
+	options := data[240:]
+	for i := 0; i < len(options); {
+		code := options[i]
+		if code == optEnd {
+			break
+		}
+
+		if code == optPad {
+			i++
+			continue
+		}
+
+		if i+1 >= len(options) || i+2+int(options[i+1]) > len(options) {
+			return nil, fmt.Errorf("Truncated option %d", code)
+		}
+
+		length := int(options[i+1])
+		p.options[code] = append(p.options[code], options[i+2:i+2+length]...)
+		i += 2 + length
+	}
+
+	return p, nil
+}
+
+// messageType returns the DHCP message type of the packet, or 0 for plain BOOTP.
+func (p *dhcpPacket) messageType() byte {
+	value := p.options[optMessageType]
+	if len(value) != 1 {
+		return 0
+	}
+
+	return value[0]
+}
+
+// optionIP returns the IPv4 address held by an option, or nil.
+func (p *dhcpPacket) optionIP(code byte) net.IP {
+	value := p.options[code]
+	if len(value) != 4 {
+		return nil
+	}
+
+	return net.IP(value)
+}
+
+// reply builds a reply to the packet with the given options, which are written in order.
+func (p *dhcpPacket) reply(yiaddr net.IP, options [][]byte) []byte {
+	data := make([]byte, 240)
+	data[0] = 2 // BOOTREPLY
+	data[1] = 1 // Ethernet
+	data[2] = 6
+	copy(data[4:8], p.xid)
+	copy(data[10:12], p.flags)
+	copy(data[12:16], p.ciaddr.To4())
+	if yiaddr != nil {
+		copy(data[16:20], yiaddr.To4())
+	}
+
+	copy(data[24:28], p.giaddr.To4())
+	copy(data[28:34], p.chaddr)
+	copy(data[236:240], dhcpMagicCookie)
+
+	for _, option := range options {
+		data = append(data, option...)
+	}
+
+	data = append(data, optEnd)
+
+	// Some clients refuse replies shorter than the BOOTP minimum.
+	for len(data) < 300 {
+		data = append(data, optPad)
+	}
+
+	return data
+}
+
+// dhcpOption encodes a single option.
+func dhcpOption(code byte, value []byte) []byte {
+	return append([]byte{code, byte(len(value))}, value...)
+}
+
+func dhcpOptionUint32(code byte, value uint32) []byte {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, value)
+	return dhcpOption(code, buf)
+}
+
+// ipToUint32 converts an IPv4 address to an integer.
+func ipToUint32(ip net.IP) uint32 {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint32(ip4)
+}
+
+// uint32ToIP converts an integer to an IPv4 address.
+func uint32ToIP(value uint32) net.IP {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, value)
+	return ip
+}
+
+type dhcpRange struct {
+	start uint32
+	end   uint32
+}
+
+// dhcp4Server hands out the IPv4 addresses of a network.
+type dhcp4Server struct {
+	server *Server
+
+	ip      net.IP
+	subnet  *net.IPNet
+	gateway net.IP
+	ranges  []dhcpRange
+	expiry  time.Duration
+
+	declined map[uint32]time.Time
+}
+
+func newDHCP4Server(s *Server) (*dhcp4Server, error) {
+	d := &dhcp4Server{
+		server:   s,
+		declined: map[uint32]time.Time{},
+	}
+
+	var err error
+	d.ip, d.subnet, err = net.ParseCIDR(s.config.IPv4Address)
+	if err != nil {
+		return nil, err
+	}
+
+	d.ip = d.ip.To4()
+	d.gateway = d.ip
+	if s.config.IPv4Gateway != "" {
+		d.gateway = net.ParseIP(s.config.IPv4Gateway).To4()
+		if d.gateway == nil {
+			return nil, fmt.Errorf("Invalid DHCP gateway %q", s.config.IPv4Gateway)
+		}
+	}
+
+	d.expiry, err = ParseExpiry(s.config.IPv4Expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range s.config.IPv4Ranges {
+		fields := strings.SplitN(strings.TrimSpace(r), "-", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Invalid DHCP range %q", r)
+		}
+
+		start := net.ParseIP(fields[0]).To4()
+		end := net.ParseIP(fields[1]).To4()
+		if start == nil || end == nil || ipToUint32(start) > ipToUint32(end) {
+			return nil, fmt.Errorf("Invalid DHCP range %q", r)
+		}
+
+		d.ranges = append(d.ranges, dhcpRange{start: ipToUint32(start), end: ipToUint32(end)})
+	}
+
+	return d, nil
+}
+
+// inRanges returns whether the address is part of one of the dynamic ranges.
+func (d *dhcp4Server) inRanges(ip net.IP) bool {
+	value := ipToUint32(ip)
+	for _, r := range d.ranges {
+		if value >= r.start && value <= r.end {
+			return true
+		}
+	}
+
+	return false
+}
+
+// staticHost returns the static allocation of a MAC address, if any. Must be called with the
+// server lock held.
+func (d *dhcp4Server) staticHost(mac net.HardwareAddr) *staticHost {
+	for i, host := range d.server.hosts {
+		if bytes.Equal(host.mac, mac) {
+			return &d.server.hosts[i]
+		}
+	}
+
+	return nil
+}
+
+// available returns whether the address can be given to the MAC address. Must be called with the
+// server lock held.
+func (d *dhcp4Server) available(mac net.HardwareAddr, ip net.IP, now time.Time) bool {
+	ip = ip.To4()
+	if ip == nil || !d.subnet.Contains(ip) || ip.Equal(d.ip) {
+		return false
+	}
+
+	// Static allocations take precedence over everything else.
+	host := d.staticHost(mac)
+	if host != nil && host.ipv4 != nil {
+		return ip.Equal(host.ipv4)
+	}
+
+	for _, other := range d.server.hosts {
+		if other.ipv4 != nil && other.ipv4.Equal(ip) {
+			return false
+		}
+	}
+
+	if !d.inRanges(ip) {
+		return false
+	}
+
+	declined, ok := d.declined[ipToUint32(ip)]
+	if ok && now.Before(declined.Add(declineTimeout)) {
+		return false
+	}
+
+	for key, l := range d.server.leases {
+		if l.ip.Equal(ip) && key != mac.String() && !l.expired(now) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// allocate picks the address to offer to a MAC address: its static address, its current lease,
+// the address it asked for or else the first free address of the ranges. Must be called with the
+// server lock held.
+func (d *dhcp4Server) allocate(mac net.HardwareAddr, requested net.IP, now time.Time) net.IP {
+	host := d.staticHost(mac)
+	if host != nil && host.ipv4 != nil {
+		return host.ipv4
+	}
+
+	l, ok := d.server.leases[mac.String()]
+	if ok && d.available(mac, l.ip, now) {
+		return l.ip
+	}
+
+	if requested != nil && d.available(mac, requested, now) {
+		return requested.To4()
+	}
+
+	for _, r := range d.ranges {
+		for value := r.start; value <= r.end && value >= r.start; value++ {
+			ip := uint32ToIP(value)
+			if d.available(mac, ip, now) {
+				return ip
+			}
+		}
+	}
+
+	return nil
+}
+
+// handle processes a request and returns the reply to send, if any.
+func (d *dhcp4Server) handle(p *dhcpPacket) ([]byte, error) {
+	// Requests relayed from other networks aren't supported.
+	if p.op != 1 || !p.giaddr.Equal(net.IPv4zero) {
+		return nil, nil
+	}
+
+	s := d.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	switch p.messageType() {
+	case dhcpDiscover:
+		ip := d.allocate(p.chaddr, p.optionIP(optRequestedIP), now)
+		if ip == nil {
+			return nil, fmt.Errorf("No address available for %s", p.chaddr)
+		}
+
+		return p.reply(ip, d.options(dhcpOffer, true)), nil
+
+	case dhcpRequest:
+		// The client picked the offer of another server.
+		serverID := p.optionIP(optServerID)
+		if serverID != nil && !serverID.Equal(d.ip) {
+			return nil, nil
+		}
+
+		ip := p.optionIP(optRequestedIP)
+		if ip == nil {
+			ip = p.ciaddr
+		}
+
+		if !d.available(p.chaddr, ip, now) {
+			return p.reply(nil, [][]byte{
+				dhcpOption(optMessageType, []byte{dhcpNak}),
+				dhcpOption(optServerID, d.ip),
+			}), nil
+		}
+
+		l := &lease{
+			mac:      p.chaddr,
+			ip:       ip.To4(),
+			clientID: formatClientID(p.options[optClientID]),
+			hostname: sanitizeHostname(string(p.options[optHostname])),
+		}
+
+		host := d.staticHost(p.chaddr)
+		if host != nil && host.name != "" {
+			l.hostname = host.name
+		}
+
+		if d.expiry != 0 {
+			l.expiry = now.Add(d.expiry)
+		}
+
+		s.leases[p.chaddr.String()] = l
+		err := s.writeLeases()
+		if err != nil {
+			return nil, err
+		}
+
+		return p.reply(ip, d.options(dhcpAck, true)), nil
+
+	case dhcpDecline:
+		ip := p.optionIP(optRequestedIP)
+		if ip == nil {
+			return nil, nil
+		}
+
+		d.declined[ipToUint32(ip)] = now
+		delete(s.leases, p.chaddr.String())
+		return nil, s.writeLeases()
+
+	case dhcpRelease:
+		l, ok := s.leases[p.chaddr.String()]
+		if !ok || !l.ip.Equal(p.ciaddr) {
+			return nil, nil
+		}
+
+		delete(s.leases, p.chaddr.String())
+		return nil, s.writeLeases()
+
+	case dhcpInform:
+		return p.reply(nil, d.options(dhcpAck, false)), nil
+	}
+
+	return nil, nil
+}
+
+// options returns the options of an offer or acknowledgement.
+func (d *dhcp4Server) options(messageType byte, withLease bool) [][]byte {
+	options := [][]byte{
+		dhcpOption(optMessageType, []byte{messageType}),
+		dhcpOption(optServerID, d.ip),
+	}
+
+	if withLease {
+		if d.expiry == 0 {
+			options = append(options, dhcpOptionUint32(optLeaseTime, 0xffffffff))
+		} else {
+			seconds := uint32(d.expiry / time.Second)
+			options = append(options,
+				dhcpOptionUint32(optLeaseTime, seconds),
+				dhcpOptionUint32(optRenewalTime, seconds/2),
+				dhcpOptionUint32(optRebindingTime, seconds/8*7))
+		}
+	}
+
+	options = append(options,
+		dhcpOption(optSubnetMask, d.subnet.Mask),
+		dhcpOption(optRouter, d.gateway),
+		dhcpOption(optDNSServer, d.ip))
+
+	if d.server.config.Domain != "" {
+		options = append(options, dhcpOption(optDomainName, []byte(d.server.config.Domain)))
+	}
+
+	return options
+}
+
+// formatClientID formats a client identifier the way dnsmasq records it in the lease file.
+func formatClientID(value []byte) string {
+	parts := make([]string, 0, len(value))
+	for _, b := range value {
+		parts = append(parts, fmt.Sprintf("%02x", b))
+	}
+
+	return strings.Join(parts, ":")
+}
+
+// sanitizeHostname returns the first label of a client provided host name, or an empty string if
+// it isn't a valid DNS label.
+func sanitizeHostname(name string) string {
+	name = strings.ToLower(strings.SplitN(strings.TrimRight(name, "\x00"), ".", 2)[0])
+	if name == "" || len(name) > 63 || strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-") {
+		return ""
+	}
+
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return ""
+		}
+	}
+
+	return name
+}
+
+// listen opens the DHCP socket on the interface of the network.
+func (d *dhcp4Server) listen() (net.PacketConn, error) {
+	iface := d.server.config.Interface
+	lc := net.ListenConfig{
+		Control: func(network string, address string, c syscall.RawConn) error {
+			var sockErr error
+			err := c.Control(func(fd uintptr) {
+				sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1)
+				if sockErr != nil {
+					return
+				}
+
+				sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_BROADCAST, 1)
+				if sockErr != nil {
+					return
+				}
+
+				sockErr = unix.BindToDevice(int(fd), iface)
+			})
+			if err != nil {
+				return err
+			}
+
+			return sockErr
+		},
+	}
+
+	return lc.ListenPacket(context.Background(), "udp4", "0.0.0.0:67")
+}
+
+// serve answers DHCP requests until the socket fails.
+func (d *dhcp4Server) serve(conn net.PacketConn) error {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		p, err := parseDHCPPacket(buf[:n])
+		if err != nil {
+			logger.Debug("Ignoring invalid DHCP packet", log.Ctx{"source": addr.String(), "err": err})
+			continue
+		}
+
+		reply, err := d.handle(p)
+		if err != nil {
+			logger.Error("Failed to handle DHCP request", log.Ctx{"mac": p.chaddr.String(), "err": err})
+		}
+
+		if reply == nil {
+			continue
+		}
+
+		// Clients which already have an address get a unicast reply, the others can't be
+		// reached before they are configured so the reply is broadcast on the network.
+		// Refusals are always broadcast. The message type is the first option of all replies.
+		dest := &net.UDPAddr{IP: net.IPv4bcast, Port: 68}
+		if !p.ciaddr.Equal(net.IPv4zero) && reply[242] != dhcpNak {
+			dest.IP = p.ciaddr
+		}
+
+		_, err = conn.WriteTo(reply, dest)
+		if err != nil {
+			logger.Error("Failed to send DHCP reply", log.Ctx{"mac": p.chaddr.String(), "err": err})
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package dhcpdns
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// request builds a DHCP request from the given client.
+func request(mac string, messageType byte, ciaddr string, options ...[]byte) *dhcpPacket {
+	hwaddr, _ := net.ParseMAC(mac)
+	p := &dhcpPacket{xid: []byte{1, 2, 3, 4}, chaddr: hwaddr, ciaddr: net.IPv4zero, giaddr: net.IPv4zero}
+	if ciaddr != "" {
+		p.ciaddr = net.ParseIP(ciaddr).To4()
+	}
+
+	data := p.reply(nil, append([][]byte{dhcpOption(optMessageType, []byte{messageType})}, options...))
+	data[0] = 1 // BOOTREQUEST
+
+	parsed, err := parseDHCPPacket(data)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
+
+func newTestServer(t *testing.T) (*dhcp4Server, func()) {
+	dir, err := ioutil.TempDir("", "lxd-dhcpdns-")
+	require.NoError(t, err)
+
+	err = os.MkdirAll(filepath.Join(dir, hostsDir), 0755)
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(dir, hostsDir, "c1"), []byte("00:16:3e:00:00:01,10.0.0.50,c1\n"), 0644)
+	require.NoError(t, err)
+
+	s := &Server{
+		config: Config{
+			Interface:   "lxdbr0",
+			Domain:      "lxd",
+			IPv4Address: "10.0.0.1/24",
+			IPv4DHCP:    true,
+			IPv4Ranges:  []string{"10.0.0.2-10.0.0.3"},
+		},
+		dir:    dir,
+		leases: map[string]*lease{},
+	}
+
+	s.hosts, err = loadStaticHosts(dir)
+	require.NoError(t, err)
+
+	s.dhcp4, err = newDHCP4Server(s)
+	require.NoError(t, err)
+
+	return s.dhcp4, func() { os.RemoveAll(dir) }
+}
+
+func TestDHCP4Allocation(t *testing.T) {
+	d, cleanup := newTestServer(t)
+	defer cleanup()
+
+	// Static allocations are always offered to their owner.
+	reply, err := d.handle(request("00:16:3e:00:00:01", dhcpDiscover, ""))
+	require.NoError(t, err)
+	offer, err := parseDHCPPacket(reply)
+	require.NoError(t, err)
+	assert.Equal(t, byte(dhcpOffer), offer.messageType())
+	assert.Equal(t, "10.0.0.50", offer.yiaddr.String())
+	assert.Equal(t, "10.0.0.1", offer.optionIP(optRouter).String())
+	assert.Equal(t, "255.255.255.0", net.IP(offer.options[optSubnetMask]).String())
+
+	// Dynamic clients get the first free address of the ranges, and keep it.
+	reply, err = d.handle(request("00:16:3e:00:00:02", dhcpRequest, "", dhcpOption(optRequestedIP, net.ParseIP("10.0.0.2").To4()), dhcpOption(optHostname, []byte("c2"))))
+	require.NoError(t, err)
+	ack, err := parseDHCPPacket(reply)
+	require.NoError(t, err)
+	assert.Equal(t, byte(dhcpAck), ack.messageType())
+	assert.Equal(t, "10.0.0.2", ack.yiaddr.String())
+
+	reply, err = d.handle(request("00:16:3e:00:00:03", dhcpDiscover, ""))
+	require.NoError(t, err)
+	offer, err = parseDHCPPacket(reply)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.3", offer.yiaddr.String())
+
+	// Leased and static addresses are refused to other clients.
+	for _, ip := range []string{"10.0.0.2", "10.0.0.50", "10.0.0.1", "10.0.0.4"} {
+		reply, err = d.handle(request("00:16:3e:00:00:03", dhcpRequest, "", dhcpOption(optRequestedIP, net.ParseIP(ip).To4())))
+		require.NoError(t, err)
+		nak, err := parseDHCPPacket(reply)
+		require.NoError(t, err)
+		assert.Equal(t, byte(dhcpNak), nak.messageType(), ip)
+	}
+
+	// The lease is written in the dnsmasq format.
+	leases, err := loadLeases(d.server.dir)
+	require.NoError(t, err)
+	require.Len(t, leases, 1)
+	assert.Equal(t, "10.0.0.2", leases["00:16:3e:00:00:02"].ip.String())
+	assert.Equal(t, "c2", leases["00:16:3e:00:00:02"].hostname)
+	assert.True(t, leases["00:16:3e:00:00:02"].expiry.After(time.Now().Add(59*time.Minute)))
+
+	// Releasing the address makes it available again.
+	_, err = d.handle(request("00:16:3e:00:00:02", dhcpRelease, "10.0.0.2"))
+	require.NoError(t, err)
+	assert.Len(t, d.server.leases, 0)
+
+	reply, err = d.handle(request("00:16:3e:00:00:03", dhcpDiscover, ""))
+	require.NoError(t, err)
+	offer, err = parseDHCPPacket(reply)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2", offer.yiaddr.String())
+}
+
+func TestParseExpiry(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":         time.Hour,
+		"infinite": 0,
+		"3600":     time.Hour,
+		"30m":      30 * time.Minute,
+		"2d":       48 * time.Hour,
+		"10s":      2 * time.Minute,
+	}
+
+	for value, expected := range cases {
+		expiry, err := ParseExpiry(value)
+		require.NoError(t, err)
+		assert.Equal(t, expected, expiry, value)
+	}
+
+	_, err := ParseExpiry("1y")
+	assert.Error(t, err)
+}
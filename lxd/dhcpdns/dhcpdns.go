@@ -0,0 +1,165 @@
+// Package dhcpdns implements the native DHCP and DNS server of LXD managed bridges, used instead of
+// dnsmasq when a network has dns.mode set to "managed-native".
+//
+// It serves DHCPv4, IPv6 router advertisements and DNS for a single network, using the same static
+// allocation and lease files as dnsmasq.
+package dhcpdns
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Server is the DHCP and DNS server of a network.
+type Server struct {
+	config Config
+	dir    string
+
+	mu     sync.Mutex
+	hosts  []staticHost
+	leases map[string]*lease
+
+	dhcp4 *dhcp4Server
+	ra    *raServer
+	dns   *dnsServer
+}
+
+// NewServer returns a new, not yet started, server for the network whose files are in dir.
+func NewServer(config Config, dir string) (*Server, error) {
+	s := &Server{
+		config: config,
+		dir:    dir,
+	}
+
+	var err error
+	s.leases, err = loadLeases(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load leases: %v", err)
+	}
+
+	if config.IPv4Address != "" && config.IPv4DHCP {
+		s.dhcp4, err = newDHCP4Server(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.IPv6Address != "" {
+		s.ra, err = newRAServer(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.dns, err = newDNSServer(s)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Reload reads the static allocations and the nameservers of the host again.
+func (s *Server) Reload() error {
+	hosts, err := loadStaticHosts(s.dir)
+	if err != nil {
+		return fmt.Errorf("Failed to load static allocations: %v", err)
+	}
+
+	s.mu.Lock()
+	s.hosts = hosts
+	s.mu.Unlock()
+
+	err = s.dns.loadUpstream()
+	if err != nil {
+		logger.Warn("Failed to load upstream nameservers", log.Ctx{"err": err})
+	}
+
+	return nil
+}
+
+// pruneLeases drops the expired leases and returns whether there were any. Must be called with
+// the lock held.
+func (s *Server) pruneLeases() bool {
+	pruned := false
+	now := time.Now()
+	for key, l := range s.leases {
+		if l.expired(now) {
+			delete(s.leases, key)
+			pruned = true
+		}
+	}
+
+	return pruned
+}
+
+// writeLeases drops the expired leases and writes the others to the lease file. Must be called
+// with the lock held.
+func (s *Server) writeLeases() error {
+	s.pruneLeases()
+	return writeLeases(s.dir, s.leases)
+}
+
+// Run starts all the services of the network and blocks until one of them fails.
+func (s *Server) Run() error {
+	errors := make(chan error, 8)
+
+	if s.dhcp4 != nil {
+		conn, err := s.dhcp4.listen()
+		if err != nil {
+			return fmt.Errorf("Failed to listen for DHCP requests: %v", err)
+		}
+
+		go func() {
+			errors <- fmt.Errorf("DHCP server failed: %v", s.dhcp4.serve(conn))
+		}()
+	}
+
+	if s.ra != nil {
+		err := s.ra.open()
+		if err != nil {
+			return fmt.Errorf("Failed to open router advertisement socket: %v", err)
+		}
+
+		go func() {
+			errors <- fmt.Errorf("Router advertisement failed: %v", s.ra.serve())
+		}()
+	}
+
+	for _, srv := range s.dns.listeners() {
+		go func(srv *dns.Server) {
+			errors <- fmt.Errorf("DNS server on %s/%s failed: %v", srv.Addr, srv.Net, srv.ListenAndServe())
+		}(srv)
+	}
+
+	// Expired leases are regularly removed from the lease file.
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+
+			var err error
+			s.mu.Lock()
+			if s.pruneLeases() {
+				err = writeLeases(s.dir, s.leases)
+			}
+			s.mu.Unlock()
+
+			if err != nil {
+				logger.Error("Failed to write leases", log.Ctx{"err": err})
+			}
+		}
+	}()
+
+	return <-errors
+}
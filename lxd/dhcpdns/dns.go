@@ -0,0 +1,359 @@
+package dhcpdns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/mdlayher/eui64"
+	"github.com/miekg/dns"
+
+	"github.com/lxc/lxd/shared/dnsutil"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// dnsServer answers the DNS queries of a network. Names in the domain of the network and reverse
+// names of its subnets are answered from the static allocations and the leases, everything else is
+// forwarded to the nameservers of the host.
+type dnsServer struct {
+	server *Server
+
+	domain   string
+	subnets  []*net.IPNet
+	ipv6     *net.IPNet
+	upstream []string
+
+	relay       string
+	relaySubnet *net.IPNet
+}
+
+func newDNSServer(s *Server) (*dnsServer, error) {
+	d := &dnsServer{
+		server: s,
+		domain: dns.Fqdn(strings.ToLower(s.config.Domain)),
+		relay:  s.config.DNSRelay,
+	}
+
+	for _, address := range []string{s.config.IPv4Address, s.config.IPv6Address} {
+		if address == "" {
+			continue
+		}
+
+		_, subnet, err := net.ParseCIDR(address)
+		if err != nil {
+			return nil, err
+		}
+
+		d.subnets = append(d.subnets, subnet)
+		if subnet.IP.To4() == nil && s.config.IPv6SLAACNames {
+			d.ipv6 = subnet
+		}
+	}
+
+	if s.config.DNSRelaySubnet != "" {
+		_, subnet, err := net.ParseCIDR(s.config.DNSRelaySubnet)
+		if err != nil {
+			return nil, err
+		}
+
+		d.relaySubnet = subnet
+		d.subnets = append(d.subnets, subnet)
+	}
+
+	return d, nil
+}
+
+// loadUpstream reads the nameservers of the host from /etc/resolv.conf.
+func (d *dnsServer) loadUpstream() error {
+	config, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil {
+		return err
+	}
+
+	d.server.mu.Lock()
+	defer d.server.mu.Unlock()
+
+	d.upstream = []string{}
+	for _, server := range config.Servers {
+		d.upstream = append(d.upstream, net.JoinHostPort(server, config.Port))
+	}
+
+	return nil
+}
+
+// listeners returns the DNS servers listening on the addresses of the network.
+func (d *dnsServer) listeners() []*dns.Server {
+	servers := []*dns.Server{}
+	for _, address := range []string{d.server.config.IPv4Address, d.server.config.IPv6Address} {
+		if address == "" {
+			continue
+		}
+
+		ip, _, err := net.ParseCIDR(address)
+		if err != nil {
+			continue
+		}
+
+		for _, protocol := range []string{"udp", "tcp"} {
+			servers = append(servers, &dns.Server{
+				Addr:    net.JoinHostPort(ip.String(), "53"),
+				Net:     protocol,
+				Handler: d,
+			})
+		}
+	}
+
+	return servers
+}
+
+// local returns whether the query must be answered by this server rather than the upstream
+// nameservers.
+func (d *dnsServer) local(name string) bool {
+	if d.domain != "." && strings.HasSuffix(name, "."+d.domain) {
+		return true
+	}
+
+	ip := net.ParseIP(dnsutil.ExtractAddressFromReverse(name))
+	if ip == nil {
+		return false
+	}
+
+	for _, subnet := range d.subnets {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookupName returns the addresses of a host of the network, and whether the host is known.
+func (d *dnsServer) lookupName(host string) ([]net.IP, []net.IP, bool) {
+	s := d.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ipv4s := []net.IP{}
+	ipv6s := []net.IP{}
+	found := false
+	macs := []net.HardwareAddr{}
+
+	for _, h := range s.hosts {
+		if h.name != host {
+			continue
+		}
+
+		found = true
+		if h.ipv4 != nil {
+			ipv4s = append(ipv4s, h.ipv4)
+		}
+
+		if h.ipv6 != nil {
+			ipv6s = append(ipv6s, h.ipv6)
+		}
+
+		macs = append(macs, h.mac)
+	}
+
+	now := time.Now()
+	for _, l := range s.leases {
+		if l.hostname != host || l.expired(now) {
+			continue
+		}
+
+		found = true
+		if len(ipv4s) == 0 {
+			ipv4s = append(ipv4s, l.ip)
+		}
+
+		macs = append(macs, l.mac)
+	}
+
+	// Derive the SLAAC address from the MAC address when there's no static IPv6 address.
+	if d.ipv6 != nil && len(ipv6s) == 0 && len(macs) > 0 {
+		ip, err := eui64.ParseMAC(d.ipv6.IP, macs[0])
+		if err == nil {
+			ipv6s = append(ipv6s, ip)
+		}
+	}
+
+	return ipv4s, ipv6s, found
+}
+
+// lookupAddress returns the name of the host with the given address.
+func (d *dnsServer) lookupAddress(ip net.IP) string {
+	s := d.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, h := range s.hosts {
+		if h.name == "" {
+			continue
+		}
+
+		if ip.Equal(h.ipv4) || ip.Equal(h.ipv6) {
+			return h.name
+		}
+
+		if d.ipv6 != nil && h.ipv6 == nil {
+			slaac, err := eui64.ParseMAC(d.ipv6.IP, h.mac)
+			if err == nil && ip.Equal(slaac) {
+				return h.name
+			}
+		}
+	}
+
+	now := time.Now()
+	for _, l := range s.leases {
+		if l.hostname == "" || l.expired(now) {
+			continue
+		}
+
+		if ip.Equal(l.ip) {
+			return l.hostname
+		}
+
+		if d.ipv6 != nil {
+			slaac, err := eui64.ParseMAC(d.ipv6.IP, l.mac)
+			if err == nil && ip.Equal(slaac) {
+				return l.hostname
+			}
+		}
+	}
+
+	return ""
+}
+
+// answer builds the authoritative answer for a local name. The returned boolean is false if the
+// name isn't known, in which case the question may be relayed to the other cluster members.
+func (d *dnsServer) answer(r *dns.Msg) (*dns.Msg, bool) {
+	q := r.Question[0]
+	name := strings.ToLower(q.Name)
+	header := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: 0}
+
+	msg := &dns.Msg{}
+	msg.SetReply(r)
+	msg.Authoritative = true
+
+	if dnsutil.IsReverse(name) > 0 {
+		host := d.lookupAddress(net.ParseIP(dnsutil.ExtractAddressFromReverse(name)))
+		if host == "" {
+			msg.SetRcode(r, dns.RcodeNameError)
+			return msg, false
+		}
+
+		if q.Qtype == dns.TypePTR || q.Qtype == dns.TypeANY {
+			msg.Answer = append(msg.Answer, &dns.PTR{Hdr: header, Ptr: fmt.Sprintf("%s.%s", host, d.domain)})
+		}
+
+		return msg, true
+	}
+
+	host := strings.TrimSuffix(name, "."+d.domain)
+	if strings.Contains(host, ".") {
+		msg.SetRcode(r, dns.RcodeNameError)
+		return msg, false
+	}
+
+	ipv4s, ipv6s, found := d.lookupName(host)
+	if !found {
+		msg.SetRcode(r, dns.RcodeNameError)
+		return msg, false
+	}
+
+	if q.Qtype == dns.TypeA || q.Qtype == dns.TypeANY {
+		for _, ip := range ipv4s {
+			hdr := header
+			hdr.Rrtype = dns.TypeA
+			msg.Answer = append(msg.Answer, &dns.A{Hdr: hdr, A: ip})
+		}
+	}
+
+	if q.Qtype == dns.TypeAAAA || q.Qtype == dns.TypeANY {
+		for _, ip := range ipv6s {
+			hdr := header
+			hdr.Rrtype = dns.TypeAAAA
+			msg.Answer = append(msg.Answer, &dns.AAAA{Hdr: hdr, AAAA: ip})
+		}
+	}
+
+	return msg, true
+}
+
+// exchange sends the query to the first of the servers which answers it.
+func (d *dnsServer) exchange(r *dns.Msg, protocol string, servers []string) (*dns.Msg, error) {
+	client := &dns.Client{Net: protocol, Timeout: 5 * time.Second}
+
+	var err error
+	for _, server := range servers {
+		var resp *dns.Msg
+		resp, _, err = client.Exchange(r, server)
+		if err == nil {
+			return resp, nil
+		}
+	}
+
+	if err == nil {
+		err = fmt.Errorf("No nameserver available")
+	}
+
+	return nil, err
+}
+
+// ServeDNS handles each DNS request.
+func (d *dnsServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	protocol := "udp"
+	_, ok := w.RemoteAddr().(*net.TCPAddr)
+	if ok {
+		protocol = "tcp"
+	}
+
+	var msg *dns.Msg
+	if len(r.Question) != 1 {
+		msg = &dns.Msg{}
+		msg.SetRcode(r, dns.RcodeFormatError)
+	} else if d.local(strings.ToLower(r.Question[0].Name)) {
+		var found bool
+		msg, found = d.answer(r)
+
+		// Ask the other cluster members about the names they may know.
+		qtype := r.Question[0].Qtype
+		if !found && d.relay != "" && (qtype == dns.TypeA || qtype == dns.TypePTR) {
+			relayed := true
+			if qtype == dns.TypePTR {
+				ip := net.ParseIP(dnsutil.ExtractAddressFromReverse(strings.ToLower(r.Question[0].Name)))
+				relayed = d.relaySubnet != nil && d.relaySubnet.Contains(ip)
+			}
+
+			if relayed {
+				req := r.Copy()
+				req.RecursionDesired = true
+				resp, err := d.exchange(req, "udp", []string{d.relay})
+				if err == nil {
+					msg = resp
+				}
+			}
+		}
+	} else {
+		d.server.mu.Lock()
+		upstream := d.upstream
+		d.server.mu.Unlock()
+
+		resp, err := d.exchange(r, protocol, upstream)
+		if err != nil {
+			logger.Debug("Failed forwarding DNS query", log.Ctx{"name": r.Question[0].Name, "err": err})
+			msg = &dns.Msg{}
+			msg.SetRcode(r, dns.RcodeServerFailure)
+		} else {
+			msg = resp
+		}
+	}
+
+	msg.Id = r.Id
+	err := w.WriteMsg(msg)
+	if err != nil {
+		logger.Error("Failed sending DNS response", log.Ctx{"err": err})
+	}
+}
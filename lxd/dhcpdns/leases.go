@@ -0,0 +1,194 @@
+package dhcpdns
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The native server uses the same files as dnsmasq for the static allocations and the leases, so
+// that the rest of LXD (and the leases API) doesn't need to know which server runs on a network.
+const (
+	hostsDir   = "dnsmasq.hosts"
+	leasesFile = "dnsmasq.leases"
+)
+
+// staticHost is a static allocation from the hosts directory.
+type staticHost struct {
+	mac  net.HardwareAddr
+	ipv4 net.IP
+	ipv6 net.IP
+	name string
+}
+
+// lease is a dynamic IPv4 allocation. A zero expiry means the lease never expires.
+type lease struct {
+	expiry   time.Time
+	mac      net.HardwareAddr
+	ip       net.IP
+	hostname string
+	clientID string
+}
+
+func (l *lease) expired(now time.Time) bool {
+	return !l.expiry.IsZero() && now.After(l.expiry)
+}
+
+// loadStaticHosts parses the "mac,ipv4,[ipv6],name" lines of the files in the hosts directory.
+func loadStaticHosts(dir string) ([]staticHost, error) {
+	files, err := ioutil.ReadDir(filepath.Join(dir, hostsDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	hosts := []staticHost{}
+	for _, entry := range files {
+		content, err := ioutil.ReadFile(filepath.Join(dir, hostsDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range strings.Split(string(content), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+
+			host := staticHost{}
+			for _, field := range strings.Split(line, ",") {
+				if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+					host.ipv6 = net.ParseIP(field[1 : len(field)-1])
+					if host.ipv6 == nil {
+						return nil, fmt.Errorf("Invalid IPv6 address %q in %q", field, entry.Name())
+					}
+				} else if strings.Count(field, ".") == 3 {
+					host.ipv4 = net.ParseIP(field).To4()
+					if host.ipv4 == nil {
+						return nil, fmt.Errorf("Invalid IPv4 address %q in %q", field, entry.Name())
+					}
+				} else if mac, err := net.ParseMAC(field); err == nil && host.mac == nil {
+					host.mac = mac
+				} else {
+					host.name = field
+				}
+			}
+
+			if host.mac == nil {
+				return nil, fmt.Errorf("Missing MAC address in %q", entry.Name())
+			}
+
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts, nil
+}
+
+// loadLeases parses the IPv4 leases from a lease file in the dnsmasq format
+// ("expiry mac ip hostname clientid"), keyed by MAC address.
+func loadLeases(dir string) (map[string]*lease, error) {
+	leases := map[string]*lease{}
+
+	file, err := os.Open(filepath.Join(dir, leasesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return leases, nil
+		}
+
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 5 {
+			continue
+		}
+
+		ip := net.ParseIP(fields[2]).To4()
+		mac, err := net.ParseMAC(fields[1])
+		if ip == nil || err != nil {
+			continue
+		}
+
+		l := &lease{mac: mac, ip: ip}
+
+		expiry, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if expiry != 0 {
+			l.expiry = time.Unix(expiry, 0)
+		}
+
+		if fields[3] != "*" {
+			l.hostname = fields[3]
+		}
+
+		if fields[4] != "*" {
+			l.clientID = fields[4]
+		}
+
+		leases[mac.String()] = l
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return leases, nil
+}
+
+// writeLeases atomically replaces the lease file with the given leases, ordered by address.
+func writeLeases(dir string, leases map[string]*lease) error {
+	sorted := make([]*lease, 0, len(leases))
+	for _, l := range leases {
+		sorted = append(sorted, l)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return ipToUint32(sorted[i].ip) < ipToUint32(sorted[j].ip)
+	})
+
+	content := ""
+	for _, l := range sorted {
+		expiry := int64(0)
+		if !l.expiry.IsZero() {
+			expiry = l.expiry.Unix()
+		}
+
+		hostname := l.hostname
+		if hostname == "" {
+			hostname = "*"
+		}
+
+		clientID := l.clientID
+		if clientID == "" {
+			clientID = "*"
+		}
+
+		content += fmt.Sprintf("%d %s %s %s %s\n", expiry, l.mac.String(), l.ip.String(), hostname, clientID)
+	}
+
+	path := filepath.Join(dir, leasesFile)
+	err := ioutil.WriteFile(path+".tmp", []byte(content), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,269 @@
+package dhcpdns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// ICMPv6 message types and router advertisement options (RFC 4861, RFC 8106).
+const (
+	icmpRouterSolicitation  = 133
+	icmpRouterAdvertisement = 134
+
+	raOptSourceLinkLayer = 1
+	raOptPrefixInfo      = 3
+	raOptMTU             = 5
+	raOptRDNSS           = 25
+	raOptDNSSL           = 31
+)
+
+// Unsolicited advertisements are sent a few times quickly after startup and then at a slower
+// pace, with lifetimes covering three intervals.
+const (
+	raInitialInterval   = 16 * time.Second
+	raInitialCount      = 3
+	raInterval          = 200 * time.Second
+	raRouterLifetime    = 1800
+	raValidLifetime     = 86400
+	raPreferredLifetime = 14400
+	raDNSLifetime       = 3 * 200
+)
+
+// raServer sends router advertisements for the IPv6 subnet of a network.
+type raServer struct {
+	server *Server
+
+	fd     int
+	iface  *net.Interface
+	packet []byte
+
+	mu       sync.Mutex
+	lastSent time.Time
+}
+
+func newRAServer(s *Server) (*raServer, error) {
+	ip, subnet, err := net.ParseCIDR(s.config.IPv6Address)
+	if err != nil {
+		return nil, err
+	}
+
+	iface, err := net.InterfaceByName(s.config.Interface)
+	if err != nil {
+		return nil, err
+	}
+
+	dnsServers := []net.IP{ip}
+	if len(s.config.IPv6RADNS) > 0 {
+		dnsServers = []net.IP{}
+		for _, server := range s.config.IPv6RADNS {
+			serverIP := net.ParseIP(strings.TrimSpace(server))
+			if serverIP == nil {
+				return nil, fmt.Errorf("Invalid DNS server %q", server)
+			}
+
+			dnsServers = append(dnsServers, serverIP)
+		}
+	}
+
+	r := &raServer{
+		server: s,
+		fd:     -1,
+		iface:  iface,
+	}
+
+	r.packet = r.advertisement(subnet, dnsServers)
+	return r, nil
+}
+
+// advertisement builds the router advertisement sent on the network.
+func (r *raServer) advertisement(subnet *net.IPNet, dnsServers []net.IP) []byte {
+	config := r.server.config
+
+	routerLifetime := uint16(raRouterLifetime)
+	if !config.IPv6RADefaultRoute {
+		routerLifetime = 0
+	}
+
+	packet := make([]byte, 16)
+	packet[0] = icmpRouterAdvertisement
+	packet[4] = 64 // Current hop limit
+	binary.BigEndian.PutUint16(packet[6:8], routerLifetime)
+
+	// Source link-layer address.
+	if len(r.iface.HardwareAddr) == 6 {
+		packet = append(packet, raOptSourceLinkLayer, 1)
+		packet = append(packet, r.iface.HardwareAddr...)
+	}
+
+	// MTU.
+	if config.IPv6RAMTU > 0 {
+		option := make([]byte, 8)
+		option[0] = raOptMTU
+		option[1] = 1
+		binary.BigEndian.PutUint32(option[4:8], config.IPv6RAMTU)
+		packet = append(packet, option...)
+	}
+
+	// Prefix information, only usable for autoconfiguration on /64 subnets.
+	prefixLength, _ := subnet.Mask.Size()
+	option := make([]byte, 32)
+	option[0] = raOptPrefixInfo
+	option[1] = 4
+	option[2] = byte(prefixLength)
+	option[3] = 0x80 // On-link
+	if prefixLength == 64 {
+		option[3] |= 0x40 // Autonomous address configuration
+	}
+
+	binary.BigEndian.PutUint32(option[4:8], raValidLifetime)
+	binary.BigEndian.PutUint32(option[8:12], raPreferredLifetime)
+	copy(option[16:32], subnet.IP.To16())
+	packet = append(packet, option...)
+
+	// Recursive DNS servers.
+	option = make([]byte, 8)
+	option[0] = raOptRDNSS
+	option[1] = byte(1 + 2*len(dnsServers))
+	binary.BigEndian.PutUint32(option[4:8], raDNSLifetime)
+	for _, server := range dnsServers {
+		option = append(option, server.To16()...)
+	}
+
+	packet = append(packet, option...)
+
+	// DNS search list.
+	if config.Domain != "" {
+		names := []byte{}
+		for _, label := range strings.Split(strings.Trim(config.Domain, "."), ".") {
+			names = append(names, byte(len(label)))
+			names = append(names, label...)
+		}
+
+		names = append(names, 0)
+		for (8+len(names))%8 != 0 {
+			names = append(names, 0)
+		}
+
+		option = make([]byte, 8)
+		option[0] = raOptDNSSL
+		option[1] = byte((8 + len(names)) / 8)
+		binary.BigEndian.PutUint32(option[4:8], raDNSLifetime)
+		packet = append(packet, append(option, names...)...)
+	}
+
+	return packet
+}
+
+// open creates the raw ICMPv6 socket used to send advertisements and receive solicitations. The
+// kernel computes the ICMPv6 checksum and picks the link-local source address.
+func (r *raServer) open() error {
+	fd, err := unix.Socket(unix.AF_INET6, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.IPPROTO_ICMPV6)
+	if err != nil {
+		return err
+	}
+
+	setup := func() error {
+		err := unix.BindToDevice(fd, r.iface.Name)
+		if err != nil {
+			return err
+		}
+
+		err = unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_MULTICAST_HOPS, 255)
+		if err != nil {
+			return err
+		}
+
+		err = unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_UNICAST_HOPS, 255)
+		if err != nil {
+			return err
+		}
+
+		err = unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_MULTICAST_IF, r.iface.Index)
+		if err != nil {
+			return err
+		}
+
+		// Join the all-routers group to receive the router solicitations.
+		mreq := &unix.IPv6Mreq{Interface: uint32(r.iface.Index)}
+		copy(mreq.Multiaddr[:], net.ParseIP("ff02::2").To16())
+		return unix.SetsockoptIPv6Mreq(fd, unix.IPPROTO_IPV6, unix.IPV6_JOIN_GROUP, mreq)
+	}
+
+	err = setup()
+	if err != nil {
+		unix.Close(fd)
+		return err
+	}
+
+	r.fd = fd
+	return nil
+}
+
+// send multicasts the advertisement to all nodes, unless one was sent very recently.
+func (r *raServer) send() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastSent) < 3*time.Second {
+		return nil
+	}
+
+	dest := &unix.SockaddrInet6{ZoneId: uint32(r.iface.Index)}
+	copy(dest.Addr[:], net.IPv6linklocalallnodes.To16())
+
+	err := unix.Sendto(r.fd, r.packet, 0, dest)
+	if err != nil {
+		return err
+	}
+
+	r.lastSent = time.Now()
+	return nil
+}
+
+// serve sends periodic advertisements and answers solicitations until the socket fails.
+func (r *raServer) serve() error {
+	go func() {
+		for i := 0; ; i++ {
+			err := r.send()
+			if err != nil {
+				logger.Error("Failed to send router advertisement", log.Ctx{"interface": r.iface.Name, "err": err})
+			}
+
+			if i < raInitialCount {
+				time.Sleep(raInitialInterval)
+			} else {
+				time.Sleep(raInterval)
+			}
+		}
+	}()
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := unix.Recvfrom(r.fd, buf, 0)
+		if err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+
+			return err
+		}
+
+		if n < 8 || buf[0] != icmpRouterSolicitation {
+			continue
+		}
+
+		err = r.send()
+		if err != nil {
+			logger.Error("Failed to send router advertisement", log.Ctx{"interface": r.iface.Name, "err": err})
+		}
+	}
+}
@@ -2,6 +2,7 @@ package dnsmasq
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -43,7 +44,7 @@ func UpdateStaticEntry(network string, projectName string, instanceName string,
 		line += fmt.Sprintf(",[%s]", ipv6Address)
 	}
 
-	if netConfig["dns.mode"] == "" || netConfig["dns.mode"] == "managed" {
+	if shared.StringInSlice(netConfig["dns.mode"], []string{"", "managed", "managed-native"}) {
 		line += fmt.Sprintf(",%s", instanceName)
 	}
 
@@ -69,7 +70,7 @@ func RemoveStaticEntry(network string, projectName string, instanceName string)
 	return nil
 }
 
-// Kill kills dnsmasq for a particular network (or optionally reloads it).
+// Kill kills dnsmasq (or the native server) for a particular network (or optionally reloads it).
 func Kill(name string, reload bool) error {
 	// Check if we have a running dnsmasq at all
 	pidPath := shared.VarPath("networks", name, "dnsmasq.pid")
@@ -118,7 +119,7 @@ func Kill(name string, reload bool) error {
 
 	// Deal with deleted paths
 	cmdName := filepath.Base(strings.Split(cmdPath, " ")[0])
-	if cmdName != "dnsmasq" {
+	if cmdName != "dnsmasq" && !isNativeServer(pid) {
 		if reload {
 			return fmt.Errorf("dnsmasq isn't running")
 		}
@@ -153,6 +154,18 @@ func Kill(name string, reload bool) error {
 	return nil
 }
 
+// isNativeServer returns whether the process is the native DHCP and DNS server of LXD, which is
+// used instead of dnsmasq with dns.mode set to "managed-native" and shares its files.
+func isNativeServer(pid string) bool {
+	cmdArgs, err := ioutil.ReadFile(fmt.Sprintf("/proc/%s/cmdline", pid))
+	if err != nil {
+		return false
+	}
+
+	cmdFields := strings.Split(string(bytes.TrimRight(cmdArgs, string("\x00"))), string(byte(0)))
+	return len(cmdFields) >= 4 && cmdFields[1] == "forkdhcpdns"
+}
+
 // GetVersion returns the version of dnsmasq.
 func GetVersion() (*version.DottedVersion, error) {
 	output, err := shared.RunCommandCLocale("dnsmasq", "--version")
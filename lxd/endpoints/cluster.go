@@ -27,7 +27,7 @@ func (e *Endpoints) ClusterAddress() string {
 // ClusterUpdateAddress updates the address for the cluster endpoint, shutting
 // it down and restarting it.
 func (e *Endpoints) ClusterUpdateAddress(address string) error {
-	networkAddress := e.NetworkAddress()
+	networkAddresses := e.NetworkAddresses()
 
 	if address != "" {
 		address = util.CanonicalNetworkAddress(address)
@@ -47,7 +47,7 @@ func (e *Endpoints) ClusterUpdateAddress(address string) error {
 	e.closeListener(cluster)
 
 	// If turning off listening, we're done
-	if address == "" || util.IsAddressCovered(address, networkAddress) {
+	if address == "" || networkAddressCovered(address, networkAddresses) {
 		return nil
 	}
 
@@ -1,9 +1,11 @@
 package endpoints
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
+	"path/filepath"
 	"sync"
 
 	"github.com/lxc/lxd/lxd/util"
@@ -42,7 +44,8 @@ type Config struct {
 
 	// NetworkSetAddress sets the address for the network endpoint. If not
 	// set, the network endpoint won't be started (unless it's passed via
-	// socket-based activation).
+	// socket-based activation). It can be a comma separated list of
+	// addresses, in which case the endpoint listens on all of them.
 	//
 	// It can be updated after the endpoints are up using UpdateNetworkAddress().
 	NetworkAddress string
@@ -142,8 +145,12 @@ type Endpoints struct {
 	listeners map[kind]net.Listener // Activer listeners by endpoint type.
 	servers   map[kind]*http.Server // HTTP servers by endpoint type.
 	cert      *shared.CertInfo      // Keypair and CA to use for TLS.
+	sniCerts  []tls.Certificate     // Additional certificates selected through SNI.
 	inherited map[kind]bool         // Store whether the listener came through socket activation
 
+	proxyMu        sync.RWMutex // Serialize access to the trusted proxies.
+	trustedProxies []*net.IPNet // Reverse proxies allowed to relay the client address.
+
 	systemdListenFDsStart int // First socket activation FD, for tests.
 }
 
@@ -164,6 +171,16 @@ func (e *Endpoints) up(config *Config) error {
 
 	var err error
 
+	// Load the additional certificates of the network endpoint, ignoring
+	// broken ones so they don't prevent LXD from starting.
+	sniDir := filepath.Join(config.Dir, "server-certs")
+	if shared.PathExists(sniDir) {
+		e.sniCerts, err = networkLoadSNICerts(sniDir)
+		if err != nil {
+			logger.Error("Failed to load SNI certificates", log.Ctx{"err": err})
+		}
+	}
+
 	// Check for socket activation.
 	systemdListeners := util.GetListeners(e.systemdListenFDsStart)
 	if len(systemdListeners) > 0 {
@@ -171,6 +188,11 @@ func (e *Endpoints) up(config *Config) error {
 		for kind := range e.listeners {
 			e.inherited[kind] = true
 		}
+
+		listener, ok := e.listeners[network].(*networkListener)
+		if ok {
+			listener.trustedProxy = e.networkIsTrustedProxy
+		}
 	} else {
 		e.listeners = map[kind]net.Listener{}
 
@@ -195,9 +217,9 @@ func (e *Endpoints) up(config *Config) error {
 		}
 
 		// Errors here are not fatal and are just logged.
-		e.listeners[network] = networkCreateListener(config.NetworkAddress, e.cert)
+		e.listeners[network] = e.networkCreateListener(config.NetworkAddress)
 
-		isCovered := util.IsAddressCovered(config.ClusterAddress, config.NetworkAddress)
+		isCovered := networkAddressCovered(config.ClusterAddress, networkSplitAddresses(config.NetworkAddress))
 		if config.ClusterAddress != "" && !isCovered {
 			e.listeners[cluster], err = clusterCreateListener(config.ClusterAddress, e.cert)
 			if err != nil {
@@ -4,9 +4,13 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	log "github.com/lxc/lxd/shared/log15"
@@ -40,7 +44,8 @@ func (e *Endpoints) NetworkCert() *shared.CertInfo {
 }
 
 // NetworkAddress returns the network addresss of the network endpoint, or an
-// empty string if there's no network endpoint
+// empty string if there's no network endpoint. If the endpoint listens on
+// several addresses, that's the first one.
 func (e *Endpoints) NetworkAddress() string {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
@@ -52,15 +57,39 @@ func (e *Endpoints) NetworkAddress() string {
 	return listener.Addr().String()
 }
 
+// NetworkAddresses returns all the addresses the network endpoint listens on.
+func (e *Endpoints) NetworkAddresses() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.networkAddresses()
+}
+
+func (e *Endpoints) networkAddresses() []string {
+	listener := e.listeners[network]
+	if listener == nil {
+		return []string{}
+	}
+
+	tlsListener, ok := listener.(*networkListener)
+	if ok {
+		multi, ok := tlsListener.Listener.(*networkMultiListener)
+		if ok {
+			return multi.Addrs()
+		}
+	}
+
+	return []string{listener.Addr().String()}
+}
+
 // NetworkUpdateAddress updates the address for the network endpoint, shutting
-// it down and restarting it.
+// it down and restarting it. The address can be a comma separated list of
+// addresses to listen on.
 func (e *Endpoints) NetworkUpdateAddress(address string) error {
-	if address != "" {
-		address = util.CanonicalNetworkAddress(address)
-	}
+	addresses := networkSplitAddresses(address)
 
-	oldAddress := e.NetworkAddress()
-	if address == oldAddress {
+	oldAddresses := e.NetworkAddresses()
+	if strings.Join(addresses, ",") == strings.Join(oldAddresses, ",") {
 		return nil
 	}
 
@@ -71,17 +100,17 @@ func (e *Endpoints) NetworkUpdateAddress(address string) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	// Close the previous socket
+	// Close the previous sockets
 	e.closeListener(network)
 
 	// If turning off listening, we're done.
-	if address == "" {
+	if len(addresses) == 0 {
 		return nil
 	}
 
-	// If the new address covers the cluster one, turn off the cluster
-	// listener.
-	if clusterAddress != "" && util.IsAddressCovered(clusterAddress, address) {
+	// If one of the new addresses covers the cluster one, turn off the
+	// cluster listener.
+	if clusterAddress != "" && networkAddressCovered(clusterAddress, addresses) {
 		e.closeListener(cluster)
 	}
 
@@ -106,24 +135,43 @@ func (e *Endpoints) NetworkUpdateAddress(address string) error {
 		return &listener, nil
 	}
 
-	// If setting a new address, setup the listener
-	if address != "" {
-		listener, err := getListener(address)
-		if err != nil {
-			// Attempt to revert to the previous address
-			listener, err1 := getListener(oldAddress)
+	getListeners := func(addresses []string) (*net.Listener, error) {
+		listeners := []net.Listener{}
+		for _, address := range addresses {
+			listener, err := getListener(address)
+			if err != nil {
+				for _, listener := range listeners {
+					listener.Close()
+				}
+
+				return nil, err
+			}
+
+			listeners = append(listeners, *listener)
+		}
+
+		listener := networkMultiListen(listeners)
+		return &listener, nil
+	}
+
+	// Setup the listeners for the new addresses
+	listener, err := getListeners(addresses)
+	if err != nil {
+		// Attempt to revert to the previous addresses
+		if len(oldAddresses) > 0 {
+			listener, err1 := getListeners(oldAddresses)
 			if err1 == nil {
-				e.listeners[network] = networkTLSListener(*listener, e.cert)
+				e.listeners[network] = e.networkTLSListener(*listener)
 				e.serveHTTP(network)
 			}
-
-			return err
 		}
 
-		e.listeners[network] = networkTLSListener(*listener, e.cert)
-		e.serveHTTP(network)
+		return err
 	}
 
+	e.listeners[network] = e.networkTLSListener(*listener)
+	e.serveHTTP(network)
+
 	return nil
 }
 
@@ -150,14 +198,88 @@ func (e *Endpoints) NetworkUpdateCert(cert *shared.CertInfo) {
 	listener.(*networkListener).Config(cert)
 }
 
-// Create a new net.Listener bound to the tcp socket of the network endpoint.
-func networkCreateListener(address string, cert *shared.CertInfo) net.Listener {
-	listener, err := net.Listen("tcp", util.CanonicalNetworkAddress(address))
-	if err != nil {
-		logger.Error("Cannot listen on https socket, skipping...", log.Ctx{"err": err})
+// Create a new net.Listener bound to the tcp sockets of the network endpoint.
+func (e *Endpoints) networkCreateListener(address string) net.Listener {
+	listeners := []net.Listener{}
+	for _, address := range networkSplitAddresses(address) {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			logger.Error("Cannot listen on https socket, skipping...", log.Ctx{"address": address, "err": err})
+			continue
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	if len(listeners) == 0 {
 		return nil
 	}
-	return networkTLSListener(listener, cert)
+
+	return e.networkTLSListener(networkMultiListen(listeners))
+}
+
+// Wrap the given listener with TLS, using the endpoint certificate and any
+// additional certificate to be selected through SNI.
+func (e *Endpoints) networkTLSListener(inner net.Listener) *networkListener {
+	listener := &networkListener{
+		Listener:     inner,
+		sniCerts:     e.sniCerts,
+		trustedProxy: e.networkIsTrustedProxy,
+	}
+	listener.Config(e.cert)
+	return listener
+}
+
+// networkSplitAddresses returns the canonical form of each of the addresses in
+// the given comma separated list.
+func networkSplitAddresses(value string) []string {
+	addresses := []string{}
+	for _, address := range strings.Split(value, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+
+		addresses = append(addresses, util.CanonicalNetworkAddress(address))
+	}
+
+	return addresses
+}
+
+// networkAddressCovered returns whether the given address is covered by one of
+// the given listen addresses.
+func networkAddressCovered(address string, addresses []string) bool {
+	for _, listenAddress := range addresses {
+		if util.IsAddressCovered(address, listenAddress) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// networkLoadSNICerts loads the additional server certificates found in the
+// given directory as <name>.crt and <name>.key pairs. The TLS listener of the
+// network endpoint serves them to the clients requesting one of the names
+// they're valid for through SNI.
+func networkLoadSNICerts(dir string) ([]tls.Certificate, error) {
+	certs := []tls.Certificate{}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.crt"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		cert, err := tls.LoadX509KeyPair(path, strings.TrimSuffix(path, ".crt")+".key")
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to load SNI certificate %q", path)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
 }
 
 // A variation of the standard tls.Listener that supports atomically swapping
@@ -165,8 +287,12 @@ func networkCreateListener(address string, cert *shared.CertInfo) net.Listener {
 // continue using the old configuration.
 type networkListener struct {
 	net.Listener
-	mu     sync.RWMutex
-	config *tls.Config
+	mu       sync.RWMutex
+	config   *tls.Config
+	sniCerts []tls.Certificate
+
+	// Connections from trusted proxies may start with a PROXY protocol header.
+	trustedProxy func(net.IP) bool
 }
 
 func networkTLSListener(inner net.Listener, cert *shared.CertInfo) *networkListener {
@@ -184,6 +310,14 @@ func (l *networkListener) Accept() (net.Conn, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	if l.trustedProxy != nil {
+		addr, ok := c.RemoteAddr().(*net.TCPAddr)
+		if ok && l.trustedProxy(addr.IP) {
+			c = newProxyProtocolConn(c)
+		}
+	}
+
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 	config := l.config
@@ -194,8 +328,117 @@ func (l *networkListener) Accept() (net.Conn, error) {
 func (l *networkListener) Config(cert *shared.CertInfo) {
 	config := util.ServerTLSConfig(cert)
 
+	// The main certificate remains first, so that it's used for the clients
+	// not requesting one of the names covered by the SNI certificates.
+	if len(l.sniCerts) > 0 {
+		config.Certificates = append(config.Certificates, l.sniCerts...)
+		config.BuildNameToCertificate()
+	}
+
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
 	l.config = config
 }
+
+// networkMultiListener accepts the connections of several listeners, used
+// when the network endpoint listens on more than one address. Its address is
+// the one of the first listener.
+type networkMultiListener struct {
+	listeners []net.Listener
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// networkMultiListen returns a listener accepting the connections of all the
+// given listeners, or the only one of them.
+func networkMultiListen(listeners []net.Listener) net.Listener {
+	if len(listeners) == 1 {
+		return listeners[0]
+	}
+
+	multi := &networkMultiListener{
+		listeners: listeners,
+		conns:     make(chan net.Conn),
+		closed:    make(chan struct{}),
+	}
+
+	for _, listener := range listeners {
+		go multi.accept(listener)
+	}
+
+	return multi
+}
+
+// accept forwards the connections of the given listener until it fails.
+func (l *networkMultiListener) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			netErr, ok := err.(net.Error)
+			if ok && netErr.Temporary() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+
+			select {
+			case <-l.closed:
+			default:
+				logger.Error("Stopped accepting connections", log.Ctx{"socket": listener.Addr(), "err": err})
+			}
+
+			return
+		}
+
+		select {
+		case l.conns <- conn:
+		case <-l.closed:
+			conn.Close()
+			return
+		}
+	}
+}
+
+// Accept waits for and returns the next connection of any of the listeners.
+func (l *networkMultiListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, fmt.Errorf("Listener closed")
+	}
+}
+
+// Close closes all the listeners.
+func (l *networkMultiListener) Close() error {
+	var err error
+
+	l.closeOnce.Do(func() {
+		close(l.closed)
+
+		for _, listener := range l.listeners {
+			closeErr := listener.Close()
+			if closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+	})
+
+	return err
+}
+
+// Addr returns the address of the first listener.
+func (l *networkMultiListener) Addr() net.Addr {
+	return l.listeners[0].Addr()
+}
+
+// Addrs returns the addresses of all the listeners.
+func (l *networkMultiListener) Addrs() []string {
+	addresses := make([]string, len(l.listeners))
+	for i, listener := range l.listeners {
+		addresses[i] = listener.Addr().String()
+	}
+
+	return addresses
+}
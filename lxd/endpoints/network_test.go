@@ -21,6 +21,23 @@ func TestEndpoints_NetworkCreateTCPSocket(t *testing.T) {
 	assert.NoError(t, httpGetOverTLSSocket(endpoints.NetworkAddressAndCert()))
 }
 
+// The network endpoint can listen on several addresses.
+func TestEndpoints_NetworkCreateTCPSocketMultipleAddresses(t *testing.T) {
+	endpoints, config, cleanup := newEndpoints(t)
+	defer cleanup()
+
+	config.NetworkAddress = "127.0.0.1:0,127.0.0.1:0"
+	require.NoError(t, endpoints.Up(config))
+
+	addresses := endpoints.NetworkAddresses()
+	require.Len(t, addresses, 2)
+	assert.Equal(t, addresses[0], endpoints.NetworkAddress())
+
+	for _, address := range addresses {
+		assert.NoError(t, httpGetOverTLSSocket(address, config.Cert))
+	}
+}
+
 // It's possible to replace the TLS certificate used by the network endpoint.
 func TestEndpoints_NetworkUpdateCert(t *testing.T) {
 	endpoints, config, cleanup := newEndpoints(t)
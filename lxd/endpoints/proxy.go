@@ -0,0 +1,312 @@
+package endpoints
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// proxyProtocolTimeout is how long a trusted proxy has to send the PROXY protocol header.
+const proxyProtocolTimeout = 5 * time.Second
+
+// proxyProtocolV2Signature starts the binary (v2) PROXY protocol header.
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ParseTrustedProxies parses a comma separated list of IP addresses and CIDR subnets of trusted
+// reverse proxies.
+func ParseTrustedProxies(value string) ([]*net.IPNet, error) {
+	subnets := []*net.IPNet{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid IP address %q", entry)
+			}
+
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+
+			subnets = append(subnets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, subnet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid subnet %q", entry)
+		}
+
+		subnets = append(subnets, subnet)
+	}
+
+	return subnets, nil
+}
+
+// NetworkUpdateTrustedProxy sets the comma separated list of addresses and subnets of the reverse
+// proxies allowed to relay the address of their clients, either through the PROXY protocol or
+// through the X-Forwarded-For header.
+func (e *Endpoints) NetworkUpdateTrustedProxy(value string) error {
+	subnets, err := ParseTrustedProxies(value)
+	if err != nil {
+		return err
+	}
+
+	e.proxyMu.Lock()
+	defer e.proxyMu.Unlock()
+
+	e.trustedProxies = subnets
+	return nil
+}
+
+// networkIsTrustedProxy returns whether the given IP belongs to a trusted reverse proxy.
+func (e *Endpoints) networkIsTrustedProxy(ip net.IP) bool {
+	e.proxyMu.RLock()
+	defer e.proxyMu.RUnlock()
+
+	for _, subnet := range e.trustedProxies {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NetworkRewriteForwarded replaces the remote address and host of a request relayed by a trusted
+// reverse proxy with the ones of the original request, taken from the X-Forwarded-For and
+// X-Forwarded-Host headers. The headers of requests not coming from a trusted proxy are ignored.
+func (e *Endpoints) NetworkRewriteForwarded(r *http.Request) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return
+	}
+
+	peer := net.ParseIP(host)
+	if peer == nil || !e.networkIsTrustedProxy(peer) {
+		return
+	}
+
+	client := forwardedClient(r.Header["X-Forwarded-For"], e.networkIsTrustedProxy)
+	if client != nil {
+		r.RemoteAddr = net.JoinHostPort(client.String(), "0")
+	}
+
+	forwardedHost := r.Header.Get("X-Forwarded-Host")
+	if forwardedHost != "" {
+		r.Host = strings.TrimSpace(strings.Split(forwardedHost, ",")[0])
+	}
+}
+
+// forwardedClient returns the client address from X-Forwarded-For headers. Each proxy appends the
+// address it got the request from, so the client is the last address not belonging to a trusted
+// proxy. Nil is returned if there's no valid address.
+func forwardedClient(headers []string, trusted func(net.IP) bool) net.IP {
+	addresses := []string{}
+	for _, header := range headers {
+		addresses = append(addresses, strings.Split(header, ",")...)
+	}
+
+	var client net.IP
+	for i := len(addresses) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(addresses[i])
+
+		// Strip the port and brackets some proxies include.
+		host, _, err := net.SplitHostPort(address)
+		if err == nil {
+			address = host
+		}
+
+		ip := net.ParseIP(strings.Trim(address, "[]"))
+		if ip == nil {
+			break
+		}
+
+		client = ip
+		if !trusted(ip) {
+			break
+		}
+	}
+
+	return client
+}
+
+// proxyProtocolConn is a connection from a trusted proxy which may start with a PROXY protocol
+// header. The header is parsed on first use of the connection, in the goroutine serving it, so a
+// slow proxy doesn't hold up the accept loop.
+type proxyProtocolConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	once       sync.Once
+	remoteAddr net.Addr
+	err        error
+}
+
+func newProxyProtocolConn(conn net.Conn) *proxyProtocolConn {
+	return &proxyProtocolConn{
+		Conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+}
+
+// readHeader parses the PROXY protocol header once, if there's one.
+func (c *proxyProtocolConn) readHeader() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(proxyProtocolTimeout))
+		defer c.Conn.SetReadDeadline(time.Time{})
+
+		c.remoteAddr, c.err = proxyProtocolReadHeader(c.reader)
+		if c.err != nil {
+			logger.Warn("Invalid PROXY protocol header", log.Ctx{"proxy": c.Conn.RemoteAddr(), "err": c.err})
+		}
+	})
+}
+
+// Read reads data from the connection, after the PROXY protocol header.
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the address of the client given by the proxy, or the one of the proxy if it
+// didn't send one.
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// proxyProtocolReadHeader reads a v1 (text) or v2 (binary) PROXY protocol header and returns the
+// source address it carries. Nil is returned if the connection doesn't start with a header or if
+// the header doesn't carry an address (LOCAL or UNKNOWN connections, such as health checks).
+func proxyProtocolReadHeader(reader *bufio.Reader) (net.Addr, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch first[0] {
+	case 'P':
+		return proxyProtocolReadV1(reader)
+	case proxyProtocolV2Signature[0]:
+		return proxyProtocolReadV2(reader)
+	}
+
+	return nil, nil
+}
+
+// proxyProtocolReadV1 parses a header such as "PROXY TCP4 <src> <dst> <sport> <dport>\r\n".
+func proxyProtocolReadV1(reader *bufio.Reader) (net.Addr, error) {
+	// The header is at most 107 bytes long including the CRLF.
+	line := []byte{}
+	for len(line) < 107 {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("Header isn't terminated by CRLF")
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, fmt.Errorf("Malformed header")
+	}
+
+	if fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+
+	if (fields[1] != "TCP4" && fields[1] != "TCP6") || len(fields) != 6 {
+		return nil, fmt.Errorf("Unsupported protocol %q", fields[1])
+	}
+
+	ip := net.ParseIP(fields[2])
+	if ip == nil {
+		return nil, fmt.Errorf("Invalid source address %q", fields[2])
+	}
+
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid source port %q", fields[4])
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// proxyProtocolReadV2 parses a binary header: a 12 bytes signature, the version and command, the
+// address family and protocol, the length of the addresses and the addresses themselves.
+func proxyProtocolReadV2(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(header[:12], proxyProtocolV2Signature) {
+		return nil, fmt.Errorf("Invalid signature")
+	}
+
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("Unsupported version %d", header[12]>>4)
+	}
+
+	addresses := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	_, err = io.ReadFull(reader, addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	// LOCAL connections are initiated by the proxy itself.
+	if header[12]&0x0f == 0 {
+		return nil, nil
+	}
+
+	switch header[13] {
+	case 0x11: // TCP over IPv4
+		if len(addresses) < 12 {
+			return nil, fmt.Errorf("Truncated IPv4 addresses")
+		}
+
+		return &net.TCPAddr{IP: net.IP(addresses[0:4]), Port: int(binary.BigEndian.Uint16(addresses[8:10]))}, nil
+	case 0x21: // TCP over IPv6
+		if len(addresses) < 36 {
+			return nil, fmt.Errorf("Truncated IPv6 addresses")
+		}
+
+		return &net.TCPAddr{IP: net.IP(addresses[0:16]), Port: int(binary.BigEndian.Uint16(addresses[32:34]))}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,131 @@
+package endpoints
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	subnets, err := ParseTrustedProxies("10.0.0.1, 192.168.1.0/24,fd00::1,")
+	require.NoError(t, err)
+	require.Len(t, subnets, 3)
+	assert.Equal(t, "10.0.0.1/32", subnets[0].String())
+	assert.Equal(t, "192.168.1.0/24", subnets[1].String())
+	assert.Equal(t, "fd00::1/128", subnets[2].String())
+
+	_, err = ParseTrustedProxies("10.0.0.1,foo")
+	assert.Error(t, err)
+
+	_, err = ParseTrustedProxies("10.0.0.0/33")
+	assert.Error(t, err)
+}
+
+func TestProxyProtocolReadHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		address string
+	}{
+		{"No header", "\x16\x03\x01", ""},
+		{"v1 IPv4", "PROXY TCP4 203.0.113.7 10.0.0.1 56324 8443\r\n\x16", "203.0.113.7:56324"},
+		{"v1 IPv6", "PROXY TCP6 2001:db8::7 fd00::1 56324 8443\r\n\x16", "[2001:db8::7]:56324"},
+		{"v1 unknown", "PROXY UNKNOWN\r\n\x16", ""},
+		{"v2 IPv4", string(proxyProtocolV2Signature) + "\x21\x11\x00\x0c\xcb\x00\x71\x07\x0a\x00\x00\x01\xdc\x04\x20\xfb\x16", "203.0.113.7:56324"},
+		{"v2 local", string(proxyProtocolV2Signature) + "\x20\x00\x00\x00\x16", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(test.data))
+			addr, err := proxyProtocolReadHeader(reader)
+			require.NoError(t, err)
+
+			if test.address == "" {
+				assert.Nil(t, addr)
+			} else {
+				require.NotNil(t, addr)
+				assert.Equal(t, test.address, addr.String())
+			}
+
+			// The connection data follows the header.
+			b, err := reader.ReadByte()
+			require.NoError(t, err)
+			assert.Equal(t, byte(0x16), b)
+		})
+	}
+}
+
+func TestProxyProtocolReadHeader_Invalid(t *testing.T) {
+	headers := []string{
+		"PROXY TCP4 203.0.113.7 10.0.0.1 56324\r\n",
+		"PROXY TCP4 foo 10.0.0.1 56324 8443\r\n",
+		"PROXY TCP4 203.0.113.7 10.0.0.1 56324 8443\n",
+		"PROXY UDP4 203.0.113.7 10.0.0.1 56324 8443\r\n",
+		string(proxyProtocolV2Signature) + "\x11\x11\x00\x00",
+		string(proxyProtocolV2Signature) + "\x21\x11\x00\x04\xcb\x00\x71\x07",
+	}
+
+	for _, header := range headers {
+		_, err := proxyProtocolReadHeader(bufio.NewReader(strings.NewReader(header)))
+		assert.Error(t, err, header)
+	}
+}
+
+func TestNetworkRewriteForwarded(t *testing.T) {
+	e := &Endpoints{}
+	require.NoError(t, e.NetworkUpdateTrustedProxy("10.0.0.0/24"))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		{"Untrusted peer", "203.0.113.7:1234", []string{"198.51.100.1"}, "203.0.113.7:1234"},
+		{"Trusted peer", "10.0.0.1:1234", []string{"198.51.100.1"}, "198.51.100.1:0"},
+		{"Chained proxies", "10.0.0.1:1234", []string{"192.0.2.1, 198.51.100.1", "10.0.0.2"}, "198.51.100.1:0"},
+		{"Only proxies", "10.0.0.1:1234", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3:0"},
+		{"Port and brackets", "10.0.0.1:1234", []string{"[2001:db8::1]:5678"}, "[2001:db8::1]:0"},
+		{"No header", "10.0.0.1:1234", nil, "10.0.0.1:1234"},
+		{"Unix socket", "@", []string{"198.51.100.1"}, "@"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &http.Request{RemoteAddr: test.remoteAddr, Header: http.Header{}, Host: "lxd.example.net"}
+			for _, value := range test.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			e.NetworkRewriteForwarded(r)
+			assert.Equal(t, test.expected, r.RemoteAddr)
+		})
+	}
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:1234", Header: http.Header{}}
+	r.Header.Set("X-Forwarded-Host", "lxd.example.com")
+	e.NetworkRewriteForwarded(r)
+	assert.Equal(t, "lxd.example.com", r.Host)
+}
+
+// Connections from trusted proxies get the client address from the PROXY protocol header.
+func TestProxyProtocolConn(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	go client.Write([]byte("PROXY TCP4 203.0.113.7 10.0.0.1 56324 8443\r\nhello"))
+
+	conn := newProxyProtocolConn(server)
+	assert.Equal(t, "203.0.113.7:56324", conn.RemoteAddr().String())
+
+	buf := make([]byte, 5)
+	_, err := conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(buf))
+}
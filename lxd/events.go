@@ -14,7 +14,7 @@ import (
 var eventsCmd = APIEndpoint{
 	Path: "events",
 
-	Get: APIEndpointAction{Handler: eventsGet, AccessHandler: AllowAuthenticated},
+	Get: APIEndpointAction{Handler: eventsGet, AccessHandler: AllowProjectAuthenticated},
 }
 
 type eventsServe struct {
@@ -59,7 +59,10 @@ func eventsSocket(d *Daemon, r *http.Request, w http.ResponseWriter) error {
 	// If this request is an internal one initiated by another node wanting
 	// to watch the events on this node, set the listener to broadcast only
 	// local events.
-	listener, err := d.events.AddListener(project, c, strings.Split(typeStr, ","), serverName, isClusterNotification(r))
+	// Restricted clients only get the events of their project.
+	restricted, _ := d.userRestricted(r)
+
+	listener, err := d.events.AddListener(project, c, strings.Split(typeStr, ","), serverName, isClusterNotification(r), restricted)
 	if err != nil {
 		return err
 	}
@@ -35,14 +35,16 @@ func NewServer(debug bool, verbose bool) *Server {
 	return server
 }
 
-// AddListener creates and returns a new event listener.
-func (s *Server) AddListener(group string, connection *websocket.Conn, messageTypes []string, location string, noForward bool) (*Listener, error) {
+// AddListener creates and returns a new event listener. If groupOnly is true, the listener doesn't
+// get the events sent to all groups.
+func (s *Server) AddListener(group string, connection *websocket.Conn, messageTypes []string, location string, noForward bool, groupOnly bool) (*Listener, error) {
 	listener := &Listener{
 		group:        group,
 		connection:   connection,
 		messageTypes: messageTypes,
 		location:     location,
 		noForward:    noForward,
+		groupOnly:    groupOnly,
 		active:       make(chan bool, 1),
 		id:           uuid.NewRandom().String(),
 	}
@@ -113,6 +115,10 @@ func (s *Server) broadcast(group string, event api.Event, isForward bool) error
 	s.lock.Lock()
 	listeners := s.listeners
 	for _, listener := range listeners {
+		if listener.groupOnly && group != listener.group {
+			continue
+		}
+
 		if group != "" && listener.group != "*" && group != listener.group {
 			continue
 		}
@@ -192,6 +198,10 @@ type Listener struct {
 	// nodes. It only used by listeners created internally by LXD nodes
 	// connecting to other LXD nodes to get their local events only.
 	noForward bool
+
+	// If true, this listener only gets the events of its own group, it's
+	// used for clients restricted to some projects.
+	groupOnly bool
 }
 
 // MessageTypes returns a list of message types the listener will be notified of.
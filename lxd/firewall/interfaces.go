@@ -2,10 +2,12 @@ package firewall
 
 import (
 	"net"
+	"os/exec"
 
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	firewallConsts "github.com/lxc/lxd/lxd/firewall/consts"
 	"github.com/lxc/lxd/lxd/iptables"
+	"github.com/lxc/lxd/lxd/nftables"
 )
 
 // Firewall represents an LXD firewall.
@@ -24,7 +26,7 @@ type Firewall interface {
 
 	// Network Functions
 	NetworkSetupAllowForwarding(family firewallConsts.Family, name string, actionType firewallConsts.Action) error
-	NetworkSetupNAT(family firewallConsts.Family, name string, location firewallConsts.Location, args ...string) error
+	NetworkSetupNAT(family firewallConsts.Family, name string, location firewallConsts.Location, subnet net.IPNet, srcIP net.IP) error
 	NetworkSetupIPv4DNSOverrides(name string) error
 	NetworkSetupIPv4DHCPWorkaround(name string) error
 	NetworkSetupIPv6DNSOverrides(name string) error
@@ -33,6 +35,37 @@ type Firewall interface {
 
 // New returns an appropriate firewall implementation.
 func New() Firewall {
-	// TODO: Issue #6223: add startup logic to choose xtables or nftables
+	if nftables.Available() {
+		// Hosts without the xtables tools get a pure nftables setup.
+		_, err := exec.LookPath("iptables")
+		if err != nil {
+			return nftables.NFTables{}
+		}
+
+		return xtablesNFTFilter{}
+	}
+
 	return iptables.XTables{}
 }
+
+// xtablesNFTFilter uses xtables for all rules except the bridged NIC filters,
+// which use nftables bridge rules that can be replaced atomically and don't
+// rely on br_netfilter for IPv6.
+type xtablesNFTFilter struct {
+	iptables.XTables
+}
+
+// VerifyIPv6Module always succeeds as nftables bridge rules see IPv6 traffic natively.
+func (f xtablesNFTFilter) VerifyIPv6Module() error {
+	return nil
+}
+
+// InstanceNicBridgedRemoveFilters removes the nftables filters of the NIC.
+func (f xtablesNFTFilter) InstanceNicBridgedRemoveFilters(m deviceConfig.Device, ipv4 net.IP, ipv6 net.IP) error {
+	return nftables.NFTables{}.InstanceNicBridgedRemoveFilters(m, ipv4, ipv6)
+}
+
+// InstanceNicBridgedSetFilters sets up the nftables filters of the NIC.
+func (f xtablesNFTFilter) InstanceNicBridgedSetFilters(m deviceConfig.Device, ipv4 net.IP, ipv6 net.IP, comment string) error {
+	return nftables.NFTables{}.InstanceNicBridgedSetFilters(m, ipv4, ipv6, comment)
+}
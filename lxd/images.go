@@ -16,6 +16,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -682,33 +683,68 @@ func imagesPost(d *Daemon, r *http.Request) response.Response {
 		}
 	}
 
-	// Store the post data to disk
-	post, err := ioutil.TempFile(builddir, "lxd_post_")
-	if err != nil {
-		cleanup(builddir, nil)
-		return response.InternalError(err)
-	}
+	// Store the post data to disk, or take the content of a previous resumable upload
+	var post *os.File
+	uploadID := r.Header.Get("X-LXD-upload")
+	if uploadID != "" {
+		uploadPath, err := imageUploadTake(uploadID, project)
+		if err != nil {
+			cleanup(builddir, nil)
+			return response.BadRequest(err)
+		}
 
-	_, err = io.Copy(post, r.Body)
-	if err != nil {
-		cleanup(builddir, post)
-		return response.InternalError(err)
+		postPath := filepath.Join(builddir, "lxd_post_upload")
+		err = os.Rename(uploadPath, postPath)
+		if err != nil {
+			os.Remove(uploadPath)
+			cleanup(builddir, nil)
+			return response.InternalError(err)
+		}
+
+		post, err = os.Open(postPath)
+		if err != nil {
+			cleanup(builddir, nil)
+			return response.InternalError(err)
+		}
+
+		checksum := r.Header.Get("X-LXD-upload-sha256")
+		if checksum != "" {
+			err = imageUploadVerify(post, checksum)
+			if err != nil {
+				cleanup(builddir, post)
+				return response.BadRequest(err)
+			}
+		}
+	} else {
+		post, err = ioutil.TempFile(builddir, "lxd_post_")
+		if err != nil {
+			cleanup(builddir, nil)
+			return response.InternalError(err)
+		}
+
+		_, err = io.Copy(post, r.Body)
+		if err != nil {
+			cleanup(builddir, post)
+			return response.InternalError(err)
+		}
 	}
 
 	// Is this a container request?
 	post.Seek(0, 0)
 	decoder := json.NewDecoder(post)
-	imageUpload := false
+	imageUpload := uploadID != ""
 
 	req := api.ImagesPost{}
-	err = decoder.Decode(&req)
-	if err != nil {
-		if r.Header.Get("Content-Type") == "application/json" {
-			cleanup(builddir, post)
-			return response.BadRequest(err)
-		}
+	if !imageUpload {
+		err = decoder.Decode(&req)
+		if err != nil {
+			if r.Header.Get("Content-Type") == "application/json" {
+				cleanup(builddir, post)
+				return response.BadRequest(err)
+			}
 
-		imageUpload = true
+			imageUpload = true
+		}
 	}
 
 	if !imageUpload && !shared.StringInSlice(req.Source.Type, []string{"container", "snapshot", "image", "url"}) {
@@ -917,7 +953,17 @@ func getImageMetadata(fname string) (*api.ImageMetadata, string, error) {
 }
 
 func doImagesGet(d *Daemon, recursion bool, project string, public bool) (interface{}, error) {
-	results, err := d.cluster.ImagesGet(project, public)
+	// In mirror mode, the cached images are listed to untrusted clients too.
+	mirror := false
+	if public {
+		var err error
+		mirror, err = imagesMirrorEnabled(d)
+		if err != nil {
+			return []string{}, err
+		}
+	}
+
+	results, err := d.cluster.ImagesGet(project, public && !mirror)
 	if err != nil {
 		return []string{}, err
 	}
@@ -926,11 +972,18 @@ func doImagesGet(d *Daemon, recursion bool, project string, public bool) (interf
 	resultMap := make([]*api.Image, len(results))
 	i := 0
 	for _, name := range results {
+		if mirror {
+			image, response := doImageGet(d.cluster, project, name, false)
+			if response != nil || (!image.Public && !image.Cached) {
+				continue
+			}
+		}
+
 		if !recursion {
 			url := fmt.Sprintf("/%s/images/%s", version.APIVersion, name)
 			resultString[i] = url
 		} else {
-			image, response := doImageGet(d.cluster, project, name, public)
+			image, response := doImageGet(d.cluster, project, name, public && !mirror)
 			if response != nil {
 				continue
 			}
@@ -941,10 +994,10 @@ func doImagesGet(d *Daemon, recursion bool, project string, public bool) (interf
 	}
 
 	if !recursion {
-		return resultString, nil
+		return resultString[:i], nil
 	}
 
-	return resultMap, nil
+	return resultMap[:i], nil
 }
 
 func imagesGet(d *Daemon, r *http.Request) response.Response {
@@ -1030,6 +1083,11 @@ func autoUpdateImagesInProject(ctx context.Context, d *Daemon, project string) e
 		return errors.Wrap(err, "Unable to retrieve the list of images")
 	}
 
+	clustered, err := cluster.Enabled(d.db)
+	if err != nil {
+		return err
+	}
+
 	for _, fingerprint := range images {
 		id, info, err := d.cluster.ImageGet(project, fingerprint, false, true)
 		if err != nil {
@@ -1041,6 +1099,20 @@ func autoUpdateImagesInProject(ctx context.Context, d *Daemon, project string) e
 			continue
 		}
 
+		// In a cluster, a single member downloads the update and copies it to the others.
+		if clustered {
+			updater, err := imageAutoUpdateNode(d, fingerprint)
+			if err != nil {
+				logger.Error("Error picking the member updating the image", log.Ctx{"err": err, "fp": fingerprint, "project": project})
+				continue
+			}
+
+			if !updater {
+				logger.Debug("Skipping image update handled by another member", log.Ctx{"fp": fingerprint, "project": project})
+				continue
+			}
+		}
+
 		// FIXME: since our APIs around image downloading don't support
 		//        cancelling, we run the function in a different
 		//        goroutine and simply abort when the context expires.
@@ -1059,6 +1131,32 @@ func autoUpdateImagesInProject(ctx context.Context, d *Daemon, project string) e
 	return nil
 }
 
+// imageAutoUpdateNode returns whether this member is the one which should refresh the image. This
+// is the leader if it has the image or the first of the online members having it otherwise.
+func imageAutoUpdateNode(d *Daemon, fingerprint string) (bool, error) {
+	localAddress, err := node.ClusterAddress(d.db)
+	if err != nil {
+		return false, err
+	}
+
+	leader, err := d.gateway.LeaderAddress()
+	if err != nil {
+		return false, err
+	}
+
+	addresses, err := d.cluster.ImageGetNodesWithImage(fingerprint)
+	if err != nil {
+		return false, err
+	}
+
+	if len(addresses) == 0 || shared.StringInSlice(leader, addresses) {
+		return localAddress == leader, nil
+	}
+
+	sort.Strings(addresses)
+	return localAddress == addresses[0], nil
+}
+
 // Update a single image.  The operation can be nil, if no progress tracking is needed.
 // Returns whether the image has been updated.
 func autoUpdateImage(d *Daemon, op *operations.Operation, id int, info *api.Image, project string) error {
@@ -1069,6 +1167,32 @@ func autoUpdateImage(d *Daemon, op *operations.Operation, id int, info *api.Imag
 		return err
 	}
 
+	// Record the other cluster members which have the image, they get the update from us.
+	var peerAddresses []string
+	clustered, err := cluster.Enabled(d.db)
+	if err != nil {
+		return err
+	}
+
+	if clustered {
+		localAddress, err := node.ClusterAddress(d.db)
+		if err != nil {
+			return err
+		}
+
+		addresses, err := d.cluster.ImageGetNodesWithImage(fingerprint)
+		if err != nil {
+			logger.Error("Error getting image members", log.Ctx{"err": err, "fp": fingerprint})
+			return err
+		}
+
+		for _, address := range addresses {
+			if address != localAddress {
+				peerAddresses = append(peerAddresses, address)
+			}
+		}
+	}
+
 	// Get the IDs of all storage pools on which a storage volume
 	// for the requested image currently exists.
 	poolIDs, err := d.cluster.ImageGetPools(fingerprint)
@@ -1165,6 +1289,31 @@ func autoUpdateImage(d *Daemon, op *operations.Operation, id int, info *api.Imag
 		return nil
 	}
 
+	// Copy the new image to the other members over the cluster network and have them drop the
+	// files of the old one.
+	for _, address := range peerAddresses {
+		err := imageCopyToNode(d, project, hash, address)
+		if err != nil {
+			logger.Error("Failed to copy the updated image to member", log.Ctx{"err": err, "fp": hash, "address": address})
+			continue
+		}
+
+		client, err := cluster.Connect(address, d.endpoints.NetworkCert(), true)
+		if err != nil {
+			logger.Error("Failed to connect to member", log.Ctx{"err": err, "address": address})
+			continue
+		}
+
+		deleteOp, err := client.UseProject(project).DeleteImage(fingerprint)
+		if err == nil {
+			err = deleteOp.Wait()
+		}
+
+		if err != nil {
+			logger.Error("Failed to delete the outdated image from member", log.Ctx{"err": err, "fp": fingerprint, "address": address})
+		}
+	}
+
 	// Remove main image file.
 	fname := filepath.Join(d.os.VarDir, "images", fingerprint)
 	if shared.PathExists(fname) {
@@ -1214,10 +1363,10 @@ func pruneExpiredImagesTask(d *Daemon) (task.Func, task.Schedule) {
 
 	// Skip the first run, and instead run an initial pruning synchronously
 	// before we start updating images later on in the start up process.
-	expiry, err := cluster.ConfigGetInt64(d.cluster, "images.remote_cache_expiry")
+	enabled, err := pruneExpiredImagesEnabled(d)
 	if err != nil {
 		logger.Error("Unable to fetch cluster configuration", log.Ctx{"err": err})
-	} else if expiry > 0 {
+	} else if enabled {
 		f(context.Background())
 	}
 
@@ -1229,14 +1378,14 @@ func pruneExpiredImagesTask(d *Daemon) (task.Func, task.Schedule) {
 			return interval, task.ErrSkip
 		}
 
-		expiry, err := cluster.ConfigGetInt64(d.cluster, "images.remote_cache_expiry")
+		enabled, err := pruneExpiredImagesEnabled(d)
 		if err != nil {
 			logger.Error("Unable to fetch cluster configuration", log.Ctx{"err": err})
 			return interval, nil
 		}
 
 		// Check if we're supposed to prune at all
-		if expiry <= 0 {
+		if !enabled {
 			interval = 0
 		}
 
@@ -1291,40 +1440,172 @@ func pruneLeftoverImages(d *Daemon) {
 	logger.Infof("Done pruning leftover image files")
 }
 
+// imagesExpiryPolicy returns the expiry in days of cached images, the expiry of the projects
+// overriding it and the cached images which never expire.
+func imagesExpiryPolicy(d *Daemon) (int64, map[string]int64, []string, error) {
+	var expiry int64
+	var pinned []string
+	projectsExpiry := map[string]int64{}
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		config, err := cluster.ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+
+		expiry = config.RemoteCacheExpiry()
+		pinned = config.RemoteCachePinned()
+
+		projects, err := tx.ProjectList(db.ProjectFilter{})
+		if err != nil {
+			return err
+		}
+
+		for _, project := range projects {
+			value := project.Config["images.remote_cache_expiry"]
+			if value == "" {
+				continue
+			}
+
+			projectExpiry, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return errors.Wrapf(err, "Invalid images.remote_cache_expiry of project %q", project.Name)
+			}
+
+			projectsExpiry[project.Name] = projectExpiry
+		}
+
+		return nil
+	})
+	if err != nil {
+		return -1, nil, nil, err
+	}
+
+	return expiry, projectsExpiry, pinned, nil
+}
+
+// pruneExpiredImagesEnabled returns whether cached images expire in any of the projects.
+func pruneExpiredImagesEnabled(d *Daemon) (bool, error) {
+	expiry, projectsExpiry, _, err := imagesExpiryPolicy(d)
+	if err != nil {
+		return false, err
+	}
+
+	if expiry > 0 {
+		return true, nil
+	}
+
+	for _, projectExpiry := range projectsExpiry {
+		if projectExpiry > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// imagePinned returns whether the cached image matches one of the pinned entries, either through
+// the alias it was downloaded as or through its fingerprint, optionally from a given server only.
+func imagePinned(pinned []string, image db.ImageCached) bool {
+	for _, entry := range pinned {
+		name := entry
+		server := ""
+
+		i := strings.LastIndex(entry, "@")
+		if i >= 0 {
+			name = entry[:i]
+			server = entry[i+1:]
+		}
+
+		if server != "" && strings.TrimSuffix(server, "/") != strings.TrimSuffix(image.Server, "/") {
+			continue
+		}
+
+		if (image.Alias != "" && name == image.Alias) || strings.HasPrefix(image.Fingerprint, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func pruneExpiredImages(ctx context.Context, d *Daemon) error {
-	expiry, err := cluster.ConfigGetInt64(d.cluster, "images.remote_cache_expiry")
+	expiry, projectsExpiry, pinned, err := imagesExpiryPolicy(d)
 	if err != nil {
 		return errors.Wrap(err, "Unable to fetch cluster configuration")
 	}
 
+	// Record which of the cached images are pinned, so that they're skipped.
+	cached, err := d.cluster.ImagesGetCached()
+	if err != nil {
+		return errors.Wrap(err, "Unable to retrieve the list of cached images")
+	}
+
+	for _, image := range cached {
+		isPinned := imagePinned(pinned, image)
+		if isPinned == image.Pinned {
+			continue
+		}
+
+		err = d.cluster.ImageSetPinned(image.ID, isPinned)
+		if err != nil {
+			return errors.Wrapf(err, "Error pinning image %s", image.Fingerprint)
+		}
+	}
+
 	// Get the list of expired images.
-	images, err := d.cluster.ImagesGetExpired(expiry)
+	images, err := d.cluster.ImagesGetExpired(expiry, projectsExpiry)
 	if err != nil {
 		return errors.Wrap(err, "Unable to retrieve the list of expired images")
 	}
 
 	// Delete them
-	for _, fp := range images {
-		// At each iteration we check if we got cancelled in the
-		// meantime. It is safe to abort here since anything not
-		// expired now will be expired at the next run.
-		select {
-		case <-ctx.Done():
-			return nil
-		default:
+	for project, fps := range images {
+		for _, fp := range fps {
+			// At each iteration we check if we got cancelled in the
+			// meantime. It is safe to abort here since anything not
+			// expired now will be expired at the next run.
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+			}
+
+			err = pruneExpiredImage(d, project, fp)
+			if err != nil {
+				return err
+			}
 		}
+	}
+
+	return nil
+}
+
+// pruneExpiredImage deletes an expired image from the project, along with its files and storage
+// volumes unless other projects still use it.
+func pruneExpiredImage(d *Daemon, project string, fp string) error {
+	imgID, _, err := d.cluster.ImageGet(project, fp, false, false)
+	if err != nil {
+		return errors.Wrapf(err, "Error retrieving image info %s", fp)
+	}
+
+	referenced, err := d.cluster.ImageIsReferencedByOtherProjects(project, fp)
+	if err != nil {
+		return errors.Wrapf(err, "Error checking the projects of image %s", fp)
+	}
 
+	if !referenced {
 		// Get the IDs of all storage pools on which a storage volume
 		// for the requested image currently exists.
 		poolIDs, err := d.cluster.ImageGetPools(fp)
 		if err != nil {
-			continue
+			return nil
 		}
 
 		// Translate the IDs to poolNames.
 		poolNames, err := d.cluster.ImageGetPoolNamesFromIDs(poolIDs)
 		if err != nil {
-			continue
+			return nil
 		}
 
 		for _, pool := range poolNames {
@@ -1351,16 +1632,12 @@ func pruneExpiredImages(ctx context.Context, d *Daemon) error {
 				return errors.Wrapf(err, "Error deleting image file %s", fname)
 			}
 		}
+	}
 
-		imgID, _, err := d.cluster.ImageGet("default", fp, false, false)
-		if err != nil {
-			return errors.Wrapf(err, "Error retrieving image info %s", fp)
-		}
-
-		// Remove the database entry for the image.
-		if err = d.cluster.ImageDelete(imgID); err != nil {
-			return errors.Wrapf(err, "Error deleting image %s from database", fp)
-		}
+	// Remove the database entry for the image.
+	err = d.cluster.ImageDelete(imgID)
+	if err != nil {
+		return errors.Wrapf(err, "Error deleting image %s from database", fp)
 	}
 
 	return nil
@@ -1583,7 +1860,7 @@ func imageGet(d *Daemon, r *http.Request) response.Response {
 		return resp
 	}
 
-	if !info.Public && public && !imageValidSecret(info.Fingerprint, secret) {
+	if !info.Public && public && !imageMirrored(d, info) && !imageValidSecret(info.Fingerprint, secret) {
 		return response.NotFound(fmt.Errorf("Image '%s' not found", info.Fingerprint))
 	}
 
@@ -1944,7 +2221,7 @@ func imageExport(d *Daemon, r *http.Request) response.Response {
 			return response.SmartError(err)
 		}
 
-		if !imgInfo.Public && public && !imageValidSecret(imgInfo.Fingerprint, secret) {
+		if !imgInfo.Public && public && !imageMirrored(d, imgInfo) && !imageValidSecret(imgInfo.Fingerprint, secret) {
 			return response.NotFound(fmt.Errorf("Image '%s' not found", imgInfo.Fingerprint))
 		}
 	}
@@ -2262,7 +2539,12 @@ func imageSyncBetweenNodes(d *Daemon, project string, fingerprint string) error
 		targetNodeAddress = addresses[0]
 	}
 
-	client, err := cluster.Connect(targetNodeAddress, d.endpoints.NetworkCert(), true)
+	return imageCopyToNode(d, project, fingerprint, targetNodeAddress)
+}
+
+// imageCopyToNode uploads the local copy of an image to another cluster member.
+func imageCopyToNode(d *Daemon, project string, fingerprint string, address string) error {
+	client, err := cluster.Connect(address, d.endpoints.NetworkCert(), true)
 	if err != nil {
 		return errors.Wrap(err, "Failed to connect node for image synchronization")
 	}
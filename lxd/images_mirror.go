@@ -0,0 +1,467 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/osarch"
+	"github.com/lxc/lxd/shared/simplestreams"
+)
+
+// The simplestreams tree served when images.mirror is enabled, so that other LXD servers can use
+// this one as a "simplestreams" remote, e.g. in air-gapped environments.
+var apiStreams = []APIEndpoint{
+	imagesStreamsIndexCmd,
+	imagesStreamsImagesCmd,
+	imagesStreamsFileCmd,
+}
+
+var imagesStreamsIndexCmd = APIEndpoint{
+	Path: "v1/index.json",
+
+	Get: APIEndpointAction{Handler: imagesStreamsIndexGet, AllowUntrusted: true},
+}
+
+var imagesStreamsImagesCmd = APIEndpoint{
+	Path: "v1/images.json",
+
+	Get: APIEndpointAction{Handler: imagesStreamsImagesGet, AllowUntrusted: true},
+}
+
+var imagesStreamsFileCmd = APIEndpoint{
+	Path: "v1/files/{fingerprint}/{file}",
+
+	Get: APIEndpointAction{Handler: imagesStreamsFileGet, AllowUntrusted: true},
+}
+
+// imagesMirrorProject is the project whose images are served through simplestreams.
+const imagesMirrorProject = "default"
+
+// imagesMirrorFile is one of the files of a mirrored image.
+type imagesMirrorFile struct {
+	name     string // Name of the file in the simplestreams tree.
+	path     string // Path of the file on disk.
+	fileType string // Simplestreams file type.
+	size     int64
+}
+
+// The sha256 of the image files, keyed by path. Image files are never modified, so these only
+// need to be computed once.
+var imagesMirrorHashesLock sync.Mutex
+var imagesMirrorHashes = map[string]string{}
+
+// imagesMirrorEnabled returns whether the server mirrors its images.
+func imagesMirrorEnabled(d *Daemon) (bool, error) {
+	return cluster.ConfigGetBool(d.cluster, "images.mirror")
+}
+
+// imageMirrored returns whether the image is made available to untrusted clients by the mirror
+// mode, which publishes the cached images on top of the public ones.
+func imageMirrored(d *Daemon, info *api.Image) bool {
+	if !info.Cached {
+		return false
+	}
+
+	enabled, err := imagesMirrorEnabled(d)
+	if err != nil {
+		logger.Error("Failed to check if images are mirrored", log.Ctx{"err": err})
+		return false
+	}
+
+	return enabled
+}
+
+// imagesMirrorList returns the public and cached images of the mirrored project which are
+// available on this server.
+func imagesMirrorList(d *Daemon) ([]*api.Image, error) {
+	fingerprints, err := d.cluster.ImagesGet(imagesMirrorProject, false)
+	if err != nil {
+		return nil, err
+	}
+
+	images := []*api.Image{}
+	for _, fingerprint := range fingerprints {
+		_, info, err := d.cluster.ImageGet(imagesMirrorProject, fingerprint, false, true)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.Public && !info.Cached {
+			continue
+		}
+
+		// In clusters, each member serves the images it holds.
+		if !shared.PathExists(shared.VarPath("images", info.Fingerprint)) {
+			continue
+		}
+
+		images = append(images, info)
+	}
+
+	return images, nil
+}
+
+// imagesMirrorFiles returns the files of an image, named like they are by image exports.
+func imagesMirrorFiles(info *api.Image) ([]imagesMirrorFile, error) {
+	imagePath := shared.VarPath("images", info.Fingerprint)
+	rootfsPath := imagePath + ".rootfs"
+
+	fileSize := func(path string) (int64, error) {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return -1, err
+		}
+
+		return fi.Size(), nil
+	}
+
+	_, ext, _, err := shared.DetectCompression(imagePath)
+	if err != nil {
+		ext = ""
+	}
+
+	size, err := fileSize(imagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !shared.PathExists(rootfsPath) {
+		return []imagesMirrorFile{{
+			name:     info.Fingerprint + ext,
+			path:     imagePath,
+			fileType: "lxd_combined.tar.gz",
+			size:     size,
+		}}, nil
+	}
+
+	files := []imagesMirrorFile{{
+		name:     "meta-" + info.Fingerprint + ext,
+		path:     imagePath,
+		fileType: "lxd.tar.xz",
+		size:     size,
+	}}
+
+	// The root filesystem may use a different compression algorithm than the metadata.
+	_, ext, _, err = shared.DetectCompression(rootfsPath)
+	if err != nil {
+		ext = ""
+	}
+
+	size, err = fileSize(rootfsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	fileType := "root.tar.xz"
+	if info.Type == "virtual-machine" {
+		fileType = "disk-kvm.img"
+	} else if ext == ".squashfs" {
+		fileType = "squashfs"
+	}
+
+	files = append(files, imagesMirrorFile{
+		name:     info.Fingerprint + ext,
+		path:     rootfsPath,
+		fileType: fileType,
+		size:     size,
+	})
+
+	return files, nil
+}
+
+// imagesMirrorHash returns the sha256 of an image file.
+func imagesMirrorHash(path string) (string, error) {
+	imagesMirrorHashesLock.Lock()
+	hash, ok := imagesMirrorHashes[path]
+	imagesMirrorHashesLock.Unlock()
+	if ok {
+		return hash, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sha256 := sha256.New()
+	_, err = io.Copy(sha256, f)
+	if err != nil {
+		return "", err
+	}
+
+	hash = hex.EncodeToString(sha256.Sum(nil))
+
+	imagesMirrorHashesLock.Lock()
+	imagesMirrorHashes[path] = hash
+	imagesMirrorHashesLock.Unlock()
+
+	return hash, nil
+}
+
+// imagesMirrorProducts builds the simplestreams products of the given images. Images with os and
+// release properties are grouped by os, release, variant, architecture and type, as they are on
+// the public image servers, and get "<os>/<release>[/<variant>]" aliases. Their local aliases are
+// kept too.
+func imagesMirrorProducts(images []*api.Image) (*simplestreams.Products, error) {
+	products := simplestreams.Products{
+		ContentID: "images",
+		DataType:  "image-downloads",
+		Format:    "products:1.0",
+		Products:  map[string]simplestreams.Product{},
+		Updated:   time.Now().UTC().Format(time.RFC1123Z),
+	}
+
+	productAliases := map[string][]string{}
+
+	for _, info := range images {
+		files, err := imagesMirrorFiles(info)
+		if err != nil {
+			return nil, err
+		}
+
+		architecture := info.Properties["architecture"]
+		_, err = osarch.ArchitectureId(architecture)
+		if err != nil {
+			architecture = info.Architecture
+		}
+
+		osName := info.Properties["os"]
+		release := info.Properties["release"]
+		name := info.Fingerprint
+		aliases := []string{}
+
+		if osName != "" && release != "" {
+			variant := info.Properties["variant"]
+			if variant == "" {
+				variant = "default"
+			}
+
+			name = strings.ToLower(fmt.Sprintf("%s:%s:%s:%s:%s", osName, release, variant, architecture, info.Type))
+			aliases = append(aliases, strings.ToLower(fmt.Sprintf("%s/%s/%s", osName, release, variant)))
+			if variant == "default" {
+				aliases = append(aliases, strings.ToLower(fmt.Sprintf("%s/%s", osName, release)))
+			}
+		}
+
+		for _, alias := range info.Aliases {
+			aliases = append(aliases, alias.Name)
+		}
+
+		for _, alias := range aliases {
+			if !shared.StringInSlice(alias, productAliases[name]) {
+				productAliases[name] = append(productAliases[name], alias)
+			}
+		}
+
+		product, ok := products.Products[name]
+		if !ok {
+			product = simplestreams.Product{
+				Architecture:    architecture,
+				OperatingSystem: osName,
+				Release:         release,
+				ReleaseTitle:    release,
+				Version:         info.Properties["version"],
+				Versions:        map[string]simplestreams.ProductVersion{},
+			}
+		}
+
+		// Version names must start with the date of the image.
+		serial := info.Properties["serial"]
+		if len(serial) < 8 {
+			serial = info.CreatedAt.UTC().Format("20060102_1504")
+		} else {
+			_, err = time.Parse("20060102", serial[0:8])
+			if err != nil {
+				serial = info.CreatedAt.UTC().Format("20060102_1504")
+			}
+		}
+
+		_, ok = product.Versions[serial]
+		if ok {
+			serial = fmt.Sprintf("%s_%s", serial, info.Fingerprint[0:12])
+		}
+
+		items := map[string]simplestreams.ProductVersionItem{}
+		for _, file := range files {
+			item := simplestreams.ProductVersionItem{
+				FileType: file.fileType,
+				Path:     fmt.Sprintf("streams/v1/files/%s/%s", info.Fingerprint, file.name),
+				Size:     file.size,
+			}
+
+			if len(files) == 1 {
+				// The fingerprint of unified images is the sha256 of their only file.
+				item.HashSha256 = info.Fingerprint
+			} else {
+				item.HashSha256, err = imagesMirrorHash(file.path)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			items[file.fileType] = item
+		}
+
+		// The fingerprint of split images is the combined sha256 of their files, recorded on
+		// the metadata item under a key depending on the root filesystem type.
+		meta, ok := items["lxd.tar.xz"]
+		if ok {
+			switch files[1].fileType {
+			case "disk-kvm.img":
+				meta.LXDHashSha256DiskKvmImg = info.Fingerprint
+			case "squashfs":
+				meta.LXDHashSha256SquashFs = info.Fingerprint
+			default:
+				meta.LXDHashSha256RootXz = info.Fingerprint
+			}
+
+			items["lxd.tar.xz"] = meta
+		}
+
+		product.Versions[serial] = simplestreams.ProductVersion{
+			Items: items,
+			Label: info.Properties["label"],
+		}
+
+		products.Products[name] = product
+	}
+
+	for name, aliases := range productAliases {
+		product := products.Products[name]
+		product.Aliases = strings.Join(aliases, ",")
+		products.Products[name] = product
+	}
+
+	return &products, nil
+}
+
+// imagesStreamsJSON renders a simplestreams file.
+func imagesStreamsJSON(data interface{}) response.Response {
+	return response.StreamResponse(map[string]string{"Content-Type": "application/json"}, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(data)
+	})
+}
+
+// imagesStreamsCheck returns an error response if the server doesn't mirror its images.
+func imagesStreamsCheck(d *Daemon) response.Response {
+	enabled, err := imagesMirrorEnabled(d)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if !enabled {
+		return response.NotFound(fmt.Errorf("Image mirroring is disabled"))
+	}
+
+	return nil
+}
+
+func imagesStreamsIndexGet(d *Daemon, r *http.Request) response.Response {
+	resp := imagesStreamsCheck(d)
+	if resp != nil {
+		return resp
+	}
+
+	images, err := imagesMirrorList(d)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	products, err := imagesMirrorProducts(images)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	names := []string{}
+	for name := range products.Products {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	stream := simplestreams.Stream{
+		Index: map[string]simplestreams.StreamIndex{
+			"images": {
+				DataType: products.DataType,
+				Path:     "streams/v1/images.json",
+				Updated:  products.Updated,
+				Products: names,
+				Format:   products.Format,
+			},
+		},
+		Updated: products.Updated,
+		Format:  "index:1.0",
+	}
+
+	return imagesStreamsJSON(stream)
+}
+
+func imagesStreamsImagesGet(d *Daemon, r *http.Request) response.Response {
+	resp := imagesStreamsCheck(d)
+	if resp != nil {
+		return resp
+	}
+
+	images, err := imagesMirrorList(d)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	products, err := imagesMirrorProducts(images)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return imagesStreamsJSON(products)
+}
+
+func imagesStreamsFileGet(d *Daemon, r *http.Request) response.Response {
+	resp := imagesStreamsCheck(d)
+	if resp != nil {
+		return resp
+	}
+
+	fingerprint := mux.Vars(r)["fingerprint"]
+	name := mux.Vars(r)["file"]
+
+	_, info, err := d.cluster.ImageGet(imagesMirrorProject, fingerprint, false, true)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if !info.Public && !info.Cached {
+		return response.NotFound(fmt.Errorf("Image '%s' not found", fingerprint))
+	}
+
+	files, err := imagesMirrorFiles(info)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	for _, file := range files {
+		if file.name != name {
+			continue
+		}
+
+		return response.FileResponse(r, []response.FileResponseEntry{{Identifier: name, Path: file.path, Filename: name}}, nil, false)
+	}
+
+	return response.NotFound(fmt.Errorf("Image file '%s' not found", name))
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// imageUploadExpiry is how long an upload may remain idle before being discarded.
+const imageUploadExpiry = 24 * time.Hour
+
+var imageUploadsCmd = APIEndpoint{
+	Path: "images/uploads",
+
+	Post: APIEndpointAction{Handler: imageUploadsPost, AccessHandler: AllowProjectPermission("images", "manage-images")},
+}
+
+var imageUploadCmd = APIEndpoint{
+	Path: "images/uploads/{id}",
+
+	Delete: APIEndpointAction{Handler: imageUploadDelete, AccessHandler: AllowProjectPermission("images", "manage-images")},
+	Get:    APIEndpointAction{Handler: imageUploadGet, AccessHandler: AllowProjectPermission("images", "manage-images")},
+	Put:    APIEndpointAction{Handler: imageUploadPut, AccessHandler: AllowProjectPermission("images", "manage-images")},
+}
+
+// imageUpload is an image upload in progress, its content is later used as the body of an image
+// creation request.
+type imageUpload struct {
+	mu       sync.Mutex
+	project  string
+	path     string
+	offset   int64
+	lastUsed time.Time
+}
+
+var imageUploadsLock sync.Mutex
+var imageUploads = map[string]*imageUpload{}
+
+// imageUploadLoad returns the upload matching the request's ID and project.
+func imageUploadLoad(r *http.Request) (string, *imageUpload, error) {
+	id := mux.Vars(r)["id"]
+
+	imageUploadsLock.Lock()
+	upload, ok := imageUploads[id]
+	imageUploadsLock.Unlock()
+
+	if !ok || upload.project != projectParam(r) {
+		return "", nil, fmt.Errorf("Image upload %q not found", id)
+	}
+
+	return id, upload, nil
+}
+
+// imageUploadTake removes the upload from the list of uploads in progress and returns the path of
+// its content, the caller becomes responsible for that file.
+func imageUploadTake(id string, project string) (string, error) {
+	imageUploadsLock.Lock()
+	defer imageUploadsLock.Unlock()
+
+	upload, ok := imageUploads[id]
+	if !ok || upload.project != project {
+		return "", fmt.Errorf("Image upload %q not found", id)
+	}
+
+	delete(imageUploads, id)
+
+	return upload.path, nil
+}
+
+// imageUploadsPrune discards the uploads which haven't been used for a while.
+func imageUploadsPrune() {
+	imageUploadsLock.Lock()
+	defer imageUploadsLock.Unlock()
+
+	for id, upload := range imageUploads {
+		if time.Since(upload.lastUsed) < imageUploadExpiry {
+			continue
+		}
+
+		logger.Debugf("Discarding expired image upload %s", id)
+		os.Remove(upload.path)
+		delete(imageUploads, id)
+	}
+}
+
+func imageUploadsPost(d *Daemon, r *http.Request) response.Response {
+	imageUploadsPrune()
+
+	id, err := shared.RandomCryptoString()
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	upload := &imageUpload{
+		project:  projectParam(r),
+		path:     shared.VarPath("images", fmt.Sprintf("lxd_upload_%s", id)),
+		lastUsed: time.Now(),
+	}
+
+	f, err := os.OpenFile(upload.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return response.InternalError(err)
+	}
+	f.Close()
+
+	imageUploadsLock.Lock()
+	imageUploads[id] = upload
+	imageUploadsLock.Unlock()
+
+	return response.SyncResponse(true, api.ImageUpload{ID: id})
+}
+
+func imageUploadGet(d *Daemon, r *http.Request) response.Response {
+	id, upload, err := imageUploadLoad(r)
+	if err != nil {
+		return response.NotFound(err)
+	}
+
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+
+	return response.SyncResponse(true, api.ImageUpload{ID: id, Offset: upload.offset})
+}
+
+// imageUploadPut writes a chunk at the offset given in the query. Anything past that offset is
+// discarded first so a client can resend a chunk which may have been partially received.
+func imageUploadPut(d *Daemon, r *http.Request) response.Response {
+	id, upload, err := imageUploadLoad(r)
+	if err != nil {
+		return response.NotFound(err)
+	}
+
+	offset, err := strconv.ParseInt(r.FormValue("offset"), 10, 64)
+	if err != nil {
+		return response.BadRequest(fmt.Errorf("Invalid offset %q", r.FormValue("offset")))
+	}
+
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+
+	if offset < 0 || offset > upload.offset {
+		return response.BadRequest(fmt.Errorf("Invalid offset %d, %d bytes were received so far", offset, upload.offset))
+	}
+
+	f, err := os.OpenFile(upload.path, os.O_WRONLY, 0)
+	if err != nil {
+		return response.InternalError(err)
+	}
+	defer f.Close()
+
+	err = f.Truncate(offset)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	n, err := io.Copy(f, r.Body)
+	upload.offset = offset + n
+	upload.lastUsed = time.Now()
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return response.SyncResponse(true, api.ImageUpload{ID: id, Offset: upload.offset})
+}
+
+func imageUploadDelete(d *Daemon, r *http.Request) response.Response {
+	id, _, err := imageUploadLoad(r)
+	if err != nil {
+		return response.NotFound(err)
+	}
+
+	path, err := imageUploadTake(id, projectParam(r))
+	if err != nil {
+		return response.NotFound(err)
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+// imageUploadVerify checks the content of a finished upload against the expected SHA256.
+func imageUploadVerify(f *os.File, expected string) error {
+	_, err := f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if checksum != expected {
+		return fmt.Errorf("Image upload checksum mismatch, expected %q but got %q", expected, checksum)
+	}
+
+	return nil
+}
@@ -65,4 +65,16 @@ struct seccomp_notif_sizes {
 						struct seccomp_notif_resp)
 #define SECCOMP_IOCTL_NOTIF_ID_VALID	SECCOMP_IOR(2, __u64)
 #endif
+
+#ifndef SECCOMP_IOCTL_NOTIF_ADDFD
+struct seccomp_notif_addfd {
+	__u64 id;
+	__u32 flags;
+	__u32 srcfd;
+	__u32 newfd;
+	__u32 newfd_flags;
+};
+
+#define SECCOMP_IOCTL_NOTIF_ADDFD	_IOW('!', 3, struct seccomp_notif_addfd)
+#endif
 #endif /* LXD_SECCOMP_H */
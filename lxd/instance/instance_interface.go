@@ -49,6 +49,9 @@ type Instance interface {
 	FilePull(srcpath string, dstpath string) (int64, int64, os.FileMode, string, []string, error)
 	FilePush(fileType string, srcpath string, dstpath string, uid int64, gid int64, mode int, write string) error
 	FileRemove(path string) error
+	FilePullArchive(srcpath string, w io.Writer) error
+	FilePushArchive(r io.Reader, dstpath string) error
+	FileSFTPConn() (io.ReadWriteCloser, error)
 
 	// Console - Allocate and run a console tty.
 	Console() (*os.File, chan error, error)
@@ -58,6 +61,7 @@ type Instance interface {
 	Render() (interface{}, interface{}, error)
 	RenderFull() (*api.InstanceFull, interface{}, error)
 	RenderState() (*api.InstanceState, error)
+	DiskIOStats() (map[string]api.StorageVolumeStateIO, error)
 	IsRunning() bool
 	IsFrozen() bool
 	IsEphemeral() bool
@@ -42,7 +42,7 @@ var Load func(s *state.State, args db.InstanceArgs, profiles []api.Profile) (Ins
 
 // NetworkGetLeaseAddresses is linked from main.networkGetLeaseAddresses to limit scope of moving
 // network related functions into their own package at this time.
-var NetworkGetLeaseAddresses func(s *state.State, network string, hwaddr string) ([]api.InstanceStateNetworkAddress, error)
+var NetworkGetLeaseAddresses func(s *state.State, project string, network string, hwaddr string) ([]api.InstanceStateNetworkAddress, error)
 
 // CompareSnapshots returns a list of snapshots to sync to the target and a list of
 // snapshots to remove from the target. A snapshot will be marked as "to sync" if it either doesn't
@@ -108,6 +108,35 @@ func CompareSnapshots(source Instance, target Instance) ([]Instance, []Instance,
 }
 
 // ValidConfig validates an instance's config.
+// validTasksConfig checks that the tasks.NAME.* keys of each task work together.
+func validTasksConfig(config map[string]string) error {
+	for k, v := range config {
+		fields := strings.Split(k, ".")
+		if len(fields) != 3 || fields[0] != "tasks" || fields[2] != "schedule" || v == "" {
+			continue
+		}
+
+		action := config[fmt.Sprintf("tasks.%s.action", fields[1])]
+		if action == "" {
+			return fmt.Errorf("Task %q is missing tasks.%s.action", fields[1], fields[1])
+		}
+
+		if action == "exec" && config[fmt.Sprintf("tasks.%s.command", fields[1])] == "" {
+			return fmt.Errorf("Task %q is missing tasks.%s.command", fields[1], fields[1])
+		}
+
+		if action != "backup" && config[fmt.Sprintf("tasks.%s.target", fields[1])] != "" {
+			return fmt.Errorf("tasks.%s.target can only be set on backup tasks", fields[1])
+		}
+
+		if action == "exec" && config[fmt.Sprintf("tasks.%s.retain", fields[1])] != "" {
+			return fmt.Errorf("tasks.%s.retain can't be set on exec tasks", fields[1])
+		}
+	}
+
+	return nil
+}
+
 func ValidConfig(sysOS *sys.OS, config map[string]string, profile bool, expanded bool) error {
 	if config == nil {
 		return nil
@@ -129,17 +158,17 @@ func ValidConfig(sysOS *sys.OS, config map[string]string, profile bool, expanded
 	}
 
 	_, rawSeccomp := config["raw.seccomp"]
-	_, whitelist := config["security.syscalls.whitelist"]
-	_, blacklist := config["security.syscalls.blacklist"]
-	blacklistDefault := shared.IsTrue(config["security.syscalls.blacklist_default"])
-	blacklistCompat := shared.IsTrue(config["security.syscalls.blacklist_compat"])
+	_, allow := config["security.syscalls.allow"]
+	_, deny := config["security.syscalls.deny"]
+	denyDefault := shared.IsTrue(config["security.syscalls.deny_default"])
+	denyCompat := shared.IsTrue(config["security.syscalls.deny_compat"])
 
-	if rawSeccomp && (whitelist || blacklist || blacklistDefault || blacklistCompat) {
+	if rawSeccomp && (allow || deny || denyDefault || denyCompat) {
 		return fmt.Errorf("raw.seccomp is mutually exclusive with security.syscalls*")
 	}
 
-	if whitelist && (blacklist || blacklistDefault || blacklistCompat) {
-		return fmt.Errorf("security.syscalls.whitelist is mutually exclusive with security.syscalls.blacklist*")
+	if allow && (deny || denyDefault || denyCompat) {
+		return fmt.Errorf("security.syscalls.allow is mutually exclusive with security.syscalls.deny*")
 	}
 
 	_, err := seccomp.SyscallInterceptMountFilter(config)
@@ -147,6 +176,13 @@ func ValidConfig(sysOS *sys.OS, config map[string]string, profile bool, expanded
 		return err
 	}
 
+	if expanded {
+		err = validTasksConfig(config)
+		if err != nil {
+			return err
+		}
+	}
+
 	if expanded && (config["security.privileged"] == "" || !shared.IsTrue(config["security.privileged"])) && sysOS.IdmapSet == nil {
 		return fmt.Errorf("LXD doesn't have a uid/gid allocation. In this mode, only privileged containers are supported")
 	}
@@ -168,6 +204,44 @@ func ValidConfig(sysOS *sys.OS, config map[string]string, profile bool, expanded
 	return nil
 }
 
+// ValidBootAfter checks that the boot.after dependencies of an instance, with the given expanded
+// config, don't form a cycle. Dependencies which don't exist (yet) are ignored.
+func ValidBootAfter(s *state.State, project string, name string, config map[string]string) error {
+	var visit func(current string, deps []string, chain []string) error
+	visit = func(current string, deps []string, chain []string) error {
+		chain = append(chain, current)
+
+		for _, dep := range deps {
+			if dep == name {
+				return fmt.Errorf("Dependency cycle in boot.after: %s", strings.Join(append(chain, dep), " -> "))
+			}
+
+			if shared.StringInSlice(dep, chain) {
+				// A cycle not involving this instance, it's reported when changing one of its members.
+				continue
+			}
+
+			inst, err := LoadByProjectAndName(s, project, dep)
+			if err != nil {
+				if errors.Cause(err) == db.ErrNoSuchObject {
+					continue
+				}
+
+				return err
+			}
+
+			err = visit(dep, shared.InstanceBootAfter(inst.ExpandedConfig()["boot.after"]), chain)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return visit(name, shared.InstanceBootAfter(config["boot.after"]), nil)
+}
+
 func validConfigKey(os *sys.OS, key string, value string) error {
 	f, err := shared.ConfigKeyChecker(key)
 	if err != nil {
@@ -179,7 +253,7 @@ func validConfigKey(os *sys.OS, key string, value string) error {
 	if key == "raw.lxc" {
 		return lxcValidConfig(value)
 	}
-	if key == "security.syscalls.blacklist_compat" {
+	if key == "security.syscalls.deny_compat" {
 		for _, arch := range os.Architectures {
 			if arch == osarch.ARCH_64BIT_INTEL_X86 ||
 				arch == osarch.ARCH_64BIT_ARMV8_LITTLE_ENDIAN ||
@@ -187,7 +261,7 @@ func validConfigKey(os *sys.OS, key string, value string) error {
 				return nil
 			}
 		}
-		return fmt.Errorf("security.syscalls.blacklist_compat isn't supported on this architecture")
+		return fmt.Errorf("security.syscalls.deny_compat isn't supported on this architecture")
 	}
 	return nil
 }
@@ -0,0 +1,209 @@
+package qemu
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// qemuConfEntry is a key and its unquoted value in a qemu config section.
+type qemuConfEntry struct {
+	key   string
+	value string
+}
+
+// qemuConfSection is a section of a qemu config file along with the comments preceding it.
+type qemuConfSection struct {
+	comments []string
+	name     string
+	entries  []qemuConfEntry
+}
+
+// qemuConfSectionHeader matches section headers, optionally followed by the section's index
+// among those with the same name (e.g. `[numa][1]`).
+var qemuConfSectionHeader = regexp.MustCompile(`^\[([^\]]+)\](?:\[(\d+)\])?$`)
+
+// parseQemuConf parses a qemu config file. Indexes apply to the section headers of patches only
+// and are returned alongside the sections (-1 when missing).
+func parseQemuConf(content string) ([]qemuConfSection, []int, error) {
+	sections := []qemuConfSection{}
+	indexes := []int{}
+	comments := []string{}
+
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			comments = append(comments, line)
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			match := qemuConfSectionHeader.FindStringSubmatch(line)
+			if match == nil {
+				return nil, nil, fmt.Errorf("Invalid section header on line %d: %s", i+1, line)
+			}
+
+			index := -1
+			if match[2] != "" {
+				index, _ = strconv.Atoi(match[2])
+			}
+
+			sections = append(sections, qemuConfSection{comments: comments, name: strings.TrimSpace(match[1])})
+			indexes = append(indexes, index)
+			comments = []string{}
+			continue
+		}
+
+		if len(sections) == 0 {
+			return nil, nil, fmt.Errorf("Key outside of a section on line %d: %s", i+1, line)
+		}
+
+		fields := strings.SplitN(line, "=", 2)
+		if len(fields) != 2 || strings.TrimSpace(fields[0]) == "" {
+			return nil, nil, fmt.Errorf("Invalid key on line %d: %s", i+1, line)
+		}
+
+		value := strings.TrimSpace(fields[1])
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = value[1 : len(value)-1]
+		}
+
+		section := &sections[len(sections)-1]
+		section.entries = append(section.entries, qemuConfEntry{key: strings.TrimSpace(fields[0]), value: value})
+	}
+
+	return sections, indexes, nil
+}
+
+// renderQemuConf writes sections back in the qemu config file format.
+func renderQemuConf(sections []qemuConfSection) string {
+	sb := &strings.Builder{}
+
+	for _, section := range sections {
+		sb.WriteString("\n")
+		for _, comment := range section.comments {
+			sb.WriteString(comment + "\n")
+		}
+
+		sb.WriteString(fmt.Sprintf("[%s]\n", section.name))
+		for _, entry := range section.entries {
+			sb.WriteString(fmt.Sprintf("%s = \"%s\"\n", entry.key, entry.value))
+		}
+	}
+
+	return sb.String()
+}
+
+// qemuRawCfgOverride applies the raw.qemu.conf patch to the generated qemu config.
+//
+// Each section of the patch applies to the generated section with the same name, or to the one
+// at the given index when several share the name (e.g. `[numa][1]`). Its keys override the
+// generated ones and keys with an empty value are removed. A section without any key removes the
+// generated section. Sections which don't exist yet are appended.
+func qemuRawCfgOverride(conf string, rawConf string) (string, error) {
+	if strings.TrimSpace(rawConf) == "" {
+		return conf, nil
+	}
+
+	sections, _, err := parseQemuConf(conf)
+	if err != nil {
+		return "", err
+	}
+
+	patches, indexes, err := parseQemuConf(rawConf)
+	if err != nil {
+		return "", fmt.Errorf("Invalid raw.qemu.conf: %v", err)
+	}
+
+	// Look for the section a patch applies to.
+	find := func(name string, index int) int {
+		count := 0
+		for i, section := range sections {
+			if section.name != name {
+				continue
+			}
+
+			if index < 0 || count == index {
+				return i
+			}
+
+			count++
+		}
+
+		return -1
+	}
+
+	removed := map[int]bool{}
+	for i, patch := range patches {
+		target := find(patch.name, indexes[i])
+
+		if len(patch.entries) == 0 {
+			if target < 0 {
+				return "", fmt.Errorf("Invalid raw.qemu.conf: Can't remove unknown section %q", patch.name)
+			}
+
+			removed[target] = true
+			continue
+		}
+
+		if target < 0 {
+			// New section.
+			section := qemuConfSection{comments: patch.comments, name: patch.name}
+			for _, entry := range patch.entries {
+				if entry.value != "" {
+					section.entries = append(section.entries, entry)
+				}
+			}
+
+			sections = append(sections, section)
+			continue
+		}
+
+		for _, entry := range patch.entries {
+			entries := []qemuConfEntry{}
+			found := false
+			for _, existing := range sections[target].entries {
+				if existing.key != entry.key {
+					entries = append(entries, existing)
+					continue
+				}
+
+				found = true
+				if entry.value != "" {
+					entries = append(entries, entry)
+				}
+			}
+
+			if !found && entry.value != "" {
+				entries = append(entries, entry)
+			}
+
+			sections[target].entries = entries
+		}
+	}
+
+	result := []qemuConfSection{}
+	for i, section := range sections {
+		if !removed[i] {
+			result = append(result, section)
+		}
+	}
+
+	return renderQemuConf(result), nil
+}
+
+// validateRawQemuConf checks the syntax of a raw.qemu.conf patch.
+func validateRawQemuConf(rawConf string) error {
+	_, _, err := parseQemuConf(rawConf)
+	if err != nil {
+		return fmt.Errorf("Invalid raw.qemu.conf: %v", err)
+	}
+
+	return nil
+}
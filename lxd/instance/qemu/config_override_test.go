@@ -0,0 +1,156 @@
+package qemu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const qemuConfBase = `
+# Machine
+[machine]
+graphics = "off"
+type = "q35"
+
+# Memory
+[memory]
+size = "1073741824B"
+
+[numa]
+type = "node"
+nodeid = "0"
+
+[numa]
+type = "node"
+nodeid = "1"
+
+[device "dev-lxd_root"]
+driver = "scsi-hd"
+drive = "lxd_root"
+`
+
+func TestQemuRawCfgOverride(t *testing.T) {
+	tests := []struct {
+		name     string
+		patch    string
+		expected string
+	}{
+		{
+			"Empty patch",
+			"",
+			qemuConfBase,
+		},
+		{
+			"Override and add keys",
+			`[memory]
+size = "4294967296B"
+maxmem = "8G"`,
+			`
+# Machine
+[machine]
+graphics = "off"
+type = "q35"
+
+# Memory
+[memory]
+size = "4294967296B"
+maxmem = "8G"
+
+[numa]
+type = "node"
+nodeid = "0"
+
+[numa]
+type = "node"
+nodeid = "1"
+
+[device "dev-lxd_root"]
+driver = "scsi-hd"
+drive = "lxd_root"
+`,
+		},
+		{
+			"Remove a key and a section",
+			`[machine]
+graphics = ""
+
+[device "dev-lxd_root"]`,
+			`
+# Machine
+[machine]
+type = "q35"
+
+# Memory
+[memory]
+size = "1073741824B"
+
+[numa]
+type = "node"
+nodeid = "0"
+
+[numa]
+type = "node"
+nodeid = "1"
+`,
+		},
+		{
+			"Indexed section and new section",
+			`[numa][1]
+nodeid = "3"
+
+# RNG
+[object "rng0"]
+qom-type = "rng-random"`,
+			`
+# Machine
+[machine]
+graphics = "off"
+type = "q35"
+
+# Memory
+[memory]
+size = "1073741824B"
+
+[numa]
+type = "node"
+nodeid = "0"
+
+[numa]
+type = "node"
+nodeid = "3"
+
+[device "dev-lxd_root"]
+driver = "scsi-hd"
+drive = "lxd_root"
+
+# RNG
+[object "rng0"]
+qom-type = "rng-random"
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := qemuRawCfgOverride(qemuConfBase, test.patch)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, conf)
+		})
+	}
+}
+
+func TestQemuRawCfgOverride_Invalid(t *testing.T) {
+	patches := []string{
+		`size = "1G"`,
+		`[memory`,
+		`[memory]
+size`,
+		`[unknown]`,
+	}
+
+	for _, patch := range patches {
+		_, err := qemuRawCfgOverride(qemuConfBase, patch)
+		assert.Error(t, err, patch)
+	}
+}
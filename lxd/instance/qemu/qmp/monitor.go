@@ -282,3 +282,217 @@ func (m *Monitor) Quit() error {
 func (m *Monitor) AgentReady() bool {
 	return m.agentReady
 }
+
+// GetCPUs fetches the host thread IDs of the virtualised CPUs, ordered by CPU index.
+func (m *Monitor) GetCPUs() ([]int, error) {
+	// Check if disconnected
+	if m.disconnected {
+		return nil, ErrMonitorDisconnect
+	}
+
+	// Query the CPUs.
+	respRaw, err := m.qmp.Run([]byte("{'execute': 'query-cpus-fast'}"))
+	if err != nil {
+		m.Disconnect()
+		return nil, ErrMonitorDisconnect
+	}
+
+	// Process the response.
+	var respDecoded struct {
+		Return []struct {
+			CPUIndex int `json:"cpu-index"`
+			ThreadID int `json:"thread-id"`
+		} `json:"return"`
+	}
+
+	err = json.Unmarshal(respRaw, &respDecoded)
+	if err != nil {
+		return nil, ErrMonitorBadReturn
+	}
+
+	pids := make([]int, len(respDecoded.Return))
+	for _, cpu := range respDecoded.Return {
+		if cpu.CPUIndex < 0 || cpu.CPUIndex >= len(pids) {
+			return nil, ErrMonitorBadReturn
+		}
+
+		pids[cpu.CPUIndex] = cpu.ThreadID
+	}
+
+	return pids, nil
+}
+
+// BlockStats represents the IO counters of a drive.
+type BlockStats struct {
+	ReadBytes  uint64 `json:"rd_bytes"`
+	ReadOps    uint64 `json:"rd_operations"`
+	WriteBytes uint64 `json:"wr_bytes"`
+	WriteOps   uint64 `json:"wr_operations"`
+}
+
+// GetBlockStats fetches the IO counters of the drives, keyed by drive name.
+func (m *Monitor) GetBlockStats() (map[string]BlockStats, error) {
+	// Check if disconnected
+	if m.disconnected {
+		return nil, ErrMonitorDisconnect
+	}
+
+	// Query the drives.
+	respRaw, err := m.qmp.Run([]byte("{'execute': 'query-blockstats'}"))
+	if err != nil {
+		m.Disconnect()
+		return nil, ErrMonitorDisconnect
+	}
+
+	// Process the response.
+	var respDecoded struct {
+		Return []struct {
+			Device string     `json:"device"`
+			Stats  BlockStats `json:"stats"`
+		} `json:"return"`
+	}
+
+	err = json.Unmarshal(respRaw, &respDecoded)
+	if err != nil {
+		return nil, ErrMonitorBadReturn
+	}
+
+	stats := map[string]BlockStats{}
+	for _, drive := range respDecoded.Return {
+		if drive.Device == "" {
+			continue
+		}
+
+		stats[drive.Device] = drive.Stats
+	}
+
+	return stats, nil
+}
+
+// SendFile passes a file descriptor to QEMU, to be referenced by name in later commands.
+func (m *Monitor) SendFile(name string, file *os.File) error {
+	// Check if disconnected
+	if m.disconnected {
+		return ErrMonitorDisconnect
+	}
+
+	_, err := m.qmp.RunWithFile([]byte(fmt.Sprintf("{'execute': 'getfd', 'arguments': {'fdname': '%s'}}", name)), file)
+	if err != nil {
+		m.Disconnect()
+		return ErrMonitorDisconnect
+	}
+
+	return nil
+}
+
+// SetMigrationParameters sets the bandwidth cap (in bytes per second) and the target downtime (in
+// milliseconds) used by the following migrations, a zero value leaves the QEMU default in place.
+func (m *Monitor) SetMigrationParameters(maxBandwidth uint64, downtimeLimit uint32) error {
+	// Check if disconnected
+	if m.disconnected {
+		return ErrMonitorDisconnect
+	}
+
+	args := map[string]interface{}{}
+	if maxBandwidth > 0 {
+		args["max-bandwidth"] = maxBandwidth
+	}
+
+	if downtimeLimit > 0 {
+		args["downtime-limit"] = downtimeLimit
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	req, err := json.Marshal(map[string]interface{}{"execute": "migrate-set-parameters", "arguments": args})
+	if err != nil {
+		return err
+	}
+
+	_, err = m.qmp.Run(req)
+	if err != nil {
+		m.Disconnect()
+		return ErrMonitorDisconnect
+	}
+
+	return nil
+}
+
+// Migrate sends the VM's state to the URI and waits for the transfer to complete.
+func (m *Monitor) Migrate(uri string) error {
+	// Check if disconnected
+	if m.disconnected {
+		return ErrMonitorDisconnect
+	}
+
+	_, err := m.qmp.Run([]byte(fmt.Sprintf("{'execute': 'migrate', 'arguments': {'uri': '%s'}}", uri)))
+	if err != nil {
+		m.Disconnect()
+		return ErrMonitorDisconnect
+	}
+
+	// Wait for the migration to end.
+	for {
+		respRaw, err := m.qmp.Run([]byte("{'execute': 'query-migrate'}"))
+		if err != nil {
+			m.Disconnect()
+			return ErrMonitorDisconnect
+		}
+
+		var respDecoded struct {
+			Return struct {
+				Status    string `json:"status"`
+				ErrorDesc string `json:"error-desc"`
+			} `json:"return"`
+		}
+
+		err = json.Unmarshal(respRaw, &respDecoded)
+		if err != nil {
+			return ErrMonitorBadReturn
+		}
+
+		switch respDecoded.Return.Status {
+		case "completed":
+			return nil
+		case "failed", "cancelled":
+			if respDecoded.Return.ErrorDesc != "" {
+				return fmt.Errorf("Migration %s: %s", respDecoded.Return.Status, respDecoded.Return.ErrorDesc)
+			}
+
+			return fmt.Errorf("Migration %s", respDecoded.Return.Status)
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// MigrateIncoming loads the VM's state from the URI and waits for the transfer to complete.
+// QEMU must have been started with "-incoming defer".
+func (m *Monitor) MigrateIncoming(uri string) error {
+	// Check if disconnected
+	if m.disconnected {
+		return ErrMonitorDisconnect
+	}
+
+	_, err := m.qmp.Run([]byte(fmt.Sprintf("{'execute': 'migrate-incoming', 'arguments': {'uri': '%s'}}", uri)))
+	if err != nil {
+		m.Disconnect()
+		return ErrMonitorDisconnect
+	}
+
+	// Wait for the migration to end, QEMU exits if it fails.
+	for {
+		status, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		if status != "inmigrate" {
+			return nil
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+}
@@ -2,7 +2,9 @@ package qemu
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -11,18 +13,23 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/flosch/pongo2"
 	"github.com/gorilla/websocket"
 	"github.com/pborman/uuid"
 	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
+	yaml "gopkg.in/yaml.v2"
 
 	lxdClient "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/apparmor"
 	"github.com/lxc/lxd/lxd/backup"
+	"github.com/lxc/lxd/lxd/cgroup"
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/db/query"
@@ -34,9 +41,11 @@ import (
 	"github.com/lxc/lxd/lxd/maas"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/resources"
 	"github.com/lxc/lxd/lxd/state"
 	storagePools "github.com/lxc/lxd/lxd/storage"
 	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
+	"github.com/lxc/lxd/lxd/template"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/lxd/vsock"
 	"github.com/lxc/lxd/shared"
@@ -184,6 +193,12 @@ func Create(s *state.State, args db.InstanceArgs) (instance.Instance, error) {
 		return nil, err
 	}
 
+	err = validateRawQemuConf(vm.expandedConfig["raw.qemu.conf"])
+	if err != nil {
+		logger.Error("Failed creating instance", ctxMap)
+		return nil, err
+	}
+
 	err = instance.ValidDevices(s, s.Cluster, vm.Type(), vm.Name(), vm.expandedDevices, true)
 	if err != nil {
 		logger.Error("Failed creating instance", ctxMap)
@@ -216,7 +231,7 @@ func Create(s *state.State, args db.InstanceArgs) (instance.Instance, error) {
 	}
 
 	// Create a new database entry for the instance's storage volume.
-	_, err = s.Cluster.StoragePoolVolumeCreate(args.Project, args.Name, "", db.StoragePoolVolumeTypeVM, false, poolID, volumeConfig)
+	_, err = s.Cluster.StoragePoolVolumeCreate(args.Project, args.Name, "", db.StoragePoolVolumeTypeVM, false, poolID, volumeConfig, db.StoragePoolVolumeContentTypeBlock)
 	if err != nil {
 		return nil, err
 	}
@@ -462,6 +477,13 @@ func (vm *Qemu) OnStop(target string) error {
 	os.Remove(vm.getMonitorPath())
 	vm.unmount()
 
+	if vm.apparmorEnabled() {
+		apparmor.UnloadQemuProfile(vm)
+	}
+
+	// Let the scheduler reclaim the CPUs the VM was pinned to.
+	cgroup.TaskSchedulerTrigger("virtual-machine", vm.name, "stopped")
+
 	// Record power state
 	err := vm.state.Cluster.ContainerSetState(vm.id, "STOPPED")
 	if err != nil {
@@ -542,17 +564,43 @@ func (vm *Qemu) Start(stateful bool) error {
 		return fmt.Errorf("The instance is already running")
 	}
 
+	if stateful && !vm.stateful {
+		return fmt.Errorf("Instance has no existing state to restore")
+	}
+
 	// Mount the instance's config volume.
 	_, err = vm.mount()
 	if err != nil {
 		return err
 	}
 
+	// A stateless start discards any saved state.
+	if !stateful && vm.stateful {
+		err = os.RemoveAll(vm.StatePath())
+		if err != nil {
+			return err
+		}
+
+		vm.stateful = false
+		err = vm.state.Cluster.ContainerSetStateful(vm.id, false)
+		if err != nil {
+			return errors.Wrap(err, "Persist stateful flag")
+		}
+	}
+
 	err = vm.generateConfigShare()
 	if err != nil {
 		return err
 	}
 
+	// The 9p config share prevents saving the VM's state, use a read-only image instead.
+	if shared.IsTrue(vm.expandedConfig["migration.stateful"]) {
+		err = vm.generateConfigDriveImage()
+		if err != nil {
+			return err
+		}
+	}
+
 	err = os.MkdirAll(vm.LogPath(), 0700)
 	if err != nil {
 		return err
@@ -613,7 +661,7 @@ func (vm *Qemu) Start(stateful bool) error {
 	}
 
 	// Check qemu is installed.
-	_, err = exec.LookPath(qemuBinary)
+	qemuPath, err := exec.LookPath(qemuBinary)
 	if err != nil {
 		return err
 	}
@@ -634,6 +682,11 @@ func (vm *Qemu) Start(stateful bool) error {
 		"-chroot", vm.Path(),
 	}
 
+	// Wait for the saved state to be sent through the monitor.
+	if stateful {
+		args = append(args, "-incoming", "defer")
+	}
+
 	// Attempt to drop privileges.
 	if vm.state.OS.UnprivUser != "" {
 		args = append(args, "-runas", vm.state.OS.UnprivUser)
@@ -661,10 +714,13 @@ func (vm *Qemu) Start(stateful bool) error {
 		if err != nil {
 			return err
 		}
-	}
 
-	if shared.IsTrue(vm.expandedConfig["limits.memory.hugepages"]) {
-		args = append(args, "-mem-path", "/dev/hugepages/", "-mem-prealloc")
+		if shared.PathExists(vm.configDriveImagePath()) {
+			err = os.Chown(vm.configDriveImagePath(), vm.state.OS.UnprivUID, -1)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	if vm.expandedConfig["raw.qemu"] != "" {
@@ -672,7 +728,28 @@ func (vm *Qemu) Start(stateful bool) error {
 		args = append(args, fields...)
 	}
 
-	_, err = shared.RunCommand(qemuBinary, args...)
+	// Confine qemu through AppArmor.
+	if vm.apparmorEnabled() {
+		aaExecPath, err := exec.LookPath("aa-exec")
+		if err != nil {
+			logger.Warn("Starting without AppArmor confinement as aa-exec is missing", log.Ctx{"project": vm.Project(), "instance": vm.Name()})
+		} else {
+			paths, err := vm.apparmorPaths(qemuPath, devConfs)
+			if err != nil {
+				return err
+			}
+
+			err = apparmor.LoadQemuProfile(vm, paths)
+			if err != nil {
+				return err
+			}
+
+			args = append([]string{"-p", apparmor.ProfileFull(vm), "--", qemuPath}, args...)
+			qemuPath = aaExecPath
+		}
+	}
+
+	_, err = shared.RunCommand(qemuPath, args...)
 	if err != nil {
 		return err
 	}
@@ -683,12 +760,38 @@ func (vm *Qemu) Start(stateful bool) error {
 		return err
 	}
 
+	// Bind the virtualised CPUs to their host CPUs.
+	err = vm.setCPUPinning(monitor)
+	if err != nil {
+		monitor.Quit()
+		return err
+	}
+
+	// Restore the saved state.
+	if stateful {
+		err = vm.restoreState(monitor)
+		if err != nil {
+			monitor.Quit()
+			return errors.Wrap(err, "Failed to restore the instance's state")
+		}
+	}
+
 	// Start the VM.
 	err = monitor.Start()
 	if err != nil {
 		return err
 	}
 
+	if stateful {
+		os.RemoveAll(vm.StatePath())
+		vm.stateful = false
+
+		err = vm.state.Cluster.ContainerSetStateful(vm.id, false)
+		if err != nil {
+			return errors.Wrap(err, "Persist stateful flag")
+		}
+	}
+
 	// Database updates
 	err = vm.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
 		// Record current state
@@ -709,15 +812,61 @@ func (vm *Qemu) Start(stateful bool) error {
 		return err
 	}
 
+	// Let the scheduler account for the CPUs the VM is pinned to.
+	cgroup.TaskSchedulerTrigger("virtual-machine", vm.name, "started")
+
 	return nil
 }
 
-func (vm *Qemu) setupNvram() error {
-	srcOvmfFile := filepath.Join(vm.ovmfPath(), "OVMF_VARS.fd")
+// apparmorEnabled returns whether qemu should be confined through AppArmor.
+func (vm *Qemu) apparmorEnabled() bool {
+	if !vm.state.OS.AppArmorAdmin {
+		return false
+	}
+
+	return vm.expandedConfig["security.apparmor"] == "" || shared.IsTrue(vm.expandedConfig["security.apparmor"])
+}
+
+// apparmorPaths returns the paths outside of the instance's directories which qemu needs to reach.
+func (vm *Qemu) apparmorPaths(qemuPath string, devConfs []*deviceConfig.RunConfig) (apparmor.QemuPaths, error) {
+	paths := apparmor.QemuPaths{
+		Binary:   qemuPath,
+		Firmware: vm.ovmfPath(),
+	}
+
+	pool, err := vm.getStoragePool()
+	if err != nil {
+		return paths, err
+	}
+
+	rootDrivePath, err := pool.GetInstanceDisk(vm)
+	if err != nil {
+		return paths, err
+	}
+
+	paths.Disks = append(paths.Disks, rootDrivePath)
+
+	for _, runConf := range devConfs {
+		for _, mount := range runConf.Mounts {
+			paths.Disks = append(paths.Disks, mount.DevPath)
+		}
+	}
+
+	return paths, nil
+}
+
+// nvramTemplatePath returns the path of the default UEFI variable store, which has the Microsoft
+// Secure Boot keys enrolled when security.secureboot is enabled.
+func (vm *Qemu) nvramTemplatePath() string {
 	if vm.expandedConfig["security.secureboot"] == "" || shared.IsTrue(vm.expandedConfig["security.secureboot"]) {
-		srcOvmfFile = filepath.Join(vm.ovmfPath(), "OVMF_VARS.ms.fd")
+		return filepath.Join(vm.ovmfPath(), "OVMF_VARS.ms.fd")
 	}
 
+	return filepath.Join(vm.ovmfPath(), "OVMF_VARS.fd")
+}
+
+func (vm *Qemu) setupNvram() error {
+	srcOvmfFile := vm.nvramTemplatePath()
 	if !shared.PathExists(srcOvmfFile) {
 		return fmt.Errorf("Required EFI firmware settings file missing: %s", srcOvmfFile)
 	}
@@ -731,6 +880,146 @@ func (vm *Qemu) setupNvram() error {
 	return nil
 }
 
+// UEFIVarsPath returns the path of the UEFI variable store of the VM, setting it up from the
+// defaults if missing.
+func (vm *Qemu) UEFIVarsPath() (string, error) {
+	if !shared.PathExists(vm.getNvramPath()) {
+		err := vm.setupNvram()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return vm.getNvramPath(), nil
+}
+
+// ResetUEFIVars replaces the UEFI variable store of the stopped VM with the defaults.
+func (vm *Qemu) ResetUEFIVars() error {
+	if vm.IsRunning() {
+		return fmt.Errorf("The UEFI variables can only be reset when the instance is stopped")
+	}
+
+	return vm.setupNvram()
+}
+
+// ImportUEFIVars replaces the UEFI variable store of the stopped VM with the content read from r,
+// which must be a variable store of the same size as the defaults.
+func (vm *Qemu) ImportUEFIVars(r io.Reader) error {
+	if vm.IsRunning() {
+		return fmt.Errorf("The UEFI variables can only be replaced when the instance is stopped")
+	}
+
+	fi, err := os.Stat(vm.nvramTemplatePath())
+	if err != nil {
+		return fmt.Errorf("Required EFI firmware settings file missing: %s", vm.nvramTemplatePath())
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(r, fi.Size()+1))
+	if err != nil {
+		return err
+	}
+
+	// The store is a firmware volume, identified by its "_FVH" signature.
+	if int64(len(content)) != fi.Size() || string(content[40:44]) != "_FVH" {
+		return fmt.Errorf("Invalid UEFI variable store (expected a %d bytes firmware volume)", fi.Size())
+	}
+
+	tmpPath := vm.getNvramPath() + ".new"
+	err = ioutil.WriteFile(tmpPath, content, 0640)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, vm.getNvramPath())
+}
+
+// EnrollUEFIKeys replaces the UEFI variable store of the stopped VM with one holding only the given
+// Secure Boot keys (PEM encoded certificates), with Secure Boot enforced.
+func (vm *Qemu) EnrollUEFIKeys(pk string, kek []string, db []string) error {
+	if vm.IsRunning() {
+		return fmt.Errorf("Secure Boot keys can only be enrolled when the instance is stopped")
+	}
+
+	if vm.expandedConfig["security.secureboot"] != "" && !shared.IsTrue(vm.expandedConfig["security.secureboot"]) {
+		return fmt.Errorf("Secure Boot keys can only be enrolled when security.secureboot is enabled")
+	}
+
+	if pk == "" || len(kek) == 0 {
+		return fmt.Errorf("A platform key and at least one key exchange key are required")
+	}
+
+	_, err := exec.LookPath("virt-fw-vars")
+	if err != nil {
+		return fmt.Errorf("The virt-fw-vars tool is required to enroll Secure Boot keys")
+	}
+
+	// Start from the variable store without any keys enrolled.
+	srcOvmfFile := filepath.Join(vm.ovmfPath(), "OVMF_VARS.fd")
+	if !shared.PathExists(srcOvmfFile) {
+		return fmt.Errorf("Required EFI firmware settings file missing: %s", srcOvmfFile)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "lxd_uefi_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// The keys are owned by the VM.
+	owner := vm.localConfig["volatile.vm.uuid"]
+	if owner == "" {
+		owner = uuid.New()
+		err = vm.VolatileSet(map[string]string{"volatile.vm.uuid": owner})
+		if err != nil {
+			return err
+		}
+	}
+
+	args := []string{"--input", srcOvmfFile, "--output", filepath.Join(tmpDir, "qemu.nvram"), "--secure-boot"}
+	addCert := func(option string, name string, cert string) error {
+		block, _ := pem.Decode([]byte(cert))
+		if block == nil || block.Type != "CERTIFICATE" {
+			return fmt.Errorf("Invalid %s certificate", name)
+		}
+
+		certPath := filepath.Join(tmpDir, fmt.Sprintf("%s%d.pem", name, len(args)))
+		err := ioutil.WriteFile(certPath, []byte(cert), 0600)
+		if err != nil {
+			return err
+		}
+
+		args = append(args, option, owner, certPath)
+		return nil
+	}
+
+	err = addCert("--set-pk", "PK", pk)
+	if err != nil {
+		return err
+	}
+
+	for _, cert := range kek {
+		err = addCert("--add-kek", "KEK", cert)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, cert := range db {
+		err = addCert("--add-db", "db", cert)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = shared.RunCommand("virt-fw-vars", args...)
+	if err != nil {
+		return fmt.Errorf("Failed to enroll the Secure Boot keys: %v", err)
+	}
+
+	os.Remove(vm.getNvramPath())
+	return shared.FileCopy(filepath.Join(tmpDir, "qemu.nvram"), vm.getNvramPath())
+}
+
 func (vm *Qemu) qemuArchConfig() (string, string, string, error) {
 	if vm.architecture == osarch.ARCH_64BIT_INTEL_X86 {
 		conf := `
@@ -920,8 +1209,9 @@ func (vm *Qemu) generateConfigShare() error {
 		return err
 	}
 
-	if vm.ExpandedConfig()["user.user-data"] != "" {
-		err = ioutil.WriteFile(filepath.Join(configDrivePath, "cloud-init", "user-data"), []byte(vm.ExpandedConfig()["user.user-data"]), 0400)
+	userData := shared.CloudInitConfig(vm.ExpandedConfig(), "user-data")
+	if userData != "" {
+		err = ioutil.WriteFile(filepath.Join(configDrivePath, "cloud-init", "user-data"), []byte(userData), 0400)
 		if err != nil {
 			return err
 		}
@@ -932,8 +1222,9 @@ func (vm *Qemu) generateConfigShare() error {
 		}
 	}
 
-	if vm.ExpandedConfig()["user.vendor-data"] != "" {
-		err = ioutil.WriteFile(filepath.Join(configDrivePath, "cloud-init", "vendor-data"), []byte(vm.ExpandedConfig()["user.vendor-data"]), 0400)
+	vendorData := shared.CloudInitConfig(vm.ExpandedConfig(), "vendor-data")
+	if vendorData != "" {
+		err = ioutil.WriteFile(filepath.Join(configDrivePath, "cloud-init", "vendor-data"), []byte(vendorData), 0400)
 		if err != nil {
 			return err
 		}
@@ -944,8 +1235,9 @@ func (vm *Qemu) generateConfigShare() error {
 		}
 	}
 
-	if vm.ExpandedConfig()["user.network-config"] != "" {
-		err = ioutil.WriteFile(filepath.Join(configDrivePath, "cloud-init", "network-config"), []byte(vm.ExpandedConfig()["user.network-config"]), 0400)
+	networkConfig := shared.CloudInitConfig(vm.ExpandedConfig(), "network-config")
+	if networkConfig != "" {
+		err = ioutil.WriteFile(filepath.Join(configDrivePath, "cloud-init", "network-config"), []byte(networkConfig), 0400)
 		if err != nil {
 			return err
 		}
@@ -959,6 +1251,25 @@ func (vm *Qemu) generateConfigShare() error {
 		return err
 	}
 
+	// Render the image templates, they are then written into the VM's filesystem by the agent.
+	triggers := []string{}
+	if vm.localConfig["volatile.apply_template"] != "" {
+		triggers = append(triggers, vm.localConfig["volatile.apply_template"])
+	}
+	triggers = append(triggers, "start")
+
+	err = vm.templateApplyNow(triggers, filepath.Join(configDrivePath, "files"))
+	if err != nil {
+		return err
+	}
+
+	if vm.localConfig["volatile.apply_template"] != "" {
+		err = vm.VolatileSet(map[string]string{"volatile.apply_template": ""})
+		if err != nil {
+			return err
+		}
+	}
+
 	// Add the VM agent.
 	path, err := exec.LookPath("lxd-agent")
 	if err != nil {
@@ -1038,7 +1349,7 @@ RemainAfterExit=yes
 ExecStartPre=-/sbin/modprobe 9pnet_virtio
 ExecStartPre=/bin/mkdir -p /run/lxd_config/9p
 ExecStartPre=/bin/chmod 0700 /run/lxd_config/
-ExecStart=/bin/mount -t 9p config /run/lxd_config/9p -o access=0
+ExecStart=/bin/sh -c "/bin/mount -t 9p config /run/lxd_config/9p -o access=0 || /bin/mount -t iso9660 -o ro /dev/disk/by-label/lxd-config /run/lxd_config/9p"
 
 [Install]
 WantedBy=multi-user.target
@@ -1079,11 +1390,41 @@ echo "To start it now, unmount this filesystem and run: systemctl start lxd-agen
 	return nil
 }
 
+// generateConfigDriveImage packs the config share into an ISO image. It replaces the 9p share
+// for stateful virtual machines as QEMU can't save the state of a VM with a mounted 9p share.
+func (vm *Qemu) generateConfigDriveImage() error {
+	mkisofs, err := exec.LookPath("mkisofs")
+	if err != nil {
+		mkisofs, err = exec.LookPath("genisoimage")
+		if err != nil {
+			return fmt.Errorf("Stateful virtual machines require mkisofs or genisoimage")
+		}
+	}
+
+	os.Remove(vm.configDriveImagePath())
+	_, err = shared.RunCommand(mkisofs, "-quiet", "-R", "-V", "lxd-config", "-o", vm.configDriveImagePath(), filepath.Join(vm.Path(), "config"))
+	if err != nil {
+		return errors.Wrap(err, "Failed to generate the config drive image")
+	}
+
+	return nil
+}
+
+// configDriveImagePath returns the path of the config drive image of stateful VMs.
+func (vm *Qemu) configDriveImagePath() string {
+	return filepath.Join(vm.Path(), "config.iso")
+}
+
 // generateQemuConfigFile writes the qemu config file and returns its location.
 // It writes the config file inside the VM's log path.
 func (vm *Qemu) generateQemuConfigFile(qemuType string, qemuConf string, devConfs []*deviceConfig.RunConfig) (string, error) {
 	var sb *strings.Builder = &strings.Builder{}
 
+	// Memory encryption must be enabled on the machine itself.
+	if shared.IsTrue(vm.expandedConfig["security.sev"]) {
+		qemuConf = "memory-encryption = \"qemu_sev\"\n" + qemuConf
+	}
+
 	// Base config. This is common for all VMs and has no variables in it.
 	sb.WriteString(fmt.Sprintf(`
 # Machine
@@ -1171,15 +1512,34 @@ backend = "pty"
 		return "", err
 	}
 
+	err = vm.addNUMAConfig(sb)
+	if err != nil {
+		return "", err
+	}
+
+	err = vm.addSEVConfig(sb)
+	if err != nil {
+		return "", err
+	}
+
 	vm.addFirmwareConfig(sb)
 	vm.addVsockConfig(sb)
 	vm.addMonitorConfig(sb)
 	vm.addConfDriveConfig(sb)
+	vm.addNICPortsConfig(sb)
+
+	bootIndexes, err := vm.deviceBootPriorities()
+	if err != nil {
+		return "", err
+	}
 
+	// Drive index is shared across all devices so that each drive gets a unique SCSI ID.
+	driveIndex := 0
+	gpuIndex := 0
 	for _, runConf := range devConfs {
 		// Add root drive device.
 		if runConf.RootFS.Path != "" {
-			err = vm.addRootDriveConfig(sb)
+			err = vm.addRootDriveConfig(sb, bootIndexes, runConf.RootFS.Opts)
 			if err != nil {
 				return "", err
 			}
@@ -1187,29 +1547,67 @@ backend = "pty"
 
 		// Add drive devices.
 		if len(runConf.Mounts) > 0 {
-			driveIndex := 0
 			for _, drive := range runConf.Mounts {
 				// Increment so index starts at 1, as root drive uses index 0.
 				driveIndex++
 
-				vm.addDriveConfig(sb, driveIndex, drive)
+				err = vm.addDriveConfig(sb, bootIndexes, driveIndex, drive)
+				if err != nil {
+					return "", err
+				}
 			}
 		}
 
 		// Add network device.
 		if len(runConf.NetworkInterface) > 0 {
-			vm.addNetDevConfig(sb, runConf.NetworkInterface)
+			err = vm.addNetDevConfig(sb, bootIndexes, runConf.NetworkInterface)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		// Add GPU device.
+		if len(runConf.GPUDevice) > 0 {
+			vm.addGPUDevConfig(sb, gpuIndex, runConf.GPUDevice)
+			gpuIndex++
+		}
+
+		// Add PCI device, sharing the slots used by GPUs.
+		if len(runConf.PCIDevice) > 0 {
+			vm.addPCIDevConfig(sb, gpuIndex, runConf.PCIDevice)
+			gpuIndex++
 		}
 	}
 
+	// Apply the user's overrides.
+	conf, err := qemuRawCfgOverride(sb.String(), vm.expandedConfig["raw.qemu.conf"])
+	if err != nil {
+		return "", err
+	}
+
 	// Write the config file to disk.
 	configPath := filepath.Join(vm.LogPath(), "qemu.conf")
-	return configPath, ioutil.WriteFile(configPath, []byte(sb.String()), 0640)
+	return configPath, ioutil.WriteFile(configPath, []byte(conf), 0640)
 }
 
 // addMemoryConfig adds the qemu config required for setting the size of the VM's memory.
 func (vm *Qemu) addMemoryConfig(sb *strings.Builder) error {
-	// Configure memory limit.
+	memSizeBytes, err := vm.memorySize()
+	if err != nil {
+		return err
+	}
+
+	sb.WriteString(fmt.Sprintf(`
+# Memory
+[memory]
+size = "%dB"
+`, memSizeBytes))
+
+	return nil
+}
+
+// memorySize returns the size in bytes of the VM's memory configured through limits.memory.
+func (vm *Qemu) memorySize() (int64, error) {
 	memSize := vm.expandedConfig["limits.memory"]
 	if memSize == "" {
 		memSize = "1GiB" // Default to 1GiB if no memory limit specified.
@@ -1217,14 +1615,54 @@ func (vm *Qemu) addMemoryConfig(sb *strings.Builder) error {
 
 	memSizeBytes, err := units.ParseByteSizeString(memSize)
 	if err != nil {
-		return fmt.Errorf("limits.memory invalid: %v", err)
+		return -1, fmt.Errorf("limits.memory invalid: %v", err)
+	}
+
+	return memSizeBytes, nil
+}
+
+// addSEVConfig adds the qemu config required for AMD SEV memory encryption.
+func (vm *Qemu) addSEVConfig(sb *strings.Builder) error {
+	if !shared.IsTrue(vm.expandedConfig["security.sev"]) {
+		return nil
+	}
+
+	if vm.architecture != osarch.ARCH_64BIT_INTEL_X86 {
+		return fmt.Errorf("AMD SEV is only supported on x86_64")
+	}
+
+	sev := resources.GetSEV()
+	if sev == nil || !sev.Supported {
+		return fmt.Errorf("AMD SEV isn't supported by the host")
+	}
+
+	if sev.CBitPosition == 0 {
+		return fmt.Errorf("Couldn't determine the AMD SEV C-bit position (is the cpuid kernel module loaded?)")
+	}
+
+	// Default to the same policy as qemu (debugging disabled).
+	policy := uint64(0x1)
+	if vm.expandedConfig["security.sev.policy"] != "" {
+		var err error
+		policy, err = strconv.ParseUint(vm.expandedConfig["security.sev.policy"], 0, 32)
+		if err != nil {
+			return fmt.Errorf("security.sev.policy invalid: %v", err)
+		}
+	}
+
+	// Bit 2 of the policy requires the guest to run with encrypted state (SEV-ES).
+	if policy&0x4 != 0 && !sev.ES {
+		return fmt.Errorf("AMD SEV-ES isn't supported by the host")
 	}
 
 	sb.WriteString(fmt.Sprintf(`
-# Memory
-[memory]
-size = "%dB"
-`, memSizeBytes))
+# Memory encryption
+[object "qemu_sev"]
+qom-type = "sev-guest"
+cbitpos = "%d"
+reduced-phys-bits = "%d"
+policy = "0x%x"
+`, sev.CBitPosition, sev.ReducedPhysBits, policy))
 
 	return nil
 }
@@ -1255,14 +1693,9 @@ addr = "0x0"
 // addCPUConfig adds the qemu config required for setting the number of virtualised CPUs.
 func (vm *Qemu) addCPUConfig(sb *strings.Builder) error {
 	// Configure CPU limit. TODO add control of sockets, cores and threads.
-	cpus := vm.expandedConfig["limits.cpu"]
-	if cpus == "" {
-		cpus = "1"
-	}
-
-	cpuCount, err := strconv.Atoi(cpus)
+	cpuCount, err := vm.cpuCount()
 	if err != nil {
-		return fmt.Errorf("limits.cpu invalid: %v", err)
+		return err
 	}
 
 	sb.WriteString(fmt.Sprintf(`
@@ -1277,6 +1710,264 @@ cpus = "%d"
 	return nil
 }
 
+// addNUMAConfig adds the qemu config required for the VM's memory backends and NUMA topology.
+// Each host node the virtualised CPUs are placed on gets a matching guest node with an even share
+// of the memory bound to it. Hugepages backed VMs which aren't placed get a single node.
+func (vm *Qemu) addNUMAConfig(sb *strings.Builder) error {
+	hugepages := shared.IsTrue(vm.expandedConfig["limits.memory.hugepages"])
+
+	pins, err := vm.cpuPins()
+	if err != nil {
+		return err
+	}
+
+	if pins == nil && !hugepages {
+		return nil
+	}
+
+	// Host node -1 is used for memory which isn't bound to a host node.
+	type numaNode struct {
+		hostNode int
+		firstCPU int
+		lastCPU  int
+		memSize  int64
+	}
+
+	nodes := []numaNode{}
+	for i, pin := range pins {
+		if len(nodes) > 0 && nodes[len(nodes)-1].hostNode == pin.node {
+			nodes[len(nodes)-1].lastCPU = i
+			continue
+		}
+
+		nodes = append(nodes, numaNode{hostNode: pin.node, firstCPU: i, lastCPU: i})
+	}
+
+	if len(nodes) == 0 {
+		cpuCount, err := vm.cpuCount()
+		if err != nil {
+			return err
+		}
+
+		nodes = append(nodes, numaNode{hostNode: -1, firstCPU: 0, lastCPU: cpuCount - 1})
+	}
+
+	memSizeBytes, err := vm.memorySize()
+	if err != nil {
+		return err
+	}
+
+	// Split the memory in multiples of the page size.
+	pageSize := int64(1024 * 1024)
+	if hugepages {
+		memory, err := resources.GetMemory()
+		if err != nil {
+			return err
+		}
+
+		if memory.HugepagesSize == 0 || memory.HugepagesTotal == 0 {
+			return fmt.Errorf("No hugepages are configured on the host")
+		}
+
+		pageSize = int64(memory.HugepagesSize)
+		if memSizeBytes%pageSize != 0 {
+			return fmt.Errorf("limits.memory must be a multiple of the hugepage size (%s)", units.GetByteSizeString(pageSize, 0))
+		}
+	}
+
+	nodeMemSize := memSizeBytes / int64(len(nodes)) / pageSize * pageSize
+	for i := range nodes {
+		nodes[i].memSize = nodeMemSize
+	}
+	nodes[len(nodes)-1].memSize = memSizeBytes - nodeMemSize*int64(len(nodes)-1)
+
+	if hugepages {
+		needs := map[int]int64{}
+		for _, node := range nodes {
+			needs[node.hostNode] += node.memSize
+		}
+
+		err := checkHugepages(needs)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, node := range nodes {
+		sb.WriteString(fmt.Sprintf(`
+# NUMA node %d
+[object "mem%d"]
+size = "%dB"
+`, i, i, node.memSize))
+
+		if hugepages {
+			sb.WriteString(`qom-type = "memory-backend-file"
+mem-path = "/dev/hugepages"
+prealloc = "on"
+share = "on"
+`)
+		} else {
+			sb.WriteString(`qom-type = "memory-backend-ram"
+`)
+		}
+
+		if node.hostNode >= 0 {
+			sb.WriteString(fmt.Sprintf(`host-nodes = "%d"
+policy = "bind"
+`, node.hostNode))
+		}
+
+		sb.WriteString(fmt.Sprintf(`
+[numa]
+type = "node"
+nodeid = "%d"
+cpus = "%d-%d"
+memdev = "mem%d"
+`, i, node.firstCPU, node.lastCPU, i))
+	}
+
+	return nil
+}
+
+// checkHugepages returns an error when the host doesn't have enough free hugepages for the
+// requested number of bytes on each host NUMA node (-1 for any node).
+func checkHugepages(needs map[int]int64) error {
+	memory, err := resources.GetMemory()
+	if err != nil {
+		return err
+	}
+
+	for hostNode, need := range needs {
+		free := int64(memory.HugepagesTotal - memory.HugepagesUsed)
+		if hostNode >= 0 {
+			free = 0
+			for _, node := range memory.Nodes {
+				if int(node.NUMANode) == hostNode {
+					free = int64(node.HugepagesTotal - node.HugepagesUsed)
+					break
+				}
+			}
+		}
+
+		if need <= free {
+			continue
+		}
+
+		if hostNode >= 0 {
+			return fmt.Errorf("Not enough free hugepages on NUMA node %d (%s needed, %s available)", hostNode, units.GetByteSizeString(need, 2), units.GetByteSizeString(free, 2))
+		}
+
+		return fmt.Errorf("Not enough free hugepages (%s needed, %s available)", units.GetByteSizeString(need, 2), units.GetByteSizeString(free, 2))
+	}
+
+	return nil
+}
+
+// cpuPin is the host placement of a virtualised CPU.
+type cpuPin struct {
+	hostCPUs []int
+	node     int
+}
+
+// cpuPins returns the host placement of each virtualised CPU, grouped by NUMA node. This is nil
+// when neither limits.cpu nor limits.cpu.nodes restrict the VM to specific host CPUs.
+func (vm *Qemu) cpuPins() ([]cpuPin, error) {
+	var nodes []int
+	if vm.expandedConfig["limits.cpu.nodes"] != "" {
+		var err error
+		nodes, err = resources.ParseCpuset(vm.expandedConfig["limits.cpu.nodes"])
+		if err != nil {
+			return nil, fmt.Errorf("limits.cpu.nodes invalid: %v", err)
+		}
+	}
+
+	cpus := vm.expandedConfig["limits.cpu"]
+	if cpus == "" {
+		cpus = "1"
+	}
+
+	// A CPU count spreads the virtualised CPUs evenly over the requested NUMA nodes.
+	cpuCount, err := strconv.Atoi(cpus)
+	if err == nil {
+		if len(nodes) == 0 {
+			return nil, nil
+		}
+
+		pins := make([]cpuPin, 0, cpuCount)
+		for i := 0; i < cpuCount; i++ {
+			node := nodes[i*len(nodes)/cpuCount]
+
+			hostCPUs, err := resources.GetNUMANodeCPUs(node)
+			if err != nil {
+				return nil, err
+			}
+
+			pins = append(pins, cpuPin{hostCPUs: hostCPUs, node: node})
+		}
+
+		return pins, nil
+	}
+
+	// A CPU set binds each virtualised CPU to one of the host CPUs.
+	hostCPUs, err := resources.ParseCpuset(cpus)
+	if err != nil {
+		return nil, fmt.Errorf("limits.cpu invalid: %v", err)
+	}
+
+	pins := make([]cpuPin, 0, len(hostCPUs))
+	for _, id := range hostCPUs {
+		node, err := resources.GetCPUNUMANode(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(nodes) > 0 && !shared.IntInSlice(node, nodes) {
+			return nil, fmt.Errorf("CPU %d isn't on any of the NUMA nodes in limits.cpu.nodes", id)
+		}
+
+		pins = append(pins, cpuPin{hostCPUs: []int{id}, node: node})
+	}
+
+	sort.SliceStable(pins, func(i, j int) bool { return pins[i].node < pins[j].node })
+
+	return pins, nil
+}
+
+// setCPUPinning binds the threads of the virtualised CPUs to their host CPUs.
+func (vm *Qemu) setCPUPinning(monitor *qmp.Monitor) error {
+	pins, err := vm.cpuPins()
+	if err != nil {
+		return err
+	}
+
+	if pins == nil {
+		return nil
+	}
+
+	pids, err := monitor.GetCPUs()
+	if err != nil {
+		return err
+	}
+
+	if len(pids) != len(pins) {
+		return fmt.Errorf("Expected %d virtualised CPUs but QEMU has %d", len(pins), len(pids))
+	}
+
+	for i, pid := range pids {
+		set := unix.CPUSet{}
+		for _, id := range pins[i].hostCPUs {
+			set.Set(id)
+		}
+
+		err := unix.SchedSetaffinity(pid, &set)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to pin virtualised CPU %d", i)
+		}
+	}
+
+	return nil
+}
+
 // addMonitorConfig adds the qemu config required for setting up the host side VM monitor device.
 func (vm *Qemu) addMonitorConfig(sb *strings.Builder) {
 	monitorPath := vm.getMonitorPath()
@@ -1323,6 +2014,23 @@ unit = "1"
 
 // addConfDriveConfig adds the qemu config required for adding the config drive.
 func (vm *Qemu) addConfDriveConfig(sb *strings.Builder) {
+	if shared.IsTrue(vm.expandedConfig["migration.stateful"]) {
+		sb.WriteString(fmt.Sprintf(`
+# Config drive
+[drive "qemu_config"]
+file = "%s"
+format = "raw"
+if = "none"
+readonly = "on"
+
+[device "dev-qemu_config"]
+driver = "virtio-blk-pci"
+drive = "qemu_config"
+`, vm.configDriveImagePath()))
+
+		return
+	}
+
 	// Devices use "qemu_" prefix indicating that this is a internally named device.
 	sb.WriteString(fmt.Sprintf(`
 # Config drive
@@ -1341,13 +2049,115 @@ mount_tag = "config"
 	return
 }
 
+// cpuCount returns the number of virtualised CPUs configured through limits.cpu, either as a
+// count or as a set of host CPUs.
+func (vm *Qemu) cpuCount() (int, error) {
+	cpus := vm.expandedConfig["limits.cpu"]
+	if cpus == "" {
+		cpus = "1"
+	}
+
+	cpuCount, err := strconv.Atoi(cpus)
+	if err != nil {
+		// Pinned to a set of host CPUs, one virtualised CPU each.
+		cpuSet, err := resources.ParseCpuset(cpus)
+		if err != nil {
+			return -1, fmt.Errorf("limits.cpu invalid: %v", err)
+		}
+
+		return len(cpuSet), nil
+	}
+
+	return cpuCount, nil
+}
+
+// driveBus returns the bus requested through the "bus" option of a drive, defaulting to virtio-scsi.
+func driveBus(opts []string) string {
+	for _, opt := range opts {
+		if strings.HasPrefix(opt, "bus=") {
+			return strings.TrimPrefix(opt, "bus=")
+		}
+	}
+
+	return "virtio-scsi"
+}
+
+// deviceBootPriorities returns the boot index of the disk and network devices, keyed by device
+// name. Devices boot in order of decreasing boot.priority. Without one, the root disk boots first,
+// followed by the network devices in the order of their PCIe ports and the other disks.
+func (vm *Qemu) deviceBootPriorities() (map[string]int, error) {
+	rootDevName, _, err := shared.GetRootDiskDevice(vm.expandedDevices.CloneNative())
+	if err != nil {
+		return nil, err
+	}
+
+	type devicePriority struct {
+		name     string
+		priority uint32
+	}
+
+	root := []devicePriority{}
+	nics := []devicePriority{}
+	disks := []devicePriority{}
+	for _, dev := range vm.expandedDevices.Sorted() {
+		if dev.Config["type"] != "disk" && dev.Config["type"] != "nic" {
+			continue
+		}
+
+		// The root disk boots before the devices without a priority.
+		priority := uint32(0)
+		if dev.Name == rootDevName {
+			priority = 1
+		}
+
+		if dev.Config["boot.priority"] != "" {
+			value, err := strconv.ParseUint(dev.Config["boot.priority"], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid boot.priority for device %q: %v", dev.Name, err)
+			}
+
+			priority = uint32(value)
+		}
+
+		entry := devicePriority{name: dev.Name, priority: priority}
+		if dev.Name == rootDevName {
+			root = append(root, entry)
+		} else if dev.Config["type"] == "nic" {
+			nics = append(nics, entry)
+		} else {
+			disks = append(disks, entry)
+		}
+	}
+
+	sort.SliceStable(nics, func(i, j int) bool {
+		return vm.nicPortIndex(nics[i].name) < vm.nicPortIndex(nics[j].name)
+	})
+
+	devices := append(append(root, nics...), disks...)
+	sort.SliceStable(devices, func(i, j int) bool {
+		return devices[i].priority > devices[j].priority
+	})
+
+	bootIndexes := map[string]int{}
+	for i, dev := range devices {
+		bootIndexes[dev.name] = i + 1
+	}
+
+	return bootIndexes, nil
+}
+
 // addRootDriveConfig adds the qemu config required for adding the root drive.
-func (vm *Qemu) addRootDriveConfig(sb *strings.Builder) error {
+func (vm *Qemu) addRootDriveConfig(sb *strings.Builder, bootIndexes map[string]int, opts []string) error {
 	pool, err := vm.getStoragePool()
 	if err != nil {
 		return err
 	}
 
+	rootDevName, _, err := shared.GetRootDiskDevice(vm.expandedDevices.CloneNative())
+	if err != nil {
+		return err
+	}
+
 	rootDrivePath, err := pool.GetInstanceDisk(vm)
 	if err != nil {
 		return err
@@ -1362,7 +2172,13 @@ format = "raw"
 if = "none"
 cache = "none"
 aio = "native"
+`, rootDrivePath))
 
+	if driveBus(opts) == "nvme" {
+		return vm.addNVMeDeviceConfig(sb, 0, "root", bootIndexes[rootDevName])
+	}
+
+	sb.WriteString(fmt.Sprintf(`
 [device "dev-lxd_root"]
 driver = "scsi-hd"
 bus = "qemu_scsi.0"
@@ -1370,14 +2186,14 @@ channel = "0"
 scsi-id = "0"
 lun = "1"
 drive = "lxd_root"
-bootindex = "1"
-`, rootDrivePath))
+bootindex = "%d"
+`, bootIndexes[rootDevName]))
 
 	return nil
 }
 
 // addDriveConfig adds the qemu config required for adding a supplementary drive.
-func (vm *Qemu) addDriveConfig(sb *strings.Builder, driveIndex int, driveConf deviceConfig.MountEntryItem) {
+func (vm *Qemu) addDriveConfig(sb *strings.Builder, bootIndexes map[string]int, driveIndex int, driveConf deviceConfig.MountEntryItem) error {
 	driveName := fmt.Sprintf(driveConf.TargetPath)
 
 	// Devices use "lxd_" prefix indicating that this is a user named device.
@@ -1389,7 +2205,13 @@ format = "raw"
 if = "none"
 cache = "none"
 aio = "native"
+`, driveName, driveName, driveConf.DevPath))
 
+	if driveBus(driveConf.Opts) == "nvme" {
+		return vm.addNVMeDeviceConfig(sb, driveIndex, driveName, bootIndexes[driveName])
+	}
+
+	sb.WriteString(fmt.Sprintf(`
 [device "dev-lxd_%s"]
 driver = "scsi-hd"
 bus = "qemu_scsi.0"
@@ -1397,50 +2219,292 @@ channel = "0"
 scsi-id = "%d"
 lun = "1"
 drive = "lxd_%s"
-`, driveName, driveName, driveConf.DevPath, driveName, driveIndex, driveName))
+bootindex = "%d"
+`, driveName, driveIndex, driveName, bootIndexes[driveName]))
+
+	return nil
+}
+
+// addNVMeDeviceConfig adds the qemu config required for exposing a drive as an emulated NVMe controller.
+// Each controller gets its own slot on the root PCIe bus and one IO queue per virtualised CPU
+// (num_queues also counts the admin queue).
+func (vm *Qemu) addNVMeDeviceConfig(sb *strings.Builder, driveIndex int, driveName string, bootIndex int) error {
+	cpuCount, err := vm.cpuCount()
+	if err != nil {
+		return err
+	}
+
+	sb.WriteString(fmt.Sprintf(`
+[device "dev-lxd_%s"]
+driver = "nvme"
+bus = "pcie.0"
+addr = "0x%x"
+drive = "lxd_%s"
+serial = "lxd_%s"
+num_queues = "%d"
+bootindex = "%d"
+`, driveName, 0x10+driveIndex, driveName, driveName, cpuCount+1, bootIndex))
+
+	return nil
+}
+
+// addGPUDevConfig adds the qemu config required for passing a mediated or physical GPU device
+// through. GPUs get their own slots on the root PCIe bus, below the ones used by NVMe drives. The
+// other functions of a physical card keep their function number in the slot of the GPU.
+func (vm *Qemu) addGPUDevConfig(sb *strings.Builder, gpuIndex int, gpuConfig []deviceConfig.RunConfigItem) {
+	var devName, vgpu, pciSlotName, romfile string
+	pciFunctions := []string{}
+	for _, gpuItem := range gpuConfig {
+		if gpuItem.Key == "devName" {
+			devName = gpuItem.Value
+		} else if gpuItem.Key == "vgpu" {
+			vgpu = gpuItem.Value
+		} else if gpuItem.Key == "pciSlotName" {
+			pciSlotName = gpuItem.Value
+		} else if gpuItem.Key == "pciFunction" {
+			pciFunctions = append(pciFunctions, gpuItem.Value)
+		} else if gpuItem.Key == "romfile" {
+			romfile = gpuItem.Value
+		}
+	}
+
+	// Devices use "lxd_" prefix indicating that this is a user named device.
+	if vgpu != "" {
+		sb.WriteString(fmt.Sprintf(`
+# GPU ("%s" device)
+[device "dev-lxd_%s"]
+driver = "vfio-pci"
+sysfsdev = "/sys/bus/mdev/devices/%s"
+bus = "pcie.0"
+addr = "0x%x"
+`, devName, devName, vgpu, 0x8+gpuIndex))
+
+		return
+	}
+
+	// The host function number is the last digit of the PCI address.
+	pciFunction := func(address string) string {
+		return address[len(address)-1:]
+	}
+
+	// The ROM file is read when the device is realized, before QEMU chroots and drops privileges.
+	sb.WriteString(fmt.Sprintf(`
+# GPU ("%s" device)
+[device "dev-lxd_%s"]
+driver = "vfio-pci"
+host = "%s"
+bus = "pcie.0"
+addr = "0x%x.%s"
+`, devName, devName, pciSlotName, 0x8+gpuIndex, pciFunction(pciSlotName)))
+
+	if len(pciFunctions) > 0 && pciFunction(pciSlotName) == "0" {
+		sb.WriteString(`multifunction = "on"
+`)
+	}
+
+	if romfile != "" {
+		sb.WriteString(fmt.Sprintf(`romfile = "%s"
+`, romfile))
+	}
+
+	for i, address := range pciFunctions {
+		sb.WriteString(fmt.Sprintf(`
+# GPU function ("%s" device)
+[device "dev-lxd_%s-%d"]
+driver = "vfio-pci"
+host = "%s"
+bus = "pcie.0"
+addr = "0x%x.%s"
+`, devName, devName, i+1, address, 0x8+gpuIndex, pciFunction(address)))
+
+		if pciFunction(address) == "0" {
+			sb.WriteString(`multifunction = "on"
+`)
+		}
+	}
+
+	return
+}
+
+// addPCIDevConfig adds the qemu config required for passing a host PCI device through.
+func (vm *Qemu) addPCIDevConfig(sb *strings.Builder, pciIndex int, pciConfig []deviceConfig.RunConfigItem) {
+	var devName, pciSlotName string
+	for _, pciItem := range pciConfig {
+		if pciItem.Key == "devName" {
+			devName = pciItem.Value
+		} else if pciItem.Key == "pciSlotName" {
+			pciSlotName = pciItem.Value
+		}
+	}
+
+	// Devices use "lxd_" prefix indicating that this is a user named device.
+	sb.WriteString(fmt.Sprintf(`
+# PCI ("%s" device)
+[device "dev-lxd_%s"]
+driver = "vfio-pci"
+host = "%s"
+bus = "pcie.0"
+addr = "0x%x"
+`, devName, devName, pciSlotName, 0x8+pciIndex))
 
 	return
 }
 
-// addNetDevConfig adds the qemu config required for adding a network device.
-func (vm *Qemu) addNetDevConfig(sb *strings.Builder, nicConfig []deviceConfig.RunConfigItem) {
-	var devName, devTap, devHwaddr string
+// qemuNICPortsMax is the number of PCIe root ports available to network devices. They use the
+// functions of the root PCIe bus slots left between the internal root ports and the GPUs.
+const qemuNICPortsMax = (0x8-0x2)*8 - 4
+
+// nicPort returns the name, port number, chassis and address on the root PCIe bus of the PCIe
+// root port of the network device at the given index. The first one is the root port historically
+// used by the only network device of virtual machines.
+func (vm *Qemu) nicPort(index int) (string, int, int, string) {
+	port := 0x13 + index
+	if index == 0 {
+		port = 0x11
+	}
+
+	function := 4 + index
+	return fmt.Sprintf("qemu_pcie%d", 5+index), port, 5 + index, fmt.Sprintf("0x%x.0x%x", 0x2+function/8, function%8)
+}
+
+// nicPortIndex returns the PCIe root port index assigned to the network device, -1 if none is.
+func (vm *Qemu) nicPortIndex(devName string) int {
+	value := vm.localConfig[fmt.Sprintf("volatile.%s.pcie_port", devName)]
+	if value == "" {
+		return -1
+	}
+
+	index, err := strconv.Atoi(value)
+	if err != nil || index < 0 || index >= qemuNICPortsMax {
+		return -1
+	}
+
+	return index
+}
+
+// nextNICPortIndex returns the lowest PCIe root port index not assigned to a network device.
+func (vm *Qemu) nextNICPortIndex() (int, error) {
+	used := map[int]bool{}
+	for devName, dev := range vm.expandedDevices {
+		if dev["type"] != "nic" {
+			continue
+		}
+
+		index := vm.nicPortIndex(devName)
+		if index >= 0 {
+			used[index] = true
+		}
+	}
+
+	for index := 0; index < qemuNICPortsMax; index++ {
+		if !used[index] {
+			return index, nil
+		}
+	}
+
+	return -1, fmt.Errorf("Too many network devices, at most %d are supported", qemuNICPortsMax)
+}
+
+// addNICPortsConfig adds the PCIe root ports of the network devices. The ports are created up to
+// the highest index in use so that the PCI bus numbers, which the guest derives its predictable
+// interface names from, don't depend on which other network devices exist.
+func (vm *Qemu) addNICPortsConfig(sb *strings.Builder) {
+	maxIndex := -1
+	for devName, dev := range vm.expandedDevices {
+		if dev["type"] != "nic" {
+			continue
+		}
+
+		index := vm.nicPortIndex(devName)
+		if index > maxIndex {
+			maxIndex = index
+		}
+	}
+
+	for index := 0; index <= maxIndex; index++ {
+		portName, port, chassis, addr := vm.nicPort(index)
+
+		multifunction := ""
+		if strings.HasSuffix(addr, ".0x0") {
+			multifunction = `multifunction = "on"
+`
+		}
+
+		sb.WriteString(fmt.Sprintf(`
+[device "%s"]
+driver = "pcie-root-port"
+port = "0x%x"
+chassis = "%d"
+bus = "pcie.0"
+%saddr = "%s"
+`, portName, port, chassis, multifunction, addr))
+	}
+}
+
+// addNetDevConfig adds the qemu config required for adding a network device. The device is put
+// on its own PCIe root port so that it keeps the same PCI address across reboots.
+func (vm *Qemu) addNetDevConfig(sb *strings.Builder, bootIndexes map[string]int, nicConfig []deviceConfig.RunConfigItem) error {
+	var devName, devTap, devHwaddr, devVhostUserSocket string
 	for _, nicItem := range nicConfig {
-		if nicItem.Key == "name" {
+		if nicItem.Key == "devName" {
 			devName = nicItem.Value
 		} else if nicItem.Key == "link" {
 			devTap = nicItem.Value
 		} else if nicItem.Key == "hwaddr" {
 			devHwaddr = nicItem.Value
+		} else if nicItem.Key == "vhostUserSocket" {
+			devVhostUserSocket = nicItem.Value
 		}
 	}
 
+	index := vm.nicPortIndex(devName)
+	if index < 0 {
+		return fmt.Errorf("No PCIe port assigned to network device %q", devName)
+	}
+
+	portName, _, _, _ := vm.nicPort(index)
+
 	// Devices use "lxd_" prefix indicating that this is a user named device.
-	sb.WriteString(fmt.Sprintf(`
+	if devVhostUserSocket != "" {
+		// The vhost-user backend maps the guest memory, which must be shared hugepages.
+		if !shared.IsTrue(vm.expandedConfig["limits.memory.hugepages"]) {
+			return fmt.Errorf("Network device %q uses vhost-user, which requires limits.memory.hugepages", devName)
+		}
+
+		sb.WriteString(fmt.Sprintf(`
+# Network card ("%s" device)
+[chardev "char-lxd_%s"]
+backend = "socket"
+path = "%s"
+server = "on"
+wait = "off"
+
+[netdev "lxd_%s"]
+type = "vhost-user"
+chardev = "char-lxd_%s"
+`, devName, devName, devVhostUserSocket, devName, devName))
+	} else {
+		sb.WriteString(fmt.Sprintf(`
 # Network card ("%s" device)
 [netdev "lxd_%s"]
 type = "tap"
 ifname = "%s"
 script = "no"
 downscript = "no"
+`, devName, devName, devTap))
+	}
 
-[device "qemu_pcie5"]
-driver = "pcie-root-port"
-port = "0x11"
-chassis = "5"
-bus = "pcie.0"
-addr = "0x2.0x4"
-
-[device "dev-lxd_eth0"]
+	sb.WriteString(fmt.Sprintf(`
+[device "dev-lxd_%s"]
 driver = "virtio-net-pci"
-netdev = "lxd_eth0"
+netdev = "lxd_%s"
 mac = "%s"
-bus = "qemu_pcie5"
+bus = "%s"
 addr = "0x0"
-bootindex = "2""
-`, devName, devName, devTap, devHwaddr))
+bootindex = "%d"
+`, devName, devName, devHwaddr, portName, bootIndexes[devName]))
 
-	return
+	return nil
 }
 
 // pidFilePath returns the path where the qemu process should write its PID.
@@ -1469,10 +2533,6 @@ func (vm *Qemu) pid() (int, error) {
 
 // Stop stops the VM.
 func (vm *Qemu) Stop(stateful bool) error {
-	if stateful {
-		return fmt.Errorf("Stateful stop isn't supported for VMs at this time")
-	}
-
 	if !vm.IsRunning() {
 		return fmt.Errorf("Instance is not running")
 	}
@@ -1494,6 +2554,22 @@ func (vm *Qemu) Stop(stateful bool) error {
 		return err
 	}
 
+	// Save the VM's state before stopping it.
+	if stateful {
+		err = vm.saveState(monitor)
+		if err != nil {
+			monitor.Start()
+			return err
+		}
+
+		vm.stateful = true
+		err = vm.state.Cluster.ContainerSetStateful(vm.id, true)
+		if err != nil {
+			monitor.Start()
+			return errors.Wrap(err, "Persist stateful flag")
+		}
+	}
+
 	// Send the quit command.
 	err = monitor.Quit()
 	if err != nil {
@@ -1510,6 +2586,161 @@ func (vm *Qemu) Stop(stateful bool) error {
 	return nil
 }
 
+// SaveState saves the memory and device state of the running instance into its state
+// directory. The instance is left paused, Unfreeze resumes it.
+func (vm *Qemu) SaveState() error {
+	// Connect to the monitor.
+	monitor, err := qmp.Connect(vm.getMonitorPath(), vm.getMonitorEventHandler())
+	if err != nil {
+		return err
+	}
+
+	return vm.saveState(monitor)
+}
+
+// saveState pauses the VM and saves its state into the state directory through QEMU's
+// migration stream, compressing it on the way.
+func (vm *Qemu) saveState(monitor *qmp.Monitor) error {
+	if !shared.IsTrue(vm.expandedConfig["migration.stateful"]) {
+		return fmt.Errorf("Saving the state of a virtual machine requires migration.stateful to be enabled")
+	}
+
+	// Cleanup any existing state.
+	os.RemoveAll(vm.StatePath())
+	err := os.MkdirAll(vm.StatePath(), 0700)
+	if err != nil {
+		return err
+	}
+
+	// The state volume must be able to hold the VM's memory.
+	memSize, err := vm.memorySize()
+	if err != nil {
+		return err
+	}
+
+	fs := unix.Statfs_t{}
+	err = unix.Statfs(vm.StatePath(), &fs)
+	if err != nil {
+		return err
+	}
+
+	if uint64(memSize) > fs.Bavail*uint64(fs.Bsize) {
+		return api.NewTypedError(http.StatusInternalServerError, api.ErrorTypeQuotaExceeded, "Not enough space left on the instance's volume to save %s of memory", units.GetByteSizeString(memSize, 2))
+	}
+
+	err = monitor.Pause()
+	if err != nil {
+		return err
+	}
+
+	stateFile, err := os.Create(vm.stateFilePath())
+	if err != nil {
+		return err
+	}
+	defer stateFile.Close()
+
+	compressed, err := gzip.NewWriterLevel(stateFile, gzip.BestSpeed)
+	if err != nil {
+		return err
+	}
+	defer compressed.Close()
+
+	pipeRead, pipeWrite, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer pipeRead.Close()
+
+	chCopy := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(compressed, pipeRead)
+		chCopy <- err
+	}()
+
+	// Apply the migration tuning of the instance.
+	var maxBandwidth uint64
+	if vm.expandedConfig["migration.incremental.memory.bandwidth"] != "" {
+		value, err := units.ParseByteSizeString(vm.expandedConfig["migration.incremental.memory.bandwidth"])
+		if err != nil {
+			return err
+		}
+
+		maxBandwidth = uint64(value)
+	}
+
+	var downtimeLimit uint32
+	if vm.expandedConfig["migration.incremental.memory.downtime"] != "" {
+		value, err := strconv.ParseUint(vm.expandedConfig["migration.incremental.memory.downtime"], 10, 32)
+		if err != nil {
+			return err
+		}
+
+		downtimeLimit = uint32(value)
+	}
+
+	err = monitor.SetMigrationParameters(maxBandwidth, downtimeLimit)
+	if err != nil {
+		return err
+	}
+
+	// QEMU holds its own copy of the pipe, ours must be closed to get EOF once it's done.
+	err = monitor.SendFile("migration", pipeWrite)
+	pipeWrite.Close()
+	if err != nil {
+		return err
+	}
+
+	err = monitor.Migrate("fd:migration")
+	if err != nil {
+		return err
+	}
+
+	err = <-chCopy
+	if err != nil {
+		return err
+	}
+
+	return compressed.Close()
+}
+
+// restoreState loads the saved state into a QEMU process started with "-incoming defer".
+func (vm *Qemu) restoreState(monitor *qmp.Monitor) error {
+	stateFile, err := os.Open(vm.stateFilePath())
+	if err != nil {
+		return err
+	}
+	defer stateFile.Close()
+
+	compressed, err := gzip.NewReader(stateFile)
+	if err != nil {
+		return err
+	}
+	defer compressed.Close()
+
+	pipeRead, pipeWrite, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		io.Copy(pipeWrite, compressed)
+		pipeWrite.Close()
+	}()
+
+	err = monitor.SendFile("migration", pipeRead)
+	pipeRead.Close()
+	if err != nil {
+		return err
+	}
+
+	return monitor.MigrateIncoming("fd:migration")
+}
+
+// stateFilePath returns the path of the file holding the VM's saved state.
+func (vm *Qemu) stateFilePath() string {
+	return filepath.Join(vm.StatePath(), "qemu.state")
+}
+
 // Unfreeze restores the instance to running.
 func (vm *Qemu) Unfreeze() error {
 	// Connect to the monitor.
@@ -1539,7 +2770,26 @@ func (vm *Qemu) Restore(source instance.Instance, stateful bool) error {
 
 // Snapshots returns a list of snapshots.
 func (vm *Qemu) Snapshots() ([]instance.Instance, error) {
-	return []instance.Instance{}, nil
+	if vm.IsSnapshot() {
+		return []instance.Instance{}, nil
+	}
+
+	snapNames, err := vm.state.Cluster.ContainerGetSnapshots(vm.Project(), vm.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]instance.Instance, 0, len(snapNames))
+	for _, snapName := range snapNames {
+		snap, err := instance.LoadByProjectAndName(vm.state, vm.Project(), snapName)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, snap)
+	}
+
+	return snapshots, nil
 }
 
 // Backups returns a list of backups.
@@ -1769,6 +3019,18 @@ func (vm *Qemu) Update(args db.InstanceArgs, userRequested bool) error {
 		return errors.Wrap(err, "Invalid expanded config")
 	}
 
+	if vm.expandedConfig["boot.after"] != oldExpandedConfig["boot.after"] {
+		err = instance.ValidBootAfter(vm.state, vm.project, vm.name, vm.expandedConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = validateRawQemuConf(vm.expandedConfig["raw.qemu.conf"])
+	if err != nil {
+		return errors.Wrap(err, "Invalid expanded config")
+	}
+
 	// Do full expanded validation of the devices diff.
 	err = instance.ValidDevices(vm.state, vm.state.Cluster, vm.Type(), vm.Name(), vm.expandedDevices, true)
 	if err != nil {
@@ -2188,11 +3450,14 @@ func (vm *Qemu) Delete() error {
 			}
 		}
 
-		// Delete the MAAS entry.
+		// Remove the AppArmor profile.
+		apparmor.DeleteProfile(vm)
+
+		// Delete the MAAS entry. The storage is already gone at this point so a failure
+		// doesn't stop the deletion, a leftover entry gets updated if the name is reused.
 		err = vm.maasDelete()
 		if err != nil {
 			logger.Error("Failed deleting instance MAAS record", log.Ctx{"project": vm.Project(), "instance": vm.Name(), "err": err})
-			return err
 		}
 
 		// Run device removal function for each device.
@@ -2397,7 +3662,30 @@ func (vm *Qemu) FilePush(fileType string, srcPath string, dstPath string, uid in
 		args.Content = bytes.NewReader([]byte(symlinkTarget))
 	}
 
-	err = agent.CreateInstanceFile("", dstPath, args)
+	err = agent.CreateInstanceFile("", dstPath, args)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FileRemove removes a file from the instance.
+func (vm *Qemu) FileRemove(path string) error {
+	// Connect to the agent.
+	client, err := vm.getAgentClient()
+	if err != nil {
+		return err
+	}
+
+	agent, err := lxdClient.ConnectLXDHTTP(nil, client)
+	if err != nil {
+		return fmt.Errorf("Failed to connect to lxd-agent")
+	}
+	defer agent.Disconnect()
+
+	// Delete instance file.
+	err = agent.DeleteInstanceFile("", path)
 	if err != nil {
 		return err
 	}
@@ -2405,9 +3693,8 @@ func (vm *Qemu) FilePush(fileType string, srcPath string, dstPath string, uid in
 	return nil
 }
 
-// FileRemove removes a file from the instance.
-func (vm *Qemu) FileRemove(path string) error {
-	// Connect to the agent.
+// FilePullArchive writes the tree at srcPath in the instance to w as a tar stream.
+func (vm *Qemu) FilePullArchive(srcPath string, w io.Writer) error {
 	client, err := vm.getAgentClient()
 	if err != nil {
 		return err
@@ -2415,17 +3702,55 @@ func (vm *Qemu) FileRemove(path string) error {
 
 	agent, err := lxdClient.ConnectLXDHTTP(nil, client)
 	if err != nil {
+		logger.Errorf("Failed to connect to lxd-agent on %s: %v", vm.Name(), err)
 		return fmt.Errorf("Failed to connect to lxd-agent")
 	}
 	defer agent.Disconnect()
 
-	// Delete instance file.
-	err = agent.DeleteInstanceFile("", path)
+	content, err := agent.GetInstanceFileArchive("", srcPath)
 	if err != nil {
 		return err
 	}
+	defer content.Close()
 
-	return nil
+	_, err = io.Copy(w, content)
+	return err
+}
+
+// FilePushArchive extracts the tar stream read from r into the existing directory dstPath in the
+// instance.
+func (vm *Qemu) FilePushArchive(r io.Reader, dstPath string) error {
+	client, err := vm.getAgentClient()
+	if err != nil {
+		return err
+	}
+
+	agent, err := lxdClient.ConnectLXDHTTP(nil, client)
+	if err != nil {
+		logger.Errorf("Failed to connect to lxd-agent on %s: %v", vm.Name(), err)
+		return fmt.Errorf("Failed to connect to lxd-agent")
+	}
+	defer agent.Disconnect()
+
+	return agent.CreateInstanceFileArchive("", dstPath, r)
+}
+
+// FileSFTPConn returns a connection to the SFTP server of the lxd-agent, serving the instance's
+// filesystem.
+func (vm *Qemu) FileSFTPConn() (io.ReadWriteCloser, error) {
+	client, err := vm.getAgentClient()
+	if err != nil {
+		return nil, err
+	}
+
+	agent, err := lxdClient.ConnectLXDHTTP(nil, client)
+	if err != nil {
+		logger.Errorf("Failed to connect to lxd-agent on %s: %v", vm.Name(), err)
+		return nil, fmt.Errorf("Failed to connect to lxd-agent")
+	}
+	defer agent.Disconnect()
+
+	return agent.GetInstanceFileSFTPConn("")
 }
 
 // Console gets access to the instance's console.
@@ -2610,6 +3935,7 @@ func (vm *Qemu) Render() (interface{}, interface{}, error) {
 			LastUsedAt:      vm.lastUsedDate,
 			Name:            strings.SplitN(vm.name, "/", 2)[1],
 			Stateful:        vm.stateful,
+			UUID:            vm.localConfig["volatile.uuid"],
 		}
 		vmSnap.Architecture = architectureName
 		vmSnap.Config = vm.localConfig
@@ -2632,6 +3958,7 @@ func (vm *Qemu) Render() (interface{}, interface{}, error) {
 		StatusCode:      vm.statusCode(),
 		Location:        vm.node,
 		Type:            vm.Type().String(),
+		UUID:            vm.localConfig["volatile.uuid"],
 	}
 
 	vmState.Description = vm.description
@@ -2721,60 +4048,18 @@ func (vm *Qemu) RenderState() (*api.InstanceState, error) {
 			// Fallback data.
 			status = &api.InstanceState{}
 			status.Processes = -1
-			networks := map[string]api.InstanceStateNetwork{}
-			for k, m := range vm.ExpandedDevices() {
-				// We only care about nics.
-				if m["type"] != "nic" || m["nictype"] != "bridged" {
-					continue
-				}
-
-				// Fill the MAC address.
-				m, err := vm.fillNetworkDevice(k, m)
-				if err != nil {
-					return nil, err
-				}
-
-				// Parse the lease file.
-				addresses, err := instance.NetworkGetLeaseAddresses(vm.state, m["parent"], m["hwaddr"])
-				if err != nil {
-					return nil, err
-				}
-
-				if len(addresses) == 0 {
-					continue
-				}
-
-				// Get MTU.
-				iface, err := net.InterfaceByName(m["parent"])
-				if err != nil {
-					return nil, err
-				}
-
-				if m["host_name"] == "" {
-					m["host_name"] = vm.localConfig[fmt.Sprintf("volatile.%s.host_name", k)]
-				}
 
-				// Retrieve the host counters, as we report the values
-				// from the instance's point of view, those counters need to be reversed below.
-				hostCounters := shared.NetworkGetCounters(m["host_name"])
-
-				networks[k] = api.InstanceStateNetwork{
-					Addresses: addresses,
-					Counters: api.InstanceStateNetworkCounters{
-						BytesReceived:   hostCounters.BytesSent,
-						BytesSent:       hostCounters.BytesReceived,
-						PacketsReceived: hostCounters.PacketsSent,
-						PacketsSent:     hostCounters.PacketsReceived,
-					},
-					Hwaddr:   m["hwaddr"],
-					HostName: m["host_name"],
-					Mtu:      iface.MTU,
-					State:    "up",
-					Type:     "broadcast",
-				}
+			status.Network, err = vm.hostNetworkState()
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			// The agent reports the interfaces by their name inside of the VM, so fill in
+			// the name of their host side TAP device.
+			err = vm.fillNetworkHostNames(status.Network)
+			if err != nil {
+				return nil, err
 			}
-
-			status.Network = networks
 		}
 
 		status.Pid = int64(pid)
@@ -2793,6 +4078,136 @@ func (vm *Qemu) RenderState() (*api.InstanceState, error) {
 	}, nil
 }
 
+// DiskIOStats returns the IO counters of the VM's disk devices, keyed by device name, as reported
+// by QEMU for their drives.
+func (vm *Qemu) DiskIOStats() (map[string]api.StorageVolumeStateIO, error) {
+	if !vm.IsRunning() {
+		return nil, fmt.Errorf("Instance is not running")
+	}
+
+	// Connect to the monitor.
+	monitor, err := qmp.Connect(vm.getMonitorPath(), vm.getMonitorEventHandler())
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := monitor.GetBlockStats()
+	if err != nil {
+		return nil, err
+	}
+
+	disks := map[string]api.StorageVolumeStateIO{}
+	for _, dev := range vm.expandedDevices.Sorted() {
+		if dev.Config["type"] != "disk" {
+			continue
+		}
+
+		// Devices use "lxd_" prefix indicating that this is a user named device.
+		driveStats, ok := stats[fmt.Sprintf("lxd_%s", dev.Name)]
+		if !ok {
+			continue
+		}
+
+		disks[dev.Name] = api.StorageVolumeStateIO{
+			ReadBytes:  driveStats.ReadBytes,
+			ReadOps:    driveStats.ReadOps,
+			WriteBytes: driveStats.WriteBytes,
+			WriteOps:   driveStats.WriteOps,
+		}
+	}
+
+	return disks, nil
+}
+
+// hostNetworkState returns the state of the VM's NICs as seen from the host side TAP devices, used
+// when the agent isn't available.
+func (vm *Qemu) hostNetworkState() (map[string]api.InstanceStateNetwork, error) {
+	networks := map[string]api.InstanceStateNetwork{}
+	for k, m := range vm.ExpandedDevices() {
+		// We only care about nics.
+		if m["type"] != "nic" || m["nictype"] != "bridged" {
+			continue
+		}
+
+		// Fill the MAC address.
+		m, err := vm.fillNetworkDevice(k, m)
+		if err != nil {
+			return nil, err
+		}
+
+		// Parse the lease file.
+		addresses, err := instance.NetworkGetLeaseAddresses(vm.state, vm.Project(), m["parent"], m["hwaddr"])
+		if err != nil {
+			return nil, err
+		}
+
+		if m["host_name"] == "" {
+			m["host_name"] = vm.localConfig[fmt.Sprintf("volatile.%s.host_name", k)]
+		}
+
+		// Get MTU, the one of the TAP device if it's there.
+		iface, err := net.InterfaceByName(m["host_name"])
+		if err != nil {
+			iface, err = net.InterfaceByName(m["parent"])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Retrieve the host counters, as we report the values
+		// from the instance's point of view, those counters need to be reversed below.
+		hostCounters := shared.NetworkGetCounters(m["host_name"])
+
+		networks[k] = api.InstanceStateNetwork{
+			Addresses: addresses,
+			Counters: api.InstanceStateNetworkCounters{
+				BytesReceived:   hostCounters.BytesSent,
+				BytesSent:       hostCounters.BytesReceived,
+				PacketsReceived: hostCounters.PacketsSent,
+				PacketsSent:     hostCounters.PacketsReceived,
+			},
+			Hwaddr:   m["hwaddr"],
+			HostName: m["host_name"],
+			Mtu:      iface.MTU,
+			State:    "up",
+			Type:     "broadcast",
+		}
+	}
+
+	return networks, nil
+}
+
+// fillNetworkHostNames sets the host side name of the interfaces reported by the agent, matching
+// them to the VM's NICs by MAC address.
+func (vm *Qemu) fillNetworkHostNames(networks map[string]api.InstanceStateNetwork) error {
+	for k, m := range vm.ExpandedDevices() {
+		if m["type"] != "nic" || m["nictype"] != "bridged" {
+			continue
+		}
+
+		m, err := vm.fillNetworkDevice(k, m)
+		if err != nil {
+			return err
+		}
+
+		hostName := m["host_name"]
+		if hostName == "" {
+			hostName = vm.localConfig[fmt.Sprintf("volatile.%s.host_name", k)]
+		}
+
+		for name, network := range networks {
+			if network.HostName != "" || !strings.EqualFold(network.Hwaddr, m["hwaddr"]) {
+				continue
+			}
+
+			network.HostName = hostName
+			networks[name] = network
+		}
+	}
+
+	return nil
+}
+
 // agentGetState connects to the agent inside of the VM and does
 // an API call to get the current state.
 func (vm *Qemu) agentGetState() (*api.InstanceState, error) {
@@ -2825,6 +4240,75 @@ func (vm *Qemu) agentGetState() (*api.InstanceState, error) {
 	return status, nil
 }
 
+// agentQuiesce asks the agent inside of the VM to freeze (or thaw) its filesystems, running the
+// guest's pre-freeze and post-thaw hooks.
+func (vm *Qemu) agentQuiesce(freeze bool) error {
+	// Check if the agent is running.
+	monitor, err := qmp.Connect(vm.getMonitorPath(), vm.getMonitorEventHandler())
+	if err != nil {
+		return err
+	}
+
+	if !monitor.AgentReady() {
+		return errQemuAgentOffline
+	}
+
+	client, err := vm.getAgentClient()
+	if err != nil {
+		return err
+	}
+
+	agent, err := lxdClient.ConnectLXDHTTP(nil, client)
+	if err != nil {
+		return err
+	}
+	defer agent.Disconnect()
+
+	method := "DELETE"
+	if freeze {
+		method = "POST"
+	}
+
+	_, _, err = agent.RawQuery(method, "/1.0/quiesce", nil, "")
+	return err
+}
+
+// AgentCommand forwards a command to the agent inside of the VM and returns its result.
+func (vm *Qemu) AgentCommand(req api.InstanceAgentPost) (*api.InstanceAgentResult, error) {
+	// Check if the agent is running.
+	monitor, err := qmp.Connect(vm.getMonitorPath(), vm.getMonitorEventHandler())
+	if err != nil {
+		return nil, err
+	}
+
+	if !monitor.AgentReady() {
+		return nil, errQemuAgentOffline
+	}
+
+	client, err := vm.getAgentClient()
+	if err != nil {
+		return nil, err
+	}
+
+	agent, err := lxdClient.ConnectLXDHTTP(nil, client)
+	if err != nil {
+		return nil, err
+	}
+	defer agent.Disconnect()
+
+	return agent.RunInstanceAgentCommand("", req)
+}
+
+// FreezeFilesystems quiesces the VM's filesystems through the agent ahead of a snapshot.
+func (vm *Qemu) FreezeFilesystems() error {
+	return vm.agentQuiesce(true)
+}
+
+// ThawFilesystems resumes writes to the filesystems frozen by FreezeFilesystems.
+func (vm *Qemu) ThawFilesystems() error {
+	return vm.agentQuiesce(false)
+}
+
 // IsRunning returns whether or not the instance is running.
 func (vm *Qemu) IsRunning() bool {
 	state := vm.State()
@@ -3079,11 +4563,146 @@ func (vm *Qemu) StorageStop() (bool, error) {
 	return false, storagePools.ErrNotImplemented
 }
 
-// DeferTemplateApply not used currently.
+// DeferTemplateApply sets volatile key to apply template on next start. Used when instance's
+// volume isn't mounted.
 func (vm *Qemu) DeferTemplateApply(trigger string) error {
+	err := vm.VolatileSet(map[string]string{"volatile.apply_template": trigger})
+	if err != nil {
+		return errors.Wrap(err, "Failed to set apply_template volatile key")
+	}
+
 	return nil
 }
 
+// templateApplyNow renders the image templates matching the triggers into the target path along with
+// a metadata.yaml describing where lxd-agent should write them inside the VM. When a template matches
+// multiple triggers, the last one is used.
+func (vm *Qemu) templateApplyNow(triggers []string, path string) error {
+	err := os.MkdirAll(path, 0500)
+	if err != nil {
+		return err
+	}
+
+	// If there's no metadata, just return.
+	fname := filepath.Join(vm.Path(), "metadata.yaml")
+	if !shared.PathExists(fname) {
+		return nil
+	}
+
+	// Parse the metadata.
+	content, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return errors.Wrap(err, "Failed to read metadata")
+	}
+
+	metadata := new(api.ImageMetadata)
+	err = yaml.Unmarshal(content, &metadata)
+	if err != nil {
+		return errors.Wrapf(err, "Could not parse %s", fname)
+	}
+
+	// Figure out the instance architecture.
+	arch, err := osarch.ArchitectureName(vm.architecture)
+	if err != nil {
+		arch, err = osarch.ArchitectureName(vm.state.OS.Architectures[0])
+		if err != nil {
+			return errors.Wrap(err, "Failed to detect system architecture")
+		}
+	}
+
+	// Generate the instance metadata.
+	instanceMeta := make(map[string]string)
+	instanceMeta["name"] = vm.name
+	instanceMeta["type"] = "virtual-machine"
+	instanceMeta["architecture"] = arch
+	instanceMeta["privileged"] = "false"
+
+	if vm.ephemeral {
+		instanceMeta["ephemeral"] = "true"
+	} else {
+		instanceMeta["ephemeral"] = "false"
+	}
+
+	// Let the cloud-init.* keys override the user.* keys used by image templates.
+	templateConfig := shared.CloudInitTemplateConfig(vm.expandedConfig)
+
+	rendered := map[string]*api.ImageMetadataTemplate{}
+
+	// Go through the templates.
+	for tplPath, tpl := range metadata.Templates {
+		trigger := ""
+		for _, t := range triggers {
+			if shared.StringInSlice(t, tpl.When) {
+				trigger = t
+			}
+		}
+
+		if trigger == "" {
+			continue
+		}
+
+		// Read the template.
+		tplString, err := ioutil.ReadFile(filepath.Join(vm.TemplatesPath(), tpl.Template))
+		if err != nil {
+			return errors.Wrap(err, "Failed to read template file")
+		}
+
+		// Restrict filesystem access to within the instance's templates.
+		tplSet := pongo2.NewSet(fmt.Sprintf("%s-%s", vm.name, tpl.Template), template.ChrootLoader{Path: vm.TemplatesPath()})
+
+		tplRender, err := tplSet.FromString("{% autoescape off %}" + string(tplString) + "{% endautoescape %}")
+		if err != nil {
+			return errors.Wrap(err, "Failed to render template")
+		}
+
+		configGet := func(confKey, confDefault *pongo2.Value) *pongo2.Value {
+			val, ok := templateConfig[confKey.String()]
+			if !ok {
+				return confDefault
+			}
+
+			return pongo2.AsValue(strings.TrimRight(val, "\r\n"))
+		}
+
+		// Name the rendered file after its target path so a template used for multiple paths
+		// doesn't conflict with itself.
+		outName := fmt.Sprintf("%s.out", strings.Replace(strings.TrimLeft(tplPath, "/"), "/", "_", -1))
+
+		w, err := os.Create(filepath.Join(path, outName))
+		if err != nil {
+			return errors.Wrap(err, "Failed to create template file")
+		}
+
+		// Render the template.
+		err = tplRender.ExecuteWriter(pongo2.Context{"trigger": trigger,
+			"path":       tplPath,
+			"container":  instanceMeta,
+			"instance":   instanceMeta,
+			"config":     templateConfig,
+			"devices":    vm.expandedDevices,
+			"properties": tpl.Properties,
+			"config_get": configGet}, w)
+		w.Close()
+		if err != nil {
+			return errors.Wrapf(err, "Failed to render template for %q", tplPath)
+		}
+
+		rendered[tplPath] = &api.ImageMetadataTemplate{
+			When:       []string{trigger},
+			CreateOnly: tpl.CreateOnly,
+			Template:   outName,
+			Properties: tpl.Properties,
+		}
+	}
+
+	content, err = yaml.Marshal(&api.ImageMetadata{Templates: rendered})
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(path, "metadata.yaml"), content, 0400)
+}
+
 // DaemonState returns the state of the daemon. Deprecated.
 func (vm *Qemu) DaemonState() *state.State {
 	// FIXME: This function should go away, since the abstract instance
@@ -3158,6 +4777,24 @@ func (vm *Qemu) fillNetworkDevice(name string, m deviceConfig.Device) (deviceCon
 		newDevice["hwaddr"] = volatileHwaddr
 	}
 
+	// Assign a PCIe root port to the NIC for the lifetime of the device, so that the guest
+	// sees it at the same PCI address and gives it the same predictable name on every boot.
+	if m["type"] == "nic" && vm.nicPortIndex(name) < 0 {
+		configKey := fmt.Sprintf("volatile.%s.pcie_port", name)
+		index, err := vm.nextNICPortIndex()
+		if err != nil {
+			return nil, err
+		}
+
+		err = updateKey(configKey, fmt.Sprintf("%d", index))
+		if err != nil {
+			return nil, err
+		}
+
+		vm.localConfig[configKey] = fmt.Sprintf("%d", index)
+		vm.expandedConfig[configKey] = fmt.Sprintf("%d", index)
+	}
+
 	return newDevice, nil
 }
 
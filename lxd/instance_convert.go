@@ -0,0 +1,370 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/rsync"
+	"github.com/lxc/lxd/lxd/state"
+	storagePools "github.com/lxc/lxd/lxd/storage"
+	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/idmap"
+	"github.com/lxc/lxd/shared/osarch"
+)
+
+// instanceConvertHook is the executable of the source filesystem which is run, chrooted into the
+// new virtual machine disk, to install its kernel and bootloader.
+const instanceConvertHook = "/etc/lxd-convert/to-vm"
+
+// The fstab of converted filesystems is replaced with one matching the new instance type.
+const (
+	instanceConvertVMFstab        = "LABEL=rootfs / ext4 defaults 0 1\nLABEL=UEFI /boot/efi vfat defaults 0 1\n"
+	instanceConvertContainerFstab = "# Converted from a virtual machine, mounts are handled by LXD.\n"
+)
+
+// instanceCreateAsConversion creates a new instance of a different type than the source instance
+// from its root filesystem. Containers are converted to virtual machines with a fresh partition
+// table and bootloader, virtual machines to containers by extracting their root partition.
+// Snapshots aren't converted.
+func instanceCreateAsConversion(s *state.State, args db.InstanceArgs, sourceInst instance.Instance, op *operations.Operation) (instance.Instance, error) {
+	if sourceInst.IsRunning() {
+		return nil, fmt.Errorf("Converting requires the source instance to be stopped")
+	}
+
+	if args.Type == sourceInst.Type() {
+		return nil, fmt.Errorf("The new instance must be of a different type than the source instance")
+	}
+
+	// Load the storage pool of the source.
+	srcPool, err := storagePools.GetPoolByInstance(s, sourceInst)
+	if err == storageDrivers.ErrUnknownDriver || err == storageDrivers.ErrNotImplemented {
+		return nil, fmt.Errorf("Converting isn't supported on the storage pool of the source instance")
+	} else if err != nil {
+		return nil, errors.Wrap(err, "Load source instance storage pool")
+	}
+
+	// Create the instance record.
+	inst, err := instanceCreateInternal(s, args)
+	if err != nil {
+		return nil, err
+	}
+
+	revert := true
+	defer func() {
+		if !revert {
+			return
+		}
+
+		inst.Delete()
+	}()
+
+	pool, err := storagePools.GetPoolByInstance(s, inst)
+	if err == storageDrivers.ErrUnknownDriver || err == storageDrivers.ErrNotImplemented {
+		return nil, fmt.Errorf("Converting isn't supported on the storage pool of the new instance")
+	} else if err != nil {
+		return nil, errors.Wrap(err, "Load instance storage pool")
+	}
+
+	err = pool.CreateInstance(inst, op)
+	if err != nil {
+		return nil, errors.Wrap(err, "Create instance")
+	}
+
+	// Apply any post-storage configuration, resizing the disk of virtual machines.
+	err = instanceConfigureInternal(s, inst)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mount both instances.
+	ourMount, err := srcPool.MountInstance(sourceInst, op)
+	if err != nil {
+		return nil, errors.Wrap(err, "Mount source instance")
+	}
+
+	if ourMount {
+		defer srcPool.UnmountInstance(sourceInst, op)
+	}
+
+	ourMount, err = pool.MountInstance(inst, op)
+	if err != nil {
+		return nil, errors.Wrap(err, "Mount instance")
+	}
+
+	if ourMount {
+		defer pool.UnmountInstance(inst, op)
+	}
+
+	if inst.Type() == instancetype.VM {
+		disk, err := pool.GetInstanceDisk(inst)
+		if err != nil {
+			return nil, err
+		}
+
+		// The files of stopped containers are owned by the host ids of the container.
+		var idmapset *idmap.IdmapSet
+		ct, ok := sourceInst.(*containerLXC)
+		if ok {
+			idmapset, err = ct.DiskIdmap()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		err = instanceConvertToVM(sourceInst.RootfsPath(), disk, idmapset, inst.Architecture())
+		if err != nil {
+			return nil, errors.Wrap(err, "Convert container to virtual machine")
+		}
+	} else {
+		disk, err := srcPool.GetInstanceDisk(sourceInst)
+		if err != nil {
+			return nil, err
+		}
+
+		err = instanceConvertToContainer(disk, inst.RootfsPath())
+		if err != nil {
+			return nil, errors.Wrap(err, "Convert virtual machine to container")
+		}
+	}
+
+	revert = false
+	return inst, nil
+}
+
+// instanceConvertLoop attaches the disk to a loop device with its partitions scanned, and returns
+// the loop device along with a function detaching it.
+func instanceConvertLoop(disk string, readonly bool) (string, func(), error) {
+	args := []string{"--find", "--show", "--partscan"}
+	if readonly {
+		args = append(args, "--read-only")
+	}
+
+	out, err := shared.RunCommand("losetup", append(args, disk)...)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "Failed to attach %q to a loop device", disk)
+	}
+
+	loop := strings.TrimSpace(out)
+	return loop, func() { shared.RunCommand("losetup", "--detach", loop) }, nil
+}
+
+// instanceConvertMount mounts the device on a temporary directory and returns it along with a
+// function unmounting and removing it.
+func instanceConvertMount(device string, options string) (string, func(), error) {
+	path, err := ioutil.TempDir(shared.VarPath("storage-pools"), "lxd_convert_")
+	if err != nil {
+		return "", nil, err
+	}
+
+	_, err = shared.RunCommand("mount", "-o", options, device, path)
+	if err != nil {
+		os.Remove(path)
+		return "", nil, errors.Wrapf(err, "Failed to mount %q", device)
+	}
+
+	return path, func() {
+		storageDrivers.TryUnmount(path, unix.MNT_DETACH)
+		os.Remove(path)
+	}, nil
+}
+
+// instanceConvertToVM partitions the disk with an EFI and a root partition, copies the container
+// root filesystem into the latter and installs the bootloader.
+func instanceConvertToVM(rootfs string, disk string, idmapset *idmap.IdmapSet, arch int) error {
+	_, err := shared.RunCommand("sgdisk", "--zap-all", disk)
+	if err != nil {
+		return errors.Wrap(err, "Failed to clear the partition table")
+	}
+
+	_, err = shared.RunCommand("sgdisk",
+		"--new=1:0:+100M", "--typecode=1:ef00", "--change-name=1:UEFI",
+		"--new=2:0:0", "--typecode=2:8300", "--change-name=2:rootfs",
+		disk)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create the partitions")
+	}
+
+	loop, detach, err := instanceConvertLoop(disk, false)
+	if err != nil {
+		return err
+	}
+	defer detach()
+
+	_, err = shared.RunCommand("mkfs.vfat", "-F", "32", "-n", "UEFI", loop+"p1")
+	if err != nil {
+		return errors.Wrap(err, "Failed to format the EFI partition")
+	}
+
+	_, err = shared.RunCommand("mkfs.ext4", "-q", "-L", "rootfs", loop+"p2")
+	if err != nil {
+		return errors.Wrap(err, "Failed to format the root partition")
+	}
+
+	path, unmount, err := instanceConvertMount(loop+"p2", "rw")
+	if err != nil {
+		return err
+	}
+	defer unmount()
+
+	_, err = rsync.LocalCopy(rootfs, path, "", true)
+	if err != nil {
+		return err
+	}
+
+	if idmapset != nil {
+		err = idmapset.UnshiftRootfs(path, nil)
+		if err != nil {
+			return errors.Wrap(err, "Failed to unshift the root filesystem")
+		}
+	}
+
+	err = ioutil.WriteFile(filepath.Join(path, "etc", "fstab"), []byte(instanceConvertVMFstab), 0644)
+	if err != nil {
+		return err
+	}
+
+	// Mount the EFI partition and the kernel filesystems for the bootloader installation.
+	mounts := []string{}
+	defer func() {
+		for i := len(mounts) - 1; i >= 0; i-- {
+			storageDrivers.TryUnmount(mounts[i], unix.MNT_DETACH)
+		}
+	}()
+
+	efiPath := filepath.Join(path, "boot", "efi")
+	err = os.MkdirAll(efiPath, 0755)
+	if err != nil {
+		return err
+	}
+
+	_, err = shared.RunCommand("mount", loop+"p1", efiPath)
+	if err != nil {
+		return errors.Wrap(err, "Failed to mount the EFI partition")
+	}
+
+	mounts = append(mounts, efiPath)
+
+	for _, fs := range []string{"dev", "proc", "sys"} {
+		target := filepath.Join(path, fs)
+		err = os.MkdirAll(target, 0755)
+		if err != nil {
+			return err
+		}
+
+		err = unix.Mount(filepath.Join("/", fs), target, "", unix.MS_BIND|unix.MS_REC, "")
+		if err != nil {
+			return errors.Wrapf(err, "Failed to mount /%s", fs)
+		}
+
+		mounts = append(mounts, target)
+	}
+
+	// Run the hook of the filesystem if any, or install GRUB.
+	if shared.PathExists(filepath.Join(path, instanceConvertHook)) {
+		_, err = shared.RunCommand("chroot", path, instanceConvertHook)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to run %q", instanceConvertHook)
+		}
+
+		return nil
+	}
+
+	if !shared.PathExists(filepath.Join(path, "usr", "sbin", "grub-install")) && !shared.PathExists(filepath.Join(path, "sbin", "grub-install")) {
+		return fmt.Errorf("The container has neither a %q hook nor GRUB to install the bootloader", instanceConvertHook)
+	}
+
+	target := "x86_64-efi"
+	if arch == osarch.ARCH_64BIT_ARMV8_LITTLE_ENDIAN {
+		target = "arm64-efi"
+	}
+
+	_, err = shared.RunCommand("chroot", path, "grub-install", "--target="+target, "--efi-directory=/boot/efi", "--removable", "--no-nvram")
+	if err != nil {
+		return errors.Wrap(err, "Failed to install GRUB")
+	}
+
+	_, err = shared.RunCommand("chroot", path, "grub-mkconfig", "-o", "/boot/grub/grub.cfg")
+	if err != nil {
+		return errors.Wrap(err, "Failed to generate the GRUB configuration")
+	}
+
+	return nil
+}
+
+// instanceConvertRootPartition returns the root partition of the loop device, the one labelled
+// "rootfs" or otherwise the largest one. Disks without partitions are used as a whole.
+func instanceConvertRootPartition(loop string) (string, error) {
+	partitions, err := filepath.Glob(loop + "p*")
+	if err != nil {
+		return "", err
+	}
+
+	if len(partitions) == 0 {
+		return loop, nil
+	}
+
+	sizes := map[string]int64{}
+	for _, partition := range partitions {
+		label, _ := shared.RunCommand("blkid", "-o", "value", "-s", "LABEL", partition)
+		if strings.TrimSpace(label) == "rootfs" {
+			return partition, nil
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join("/sys/class/block", filepath.Base(partition), "size"))
+		if err != nil {
+			return "", err
+		}
+
+		sizes[partition], err = strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	sort.SliceStable(partitions, func(i, j int) bool {
+		return sizes[partitions[i]] > sizes[partitions[j]]
+	})
+
+	return partitions[0], nil
+}
+
+// instanceConvertToContainer copies the root partition of the virtual machine disk into the
+// container root filesystem. The files are shifted to the container ids on its first start.
+func instanceConvertToContainer(disk string, rootfs string) error {
+	loop, detach, err := instanceConvertLoop(disk, true)
+	if err != nil {
+		return err
+	}
+	defer detach()
+
+	partition, err := instanceConvertRootPartition(loop)
+	if err != nil {
+		return errors.Wrap(err, "Failed to find the root partition")
+	}
+
+	path, unmount, err := instanceConvertMount(partition, "ro")
+	if err != nil {
+		return err
+	}
+	defer unmount()
+
+	_, err = rsync.LocalCopy(path, rootfs, "", true)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(rootfs, "etc", "fstab"), []byte(instanceConvertContainerFstab), 0644)
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// instanceTasksRunning records the instance tasks currently running, keyed by project, instance
+// and task name, so that a slow run doesn't overlap with the next scheduled one.
+var instanceTasksRunning = map[string]bool{}
+var instanceTasksRunningLock sync.Mutex
+
+func instanceTasksTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		// Load all local instances
+		allInstances, err := instanceLoadNodeAll(d.State(), instancetype.Any)
+		if err != nil {
+			logger.Error("Failed to load instances for scheduled tasks", log.Ctx{"err": err})
+			return
+		}
+
+		// Truncate the time now back to the start of the minute, see autoCreateContainerSnapshotsTask.
+		now := time.Now().Truncate(time.Minute)
+
+		wg := sync.WaitGroup{}
+		for _, inst := range allInstances {
+			for _, name := range instanceTaskNames(inst.ExpandedConfig()) {
+				if !instanceScheduledNow(inst, inst.ExpandedConfig()[fmt.Sprintf("tasks.%s.schedule", name)], now) {
+					continue
+				}
+
+				wg.Add(1)
+				go func(inst instance.Instance, name string) {
+					defer wg.Done()
+					instanceTaskRun(d, inst, name)
+				}(inst, name)
+			}
+		}
+
+		wg.Wait()
+	}
+
+	first := true
+	schedule := func() (time.Duration, error) {
+		interval := time.Minute
+
+		if first {
+			first = false
+			return interval, task.ErrSkip
+		}
+
+		return interval, nil
+	}
+
+	return f, schedule
+}
+
+// instanceTaskNames returns the sorted names of the tasks defined in the instance config.
+func instanceTaskNames(config map[string]string) []string {
+	names := []string{}
+	for k, v := range config {
+		fields := strings.Split(k, ".")
+		if len(fields) != 3 || fields[0] != "tasks" || fields[2] != "schedule" || v == "" {
+			continue
+		}
+
+		names = append(names, fields[1])
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// instanceTaskRun runs a task of the instance as an operation and records the outcome in the
+// volatile.tasks.NAME.last_run and volatile.tasks.NAME.last_status keys.
+func instanceTaskRun(d *Daemon, inst instance.Instance, name string) {
+	config := inst.ExpandedConfig()
+	action := config[fmt.Sprintf("tasks.%s.action", name)]
+	ctxMap := log.Ctx{"project": inst.Project(), "instance": inst.Name(), "task": name, "action": action}
+
+	// Commands can only be run in running instances.
+	if action == "exec" && !inst.IsRunning() {
+		logger.Debug("Skipping instance task as the instance isn't running", ctxMap)
+		return
+	}
+
+	// Skip this run if the previous one is still going.
+	key := fmt.Sprintf("%s/%s", project.Prefix(inst.Project(), inst.Name()), name)
+	instanceTasksRunningLock.Lock()
+	if instanceTasksRunning[key] {
+		instanceTasksRunningLock.Unlock()
+		logger.Warn("Skipping instance task as the previous run is still in progress", ctxMap)
+		return
+	}
+
+	instanceTasksRunning[key] = true
+	instanceTasksRunningLock.Unlock()
+
+	defer func() {
+		instanceTasksRunningLock.Lock()
+		delete(instanceTasksRunning, key)
+		instanceTasksRunningLock.Unlock()
+	}()
+
+	run := func(op *operations.Operation) error {
+		switch action {
+		case "snapshot":
+			return instanceTaskSnapshot(d, inst, name)
+		case "backup":
+			return instanceTaskBackup(d, inst, name)
+		case "exec":
+			return instanceTaskExec(d, inst, name)
+		}
+
+		return fmt.Errorf("Unknown task action %q", action)
+	}
+
+	resources := map[string][]string{}
+	resources["instances"] = []string{inst.Name()}
+	if inst.Type() == instancetype.Container {
+		resources["containers"] = resources["instances"]
+	}
+
+	metadata := map[string]interface{}{"task": name, "action": action}
+
+	op, err := operations.OperationCreate(d.State(), inst.Project(), operations.OperationClassTask, db.OperationInstanceTaskRun, resources, metadata, run, nil, nil)
+	if err != nil {
+		logger.Error("Failed to start instance task operation", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "task": name, "err": err})
+		return
+	}
+
+	logger.Info("Running instance task", ctxMap)
+
+	status := "Success"
+	chanErr, err := op.Run()
+	if err == nil {
+		err = <-chanErr
+	}
+
+	if err != nil {
+		status = err.Error()
+		logger.Error("Failed to run instance task", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "task": name, "err": err})
+	} else {
+		logger.Info("Done running instance task", ctxMap)
+	}
+
+	err = inst.VolatileSet(map[string]string{
+		fmt.Sprintf("volatile.tasks.%s.last_run", name):    time.Now().UTC().Format(time.RFC3339),
+		fmt.Sprintf("volatile.tasks.%s.last_status", name): status,
+	})
+	if err != nil {
+		logger.Warn("Failed to record instance task run", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "task": name, "err": err})
+	}
+}
+
+// instanceTaskRetain returns the number of snapshots or backups a task keeps, 0 meaning all of them.
+func instanceTaskRetain(inst instance.Instance, name string) int {
+	retain, err := strconv.Atoi(inst.ExpandedConfig()[fmt.Sprintf("tasks.%s.retain", name)])
+	if err != nil {
+		return 0
+	}
+
+	return retain
+}
+
+// instanceTaskSnapshot creates a NAME-<index> snapshot of the instance and deletes the oldest
+// snapshots made by the task beyond tasks.NAME.retain.
+func instanceTaskSnapshot(d *Daemon, inst instance.Instance, name string) error {
+	pattern := fmt.Sprintf("%s-%%d", name)
+	i := d.cluster.ContainerNextSnapshot(inst.Project(), inst.Name(), pattern)
+	snapshotName := strings.Replace(pattern, "%d", strconv.Itoa(i), 1)
+
+	args := db.InstanceArgs{
+		Architecture: inst.Architecture(),
+		Config:       inst.LocalConfig(),
+		Type:         inst.Type(),
+		Snapshot:     true,
+		Devices:      inst.LocalDevices(),
+		Ephemeral:    inst.IsEphemeral(),
+		Name:         fmt.Sprintf("%s%s%s", inst.Name(), shared.SnapshotDelimiter, snapshotName),
+		Profiles:     inst.Profiles(),
+		Project:      inst.Project(),
+		Stateful:     false,
+	}
+
+	_, err := instanceCreateAsSnapshot(d.State(), args, inst, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to create snapshot %q", snapshotName)
+	}
+
+	retain := instanceTaskRetain(inst, name)
+	if retain == 0 {
+		return nil
+	}
+
+	snapshots, err := inst.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	match := regexp.MustCompile(fmt.Sprintf("^%s-[0-9]+$", regexp.QuoteMeta(name)))
+	taskSnapshots := []instance.Instance{}
+	for _, snapshot := range snapshots {
+		_, snapName, _ := shared.InstanceGetParentAndSnapshotName(snapshot.Name())
+		if match.MatchString(snapName) {
+			taskSnapshots = append(taskSnapshots, snapshot)
+		}
+	}
+
+	sort.Slice(taskSnapshots, func(i, j int) bool {
+		return taskSnapshots[i].CreationDate().Before(taskSnapshots[j].CreationDate())
+	})
+
+	for len(taskSnapshots) > retain {
+		err = taskSnapshots[0].Delete()
+		if err != nil {
+			return errors.Wrapf(err, "Failed to prune snapshot %q", taskSnapshots[0].Name())
+		}
+
+		taskSnapshots = taskSnapshots[1:]
+	}
+
+	return nil
+}
+
+// instanceTaskBackup creates a NAME-<timestamp> backup of the instance. With tasks.NAME.target
+// set, the backup is moved to that directory, which must be listed in tasks.backup_targets. The
+// oldest backups made by the task beyond tasks.NAME.retain are then deleted.
+func instanceTaskBackup(d *Daemon, inst instance.Instance, name string) error {
+	target := inst.ExpandedConfig()[fmt.Sprintf("tasks.%s.target", name)]
+	if target != "" {
+		targets, err := cluster.ConfigGetString(d.cluster, "tasks.backup_targets")
+		if err != nil {
+			return err
+		}
+
+		allowed := false
+		for _, entry := range strings.Split(targets, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry != "" && filepath.Clean(entry) == filepath.Clean(target) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return fmt.Errorf("Backup target %q isn't listed in tasks.backup_targets", target)
+		}
+	}
+
+	backupName := fmt.Sprintf("%s-%s", name, time.Now().UTC().Format("20060102-150405"))
+	args := db.InstanceBackupArgs{
+		Name:         fmt.Sprintf("%s%s%s", inst.Name(), shared.SnapshotDelimiter, backupName),
+		InstanceID:   inst.ID(),
+		CreationDate: time.Now(),
+	}
+
+	err := backupCreate(d.State(), args, inst)
+	if err != nil {
+		return errors.Wrap(err, "Create backup")
+	}
+
+	retain := instanceTaskRetain(inst, name)
+	match := regexp.MustCompile(fmt.Sprintf("^%s-[0-9]{8}-[0-9]{6}$", regexp.QuoteMeta(name)))
+
+	if target == "" {
+		if retain == 0 {
+			return nil
+		}
+
+		backups, err := inst.Backups()
+		if err != nil {
+			return err
+		}
+
+		taskBackups := []string{}
+		for _, b := range backups {
+			if match.MatchString(b.Render().Name) {
+				taskBackups = append(taskBackups, b.Name())
+			}
+		}
+
+		// The timestamp suffix makes the names sort by creation date.
+		sort.Strings(taskBackups)
+		for len(taskBackups) > retain {
+			b, err := instance.BackupLoadByName(d.State(), inst.Project(), taskBackups[0])
+			if err != nil {
+				return err
+			}
+
+			err = b.Delete()
+			if err != nil {
+				return errors.Wrapf(err, "Failed to prune backup %q", taskBackups[0])
+			}
+
+			taskBackups = taskBackups[1:]
+		}
+
+		return nil
+	}
+
+	// Move the tarball to the target and drop the local backup.
+	b, err := instance.BackupLoadByName(d.State(), inst.Project(), args.Name)
+	if err != nil {
+		return err
+	}
+
+	prefix := fmt.Sprintf("%s_", project.Prefix(inst.Project(), inst.Name()))
+	err = shared.FileCopy(shared.VarPath("backups", project.Prefix(inst.Project(), args.Name)), filepath.Join(target, prefix+backupName))
+	if err != nil {
+		b.Delete()
+		return errors.Wrapf(err, "Failed to export backup to %q", target)
+	}
+
+	err = b.Delete()
+	if err != nil {
+		return err
+	}
+
+	if retain == 0 {
+		return nil
+	}
+
+	entries, err := ioutil.ReadDir(target)
+	if err != nil {
+		return err
+	}
+
+	exported := []string{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), prefix) && match.MatchString(strings.TrimPrefix(entry.Name(), prefix)) {
+			exported = append(exported, entry.Name())
+		}
+	}
+
+	sort.Strings(exported)
+	for len(exported) > retain {
+		err = os.Remove(filepath.Join(target, exported[0]))
+		if err != nil {
+			return errors.Wrapf(err, "Failed to prune backup %q", exported[0])
+		}
+
+		exported = exported[1:]
+	}
+
+	return nil
+}
+
+// instanceTaskExec runs tasks.NAME.command in the instance as root. The command must be listed
+// in tasks.exec_whitelist.
+func instanceTaskExec(d *Daemon, inst instance.Instance, name string) error {
+	command := strings.Fields(inst.ExpandedConfig()[fmt.Sprintf("tasks.%s.command", name)])
+	if len(command) == 0 {
+		return fmt.Errorf("No command set in tasks.%s.command", name)
+	}
+
+	whitelist, err := cluster.ConfigGetString(d.cluster, "tasks.exec_whitelist")
+	if err != nil {
+		return err
+	}
+
+	allowed := false
+	for _, entry := range strings.Split(whitelist, ",") {
+		if strings.TrimSpace(entry) == command[0] {
+			allowed = true
+			break
+		}
+	}
+
+	if !allowed {
+		return fmt.Errorf("Command %q isn't listed in tasks.exec_whitelist", command[0])
+	}
+
+	env := map[string]string{}
+	for k, v := range inst.ExpandedConfig() {
+		if strings.HasPrefix(k, "environment.") {
+			env[strings.TrimPrefix(k, "environment.")] = v
+		}
+	}
+
+	if env["PATH"] == "" {
+		env["PATH"] = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	}
+
+	if env["HOME"] == "" {
+		env["HOME"] = "/root"
+	}
+
+	cmd, err := inst.Exec(command, env, nil, nil, nil, "", 0, 0)
+	if err != nil {
+		return err
+	}
+
+	exitCode, err := cmd.Wait()
+	if err != nil {
+		return err
+	}
+
+	if exitCode != 0 {
+		return fmt.Errorf("Command %q exited with status %d", strings.Join(command, " "), exitCode)
+	}
+
+	return nil
+}
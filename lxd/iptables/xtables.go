@@ -165,7 +165,13 @@ func (xt XTables) NetworkSetupAllowForwarding(family firewallConsts.Family, name
 }
 
 // NetworkSetupNAT configures NAT
-func (xt XTables) NetworkSetupNAT(family firewallConsts.Family, name string, location firewallConsts.Location, args ...string) error {
+func (xt XTables) NetworkSetupNAT(family firewallConsts.Family, name string, location firewallConsts.Location, subnet net.IPNet, srcIP net.IP) error {
+	// If a SNAT source address is specified, use that, otherwise default to using MASQUERADE mode.
+	args := []string{"-s", subnet.String(), "!", "-d", subnet.String(), "-j", "MASQUERADE"}
+	if srcIP != nil {
+		args = []string{"-s", subnet.String(), "!", "-d", subnet.String(), "-j", "SNAT", "--to", srcIP.String()}
+	}
+
 	if location == firewallConsts.LocationPrepend {
 		err := NetworkPrepend(fmt.Sprintf("%s", family), name, "nat", "POSTROUTING", args...)
 		if err != nil {
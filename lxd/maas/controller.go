@@ -2,6 +2,7 @@ package maas
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"strings"
 
@@ -58,6 +59,48 @@ func parseInterfaces(interfaces []ContainerInterface) (map[string]ContainerInter
 	return macInterfaces, nil
 }
 
+// validateSubnets checks that the subnets of each interface exist in MAAS and can be used together.
+// An interface may be linked to both an IPv4 and an IPv6 subnet but those have to be on the same
+// VLAN as MAAS attaches the interface itself to a VLAN.
+func validateSubnets(interfaces []ContainerInterface, subnets map[string]gomaasapi.Subnet) error {
+	if len(interfaces) < 1 {
+		return fmt.Errorf("Empty list of MAAS interface provided")
+	}
+
+	for _, iface := range interfaces {
+		if len(iface.Subnets) < 1 || len(iface.Subnets) > 2 {
+			return fmt.Errorf("Bad subnet provided for interface '%s'", iface.Name)
+		}
+
+		vlanID := -1
+		families := map[bool]string{}
+		for _, entry := range iface.Subnets {
+			subnet, ok := subnets[entry.Name]
+			if !ok {
+				return fmt.Errorf("Subnet '%s' doesn't exist in MAAS", entry.Name)
+			}
+
+			ip, _, err := net.ParseCIDR(subnet.CIDR())
+			if err != nil {
+				return err
+			}
+
+			isIPv4 := ip.To4() != nil
+			if families[isIPv4] != "" {
+				return fmt.Errorf("Subnets '%s' and '%s' of interface '%s' are of the same family", families[isIPv4], entry.Name, iface.Name)
+			}
+			families[isIPv4] = entry.Name
+
+			if vlanID != -1 && subnet.VLAN().ID() != vlanID {
+				return fmt.Errorf("Subnets '%s' and '%s' of interface '%s' aren't on the same VLAN", iface.Subnets[0].Name, entry.Name, iface.Name)
+			}
+			vlanID = subnet.VLAN().ID()
+		}
+	}
+
+	return nil
+}
+
 // NewController returns a new Controller using the specific MAAS server and machine
 func NewController(url string, key string, machine string) (*Controller, error) {
 	baseURL := fmt.Sprintf("%s/api/2.0/", url)
@@ -148,19 +191,9 @@ func (c *Controller) CreateContainer(name string, interfaces []ContainerInterfac
 	}
 
 	// Validation
-	if len(interfaces) < 1 {
-		return fmt.Errorf("Empty list of MAAS interface provided")
-	}
-
-	for _, iface := range interfaces {
-		if len(iface.Subnets) != 1 {
-			return fmt.Errorf("Bad subnet provided for interface '%s'", iface.Name)
-		}
-
-		_, ok := subnets[iface.Subnets[0].Name]
-		if !ok {
-			return fmt.Errorf("Subnet '%s' doesn't exist in MAAS", interfaces[0].Subnets[0].Name)
-		}
+	err = validateSubnets(interfaces, subnets)
+	if err != nil {
+		return err
 	}
 
 	// Create the device and first interface
@@ -261,19 +294,9 @@ func (c *Controller) UpdateContainer(name string, interfaces []ContainerInterfac
 	}
 
 	// Validation
-	if len(interfaces) < 1 {
-		return fmt.Errorf("Empty list of MAAS interface provided")
-	}
-
-	for _, iface := range interfaces {
-		if len(iface.Subnets) != 1 {
-			return fmt.Errorf("Bad subnet provided for interface '%s'", iface.Name)
-		}
-
-		_, ok := subnets[iface.Subnets[0].Name]
-		if !ok {
-			return fmt.Errorf("Subnet '%s' doesn't exist in MAAS", interfaces[0].Subnets[0].Name)
-		}
+	err = validateSubnets(interfaces, subnets)
+	if err != nil {
+		return err
 	}
 
 	// Iterate over existing interfaces, drop all removed ones and update existing ones
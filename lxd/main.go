@@ -108,6 +108,10 @@ func main() {
 	forkconsoleCmd := cmdForkconsole{global: &globalCmd}
 	app.AddCommand(forkconsoleCmd.Command())
 
+	// forkdhcpdns sub-command
+	forkDHCPDNSCmd := cmdForkDHCPDNS{global: &globalCmd}
+	app.AddCommand(forkDHCPDNSCmd.Command())
+
 	// forkdns sub-command
 	forkDNSCmd := cmdForkDNS{global: &globalCmd}
 	app.AddCommand(forkDNSCmd.Command())
@@ -0,0 +1,199 @@
+// forkdhcpdns runs the native DHCP and DNS server of a network.
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/dhcpdns"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/logging"
+)
+
+/*
+#ifndef _GNU_SOURCE
+#define _GNU_SOURCE 1
+#endif
+#include <errno.h>
+#include <fcntl.h>
+#include <stdbool.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+extern char* advance_arg(bool required);
+
+static int wait_for_pid(pid_t pid)
+{
+	int status, ret;
+
+again:
+	ret = waitpid(pid, &status, 0);
+	if (ret == -1) {
+		if (errno == EINTR)
+			goto again;
+		return -1;
+	}
+	if (ret != pid)
+		goto again;
+	if (!WIFEXITED(status) || WEXITSTATUS(status) != 0)
+		return -1;
+	return 0;
+}
+
+void forkdhcpdns(void)
+{
+	ssize_t ret;
+	pid_t pid;
+	FILE *pid_file;
+	int log_fd;
+	char *pid_path, *log_path;
+
+	close(STDIN_FILENO);
+
+	log_path = advance_arg(false);
+	pid_path = advance_arg(false);
+
+	// If arguments are missing, fall through to Go part without double forking to output usage info.
+	if (log_path == NULL || pid_path == NULL)
+		return;
+
+	log_fd = open(log_path, O_WRONLY | O_CREAT | O_CLOEXEC | O_TRUNC, 0600);
+	if (log_fd < 0)
+		_exit(EXIT_FAILURE);
+
+	ret = dup3(log_fd, STDOUT_FILENO, O_CLOEXEC);
+	if (ret < 0)
+		_exit(EXIT_FAILURE);
+
+	ret = dup3(log_fd, STDERR_FILENO, O_CLOEXEC);
+	if (ret < 0)
+		_exit(EXIT_FAILURE);
+
+	pid_file = fopen(pid_path, "we+");
+	if (!pid_file) {
+		fprintf(stderr,
+			"%s - Failed to create pid file for forkdhcpdns daemon\n",
+			strerror(errno));
+		_exit(EXIT_FAILURE);
+	}
+
+	// daemonize
+	pid = fork();
+	if (pid < 0)
+		_exit(EXIT_FAILURE);
+
+	if (pid != 0) {
+		ret = wait_for_pid(pid);
+		if (ret < 0)
+			_exit(EXIT_FAILURE);
+
+		_exit(EXIT_SUCCESS);
+	}
+
+	pid = fork();
+	if (pid < 0)
+		_exit(EXIT_FAILURE);
+
+	if (pid != 0) {
+		ret = fprintf(pid_file, "%d", pid);
+		fclose(pid_file);
+		if (ret < 0) {
+			fprintf(stderr, "Failed to write forkdhcpdns daemon pid %d to \"%s\"\n",
+				pid, pid_path);
+			ret = EXIT_FAILURE;
+		}
+
+		close(STDOUT_FILENO);
+		close(STDERR_FILENO);
+		_exit(EXIT_SUCCESS);
+	}
+
+	ret = setsid();
+	if (ret < 0)
+		fprintf(stderr, "%s - Failed to setsid in forkdhcpdns daemon\n",
+			strerror(errno));
+}
+*/
+import "C"
+
+type cmdForkDHCPDNS struct {
+	global *cmdGlobal
+}
+
+func (c *cmdForkDHCPDNS) Command() *cobra.Command {
+	// Main subcommand
+	cmd := &cobra.Command{}
+	cmd.Use = "forkdhcpdns <log path> <pid path> <network name>"
+	cmd.Short = "Internal DHCP and DNS server for managed bridges"
+	cmd.Long = `Description:
+  Spawns the native DHCP and DNS server of a managed bridge, used instead of dnsmasq when the
+  network has dns.mode set to "managed-native".
+  It hands out IPv4 addresses over DHCP, sends IPv6 router advertisements and answers DNS queries
+  for the instances of the network, forwarding the other queries to the nameservers of the host.
+  The static allocations and leases are kept in the same files as dnsmasq. Sending SIGHUP to the
+  process reloads the static allocations.
+`
+	cmd.RunE = c.Run
+	cmd.Hidden = true
+
+	return cmd
+}
+
+func (c *cmdForkDHCPDNS) Run(cmd *cobra.Command, args []string) error {
+	// Sanity checks
+	if len(args) < 3 {
+		cmd.Help()
+
+		if len(args) == 0 {
+			return nil
+		}
+
+		return fmt.Errorf("Missing required arguments")
+	}
+
+	log, err := logging.GetLogger("lxd-forkdhcpdns", "", c.global.flagLogVerbose, c.global.flagLogDebug, nil)
+	if err != nil {
+		return err
+	}
+	logger.Log = log
+
+	networkName := args[2]
+	dir := shared.VarPath("networks", networkName)
+	config, err := dhcpdns.LoadConfig(filepath.Join(dir, dhcpdns.ConfigFile))
+	if err != nil {
+		return err
+	}
+
+	srv, err := dhcpdns.NewServer(config, dir)
+	if err != nil {
+		return err
+	}
+
+	// Reload the static allocations on SIGHUP, like dnsmasq does.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, unix.SIGHUP)
+	go func() {
+		for range sigs {
+			err := srv.Reload()
+			if err != nil {
+				logger.Errorf("Reload failed: %v", err)
+				continue
+			}
+
+			logger.Info("Reloaded")
+		}
+	}()
+
+	logger.Info("Started")
+
+	return srv.Run()
+}
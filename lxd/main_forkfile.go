@@ -2,8 +2,13 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"os"
 
+	"github.com/pkg/sftp"
 	"github.com/spf13/cobra"
+
+	"github.com/lxc/lxd/shared/filearchive"
 )
 
 /*
@@ -401,6 +406,29 @@ void forkremovefile(char *rootfs, pid_t pid) {
 	_exit(0);
 }
 
+// forkattachfile attaches to the container and returns, the Go side of the
+// command then runs within it.
+void forkattachfile(char *rootfs, pid_t pid) {
+	if (pid > 0) {
+		attach_userns(pid);
+
+		if (dosetns(pid, "mnt") < 0) {
+			error("error: setns");
+			_exit(1);
+		}
+	} else {
+		if (chroot(rootfs) < 0) {
+			error("error: chroot");
+			_exit(1);
+		}
+
+		if (chdir("/") < 0) {
+			error("error: chdir");
+			_exit(1);
+		}
+	}
+}
+
 void forkfile(void)
 {
 	char *command = NULL;
@@ -437,6 +465,8 @@ void forkfile(void)
 		forkcheckfile(rootfs, pid);
 	} else if (strcmp(command, "remove") == 0) {
 		forkremovefile(rootfs, pid);
+	} else if (strcmp(command, "archive") == 0 || strcmp(command, "extract") == 0 || strcmp(command, "sftp") == 0) {
+		forkattachfile(rootfs, pid);
 	}
 }
 */
@@ -488,9 +518,58 @@ func (c *cmdForkfile) Command() *cobra.Command {
 	cmdRemove.RunE = c.Run
 	cmd.AddCommand(cmdRemove)
 
+	// archive
+	cmdArchive := &cobra.Command{}
+	cmdArchive.Use = "archive <rootfs> <PID> <path>"
+	cmdArchive.Args = cobra.ExactArgs(3)
+	cmdArchive.RunE = c.RunArchive
+	cmd.AddCommand(cmdArchive)
+
+	// extract
+	cmdExtract := &cobra.Command{}
+	cmdExtract.Use = "extract <rootfs> <PID> <path>"
+	cmdExtract.Args = cobra.ExactArgs(3)
+	cmdExtract.RunE = c.RunExtract
+	cmd.AddCommand(cmdExtract)
+
+	// sftp
+	cmdSFTP := &cobra.Command{}
+	cmdSFTP.Use = "sftp <rootfs> <PID>"
+	cmdSFTP.Args = cobra.ExactArgs(2)
+	cmdSFTP.RunE = c.RunSFTP
+	cmd.AddCommand(cmdSFTP)
+
 	return cmd
 }
 
 func (c *cmdForkfile) Run(cmd *cobra.Command, args []string) error {
 	return fmt.Errorf("This command should have been intercepted in cgo")
 }
+
+// RunArchive writes the tree at the path, already attached to the container, to stdout as a tar
+// stream.
+func (c *cmdForkfile) RunArchive(cmd *cobra.Command, args []string) error {
+	return filearchive.Write(os.Stdout, args[2])
+}
+
+// RunExtract extracts the tar stream read from stdin into the path, already attached to the
+// container.
+func (c *cmdForkfile) RunExtract(cmd *cobra.Command, args []string) error {
+	return filearchive.Extract(os.Stdin, args[2], true)
+}
+
+// RunSFTP serves SFTP, already attached to the container, on stdin which is a socket also used as
+// stdout.
+func (c *cmdForkfile) RunSFTP(cmd *cobra.Command, args []string) error {
+	server, err := sftp.NewServer(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	err = server.Serve()
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	return nil
+}
@@ -69,7 +69,7 @@ void forknet(void)
 	}
 
 	// Call the subcommands
-	if (strcmp(command, "info") == 0) {
+	if (strcmp(command, "info") == 0 || strcmp(command, "set-mtu") == 0) {
 		pid = atoi(cur);
 		forkdonetinfo(pid);
 	}
@@ -112,6 +112,13 @@ func (c *cmdForknet) Command() *cobra.Command {
 	cmdDetach.RunE = c.RunDetach
 	cmd.AddCommand(cmdDetach)
 
+	// set-mtu
+	cmdSetMTU := &cobra.Command{}
+	cmdSetMTU.Use = "set-mtu <PID> <ifname> <mtu>"
+	cmdSetMTU.Args = cobra.ExactArgs(3)
+	cmdSetMTU.RunE = c.RunSetMTU
+	cmd.AddCommand(cmdSetMTU)
+
 	return cmd
 }
 
@@ -163,3 +170,23 @@ func (c *cmdForknet) RunDetach(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func (c *cmdForknet) RunSetMTU(cmd *cobra.Command, args []string) error {
+	ifName := args[1]
+	mtu := args[2]
+
+	if ifName == "" {
+		return fmt.Errorf("ifname argument is required")
+	}
+
+	if mtu == "" {
+		return fmt.Errorf("mtu argument is required")
+	}
+
+	_, err := shared.RunCommand("ip", "link", "set", "dev", ifName, "mtu", mtu)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -350,13 +350,6 @@ func listenerInstance(epFd C.int, lAddr *device.ProxyAddress, cAddr *device.Prox
 		// This only handles udp <-> udp. The C constructor will have
 		// verified this before.
 		go func() {
-			// single or multiple port -> single port
-			connectAddr := cAddr.Addr[0]
-			if len(cAddr.Addr) > 1 {
-				// multiple port -> multiple port
-				connectAddr = cAddr.Addr[(*lStruct).lAddrIndex]
-			}
-
 			srcConn, err := net.FileConn((*lStruct).f)
 			if err != nil {
 				fmt.Printf("Warning: Failed to re-assemble listener: %s\n", err)
@@ -364,7 +357,7 @@ func listenerInstance(epFd C.int, lAddr *device.ProxyAddress, cAddr *device.Prox
 				return
 			}
 
-			dstConn, err := net.Dial(cAddr.ConnType, connectAddr)
+			dstConn, err := net.Dial(cAddr.ConnType, (*lStruct).connectAddr)
 			if err != nil {
 				fmt.Printf("Warning: Failed to connect to target: %v\n", err)
 				rearmUDPFd(epFd, connFd)
@@ -386,13 +379,7 @@ func listenerInstance(epFd C.int, lAddr *device.ProxyAddress, cAddr *device.Prox
 		return err
 	}
 
-	// single or multiple port -> single port
-	connectAddr := cAddr.Addr[0]
-	if lAddr.ConnType != "unix" && cAddr.ConnType != "unix" && len(cAddr.Addr) > 1 {
-		// multiple port -> multiple port
-		connectAddr = cAddr.Addr[(*lStruct).lAddrIndex]
-	}
-	dstConn, err := net.Dial(cAddr.ConnType, connectAddr)
+	dstConn, err := net.Dial(cAddr.ConnType, (*lStruct).connectAddr)
 	if err != nil {
 		srcConn.Close()
 		fmt.Printf("Warning: Failed to connect to target: %v\n", err)
@@ -437,10 +424,11 @@ func listenerInstance(epFd C.int, lAddr *device.ProxyAddress, cAddr *device.Prox
 }
 
 type lStruct struct {
-	f          *os.File
-	lConn      *net.Listener
-	udpConn    *net.Conn
-	lAddrIndex int
+	f           *os.File
+	lConn       *net.Listener
+	udpConn     *net.Conn
+	lAddrIndex  int
+	connectAddr string
 }
 
 func (c *cmdForkproxy) Run(cmd *cobra.Command, args []string) error {
@@ -477,15 +465,11 @@ func (c *cmdForkproxy) Run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if (lAddr.ConnType == "udp" || lAddr.ConnType == "tcp") && cAddr.ConnType == "udp" || cAddr.ConnType == "tcp" {
-		err := fmt.Errorf("Invalid port range")
-		if len(lAddr.Addr) > 1 && len(cAddr.Addr) > 1 && (len(cAddr.Addr) != len(lAddr.Addr)) {
-			fmt.Println(err)
-			return err
-		} else if len(lAddr.Addr) == 1 && len(cAddr.Addr) > 1 {
-			fmt.Println(err)
-			return err
-		}
+	// Resolve which address each of the listen addresses forwards to.
+	connectAddrs, err := device.ProxyConnectAddrs(listenAddr, connectAddr)
+	if err != nil {
+		fmt.Println(err)
+		return err
 	}
 
 	if C.whoami == C.FORKPROXY_CHILD {
@@ -592,8 +576,9 @@ func (c *cmdForkproxy) Run(cmd *cobra.Command, args []string) error {
 	if isUDPListener {
 		for i, f := range files {
 			listenerMap[int(f.Fd())] = &lStruct{
-				f:          f,
-				lAddrIndex: i,
+				f:           f,
+				lAddrIndex:  i,
+				connectAddr: connectAddrs[i],
 			}
 		}
 	} else {
@@ -604,8 +589,9 @@ func (c *cmdForkproxy) Run(cmd *cobra.Command, args []string) error {
 				return err
 			}
 			listenerMap[int(f.Fd())] = &lStruct{
-				lConn:      &listener,
-				lAddrIndex: i,
+				lConn:       &listener,
+				lAddrIndex:  i,
+				connectAddr: connectAddrs[i],
 			}
 		}
 	}
@@ -75,6 +75,26 @@ func TestParseAddr(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"Multiple addresses",
+			"tcp:127.0.0.1:2000,2001,tcp:[::1]:2000",
+			&device.ProxyAddress{
+				ConnType: "tcp",
+				Addr: []string{
+					"127.0.0.1:2000",
+					"127.0.0.1:2001",
+					"[::1]:2000",
+				},
+				Abstract: false,
+			},
+			false,
+		},
+		{
+			"Multiple addresses with different connection types",
+			"tcp:127.0.0.1:2000,udp:127.0.0.1:2000",
+			nil,
+			true,
+		},
 		// connType testing
 		{
 			"UDP",
@@ -179,3 +199,96 @@ func TestParseAddr(t *testing.T) {
 		require.Equal(t, tt.expected, addr)
 	}
 }
+
+func TestProxyConnectAddrs(t *testing.T) {
+	tests := []struct {
+		name       string
+		listen     string
+		connect    string
+		expected   []string
+		shouldFail bool
+	}{
+		{
+			"Single port",
+			"tcp:127.0.0.1:2000",
+			"tcp:10.0.0.1:3000",
+			[]string{"10.0.0.1:3000"},
+			false,
+		},
+		{
+			"Port range to single port",
+			"tcp:127.0.0.1:2000-2002",
+			"tcp:10.0.0.1:3000",
+			[]string{"10.0.0.1:3000", "10.0.0.1:3000", "10.0.0.1:3000"},
+			false,
+		},
+		{
+			"Equal number of ports",
+			"tcp:127.0.0.1:2000-2001,2005",
+			"tcp:10.0.0.1:3000,3010-3011",
+			[]string{"10.0.0.1:3000", "10.0.0.1:3010", "10.0.0.1:3011"},
+			false,
+		},
+		{
+			"Pairwise port lists",
+			"udp:127.0.0.1:53,2000-2003,5000",
+			"udp:10.0.0.1:5353,3000-3001",
+			[]string{
+				"10.0.0.1:5353",
+				"10.0.0.1:3000",
+				"10.0.0.1:3001",
+				"10.0.0.1:3001",
+				"10.0.0.1:3001",
+				"10.0.0.1:3001",
+			},
+			false,
+		},
+		{
+			"Multiple listen addresses",
+			"tcp:127.0.0.1:80,443,tcp:[::1]:80,443",
+			"tcp:10.0.0.1:8080,8443",
+			[]string{"10.0.0.1:8080", "10.0.0.1:8443", "10.0.0.1:8080", "10.0.0.1:8443"},
+			false,
+		},
+		{
+			"Unix socket",
+			"tcp:127.0.0.1:2000-2001",
+			"unix:/foobar",
+			[]string{"/foobar", "/foobar"},
+			false,
+		},
+		{
+			"Connect range larger than listen range",
+			"tcp:127.0.0.1:2000-2001,2005",
+			"tcp:10.0.0.1:3000-3002,3005",
+			nil,
+			true,
+		},
+		{
+			"More connect entries than listen entries",
+			"tcp:127.0.0.1:2000-2005",
+			"tcp:10.0.0.1:3000,3002",
+			nil,
+			true,
+		},
+		{
+			"Multiple connect addresses",
+			"tcp:127.0.0.1:2000,2001",
+			"tcp:10.0.0.1:3000,tcp:10.0.0.2:3000",
+			nil,
+			true,
+		},
+	}
+
+	for i, tt := range tests {
+		log.Printf("Running test #%d: %s", i, tt.name)
+		addrs, err := device.ProxyConnectAddrs(tt.listen, tt.connect)
+		if tt.shouldFail {
+			require.Error(t, err)
+			require.Nil(t, addrs)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, tt.expected, addrs)
+	}
+}
@@ -12,7 +12,9 @@ import (
 type cmdImport struct {
 	global *cmdGlobal
 
-	flagForce bool
+	flagForce            bool
+	flagMissingSnapshots string
+	flagUnknownSnapshots string
 }
 
 func (c *cmdImport) Command() *cobra.Command {
@@ -29,9 +31,17 @@ func (c *cmdImport) Command() *cobra.Command {
   To do so, you must first mount your container storage at the expected
   path inside the storage-pools directory. Once that's in place,
   ` + "`lxd import`" + ` can be called for each individual container.
+
+  Snapshots recorded in the container's backup.yaml but missing on disk
+  can be discarded with --missing-snapshots=discard. Snapshots found on
+  disk but not recorded in backup.yaml can be imported using the
+  container's configuration with --unknown-snapshots=recover or deleted
+  with --unknown-snapshots=delete. --force implies discard and delete.
 `
 	cmd.RunE = c.Run
 	cmd.Flags().BoolVarP(&c.flagForce, "force", "f", false, "Force the import (override existing data or partial restore)")
+	cmd.Flags().StringVar(&c.flagMissingSnapshots, "missing-snapshots", "", "What to do with recorded snapshots missing on disk (discard)"+"``")
+	cmd.Flags().StringVar(&c.flagUnknownSnapshots, "unknown-snapshots", "", "What to do with snapshots on disk missing from backup.yaml (recover or delete)"+"``")
 
 	return cmd
 }
@@ -55,8 +65,10 @@ func (c *cmdImport) Run(cmd *cobra.Command, args []string) error {
 
 	name := args[0]
 	req := map[string]interface{}{
-		"name":  name,
-		"force": c.flagForce,
+		"name":              name,
+		"force":             c.flagForce,
+		"missing_snapshots": c.flagMissingSnapshots,
+		"unknown_snapshots": c.flagUnknownSnapshots,
 	}
 
 	d, err := lxd.ConnectLXDUnix("", nil)
@@ -165,11 +165,11 @@ func (c *cmdInit) availableStorageDrivers(poolType string) []string {
 
 	// Check available backends
 	for _, driver := range supportedStoragePoolDrivers {
-		if poolType == "remote" && !shared.StringInSlice(driver, []string{"ceph", "cephfs"}) {
+		if poolType == "remote" && !shared.StringInSlice(driver, []string{"ceph", "cephfs", "iscsi"}) {
 			continue
 		}
 
-		if poolType == "local" && shared.StringInSlice(driver, []string{"ceph", "cephfs"}) {
+		if poolType == "local" && shared.StringInSlice(driver, []string{"ceph", "cephfs", "iscsi"}) {
 			continue
 		}
 
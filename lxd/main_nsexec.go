@@ -43,6 +43,7 @@ extern void forkfile();
 extern void forksyscall();
 extern void forkmount();
 extern void forknet();
+extern void forkdhcpdns();
 extern void forkdns();
 extern void forkproxy();
 extern void forkuevent();
@@ -292,6 +293,8 @@ __attribute__((constructor)) void init(void) {
 		forkmount();
 	else if (strcmp(cmdline_cur, "forknet") == 0)
 		forknet();
+	else if (strcmp(cmdline_cur, "forkdhcpdns") == 0)
+		forkdhcpdns();
 	else if (strcmp(cmdline_cur, "forkdns") == 0)
 		forkdns();
 	else if (strcmp(cmdline_cur, "forkproxy") == 0)
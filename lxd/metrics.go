@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var metricsCmd = APIEndpoint{
+	Path: "metrics",
+
+	Get: APIEndpointAction{Handler: metricsGet},
+}
+
+// metricsVolumeCounters lists the per volume counters, with their help text and how to get them.
+var metricsVolumeCounters = []struct {
+	name  string
+	help  string
+	value func(stats api.StorageVolumeStateIO) uint64
+}{
+	{"lxd_storage_volume_read_bytes_total", "Bytes read from the storage volume.", func(s api.StorageVolumeStateIO) uint64 { return s.ReadBytes }},
+	{"lxd_storage_volume_read_ops_total", "Read operations completed on the storage volume.", func(s api.StorageVolumeStateIO) uint64 { return s.ReadOps }},
+	{"lxd_storage_volume_write_bytes_total", "Bytes written to the storage volume.", func(s api.StorageVolumeStateIO) uint64 { return s.WriteBytes }},
+	{"lxd_storage_volume_write_ops_total", "Write operations completed on the storage volume.", func(s api.StorageVolumeStateIO) uint64 { return s.WriteOps }},
+}
+
+type metricsResponse struct {
+	text string
+}
+
+func (r *metricsResponse) Render(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+
+	_, err := w.Write([]byte(r.text))
+	return err
+}
+
+func (r *metricsResponse) String() string {
+	return "metrics"
+}
+
+// /1.0/metrics
+// Get the metrics of this node in the Prometheus text format.
+func metricsGet(d *Daemon, r *http.Request) response.Response {
+	insts, err := instanceLoadNodeAll(d.State(), instancetype.Any)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Samples of each counter, in the order of metricsVolumeCounters.
+	samples := make([][]string, len(metricsVolumeCounters))
+	storagePoolVolumesIOStats(insts, func(inst instance.Instance, pool string, volType string, volName string, stats api.StorageVolumeStateIO) {
+		// The instance UUID lets the samples be followed across renames.
+		labels := fmt.Sprintf(`instance="%s",instance_uuid="%s",pool="%s",project="%s",type="%s",volume="%s"`,
+			metricsEscape(inst.Name()), metricsEscape(inst.LocalConfig()["volatile.uuid"]), metricsEscape(pool), metricsEscape(inst.Project()), metricsEscape(volType), metricsEscape(volName))
+
+		for i, counter := range metricsVolumeCounters {
+			samples[i] = append(samples[i], fmt.Sprintf("%s{%s} %d\n", counter.name, labels, counter.value(stats)))
+		}
+	})
+
+	var sb strings.Builder
+	for i, counter := range metricsVolumeCounters {
+		sb.WriteString(fmt.Sprintf("# HELP %s %s\n# TYPE %s counter\n", counter.name, counter.help, counter.name))
+
+		sort.Strings(samples[i])
+		for _, sample := range samples[i] {
+			sb.WriteString(sample)
+		}
+	}
+
+	return &metricsResponse{text: sb.String()}
+}
+
+// metricsEscape escapes a label value of the Prometheus text format.
+func metricsEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -3,16 +3,20 @@ package main
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
 	"gopkg.in/lxc/go-lxc.v2"
 
 	"github.com/lxc/lxd/lxd/db"
@@ -29,6 +33,7 @@ import (
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/idmap"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/units"
 )
 
 func NewMigrationSource(inst instance.Instance, stateful bool, instanceOnly bool) (*migrationSourceWs, error) {
@@ -125,6 +130,120 @@ func snapshotToProtobuf(c instance.Instance) *migration.Snapshot {
 	}
 }
 
+// criuFeatureSupported asks CRIU whether a feature is available on this host.
+func criuFeatureSupported(ct *containerLXC, feature lxc.CriuFeatures) bool {
+	criuMigrationArgs := CriuMigrationArgs{
+		cmd:      lxc.MIGRATE_FEATURE_CHECK,
+		function: "feature-check",
+		features: feature,
+	}
+
+	return ct.Migrate(&criuMigrationArgs) == nil
+}
+
+// waitForLocalListener waits for a TCP socket to listen on the given port of the loopback address.
+func waitForLocalListener(port int, stop <-chan struct{}) error {
+	// /proc/net/tcp shows addresses in host byte order.
+	addresses := []string{fmt.Sprintf("0100007F:%04X", port), fmt.Sprintf("7F000001:%04X", port)}
+
+	for {
+		content, err := ioutil.ReadFile("/proc/net/tcp")
+		if err != nil {
+			return err
+		}
+
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 4 || fields[3] != "0A" {
+				continue
+			}
+
+			if shared.StringInSlice(fields[1], addresses) {
+				return nil
+			}
+		}
+
+		select {
+		case <-stop:
+			return fmt.Errorf("Nothing listening on port %d", port)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// proxyCriuConn relays a local connection of CRIU over the CRIU websocket until both sides are
+// done with it.
+func proxyCriuConn(conn net.Conn, criuConn *websocket.Conn) {
+	wsConn := &shared.WebsocketIO{Conn: criuConn}
+	done := make(chan struct{}, 2)
+
+	go func() {
+		io.Copy(wsConn, conn)
+		wsConn.Close()
+		done <- struct{}{}
+	}()
+
+	go func() {
+		io.Copy(conn, wsConn)
+		conn.Close()
+		done <- struct{}{}
+	}()
+
+	<-done
+	<-done
+	conn.Close()
+}
+
+// startLazyPagesDaemon starts the CRIU lazy-pages daemon for the images in imagesDir. It fetches the
+// memory pages from the page server of the source through the CRIU websocket and hands them to the
+// restore.
+func startLazyPagesDaemon(imagesDir string, criuConn *websocket.Conn) (*exec.Cmd, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer listener.Close()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		proxyCriuConn(conn, criuConn)
+	}()
+
+	cmd := exec.Command(
+		"criu", "lazy-pages",
+		"--page-server",
+		"--address", "127.0.0.1",
+		"--port", fmt.Sprintf("%d", listener.Addr().(*net.TCPAddr).Port),
+		"--images-dir", imagesDir,
+		"--log-file", "lazy-pages.log",
+		"-v4")
+
+	err = cmd.Start()
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	// The restore connects to the daemon through its socket.
+	socket := filepath.Join(imagesDir, "lazy-pages.socket")
+	for i := 0; i < 100 && !shared.PathExists(socket); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if !shared.PathExists(socket) {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, fmt.Errorf("CRIU lazy-pages daemon failed to start")
+	}
+
+	return cmd, nil
+}
+
 // Check if CRIU supports pre-dumping and number of
 // pre-dump iterations
 func (s *migrationSourceWs) checkForPreDumpSupport() (bool, int) {
@@ -189,6 +308,38 @@ func (s *migrationSourceWs) checkForPreDumpSupport() (bool, int) {
 	return use_pre_dumps, max_iterations
 }
 
+// migrationPreDumpTuning returns the pre-copy bandwidth cap (in bytes per second) and target
+// downtime (in milliseconds) set in the instance config, 0 meaning unset.
+func migrationPreDumpTuning(config map[string]string) (uint64, uint32) {
+	var bandwidth uint64
+	var downtime uint32
+
+	if config["migration.incremental.memory.bandwidth"] != "" {
+		value, err := units.ParseByteSizeString(config["migration.incremental.memory.bandwidth"])
+		if err == nil && value > 0 {
+			bandwidth = uint64(value)
+		}
+	}
+
+	if config["migration.incremental.memory.downtime"] != "" {
+		value, err := strconv.ParseUint(config["migration.incremental.memory.downtime"], 10, 32)
+		if err == nil {
+			downtime = uint32(value)
+		}
+	}
+
+	return bandwidth, downtime
+}
+
+// migrationTuningMin returns the lowest non-zero value of a and b, 0 if both are unset.
+func migrationTuningMin(a uint64, b uint64) uint64 {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+
+	return a
+}
+
 // The function readCriuStatsDump() reads the CRIU 'stats-dump' file
 // in path and returns the pages_written, pages_skipped_parent, error.
 func readCriuStatsDump(path string) (uint64, uint64, error) {
@@ -234,6 +385,7 @@ type preDumpLoopArgs struct {
 	dumpDir       string
 	final         bool
 	rsyncFeatures []string
+	downtime      time.Duration
 }
 
 // The function preDumpLoop is the main logic behind the pre-copy migration.
@@ -269,10 +421,12 @@ func (s *migrationSourceWs) preDumpLoop(args *preDumpLoopArgs) (bool, error) {
 	// Send the pre-dump.
 	ctName, _, _ := shared.InstanceGetParentAndSnapshotName(s.instance.Name())
 	state := s.instance.DaemonState()
+	start := time.Now()
 	err = rsync.Send(ctName, shared.AddSlash(args.checkpointDir), &shared.WebsocketIO{Conn: s.criuConn}, nil, args.rsyncFeatures, args.bwlimit, state.OS.ExecPath)
 	if err != nil {
 		return final, err
 	}
+	transferTime := time.Since(start)
 
 	// Read the CRIU's 'stats-dump' file
 	dumpPath := shared.AddSlash(args.checkpointDir)
@@ -308,6 +462,14 @@ func (s *migrationSourceWs) preDumpLoop(args *preDumpLoopArgs) (bool, error) {
 		final = true
 	}
 
+	// The final dump transfers about as many pages as this pre-dump did, so stop once
+	// that fits in the target downtime.
+	if args.downtime > 0 && transferTime <= args.downtime {
+		logger.Debugf("Pre-dump transferred in %s which is within the target downtime (%s)", transferTime, args.downtime)
+		logger.Debugf("This was the last pre-dump; next dump is the final dump")
+		final = true
+	}
+
 	// If in pre-dump mode, the receiving side
 	// expects a message to know if this was the
 	// last pre-dump
@@ -452,8 +614,33 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 		offerUsePreDumps, maxDumpIterations = s.checkForPreDumpSupport()
 	}
 
+	// Add the optional CRIU features to source header. Lazy pages replace pre-dumps.
+	if s.live {
+		offerHeader.CriuFeatures = &migration.CriuFeatures{
+			TcpEstablished: proto.Bool(shared.IsTrue(s.instance.ExpandedConfig()["migration.criu.tcp_established"])),
+			LazyPages:      proto.Bool(shared.IsTrue(s.instance.ExpandedConfig()["migration.criu.lazy_pages"]) && criuFeatureSupported(ct, lxc.FEATURE_LAZY_PAGES)),
+		}
+
+		if offerHeader.CriuFeatures.GetLazyPages() {
+			offerUsePreDumps = false
+		}
+	}
+
 	offerHeader.Predump = proto.Bool(offerUsePreDumps)
 
+	// Add the pre-copy tuning to source header, the target may lower it.
+	preDumpBandwidth, preDumpDowntime := migrationPreDumpTuning(s.instance.ExpandedConfig())
+	if offerUsePreDumps {
+		// At least one pre-dump is always sent.
+		if maxDumpIterations < 1 {
+			maxDumpIterations = 1
+		}
+
+		offerHeader.PredumpMaxIterations = proto.Uint32(uint32(maxDumpIterations))
+		offerHeader.PredumpBandwidth = proto.Uint64(preDumpBandwidth)
+		offerHeader.PredumpDowntime = proto.Uint32(preDumpDowntime)
+	}
+
 	// Send offer to target.
 	err = s.send(&offerHeader)
 	if err != nil {
@@ -584,6 +771,10 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 			return abort(err)
 		}
 
+		criuFeatures := respHeader.GetCriuFeatures()
+		lazyPages := false
+		pageServerPort := 0
+
 		if util.RuntimeLiblxcVersionAtLeast(2, 0, 4) {
 			// What happens below is slightly convoluted. Due to various complications
 			// with networking, there's no easy way for criu to exit and leave the
@@ -656,6 +847,27 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 			// rsync protocol.
 			if respHeader.GetPredump() {
 				logger.Debugf("The other side does support pre-copy")
+
+				// Older targets don't answer with the pre-copy tuning.
+				if respHeader.PredumpMaxIterations != nil {
+					maxDumpIterations = int(respHeader.GetPredumpMaxIterations())
+					preDumpBandwidth = respHeader.GetPredumpBandwidth()
+					preDumpDowntime = respHeader.GetPredumpDowntime()
+				}
+
+				preDumpBwlimit := rsyncBwlimit
+				if preDumpBandwidth > 0 {
+					// rsync takes the limit in KiB per second.
+					limit := preDumpBandwidth / 1024
+					if limit == 0 {
+						limit = 1
+					}
+
+					preDumpBwlimit = fmt.Sprintf("%d", limit)
+				}
+
+				logger.Debugf("Pre-copy with at most %d iterations, a %s bandwidth limit and a %dms target downtime", maxDumpIterations, preDumpBwlimit, preDumpDowntime)
+
 				final := false
 				for !final {
 					preDumpCounter++
@@ -667,19 +879,19 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 					dumpDir := fmt.Sprintf("%03d", preDumpCounter)
 					loopArgs := preDumpLoopArgs{
 						checkpointDir: checkpointDir,
-						bwlimit:       rsyncBwlimit,
+						bwlimit:       preDumpBwlimit,
 						preDumpDir:    preDumpDir,
 						dumpDir:       dumpDir,
 						final:         final,
 						rsyncFeatures: rsyncFeatures,
+						downtime:      time.Duration(preDumpDowntime) * time.Millisecond,
 					}
 					final, err = s.preDumpLoop(&loopArgs)
 					if err != nil {
 						os.RemoveAll(checkpointDir)
 						return abort(err)
 					}
-					preDumpDir = fmt.Sprintf("%03d", preDumpCounter)
-					preDumpCounter++
+					preDumpDir = dumpDir
 				}
 			} else {
 				logger.Debugf("The other side does not support pre-copy")
@@ -691,15 +903,40 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 				return abort(err)
 			}
 
+			// With lazy pages, CRIU keeps the memory of the container and serves it from
+			// a page server until the target has fetched all of it. The action script
+			// only runs after that, so the restore starts as soon as the page server
+			// is listening.
+			pageServerReady := make(chan error, 1)
+			if criuFeatures.GetLazyPages() {
+				lazyPages = true
+
+				pageServerPort, err = shared.AllocatePort()
+				if err != nil {
+					os.RemoveAll(checkpointDir)
+					return abort(err)
+				}
+
+				stopWaiting := make(chan struct{})
+				defer close(stopWaiting)
+
+				go func() {
+					pageServerReady <- waitForLocalListener(pageServerPort, stopWaiting)
+				}()
+			}
+
 			go func() {
 				criuMigrationArgs := CriuMigrationArgs{
-					cmd:          lxc.MIGRATE_DUMP,
-					stop:         true,
-					actionScript: true,
-					preDumpDir:   preDumpDir,
-					dumpDir:      "final",
-					stateDir:     checkpointDir,
-					function:     "migration",
+					cmd:            lxc.MIGRATE_DUMP,
+					stop:           true,
+					actionScript:   true,
+					preDumpDir:     preDumpDir,
+					dumpDir:        "final",
+					stateDir:       checkpointDir,
+					function:       "migration",
+					tcpEstablished: criuFeatures.GetTcpEstablished(),
+					lazyPages:      lazyPages,
+					pageServerPort: pageServerPort,
 				}
 
 				// Do the final CRIU dump. This is needs no special handling if
@@ -715,18 +952,26 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 			// The dump finished, let's continue on to the restore.
 			case <-dumpDone:
 				logger.Debugf("Dump finished, continuing with restore...")
+			// The page server is up, let's continue on to the restore.
+			case err = <-pageServerReady:
+				if err != nil {
+					return abort(err)
+				}
+
+				logger.Debugf("Dump finished, serving lazy pages and continuing with restore...")
 			}
 		} else {
 			logger.Debugf("The version of liblxc is older than 2.0.4 and the live migration will probably fail")
 			defer os.RemoveAll(checkpointDir)
 			criuMigrationArgs := CriuMigrationArgs{
-				cmd:          lxc.MIGRATE_DUMP,
-				stateDir:     checkpointDir,
-				function:     "migration",
-				stop:         true,
-				actionScript: false,
-				dumpDir:      "final",
-				preDumpDir:   "",
+				cmd:            lxc.MIGRATE_DUMP,
+				stateDir:       checkpointDir,
+				function:       "migration",
+				stop:           true,
+				actionScript:   false,
+				dumpDir:        "final",
+				preDumpDir:     "",
+				tcpEstablished: criuFeatures.GetTcpEstablished(),
 			}
 
 			err = ct.Migrate(&criuMigrationArgs)
@@ -745,6 +990,16 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 		if err != nil {
 			return abort(err)
 		}
+
+		// The memory then follows over the CRIU websocket as the target requests it.
+		if lazyPages {
+			conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", pageServerPort))
+			if err != nil {
+				return abort(errors.Wrap(err, "Failed to connect to the CRIU page server"))
+			}
+
+			go proxyCriuConn(conn, s.criuConn)
+		}
 	}
 
 	// If s.live is true or Criu is set to CRIUTYPE_NONE rather than nil, it indicates
@@ -1096,10 +1351,40 @@ func (c *migrationSink) Do(state *state.State, migrateOp *operations.Operation)
 	if offerHeader.GetPredump() == true {
 		// If the other side wants pre-dump and if this side supports it, let's use it.
 		respHeader.Predump = proto.Bool(true)
+
+		// Agree on the lowest of the pre-copy tuning set on both sides.
+		if offerHeader.PredumpMaxIterations != nil {
+			config := c.src.instance.ExpandedConfig()
+			maxIterations := uint64(offerHeader.GetPredumpMaxIterations())
+			if config["migration.incremental.memory.iterations"] != "" {
+				value, err := strconv.ParseUint(config["migration.incremental.memory.iterations"], 10, 32)
+				if err == nil {
+					maxIterations = migrationTuningMin(maxIterations, value)
+				}
+			}
+
+			bandwidth, downtime := migrationPreDumpTuning(config)
+			respHeader.PredumpMaxIterations = proto.Uint32(uint32(maxIterations))
+			respHeader.PredumpBandwidth = proto.Uint64(migrationTuningMin(offerHeader.GetPredumpBandwidth(), bandwidth))
+			respHeader.PredumpDowntime = proto.Uint32(uint32(migrationTuningMin(uint64(offerHeader.GetPredumpDowntime()), uint64(downtime))))
+		}
 	} else {
 		respHeader.Predump = proto.Bool(false)
 	}
 
+	// Only keep the CRIU features which this side supports too.
+	if offerHeader.CriuFeatures != nil && live && c.src.instance.Type() == instancetype.Container {
+		ct := c.src.instance.(*containerLXC)
+		respHeader.CriuFeatures = &migration.CriuFeatures{
+			TcpEstablished: proto.Bool(offerHeader.CriuFeatures.GetTcpEstablished()),
+			LazyPages:      proto.Bool(offerHeader.CriuFeatures.GetLazyPages() && criuFeatureSupported(ct, lxc.FEATURE_LAZY_PAGES)),
+		}
+
+		if respHeader.CriuFeatures.GetLazyPages() {
+			respHeader.Predump = proto.Bool(false)
+		}
+	}
+
 	// Get rsync options from sender, these are passed into mySink function as part of
 	// MigrationSinkArgs below.
 	rsyncFeatures := respHeader.GetRsyncFeaturesSlice()
@@ -1113,6 +1398,7 @@ func (c *migrationSink) Do(state *state.State, migrateOp *operations.Operation)
 	restore := make(chan error)
 	go func(c *migrationSink) {
 		imagesDir := ""
+		restored := false
 		srcIdmap := new(idmap.IdmapSet)
 
 		for _, idmapSet := range offerHeader.Idmap {
@@ -1219,7 +1505,15 @@ func (c *migrationSink) Do(state *state.State, migrateOp *operations.Operation)
 			}
 
 			if respHeader.GetPredump() {
+				preDumpCounter := uint32(0)
 				for !sync.GetFinalPreDump() {
+					// The source must stop pre-copying at the agreed iterations.
+					preDumpCounter++
+					if respHeader.PredumpMaxIterations != nil && preDumpCounter > respHeader.GetPredumpMaxIterations() {
+						restore <- fmt.Errorf("Source sent more than the %d agreed pre-dumps", respHeader.GetPredumpMaxIterations())
+						return
+					}
+
 					logger.Debugf("About to receive rsync")
 					// Transfer a CRIU pre-dump.
 					err = rsync.Recv(shared.AddSlash(imagesDir), &shared.WebsocketIO{Conn: criuConn}, nil, rsyncFeatures)
@@ -1255,6 +1549,25 @@ func (c *migrationSink) Do(state *state.State, migrateOp *operations.Operation)
 				restore <- err
 				return
 			}
+
+			// With lazy pages, the memory gets fetched from the source over the CRIU
+			// websocket by the lazy-pages daemon as the restored container accesses it.
+			if respHeader.GetCriuFeatures().GetLazyPages() {
+				daemon, err := startLazyPagesDaemon(filepath.Join(imagesDir, "final"), criuConn)
+				if err != nil {
+					restore <- err
+					return
+				}
+
+				// Keep the images around until the daemon is done with them.
+				defer func() {
+					if !restored {
+						daemon.Process.Kill()
+					}
+
+					daemon.Wait()
+				}()
+			}
 		}
 
 		err := <-fsTransfer
@@ -1265,13 +1578,15 @@ func (c *migrationSink) Do(state *state.State, migrateOp *operations.Operation)
 
 		if live {
 			criuMigrationArgs := CriuMigrationArgs{
-				cmd:          lxc.MIGRATE_RESTORE,
-				stateDir:     imagesDir,
-				function:     "migration",
-				stop:         false,
-				actionScript: false,
-				dumpDir:      "final",
-				preDumpDir:   "",
+				cmd:            lxc.MIGRATE_RESTORE,
+				stateDir:       imagesDir,
+				function:       "migration",
+				stop:           false,
+				actionScript:   false,
+				dumpDir:        "final",
+				preDumpDir:     "",
+				tcpEstablished: respHeader.GetCriuFeatures().GetTcpEstablished(),
+				lazyPages:      respHeader.GetCriuFeatures().GetLazyPages(),
 			}
 
 			// Currently we only do a single CRIU pre-dump so we can hardcode "final"
@@ -1286,6 +1601,7 @@ func (c *migrationSink) Do(state *state.State, migrateOp *operations.Operation)
 			}
 		}
 
+		restored = true
 		restore <- nil
 	}(c)
 
@@ -153,6 +153,11 @@ func (s *migrationSourceWs) DoStorage(state *state.State, poolName string, volNa
 			return err
 		}
 
+		// When refreshing, the target tells us which snapshots it's missing.
+		if respHeader.GetRefresh() {
+			snapshotNames = respHeader.GetSnapshotNames()
+		}
+
 		volSourceArgs := migration.VolumeSourceArgs{
 			Name:          volName,
 			MigrationType: migrationType,
@@ -236,11 +241,12 @@ func (s *migrationSourceWs) DoStorage(state *state.State, poolName string, volNa
 
 func NewStorageMigrationSink(args *MigrationSinkArgs) (*migrationSink, error) {
 	sink := migrationSink{
-		src:    migrationFields{storage: args.Storage, volumeOnly: args.VolumeOnly},
-		dest:   migrationFields{storage: args.Storage, volumeOnly: args.VolumeOnly},
-		url:    args.Url,
-		dialer: args.Dialer,
-		push:   args.Push,
+		src:     migrationFields{storage: args.Storage, volumeOnly: args.VolumeOnly},
+		dest:    migrationFields{storage: args.Storage, volumeOnly: args.VolumeOnly},
+		url:     args.Url,
+		dialer:  args.Dialer,
+		push:    args.Push,
+		refresh: args.Refresh,
 	}
 
 	if sink.push {
@@ -356,6 +362,25 @@ func (c *migrationSink) DoStorage(state *state.State, poolName string, req *api.
 		respHeader.SnapshotNames = offerHeader.SnapshotNames
 		respHeader.Snapshots = offerHeader.Snapshots
 
+		// When refreshing, only request the snapshots we don't have yet and remove the ones
+		// which are gone from the source.
+		if c.refresh {
+			respHeader.Refresh = &c.refresh
+		}
+
+		if c.refresh && !c.src.volumeOnly {
+			respHeader.Snapshots, err = storageVolumeRefreshSnapshots(state, pool, req.Name, offerHeader.Snapshots)
+			if err != nil {
+				controller(err)
+				return err
+			}
+
+			respHeader.SnapshotNames = make([]string, 0, len(respHeader.Snapshots))
+			for _, snap := range respHeader.Snapshots {
+				respHeader.SnapshotNames = append(respHeader.SnapshotNames, snap.GetName())
+			}
+		}
+
 		// Translate the legacy MigrationSinkArgs to a VolumeTargetArgs suitable for use
 		// with the new storage layer.
 		myTarget = func(conn *websocket.Conn, op *operations.Operation, args MigrationSinkArgs) error {
@@ -365,6 +390,7 @@ func (c *migrationSink) DoStorage(state *state.State, poolName string, req *api.
 				Description:   req.Description,
 				MigrationType: respType,
 				TrackProgress: true,
+				Refresh:       c.refresh,
 			}
 
 			// A zero length Snapshots slice indicates volume only migration in
@@ -379,6 +405,12 @@ func (c *migrationSink) DoStorage(state *state.State, poolName string, req *api.
 			return pool.CreateCustomVolumeFromMigration(&shared.WebsocketIO{Conn: conn}, volTargetArgs, op)
 		}
 	} else {
+		if c.refresh {
+			err = fmt.Errorf("Refreshing custom volumes isn't supported by this storage driver")
+			controller(err)
+			return err
+		}
+
 		// Setup legacy storage migration sink if destination pool isn't supported yet by
 		// new storage layer.
 		storage, err := storagePoolVolumeDBCreateInternal(state, poolName, req)
@@ -536,3 +568,41 @@ func volumeSnapshotToProtobuf(vol *api.StorageVolume) *migration.Snapshot {
 		LastUsedDate: proto.Int64(0),
 	}
 }
+
+// storageVolumeRefreshSnapshots deletes the snapshots of a custom volume which aren't in the
+// offered list anymore and returns the offered snapshots the volume doesn't have yet.
+func storageVolumeRefreshSnapshots(state *state.State, pool storagePools.Pool, volName string, offered []*migration.Snapshot) ([]*migration.Snapshot, error) {
+	snapshots, err := storagePools.VolumeSnapshotsGet(state, pool.Name(), volName, storagePoolVolumeTypeCustom)
+	if err != nil {
+		return nil, err
+	}
+
+	offeredNames := make([]string, 0, len(offered))
+	for _, snap := range offered {
+		offeredNames = append(offeredNames, snap.GetName())
+	}
+
+	existing := []string{}
+	for _, snapshot := range snapshots {
+		_, snapName, _ := shared.InstanceGetParentAndSnapshotName(snapshot.Name)
+		if !shared.StringInSlice(snapName, offeredNames) {
+			err = pool.DeleteCustomVolumeSnapshot(snapshot.Name, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		existing = append(existing, snapName)
+	}
+
+	missing := []*migration.Snapshot{}
+	for _, snap := range offered {
+		if !shared.StringInSlice(snap.GetName(), existing) {
+			missing = append(missing, snap)
+		}
+	}
+
+	return missing, nil
+}
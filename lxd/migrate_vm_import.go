@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/migration"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/state"
+	storagePools "github.com/lxc/lxd/lxd/storage"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// vmImportBlockSize is the size of the chunks written to the VM disk, all-zero chunks are skipped.
+const vmImportBlockSize = 4 * 1024 * 1024
+
+// vmImportSink receives a raw disk image pushed by an external tool into a VM's root volume.
+//
+// The protocol is:
+//   - The raw disk content is sent as binary messages over the "fs" websocket, terminated by an
+//     empty text message.
+//   - The sender then sends a MigrationControl message over the "control" websocket whose message
+//     is the hex encoded SHA256 of the disk content (or empty to skip verification).
+//   - The sink replies with a MigrationControl message indicating success or failure.
+type vmImportSink struct {
+	inst instance.Instance
+
+	controlSecret string
+	controlConn   *websocket.Conn
+	fsSecret      string
+	fsConn        *websocket.Conn
+
+	allConnected chan bool
+}
+
+func newVMImportSink(inst instance.Instance) (*vmImportSink, error) {
+	var err error
+
+	sink := vmImportSink{
+		inst:         inst,
+		allConnected: make(chan bool),
+	}
+
+	sink.controlSecret, err = shared.RandomCryptoString()
+	if err != nil {
+		return nil, err
+	}
+
+	sink.fsSecret, err = shared.RandomCryptoString()
+	if err != nil {
+		return nil, err
+	}
+
+	return &sink, nil
+}
+
+// Metadata returns the websocket secrets.
+func (s *vmImportSink) Metadata() interface{} {
+	return shared.Jmap{
+		"control": s.controlSecret,
+		"fs":      s.fsSecret,
+	}
+}
+
+// Connect handles the websocket connections of the sender.
+func (s *vmImportSink) Connect(op *operations.Operation, r *http.Request, w http.ResponseWriter) error {
+	secret := r.FormValue("secret")
+	if secret == "" {
+		return fmt.Errorf("missing secret")
+	}
+
+	var conn **websocket.Conn
+
+	switch secret {
+	case s.controlSecret:
+		conn = &s.controlConn
+	case s.fsSecret:
+		conn = &s.fsConn
+	default:
+		return os.ErrPermission
+	}
+
+	c, err := shared.WebsocketUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return err
+	}
+
+	*conn = c
+
+	if s.controlConn != nil && s.fsConn != nil {
+		s.allConnected <- true
+	}
+
+	return nil
+}
+
+// Do creates the VM's root volume and writes the received disk into it.
+func (s *vmImportSink) Do(state *state.State, op *operations.Operation) error {
+	<-s.allConnected
+
+	defer func() {
+		for _, conn := range []*websocket.Conn{s.controlConn, s.fsConn} {
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			conn.Close()
+		}
+	}()
+
+	checksum, err := s.receiveDisk(state, op)
+	if err == nil {
+		// Wait for the sender to tell us the expected checksum.
+		msg := migration.MigrationControl{}
+		err = migration.ProtoRecv(s.controlConn, &msg)
+		if err == nil {
+			if !msg.GetSuccess() {
+				err = fmt.Errorf("Sender failed: %s", msg.GetMessage())
+			} else if msg.GetMessage() != "" && msg.GetMessage() != checksum {
+				err = fmt.Errorf("Disk checksum mismatch, expected %q but got %q", msg.GetMessage(), checksum)
+			}
+		}
+	}
+
+	migration.ProtoSendControl(s.controlConn, err)
+	return err
+}
+
+// receiveDisk writes the content of the fs websocket to the VM disk and returns its SHA256.
+func (s *vmImportSink) receiveDisk(state *state.State, op *operations.Operation) (string, error) {
+	pool, err := storagePools.GetPoolByInstance(state, s.inst)
+	if err != nil {
+		return "", err
+	}
+
+	err = pool.CreateInstance(s.inst, op)
+	if err != nil {
+		return "", err
+	}
+
+	ourMount, err := pool.MountInstance(s.inst, op)
+	if err != nil {
+		return "", err
+	}
+
+	if ourMount {
+		defer pool.UnmountInstance(s.inst, op)
+	}
+
+	diskPath, err := pool.GetInstanceDisk(s.inst)
+	if err != nil {
+		return "", err
+	}
+
+	disk, err := os.OpenFile(diskPath, os.O_WRONLY, 0)
+	if err != nil {
+		return "", err
+	}
+	defer disk.Close()
+
+	// Works for both block devices and image files.
+	diskSize, err := disk.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = disk.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	reader := io.TeeReader(&shared.WebsocketIO{Conn: s.fsConn}, hash)
+	buf := make([]byte, vmImportBlockSize)
+	zero := make([]byte, vmImportBlockSize)
+	var written int64
+
+	for {
+		n, err := io.ReadFull(reader, buf)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return "", err
+		}
+
+		if written+int64(n) > diskSize {
+			return "", fmt.Errorf("Received disk is larger than the instance's root disk (%d bytes)", diskSize)
+		}
+
+		// The volume is freshly created so it reads as zero already, skip writing those blocks
+		// to keep thin and sparse volumes small.
+		if bytes.Equal(buf[:n], zero[:n]) {
+			_, err = disk.Seek(int64(n), io.SeekCurrent)
+		} else {
+			_, err = disk.Write(buf[:n])
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		written += int64(n)
+	}
+
+	err = disk.Sync()
+	if err != nil {
+		return "", err
+	}
+
+	logger.Debugf("Received %d bytes into the root disk of %q", written, s.inst.Name())
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
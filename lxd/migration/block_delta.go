@@ -0,0 +1,332 @@
+package migration
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lxc/lxd/shared/ioprogress"
+)
+
+// blockDeltaChunkSize is the size of the chunks disks are compared and sent in. Block devices and
+// disk images are updated in place, so chunks are compared at fixed offsets rather than by looking
+// for moved data as rsync does for files.
+const blockDeltaChunkSize = 1024 * 1024
+
+// blockDeltaZero is the frame length indicating that a chunk is all zeroes.
+const blockDeltaZero = 0
+
+// blockDeltaFrameHeaderSize is the size of the index and length preceding each chunk.
+const blockDeltaFrameHeaderSize = 12
+
+// SendBlockDelta sends the disk at path to the other end of the connection, which is expected to
+// run RecvBlockDelta. The receiver first sends the checksums of the chunks of its own copy of the
+// disk and only the chunks which differ are then sent, allowing existing volumes to be refreshed
+// without transferring them in full. Chunks which only contain zeroes are never sent in full.
+func SendBlockDelta(conn io.ReadWriteCloser, path string, tracker *ioprogress.ProgressTracker) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	// Receive the checksums of the chunks the receiver already has.
+	reader := bufio.NewReader(&blockDeltaConn{ReadWriteCloser: conn})
+	var count uint64
+	err = binary.Read(reader, binary.BigEndian, &count)
+	if err != nil {
+		return fmt.Errorf("Failed to receive block checksums: %v", err)
+	}
+
+	// Only keep the checksums of chunks within the disk being sent, the receiver's copy may be larger.
+	checksums := [][sha256.Size]byte{}
+	for i := uint64(0); i < count; i++ {
+		var checksum [sha256.Size]byte
+		_, err = io.ReadFull(reader, checksum[:])
+		if err != nil {
+			return fmt.Errorf("Failed to receive block checksums: %v", err)
+		}
+
+		if int64(i)*blockDeltaChunkSize < size {
+			checksums = append(checksums, checksum)
+		}
+	}
+
+	count = uint64(len(checksums))
+
+	err = blockDeltaWaitEnd(reader)
+	if err != nil {
+		return err
+	}
+
+	var target io.WriteCloser = conn
+	if tracker != nil {
+		target = &ioprogress.ProgressWriter{WriteCloser: conn, Tracker: tracker}
+	}
+
+	writer := bufio.NewWriterSize(target, blockDeltaChunkSize+blockDeltaFrameHeaderSize)
+	err = binary.Write(writer, binary.BigEndian, uint64(size))
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, blockDeltaChunkSize)
+	for index := uint64(0); int64(index)*blockDeltaChunkSize < size; index++ {
+		n, err := f.ReadAt(buf, int64(index)*blockDeltaChunkSize)
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		chunk := buf[:n]
+		zero := blockDeltaIsZero(chunk)
+
+		// Chunks past the receiver's checksums are zeroes on its side.
+		if index < count {
+			if sha256.Sum256(chunk) == checksums[index] {
+				continue
+			}
+		} else if zero {
+			continue
+		}
+
+		length := uint32(len(chunk))
+		if zero {
+			length = blockDeltaZero
+		}
+
+		err = binary.Write(writer, binary.BigEndian, index)
+		if err != nil {
+			return err
+		}
+
+		err = binary.Write(writer, binary.BigEndian, length)
+		if err != nil {
+			return err
+		}
+
+		if !zero {
+			_, err = writer.Write(chunk)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	return conn.Close() // Sends the end of stream barrier.
+}
+
+// RecvBlockDelta updates the disk at path from the other end of the connection, which is expected
+// to run SendBlockDelta. When refresh is false the disk is known to only contain zeroes, such as a
+// newly created sparse image, and no checksums are computed. Disk image files are resized to the
+// size of the sent disk, block devices must be large enough to hold it.
+func RecvBlockDelta(conn io.ReadWriteCloser, path string, refresh bool, tracker *ioprogress.ProgressTracker) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	isFile := fi.Mode().IsRegular()
+
+	// Send the checksums of the chunks we already have.
+	writer := bufio.NewWriter(conn)
+	checksums := [][sha256.Size]byte{}
+	if refresh {
+		size, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+
+		buf := make([]byte, blockDeltaChunkSize)
+		for offset := int64(0); offset < size; offset += blockDeltaChunkSize {
+			n, err := f.ReadAt(buf, offset)
+			if err != nil && err != io.EOF {
+				return err
+			}
+
+			checksums = append(checksums, sha256.Sum256(buf[:n]))
+		}
+	}
+
+	err = binary.Write(writer, binary.BigEndian, uint64(len(checksums)))
+	if err != nil {
+		return err
+	}
+
+	for _, checksum := range checksums {
+		_, err = writer.Write(checksum[:])
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	err = conn.Close() // Sends the end of stream barrier.
+	if err != nil {
+		return err
+	}
+
+	// Receive the changed chunks.
+	var source io.ReadCloser = &blockDeltaConn{ReadWriteCloser: conn}
+	if tracker != nil {
+		source = &ioprogress.ProgressReader{ReadCloser: source, Tracker: tracker}
+	}
+
+	reader := bufio.NewReaderSize(source, blockDeltaChunkSize+blockDeltaFrameHeaderSize)
+
+	var size uint64
+	err = binary.Read(reader, binary.BigEndian, &size)
+	if err != nil {
+		return fmt.Errorf("Failed to receive disk size: %v", err)
+	}
+
+	if isFile {
+		err = f.Truncate(int64(size))
+		if err != nil {
+			return err
+		}
+	} else {
+		deviceSize, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+
+		if uint64(deviceSize) < size {
+			return fmt.Errorf("Block device %q is smaller than the volume being received (%d < %d bytes)", path, deviceSize, size)
+		}
+	}
+
+	buf := make([]byte, blockDeltaChunkSize)
+	zeroes := make([]byte, blockDeltaChunkSize)
+	for {
+		var index uint64
+		err = binary.Read(reader, binary.BigEndian, &index)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("Failed to receive block: %v", err)
+		}
+
+		var length uint32
+		err = binary.Read(reader, binary.BigEndian, &length)
+		if err != nil {
+			return fmt.Errorf("Failed to receive block: %v", err)
+		}
+
+		offset := int64(index) * blockDeltaChunkSize
+		if length > blockDeltaChunkSize || uint64(offset) >= size {
+			return fmt.Errorf("Received invalid block %d", index)
+		}
+
+		data := buf[:length]
+		if length == blockDeltaZero {
+			data = zeroes
+			if uint64(offset)+uint64(len(data)) > size {
+				data = zeroes[:size-uint64(offset)]
+			}
+		} else {
+			_, err = io.ReadFull(reader, data)
+			if err != nil {
+				return fmt.Errorf("Failed to receive block: %v", err)
+			}
+		}
+
+		_, err = f.WriteAt(data, offset)
+		if err != nil {
+			return err
+		}
+	}
+
+	return f.Sync()
+}
+
+// blockDeltaConn wraps the migration connection for buffered readers. Some connections return a
+// negative count at the end of stream, which buffered readers don't accept, and message based ones
+// may drop the part of a message which doesn't fit in the buffer passed to Read, so messages are
+// read whole into a buffer large enough for the biggest frame.
+type blockDeltaConn struct {
+	io.ReadWriteCloser
+	buf     []byte
+	pending []byte
+}
+
+// Read reads from the connection.
+func (c *blockDeltaConn) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 {
+		if c.buf == nil {
+			c.buf = make([]byte, blockDeltaChunkSize+blockDeltaFrameHeaderSize)
+		}
+
+		n, err := c.ReadWriteCloser.Read(c.buf)
+		if n < 0 {
+			n = 0
+		}
+
+		c.pending = c.buf[:n]
+		if n == 0 {
+			return 0, err
+		}
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+
+	return n, nil
+}
+
+// blockDeltaWaitEnd reads the end of stream barrier sent by the other end after a message.
+func blockDeltaWaitEnd(reader io.Reader) error {
+	buf := make([]byte, 1)
+	n, err := reader.Read(buf)
+	if n > 0 {
+		return fmt.Errorf("Unexpected data in block checksums")
+	}
+
+	if err != io.EOF {
+		return fmt.Errorf("Failed to receive end of block checksums: %v", err)
+	}
+
+	return nil
+}
+
+// blockDeltaIsZero returns whether the chunk only contains zeroes.
+func blockDeltaIsZero(chunk []byte) bool {
+	zeroes := make([]byte, 4096)
+	for len(chunk) > 0 {
+		n := len(chunk)
+		if n > len(zeroes) {
+			n = len(zeroes)
+		}
+
+		if !bytes.Equal(chunk[:n], zeroes[:n]) {
+			return false
+		}
+
+		chunk = chunk[n:]
+	}
+
+	return true
+}
@@ -0,0 +1,94 @@
+package migration
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/storage/memorypipe"
+)
+
+// countingConn counts the bytes written to the connection.
+type countingConn struct {
+	io.ReadWriteCloser
+	written int64
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Write(p)
+	c.written += int64(n)
+	return n, err
+}
+
+// Transfer the disk at src to dst and return the number of bytes sent.
+func blockDeltaTransfer(t *testing.T, src string, dst string, refresh bool) int64 {
+	aEnd, bEnd := memorypipe.NewPipePair()
+	sender := &countingConn{ReadWriteCloser: aEnd}
+
+	errCh := make(chan error, 1)
+	go func() {
+		err := SendBlockDelta(sender, src, nil)
+		if err != nil {
+			aEnd.Close() // Unblock the receiver.
+		}
+
+		errCh <- err
+	}()
+
+	require.NoError(t, RecvBlockDelta(bEnd, dst, refresh, nil))
+	require.NoError(t, <-errCh)
+
+	return sender.written
+}
+
+func TestBlockDelta(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-block-delta-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src.img")
+	dst := filepath.Join(dir, "dst.img")
+
+	// A 5.5MiB disk with data in the first and fourth chunks.
+	data := make([]byte, 5*blockDeltaChunkSize+blockDeltaChunkSize/2)
+	rand.Read(data[:blockDeltaChunkSize])
+	rand.Read(data[3*blockDeltaChunkSize : 4*blockDeltaChunkSize])
+	require.NoError(t, ioutil.WriteFile(src, data, 0600))
+
+	// Initial copy into an empty image, zero chunks aren't sent.
+	require.NoError(t, ioutil.WriteFile(dst, nil, 0600))
+	blockDeltaTransfer(t, src, dst, false)
+
+	content, err := ioutil.ReadFile(dst)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, content))
+
+	// Refresh after changing a single chunk and zeroing another one.
+	rand.Read(data[2*blockDeltaChunkSize : 2*blockDeltaChunkSize+10])
+	copy(data[3*blockDeltaChunkSize:4*blockDeltaChunkSize], make([]byte, blockDeltaChunkSize))
+	require.NoError(t, ioutil.WriteFile(src, data, 0600))
+
+	sent := blockDeltaTransfer(t, src, dst, true)
+	assert.True(t, sent > blockDeltaChunkSize && sent < 2*blockDeltaChunkSize, "sent %d bytes", sent)
+
+	content, err = ioutil.ReadFile(dst)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, content))
+
+	// Refresh after shrinking the source.
+	data = data[:2*blockDeltaChunkSize+100]
+	require.NoError(t, ioutil.WriteFile(src, data, 0600))
+
+	blockDeltaTransfer(t, src, dst, true)
+
+	content, err = ioutil.ReadFile(dst)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, content))
+}
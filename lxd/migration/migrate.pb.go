@@ -5,15 +5,18 @@
 Package migration is a generated protocol buffer package.
 
 It is generated from these files:
+
 	lxd/migration/migrate.proto
 
 It has these top-level messages:
+
 	IDMapType
 	Config
 	Device
 	Snapshot
 	RsyncFeatures
 	ZfsFeatures
+	CriuFeatures
 	MigrationHeader
 	MigrationControl
 	MigrationSync
@@ -41,10 +44,11 @@ const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 type MigrationFSType int32
 
 const (
-	MigrationFSType_RSYNC MigrationFSType = 0
-	MigrationFSType_BTRFS MigrationFSType = 1
-	MigrationFSType_ZFS   MigrationFSType = 2
-	MigrationFSType_RBD   MigrationFSType = 3
+	MigrationFSType_RSYNC           MigrationFSType = 0
+	MigrationFSType_BTRFS           MigrationFSType = 1
+	MigrationFSType_ZFS             MigrationFSType = 2
+	MigrationFSType_RBD             MigrationFSType = 3
+	MigrationFSType_BLOCK_AND_RSYNC MigrationFSType = 4
 )
 
 var MigrationFSType_name = map[int32]string{
@@ -52,12 +56,14 @@ var MigrationFSType_name = map[int32]string{
 	1: "BTRFS",
 	2: "ZFS",
 	3: "RBD",
+	4: "BLOCK_AND_RSYNC",
 }
 var MigrationFSType_value = map[string]int32{
-	"RSYNC": 0,
-	"BTRFS": 1,
-	"ZFS":   2,
-	"RBD":   3,
+	"RSYNC":           0,
+	"BTRFS":           1,
+	"ZFS":             2,
+	"RBD":             3,
+	"BLOCK_AND_RSYNC": 4,
 }
 
 func (x MigrationFSType) Enum() *MigrationFSType {
@@ -300,6 +306,7 @@ type RsyncFeatures struct {
 	Delete           *bool  `protobuf:"varint,2,opt,name=delete" json:"delete,omitempty"`
 	Compress         *bool  `protobuf:"varint,3,opt,name=compress" json:"compress,omitempty"`
 	Bidirectional    *bool  `protobuf:"varint,4,opt,name=bidirectional" json:"bidirectional,omitempty"`
+	Zstd             *bool  `protobuf:"varint,5,opt,name=zstd" json:"zstd,omitempty"`
 	XXX_unrecognized []byte `json:"-"`
 }
 
@@ -336,6 +343,13 @@ func (m *RsyncFeatures) GetBidirectional() bool {
 	return false
 }
 
+func (m *RsyncFeatures) GetZstd() bool {
+	if m != nil && m.Zstd != nil {
+		return *m.Zstd
+	}
+	return false
+}
+
 type ZfsFeatures struct {
 	Compress         *bool  `protobuf:"varint,1,opt,name=compress" json:"compress,omitempty"`
 	XXX_unrecognized []byte `json:"-"`
@@ -353,23 +367,52 @@ func (m *ZfsFeatures) GetCompress() bool {
 	return false
 }
 
+type CriuFeatures struct {
+	TcpEstablished   *bool  `protobuf:"varint,1,opt,name=tcpEstablished" json:"tcpEstablished,omitempty"`
+	LazyPages        *bool  `protobuf:"varint,2,opt,name=lazyPages" json:"lazyPages,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *CriuFeatures) Reset()                    { *m = CriuFeatures{} }
+func (m *CriuFeatures) String() string            { return proto.CompactTextString(m) }
+func (*CriuFeatures) ProtoMessage()               {}
+func (*CriuFeatures) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *CriuFeatures) GetTcpEstablished() bool {
+	if m != nil && m.TcpEstablished != nil {
+		return *m.TcpEstablished
+	}
+	return false
+}
+
+func (m *CriuFeatures) GetLazyPages() bool {
+	if m != nil && m.LazyPages != nil {
+		return *m.LazyPages
+	}
+	return false
+}
+
 type MigrationHeader struct {
-	Fs               *MigrationFSType `protobuf:"varint,1,req,name=fs,enum=migration.MigrationFSType" json:"fs,omitempty"`
-	Criu             *CRIUType        `protobuf:"varint,2,opt,name=criu,enum=migration.CRIUType" json:"criu,omitempty"`
-	Idmap            []*IDMapType     `protobuf:"bytes,3,rep,name=idmap" json:"idmap,omitempty"`
-	SnapshotNames    []string         `protobuf:"bytes,4,rep,name=snapshotNames" json:"snapshotNames,omitempty"`
-	Snapshots        []*Snapshot      `protobuf:"bytes,5,rep,name=snapshots" json:"snapshots,omitempty"`
-	Predump          *bool            `protobuf:"varint,7,opt,name=predump" json:"predump,omitempty"`
-	RsyncFeatures    *RsyncFeatures   `protobuf:"bytes,8,opt,name=rsyncFeatures" json:"rsyncFeatures,omitempty"`
-	Refresh          *bool            `protobuf:"varint,9,opt,name=refresh" json:"refresh,omitempty"`
-	ZfsFeatures      *ZfsFeatures     `protobuf:"bytes,10,opt,name=zfsFeatures" json:"zfsFeatures,omitempty"`
-	XXX_unrecognized []byte           `json:"-"`
+	Fs                   *MigrationFSType `protobuf:"varint,1,req,name=fs,enum=migration.MigrationFSType" json:"fs,omitempty"`
+	Criu                 *CRIUType        `protobuf:"varint,2,opt,name=criu,enum=migration.CRIUType" json:"criu,omitempty"`
+	Idmap                []*IDMapType     `protobuf:"bytes,3,rep,name=idmap" json:"idmap,omitempty"`
+	SnapshotNames        []string         `protobuf:"bytes,4,rep,name=snapshotNames" json:"snapshotNames,omitempty"`
+	Snapshots            []*Snapshot      `protobuf:"bytes,5,rep,name=snapshots" json:"snapshots,omitempty"`
+	Predump              *bool            `protobuf:"varint,7,opt,name=predump" json:"predump,omitempty"`
+	RsyncFeatures        *RsyncFeatures   `protobuf:"bytes,8,opt,name=rsyncFeatures" json:"rsyncFeatures,omitempty"`
+	Refresh              *bool            `protobuf:"varint,9,opt,name=refresh" json:"refresh,omitempty"`
+	ZfsFeatures          *ZfsFeatures     `protobuf:"bytes,10,opt,name=zfsFeatures" json:"zfsFeatures,omitempty"`
+	CriuFeatures         *CriuFeatures    `protobuf:"bytes,11,opt,name=criuFeatures" json:"criuFeatures,omitempty"`
+	PredumpMaxIterations *uint32          `protobuf:"varint,12,opt,name=predumpMaxIterations" json:"predumpMaxIterations,omitempty"`
+	PredumpBandwidth     *uint64          `protobuf:"varint,13,opt,name=predumpBandwidth" json:"predumpBandwidth,omitempty"`
+	PredumpDowntime      *uint32          `protobuf:"varint,14,opt,name=predumpDowntime" json:"predumpDowntime,omitempty"`
+	XXX_unrecognized     []byte           `json:"-"`
 }
 
 func (m *MigrationHeader) Reset()                    { *m = MigrationHeader{} }
 func (m *MigrationHeader) String() string            { return proto.CompactTextString(m) }
 func (*MigrationHeader) ProtoMessage()               {}
-func (*MigrationHeader) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*MigrationHeader) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
 
 func (m *MigrationHeader) GetFs() MigrationFSType {
 	if m != nil && m.Fs != nil {
@@ -434,6 +477,34 @@ func (m *MigrationHeader) GetZfsFeatures() *ZfsFeatures {
 	return nil
 }
 
+func (m *MigrationHeader) GetCriuFeatures() *CriuFeatures {
+	if m != nil {
+		return m.CriuFeatures
+	}
+	return nil
+}
+
+func (m *MigrationHeader) GetPredumpMaxIterations() uint32 {
+	if m != nil && m.PredumpMaxIterations != nil {
+		return *m.PredumpMaxIterations
+	}
+	return 0
+}
+
+func (m *MigrationHeader) GetPredumpBandwidth() uint64 {
+	if m != nil && m.PredumpBandwidth != nil {
+		return *m.PredumpBandwidth
+	}
+	return 0
+}
+
+func (m *MigrationHeader) GetPredumpDowntime() uint32 {
+	if m != nil && m.PredumpDowntime != nil {
+		return *m.PredumpDowntime
+	}
+	return 0
+}
+
 type MigrationControl struct {
 	Success *bool `protobuf:"varint,1,req,name=success" json:"success,omitempty"`
 	// optional failure message if sending a failure
@@ -444,7 +515,7 @@ type MigrationControl struct {
 func (m *MigrationControl) Reset()                    { *m = MigrationControl{} }
 func (m *MigrationControl) String() string            { return proto.CompactTextString(m) }
 func (*MigrationControl) ProtoMessage()               {}
-func (*MigrationControl) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*MigrationControl) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
 
 func (m *MigrationControl) GetSuccess() bool {
 	if m != nil && m.Success != nil {
@@ -468,7 +539,7 @@ type MigrationSync struct {
 func (m *MigrationSync) Reset()                    { *m = MigrationSync{} }
 func (m *MigrationSync) String() string            { return proto.CompactTextString(m) }
 func (*MigrationSync) ProtoMessage()               {}
-func (*MigrationSync) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+func (*MigrationSync) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
 
 func (m *MigrationSync) GetFinalPreDump() bool {
 	if m != nil && m.FinalPreDump != nil {
@@ -496,7 +567,7 @@ type DumpStatsEntry struct {
 func (m *DumpStatsEntry) Reset()                    { *m = DumpStatsEntry{} }
 func (m *DumpStatsEntry) String() string            { return proto.CompactTextString(m) }
 func (*DumpStatsEntry) ProtoMessage()               {}
-func (*DumpStatsEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+func (*DumpStatsEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
 
 func (m *DumpStatsEntry) GetFreezingTime() uint32 {
 	if m != nil && m.FreezingTime != nil {
@@ -587,7 +658,7 @@ type RestoreStatsEntry struct {
 func (m *RestoreStatsEntry) Reset()                    { *m = RestoreStatsEntry{} }
 func (m *RestoreStatsEntry) String() string            { return proto.CompactTextString(m) }
 func (*RestoreStatsEntry) ProtoMessage()               {}
-func (*RestoreStatsEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+func (*RestoreStatsEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
 
 func (m *RestoreStatsEntry) GetPagesCompared() uint64 {
 	if m != nil && m.PagesCompared != nil {
@@ -633,7 +704,7 @@ type StatsEntry struct {
 func (m *StatsEntry) Reset()                    { *m = StatsEntry{} }
 func (m *StatsEntry) String() string            { return proto.CompactTextString(m) }
 func (*StatsEntry) ProtoMessage()               {}
-func (*StatsEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+func (*StatsEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
 
 func (m *StatsEntry) GetDump() *DumpStatsEntry {
 	if m != nil {
@@ -656,6 +727,7 @@ func init() {
 	proto.RegisterType((*Snapshot)(nil), "migration.Snapshot")
 	proto.RegisterType((*RsyncFeatures)(nil), "migration.rsyncFeatures")
 	proto.RegisterType((*ZfsFeatures)(nil), "migration.zfsFeatures")
+	proto.RegisterType((*CriuFeatures)(nil), "migration.criuFeatures")
 	proto.RegisterType((*MigrationHeader)(nil), "migration.MigrationHeader")
 	proto.RegisterType((*MigrationControl)(nil), "migration.MigrationControl")
 	proto.RegisterType((*MigrationSync)(nil), "migration.MigrationSync")
@@ -669,71 +741,78 @@ func init() {
 func init() { proto.RegisterFile("lxd/migration/migrate.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 1047 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x55, 0xdb, 0x6e, 0xdb, 0x46,
-	0x10, 0xad, 0x6e, 0xb6, 0x38, 0x92, 0x1c, 0x65, 0x63, 0x04, 0x44, 0xd2, 0x8b, 0xca, 0xa4, 0xa8,
-	0xe2, 0x07, 0x3b, 0x55, 0x50, 0x20, 0x7d, 0x29, 0x50, 0xcb, 0x75, 0x13, 0x20, 0x71, 0x8d, 0x95,
-	0x8d, 0xa2, 0x7d, 0x21, 0x36, 0xe4, 0x50, 0x5e, 0x98, 0x37, 0xec, 0x52, 0xb6, 0xe5, 0x97, 0xa2,
-	0x9f, 0xd1, 0x0f, 0xe8, 0xf7, 0xf4, 0xa9, 0xff, 0x53, 0xec, 0x2c, 0x49, 0x53, 0x4e, 0x81, 0xbe,
-	0xed, 0x9c, 0x39, 0x3c, 0xb3, 0x3b, 0x37, 0xc2, 0xd3, 0xf8, 0x26, 0x3c, 0x48, 0xe4, 0x52, 0x89,
-	0x42, 0x66, 0x69, 0x79, 0xc2, 0xfd, 0x5c, 0x65, 0x45, 0xc6, 0x9c, 0xda, 0xe1, 0xfd, 0x0e, 0xce,
-	0xdb, 0xa3, 0xf7, 0x22, 0x3f, 0x5b, 0xe7, 0xc8, 0x76, 0xa1, 0x27, 0xf5, 0x4a, 0x86, 0x6e, 0x6b,
-	0xd2, 0x9e, 0xf6, 0xb9, 0x35, 0x2c, 0xba, 0x94, 0xa1, 0xdb, 0xae, 0xd0, 0xa5, 0x0c, 0xd9, 0x63,
-	0xd8, 0xba, 0xc8, 0x74, 0x21, 0x43, 0xb7, 0x33, 0x69, 0x4f, 0x7b, 0xbc, 0xb4, 0x18, 0x83, 0x6e,
-	0xaa, 0x65, 0xe8, 0x76, 0x09, 0xa5, 0x33, 0x7b, 0x02, 0xfd, 0x44, 0xe4, 0x4a, 0xa4, 0x4b, 0x74,
-	0x7b, 0x84, 0xd7, 0xb6, 0xf7, 0x12, 0xb6, 0xe6, 0x59, 0x1a, 0xc9, 0x25, 0x1b, 0x43, 0xe7, 0x12,
-	0xd7, 0x14, 0xdb, 0xe1, 0xe6, 0x68, 0x22, 0x5f, 0x89, 0x78, 0x85, 0x14, 0xd9, 0xe1, 0xd6, 0xf0,
-	0x7e, 0x82, 0xad, 0x23, 0xbc, 0x92, 0x01, 0x52, 0x2c, 0x91, 0x60, 0xf9, 0x09, 0x9d, 0xd9, 0x0b,
-	0xd8, 0x0a, 0x48, 0xcf, 0x6d, 0x4f, 0x3a, 0xd3, 0xc1, 0xec, 0xe1, 0x7e, 0xfd, 0xd8, 0x7d, 0x1b,
-	0x88, 0x97, 0x04, 0xef, 0xef, 0x36, 0xf4, 0x17, 0xa9, 0xc8, 0xf5, 0x45, 0x56, 0xfc, 0xa7, 0xd6,
-	0x2b, 0x18, 0xc4, 0x59, 0x20, 0xe2, 0xf9, 0xff, 0x08, 0x36, 0x59, 0xe6, 0xb1, 0xb9, 0xca, 0x22,
-	0x19, 0xa3, 0x76, 0x3b, 0x93, 0xce, 0xd4, 0xe1, 0xb5, 0xcd, 0x3e, 0x05, 0x07, 0xf3, 0x0b, 0x4c,
-	0x50, 0x89, 0x98, 0x32, 0xd4, 0xe7, 0x77, 0x00, 0xfb, 0x16, 0x86, 0x24, 0x64, 0x5f, 0xa7, 0xdd,
-	0xde, 0x47, 0xf1, 0xac, 0x87, 0x6f, 0xd0, 0x98, 0x07, 0x43, 0xa1, 0x82, 0x0b, 0x59, 0x60, 0x50,
-	0xac, 0x14, 0xba, 0x5b, 0x94, 0xe1, 0x0d, 0xcc, 0x5c, 0x4a, 0x17, 0xa2, 0xc0, 0x68, 0x15, 0xbb,
-	0xdb, 0x14, 0xb7, 0xb6, 0xd9, 0x33, 0x18, 0x05, 0x0a, 0x29, 0x80, 0x1f, 0x8a, 0x02, 0xdd, 0xfe,
-	0xa4, 0x35, 0xed, 0xf0, 0x61, 0x05, 0x1e, 0x89, 0x02, 0xd9, 0x73, 0xd8, 0x89, 0x85, 0x2e, 0xfc,
-	0x95, 0xc6, 0xd0, 0xb2, 0x1c, 0xcb, 0x32, 0xe8, 0xb9, 0xc6, 0xd0, 0xb0, 0xbc, 0x3f, 0x5a, 0x30,
-	0x52, 0x7a, 0x9d, 0x06, 0xc7, 0x28, 0x4c, 0x5c, 0x6d, 0xda, 0xe4, 0x46, 0x14, 0x85, 0xd2, 0x6e,
-	0x6b, 0xd2, 0x9a, 0xf6, 0x79, 0x69, 0x19, 0x3c, 0xc4, 0x18, 0x0b, 0x53, 0x5b, 0xc2, 0xad, 0x65,
-	0x2e, 0x1a, 0x64, 0x49, 0xae, 0x50, 0x9b, 0xec, 0x19, 0x4f, 0x6d, 0xb3, 0xe7, 0x30, 0xfa, 0x20,
-	0x43, 0xa9, 0x30, 0x30, 0xd7, 0xa2, 0x0c, 0x1a, 0xc2, 0x26, 0xe8, 0xbd, 0x80, 0xc1, 0x6d, 0xa4,
-	0xeb, 0x0b, 0x34, 0x05, 0x5b, 0x9b, 0x82, 0xde, 0x9f, 0x1d, 0x78, 0xf0, 0xbe, 0x4a, 0xee, 0x1b,
-	0x14, 0x21, 0x2a, 0xb6, 0x07, 0xed, 0x48, 0x53, 0x17, 0xec, 0xcc, 0x9e, 0x34, 0x52, 0x5f, 0xf3,
-	0x8e, 0x17, 0x66, 0x56, 0x78, 0x3b, 0xd2, 0xec, 0x6b, 0xe8, 0x06, 0x4a, 0xae, 0xe8, 0x09, 0x3b,
-	0xb3, 0x47, 0xcd, 0xc6, 0xe0, 0x6f, 0xcf, 0x89, 0x46, 0x04, 0xb6, 0x07, 0x3d, 0x19, 0x26, 0x22,
-	0xa7, 0x86, 0x18, 0xcc, 0x76, 0x1b, 0xcc, 0x7a, 0xfa, 0xb8, 0xa5, 0x98, 0x57, 0xea, 0xb2, 0x29,
-	0x4f, 0x44, 0x82, 0xda, 0xed, 0x52, 0x13, 0x6d, 0x82, 0xec, 0x1b, 0x70, 0x2a, 0xa0, 0x6a, 0x94,
-	0x66, 0xfc, 0xaa, 0xad, 0xf9, 0x1d, 0x8b, 0xb9, 0xb0, 0x9d, 0x2b, 0x0c, 0x57, 0x49, 0xee, 0x6e,
-	0x53, 0x22, 0x2a, 0x93, 0x7d, 0x7f, 0xaf, 0x6a, 0xd4, 0x01, 0x83, 0x99, 0xdb, 0x10, 0xdc, 0xf0,
-	0xf3, 0x7b, 0x45, 0x76, 0x61, 0x5b, 0x61, 0xa4, 0x50, 0x5f, 0x50, 0x57, 0xf4, 0x79, 0x65, 0xb2,
-	0xd7, 0x1b, 0xc5, 0x70, 0x81, 0x74, 0x1f, 0x37, 0x74, 0x1b, 0x5e, 0xde, 0xa4, 0x7a, 0xc7, 0x30,
-	0xae, 0x53, 0x3e, 0xcf, 0xd2, 0x42, 0x65, 0xb1, 0x89, 0xa3, 0x57, 0x41, 0x60, 0x4b, 0x69, 0x9a,
-	0xb8, 0x32, 0x8d, 0x27, 0x41, 0xad, 0xc5, 0xd2, 0xf6, 0x93, 0xc3, 0x2b, 0xd3, 0x7b, 0x05, 0xa3,
-	0x5a, 0x67, 0xb1, 0x4e, 0x03, 0x33, 0x2e, 0x91, 0x4c, 0x45, 0x7c, 0xaa, 0xf0, 0xc8, 0xe4, 0xc2,
-	0x2a, 0x6d, 0x60, 0xde, 0x5f, 0x1d, 0x18, 0x9b, 0xcc, 0xf8, 0x66, 0x48, 0xb4, 0x8f, 0x69, 0xa1,
-	0xd6, 0x66, 0x4e, 0x22, 0x85, 0x78, 0x2b, 0xd3, 0xa5, 0x5f, 0xc8, 0x72, 0x55, 0x8c, 0xf8, 0xb0,
-	0x02, 0xcf, 0x64, 0x82, 0xec, 0x0b, 0x18, 0x44, 0x2a, 0xbb, 0xc5, 0xd4, 0x52, 0xda, 0x44, 0x01,
-	0x0b, 0x11, 0xe1, 0x4b, 0x18, 0x26, 0x98, 0x90, 0x38, 0x31, 0x3a, 0xc4, 0x18, 0x94, 0x18, 0x51,
-	0x9e, 0xc1, 0x28, 0xc1, 0xe4, 0x5a, 0xc9, 0x02, 0x2d, 0xa7, 0x6b, 0x03, 0x55, 0x60, 0x45, 0xca,
-	0xc5, 0x12, 0xb5, 0xaf, 0x03, 0x91, 0xa6, 0x18, 0xd2, 0x62, 0xed, 0xf2, 0x21, 0x81, 0x0b, 0x8b,
-	0xb1, 0x97, 0xb0, 0x5b, 0x92, 0x2e, 0x65, 0x9e, 0x63, 0xe8, 0xe7, 0x42, 0x61, 0x5a, 0xd0, 0x8a,
-	0xe8, 0x72, 0x66, 0xb9, 0xd6, 0x75, 0x4a, 0x9e, 0x3b, 0x59, 0x13, 0xa9, 0xc0, 0x94, 0xb6, 0x45,
-	0x25, 0xfb, 0x8b, 0xc5, 0x0c, 0x49, 0xaa, 0x44, 0xe4, 0xbe, 0x42, 0x9d, 0xc5, 0x57, 0x76, 0x63,
-	0x8c, 0xf8, 0x90, 0x40, 0x6e, 0x31, 0xf6, 0x19, 0x80, 0x55, 0x8a, 0xc5, 0xed, 0xda, 0x75, 0x48,
-	0xc6, 0x21, 0xe4, 0x9d, 0xb8, 0x5d, 0x57, 0x6e, 0x3f, 0x97, 0x79, 0xd9, 0x18, 0xa5, 0xfb, 0xd4,
-	0x00, 0x66, 0xdf, 0xd4, 0x6e, 0xff, 0xc3, 0x2a, 0xd2, 0xee, 0x80, 0x28, 0xc3, 0x8a, 0x72, 0xb8,
-	0x8a, 0xb4, 0xf7, 0x4f, 0x0b, 0x1e, 0x29, 0xd4, 0x45, 0xa6, 0x70, 0xa3, 0x54, 0x5f, 0xd9, 0xaf,
-	0xb5, 0x6f, 0x46, 0x5d, 0x28, 0xb4, 0x7f, 0xb4, 0x2e, 0xb7, 0x6f, 0x9b, 0x97, 0x20, 0xdb, 0x83,
-	0x87, 0x9b, 0xe9, 0x09, 0xb2, 0x6b, 0x2a, 0x59, 0x97, 0x3f, 0x68, 0xe6, 0x66, 0x9e, 0x5d, 0x9b,
-	0xba, 0x45, 0x99, 0xba, 0xac, 0x8b, 0x5f, 0xd6, 0xad, 0xc4, 0xaa, 0xd2, 0x56, 0x97, 0x69, 0x94,
-	0x6d, 0x50, 0x62, 0x44, 0xa9, 0x2f, 0x56, 0x82, 0xa6, 0x6c, 0xad, 0xfa, 0x62, 0xbc, 0x04, 0xbd,
-	0x1b, 0x18, 0x34, 0x9f, 0x73, 0x00, 0xdd, 0xd0, 0xb6, 0xaa, 0x19, 0x9f, 0xa7, 0x8d, 0xf1, 0xb9,
-	0xdf, 0xa4, 0x9c, 0x88, 0xec, 0xb5, 0x19, 0x48, 0xd2, 0xa2, 0x71, 0x18, 0xcc, 0x3e, 0x6f, 0x8e,
-	0xf2, 0xc7, 0x09, 0xe3, 0x15, 0x7d, 0xef, 0xbb, 0xc6, 0x46, 0xb4, 0x9b, 0x8e, 0x39, 0xd0, 0xe3,
-	0x8b, 0x5f, 0x4f, 0xe6, 0xe3, 0x4f, 0xcc, 0xf1, 0xf0, 0x8c, 0x1f, 0x2f, 0xc6, 0x2d, 0xb6, 0x0d,
-	0x9d, 0xdf, 0x8e, 0x17, 0xe3, 0xb6, 0x39, 0xf0, 0xc3, 0xa3, 0x71, 0x67, 0xef, 0x00, 0xfa, 0xd5,
-	0xda, 0x63, 0x3b, 0x00, 0xe6, 0xec, 0x37, 0x3e, 0x3c, 0x7d, 0xf3, 0xc3, 0xf9, 0xbb, 0x71, 0x8b,
-	0xf5, 0xa1, 0x7b, 0xf2, 0xf3, 0xc9, 0x8f, 0xe3, 0xf6, 0xbf, 0x01, 0x00, 0x00, 0xff, 0xff, 0xcf,
-	0xd6, 0xdc, 0x0b, 0xa4, 0x08, 0x00, 0x00,
+	// 1162 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x85, 0x56, 0xdb, 0x6e, 0xdb, 0x46,
+	0x10, 0xad, 0x24, 0xda, 0x96, 0x46, 0x17, 0x2b, 0x6b, 0x23, 0x15, 0x92, 0x5e, 0x52, 0xf6, 0xe6,
+	0xf8, 0xc1, 0x4e, 0x15, 0x14, 0x28, 0x50, 0xa0, 0x40, 0x2c, 0xc5, 0x8d, 0x51, 0x47, 0x31, 0x56,
+	0x36, 0x8a, 0xf6, 0x85, 0xa0, 0xc9, 0x95, 0x44, 0x98, 0x22, 0x09, 0x2e, 0xe5, 0xdb, 0x4b, 0xbf,
+	0xa2, 0x9f, 0xd0, 0xef, 0xe9, 0x53, 0xdf, 0xfb, 0x29, 0x9d, 0x9d, 0x5d, 0xd2, 0xa4, 0x12, 0xa0,
+	0x6f, 0x9c, 0x33, 0x67, 0x67, 0x66, 0x67, 0xce, 0x8e, 0x04, 0x4f, 0xc3, 0x5b, 0xff, 0x70, 0x19,
+	0xcc, 0x53, 0x37, 0x0b, 0xe2, 0xc8, 0x7c, 0x89, 0x83, 0x24, 0x8d, 0xb3, 0x98, 0xb5, 0x0a, 0x87,
+	0xfd, 0x07, 0xb4, 0x4e, 0xc6, 0x6f, 0xdd, 0xe4, 0xfc, 0x2e, 0x11, 0x6c, 0x17, 0x36, 0x02, 0xb9,
+	0x0a, 0xfc, 0x41, 0xed, 0x59, 0x7d, 0xaf, 0xc9, 0xb5, 0xa1, 0xd1, 0x39, 0xa2, 0xf5, 0x1c, 0x45,
+	0x83, 0x3d, 0x86, 0xcd, 0x45, 0x2c, 0x33, 0x84, 0x1b, 0x08, 0x6f, 0x70, 0x63, 0x31, 0x06, 0x56,
+	0x24, 0x11, 0xb5, 0x08, 0xa5, 0x6f, 0xf6, 0x04, 0x9a, 0x4b, 0x37, 0x49, 0xdd, 0x68, 0x2e, 0x06,
+	0x1b, 0x84, 0x17, 0xb6, 0xfd, 0x02, 0x36, 0x47, 0x71, 0x34, 0x0b, 0xe6, 0xac, 0x0f, 0x8d, 0x2b,
+	0x71, 0x47, 0xb9, 0x5b, 0x5c, 0x7d, 0xaa, 0xcc, 0xd7, 0x6e, 0xb8, 0x12, 0x94, 0xb9, 0xc5, 0xb5,
+	0x61, 0xff, 0x0c, 0x9b, 0x63, 0x71, 0x1d, 0x78, 0x82, 0x72, 0xb9, 0x4b, 0x61, 0x8e, 0xd0, 0x37,
+	0x7b, 0x0e, 0x9b, 0x1e, 0xc5, 0xc3, 0x43, 0x8d, 0xbd, 0xf6, 0xf0, 0xd1, 0x41, 0x71, 0xd9, 0x03,
+	0x9d, 0x88, 0x1b, 0x82, 0xfd, 0x77, 0x1d, 0x9a, 0xd3, 0xc8, 0x4d, 0xe4, 0x22, 0xce, 0x3e, 0x18,
+	0xeb, 0x25, 0xb4, 0xc3, 0xd8, 0x73, 0xc3, 0xd1, 0xff, 0x04, 0x2c, 0xb3, 0xd4, 0x65, 0xb1, 0xcb,
+	0xb3, 0x20, 0x14, 0x12, 0x5b, 0xd3, 0xc0, 0x60, 0x85, 0xcd, 0x3e, 0x81, 0x96, 0x48, 0x16, 0x62,
+	0x29, 0x52, 0x37, 0xa4, 0x0e, 0x35, 0xf9, 0x03, 0xc0, 0xbe, 0x87, 0x0e, 0x05, 0xd2, 0xb7, 0x93,
+	0xd8, 0xaa, 0xf5, 0x7c, 0xda, 0xc3, 0x2b, 0x34, 0x66, 0x43, 0xc7, 0x4d, 0xbd, 0x45, 0x90, 0x09,
+	0x2f, 0x5b, 0xa5, 0x62, 0xb0, 0x49, 0x1d, 0xae, 0x60, 0xaa, 0x28, 0x99, 0xa1, 0x00, 0x66, 0xab,
+	0x70, 0xb0, 0x45, 0x79, 0x0b, 0x9b, 0x7d, 0x09, 0x5d, 0x2f, 0x15, 0x94, 0xc0, 0xf1, 0x11, 0x1b,
+	0x34, 0x9f, 0xd5, 0xf6, 0x1a, 0xbc, 0x93, 0x83, 0x63, 0xc4, 0xd8, 0x57, 0xd0, 0x0b, 0x5d, 0x99,
+	0x39, 0x2b, 0x29, 0x7c, 0xcd, 0x6a, 0x69, 0x96, 0x42, 0x2f, 0x10, 0x54, 0x2c, 0xfb, 0xcf, 0x1a,
+	0x74, 0x53, 0x79, 0x17, 0x79, 0xc7, 0x78, 0x14, 0xf3, 0x4a, 0x25, 0x93, 0x5b, 0x37, 0xcb, 0x52,
+	0x89, 0x8d, 0xad, 0x61, 0x5a, 0x63, 0x29, 0xdc, 0x17, 0xa1, 0xc8, 0xd4, 0x6c, 0x09, 0xd7, 0x96,
+	0x2a, 0xd4, 0x8b, 0x97, 0x09, 0x1e, 0x55, 0xdd, 0x53, 0x9e, 0xc2, 0xc6, 0x1a, 0xba, 0x97, 0x81,
+	0x1f, 0xa4, 0x78, 0x27, 0x2c, 0x8b, 0x3a, 0xa8, 0x08, 0x55, 0x50, 0x0d, 0xf2, 0x5e, 0x66, 0x3e,
+	0x76, 0x4f, 0x39, 0xe9, 0xdb, 0x7e, 0x0e, 0xed, 0xfb, 0x99, 0x2c, 0x8a, 0x2a, 0x27, 0xa9, 0x55,
+	0x93, 0xd8, 0xe7, 0x80, 0x17, 0x0f, 0x56, 0x05, 0xf7, 0x1b, 0xe8, 0x65, 0x5e, 0xf2, 0x1a, 0xbb,
+	0x75, 0x19, 0x06, 0x72, 0x21, 0x7c, 0x73, 0x62, 0x0d, 0x55, 0xa3, 0x0d, 0xdd, 0xfb, 0xbb, 0x33,
+	0x77, 0x8e, 0x93, 0xd3, 0x77, 0x7a, 0x00, 0xec, 0x7f, 0x2d, 0xd8, 0x7e, 0x9b, 0x8f, 0xf1, 0x8d,
+	0x70, 0x7d, 0x91, 0xb2, 0x7d, 0xa8, 0xcf, 0x24, 0xe9, 0xad, 0x37, 0x7c, 0x52, 0x1a, 0x72, 0xc1,
+	0x3b, 0x9e, 0xaa, 0x57, 0xc9, 0x91, 0xc5, 0xbe, 0x05, 0x4b, 0x55, 0x45, 0x81, 0x7b, 0xc3, 0x9d,
+	0xb2, 0x04, 0xf9, 0xc9, 0x05, 0xd1, 0x88, 0x80, 0x41, 0x37, 0x02, 0x1f, 0x1f, 0x17, 0x49, 0xaf,
+	0x3d, 0xdc, 0x2d, 0x31, 0x8b, 0x77, 0xce, 0x35, 0x45, 0xf5, 0x53, 0x1a, 0xf9, 0x4f, 0x50, 0xee,
+	0x12, 0xfb, 0xa9, 0xe4, 0x5a, 0x05, 0xd9, 0x77, 0xd0, 0xca, 0x81, 0x5c, 0x92, 0xe5, 0xfc, 0xf9,
+	0x03, 0xe2, 0x0f, 0x2c, 0x36, 0x80, 0x2d, 0x6c, 0xa6, 0xbf, 0x5a, 0x26, 0x28, 0x36, 0xd5, 0x89,
+	0xdc, 0x64, 0x3f, 0xad, 0xe9, 0x83, 0xb4, 0xd6, 0x1e, 0x0e, 0x4a, 0x01, 0x2b, 0x7e, 0xbe, 0x26,
+	0x27, 0x8c, 0x9c, 0x8a, 0x19, 0x7e, 0x2d, 0x48, 0x7f, 0x18, 0xd9, 0x98, 0xec, 0x87, 0xca, 0x88,
+	0x07, 0x40, 0x71, 0x1f, 0x97, 0xe2, 0x96, 0xbc, 0xbc, 0xa2, 0x86, 0x1f, 0xab, 0x13, 0x1f, 0xb4,
+	0xe9, 0xe8, 0xc7, 0xa5, 0xa3, 0x65, 0x37, 0xaf, 0xca, 0x63, 0x08, 0xbb, 0xe6, 0x6e, 0x6f, 0xdd,
+	0xdb, 0x93, 0x4c, 0xe8, 0x13, 0x72, 0xd0, 0xc1, 0x20, 0x5d, 0xfe, 0x41, 0x1f, 0xce, 0xa8, 0x6f,
+	0xf0, 0x23, 0x37, 0xf2, 0x6f, 0x02, 0x3f, 0x5b, 0x0c, 0xba, 0xc8, 0xb7, 0xf8, 0x7b, 0x38, 0xdb,
+	0x83, 0x6d, 0x83, 0x8d, 0xe3, 0x9b, 0x28, 0x0b, 0x70, 0x43, 0xf5, 0x28, 0xf4, 0x3a, 0x6c, 0x1f,
+	0x43, 0xbf, 0x50, 0x0e, 0xae, 0xa2, 0x2c, 0x8d, 0x43, 0xd5, 0x2e, 0xb9, 0xf2, 0x3c, 0xad, 0x73,
+	0xf5, 0xea, 0x73, 0x53, 0x79, 0x70, 0xb8, 0x12, 0xc5, 0x49, 0x9a, 0x6a, 0xf1, 0xdc, 0xb4, 0x5f,
+	0x42, 0xb7, 0x88, 0x33, 0xc5, 0xde, 0xab, 0xfd, 0x32, 0x0b, 0xf0, 0x65, 0x9d, 0xa5, 0x62, 0xac,
+	0x46, 0xaa, 0x23, 0x55, 0x30, 0xfb, 0xaf, 0x06, 0xf4, 0x55, 0x35, 0x8e, 0xda, 0x2a, 0xd2, 0x11,
+	0x98, 0xfe, 0x4e, 0x2d, 0x16, 0x9c, 0x8d, 0xb8, 0x0f, 0xa2, 0xb9, 0x43, 0x95, 0xab, 0x93, 0x5d,
+	0x3c, 0x69, 0xc0, 0x73, 0xc4, 0xd8, 0xe7, 0xd0, 0x9e, 0xa5, 0xf1, 0xbd, 0x88, 0x34, 0xa5, 0x4e,
+	0x14, 0xd0, 0x10, 0x11, 0xbe, 0x80, 0xce, 0x52, 0x2c, 0x29, 0x38, 0x31, 0x1a, 0xc4, 0x68, 0x1b,
+	0x8c, 0x28, 0x98, 0x08, 0xcd, 0x9b, 0x14, 0xd7, 0x9d, 0xe6, 0x58, 0x3a, 0x51, 0x0e, 0xe6, 0xa4,
+	0x44, 0xbd, 0x45, 0x47, 0x7a, 0x6e, 0x14, 0x09, 0x9f, 0x7e, 0x89, 0x2c, 0xde, 0x21, 0x70, 0xaa,
+	0x31, 0xf6, 0x02, 0xc7, 0xa9, 0x49, 0x57, 0x41, 0x92, 0xe0, 0xaa, 0x4b, 0xdc, 0x14, 0x2f, 0x43,
+	0x3b, 0xd5, 0xe2, 0x4c, 0x73, 0xb5, 0xeb, 0x8c, 0x3c, 0x0f, 0x61, 0x55, 0xa6, 0x4c, 0x44, 0xb4,
+	0x5e, 0xf3, 0xb0, 0xbf, 0x6a, 0x4c, 0x91, 0x82, 0x14, 0x9f, 0x9c, 0x83, 0x92, 0x89, 0xc3, 0x6b,
+	0xbd, 0x62, 0xb1, 0x40, 0x02, 0xb9, 0xc6, 0xd8, 0xa7, 0x00, 0x3a, 0x92, 0x5a, 0x1b, 0x28, 0x6f,
+	0x15, 0xa6, 0x45, 0xc8, 0x29, 0x02, 0xb9, 0xdb, 0x49, 0x82, 0xc4, 0xe8, 0xdb, 0xb8, 0xcf, 0x14,
+	0xa0, 0x16, 0x74, 0xe1, 0x76, 0x2e, 0x57, 0x33, 0xad, 0x63, 0x53, 0x88, 0xa2, 0x1c, 0x21, 0x66,
+	0xff, 0x53, 0x83, 0x1d, 0xac, 0x21, 0x8b, 0x53, 0x51, 0x19, 0xd5, 0xd7, 0xfa, 0xb4, 0x74, 0xd4,
+	0x1e, 0xc4, 0x8b, 0xe9, 0xbf, 0x00, 0x16, 0xd7, 0x77, 0x1b, 0x19, 0x10, 0x95, 0xfb, 0xa8, 0xda,
+	0x1e, 0x2f, 0xbe, 0xa1, 0x91, 0x59, 0xa8, 0xc7, 0x52, 0x6f, 0x46, 0xf1, 0x8d, 0x9a, 0xdb, 0x2c,
+	0x4e, 0xaf, 0x8a, 0xe1, 0x9b, 0xb9, 0x19, 0x2c, 0x1f, 0x6d, 0x5e, 0x4c, 0x69, 0x6c, 0x6d, 0x83,
+	0x11, 0xa5, 0x28, 0xcc, 0x80, 0x7a, 0xaf, 0xe7, 0x85, 0x71, 0x03, 0xda, 0xb7, 0xd0, 0x2e, 0x5f,
+	0xe7, 0x10, 0x2c, 0x5f, 0x4b, 0x55, 0x3d, 0xe5, 0xa7, 0xa5, 0xa7, 0xbc, 0x2e, 0x52, 0x4e, 0x44,
+	0xdc, 0x1e, 0x5b, 0x26, 0x01, 0x3d, 0x87, 0xf6, 0xf0, 0xb3, 0xf2, 0x46, 0x7a, 0xbf, 0x61, 0x3c,
+	0xa7, 0xef, 0x4f, 0x4a, 0x8b, 0x5d, 0x2f, 0x6c, 0xd6, 0x82, 0x0d, 0x3e, 0xfd, 0x6d, 0x32, 0xea,
+	0x7f, 0xa4, 0x3e, 0x8f, 0xce, 0xf9, 0xf1, 0xb4, 0x5f, 0x63, 0x5b, 0xd0, 0xf8, 0x1d, 0x3f, 0xea,
+	0xea, 0x83, 0x1f, 0x8d, 0xfb, 0x0d, 0xb6, 0x03, 0xdb, 0x47, 0xa7, 0xef, 0x46, 0xbf, 0x38, 0xaf,
+	0x26, 0x63, 0x47, 0x9f, 0xb0, 0xf6, 0x0f, 0xa1, 0x99, 0xaf, 0x74, 0xd6, 0x03, 0x50, 0xdf, 0x4e,
+	0x29, 0xda, 0xd9, 0x9b, 0x57, 0x17, 0xa7, 0x18, 0xad, 0x09, 0xd6, 0xe4, 0xdd, 0xe4, 0x75, 0xbf,
+	0xfe, 0x1f, 0xf9, 0xfc, 0xc2, 0x1b, 0xea, 0x09, 0x00, 0x00,
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/units"
 )
@@ -65,9 +66,9 @@ func TypesToHeader(types ...Type) MigrationHeader {
 	}
 
 	// Check all the types for an Rsync method, if found then add its features to the header's
-	// RsyncFeatures list.
+	// RsyncFeatures list. Block volumes also use rsync for their metadata files.
 	for _, t := range types {
-		if t.FSType != MigrationFSType_RSYNC {
+		if t.FSType != MigrationFSType_RSYNC && t.FSType != MigrationFSType_BLOCK_AND_RSYNC {
 			continue
 		}
 
@@ -76,6 +77,7 @@ func TypesToHeader(types ...Type) MigrationHeader {
 			Delete:        &missingFeature,
 			Compress:      &missingFeature,
 			Bidirectional: &missingFeature,
+			Zstd:          &missingFeature,
 		}
 
 		for _, feature := range t.Features {
@@ -87,6 +89,8 @@ func TypesToHeader(types ...Type) MigrationHeader {
 				features.Compress = &hasFeature
 			} else if feature == "bidirectional" {
 				features.Bidirectional = &hasFeature
+			} else if feature == "zstd" {
+				features.Zstd = &hasFeature
 			}
 		}
 
@@ -119,7 +123,7 @@ func MatchTypes(offer MigrationHeader, fallbackType MigrationFSType, ourTypes []
 			var offeredFeatures []string
 			if offerFSType == MigrationFSType_ZFS {
 				offeredFeatures = offer.GetZfsFeaturesSlice()
-			} else if offerFSType == MigrationFSType_RSYNC {
+			} else if offerFSType == MigrationFSType_RSYNC || offerFSType == MigrationFSType_BLOCK_AND_RSYNC {
 				offeredFeatures = offer.GetRsyncFeaturesSlice()
 			}
 
@@ -164,8 +168,30 @@ func progressWrapperRender(op *operations.Operation, key string, description str
 		progress = fmt.Sprintf("%s: %s (%s/s)", description, units.GetByteSizeString(progressInt, 2), units.GetByteSizeString(speedInt, 2))
 	}
 
+	// Keep per-volume counters alongside the human readable string so clients
+	// can render their own progress information.
+	name := description
+	if name == "" {
+		name = key
+	}
+
+	transfers := map[string]api.OperationTransferProgress{}
+	current, ok := meta["transfers"].(map[string]api.OperationTransferProgress)
+	if ok {
+		for k, v := range current {
+			transfers[k] = v
+		}
+	}
+
+	transfers[name] = api.OperationTransferProgress{
+		Stage:            key,
+		TransferredBytes: progressInt,
+		Rate:             speedInt,
+	}
+
 	if meta[key] != progress {
 		meta[key] = progress
+		meta["transfers"] = transfers
 		op.UpdateMetadata(meta)
 	}
 }
@@ -22,6 +22,10 @@ func (m *MigrationHeader) GetRsyncFeaturesSlice() []string {
 		if m.RsyncFeatures.Bidirectional != nil && *m.RsyncFeatures.Bidirectional == true {
 			features = append(features, "bidirectional")
 		}
+
+		if m.RsyncFeatures.Zstd != nil && *m.RsyncFeatures.Zstd == true {
+			features = append(features, "zstd")
+		}
 	}
 
 	return features
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -19,16 +20,20 @@ import (
 
 	lxd "github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/daemon"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/device"
+	"github.com/lxc/lxd/lxd/dhcpdns"
 	"github.com/lxc/lxd/lxd/dnsmasq"
 	firewallConsts "github.com/lxc/lxd/lxd/firewall/consts"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/lxd/warnings"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
@@ -76,7 +81,12 @@ var networkStateCmd = APIEndpoint{
 func networksGet(d *Daemon, r *http.Request) response.Response {
 	recursion := util.IsRecursionRequest(r)
 
-	ifs, err := networkGetInterfaces(d.cluster)
+	projectName, err := networkProjectParam(d, r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	ifs, err := networkGetInterfaces(d.cluster, projectName)
 	if err != nil {
 		return response.InternalError(err)
 	}
@@ -87,7 +97,7 @@ func networksGet(d *Daemon, r *http.Request) response.Response {
 		if !recursion {
 			resultString = append(resultString, fmt.Sprintf("/%s/networks/%s", version.APIVersion, iface))
 		} else {
-			net, err := doNetworkGet(d, iface)
+			net, err := doNetworkGet(d, projectName, iface)
 			if err != nil {
 				continue
 			}
@@ -114,12 +124,20 @@ func networksPost(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	configTranslateKeys(config.EntityNetwork, req.Config)
+
 	// Sanity checks
 	if req.Name == "" {
 		return response.BadRequest(fmt.Errorf("No name provided"))
 	}
 
-	err = networkValidName(req.Name)
+	projectName, err := networkProjectParam(d, r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// The bridge of the network on the host is prefixed with the project name.
+	err = networkValidName(project.Prefix(projectName, req.Name))
 	if err != nil {
 		return response.BadRequest(err)
 	}
@@ -132,25 +150,40 @@ func networksPost(d *Daemon, r *http.Request) response.Response {
 		req.Config = map[string]string{}
 	}
 
-	err = networkValidateConfig(req.Name, req.Config)
+	err = networkValidateConfig(project.Prefix(projectName, req.Name), req.Config)
 	if err != nil {
 		return response.BadRequest(err)
 	}
 
 	url := fmt.Sprintf("/%s/networks/%s", version.APIVersion, req.Name)
+	if projectName != "default" {
+		url += fmt.Sprintf("?project=%s", projectName)
+	}
 	resp := response.SyncResponseLocation(true, nil, url)
 
 	if isClusterNotification(r) {
 		// This is an internal request which triggers the actual
 		// creation of the network across all nodes, after they have
 		// been previously defined.
-		err = doNetworksCreate(d, req, true)
+		err = doNetworksCreate(d, "default", req, true)
 		if err != nil {
 			return response.SmartError(err)
 		}
 		return resp
 	}
 
+	// Check if we're clustered
+	count, err := cluster.Count(d.State())
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// The networks of projects other than the default one are only
+	// defined on the node they are created on.
+	if count > 1 && projectName != "default" {
+		return response.BadRequest(fmt.Errorf("Networks can only be created in the default project in LXD clusters"))
+	}
+
 	targetNode := queryParam(r, "target")
 	if targetNode != "" {
 		// A targetNode was specified, let's just define the node's
@@ -173,12 +206,6 @@ func networksPost(d *Daemon, r *http.Request) response.Response {
 		return resp
 	}
 
-	// Check if we're clustered
-	count, err := cluster.Count(d.State())
-	if err != nil {
-		return response.SmartError(err)
-	}
-
 	if count > 1 {
 		err = networksPostCluster(d, req)
 		if err != nil {
@@ -196,22 +223,22 @@ func networksPost(d *Daemon, r *http.Request) response.Response {
 	// No targetNode was specified and we're either a single-node
 	// cluster or not clustered at all, so create the storage
 	// pool immediately.
-	networks, err := networkGetInterfaces(d.cluster)
+	networks, err := networkGetInterfaces(d.cluster, projectName)
 	if err != nil {
 		return response.InternalError(err)
 	}
 
-	if shared.StringInSlice(req.Name, networks) {
+	if shared.StringInSlice(req.Name, networks) || shared.PathExists(fmt.Sprintf("/sys/class/net/%s", project.Prefix(projectName, req.Name))) {
 		return response.BadRequest(fmt.Errorf("The network already exists"))
 	}
 
 	// Create the database entry
-	_, err = d.cluster.NetworkCreate(req.Name, req.Description, req.Config)
+	_, err = d.cluster.NetworkCreate(projectName, req.Name, req.Description, req.Config)
 	if err != nil {
 		return response.SmartError(fmt.Errorf("Error inserting %s into database: %s", req.Name, err))
 	}
 
-	err = doNetworksCreate(d, req, true)
+	err = doNetworksCreate(d, projectName, req, true)
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -228,7 +255,7 @@ func networksPostCluster(d *Daemon, req api.NetworksPost) error {
 	}
 
 	// Merge the current config.
-	networkID, dbNetwork, err := d.cluster.NetworkGet(req.Name)
+	networkID, dbNetwork, err := d.cluster.NetworkGet("default", req.Name)
 	if err != nil {
 		return err
 	}
@@ -278,7 +305,7 @@ func networksPostCluster(d *Daemon, req api.NetworksPost) error {
 	for key, value := range configs[nodeName] {
 		nodeReq.Config[key] = value
 	}
-	err = doNetworksCreate(d, nodeReq, false)
+	err = doNetworksCreate(d, "default", nodeReq, false)
 	if err != nil {
 		return err
 	}
@@ -351,11 +378,11 @@ func networkFillConfig(req *api.NetworksPost) error {
 	return nil
 }
 
-// Create the network on the system. The withDatabase flag is used to decide
-// whether to cleanup the database if an error occurs.
-func doNetworksCreate(d *Daemon, req api.NetworksPost, withDatabase bool) error {
+// Create the network of the given project on the system. The withDatabase flag
+// is used to decide whether to cleanup the database if an error occurs.
+func doNetworksCreate(d *Daemon, projectName string, req api.NetworksPost, withDatabase bool) error {
 	// Start the network
-	n, err := networkLoadByName(d.State(), req.Name)
+	n, err := networkLoadByName(d.State(), projectName, req.Name)
 	if err != nil {
 		return err
 	}
@@ -378,7 +405,12 @@ func networkGet(d *Daemon, r *http.Request) response.Response {
 
 	name := mux.Vars(r)["name"]
 
-	n, err := doNetworkGet(d, name)
+	projectName, err := networkProjectParam(d, r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	n, err := doNetworkGet(d, projectName, name)
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -402,15 +434,22 @@ func networkGet(d *Daemon, r *http.Request) response.Response {
 	return response.SyncResponseETag(true, &n, etag)
 }
 
-func doNetworkGet(d *Daemon, name string) (api.Network, error) {
+func doNetworkGet(d *Daemon, projectName string, name string) (api.Network, error) {
 	// Ignore veth pairs (for performance reasons)
 	if strings.HasPrefix(name, "veth") {
 		return api.Network{}, os.ErrNotExist
 	}
 
 	// Get some information
-	osInfo, _ := net.InterfaceByName(name)
-	_, dbInfo, _ := d.cluster.NetworkGet(name)
+	_, dbInfo, _ := d.cluster.NetworkGet(projectName, name)
+
+	// Managed networks of projects have their own bridge on the host.
+	hostName := name
+	if dbInfo != nil {
+		hostName = project.Prefix(projectName, name)
+	}
+
+	osInfo, _ := net.InterfaceByName(hostName)
 
 	// Sanity check
 	if osInfo == nil && dbInfo == nil {
@@ -426,7 +465,7 @@ func doNetworkGet(d *Daemon, name string) (api.Network, error) {
 	// Set the device type as needed
 	if osInfo != nil && shared.IsLoopback(osInfo) {
 		n.Type = "loopback"
-	} else if dbInfo != nil || shared.PathExists(fmt.Sprintf("/sys/class/net/%s/bridge", n.Name)) {
+	} else if dbInfo != nil || shared.PathExists(fmt.Sprintf("/sys/class/net/%s/bridge", hostName)) {
 		if dbInfo != nil {
 			n.Managed = true
 			n.Description = dbInfo.Description
@@ -434,14 +473,14 @@ func doNetworkGet(d *Daemon, name string) (api.Network, error) {
 		}
 
 		n.Type = "bridge"
-	} else if shared.PathExists(fmt.Sprintf("/proc/net/vlan/%s", n.Name)) {
+	} else if shared.PathExists(fmt.Sprintf("/proc/net/vlan/%s", hostName)) {
 		n.Type = "vlan"
-	} else if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/device", n.Name)) {
+	} else if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/device", hostName)) {
 		n.Type = "physical"
-	} else if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/bonding", n.Name)) {
+	} else if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/bonding", hostName)) {
 		n.Type = "bond"
 	} else {
-		_, err := shared.RunCommand("ovs-vsctl", "br-exists", n.Name)
+		_, err := shared.RunCommand("ovs-vsctl", "br-exists", hostName)
 		if err == nil {
 			n.Type = "bridge"
 		} else {
@@ -457,7 +496,7 @@ func doNetworkGet(d *Daemon, name string) (api.Network, error) {
 		}
 
 		for _, inst := range insts {
-			if networkIsInUse(inst, n.Name) {
+			if networkIsInUse(d.State(), inst, hostName) {
 				uri := fmt.Sprintf("/%s/instances/%s", version.APIVersion, inst.Name())
 				if inst.Project() != "default" {
 					uri += fmt.Sprintf("?project=%s", inst.Project())
@@ -479,14 +518,19 @@ func networkDelete(d *Daemon, r *http.Request) response.Response {
 	name := mux.Vars(r)["name"]
 	state := d.State()
 
+	projectName, err := networkProjectParam(d, r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
 	// Check if the network is pending, if so we just need to delete it from
 	// the database.
-	_, network, err := d.cluster.NetworkGet(name)
+	_, network, err := d.cluster.NetworkGet(projectName, name)
 	if err != nil {
 		return response.SmartError(err)
 	}
 	if network.Status == "Pending" {
-		err := d.cluster.NetworkDelete(name)
+		err := d.cluster.NetworkDelete(projectName, name)
 		if err != nil {
 			return response.SmartError(err)
 		}
@@ -494,7 +538,7 @@ func networkDelete(d *Daemon, r *http.Request) response.Response {
 	}
 
 	// Get the existing network
-	n, err := networkLoadByName(state, name)
+	n, err := networkLoadByName(state, projectName, name)
 	if err != nil {
 		return response.NotFound(err)
 	}
@@ -561,8 +605,13 @@ func networkPost(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	projectName, err := networkProjectParam(d, r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
 	// Get the existing network
-	n, err := networkLoadByName(state, name)
+	n, err := networkLoadByName(state, projectName, name)
 	if err != nil {
 		return response.NotFound(err)
 	}
@@ -572,18 +621,18 @@ func networkPost(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(fmt.Errorf("No name provided"))
 	}
 
-	err = networkValidName(req.Name)
+	err = networkValidName(project.Prefix(projectName, req.Name))
 	if err != nil {
 		return response.BadRequest(err)
 	}
 
 	// Check that the name isn't already in use
-	networks, err := networkGetInterfaces(d.cluster)
+	networks, err := networkGetInterfaces(d.cluster, projectName)
 	if err != nil {
 		return response.InternalError(err)
 	}
 
-	if shared.StringInSlice(req.Name, networks) {
+	if shared.StringInSlice(req.Name, networks) || shared.PathExists(fmt.Sprintf("/sys/class/net/%s", project.Prefix(projectName, req.Name))) {
 		return response.Conflict(fmt.Errorf("Network '%s' already exists", req.Name))
 	}
 
@@ -593,14 +642,24 @@ func networkPost(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
-	return response.SyncResponseLocation(true, nil, fmt.Sprintf("/%s/networks/%s", version.APIVersion, req.Name))
+	url := fmt.Sprintf("/%s/networks/%s", version.APIVersion, req.Name)
+	if projectName != "default" {
+		url += fmt.Sprintf("?project=%s", projectName)
+	}
+
+	return response.SyncResponseLocation(true, nil, url)
 }
 
 func networkPut(d *Daemon, r *http.Request) response.Response {
 	name := mux.Vars(r)["name"]
 
+	projectName, err := networkProjectParam(d, r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
 	// Get the existing network
-	_, dbInfo, err := d.cluster.NetworkGet(name)
+	_, dbInfo, err := d.cluster.NetworkGet(projectName, name)
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -632,14 +691,21 @@ func networkPut(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
-	return doNetworkUpdate(d, name, dbInfo.Config, req, isClusterNotification(r))
+	configTranslateKeys(config.EntityNetwork, req.Config)
+
+	return doNetworkUpdate(d, projectName, name, dbInfo.Config, req, isClusterNotification(r))
 }
 
 func networkPatch(d *Daemon, r *http.Request) response.Response {
 	name := mux.Vars(r)["name"]
 
+	projectName, err := networkProjectParam(d, r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
 	// Get the existing network
-	_, dbInfo, err := d.cluster.NetworkGet(name)
+	_, dbInfo, err := d.cluster.NetworkGet(projectName, name)
 	if err != nil {
 		return response.SmartError(err)
 	}
@@ -671,6 +737,8 @@ func networkPatch(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	configTranslateKeys(config.EntityNetwork, req.Config)
+
 	// Config stacking
 	if req.Config == nil {
 		req.Config = map[string]string{}
@@ -683,12 +751,12 @@ func networkPatch(d *Daemon, r *http.Request) response.Response {
 		}
 	}
 
-	return doNetworkUpdate(d, name, dbInfo.Config, req, isClusterNotification(r))
+	return doNetworkUpdate(d, projectName, name, dbInfo.Config, req, isClusterNotification(r))
 }
 
-func doNetworkUpdate(d *Daemon, name string, oldConfig map[string]string, req api.NetworkPut, notify bool) response.Response {
+func doNetworkUpdate(d *Daemon, projectName string, name string, oldConfig map[string]string, req api.NetworkPut, notify bool) response.Response {
 	// Validate the configuration
-	err := networkValidateConfig(name, req.Config)
+	err := networkValidateConfig(project.Prefix(projectName, name), req.Config)
 	if err != nil {
 		return response.BadRequest(err)
 	}
@@ -701,7 +769,7 @@ func doNetworkUpdate(d *Daemon, name string, oldConfig map[string]string, req ap
 	}
 
 	// Load the network
-	n, err := networkLoadByName(d.State(), name)
+	n, err := networkLoadByName(d.State(), projectName, name)
 	if err != nil {
 		return response.NotFound(err)
 	}
@@ -716,14 +784,21 @@ func doNetworkUpdate(d *Daemon, name string, oldConfig map[string]string, req ap
 
 func networkLeasesGet(d *Daemon, r *http.Request) response.Response {
 	name := mux.Vars(r)["name"]
-	project := projectParam(r)
+	instProject := projectParam(r)
+
+	projectName, err := networkProjectParam(d, r)
+	if err != nil {
+		return response.SmartError(err)
+	}
 
 	// Try to get the network
-	n, err := doNetworkGet(d, name)
+	n, err := doNetworkGet(d, projectName, name)
 	if err != nil {
 		return response.SmartError(err)
 	}
 
+	hostName := project.Prefix(projectName, name)
+
 	// Validate that we do have leases for it
 	if !n.Managed || n.Type != "bridge" {
 		return response.NotFound(errors.New("Leases not found"))
@@ -735,45 +810,55 @@ func networkLeasesGet(d *Daemon, r *http.Request) response.Response {
 	// Get all static leases
 	if !isClusterNotification(r) {
 		// Get all the instances
-		instances, err := instanceLoadByProject(d.State(), project)
+		instances, err := instanceLoadByProject(d.State(), instProject)
 		if err != nil {
 			return response.SmartError(err)
 		}
 
 		for _, inst := range instances {
 			// Go through all its devices (including profiles
-			for k, d := range inst.ExpandedDevices() {
+			for k, dev := range inst.ExpandedDevices() {
 				// Skip uninteresting entries
-				if d["type"] != "nic" || d["nictype"] != "bridged" || d["parent"] != name {
+				if dev["type"] != "nic" || dev["nictype"] != "bridged" || dev["parent"] != name {
+					continue
+				}
+
+				// Skip devices using a network of the same name in another project.
+				parentProject, err := device.NetworkProject(d.State(), inst.Project(), dev["parent"])
+				if err != nil {
+					return response.SmartError(err)
+				}
+
+				if parentProject != projectName {
 					continue
 				}
 
 				// Fill in the hwaddr from volatile
-				if d["hwaddr"] == "" {
-					d["hwaddr"] = inst.LocalConfig()[fmt.Sprintf("volatile.%s.hwaddr", k)]
+				if dev["hwaddr"] == "" {
+					dev["hwaddr"] = inst.LocalConfig()[fmt.Sprintf("volatile.%s.hwaddr", k)]
 				}
 
 				// Record the MAC
-				if d["hwaddr"] != "" {
-					projectMacs = append(projectMacs, d["hwaddr"])
+				if dev["hwaddr"] != "" {
+					projectMacs = append(projectMacs, dev["hwaddr"])
 				}
 
 				// Add the lease
-				if d["ipv4.address"] != "" {
+				if dev["ipv4.address"] != "" {
 					leases = append(leases, api.NetworkLease{
 						Hostname: inst.Name(),
-						Address:  d["ipv4.address"],
-						Hwaddr:   d["hwaddr"],
+						Address:  dev["ipv4.address"],
+						Hwaddr:   dev["hwaddr"],
 						Type:     "static",
 						Location: inst.Location(),
 					})
 				}
 
-				if d["ipv6.address"] != "" {
+				if dev["ipv6.address"] != "" {
 					leases = append(leases, api.NetworkLease{
 						Hostname: inst.Name(),
-						Address:  d["ipv6.address"],
-						Hwaddr:   d["hwaddr"],
+						Address:  dev["ipv6.address"],
+						Hwaddr:   dev["hwaddr"],
 						Type:     "static",
 						Location: inst.Location(),
 					})
@@ -793,7 +878,7 @@ func networkLeasesGet(d *Daemon, r *http.Request) response.Response {
 	}
 
 	// Get dynamic leases
-	leaseFile := shared.VarPath("networks", name, "dnsmasq.leases")
+	leaseFile := shared.VarPath("networks", hostName, "dnsmasq.leases")
 	if !shared.PathExists(leaseFile) {
 		return response.SyncResponse(true, leases)
 	}
@@ -874,15 +959,20 @@ func networkLeasesGet(d *Daemon, r *http.Request) response.Response {
 	return response.SyncResponse(true, leases)
 }
 
-func networkGetLeaseAddresses(s *state.State, network string, hwaddr string) ([]api.InstanceStateNetworkAddress, error) {
+func networkGetLeaseAddresses(s *state.State, instProject string, network string, hwaddr string) ([]api.InstanceStateNetworkAddress, error) {
 	addresses := []api.InstanceStateNetworkAddress{}
 
-	leaseFile := shared.VarPath("networks", network, "dnsmasq.leases")
+	networkProject, err := device.NetworkProject(s, instProject, network)
+	if err != nil {
+		return nil, err
+	}
+
+	leaseFile := shared.VarPath("networks", project.Prefix(networkProject, network), "dnsmasq.leases")
 	if !shared.PathExists(leaseFile) {
 		return addresses, nil
 	}
 
-	dbInfo, err := networkLoadByName(s, network)
+	dbInfo, err := networkLoadByName(s, networkProject, network)
 	if err != nil {
 		return nil, err
 	}
@@ -945,36 +1035,102 @@ func networkGetLeaseAddresses(s *state.State, network string, hwaddr string) ([]
 	return addresses, nil
 }
 
+// networkProjectParam returns the project whose networks are the target of
+// the request: the requested project if it has the networks feature enabled,
+// the default project otherwise.
+func networkProjectParam(d *Daemon, r *http.Request) (string, error) {
+	projectName := projectParam(r)
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		hasNetworks, err := tx.ProjectHasNetworks(projectName)
+		if err != nil {
+			return errors.Wrap(err, "Check project features")
+		}
+
+		if !hasNetworks {
+			projectName = "default"
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return projectName, nil
+}
+
 // The network structs and functions
-func networkLoadByName(s *state.State, name string) (*network, error) {
-	id, dbInfo, err := s.Cluster.NetworkGet(name)
+func networkLoadByName(s *state.State, projectName string, name string) (*network, error) {
+	id, dbInfo, err := s.Cluster.NetworkGet(projectName, name)
 	if err != nil {
 		return nil, err
 	}
 
-	n := network{state: s, id: id, name: name, description: dbInfo.Description, config: dbInfo.Config}
+	n := network{state: s, id: id, project: projectName, dbName: name, name: project.Prefix(projectName, name), description: dbInfo.Description, config: dbInfo.Config}
 
 	return &n, nil
 }
 
+// networkLoadAll loads the managed networks of all projects, skipping the
+// pending ones if requested.
+func networkLoadAll(s *state.State, notPending bool) ([]*network, error) {
+	var projects []string
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		projects, err = tx.ProjectNames()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	networks := []*network{}
+	for _, projectName := range projects {
+		var names []string
+		if notPending {
+			names, err = s.Cluster.NetworksNotPending(projectName)
+		} else {
+			names, err = s.Cluster.Networks(projectName)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range names {
+			n, err := networkLoadByName(s, projectName, name)
+			if err != nil {
+				return nil, err
+			}
+
+			networks = append(networks, n)
+		}
+	}
+
+	return networks, nil
+}
+
 func networkStartup(s *state.State) error {
 	// Get a list of managed networks
-	networks, err := s.Cluster.NetworksNotPending()
+	networks, err := networkLoadAll(s, true)
 	if err != nil {
 		return err
 	}
 
 	// Bring them all up
-	for _, name := range networks {
-		n, err := networkLoadByName(s, name)
+	for _, n := range networks {
+		err = n.Start()
 		if err != nil {
-			return err
+			// Don't cause LXD to fail to start entirely on network bring up failure
+			logger.Error("Failed to bring up network", log.Ctx{"err": err, "name": n.name})
+
+			err = warnings.UpsertLocalNode(s.Cluster, "", db.WarningEntityTypeNetwork, n.id, db.WarningNetworkUnavailable, fmt.Sprintf("Failed to bring up network %q: %v", n.dbName, err))
+		} else {
+			err = warnings.ResolveLocalNodeByTypeAndEntity(s.Cluster, db.WarningNetworkUnavailable, db.WarningEntityTypeNetwork, n.id)
 		}
 
-		err = n.Start()
 		if err != nil {
-			// Don't cause LXD to fail to start entirely on network bring up failure
-			logger.Error("Failed to bring up network", log.Ctx{"err": err, "name": name})
+			logger.Error("Failed to record network warning", log.Ctx{"err": err, "name": n.name})
 		}
 	}
 
@@ -983,25 +1139,20 @@ func networkStartup(s *state.State) error {
 
 func networkShutdown(s *state.State) error {
 	// Get a list of managed networks
-	networks, err := s.Cluster.Networks()
+	networks, err := networkLoadAll(s, false)
 	if err != nil {
 		return err
 	}
 
 	// Bring them all up
-	for _, name := range networks {
-		n, err := networkLoadByName(s, name)
-		if err != nil {
-			return err
-		}
-
+	for _, n := range networks {
 		if !n.IsRunning() {
 			continue
 		}
 
 		err = n.Stop()
 		if err != nil {
-			logger.Error("Failed to bring down network", log.Ctx{"err": err, "name": name})
+			logger.Error("Failed to bring down network", log.Ctx{"err": err, "name": n.name})
 		}
 	}
 
@@ -1017,6 +1168,17 @@ func networkStateGet(d *Daemon, r *http.Request) response.Response {
 
 	name := mux.Vars(r)["name"]
 
+	projectName, err := networkProjectParam(d, r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Managed networks of projects have their own bridge on the host.
+	_, dbInfo, _ := d.cluster.NetworkGet(projectName, name)
+	if dbInfo != nil {
+		name = project.Prefix(projectName, name)
+	}
+
 	// Get some information
 	osInfo, _ := net.InterfaceByName(name)
 
@@ -1032,7 +1194,9 @@ type network struct {
 	// Properties
 	state       *state.State
 	id          int64
-	name        string
+	project     string // Project the network is defined in.
+	dbName      string // Name of the network in its project.
+	name        string // Name of the bridge on the host.
 	description string
 
 	// config
@@ -1055,7 +1219,7 @@ func (n *network) IsUsed() bool {
 	}
 
 	for _, inst := range insts {
-		if networkIsInUse(inst, n.name) {
+		if networkIsInUse(n.state, inst, n.name) {
 			return true
 		}
 	}
@@ -1079,7 +1243,7 @@ func (n *network) Delete(withDatabase bool) error {
 	}
 
 	// Remove the network from the database
-	err := n.state.Cluster.NetworkDelete(n.name)
+	err := n.state.Cluster.NetworkDelete(n.project, n.dbName)
 	if err != nil {
 		return err
 	}
@@ -1102,12 +1266,13 @@ func (n *network) Rename(name string) error {
 	}
 
 	// Rename directory
-	if shared.PathExists(shared.VarPath("networks", name)) {
-		os.RemoveAll(shared.VarPath("networks", name))
+	hostName := project.Prefix(n.project, name)
+	if shared.PathExists(shared.VarPath("networks", hostName)) {
+		os.RemoveAll(shared.VarPath("networks", hostName))
 	}
 
 	if shared.PathExists(shared.VarPath("networks", n.name)) {
-		err := os.Rename(shared.VarPath("networks", n.name), shared.VarPath("networks", name))
+		err := os.Rename(shared.VarPath("networks", n.name), shared.VarPath("networks", hostName))
 		if err != nil {
 			return err
 		}
@@ -1115,18 +1280,19 @@ func (n *network) Rename(name string) error {
 
 	forkDNSLogPath := fmt.Sprintf("forkdns.%s.log", n.name)
 	if shared.PathExists(shared.LogPath(forkDNSLogPath)) {
-		err := os.Rename(forkDNSLogPath, shared.LogPath(fmt.Sprintf("forkdns.%s.log", name)))
+		err := os.Rename(forkDNSLogPath, shared.LogPath(fmt.Sprintf("forkdns.%s.log", hostName)))
 		if err != nil {
 			return err
 		}
 	}
 
 	// Rename the database entry
-	err := n.state.Cluster.NetworkRename(n.name, name)
+	err := n.state.Cluster.NetworkRename(n.project, n.dbName, name)
 	if err != nil {
 		return err
 	}
-	n.name = name
+	n.dbName = name
+	n.name = hostName
 
 	// Bring the network up
 	err = n.Start()
@@ -1372,23 +1538,29 @@ func (n *network) Setup(oldConfig map[string]string) error {
 		"--no-ping", // --no-ping is very important to prevent delays to lease file updates.
 		fmt.Sprintf("--interface=%s", n.name)}
 
-	dnsmasqVersion, err := dnsmasq.GetVersion()
-	if err != nil {
-		return err
-	}
+	// The native DHCP and DNS server is configured alongside, only one of them gets started.
+	nativeDHCPDNS := n.config["dns.mode"] == "managed-native"
+	dhcpdnsConfig := dhcpdns.Config{Interface: n.name}
 
-	// --dhcp-rapid-commit option is only supported on >2.79
-	minVer, _ := version.NewDottedVersion("2.79")
-	if dnsmasqVersion.Compare(minVer) > 0 {
-		dnsmasqCmd = append(dnsmasqCmd, "--dhcp-rapid-commit")
-	}
+	if !nativeDHCPDNS {
+		dnsmasqVersion, err := dnsmasq.GetVersion()
+		if err != nil {
+			return err
+		}
 
-	if !daemon.Debug {
-		// --quiet options are only supported on >2.67
-		minVer, _ := version.NewDottedVersion("2.67")
+		// --dhcp-rapid-commit option is only supported on >2.79
+		minVer, _ := version.NewDottedVersion("2.79")
+		if dnsmasqVersion.Compare(minVer) > 0 {
+			dnsmasqCmd = append(dnsmasqCmd, "--dhcp-rapid-commit")
+		}
 
-		if err == nil && dnsmasqVersion.Compare(minVer) > 0 {
-			dnsmasqCmd = append(dnsmasqCmd, []string{"--quiet-dhcp", "--quiet-dhcp6", "--quiet-ra"}...)
+		if !daemon.Debug {
+			// --quiet options are only supported on >2.67
+			minVer, _ := version.NewDottedVersion("2.67")
+
+			if err == nil && dnsmasqVersion.Compare(minVer) > 0 {
+				dnsmasqCmd = append(dnsmasqCmd, []string{"--quiet-dhcp", "--quiet-dhcp6", "--quiet-ra"}...)
+			}
 		}
 	}
 
@@ -1402,6 +1574,7 @@ func (n *network) Setup(oldConfig map[string]string) error {
 
 		// Update the dnsmasq config
 		dnsmasqCmd = append(dnsmasqCmd, fmt.Sprintf("--listen-address=%s", ip.String()))
+		dhcpdnsConfig.IPv4Address = n.config["ipv4.address"]
 		if n.config["ipv4.dhcp"] == "" || shared.IsTrue(n.config["ipv4.dhcp"]) {
 			if !shared.StringInSlice("--dhcp-no-override", dnsmasqCmd) {
 				dnsmasqCmd = append(dnsmasqCmd, []string{"--dhcp-no-override", "--dhcp-authoritative", fmt.Sprintf("--dhcp-leasefile=%s", shared.VarPath("networks", n.name, "dnsmasq.leases")), fmt.Sprintf("--dhcp-hostsfile=%s", shared.VarPath("networks", n.name, "dnsmasq.hosts"))}...)
@@ -1416,13 +1589,19 @@ func (n *network) Setup(oldConfig map[string]string) error {
 				expiry = n.config["ipv4.dhcp.expiry"]
 			}
 
+			dhcpdnsConfig.IPv4DHCP = true
+			dhcpdnsConfig.IPv4Gateway = n.config["ipv4.dhcp.gateway"]
+			dhcpdnsConfig.IPv4Expiry = expiry
+
 			if n.config["ipv4.dhcp.ranges"] != "" {
 				for _, dhcpRange := range strings.Split(n.config["ipv4.dhcp.ranges"], ",") {
 					dhcpRange = strings.TrimSpace(dhcpRange)
 					dnsmasqCmd = append(dnsmasqCmd, []string{"--dhcp-range", fmt.Sprintf("%s,%s", strings.Replace(dhcpRange, "-", ",", -1), expiry)}...)
+					dhcpdnsConfig.IPv4Ranges = append(dhcpdnsConfig.IPv4Ranges, dhcpRange)
 				}
 			} else {
 				dnsmasqCmd = append(dnsmasqCmd, []string{"--dhcp-range", fmt.Sprintf("%s,%s,%s", networkGetIP(subnet, 2).String(), networkGetIP(subnet, -2).String(), expiry)}...)
+				dhcpdnsConfig.IPv4Ranges = []string{fmt.Sprintf("%s-%s", networkGetIP(subnet, 2).String(), networkGetIP(subnet, -2).String())}
 			}
 		}
 
@@ -1434,19 +1613,19 @@ func (n *network) Setup(oldConfig map[string]string) error {
 
 		// Configure NAT
 		if shared.IsTrue(n.config["ipv4.nat"]) {
-			//If a SNAT source address is specified, use that, otherwise default to using MASQUERADE mode.
-			args := []string{"-s", subnet.String(), "!", "-d", subnet.String(), "-j", "MASQUERADE"}
+			// If a SNAT source address is specified, use that, otherwise default to using MASQUERADE mode.
+			var srcIP net.IP
 			if n.config["ipv4.nat.address"] != "" {
-				args = []string{"-s", subnet.String(), "!", "-d", subnet.String(), "-j", "SNAT", "--to", n.config["ipv4.nat.address"]}
+				srcIP = net.ParseIP(n.config["ipv4.nat.address"])
 			}
 
 			if n.config["ipv4.nat.order"] == "after" {
-				err = n.state.Firewall.NetworkSetupNAT(firewallConsts.FamilyIPv4, n.name, firewallConsts.LocationAppend, args...)
+				err = n.state.Firewall.NetworkSetupNAT(firewallConsts.FamilyIPv4, n.name, firewallConsts.LocationAppend, *subnet, srcIP)
 				if err != nil {
 					return err
 				}
 			} else {
-				err = n.state.Firewall.NetworkSetupNAT(firewallConsts.FamilyIPv4, n.name, firewallConsts.LocationPrepend, args...)
+				err = n.state.Firewall.NetworkSetupNAT(firewallConsts.FamilyIPv4, n.name, firewallConsts.LocationPrepend, *subnet, srcIP)
 				if err != nil {
 					return err
 				}
@@ -1520,7 +1699,10 @@ func (n *network) Setup(oldConfig map[string]string) error {
 
 		// Update the dnsmasq config
 		dnsmasqCmd = append(dnsmasqCmd, []string{fmt.Sprintf("--listen-address=%s", ip.String()), "--enable-ra"}...)
+		dhcpdnsConfig.IPv6Address = n.config["ipv6.address"]
 		if n.config["ipv6.dhcp"] == "" || shared.IsTrue(n.config["ipv6.dhcp"]) {
+			dhcpdnsConfig.IPv6SLAACNames = true
+
 			if n.config["ipv6.firewall"] == "" || shared.IsTrue(n.config["ipv6.firewall"]) {
 				// Setup basic iptables overrides for DHCP/DNS
 				n.state.Firewall.NetworkSetupIPv6DNSOverrides(n.name)
@@ -1553,6 +1735,44 @@ func (n *network) Setup(oldConfig map[string]string) error {
 			dnsmasqCmd = append(dnsmasqCmd, []string{"--dhcp-range", fmt.Sprintf("::,constructor:%s,ra-only", n.name)}...)
 		}
 
+		// Configure the router advertisements
+		if n.config["ipv6.ra.dns"] != "" {
+			servers := []string{}
+			for _, server := range strings.Split(n.config["ipv6.ra.dns"], ",") {
+				servers = append(servers, fmt.Sprintf("[%s]", strings.TrimSpace(server)))
+				dhcpdnsConfig.IPv6RADNS = append(dhcpdnsConfig.IPv6RADNS, strings.TrimSpace(server))
+			}
+
+			dnsmasqCmd = append(dnsmasqCmd, fmt.Sprintf("--dhcp-option=option6:dns-server,%s", strings.Join(servers, ",")))
+		}
+
+		raNoDefaultRoute := n.config["ipv6.ra.default_route"] != "" && !shared.IsTrue(n.config["ipv6.ra.default_route"])
+		dhcpdnsConfig.IPv6RADefaultRoute = !raNoDefaultRoute
+		if n.config["ipv6.ra.mtu"] != "" {
+			raMTU, err := strconv.ParseUint(n.config["ipv6.ra.mtu"], 10, 32)
+			if err != nil {
+				return err
+			}
+
+			dhcpdnsConfig.IPv6RAMTU = uint32(raMTU)
+		}
+
+		if n.config["ipv6.ra.mtu"] != "" || raNoDefaultRoute {
+			// An interval of 0 keeps the dnsmasq default and a router lifetime of 0 stops the
+			// bridge from being advertised as a default router.
+			raParam := n.name
+			if n.config["ipv6.ra.mtu"] != "" {
+				raParam = fmt.Sprintf("%s,mtu:%s", raParam, n.config["ipv6.ra.mtu"])
+			}
+
+			raParam = fmt.Sprintf("%s,0", raParam)
+			if raNoDefaultRoute {
+				raParam = fmt.Sprintf("%s,0", raParam)
+			}
+
+			dnsmasqCmd = append(dnsmasqCmd, fmt.Sprintf("--ra-param=%s", raParam))
+		}
+
 		// Allow forwarding
 		if n.config["ipv6.routing"] == "" || shared.IsTrue(n.config["ipv6.routing"]) {
 			// Get a list of proc entries
@@ -1605,18 +1825,19 @@ func (n *network) Setup(oldConfig map[string]string) error {
 
 		// Configure NAT
 		if shared.IsTrue(n.config["ipv6.nat"]) {
-			args := []string{"-s", subnet.String(), "!", "-d", subnet.String(), "-j", "MASQUERADE"}
+			// If a SNAT source address is specified, use that, otherwise default to using MASQUERADE mode.
+			var srcIP net.IP
 			if n.config["ipv6.nat.address"] != "" {
-				args = []string{"-s", subnet.String(), "!", "-d", subnet.String(), "-j", "SNAT", "--to", n.config["ipv6.nat.address"]}
+				srcIP = net.ParseIP(n.config["ipv6.nat.address"])
 			}
 
 			if n.config["ipv6.nat.order"] == "after" {
-				err = n.state.Firewall.NetworkSetupNAT(firewallConsts.FamilyIPv6, n.name, firewallConsts.LocationAppend, args...)
+				err = n.state.Firewall.NetworkSetupNAT(firewallConsts.FamilyIPv6, n.name, firewallConsts.LocationAppend, *subnet, srcIP)
 				if err != nil {
 					return err
 				}
 			} else {
-				err = n.state.Firewall.NetworkSetupNAT(firewallConsts.FamilyIPv6, n.name, firewallConsts.LocationPrepend, args...)
+				err = n.state.Firewall.NetworkSetupNAT(firewallConsts.FamilyIPv6, n.name, firewallConsts.LocationPrepend, *subnet, srcIP)
 				if err != nil {
 					return err
 				}
@@ -1730,6 +1951,11 @@ func (n *network) Setup(oldConfig map[string]string) error {
 			fmt.Sprintf("--dhcp-hostsfile=%s", shared.VarPath("networks", n.name, "dnsmasq.hosts")),
 			"--dhcp-range", fmt.Sprintf("%s,%s,%s", networkGetIP(hostSubnet, 2).String(), networkGetIP(hostSubnet, -2).String(), expiry)}...)
 
+		dhcpdnsConfig.IPv4Address = fanAddress
+		dhcpdnsConfig.IPv4DHCP = true
+		dhcpdnsConfig.IPv4Expiry = expiry
+		dhcpdnsConfig.IPv4Ranges = []string{fmt.Sprintf("%s-%s", networkGetIP(hostSubnet, 2).String(), networkGetIP(hostSubnet, -2).String())}
+
 		// Setup the tunnel
 		if n.config["fan.type"] == "ipip" {
 			_, err = shared.RunCommand("ip", "-4", "route", "flush", "dev", "tunl0")
@@ -1896,6 +2122,12 @@ func (n *network) Setup(oldConfig map[string]string) error {
 		}
 	}
 
+	// Configure BGP
+	err = n.bgpSetup(oldConfig)
+	if err != nil {
+		return err
+	}
+
 	// Kill any existing dnsmasq and forkdns daemon for this network
 	err = dnsmasq.Kill(n.name, false)
 	if err != nil {
@@ -1920,6 +2152,8 @@ func (n *network) Setup(oldConfig map[string]string) error {
 				dnsmasqCmd = append(dnsmasqCmd, "-s", dnsDomain)
 				dnsmasqCmd = append(dnsmasqCmd, "-S", fmt.Sprintf("/%s/%s#1053", dnsDomain, dnsClusteredAddress))
 				dnsmasqCmd = append(dnsmasqCmd, fmt.Sprintf("--rev-server=%s,%s#1053", overlaySubnet, dnsClusteredAddress))
+				dhcpdnsConfig.DNSRelay = fmt.Sprintf("%s:1053", dnsClusteredAddress)
+				dhcpdnsConfig.DNSRelaySubnet = overlaySubnet.String()
 			} else {
 				dnsmasqCmd = append(dnsmasqCmd, []string{"-s", dnsDomain, "-S", fmt.Sprintf("/%s/", dnsDomain)}...)
 			}
@@ -1945,16 +2179,25 @@ func (n *network) Setup(oldConfig map[string]string) error {
 			}
 		}
 
-		// Check for dnsmasq
-		_, err := exec.LookPath("dnsmasq")
-		if err != nil {
-			return fmt.Errorf("dnsmasq is required for LXD managed bridges")
-		}
+		if nativeDHCPDNS {
+			// Start the native DHCP and DNS server
+			dhcpdnsConfig.Domain = dnsDomain
+			err = n.spawnForkDHCPDNS(dhcpdnsConfig)
+			if err != nil {
+				return err
+			}
+		} else {
+			// Check for dnsmasq
+			_, err := exec.LookPath("dnsmasq")
+			if err != nil {
+				return fmt.Errorf("dnsmasq is required for LXD managed bridges")
+			}
 
-		// Start dnsmasq (occasionally races, try a few times)
-		_, err = shared.TryRunCommand(dnsmasqCmd[0], dnsmasqCmd[1:]...)
-		if err != nil {
-			return fmt.Errorf("Failed to run: %s: %v", strings.Join(dnsmasqCmd, " "), err)
+			// Start dnsmasq (occasionally races, try a few times)
+			_, err = shared.TryRunCommand(dnsmasqCmd[0], dnsmasqCmd[1:]...)
+			if err != nil {
+				return fmt.Errorf("Failed to run: %s: %v", strings.Join(dnsmasqCmd, " "), err)
+			}
 		}
 
 		// Update the static leases
@@ -2051,8 +2294,14 @@ func (n *network) Stop() error {
 		}
 	}
 
+	// Withdraw the BGP peers and prefixes
+	err := n.bgpClear(n.config)
+	if err != nil {
+		return err
+	}
+
 	// Kill any existing dnsmasq and forkdns daemon for this network
-	err := dnsmasq.Kill(n.name, false)
+	err = dnsmasq.Kill(n.name, false)
 	if err != nil {
 		return err
 	}
@@ -2108,7 +2357,7 @@ func (n *network) Update(newNetwork api.NetworkPut, notify bool) error {
 			n.description = oldDescription
 
 			// Update the database
-			n.state.Cluster.NetworkUpdate(n.name, n.description, n.config)
+			n.state.Cluster.NetworkUpdate(n.project, n.dbName, n.description, n.config)
 
 			// Reset any change that was made to the bridge
 			n.Setup(newConfig)
@@ -2192,14 +2441,14 @@ func (n *network) Update(newNetwork api.NetworkPut, notify bool) error {
 		}
 
 		err = notifier(func(client lxd.InstanceServer) error {
-			return client.UpdateNetwork(n.name, newNetwork, "")
+			return client.UpdateNetwork(n.dbName, newNetwork, "")
 		})
 		if err != nil {
 			return err
 		}
 
 		// Update the database.
-		err = n.state.Cluster.NetworkUpdate(n.name, n.description, n.config)
+		err = n.state.Cluster.NetworkUpdate(n.project, n.dbName, n.description, n.config)
 		if err != nil {
 			return err
 		}
@@ -2243,6 +2492,29 @@ func (n *network) spawnForkDNS(listenAddress string) error {
 	return nil
 }
 
+// spawnForkDHCPDNS writes the configuration of the native DHCP and DNS server and starts it. It
+// uses the pid file of dnsmasq so that it's reloaded and stopped the same way.
+func (n *network) spawnForkDHCPDNS(config dhcpdns.Config) error {
+	err := dhcpdns.WriteConfig(shared.VarPath("networks", n.name, dhcpdns.ConfigFile), config)
+	if err != nil {
+		return err
+	}
+
+	// Spawn the daemon
+	_, err = shared.RunCommand(
+		n.state.OS.ExecPath,
+		"forkdhcpdns",
+		shared.LogPath(fmt.Sprintf("forkdhcpdns.%s.log", n.name)),
+		shared.VarPath("networks", n.name, "dnsmasq.pid"),
+		n.name,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // refreshForkdnsServerAddresses retrieves the IPv4 address of each cluster node (excluding ourselves)
 // for this network. It then updates the forkdns server list file if there are changes.
 func (n *network) refreshForkdnsServerAddresses(heartbeatData *cluster.APIHeartbeat) error {
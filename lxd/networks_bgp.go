@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// networkGetBGPPeers returns the names of the BGP peers defined in a network config.
+func networkGetBGPPeers(config map[string]string) []string {
+	peers := []string{}
+
+	for k := range config {
+		if !strings.HasPrefix(k, "bgp.peers.") {
+			continue
+		}
+
+		fields := strings.Split(k, ".")
+		if !shared.StringInSlice(fields[2], peers) {
+			peers = append(peers, fields[2])
+		}
+	}
+
+	return peers
+}
+
+// bgpOwner returns the owner string used to track the prefixes announced for this network.
+func (n *network) bgpOwner() string {
+	return fmt.Sprintf("network_%d", n.id)
+}
+
+// bgpSetup configures the BGP peers of the network and announces its subnets.
+func (n *network) bgpSetup(oldConfig map[string]string) error {
+	if n.state.BGP == nil {
+		return nil
+	}
+
+	// Clear the previous peers and prefixes.
+	err := n.bgpClear(oldConfig)
+	if err != nil {
+		return err
+	}
+
+	for _, peer := range networkGetBGPPeers(n.config) {
+		address := net.ParseIP(n.config[fmt.Sprintf("bgp.peers.%s.address", peer)])
+		if address == nil {
+			return fmt.Errorf("BGP peer %q is missing a valid address", peer)
+		}
+
+		asn, err := strconv.ParseUint(n.config[fmt.Sprintf("bgp.peers.%s.asn", peer)], 10, 32)
+		if err != nil {
+			return fmt.Errorf("BGP peer %q is missing a valid ASN", peer)
+		}
+
+		err = n.state.BGP.AddPeer(address, uint32(asn), n.config[fmt.Sprintf("bgp.peers.%s.password", peer)])
+		if err != nil {
+			return err
+		}
+	}
+
+	// Announce the subnets which aren't NATed as well as the routed subnets.
+	for _, family := range []string{"ipv4", "ipv6"} {
+		nexthop := net.ParseIP(n.config[fmt.Sprintf("bgp.%s.nexthop", family)])
+
+		subnets := []string{}
+		address := n.config[fmt.Sprintf("%s.address", family)]
+		if !shared.StringInSlice(address, []string{"", "none"}) && !shared.IsTrue(n.config[fmt.Sprintf("%s.nat", family)]) {
+			subnets = append(subnets, address)
+		}
+
+		routes := n.config[fmt.Sprintf("%s.routes", family)]
+		if routes != "" {
+			subnets = append(subnets, strings.Split(routes, ",")...)
+		}
+
+		for _, subnet := range subnets {
+			_, ipNet, err := net.ParseCIDR(strings.TrimSpace(subnet))
+			if err != nil {
+				return err
+			}
+
+			err = n.state.BGP.AddPrefix(*ipNet, nexthop, n.bgpOwner())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// bgpClear removes the BGP peers of the given network config and withdraws its prefixes.
+func (n *network) bgpClear(config map[string]string) error {
+	if n.state.BGP == nil {
+		return nil
+	}
+
+	for _, peer := range networkGetBGPPeers(config) {
+		address := net.ParseIP(config[fmt.Sprintf("bgp.peers.%s.address", peer)])
+		if address == nil {
+			continue
+		}
+
+		err := n.state.BGP.RemovePeer(address)
+		if err != nil {
+			return err
+		}
+	}
+
+	return n.state.BGP.RemovePrefixByOwner(n.bgpOwner())
+}
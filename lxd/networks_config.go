@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 
@@ -56,6 +58,23 @@ var networkConfigKeys = map[string]func(value string) error{
 	"tunnel.TARGET.interface": networkValidName,
 	"tunnel.TARGET.ttl":       shared.IsUint8,
 
+	"bgp.peers.NAME.address": device.NetworkValidAddress,
+	"bgp.peers.NAME.asn": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		_, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("Invalid ASN %q", value)
+		}
+
+		return nil
+	},
+	"bgp.peers.NAME.password": shared.IsAny,
+	"bgp.ipv4.nexthop":        device.NetworkValidAddressV4,
+	"bgp.ipv6.nexthop":        device.NetworkValidAddressV6,
+
 	"ipv4.address": func(value string) error {
 		if shared.IsOneOf(value, []string{"none", "auto"}) == nil {
 			return nil
@@ -92,13 +111,17 @@ var networkConfigKeys = map[string]func(value string) error{
 	"ipv6.dhcp":          shared.IsBool,
 	"ipv6.dhcp.expiry":   shared.IsAny,
 	"ipv6.dhcp.stateful": shared.IsBool,
-	"ipv6.dhcp.ranges":   shared.IsAny,
+	"ipv6.dhcp.ranges":   networkValidDHCPRangesV6,
 	"ipv6.routes":        shared.IsAny,
 	"ipv6.routing":       shared.IsBool,
 
+	"ipv6.ra.default_route": shared.IsBool,
+	"ipv6.ra.dns":           device.NetworkValidAddressV6List,
+	"ipv6.ra.mtu":           shared.IsUint32,
+
 	"dns.domain": shared.IsAny,
 	"dns.mode": func(value string) error {
-		return shared.IsOneOf(value, []string{"dynamic", "managed", "none"})
+		return shared.IsOneOf(value, []string{"dynamic", "managed", "managed-native", "none"})
 	},
 
 	"raw.dnsmasq": shared.IsAny,
@@ -133,6 +156,16 @@ func networkValidateConfig(name string, config map[string]string) error {
 			key = fmt.Sprintf("tunnel.TARGET.%s", fields[2])
 		}
 
+		// BGP peer keys have the peer name in their name, so extract the real key
+		if strings.HasPrefix(key, "bgp.peers.") {
+			fields := strings.Split(key, ".")
+			if len(fields) != 4 {
+				return fmt.Errorf("Invalid network configuration key: %s", k)
+			}
+
+			key = fmt.Sprintf("bgp.peers.NAME.%s", fields[3])
+		}
+
 		// Then validate
 		validator, ok := networkConfigKeys[key]
 		if !ok {
@@ -188,6 +221,91 @@ func networkValidateConfig(name string, config map[string]string) error {
 		}
 	}
 
+	// The native DHCP and DNS server doesn't support everything dnsmasq does.
+	if config["dns.mode"] == "managed-native" {
+		if config["raw.dnsmasq"] != "" {
+			return fmt.Errorf("raw.dnsmasq can't be used with dns.mode set to managed-native")
+		}
+
+		if shared.IsTrue(config["ipv6.dhcp.stateful"]) {
+			return fmt.Errorf("Stateful DHCPv6 isn't supported with dns.mode set to managed-native")
+		}
+	}
+
+	return networkValidateIPv6Config(config)
+}
+
+// networkValidateIPv6Config checks the IPv6 keys which depend on each other. Only the IPv6
+// configuration is looked at so that IPv6-only networks validate the same way as dual-stack ones.
+func networkValidateIPv6Config(config map[string]string) error {
+	hasIPv6 := !shared.StringInSlice(config["ipv6.address"], []string{"", "none"})
+
+	for _, key := range []string{"ipv6.ra.default_route", "ipv6.ra.dns", "ipv6.ra.mtu"} {
+		if config[key] != "" && !hasIPv6 {
+			return fmt.Errorf("%s requires ipv6.address to be set", key)
+		}
+	}
+
+	if config["ipv6.ra.mtu"] != "" {
+		mtu, err := strconv.ParseUint(config["ipv6.ra.mtu"], 10, 32)
+		if err != nil {
+			return fmt.Errorf("Invalid value for an integer: %s", config["ipv6.ra.mtu"])
+		}
+
+		if mtu < 1280 {
+			return fmt.Errorf("The minimum MTU for an IPv6 network is 1280")
+		}
+
+		if config["bridge.mtu"] != "" {
+			bridgeMTU, err := strconv.ParseUint(config["bridge.mtu"], 10, 32)
+			if err == nil && mtu > bridgeMTU {
+				return fmt.Errorf("ipv6.ra.mtu can't be larger than bridge.mtu (%d)", bridgeMTU)
+			}
+		}
+	}
+
+	// The stateful DHCPv6 ranges must be within the bridge subnet ("auto" is only resolved later).
+	if config["ipv6.dhcp.ranges"] != "" && hasIPv6 && config["ipv6.address"] != "auto" {
+		_, subnet, err := net.ParseCIDR(config["ipv6.address"])
+		if err != nil {
+			return err
+		}
+
+		for _, dhcpRange := range strings.Split(config["ipv6.dhcp.ranges"], ",") {
+			for _, addr := range strings.SplitN(strings.TrimSpace(dhcpRange), "-", 2) {
+				if !subnet.Contains(net.ParseIP(addr)) {
+					return fmt.Errorf("DHCPv6 range address %s isn't within the %s subnet", addr, subnet.String())
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// networkValidDHCPRangesV6 validates a comma separated list of FIRST-LAST IPv6 address ranges.
+func networkValidDHCPRangesV6(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	for _, dhcpRange := range strings.Split(value, ",") {
+		fields := strings.SplitN(strings.TrimSpace(dhcpRange), "-", 2)
+		if len(fields) != 2 {
+			return fmt.Errorf("Invalid DHCPv6 range %q (must be FIRST-LAST)", dhcpRange)
+		}
+
+		first := net.ParseIP(fields[0])
+		last := net.ParseIP(fields[1])
+		if first == nil || first.To4() != nil || last == nil || last.To4() != nil {
+			return fmt.Errorf("Invalid DHCPv6 range %q (must be two IPv6 addresses)", dhcpRange)
+		}
+
+		if bytes.Compare(first.To16(), last.To16()) > 0 {
+			return fmt.Errorf("Invalid DHCPv6 range %q (first address is after the last one)", dhcpRange)
+		}
+	}
+
 	return nil
 }
 
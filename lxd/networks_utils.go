@@ -37,13 +37,13 @@ import (
 var forkdnsServersLock sync.Mutex
 
 func networkAutoAttach(cluster *db.Cluster, devName string) error {
-	_, dbInfo, err := cluster.NetworkGetInterface(devName)
+	_, projectName, dbInfo, err := cluster.NetworkGetInterface(devName)
 	if err != nil {
 		// No match found, move on
 		return nil
 	}
 
-	return device.NetworkAttachInterface(dbInfo.Name, devName)
+	return device.NetworkAttachInterface(project.Prefix(projectName, dbInfo.Name), devName)
 }
 
 func networkDetachInterface(netName string, devName string) error {
@@ -65,12 +65,37 @@ func networkDetachInterface(netName string, devName string) error {
 	return nil
 }
 
-func networkGetInterfaces(cluster *db.Cluster) ([]string, error) {
-	networks, err := cluster.Networks()
+// networkGetInterfaces returns the names of the managed networks of the given
+// project, followed by the host interfaces which aren't the bridge of a managed
+// network.
+func networkGetInterfaces(cluster *db.Cluster, projectName string) ([]string, error) {
+	networks, err := cluster.Networks(projectName)
 	if err != nil {
 		return nil, err
 	}
 
+	// Collect the bridges of the managed networks of all projects.
+	var projects []string
+	err = cluster.Transaction(func(tx *db.ClusterTx) error {
+		projects, err = tx.ProjectNames()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bridges := []string{}
+	for _, p := range projects {
+		names, err := cluster.Networks(p)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range names {
+			bridges = append(bridges, project.Prefix(p, name))
+		}
+	}
+
 	ifaces, err := net.Interfaces()
 	if err != nil {
 		return nil, err
@@ -82,6 +107,11 @@ func networkGetInterfaces(cluster *db.Cluster) ([]string, error) {
 			continue
 		}
 
+		// Ignore the bridges of managed networks
+		if shared.StringInSlice(iface.Name, bridges) {
+			continue
+		}
+
 		// Append to the list
 		if !shared.StringInSlice(iface.Name, networks) {
 			networks = append(networks, iface.Name)
@@ -91,7 +121,9 @@ func networkGetInterfaces(cluster *db.Cluster) ([]string, error) {
 	return networks, nil
 }
 
-func networkIsInUse(c instance.Instance, name string) bool {
+// networkIsInUse returns whether the instance has a NIC using the host
+// interface with the given name.
+func networkIsInUse(s *state.State, c instance.Instance, name string) bool {
 	for _, d := range c.ExpandedDevices() {
 		if d["type"] != "nic" {
 			continue
@@ -105,7 +137,18 @@ func networkIsInUse(c instance.Instance, name string) bool {
 			continue
 		}
 
-		if device.NetworkGetHostDevice(d["parent"], d["vlan"]) == name {
+		// Parents may refer to the networks of the instance's project.
+		parent := d["parent"]
+		if d["nictype"] == "bridged" {
+			parentProject, err := device.NetworkProject(s, c.Project(), parent)
+			if err != nil {
+				return true
+			}
+
+			parent = project.Prefix(parentProject, parent)
+		}
+
+		if device.NetworkGetHostDevice(parent, d["vlan"]) == name {
 			return true
 		}
 	}
@@ -622,21 +665,28 @@ func networkKillForkDNS(name string) error {
 	return nil
 }
 
+// networkUpdateStatic rebuilds the static DHCP allocations of the network whose
+// bridge has the given name, or of all networks if the name is empty.
 func networkUpdateStatic(s *state.State, networkName string) error {
 	// We don't want to race with ourselves here
 	dnsmasq.ConfigMutex.Lock()
 	defer dnsmasq.ConfigMutex.Unlock()
 
-	// Get all the networks
-	var networks []string
-	if networkName == "" {
-		var err error
-		networks, err = s.Cluster.Networks()
-		if err != nil {
-			return err
+	// Get all the networks, by bridge name
+	allNetworks, err := networkLoadAll(s, false)
+	if err != nil {
+		return err
+	}
+
+	managed := map[string]*network{}
+	networks := []string{}
+	for _, n := range allNetworks {
+		if networkName != "" && n.name != networkName {
+			continue
 		}
-	} else {
-		networks = []string{networkName}
+
+		managed[n.name] = n
+		networks = append(networks, n.name)
 	}
 
 	// Get all the instances
@@ -651,7 +701,18 @@ func networkUpdateStatic(s *state.State, networkName string) error {
 		// Go through all its devices (including profiles
 		for k, d := range inst.ExpandedDevices() {
 			// Skip uninteresting entries
-			if d["type"] != "nic" || d["nictype"] != "bridged" || !shared.StringInSlice(d["parent"], networks) {
+			if d["type"] != "nic" || d["nictype"] != "bridged" {
+				continue
+			}
+
+			// Parents may refer to the networks of the instance's project.
+			parentProject, err := device.NetworkProject(s, inst.Project(), d["parent"])
+			if err != nil {
+				return err
+			}
+
+			parent := project.Prefix(parentProject, d["parent"])
+			if !shared.StringInSlice(parent, networks) {
 				continue
 			}
 
@@ -664,13 +725,13 @@ func networkUpdateStatic(s *state.State, networkName string) error {
 			}
 
 			// Add the new host entries
-			_, ok := entries[d["parent"]]
+			_, ok := entries[parent]
 			if !ok {
-				entries[d["parent"]] = [][]string{}
+				entries[parent] = [][]string{}
 			}
 
 			if (shared.IsTrue(d["security.ipv4_filtering"]) && d["ipv4.address"] == "") || (shared.IsTrue(d["security.ipv6_filtering"]) && d["ipv6.address"] == "") {
-				curIPv4, curIPv6, err := dnsmasq.DHCPStaticIPs(d["parent"], inst.Name())
+				curIPv4, curIPv6, err := dnsmasq.DHCPStaticIPs(parent, inst.Name())
 				if err != nil && !os.IsNotExist(err) {
 					return err
 				}
@@ -684,7 +745,7 @@ func networkUpdateStatic(s *state.State, networkName string) error {
 				}
 			}
 
-			entries[d["parent"]] = append(entries[d["parent"]], []string{d["hwaddr"], inst.Project(), inst.Name(), d["ipv4.address"], d["ipv6.address"]})
+			entries[parent] = append(entries[parent], []string{d["hwaddr"], inst.Project(), inst.Name(), d["ipv4.address"], d["ipv6.address"]})
 		}
 	}
 
@@ -697,11 +758,7 @@ func networkUpdateStatic(s *state.State, networkName string) error {
 			continue
 		}
 
-		n, err := networkLoadByName(s, network)
-		if err != nil {
-			return err
-		}
-		config := n.Config()
+		config := managed[network].Config()
 
 		// Wipe everything clean
 		files, err := ioutil.ReadDir(shared.VarPath("networks", network, "dnsmasq.hosts"))
@@ -813,17 +870,12 @@ func networkUpdateForkdnsServersFile(networkName string, addresses []string) err
 // networkUpdateForkdnsServersTask runs every 30s and refreshes the forkdns servers list.
 func networkUpdateForkdnsServersTask(s *state.State, heartbeatData *cluster.APIHeartbeat) error {
 	// Get a list of managed networks
-	networks, err := s.Cluster.NetworksNotPending()
+	networks, err := networkLoadAll(s, true)
 	if err != nil {
 		return err
 	}
 
-	for _, name := range networks {
-		n, err := networkLoadByName(s, name)
-		if err != nil {
-			return err
-		}
-
+	for _, n := range networks {
 		if n.config["bridge.mode"] == "fan" {
 			err := n.refreshForkdnsServerAddresses(heartbeatData)
 			if err != nil {
@@ -0,0 +1,373 @@
+package nftables
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	firewallConsts "github.com/lxc/lxd/lxd/firewall/consts"
+	"github.com/lxc/lxd/shared"
+)
+
+// bridgeTable is the name of the nftables bridge family table used by LXD.
+const bridgeTable = "lxd"
+
+// bridgeChainPriority runs our chains ahead of any distribution supplied bridge filtering.
+const bridgeChainPriority = -200
+
+// ipTable is the name of the nftables ip and ip6 family tables used by LXD.
+const ipTable = "lxd"
+
+// NFTables is an implementation of LXD firewall using nftables. All the rules live in LXD's own tables
+// with one set of chains per network or device so they can be replaced and removed atomically.
+type NFTables struct{}
+
+// Available returns whether nftables can be used on this host.
+func Available() bool {
+	_, err := exec.LookPath("nft")
+	if err != nil {
+		return false
+	}
+
+	_, err = shared.RunCommand("nft", "list", "tables")
+	return err == nil
+}
+
+// apply runs the given nft script as a single atomic transaction.
+func apply(script string) error {
+	stderr := &bytes.Buffer{}
+	err := shared.RunCommandWithFds(strings.NewReader(script), stderr, "nft", "-f", "-")
+	if err != nil {
+		return fmt.Errorf("Failed to apply nftables rules: %v (%s)", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// chainName returns a valid nftables identifier for a per-network or per-device chain.
+func chainName(prefix string, name string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+
+		return '_'
+	}, name)
+
+	return fmt.Sprintf("%s_%s", prefix, sanitized)
+}
+
+// tableFamily returns the nftables family matching the IP family.
+func tableFamily(family firewallConsts.Family) string {
+	if family == firewallConsts.FamilyIPv6 {
+		return "ip6"
+	}
+
+	return "ip"
+}
+
+// natPriority returns the priority of source NAT chains, either ahead or after the distribution rules.
+func natPriority(location firewallConsts.Location) int {
+	if location == firewallConsts.LocationAppend {
+		return 101
+	}
+
+	return 99
+}
+
+// addBaseChain writes the commands creating a base chain and flushing any rules it already had.
+func addBaseChain(buf *strings.Builder, family string, chain string, chainType string, hook string, priority int) {
+	fmt.Fprintf(buf, "add table %s %s\n", family, ipTable)
+	fmt.Fprintf(buf, "add chain %s %s %s { type %s hook %s priority %d; policy accept; }\n", family, ipTable, chain, chainType, hook, priority)
+	fmt.Fprintf(buf, "flush chain %s %s %s\n", family, ipTable, chain)
+}
+
+// removeChains removes the given chains from the family table if they exist.
+func removeChains(family string, chains ...string) error {
+	// Adding the chains before flushing and deleting them makes removal idempotent.
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "add table %s %s\n", family, ipTable)
+	for _, chain := range chains {
+		fmt.Fprintf(buf, "add chain %s %s %s\n", family, ipTable, chain)
+		fmt.Fprintf(buf, "flush chain %s %s %s\n", family, ipTable, chain)
+		fmt.Fprintf(buf, "delete chain %s %s %s\n", family, ipTable, chain)
+	}
+
+	return apply(buf.String())
+}
+
+// networkChains returns the chains used by a network for the given table.
+func networkChains(table firewallConsts.Table, name string) []string {
+	switch table {
+	case firewallConsts.TableAll, firewallConsts.TableFilter:
+		return []string{chainName("in", name), chainName("out", name), chainName("fwd", name)}
+	case firewallConsts.TableNat:
+		return []string{chainName("nat", name), chainName("fan", name)}
+	}
+
+	return nil
+}
+
+// instanceChains returns the chains used by an instance device for the given table.
+func instanceChains(table firewallConsts.Table, comment string) []string {
+	switch table {
+	case firewallConsts.TableAll, firewallConsts.TableNat:
+		return []string{chainName("proxy_prert", comment), chainName("proxy_out", comment)}
+	}
+
+	return nil
+}
+
+// Lower-level Functions
+
+// NetworkClear removes network rules.
+func (nft NFTables) NetworkClear(family firewallConsts.Family, table firewallConsts.Table, comment string) error {
+	chains := networkChains(table, comment)
+	if len(chains) == 0 {
+		return nil
+	}
+
+	return removeChains(tableFamily(family), chains...)
+}
+
+// InstanceClear removes rules all rules for the given instance.
+func (nft NFTables) InstanceClear(family firewallConsts.Family, table firewallConsts.Table, comment string) error {
+	chains := instanceChains(table, comment)
+	if len(chains) == 0 {
+		return nil
+	}
+
+	return removeChains(tableFamily(family), chains...)
+}
+
+// VerifyIPv6Module always succeeds as nftables bridge rules see IPv6 traffic natively.
+func (nft NFTables) VerifyIPv6Module() error {
+	return nil
+}
+
+// Proxy Functions
+
+// InstanceProxySetupNAT creates a default NAT setup.
+func (nft NFTables) InstanceProxySetupNAT(family firewallConsts.Family, connType, address, port string, destAddr net.IP, destPort string, comment string) error {
+	ipFamily := tableFamily(family)
+
+	toDest := fmt.Sprintf("%s:%s", destAddr, destPort)
+	if family == firewallConsts.FamilyIPv6 {
+		toDest = fmt.Sprintf("[%s]:%s", destAddr, destPort)
+	}
+
+	// The chains can hold the rules of multiple listen addresses, so they're only flushed on clear.
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "add table %s %s\n", ipFamily, ipTable)
+
+	hooks := map[string]string{
+		chainName("proxy_prert", comment): "prerouting", // outbound <-> instance
+		chainName("proxy_out", comment):   "output",     // host <-> instance
+	}
+
+	for chain, hook := range hooks {
+		fmt.Fprintf(buf, "add chain %s %s %s { type nat hook %s priority -100; policy accept; }\n", ipFamily, ipTable, chain, hook)
+		fmt.Fprintf(buf, "add rule %s %s %s %s daddr %s %s dport %s dnat to %s comment \"generated for LXD container %s\"\n", ipFamily, ipTable, chain, ipFamily, address, connType, port, toDest, comment)
+	}
+
+	return apply(buf.String())
+}
+
+// NIC Bridged Functions
+
+// InstanceNicBridgedRemoveFilters removes the filtering chains of the device.
+func (nft NFTables) InstanceNicBridgedRemoveFilters(m deviceConfig.Device, ipv4 net.IP, ipv6 net.IP) error {
+	if m["host_name"] == "" {
+		return fmt.Errorf("Failed to remove network filters for %s: host_name not defined", m["name"])
+	}
+
+	// Adding the chains before deleting them makes removal idempotent.
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "add table bridge %s\n", bridgeTable)
+	for _, chain := range []string{chainName("in", m["host_name"]), chainName("fwd", m["host_name"])} {
+		fmt.Fprintf(buf, "add chain bridge %s %s\n", bridgeTable, chain)
+		fmt.Fprintf(buf, "flush chain bridge %s %s\n", bridgeTable, chain)
+		fmt.Fprintf(buf, "delete chain bridge %s %s\n", bridgeTable, chain)
+	}
+
+	err := apply(buf.String())
+	if err != nil {
+		return fmt.Errorf("Failed to remove network filters for %s: %v", m["name"], err)
+	}
+
+	return nil
+}
+
+// InstanceNicBridgedSetFilters sets up the filtering chains of the device. Any existing rules for the
+// device are atomically replaced so the instance is never left unfiltered while addresses change.
+func (nft NFTables) InstanceNicBridgedSetFilters(m deviceConfig.Device, ipv4 net.IP, ipv6 net.IP, comment string) error {
+	mac, err := net.ParseMAC(m["hwaddr"])
+	if err != nil {
+		return err
+	}
+
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "add table bridge %s\n", bridgeTable)
+
+	hooks := map[string]string{
+		chainName("in", m["host_name"]):  "input",
+		chainName("fwd", m["host_name"]): "forward",
+	}
+
+	for chain, hook := range hooks {
+		fmt.Fprintf(buf, "add chain bridge %s %s { type filter hook %s priority %d; policy accept; }\n", bridgeTable, chain, hook, bridgeChainPriority)
+		fmt.Fprintf(buf, "flush chain bridge %s %s\n", bridgeTable, chain)
+
+		for _, rule := range generateFilterRules(m, mac, ipv4, ipv6, hook == "input") {
+			fmt.Fprintf(buf, "add rule bridge %s %s iifname \"%s\" %s comment \"generated for %s\"\n", bridgeTable, chain, m["host_name"], rule, comment)
+		}
+	}
+
+	return apply(buf.String())
+}
+
+// generateFilterRules returns the rule bodies (without chain or interface match) for the device.
+// Host bound DHCP and router solicitation traffic is only allowed on the input hook.
+func generateFilterRules(m deviceConfig.Device, mac net.HardwareAddr, ipv4 net.IP, ipv6 net.IP, input bool) []string {
+	// MAC source filtering rules. Blocks any packet coming from instance with an incorrect Ethernet source MAC.
+	// This is required for IP filtering too.
+	rules := []string{
+		fmt.Sprintf("ether saddr != %s drop", mac.String()),
+	}
+
+	if shared.IsTrue(m["security.ipv4_filtering"]) && ipv4 != nil {
+		// Prevent ARP MAC and IP spoofing.
+		rules = append(rules,
+			fmt.Sprintf("arp saddr ether != %s drop", mac.String()),
+			fmt.Sprintf("arp saddr ip != %s drop", ipv4.String()),
+		)
+
+		// Allow DHCPv4 to the host only. This must come before the IP source filtering rule below.
+		if input {
+			rules = append(rules, "ip saddr 0.0.0.0 ip daddr 255.255.255.255 udp dport 67 accept")
+		}
+
+		// IP source filtering. Blocks any packet coming from instance with an incorrect IP source address.
+		rules = append(rules, fmt.Sprintf("ether type ip ip saddr != %s drop", ipv4.String()))
+	}
+
+	if shared.IsTrue(m["security.ipv6_filtering"]) && ipv6 != nil {
+		// Allow DHCPv6 and Router Solicitation to the host only. This must come before the IP source filtering rules below.
+		if input {
+			rules = append(rules,
+				"ip6 saddr fe80::/10 ip6 daddr ff02::1:2 udp dport 547 accept",
+				"ip6 saddr fe80::/10 ip6 daddr ff02::2 icmpv6 type nd-router-solicit accept",
+			)
+		}
+
+		// Prevent Neighbor Advertisement IP and MAC spoofing. The target address is at offset 64 of the
+		// ICMPv6 header and the target link-layer address option follows it.
+		rules = append(rules,
+			fmt.Sprintf("icmpv6 type nd-neighbor-advert @th,64,128 != 0x%s drop", ipv6Hex(ipv6)),
+			fmt.Sprintf("icmpv6 type nd-neighbor-advert @th,208,48 != 0x%s drop", strings.Replace(mac.String(), ":", "", -1)),
+			// IP source filtering. Link-local addresses are needed for NDP and DHCPv6.
+			fmt.Sprintf("ether type ip6 ip6 saddr != { fe80::/10, %s } drop", ipv6.String()),
+		)
+	}
+
+	return rules
+}
+
+func ipv6Hex(ip net.IP) string {
+	return fmt.Sprintf("%x", []byte(ip.To16()))
+}
+
+// Network Functions
+
+// NetworkSetupAllowForwarding allows forwarding dependent on boolean argument
+func (nft NFTables) NetworkSetupAllowForwarding(family firewallConsts.Family, name string, actionType firewallConsts.Action) error {
+	verdict := "drop"
+	if actionType == firewallConsts.ActionAccept {
+		verdict = "accept"
+	} else if actionType == firewallConsts.ActionReject {
+		verdict = "reject"
+	}
+
+	ipFamily := tableFamily(family)
+	chain := chainName("fwd", name)
+
+	buf := &strings.Builder{}
+	addBaseChain(buf, ipFamily, chain, "filter", "forward", 0)
+	fmt.Fprintf(buf, "add rule %s %s %s iifname \"%s\" %s comment \"generated for LXD network %s\"\n", ipFamily, ipTable, chain, name, verdict, name)
+	fmt.Fprintf(buf, "add rule %s %s %s oifname \"%s\" %s comment \"generated for LXD network %s\"\n", ipFamily, ipTable, chain, name, verdict, name)
+
+	return apply(buf.String())
+}
+
+// NetworkSetupNAT configures NAT
+func (nft NFTables) NetworkSetupNAT(family firewallConsts.Family, name string, location firewallConsts.Location, subnet net.IPNet, srcIP net.IP) error {
+	ipFamily := tableFamily(family)
+	chain := chainName("nat", name)
+
+	buf := &strings.Builder{}
+	addBaseChain(buf, ipFamily, chain, "nat", "postrouting", natPriority(location))
+	fmt.Fprintf(buf, "add rule %s %s %s %s comment \"generated for LXD network %s\"\n", ipFamily, ipTable, chain, generateNATRule(ipFamily, subnet, srcIP), name)
+
+	return apply(buf.String())
+}
+
+// NetworkSetupIPv4DNSOverrides sets up basic nftables overrides for DHCP/DNS
+func (nft NFTables) NetworkSetupIPv4DNSOverrides(name string) error {
+	return nft.networkSetupDNSOverrides("ip", name, "67")
+}
+
+// NetworkSetupIPv4DHCPWorkaround is a no-op as nftables has no equivalent to the CHECKSUM target.
+// The clients affected by the missing checksums are long obsolete.
+func (nft NFTables) NetworkSetupIPv4DHCPWorkaround(name string) error {
+	return nil
+}
+
+// NetworkSetupIPv6DNSOverrides sets up basic nftables overrides for DHCP/DNS
+func (nft NFTables) NetworkSetupIPv6DNSOverrides(name string) error {
+	return nft.networkSetupDNSOverrides("ip6", name, "547")
+}
+
+// NetworkSetupTunnelNAT configures tunnel NAT
+func (nft NFTables) NetworkSetupTunnelNAT(name string, location firewallConsts.Location, overlaySubnet net.IPNet) error {
+	chain := chainName("fan", name)
+
+	buf := &strings.Builder{}
+	addBaseChain(buf, "ip", chain, "nat", "postrouting", natPriority(location))
+	fmt.Fprintf(buf, "add rule ip %s %s %s comment \"generated for LXD network %s\"\n", ipTable, chain, generateNATRule("ip", overlaySubnet, nil), name)
+
+	return apply(buf.String())
+}
+
+// networkSetupDNSOverrides accepts DHCP and DNS traffic between the host and the network.
+func (nft NFTables) networkSetupDNSOverrides(ipFamily string, name string, dhcpPort string) error {
+	inChain := chainName("in", name)
+	outChain := chainName("out", name)
+
+	buf := &strings.Builder{}
+	addBaseChain(buf, ipFamily, inChain, "filter", "input", 0)
+	addBaseChain(buf, ipFamily, outChain, "filter", "output", 0)
+
+	for _, rule := range []string{"udp dport " + dhcpPort, "udp dport 53", "tcp dport 53"} {
+		fmt.Fprintf(buf, "add rule %s %s %s iifname \"%s\" %s accept comment \"generated for LXD network %s\"\n", ipFamily, ipTable, inChain, name, rule, name)
+	}
+
+	for _, rule := range []string{"udp sport " + dhcpPort, "udp sport 53", "tcp sport 53"} {
+		fmt.Fprintf(buf, "add rule %s %s %s oifname \"%s\" %s accept comment \"generated for LXD network %s\"\n", ipFamily, ipTable, outChain, name, rule, name)
+	}
+
+	return apply(buf.String())
+}
+
+// generateNATRule returns the rule body masquerading (or source NATing to srcIP when set) the traffic
+// leaving the subnet.
+func generateNATRule(ipFamily string, subnet net.IPNet, srcIP net.IP) string {
+	action := "masquerade"
+	if srcIP != nil {
+		action = fmt.Sprintf("snat to %s", srcIP.String())
+	}
+
+	return fmt.Sprintf("%s saddr %s %s daddr != %s %s", ipFamily, subnet.String(), ipFamily, subnet.String(), action)
+}
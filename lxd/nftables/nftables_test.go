@@ -0,0 +1,54 @@
+package nftables
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	firewallConsts "github.com/lxc/lxd/lxd/firewall/consts"
+)
+
+func TestGenerateFilterRules(t *testing.T) {
+	mac, _ := net.ParseMAC("00:16:3e:12:34:56")
+	m := deviceConfig.Device{
+		"security.mac_filtering":  "true",
+		"security.ipv4_filtering": "true",
+		"security.ipv6_filtering": "true",
+	}
+
+	ipv4 := net.ParseIP("10.0.0.2")
+	ipv6 := net.ParseIP("fd42::2")
+
+	input := generateFilterRules(m, mac, ipv4, ipv6, true)
+	assert.Equal(t, "ether saddr != 00:16:3e:12:34:56 drop", input[0])
+	assert.Contains(t, input, "arp saddr ip != 10.0.0.2 drop")
+	assert.Contains(t, input, "ip saddr 0.0.0.0 ip daddr 255.255.255.255 udp dport 67 accept")
+	assert.Contains(t, input, "icmpv6 type nd-neighbor-advert @th,64,128 != 0xfd420000000000000000000000000002 drop")
+	assert.Contains(t, input, "icmpv6 type nd-neighbor-advert @th,208,48 != 0x00163e123456 drop")
+
+	// Host bound DHCP is only allowed on input.
+	forward := generateFilterRules(m, mac, ipv4, ipv6, false)
+	assert.NotContains(t, forward, "ip saddr 0.0.0.0 ip daddr 255.255.255.255 udp dport 67 accept")
+	assert.Len(t, forward, len(input)-3)
+
+	// MAC filtering only.
+	rules := generateFilterRules(deviceConfig.Device{"security.mac_filtering": "true"}, mac, nil, nil, true)
+	assert.Len(t, rules, 1)
+}
+
+func TestChainName(t *testing.T) {
+	assert.Equal(t, "in_veth_a1", chainName("in", "veth-a1"))
+}
+
+func TestGenerateNATRule(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/24")
+	assert.Equal(t, "ip saddr 10.0.0.0/24 ip daddr != 10.0.0.0/24 masquerade", generateNATRule("ip", *subnet, nil))
+	assert.Equal(t, "ip saddr 10.0.0.0/24 ip daddr != 10.0.0.0/24 snat to 192.0.2.1", generateNATRule("ip", *subnet, net.ParseIP("192.0.2.1")))
+}
+
+func TestInstanceChains(t *testing.T) {
+	assert.Equal(t, []string{"proxy_prert_c1__proxy0_", "proxy_out_c1__proxy0_"}, instanceChains(firewallConsts.TableNat, "c1 (proxy0)"))
+	assert.Nil(t, instanceChains(firewallConsts.TableFilter, "c1 (proxy0)"))
+}
@@ -2,6 +2,7 @@ package node
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
@@ -32,9 +33,30 @@ func ConfigLoad(tx *db.NodeTx) (*Config, error) {
 }
 
 // HTTPSAddress returns the address and port this LXD node should expose its
-// API to, if any.
+// API to, if any. If several addresses are set, that's the first one.
 func (c *Config) HTTPSAddress() string {
-	return c.m.GetString("core.https_address")
+	addresses := c.HTTPSAddresses()
+	if len(addresses) == 0 {
+		return ""
+	}
+
+	return addresses[0]
+}
+
+// HTTPSAddresses returns all the addresses and ports this LXD node should
+// expose its API to.
+func (c *Config) HTTPSAddresses() []string {
+	addresses := []string{}
+	for _, address := range strings.Split(c.m.GetString("core.https_address"), ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+
+		addresses = append(addresses, address)
+	}
+
+	return addresses
 }
 
 // ClusterAddress returns the address and port this LXD node should use for
@@ -48,6 +70,16 @@ func (c *Config) DebugAddress() string {
 	return c.m.GetString("core.debug_address")
 }
 
+// BGPAddress returns the address and port the BGP server should listen on, if any.
+func (c *Config) BGPAddress() string {
+	return c.m.GetString("core.bgp_address")
+}
+
+// BGPRouterID returns the router ID the BGP server should use.
+func (c *Config) BGPRouterID() string {
+	return c.m.GetString("core.bgp_routerid")
+}
+
 // MAASMachine returns the MAAS machine this instance is associated with, if
 // any.
 func (c *Config) MAASMachine() string {
@@ -64,6 +96,12 @@ func (c *Config) StorageImagesVolume() string {
 	return c.m.GetString("storage.images_volume")
 }
 
+// StorageEncryptionKeyHook returns the command to call to create, get and delete the keys of
+// encrypted storage volumes, if any
+func (c *Config) StorageEncryptionKeyHook() string {
+	return c.m.GetString("storage.encryption_key_hook")
+}
+
 // Dump current configuration keys and their values. Keys with values matching
 // their defaults are omitted.
 func (c *Config) Dump() map[string]interface{} {
@@ -101,6 +139,22 @@ func HTTPSAddress(node *db.Node) (string, error) {
 	return config.HTTPSAddress(), nil
 }
 
+// HTTPSAddresses is a convenience for loading the node configuration and
+// returning all the addresses of core.https_address.
+func HTTPSAddresses(node *db.Node) ([]string, error) {
+	var config *Config
+	err := node.Transaction(func(tx *db.NodeTx) error {
+		var err error
+		config, err = ConfigLoad(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return config.HTTPSAddresses(), nil
+}
+
 // ClusterAddress is a convenience for loading the node configuration and
 // returning the value of cluster.https_address.
 func ClusterAddress(node *db.Node) (string, error) {
@@ -149,7 +203,7 @@ func (c *Config) update(values map[string]interface{}) (map[string]string, error
 
 // ConfigSchema defines available server configuration keys.
 var ConfigSchema = config.Schema{
-	// Network address for this LXD server
+	// Network addresses for this LXD server (comma separated)
 	"core.https_address": {},
 
 	// Network address for cluster communication
@@ -158,10 +212,17 @@ var ConfigSchema = config.Schema{
 	// Network address for the debug server
 	"core.debug_address": {},
 
+	// Network address and router ID for the BGP server
+	"core.bgp_address":  {},
+	"core.bgp_routerid": {},
+
 	// MAAS machine this LXD instance is associated with
 	"maas.machine": {},
 
 	// Storage volumes to store backups/images on
 	"storage.backups_volume": {},
 	"storage.images_volume":  {},
+
+	// Command managing the keys of encrypted storage volumes
+	"storage.encryption_key_hook": {},
 }
@@ -142,3 +142,27 @@ func TestClusterAddress(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "127.0.0.1:666", address)
 }
+
+// The core.https_address config key can list several addresses, the first one
+// being the main one.
+func TestHTTPSAddresses(t *testing.T) {
+	nodeDB, cleanup := db.NewTestNode(t)
+	defer cleanup()
+
+	err := nodeDB.Transaction(func(tx *db.NodeTx) error {
+		config, err := node.ConfigLoad(tx)
+		require.NoError(t, err)
+		_, err = config.Replace(map[string]interface{}{"core.https_address": "127.0.0.1:666, 10.0.0.1:8443"})
+		require.NoError(t, err)
+		return nil
+	})
+	require.NoError(t, err)
+
+	address, err := node.HTTPSAddress(nodeDB)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:666", address)
+
+	addresses, err := node.HTTPSAddresses(nodeDB)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:666", "10.0.0.1:8443"}, addresses)
+}
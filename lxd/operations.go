@@ -28,7 +28,7 @@ var operationCmd = APIEndpoint{
 var operationsCmd = APIEndpoint{
 	Path: "operations",
 
-	Get: APIEndpointAction{Handler: operationsGet, AccessHandler: AllowAuthenticated},
+	Get: APIEndpointAction{Handler: operationsGet, AccessHandler: AllowProjectAuthenticated},
 }
 
 var operationWait = APIEndpoint{
@@ -43,6 +43,13 @@ var operationWebsocket = APIEndpoint{
 	Get: APIEndpointAction{Handler: operationWebsocketGet, AllowUntrusted: true},
 }
 
+// operationAllowed returns whether the requestor may access the operation. Restricted clients only
+// get to see the operations of their projects.
+func operationAllowed(d *Daemon, r *http.Request, op *operations.Operation) bool {
+	restricted, projects := d.userRestricted(r)
+	return !restricted || shared.StringInSlice(op.Project(), projects)
+}
+
 // API functions
 func operationGet(d *Daemon, r *http.Request) response.Response {
 	id := mux.Vars(r)["id"]
@@ -52,6 +59,10 @@ func operationGet(d *Daemon, r *http.Request) response.Response {
 	// First check if the query is for a local operation from this node
 	op, err := operations.OperationGetInternal(id)
 	if err == nil {
+		if !operationAllowed(d, r, op) {
+			return response.NotFound(nil)
+		}
+
 		_, body, err = op.Render()
 		if err != nil {
 			return response.SmartError(err)
@@ -90,6 +101,10 @@ func operationDelete(d *Daemon, r *http.Request) response.Response {
 	// First check if the query is for a local operation from this node
 	op, err := operations.OperationGetInternal(id)
 	if err == nil {
+		if !operationAllowed(d, r, op) {
+			return response.NotFound(nil)
+		}
+
 		if op.Permission() != "" {
 			project := op.Project()
 			if project == "" {
@@ -150,6 +165,10 @@ func operationsGet(d *Daemon, r *http.Request) response.Response {
 			if v.Project() != "" && v.Project() != project {
 				continue
 			}
+
+			if !operationAllowed(d, r, v) {
+				continue
+			}
 			status := strings.ToLower(v.Status().String())
 			_, ok := body[status]
 			if !ok {
@@ -175,6 +194,10 @@ func operationsGet(d *Daemon, r *http.Request) response.Response {
 			if v.Project() != "" && v.Project() != project {
 				continue
 			}
+
+			if !operationAllowed(d, r, v) {
+				continue
+			}
 			status := strings.ToLower(v.Status().String())
 			_, ok := body[status]
 			if !ok {
@@ -242,7 +265,9 @@ func operationsGet(d *Daemon, r *http.Request) response.Response {
 	}
 
 	// Get all nodes with running operations in this project.
+	restricted, _ := d.userRestricted(r)
 	var nodes []string
+	var projectOps []string
 	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
 		var err error
 
@@ -251,6 +276,14 @@ func operationsGet(d *Daemon, r *http.Request) response.Response {
 			return err
 		}
 
+		// Restricted clients don't get to see the operations which aren't tied to a project.
+		if restricted {
+			projectOps, err = tx.OperationsUUIDsByProject(project)
+			if err != nil {
+				return err
+			}
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -283,6 +316,10 @@ func operationsGet(d *Daemon, r *http.Request) response.Response {
 
 		// Merge with existing data
 		for _, op := range ops {
+			if restricted && !shared.StringInSlice(op.ID, projectOps) {
+				continue
+			}
+
 			status := strings.ToLower(op.Status)
 
 			_, ok := md[status]
@@ -316,6 +353,10 @@ func operationWaitGet(d *Daemon, r *http.Request) response.Response {
 	// First check if the query is for a local operation from this node
 	op, err := operations.OperationGetInternal(id)
 	if err == nil {
+		if !operationAllowed(d, r, op) {
+			return response.NotFound(nil)
+		}
+
 		_, err = op.WaitFinal(timeout)
 		if err != nil {
 			return response.InternalError(err)
@@ -0,0 +1,168 @@
+package operations
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// instanceLock tracks the operation changing an instance along with the operations queued
+// behind it.
+type instanceLock struct {
+	holder *Operation
+	queue  []*instanceLockWaiter
+}
+
+type instanceLockWaiter struct {
+	op    *Operation
+	ready chan struct{}
+}
+
+var instanceLocksMutex sync.Mutex
+var instanceLocks = make(map[string]*instanceLock)
+
+// InstanceBusyError is returned when another operation is already running on an instance.
+type InstanceBusyError struct {
+	Project     string
+	Instance    string
+	Operation   string
+	Description string
+}
+
+// Error returns the error message including the blocking operation.
+func (e *InstanceBusyError) Error() string {
+	return fmt.Sprintf("Instance %q is busy running operation %s (%s)", e.Instance, e.Operation, e.Description)
+}
+
+// Response returns a conflict response (409) with the blocking operation in its metadata.
+func (e *InstanceBusyError) Response() response.Response {
+	metadata := map[string]string{
+		"operation":   fmt.Sprintf("/%s/operations/%s", version.APIVersion, e.Operation),
+		"description": e.Description,
+	}
+
+	return response.ErrorResponseWithMetadata(http.StatusConflict, api.ErrorTypeInstanceBusy, e.Error(), metadata)
+}
+
+func instanceLockKey(project string, name string) string {
+	return fmt.Sprintf("%s/%s", project, name)
+}
+
+// busyError returns the error reported to the operations conflicting with the lock.
+// The caller must hold instanceLocksMutex.
+func (l *instanceLock) busyError(project string, name string) *InstanceBusyError {
+	return &InstanceBusyError{
+		Project:     project,
+		Instance:    name,
+		Operation:   l.holder.id,
+		Description: l.holder.description,
+	}
+}
+
+// InstanceBusy returns an InstanceBusyError if an operation holds the lock of the instance.
+// Requests which shouldn't queue use it to fail before creating their operation.
+func InstanceBusy(project string, name string) *InstanceBusyError {
+	instanceLocksMutex.Lock()
+	defer instanceLocksMutex.Unlock()
+
+	lock := instanceLocks[instanceLockKey(project, name)]
+	if lock == nil {
+		return nil
+	}
+
+	return lock.busyError(project, name)
+}
+
+// LockInstance takes the lock of the instance for the operation, failing with an InstanceBusyError
+// if another operation holds it or is queued for it. The lock is released when the operation is
+// done.
+func (op *Operation) LockInstance(project string, name string) error {
+	instanceLocksMutex.Lock()
+	defer instanceLocksMutex.Unlock()
+
+	key := instanceLockKey(project, name)
+	lock := instanceLocks[key]
+	if lock == nil {
+		instanceLocks[key] = &instanceLock{holder: op}
+		return nil
+	}
+
+	if lock.holder == op {
+		return nil
+	}
+
+	return lock.busyError(project, name)
+}
+
+// WaitInstanceLock takes the lock of the instance for the operation, waiting for the operations
+// holding it or queued before this one to be done first. The lock is released when the operation
+// is done.
+func (op *Operation) WaitInstanceLock(project string, name string) error {
+	instanceLocksMutex.Lock()
+
+	key := instanceLockKey(project, name)
+	lock := instanceLocks[key]
+	if lock == nil {
+		instanceLocks[key] = &instanceLock{holder: op}
+		instanceLocksMutex.Unlock()
+		return nil
+	}
+
+	if lock.holder == op {
+		instanceLocksMutex.Unlock()
+		return nil
+	}
+
+	waiter := &instanceLockWaiter{op: op, ready: make(chan struct{})}
+	lock.queue = append(lock.queue, waiter)
+	holder := lock.holder.id
+	instanceLocksMutex.Unlock()
+
+	op.setWaitingFor(fmt.Sprintf("/%s/operations/%s", version.APIVersion, holder))
+	<-waiter.ready
+	op.setWaitingFor("")
+
+	return nil
+}
+
+// setWaitingFor records the operation this one is queued behind in its metadata.
+func (op *Operation) setWaitingFor(url string) {
+	metadata := map[string]interface{}{}
+	for k, v := range op.Metadata() {
+		metadata[k] = v
+	}
+
+	if url == "" {
+		delete(metadata, "waiting_for")
+	} else {
+		metadata["waiting_for"] = url
+	}
+
+	op.UpdateMetadata(metadata)
+}
+
+// releaseInstanceLocks hands the locks held by the operation over to the next queued operations.
+func releaseInstanceLocks(op *Operation) {
+	instanceLocksMutex.Lock()
+	defer instanceLocksMutex.Unlock()
+
+	for key, lock := range instanceLocks {
+		if lock.holder != op {
+			continue
+		}
+
+		if len(lock.queue) == 0 {
+			delete(instanceLocks, key)
+			continue
+		}
+
+		next := lock.queue[0]
+		lock.queue = lock.queue[1:]
+		lock.holder = next.op
+		close(next.ready)
+	}
+}
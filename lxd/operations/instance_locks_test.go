@@ -0,0 +1,81 @@
+package operations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/db"
+)
+
+func newTestOperation(t *testing.T) *Operation {
+	op, err := OperationCreate(nil, "default", OperationClassTask, db.OperationSnapshotCreate, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	return op
+}
+
+func TestLockInstance(t *testing.T) {
+	op1 := newTestOperation(t)
+	op2 := newTestOperation(t)
+
+	require.NoError(t, op1.LockInstance("default", "c1"))
+	require.NoError(t, op1.LockInstance("default", "c1"))
+
+	busy := InstanceBusy("default", "c1")
+	require.NotNil(t, busy)
+	assert.Equal(t, op1.ID(), busy.Operation)
+	assert.Nil(t, InstanceBusy("default", "c2"))
+	assert.Nil(t, InstanceBusy("other", "c1"))
+
+	err := op2.LockInstance("default", "c1")
+	require.IsType(t, &InstanceBusyError{}, err)
+	assert.Equal(t, op1.ID(), err.(*InstanceBusyError).Operation)
+
+	op1.done()
+	assert.Nil(t, InstanceBusy("default", "c1"))
+
+	require.NoError(t, op2.LockInstance("default", "c1"))
+	op2.done()
+	assert.Nil(t, InstanceBusy("default", "c1"))
+}
+
+func TestWaitInstanceLock(t *testing.T) {
+	op1 := newTestOperation(t)
+	op2 := newTestOperation(t)
+	op3 := newTestOperation(t)
+
+	require.NoError(t, op1.WaitInstanceLock("default", "c1"))
+
+	locked := make(chan *Operation, 2)
+	go func() {
+		op2.WaitInstanceLock("default", "c1")
+		locked <- op2
+	}()
+
+	// Give op2 time to queue before op3.
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, "/1.0/operations/"+op1.ID(), op2.Metadata()["waiting_for"])
+
+	go func() {
+		op3.WaitInstanceLock("default", "c1")
+		locked <- op3
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, locked, 0)
+
+	// Queued operations are handed the lock in order.
+	op1.done()
+	assert.Equal(t, op2, <-locked)
+	assert.Equal(t, op2.ID(), InstanceBusy("default", "c1").Operation)
+	assert.NotContains(t, op2.Metadata(), "waiting_for")
+
+	op2.done()
+	assert.Equal(t, op3, <-locked)
+
+	op3.done()
+	assert.Nil(t, InstanceBusy("default", "c1"))
+}
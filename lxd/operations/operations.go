@@ -82,6 +82,7 @@ type Operation struct {
 	project     string
 	id          string
 	class       operationClass
+	opType      db.OperationType
 	createdAt   time.Time
 	updatedAt   time.Time
 	status      api.StatusCode
@@ -119,6 +120,7 @@ func OperationCreate(s *state.State, project string, opClass operationClass, opT
 	op.description = opType.Description()
 	op.permission = opType.Permission()
 	op.class = opClass
+	op.opType = opType
 	op.createdAt = time.Now()
 	op.updatedAt = op.createdAt
 	op.status = api.Pending
@@ -155,10 +157,6 @@ func OperationCreate(s *state.State, project string, opClass operationClass, opT
 		return nil, fmt.Errorf("Token operations can't have a Run hook")
 	}
 
-	if op.class == OperationClassToken && op.onCancel != nil {
-		return nil, fmt.Errorf("Token operations can't have a Cancel hook")
-	}
-
 	operationsLock.Lock()
 	operations[op.id] = &op
 	operationsLock.Unlock()
@@ -193,6 +191,8 @@ func (op *Operation) done() {
 	close(op.chanDone)
 	op.lock.Unlock()
 
+	releaseInstanceLocks(op)
+
 	time.AfterFunc(time.Second*5, func() {
 		operationsLock.Lock()
 		_, ok := operations[op.id]
@@ -512,6 +512,11 @@ func (op *Operation) ID() string {
 	return op.id
 }
 
+// Type returns the operation type.
+func (op *Operation) Type() db.OperationType {
+	return op.opType
+}
+
 // Metadata returns the operation Metadata.
 func (op *Operation) Metadata() map[string]interface{} {
 	return op.metadata
@@ -13,6 +13,7 @@ import (
 
 	lxd "github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance"
@@ -91,6 +92,8 @@ func profilesPost(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	configTranslateKeys(config.EntityInstance, req.Config)
+
 	// Sanity checks
 	if req.Name == "" {
 		return response.BadRequest(fmt.Errorf("No name provided"))
@@ -252,6 +255,8 @@ func profilePut(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	configTranslateKeys(config.EntityInstance, req.Config)
+
 	err = doProfileUpdate(d, project, name, id, profile, req)
 
 	if err == nil && !isClusterNotification(r) {
@@ -331,6 +336,8 @@ func profilePatch(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	configTranslateKeys(config.EntityInstance, req.Config)
+
 	// Get Description
 	_, err = reqRaw.GetString("description")
 	if err != nil {
@@ -331,5 +331,8 @@ func GetCPU() (*api.ResourcesCPU, error) {
 
 	cpu.Architecture = strings.TrimRight(string(uname.Machine[:]), "\x00")
 
+	// Get AMD SEV support (only reported when the kvm_amd module is loaded)
+	cpu.SEV = GetSEV()
+
 	return &cpu, nil
 }
@@ -0,0 +1,64 @@
+package resources
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+var sysModuleKVMAMD = "/sys/module/kvm_amd/parameters"
+
+// sevCPUIDLeaf is the CPUID leaf holding the AMD memory encryption capabilities.
+const sevCPUIDLeaf = 0x8000001f
+
+func sysfsBoolParameter(path string) bool {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	value := strings.TrimSpace(string(content))
+	return value == "Y" || value == "1"
+}
+
+// GetSEV returns the AMD SEV capabilities of the system or nil if the kvm_amd
+// module isn't loaded.
+func GetSEV() *api.ResourcesCPUSEV {
+	if !sysfsExists(sysModuleKVMAMD) {
+		return nil
+	}
+
+	sev := api.ResourcesCPUSEV{}
+	sev.Supported = sysfsBoolParameter(filepath.Join(sysModuleKVMAMD, "sev"))
+	if !sev.Supported {
+		return &sev
+	}
+
+	sev.ES = sysfsBoolParameter(filepath.Join(sysModuleKVMAMD, "sev_es"))
+	sev.SNP = sysfsBoolParameter(filepath.Join(sysModuleKVMAMD, "sev_snp"))
+
+	// The C-bit position and the physical address bit reduction are needed to
+	// launch guests. They're exposed through CPUID which we read through the
+	// cpuid driver (EBX bits 0-5 and 6-11 respectively).
+	f, err := os.Open("/dev/cpu/0/cpuid")
+	if err != nil {
+		return &sev
+	}
+	defer f.Close()
+
+	regs := make([]byte, 16)
+	_, err = f.ReadAt(regs, sevCPUIDLeaf)
+	if err != nil {
+		return &sev
+	}
+
+	ebx := binary.LittleEndian.Uint32(regs[4:8])
+	sev.CBitPosition = uint64(ebx & 0x3f)
+	sev.ReducedPhysBits = uint64((ebx >> 6) & 0x3f)
+
+	return &sev
+}
@@ -309,6 +309,63 @@ func gpuAddDeviceInfo(devicePath string, nvidiaCards map[string]*api.ResourcesGP
 		card.DRM = &drm
 	}
 
+	// Mediated device types
+	mdevPath := filepath.Join(devicePath, "mdev_supported_types")
+	if sysfsExists(mdevPath) {
+		card.Mdev = map[string]api.ResourcesGPUCardMdev{}
+
+		// List all the types
+		entries, err := ioutil.ReadDir(mdevPath)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to list \"%s\"", mdevPath)
+		}
+
+		for _, entry := range entries {
+			entryName := entry.Name()
+			entryPath := filepath.Join(mdevPath, entryName)
+
+			mdev := api.ResourcesGPUCardMdev{}
+
+			// API
+			mdev.API, err = readString(filepath.Join(entryPath, "device_api"))
+			if err != nil {
+				return errors.Wrapf(err, "Failed to read \"%s\"", filepath.Join(entryPath, "device_api"))
+			}
+
+			// Available instances
+			mdev.Available, err = readUint(filepath.Join(entryPath, "available_instances"))
+			if err != nil {
+				return errors.Wrapf(err, "Failed to read \"%s\"", filepath.Join(entryPath, "available_instances"))
+			}
+
+			// Name and description are optional
+			if sysfsExists(filepath.Join(entryPath, "name")) {
+				mdev.Name, err = readString(filepath.Join(entryPath, "name"))
+				if err != nil {
+					return errors.Wrapf(err, "Failed to read \"%s\"", filepath.Join(entryPath, "name"))
+				}
+			}
+
+			if sysfsExists(filepath.Join(entryPath, "description")) {
+				mdev.Description, err = readString(filepath.Join(entryPath, "description"))
+				if err != nil {
+					return errors.Wrapf(err, "Failed to read \"%s\"", filepath.Join(entryPath, "description"))
+				}
+			}
+
+			// Existing devices of that type
+			mdev.Devices = []string{}
+			devices, err := ioutil.ReadDir(filepath.Join(entryPath, "devices"))
+			if err == nil {
+				for _, device := range devices {
+					mdev.Devices = append(mdev.Devices, device.Name())
+				}
+			}
+
+			card.Mdev[entryName] = mdev
+		}
+	}
+
 	return nil
 }
 
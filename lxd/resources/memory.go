@@ -194,6 +194,24 @@ func GetMemory() (*api.ResourcesMemory, error) {
 			node.Used = info.Used
 			node.Total = info.Total
 
+			// Get the distances to the other nodes
+			distancePath := filepath.Join(entryPath, "distance")
+			if sysfsExists(distancePath) {
+				content, err := ioutil.ReadFile(distancePath)
+				if err != nil {
+					return nil, errors.Wrapf(err, "Failed to read \"%s\"", distancePath)
+				}
+
+				for _, field := range strings.Fields(string(content)) {
+					distance, err := strconv.ParseUint(field, 10, 64)
+					if err != nil {
+						return nil, errors.Wrap(err, "Failed to parse NUMA distance")
+					}
+
+					node.Distances = append(node.Distances, distance)
+				}
+			}
+
 			memory.Nodes = append(memory.Nodes, node)
 		}
 	}
@@ -0,0 +1,92 @@
+package resources
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParseCpuset parses a cpuset style list (e.g. "0-3,6") of CPU or NUMA node IDs.
+func ParseCpuset(cpu string) ([]int, error) {
+	cpus := []int{}
+	chunks := strings.Split(cpu, ",")
+	for _, chunk := range chunks {
+		if strings.Contains(chunk, "-") {
+			// Range
+			fields := strings.SplitN(chunk, "-", 2)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
+			}
+
+			low, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
+			}
+
+			high, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
+			}
+
+			for i := low; i <= high; i++ {
+				cpus = append(cpus, i)
+			}
+		} else {
+			// Simple entry
+			nr, err := strconv.Atoi(chunk)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid cpuset value: %s", cpu)
+			}
+			cpus = append(cpus, nr)
+		}
+	}
+	return cpus, nil
+}
+
+// GetNUMANodeCPUs returns the IDs of the CPU threads on a NUMA node.
+func GetNUMANodeCPUs(node int) ([]int, error) {
+	cpuList := filepath.Join(sysDevicesNode, fmt.Sprintf("node%d", node), "cpulist")
+	if !sysfsExists(cpuList) {
+		// Single-node systems may not expose their NUMA layout.
+		if node == 0 && !sysfsExists(sysDevicesNode) {
+			content, err := ioutil.ReadFile(filepath.Join(sysDevicesCPU, "online"))
+			if err != nil {
+				return nil, err
+			}
+
+			return ParseCpuset(strings.TrimSpace(string(content)))
+		}
+
+		return nil, fmt.Errorf("Unknown NUMA node %d", node)
+	}
+
+	content, err := ioutil.ReadFile(cpuList)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read \"%s\"", cpuList)
+	}
+
+	if strings.TrimSpace(string(content)) == "" {
+		return []int{}, nil
+	}
+
+	return ParseCpuset(strings.TrimSpace(string(content)))
+}
+
+// GetCPUNUMANode returns the NUMA node a CPU thread belongs to.
+func GetCPUNUMANode(cpu int) (int, error) {
+	cpuPath := filepath.Join(sysDevicesCPU, fmt.Sprintf("cpu%d", cpu))
+	if !sysfsExists(cpuPath) {
+		return -1, fmt.Errorf("Unknown CPU %d", cpu)
+	}
+
+	node, err := sysfsNumaNode(cpuPath)
+	if err != nil {
+		return -1, errors.Wrap(err, "Failed to find NUMA node")
+	}
+
+	return int(node), nil
+}
@@ -0,0 +1,162 @@
+package resources
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jaypipes/pcidb"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func pciAddDeviceInfo(devicePath string, pciDB *pcidb.PCIDB, uname unix.Utsname, device *api.ResourcesPCIDevice) error {
+	// NUMA node
+	if sysfsExists(filepath.Join(devicePath, "numa_node")) {
+		numaNode, err := readInt(filepath.Join(devicePath, "numa_node"))
+		if err != nil {
+			return errors.Wrapf(err, "Failed to read \"%s\"", filepath.Join(devicePath, "numa_node"))
+		}
+
+		if numaNode > 0 {
+			device.NUMANode = uint64(numaNode)
+		}
+	}
+
+	// Device class
+	deviceClassPath := filepath.Join(devicePath, "class")
+	if sysfsExists(deviceClassPath) {
+		class, err := ioutil.ReadFile(deviceClassPath)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to read \"%s\"", deviceClassPath)
+		}
+
+		device.Class = strings.TrimPrefix(strings.TrimSpace(string(class)), "0x")
+	}
+
+	// Vendor and product
+	deviceVendorPath := filepath.Join(devicePath, "vendor")
+	if sysfsExists(deviceVendorPath) {
+		id, err := ioutil.ReadFile(deviceVendorPath)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to read \"%s\"", deviceVendorPath)
+		}
+
+		device.VendorID = strings.TrimPrefix(strings.TrimSpace(string(id)), "0x")
+	}
+
+	deviceDevicePath := filepath.Join(devicePath, "device")
+	if sysfsExists(deviceDevicePath) {
+		id, err := ioutil.ReadFile(deviceDevicePath)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to read \"%s\"", deviceDevicePath)
+		}
+
+		device.ProductID = strings.TrimPrefix(strings.TrimSpace(string(id)), "0x")
+	}
+
+	// Fill vendor and product names
+	if pciDB != nil {
+		vendor, ok := pciDB.Vendors[device.VendorID]
+		if ok {
+			device.Vendor = vendor.Name
+
+			for _, product := range vendor.Products {
+				if product.ID == device.ProductID {
+					device.Product = product.Name
+					break
+				}
+			}
+		}
+	}
+
+	// Driver information
+	driverPath := filepath.Join(devicePath, "driver")
+	if sysfsExists(driverPath) {
+		linkTarget, err := filepath.EvalSymlinks(driverPath)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to track down \"%s\"", driverPath)
+		}
+
+		// Set the driver name
+		device.Driver = filepath.Base(linkTarget)
+
+		// Try to get the version, fallback to kernel version
+		out, err := ioutil.ReadFile(filepath.Join(driverPath, "module", "version"))
+		if err == nil {
+			device.DriverVersion = strings.TrimSpace(string(out))
+		} else {
+			device.DriverVersion = strings.TrimRight(string(uname.Release[:]), "\x00")
+		}
+	}
+
+	// IOMMU group
+	iommuGroupPath := filepath.Join(devicePath, "iommu_group")
+	if sysfsExists(iommuGroupPath) {
+		linkTarget, err := filepath.EvalSymlinks(iommuGroupPath)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to track down \"%s\"", iommuGroupPath)
+		}
+
+		iommuGroup, err := strconv.ParseUint(filepath.Base(linkTarget), 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "Failed to parse IOMMU group")
+		}
+
+		device.IOMMUGroup = &iommuGroup
+	}
+
+	return nil
+}
+
+// GetPCI returns a filled api.ResourcesPCI struct ready for use by LXD
+func GetPCI() (*api.ResourcesPCI, error) {
+	pci := api.ResourcesPCI{}
+	pci.Devices = []api.ResourcesPCIDevice{}
+
+	if !sysfsExists(sysBusPci) {
+		return &pci, nil
+	}
+
+	// Get uname for driver version
+	uname := unix.Utsname{}
+	err := unix.Uname(&uname)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get uname")
+	}
+
+	// Load PCI database
+	pciDB, err := pcidb.New()
+	if err != nil {
+		pciDB = nil
+	}
+
+	entries, err := ioutil.ReadDir(sysBusPci)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to list \"%s\"", sysBusPci)
+	}
+
+	// Iterate and add to our list
+	for _, entry := range entries {
+		entryName := entry.Name()
+		devicePath := filepath.Join(sysBusPci, entryName)
+
+		device := api.ResourcesPCIDevice{}
+		device.PCIAddress = entryName
+
+		// Add device information
+		err = pciAddDeviceInfo(devicePath, pciDB, uname, &device)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to add device information for \"%s\"", devicePath)
+		}
+
+		pci.Devices = append(pci.Devices, device)
+	}
+
+	pci.Total = uint64(len(pci.Devices))
+
+	return &pci, nil
+}
@@ -38,6 +38,18 @@ func GetResources() (*api.Resources, error) {
 		return nil, errors.Wrap(err, "Failed to retrieve storage information")
 	}
 
+	// Get USB information
+	usb, err := GetUSB()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to retrieve USB information")
+	}
+
+	// Get PCI information
+	pci, err := GetPCI()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to retrieve PCI information")
+	}
+
 	// Build the final struct
 	resources := api.Resources{
 		CPU:     *cpu,
@@ -45,6 +57,8 @@ func GetResources() (*api.Resources, error) {
 		GPU:     *gpu,
 		Network: *network,
 		Storage: *storage,
+		USB:     *usb,
+		PCI:     *pci,
 	}
 
 	return &resources, nil
@@ -0,0 +1,112 @@
+package resources
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+var sysBusUsb = "/sys/bus/usb/devices"
+
+// GetUSB returns a filled api.ResourcesUSB struct ready for use by LXD
+func GetUSB() (*api.ResourcesUSB, error) {
+	usb := api.ResourcesUSB{}
+	usb.Devices = []api.ResourcesUSBDevice{}
+
+	if !sysfsExists(sysBusUsb) {
+		return &usb, nil
+	}
+
+	entries, err := ioutil.ReadDir(sysBusUsb)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to list \"%s\"", sysBusUsb)
+	}
+
+	// Iterate and add to our list
+	for _, entry := range entries {
+		entryName := entry.Name()
+		devicePath := filepath.Join(sysBusUsb, entryName)
+
+		// Skip interfaces and the root hubs
+		if strings.Contains(entryName, ":") || strings.HasPrefix(entryName, "usb") {
+			continue
+		}
+
+		// Only care about devices we can identify
+		if !sysfsExists(filepath.Join(devicePath, "idVendor")) {
+			continue
+		}
+
+		device := api.ResourcesUSBDevice{}
+
+		// Bus and device addresses
+		busAddress, err := readUint(filepath.Join(devicePath, "busnum"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read \"%s\"", filepath.Join(devicePath, "busnum"))
+		}
+
+		deviceAddress, err := readUint(filepath.Join(devicePath, "devnum"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read \"%s\"", filepath.Join(devicePath, "devnum"))
+		}
+
+		device.BusAddress = busAddress
+		device.DeviceAddress = deviceAddress
+
+		// Vendor and product
+		device.VendorID, err = readString(filepath.Join(devicePath, "idVendor"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read \"%s\"", filepath.Join(devicePath, "idVendor"))
+		}
+
+		device.ProductID, err = readString(filepath.Join(devicePath, "idProduct"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read \"%s\"", filepath.Join(devicePath, "idProduct"))
+		}
+
+		// The strings are optional and only set if the device provides them
+		for file, value := range map[string]*string{"manufacturer": &device.Vendor, "product": &device.Product, "serial": &device.Serial} {
+			if !sysfsExists(filepath.Join(devicePath, file)) {
+				continue
+			}
+
+			*value, err = readString(filepath.Join(devicePath, file))
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to read \"%s\"", filepath.Join(devicePath, file))
+			}
+		}
+
+		// Speed
+		if sysfsExists(filepath.Join(devicePath, "speed")) {
+			content, err := readString(filepath.Join(devicePath, "speed"))
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to read \"%s\"", filepath.Join(devicePath, "speed"))
+			}
+
+			device.Speed, err = strconv.ParseFloat(content, 64)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to parse USB device speed")
+			}
+		}
+
+		// Driver of the first interface
+		interfaces, err := filepath.Glob(filepath.Join(devicePath, entryName+":*", "driver"))
+		if err == nil && len(interfaces) > 0 {
+			linkTarget, err := filepath.EvalSymlinks(interfaces[0])
+			if err == nil {
+				device.Driver = filepath.Base(linkTarget)
+			}
+		}
+
+		usb.Devices = append(usb.Devices, device)
+	}
+
+	usb.Total = uint64(len(usb.Devices))
+
+	return &usb, nil
+}
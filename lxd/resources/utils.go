@@ -38,6 +38,15 @@ func readInt(path string) (int64, error) {
 	return value, nil
 }
 
+func readString(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
 func stringInSlice(key string, list []string) bool {
 	for _, entry := range list {
 		if entry == key {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"os"
 	"time"
@@ -119,18 +120,32 @@ func (r *syncResponse) String() string {
 
 // Error response
 type errorResponse struct {
-	code int
-	msg  string
+	code      int
+	msg       string
+	metadata  interface{}
+	errorType api.ErrorType
+}
+
+// newErrorResponse returns an error response with the given code and msg, keeping the type of err
+// if it's an API error.
+func newErrorResponse(code int, msg string, err error) *errorResponse {
+	return &errorResponse{code: code, msg: msg, errorType: api.ErrorTypeOf(err)}
 }
 
 // ErrorResponse returns an error response with the given code and msg.
 func ErrorResponse(code int, msg string) Response {
-	return &errorResponse{code, msg}
+	return &errorResponse{code: code, msg: msg}
+}
+
+// ErrorResponseWithMetadata returns an error response with the given code, type and msg, along with
+// metadata describing the error.
+func ErrorResponseWithMetadata(code int, errorType api.ErrorType, msg string, metadata interface{}) Response {
+	return &errorResponse{code: code, msg: msg, metadata: metadata, errorType: errorType}
 }
 
 // BadRequest returns a bad request response (400) with the given error.
 func BadRequest(err error) Response {
-	return &errorResponse{http.StatusBadRequest, err.Error()}
+	return newErrorResponse(http.StatusBadRequest, err.Error(), err)
 }
 
 // Conflict returns a conflict response (409) with the given error.
@@ -140,7 +155,7 @@ func Conflict(err error) Response {
 		message = err.Error()
 	}
 
-	return &errorResponse{http.StatusConflict, message}
+	return newErrorResponse(http.StatusConflict, message, err)
 }
 
 // Forbidden returns a forbidden response (403) with the given error.
@@ -150,12 +165,12 @@ func Forbidden(err error) Response {
 		message = err.Error()
 	}
 
-	return &errorResponse{http.StatusForbidden, message}
+	return newErrorResponse(http.StatusForbidden, message, err)
 }
 
 // InternalError returns an internal error response (500) with the given error.
 func InternalError(err error) Response {
-	return &errorResponse{http.StatusInternalServerError, err.Error()}
+	return newErrorResponse(http.StatusInternalServerError, err.Error(), err)
 }
 
 // NotFound returns a not found response (404) with the given error.
@@ -165,7 +180,7 @@ func NotFound(err error) Response {
 		message = err.Error()
 	}
 
-	return &errorResponse{http.StatusNotFound, message}
+	return newErrorResponse(http.StatusNotFound, message, err)
 }
 
 // NotImplemented returns a not implemented response (501) with the given error.
@@ -175,13 +190,13 @@ func NotImplemented(err error) Response {
 		message = err.Error()
 	}
 
-	return &errorResponse{http.StatusNotImplemented, message}
+	return newErrorResponse(http.StatusNotImplemented, message, err)
 }
 
 // PreconditionFailed returns a precondition failed response (412) with the
 // given error.
 func PreconditionFailed(err error) Response {
-	return &errorResponse{http.StatusPreconditionFailed, err.Error()}
+	return newErrorResponse(http.StatusPreconditionFailed, err.Error(), err)
 }
 
 // Unavailable return an unavailable response (503) with the given error.
@@ -191,7 +206,7 @@ func Unavailable(err error) Response {
 		message = err.Error()
 	}
 
-	return &errorResponse{http.StatusServiceUnavailable, message}
+	return newErrorResponse(http.StatusServiceUnavailable, message, err)
 }
 
 func (r *errorResponse) String() string {
@@ -209,7 +224,12 @@ func (r *errorResponse) Render(w http.ResponseWriter) error {
 		output = io.MultiWriter(buf, captured)
 	}
 
-	err := json.NewEncoder(output).Encode(shared.Jmap{"type": api.ErrorResponse, "error": r.msg, "error_code": r.code})
+	resp := shared.Jmap{"type": api.ErrorResponse, "error": r.msg, "error_code": r.code, "error_type": r.errorType}
+	if r.metadata != nil {
+		resp["metadata"] = r.metadata
+	}
+
+	err := json.NewEncoder(output).Encode(resp)
 
 	if err != nil {
 		return err
@@ -342,6 +362,104 @@ func (r *fileResponse) String() string {
 	return fmt.Sprintf("%d files", len(r.files))
 }
 
+// Stream response
+type streamResponse struct {
+	headers map[string]string
+	hook    func(w io.Writer) error
+}
+
+// StreamResponse returns a new response streaming the content written by hook, sent with the
+// provided headers. Errors happening before anything was written are rendered as error responses,
+// later errors abort the connection as the client has already been told about a success.
+func StreamResponse(headers map[string]string, hook func(w io.Writer) error) Response {
+	return &streamResponse{headers: headers, hook: hook}
+}
+
+func (r *streamResponse) Render(w http.ResponseWriter) error {
+	sw := &streamWriter{w: w, headers: r.headers}
+
+	err := r.hook(sw)
+	if err != nil {
+		if !sw.started {
+			return SmartError(err).Render(w)
+		}
+
+		panic(http.ErrAbortHandler)
+	}
+
+	// Send the headers if nothing was written.
+	if !sw.started {
+		sw.start()
+	}
+
+	return nil
+}
+
+func (r *streamResponse) String() string {
+	return "stream"
+}
+
+// streamWriter sends the headers of a stream response on the first write.
+type streamWriter struct {
+	w       http.ResponseWriter
+	headers map[string]string
+	started bool
+}
+
+func (sw *streamWriter) start() {
+	for k, v := range sw.headers {
+		sw.w.Header().Set(k, v)
+	}
+
+	sw.w.WriteHeader(http.StatusOK)
+	sw.started = true
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if !sw.started {
+		sw.start()
+	}
+
+	return sw.w.Write(p)
+}
+
+type upgradeResponse struct {
+	protocol string
+	hook     func(conn net.Conn)
+}
+
+// UpgradeResponse takes over the connection of the request, switches it to the protocol and
+// hands it over to hook, which is responsible for closing it. Clients must wait for the switch
+// before sending data over the connection.
+func UpgradeResponse(protocol string, hook func(conn net.Conn)) Response {
+	return &upgradeResponse{protocol: protocol, hook: hook}
+}
+
+func (r *upgradeResponse) Render(w http.ResponseWriter) error {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return fmt.Errorf("Webserver doesn't support hijacking")
+	}
+
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		return fmt.Errorf("Failed to hijack connection: %v", err)
+	}
+
+	_, err = fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: %s\r\nConnection: Upgrade\r\n\r\n", r.protocol)
+	if err != nil {
+		conn.Close()
+		return nil
+	}
+
+	r.hook(conn)
+	return nil
+}
+
+func (r *upgradeResponse) String() string {
+	return fmt.Sprintf("upgrade to %s", r.protocol)
+}
+
 type forwardedResponse struct {
 	client  lxd.InstanceServer
 	request *http.Request
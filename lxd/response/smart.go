@@ -9,6 +9,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
 )
 
 // SmartError returns the right error message based on err.
@@ -17,6 +18,12 @@ func SmartError(err error) Response {
 		return EmptySyncResponse
 	}
 
+	// API errors come with their own status code.
+	apiErr, ok := errors.Cause(err).(*api.TypedError)
+	if ok {
+		return newErrorResponse(apiErr.Code, err.Error(), err)
+	}
+
 	switch errors.Cause(err) {
 	case os.ErrNotExist, sql.ErrNoRows, db.ErrNoSuchObject:
 		if errors.Cause(err) != err {
@@ -4,10 +4,13 @@ package response
 
 import (
 	"database/sql"
+	"net/http"
 	"os"
+	"syscall"
 
 	"github.com/canonical/go-dqlite/driver"
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/mattn/go-sqlite3"
 	"github.com/pkg/errors"
 )
@@ -18,6 +21,17 @@ func SmartError(err error) Response {
 		return EmptySyncResponse
 	}
 
+	// API errors come with their own status code.
+	apiErr, ok := errors.Cause(err).(*api.TypedError)
+	if ok {
+		return newErrorResponse(apiErr.Code, err.Error(), err)
+	}
+
+	// Running out of space on the filesystem or of quota.
+	if errnoCause(err) == syscall.ENOSPC || errnoCause(err) == syscall.EDQUOT {
+		return &errorResponse{code: http.StatusInternalServerError, msg: err.Error(), errorType: api.ErrorTypeQuotaExceeded}
+	}
+
 	switch errors.Cause(err) {
 	case os.ErrNotExist, sql.ErrNoRows, db.ErrNoSuchObject:
 		if errors.Cause(err) != err {
@@ -43,3 +57,22 @@ func SmartError(err error) Response {
 		return InternalError(err)
 	}
 }
+
+// errnoCause returns the system error at the origin of err, if any.
+func errnoCause(err error) syscall.Errno {
+	switch cause := errors.Cause(err).(type) {
+	case syscall.Errno:
+		return cause
+	case *os.PathError:
+		errno, _ := cause.Err.(syscall.Errno)
+		return errno
+	case *os.LinkError:
+		errno, _ := cause.Err.(syscall.Errno)
+		return errno
+	case *os.SyscallError:
+		errno, _ := cause.Err.(syscall.Errno)
+		return errno
+	}
+
+	return 0
+}
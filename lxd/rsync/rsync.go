@@ -74,7 +74,7 @@ func LocalCopy(source string, dest string, bwlimit string, xattrs bool) (string,
 	return msg, nil
 }
 
-func sendSetup(name string, path string, bwlimit string, execPath string, features []string) (*exec.Cmd, net.Conn, io.ReadCloser, error) {
+func sendSetup(name string, path string, bwlimit string, execPath string, features []string, exclude []string) (*exec.Cmd, net.Conn, io.ReadCloser, error) {
 	/*
 	 * The way rsync works, it invokes a subprocess that does the actual
 	 * talking (given to it by a -E argument). Since there isn't an easy
@@ -129,6 +129,10 @@ func sendSetup(name string, path string, bwlimit string, execPath string, featur
 		args = append(args, rsyncFeatureArgs(features)...)
 	}
 
+	for _, pattern := range exclude {
+		args = append(args, fmt.Sprintf("--exclude=%s", pattern))
+	}
+
 	args = append(args, []string{
 		path,
 		"localhost:/tmp/foo",
@@ -181,9 +185,10 @@ func sendSetup(name string, path string, bwlimit string, execPath string, featur
 }
 
 // Send sets up the sending half of an rsync, to recursively send the
-// directory pointed to by path over the websocket.
-func Send(name string, path string, conn io.ReadWriteCloser, tracker *ioprogress.ProgressTracker, features []string, bwlimit string, execPath string) error {
-	cmd, netcatConn, stderr, err := sendSetup(name, path, bwlimit, execPath, features)
+// directory pointed to by path over the websocket. Files matching the
+// optional exclude patterns are neither sent nor deleted on the receiver.
+func Send(name string, path string, conn io.ReadWriteCloser, tracker *ioprogress.ProgressTracker, features []string, bwlimit string, execPath string, exclude ...string) error {
+	cmd, netcatConn, stderr, err := sendSetup(name, path, bwlimit, execPath, features, exclude)
 	if err != nil {
 		return err
 	}
@@ -197,11 +202,27 @@ func Send(name string, path string, conn io.ReadWriteCloser, tracker *ioprogress
 		}
 	}
 
+	// Setup compression of the websocket stream.
+	writeTarget, err := streamWriter(conn, features)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	readTarget, err := streamReader(conn, features)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
 	// Forward from netcat to target.
 	chCopyNetcat := make(chan error, 1)
 	go func() {
-		_, err := io.Copy(conn, readNetcatPipe)
+		_, err := io.Copy(writeTarget, readNetcatPipe)
 		chCopyNetcat <- err
+		writeTarget.Close()
 		readNetcatPipe.Close()
 		netcatConn.Close()
 		conn.Close() // sends barrier message.
@@ -211,8 +232,9 @@ func Send(name string, path string, conn io.ReadWriteCloser, tracker *ioprogress
 	writeNetcatPipe := io.WriteCloser(netcatConn)
 	chCopyTarget := make(chan error, 1)
 	go func() {
-		_, err := io.Copy(writeNetcatPipe, conn)
+		_, err := io.Copy(writeNetcatPipe, readTarget)
 		chCopyTarget <- err
+		readTarget.Close()
 		writeNetcatPipe.Close()
 	}()
 
@@ -269,6 +291,17 @@ func Recv(path string, conn io.ReadWriteCloser, tracker *ioprogress.ProgressTrac
 
 	cmd := exec.Command("rsync", args...)
 
+	// Setup compression of the websocket stream.
+	writeSource, err := streamWriter(conn, features)
+	if err != nil {
+		return err
+	}
+
+	readSource, err := streamReader(conn, features)
+	if err != nil {
+		return err
+	}
+
 	// Forward from rsync to source.
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -277,7 +310,8 @@ func Recv(path string, conn io.ReadWriteCloser, tracker *ioprogress.ProgressTrac
 
 	chCopyRsync := make(chan error, 1)
 	go func() {
-		_, err := io.Copy(conn, stdout)
+		_, err := io.Copy(writeSource, stdout)
+		writeSource.Close()
 		stdout.Close()
 		conn.Close() // sends barrier message.
 		chCopyRsync <- err
@@ -289,10 +323,10 @@ func Recv(path string, conn io.ReadWriteCloser, tracker *ioprogress.ProgressTrac
 		return err
 	}
 
-	readSourcePipe := io.ReadCloser(conn)
+	readSourcePipe := readSource
 	if tracker != nil {
 		readSourcePipe = &ioprogress.ProgressReader{
-			ReadCloser: conn,
+			ReadCloser: readSource,
 			Tracker:    tracker,
 		}
 	}
@@ -300,6 +334,7 @@ func Recv(path string, conn io.ReadWriteCloser, tracker *ioprogress.ProgressTrac
 	chCopySource := make(chan error, 1)
 	go func() {
 		_, err := io.Copy(stdin, readSourcePipe)
+		readSourcePipe.Close()
 		stdin.Close()
 		chCopySource <- err
 	}()
@@ -355,7 +390,8 @@ func rsyncFeatureArgs(features []string) []string {
 		args = append(args, "--delete")
 	}
 
-	if shared.StringInSlice("compress", features) {
+	// The zstd stream compression replaces rsync's own.
+	if shared.StringInSlice("compress", features) && !shared.StringInSlice("zstd", features) {
 		args = append(args, "--compress")
 		args = append(args, "--compress-level=2")
 	}
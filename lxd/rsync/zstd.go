@@ -0,0 +1,66 @@
+package rsync
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// zstdWriter compresses the data written to it, flushing every write so that the interactive rsync
+// protocol never stalls waiting for a block to fill up.
+type zstdWriter struct {
+	encoder *zstd.Encoder
+}
+
+func (w *zstdWriter) Write(p []byte) (int, error) {
+	n, err := w.encoder.Write(p)
+	if err != nil {
+		return n, err
+	}
+
+	return n, w.encoder.Flush()
+}
+
+// Close ends the zstd stream, it doesn't close the underlying writer.
+func (w *zstdWriter) Close() error {
+	return w.encoder.Close()
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// streamWriter returns the writer to use when sending rsync's output to the remote end, compressing
+// it with zstd when negotiated. The returned writer must be closed once done, before closing conn.
+func streamWriter(conn io.Writer, features []string) (io.WriteCloser, error) {
+	if !shared.StringInSlice("zstd", features) {
+		return nopWriteCloser{conn}, nil
+	}
+
+	encoder, err := zstd.NewWriter(conn, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	if err != nil {
+		return nil, err
+	}
+
+	return &zstdWriter{encoder: encoder}, nil
+}
+
+// streamReader returns the reader to use when receiving the remote end's rsync output, decompressing
+// it when zstd was negotiated. The returned reader must be closed once done, it doesn't close conn.
+func streamReader(conn io.Reader, features []string) (io.ReadCloser, error) {
+	if !shared.StringInSlice("zstd", features) {
+		return ioutil.NopCloser(conn), nil
+	}
+
+	decoder, err := zstd.NewReader(conn, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+
+	return decoder.IOReadCloser(), nil
+}
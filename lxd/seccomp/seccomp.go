@@ -37,6 +37,7 @@ import (
 #include <elf.h>
 #include <errno.h>
 #include <fcntl.h>
+#include <linux/bpf.h>
 #include <linux/seccomp.h>
 #include <linux/types.h>
 #include <linux/kdev_t.h>
@@ -45,6 +46,7 @@ import (
 #include <stdint.h>
 #include <stdlib.h>
 #include <string.h>
+#include <sys/ioctl.h>
 #include <sys/mount.h>
 #include <sys/socket.h>
 #include <sys/stat.h>
@@ -123,69 +125,71 @@ struct lxd_seccomp_data_arch {
 	int nr_mknodat;
 	int nr_setxattr;
 	int nr_mount;
+	int nr_bpf;
 };
 
 #define LXD_SECCOMP_NOTIFY_MKNOD    0
 #define LXD_SECCOMP_NOTIFY_MKNODAT  1
 #define LXD_SECCOMP_NOTIFY_SETXATTR 2
 #define LXD_SECCOMP_NOTIFY_MOUNT 3
+#define LXD_SECCOMP_NOTIFY_BPF 4
 
 // ordered by likelihood of usage...
 static const struct lxd_seccomp_data_arch seccomp_notify_syscall_table[] = {
-	{ -1, LXD_SECCOMP_NOTIFY_MKNOD, LXD_SECCOMP_NOTIFY_MKNODAT, LXD_SECCOMP_NOTIFY_SETXATTR, LXD_SECCOMP_NOTIFY_MOUNT },
+	{ -1, LXD_SECCOMP_NOTIFY_MKNOD, LXD_SECCOMP_NOTIFY_MKNODAT, LXD_SECCOMP_NOTIFY_SETXATTR, LXD_SECCOMP_NOTIFY_MOUNT, LXD_SECCOMP_NOTIFY_BPF },
 #ifdef AUDIT_ARCH_X86_64
-	{ AUDIT_ARCH_X86_64,      133, 259, 188, 165 },
+	{ AUDIT_ARCH_X86_64,      133, 259, 188, 165, 321 },
 #endif
 #ifdef AUDIT_ARCH_I386
-	{ AUDIT_ARCH_I386,         14, 297, 226,  21 },
+	{ AUDIT_ARCH_I386,         14, 297, 226,  21, 357 },
 #endif
 #ifdef AUDIT_ARCH_AARCH64
-	{ AUDIT_ARCH_AARCH64,      -1,  33,   5,  21 },
+	{ AUDIT_ARCH_AARCH64,      -1,  33,   5,  21, 280 },
 #endif
 #ifdef AUDIT_ARCH_ARM
-	{ AUDIT_ARCH_ARM,          14, 324, 226,  21 },
+	{ AUDIT_ARCH_ARM,          14, 324, 226,  21, 386 },
 #endif
 #ifdef AUDIT_ARCH_ARMEB
-	{ AUDIT_ARCH_ARMEB,        14, 324, 226,  21 },
+	{ AUDIT_ARCH_ARMEB,        14, 324, 226,  21, 386 },
 #endif
 #ifdef AUDIT_ARCH_S390
-	{ AUDIT_ARCH_S390,         14, 290, 224,  21 },
+	{ AUDIT_ARCH_S390,         14, 290, 224,  21, 351 },
 #endif
 #ifdef AUDIT_ARCH_S390X
-	{ AUDIT_ARCH_S390X,        14, 290, 224,  21 },
+	{ AUDIT_ARCH_S390X,        14, 290, 224,  21, 351 },
 #endif
 #ifdef AUDIT_ARCH_PPC
-	{ AUDIT_ARCH_PPC,          14, 288, 209,  21 },
+	{ AUDIT_ARCH_PPC,          14, 288, 209,  21, 361 },
 #endif
 #ifdef AUDIT_ARCH_PPC64
-	{ AUDIT_ARCH_PPC64,        14, 288, 209,  21 },
+	{ AUDIT_ARCH_PPC64,        14, 288, 209,  21, 361 },
 #endif
 #ifdef AUDIT_ARCH_PPC64LE
-	{ AUDIT_ARCH_PPC64LE,      14, 288, 209,  21 },
+	{ AUDIT_ARCH_PPC64LE,      14, 288, 209,  21, 361 },
 #endif
 #ifdef AUDIT_ARCH_SPARC
-	{ AUDIT_ARCH_SPARC,        14, 286, 169, 167 },
+	{ AUDIT_ARCH_SPARC,        14, 286, 169, 167, 349 },
 #endif
 #ifdef AUDIT_ARCH_SPARC64
-	{ AUDIT_ARCH_SPARC64,      14, 286, 169, 167 },
+	{ AUDIT_ARCH_SPARC64,      14, 286, 169, 167, 349 },
 #endif
 #ifdef AUDIT_ARCH_MIPS
-	{ AUDIT_ARCH_MIPS,         14, 290, 224,  21 },
+	{ AUDIT_ARCH_MIPS,         14, 290, 224,  21, 355 },
 #endif
 #ifdef AUDIT_ARCH_MIPSEL
-	{ AUDIT_ARCH_MIPSEL,       14, 290, 224,  21 },
+	{ AUDIT_ARCH_MIPSEL,       14, 290, 224,  21, 355 },
 #endif
 #ifdef AUDIT_ARCH_MIPS64
-	{ AUDIT_ARCH_MIPS64,      131, 249, 180, 160 },
+	{ AUDIT_ARCH_MIPS64,      131, 249, 180, 160, 315 },
 #endif
 #ifdef AUDIT_ARCH_MIPS64N32
-	{ AUDIT_ARCH_MIPS64N32,   131, 253, 180, 160 },
+	{ AUDIT_ARCH_MIPS64N32,   131, 253, 180, 160, 319 },
 #endif
 #ifdef AUDIT_ARCH_MIPSEL64
-	{ AUDIT_ARCH_MIPSEL64,    131, 249, 180, 160 },
+	{ AUDIT_ARCH_MIPSEL64,    131, 249, 180, 160, 315 },
 #endif
 #ifdef AUDIT_ARCH_MIPSEL64N32
-	{ AUDIT_ARCH_MIPSEL64N32, 131, 253, 180, 160 },
+	{ AUDIT_ARCH_MIPSEL64N32, 131, 253, 180, 160, 319 },
 #endif
 };
 
@@ -217,6 +221,9 @@ static int seccomp_notify_get_syscall(struct seccomp_notif *req,
 		if (entry->nr_mount == req->data.nr)
 			return LXD_SECCOMP_NOTIFY_MOUNT;
 
+		if (entry->nr_bpf == req->data.nr)
+			return LXD_SECCOMP_NOTIFY_BPF;
+
 		break;
 	}
 
@@ -231,6 +238,165 @@ static void seccomp_notify_update_response(struct seccomp_notif_resp *resp,
 	resp->flags |= flags;
 }
 
+#ifndef __NR_pidfd_open
+#define __NR_pidfd_open 434
+#endif
+
+#ifndef __NR_pidfd_getfd
+#define __NR_pidfd_getfd 438
+#endif
+
+static int bpf_read_attr(int mem_fd, struct seccomp_notif *req, union bpf_attr *attr)
+{
+	size_t size = req->data.args[2];
+	ssize_t ret;
+
+	if (size > sizeof(*attr))
+		size = sizeof(*attr);
+
+	memset(attr, 0, sizeof(*attr));
+	ret = pread(mem_fd, attr, size, req->data.args[1]);
+	if (ret < 0 || (size_t)ret != size)
+		return -EFAULT;
+
+	return 0;
+}
+
+// Only device cgroup programs are handled, everything else is left to the kernel.
+static bool bpf_is_device_program(int cmd, union bpf_attr *attr)
+{
+	switch (cmd) {
+	case BPF_PROG_LOAD:
+		return attr->prog_type == BPF_PROG_TYPE_CGROUP_DEVICE;
+	case BPF_PROG_ATTACH:
+	case BPF_PROG_DETACH:
+		return attr->attach_type == BPF_CGROUP_DEVICE;
+	}
+
+	return false;
+}
+
+// Load the program on the host and install the resulting fd into the calling task.
+static int bpf_device_program_load(int notify_fd, int mem_fd, struct seccomp_notif *req,
+				   struct seccomp_notif_resp *resp, union bpf_attr *attr)
+{
+	struct seccomp_notif_addfd addfd = {};
+	union bpf_attr new_attr = {};
+	char license[128] = {};
+	struct bpf_insn *insns;
+	size_t size;
+	ssize_t bytes;
+	int fd, ret;
+
+	if (attr->insn_cnt == 0 || attr->insn_cnt > BPF_MAXINSNS)
+		return -EINVAL;
+
+	size = attr->insn_cnt * sizeof(struct bpf_insn);
+	insns = malloc(size);
+	if (!insns)
+		return -ENOMEM;
+
+	bytes = pread(mem_fd, insns, size, attr->insns);
+	if (bytes < 0 || (size_t)bytes != size) {
+		free(insns);
+		return -EFAULT;
+	}
+
+	if (attr->license) {
+		bytes = pread(mem_fd, license, sizeof(license) - 1, attr->license);
+		if (bytes <= 0) {
+			free(insns);
+			return -EFAULT;
+		}
+	}
+
+	new_attr.prog_type = attr->prog_type;
+	new_attr.insn_cnt = attr->insn_cnt;
+	new_attr.insns = (uintptr_t)insns;
+	new_attr.license = (uintptr_t)license;
+	new_attr.prog_flags = attr->prog_flags;
+
+	fd = syscall(__NR_bpf, BPF_PROG_LOAD, &new_attr, sizeof(new_attr));
+	ret = -errno;
+	free(insns);
+	if (fd < 0)
+		return ret;
+
+	addfd.id = req->id;
+	addfd.srcfd = fd;
+	addfd.newfd_flags = O_CLOEXEC;
+	ret = ioctl(notify_fd, SECCOMP_IOCTL_NOTIF_ADDFD, &addfd);
+	if (ret < 0)
+		ret = -errno;
+	close(fd);
+	if (ret < 0)
+		return ret;
+
+	resp->val = ret;
+	return 0;
+}
+
+// Attach or detach a program using the cgroup and program fds of the calling task.
+static int bpf_device_program_attach(int cmd, struct seccomp_notif *req, union bpf_attr *attr)
+{
+	union bpf_attr new_attr = {};
+	int pidfd, cgroup_fd, prog_fd = -1;
+	int ret;
+
+	pidfd = syscall(__NR_pidfd_open, req->pid, 0);
+	if (pidfd < 0)
+		return -errno;
+
+	cgroup_fd = syscall(__NR_pidfd_getfd, pidfd, attr->target_fd, 0);
+	if (cgroup_fd < 0) {
+		ret = -errno;
+		close(pidfd);
+		return ret;
+	}
+
+	if (cmd == BPF_PROG_ATTACH || attr->attach_bpf_fd > 0) {
+		prog_fd = syscall(__NR_pidfd_getfd, pidfd, attr->attach_bpf_fd, 0);
+		if (prog_fd < 0) {
+			ret = -errno;
+			close(cgroup_fd);
+			close(pidfd);
+			return ret;
+		}
+	}
+
+	new_attr.target_fd = cgroup_fd;
+	new_attr.attach_bpf_fd = prog_fd >= 0 ? prog_fd : 0;
+	new_attr.attach_type = attr->attach_type;
+	new_attr.attach_flags = attr->attach_flags;
+
+	ret = syscall(__NR_bpf, cmd, &new_attr, sizeof(new_attr));
+	if (ret < 0)
+		ret = -errno;
+
+	if (prog_fd >= 0)
+		close(prog_fd);
+	close(cgroup_fd);
+	close(pidfd);
+
+	return ret;
+}
+
+static int handle_bpf_syscall(int notify_fd, int mem_fd, struct seccomp_notif *req,
+			      struct seccomp_notif_resp *resp, union bpf_attr *attr)
+{
+	int cmd = req->data.args[0];
+
+	switch (cmd) {
+	case BPF_PROG_LOAD:
+		return bpf_device_program_load(notify_fd, mem_fd, req, resp, attr);
+	case BPF_PROG_ATTACH:
+	case BPF_PROG_DETACH:
+		return bpf_device_program_attach(cmd, req, attr);
+	}
+
+	return -EINVAL;
+}
+
 static void prepare_seccomp_iovec(struct iovec *iov,
 				  struct seccomp_notify_proxy_msg *msg,
 				  struct seccomp_notif *notif,
@@ -255,6 +421,7 @@ const lxdSeccompNotifyMknod = C.LXD_SECCOMP_NOTIFY_MKNOD
 const lxdSeccompNotifyMknodat = C.LXD_SECCOMP_NOTIFY_MKNODAT
 const lxdSeccompNotifySetxattr = C.LXD_SECCOMP_NOTIFY_SETXATTR
 const lxdSeccompNotifyMount = C.LXD_SECCOMP_NOTIFY_MOUNT
+const lxdSeccompNotifyBpf = C.LXD_SECCOMP_NOTIFY_BPF
 
 const seccompHeader = `2
 `
@@ -320,6 +487,12 @@ move_mount errno 38
 // the allowed flags, i.e. we only intercept combinations were a new superblock
 // is created.
 
+// BPF_PROG_LOAD, BPF_PROG_ATTACH and BPF_PROG_DETACH
+const seccompNotifyBpf = `bpf notify [0,5,SCMP_CMP_EQ]
+bpf notify [0,8,SCMP_CMP_EQ]
+bpf notify [0,9,SCMP_CMP_EQ]
+`
+
 const seccompNotifyMount = `mount notify [3,0,SCMP_CMP_MASKED_EQ,18446744070422410016]
 `
 
@@ -388,8 +561,8 @@ func InstanceNeedsPolicy(c Instance) bool {
 	// Check for text keys
 	keys := []string{
 		"raw.seccomp",
-		"security.syscalls.whitelist",
-		"security.syscalls.blacklist",
+		"security.syscalls.allow",
+		"security.syscalls.deny",
 	}
 
 	for _, k := range keys {
@@ -401,10 +574,11 @@ func InstanceNeedsPolicy(c Instance) bool {
 
 	// Check for boolean keys that default to false
 	keys = []string{
-		"security.syscalls.blacklist_compat",
+		"security.syscalls.deny_compat",
 		"security.syscalls.intercept.mknod",
 		"security.syscalls.intercept.setxattr",
 		"security.syscalls.intercept.mount",
+		"security.syscalls.intercept.bpf",
 	}
 
 	for _, k := range keys {
@@ -415,7 +589,7 @@ func InstanceNeedsPolicy(c Instance) bool {
 
 	// Check for boolean keys that default to true
 	keys = []string{
-		"security.syscalls.blacklist_default",
+		"security.syscalls.deny_default",
 	}
 
 	for _, k := range keys {
@@ -446,6 +620,7 @@ func InstanceNeedsIntercept(c Instance) (bool, error) {
 		"security.syscalls.intercept.mknod":    lxcSupportSeccompNotify,
 		"security.syscalls.intercept.setxattr": lxcSupportSeccompNotify,
 		"security.syscalls.intercept.mount":    lxcSupportSeccompNotifyContinue,
+		"security.syscalls.intercept.bpf":      lxcSupportSeccompNotifyAddfd,
 	}
 
 	needed := false
@@ -475,14 +650,14 @@ func seccompGetPolicyContent(c Instance) (string, error) {
 
 	// Policy header
 	policy := seccompHeader
-	whitelist := config["security.syscalls.whitelist"]
-	if whitelist != "" {
+	allow := config["security.syscalls.allow"]
+	if allow != "" {
 		policy += "whitelist\n[all]\n"
-		policy += whitelist
+		policy += allow
 	} else {
 		policy += "blacklist\n"
 
-		defaultFlag, ok := config["security.syscalls.blacklist_default"]
+		defaultFlag, ok := config["security.syscalls.deny_default"]
 		if !ok || shared.IsTrue(defaultFlag) {
 			policy += defaultSeccompPolicy
 		}
@@ -514,14 +689,18 @@ func seccompGetPolicyContent(c Instance) (string, error) {
 			// multiple syscalls.
 			policy += seccompBlockNewMountAPI
 		}
+
+		if shared.IsTrue(config["security.syscalls.intercept.bpf"]) {
+			policy += seccompNotifyBpf
+		}
 	}
 
-	if whitelist != "" {
+	if allow != "" {
 		return policy, nil
 	}
 
-	// Additional blacklist entries
-	compat := config["security.syscalls.blacklist_compat"]
+	// Additional deny entries
+	compat := config["security.syscalls.deny_compat"]
 	if shared.IsTrue(compat) {
 		arch, err := osarch.ArchitectureName(c.Architecture())
 		if err != nil {
@@ -530,9 +709,9 @@ func seccompGetPolicyContent(c Instance) (string, error) {
 		policy += fmt.Sprintf(compatBlockingPolicy, arch)
 	}
 
-	blacklist := config["security.syscalls.blacklist"]
-	if blacklist != "" {
-		policy += blacklist
+	deny := config["security.syscalls.deny"]
+	if deny != "" {
+		policy += deny
 	}
 
 	return policy, nil
@@ -579,14 +758,15 @@ type Server struct {
 
 // Iovec defines an iovec to move data between kernel and userspace.
 type Iovec struct {
-	ucred  *ucred.UCred
-	memFd  int
-	procFd int
-	msg    *C.struct_seccomp_notify_proxy_msg
-	req    *C.struct_seccomp_notif
-	resp   *C.struct_seccomp_notif_resp
-	cookie *C.char
-	iov    *C.struct_iovec
+	ucred    *ucred.UCred
+	memFd    int
+	procFd   int
+	notifyFd int
+	msg      *C.struct_seccomp_notify_proxy_msg
+	req      *C.struct_seccomp_notif
+	resp     *C.struct_seccomp_notif_resp
+	cookie   *C.char
+	iov      *C.struct_iovec
 }
 
 // NewSeccompIovec creates a new seccomp iovec.
@@ -614,14 +794,15 @@ func NewSeccompIovec(ucred *ucred.UCred) *Iovec {
 	C.prepare_seccomp_iovec(iov, msg, req, resp, cookie)
 
 	return &Iovec{
-		memFd:  -1,
-		procFd: -1,
-		msg:    msg,
-		req:    req,
-		resp:   resp,
-		cookie: cookie,
-		iov:    iov,
-		ucred:  ucred,
+		memFd:    -1,
+		procFd:   -1,
+		notifyFd: -1,
+		msg:      msg,
+		req:      req,
+		resp:     resp,
+		cookie:   cookie,
+		iov:      iov,
+		ucred:    ucred,
 	}
 }
 
@@ -633,6 +814,9 @@ func (siov *Iovec) PutSeccompIovec() {
 	if siov.procFd >= 0 {
 		unix.Close(siov.procFd)
 	}
+	if siov.notifyFd >= 0 {
+		unix.Close(siov.notifyFd)
+	}
 	C.free(unsafe.Pointer(siov.msg))
 	C.free(unsafe.Pointer(siov.req))
 	C.free(unsafe.Pointer(siov.resp))
@@ -642,12 +826,17 @@ func (siov *Iovec) PutSeccompIovec() {
 
 // ReceiveSeccompIovec receives a seccomp iovec.
 func (siov *Iovec) ReceiveSeccompIovec(fd int) (uint64, error) {
-	bytes, fds, err := netutils.AbstractUnixReceiveFdData(fd, 2, unsafe.Pointer(siov.iov), 4)
+	bytes, fds, err := netutils.AbstractUnixReceiveFdData(fd, 3, unsafe.Pointer(siov.iov), 4)
 	if err != nil || err == io.EOF {
 		return 0, err
 	}
 
-	if len(fds) == 2 {
+	// Recent liblxc also sends the seccomp notify fd.
+	if len(fds) == 3 {
+		siov.procFd = int(fds[0])
+		siov.memFd = int(fds[1])
+		siov.notifyFd = int(fds[2])
+	} else if len(fds) == 2 {
 		siov.procFd = int(fds[0])
 		siov.memFd = int(fds[1])
 	} else {
@@ -1397,6 +1586,56 @@ func (s *Server) HandleMountSyscall(c Instance, siov *Iovec) int {
 	return 0
 }
 
+// HandleBpfSyscall handles bpf syscalls loading, attaching or detaching device cgroup programs.
+func (s *Server) HandleBpfSyscall(c Instance, siov *Iovec) int {
+	ctx := log.Ctx{"container": c.Name(),
+		"project":              c.Project(),
+		"syscall_number":       siov.req.data.nr,
+		"audit_architecture":   siov.req.data.arch,
+		"seccomp_notify_id":    siov.req.id,
+		"seccomp_notify_flags": siov.req.flags,
+	}
+
+	defer logger.Debug("Handling bpf syscall", ctx)
+
+	// Leave anything we don't handle to the kernel.
+	doContinue := func() int {
+		ctx["syscall_continue"] = "true"
+		C.seccomp_notify_update_response(siov.resp, 0, C.uint32_t(seccompUserNotifFlagContinue))
+		return 0
+	}
+
+	if !shared.IsTrue(c.ExpandedConfig()["security.syscalls.intercept.bpf.devices"]) {
+		ctx["err"] = "Device cgroup program management not enabled"
+		return doContinue()
+	}
+
+	var attr C.union_bpf_attr
+	ret := C.bpf_read_attr(C.int(siov.memFd), siov.req, &attr)
+	if ret < 0 {
+		ctx["err"] = "Failed to read memory for bpf syscall"
+		return doContinue()
+	}
+
+	cmd := C.int(siov.req.data.args[0])
+	if !bool(C.bpf_is_device_program(cmd, &attr)) {
+		return doContinue()
+	}
+
+	if siov.notifyFd < 0 && cmd == C.BPF_PROG_LOAD {
+		ctx["err"] = "No seccomp notify fd received"
+		return int(-C.ENOSYS)
+	}
+
+	ret = C.handle_bpf_syscall(C.int(siov.notifyFd), C.int(siov.memFd), siov.req, siov.resp, &attr)
+	if ret < 0 {
+		ctx["err"] = fmt.Sprintf("Failed to handle bpf syscall: %d", ret)
+		return int(ret)
+	}
+
+	return 0
+}
+
 func (s *Server) handleSyscall(c Instance, siov *Iovec) int {
 	switch int(C.seccomp_notify_get_syscall(siov.req, siov.resp)) {
 	case lxdSeccompNotifyMknod:
@@ -1407,6 +1646,8 @@ func (s *Server) handleSyscall(c Instance, siov *Iovec) int {
 		return s.HandleSetxattrSyscall(c, siov)
 	case lxdSeccompNotifyMount:
 		return s.HandleMountSyscall(c, siov)
+	case lxdSeccompNotifyBpf:
+		return s.HandleBpfSyscall(c, siov)
 	}
 
 	return int(-C.EINVAL)
@@ -1457,6 +1698,18 @@ func lxcSupportSeccompNotifyContinue(state *state.State) bool {
 	return true
 }
 
+func lxcSupportSeccompNotifyAddfd(state *state.State) bool {
+	if !lxcSupportSeccompNotifyContinue(state) {
+		return false
+	}
+
+	if !state.OS.LXCFeatures["seccomp_proxy_send_notify_fd"] {
+		return false
+	}
+
+	return true
+}
+
 func lxcSupportSeccompNotify(state *state.State) bool {
 	if !state.OS.SeccompListener {
 		return false
@@ -1492,7 +1745,7 @@ func MountSyscallFilter(config map[string]string) []string {
 	fsAllowed := strings.Split(config["security.syscalls.intercept.mount.allowed"], ",")
 	if len(fsAllowed) > 0 && fsAllowed[0] != "" {
 		for _, allowedfs := range fsAllowed {
-			fs = append(fs, allowedfs)
+			fs = append(fs, strings.TrimSpace(allowedfs))
 		}
 	}
 
@@ -1517,13 +1770,14 @@ func SyscallInterceptMountFilter(config map[string]string) (map[string]string, e
 
 			// fsfuse[0] == filesystems that are ok to mount
 			// fsfuse[1] == fuse binary to use to mount filesystemstype
-			fsMap[fsfuse[0]] = fsfuse[1]
+			fsMap[strings.TrimSpace(fsfuse[0])] = strings.TrimSpace(fsfuse[1])
 		}
 	}
 
 	fsAllowed := strings.Split(config["security.syscalls.intercept.mount.allowed"], ",")
 	if len(fsAllowed) > 0 && fsAllowed[0] != "" {
 		for _, allowedfs := range fsAllowed {
+			allowedfs = strings.TrimSpace(allowedfs)
 			if fsMap[allowedfs] != "" {
 				return map[string]string{}, fmt.Errorf("Filesystem %s cannot appear in security.syscalls.intercept.mount.allowed and security.syscalls.intercept.mount.fuse", allowedfs)
 			}
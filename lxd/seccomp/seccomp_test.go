@@ -19,3 +19,31 @@ func TestMountFlagsToOpts(t *testing.T) {
 		t.Fatal(fmt.Errorf("Mount options parsing failed with invalid option string: %s", opts))
 	}
 }
+
+func TestSyscallInterceptMountFilter(t *testing.T) {
+	config := map[string]string{
+		"security.syscalls.intercept.mount":         "true",
+		"security.syscalls.intercept.mount.allowed": "ext4, btrfs",
+		"security.syscalls.intercept.mount.fuse":    "xfs=fuse-xfs",
+	}
+
+	fsMap, err := SyscallInterceptMountFilter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fsMap) != 3 || fsMap["ext4"] != "" || fsMap["xfs"] != "fuse-xfs" {
+		t.Fatal(fmt.Errorf("Unexpected mount filter: %v", fsMap))
+	}
+
+	_, ok := fsMap["btrfs"]
+	if !ok {
+		t.Fatal(fmt.Errorf("Filesystem btrfs missing from mount filter: %v", fsMap))
+	}
+
+	config["security.syscalls.intercept.mount.allowed"] = "xfs"
+	_, err = SyscallInterceptMountFilter(config)
+	if err == nil {
+		t.Fatal(fmt.Errorf("Filesystem both allowed and fused wasn't rejected"))
+	}
+}
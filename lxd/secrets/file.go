@@ -0,0 +1,86 @@
+package secrets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// file stores each secret in its own file, readable by root only.
+type file struct {
+	path string
+}
+
+// NewFile returns a backend storing the secrets as files under path.
+func NewFile(path string) Backend {
+	return &file{path: path}
+}
+
+// Get returns the value of the secret or ErrNotFound.
+func (f *file) Get(name string) ([]byte, error) {
+	err := ValidName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := ioutil.ReadFile(filepath.Join(f.path, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// Put creates or replaces the secret. The value is written to a temporary file first so that
+// readers never see a partial secret.
+func (f *file) Put(name string, value []byte) error {
+	err := ValidName(name)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(f.path, name)
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(value)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Delete removes the secret, if it exists.
+func (f *file) Delete(name string) error {
+	err := ValidName(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filepath.Join(f.path, name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package secrets
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrNotFound is returned when a secret doesn't exist.
+var ErrNotFound = fmt.Errorf("Secret not found")
+
+// Backend stores secrets, such as encryption keys and credentials, outside of the LXD database.
+type Backend interface {
+	// Get returns the value of the secret or ErrNotFound.
+	Get(name string) ([]byte, error)
+
+	// Put creates or replaces the secret.
+	Put(name string, value []byte) error
+
+	// Delete removes the secret, if it exists.
+	Delete(name string) error
+}
+
+// List of backend types.
+const (
+	TypeFile  = "file"
+	TypeVault = "vault"
+)
+
+// Types is the list of supported backend types.
+var Types = []string{TypeFile, TypeVault}
+
+// validName matches secret names made of slash separated components.
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_.-]+(/[a-zA-Z0-9_.-]+)*$`)
+
+// ValidName checks that the secret name can be used with all the backends.
+func ValidName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("Invalid secret name %q", name)
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		if component == "." || component == ".." {
+			return fmt.Errorf("Invalid secret name %q", name)
+		}
+	}
+
+	return nil
+}
+
+// CephKey returns the cephx key of a ceph client stored in the backend, for hosts which don't have
+// a ceph keyring for it.
+func CephKey(backend Backend, clusterName string, userName string) (string, error) {
+	value, err := backend.Get(fmt.Sprintf("ceph/%s/client.%s", clusterName, userName))
+	if err != nil {
+		if err == ErrNotFound {
+			return "", fmt.Errorf("No keyring or stored key for ceph client %q", userName)
+		}
+
+		return "", err
+	}
+
+	return strings.TrimSpace(string(value)), nil
+}
@@ -0,0 +1,100 @@
+package secrets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBackend(t *testing.T, backend Backend) {
+	_, err := backend.Get("storage/key")
+	assert.Equal(t, ErrNotFound, err)
+
+	require.NoError(t, backend.Put("storage/key", []byte("foo")))
+	value, err := backend.Get("storage/key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("foo"), value)
+
+	require.NoError(t, backend.Put("storage/key", []byte("bar")))
+	value, err = backend.Get("storage/key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("bar"), value)
+
+	require.NoError(t, backend.Delete("storage/key"))
+	require.NoError(t, backend.Delete("storage/key"))
+	_, err = backend.Get("storage/key")
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.Error(t, backend.Put("../key", []byte("foo")))
+	assert.Error(t, backend.Put("/key", []byte("foo")))
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-secrets-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	testBackend(t, NewFile(dir))
+}
+
+func TestVault(t *testing.T) {
+	var lock sync.Mutex
+	secrets := map[string]vaultSecret{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, "/v1/secret/data/lxd/")
+		if r.Method == "DELETE" {
+			name = strings.TrimPrefix(r.URL.Path, "/v1/secret/metadata/lxd/")
+		}
+
+		switch r.Method {
+		case "GET":
+			secret, ok := secrets[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"errors":[]}`))
+				return
+			}
+
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": secret})
+		case "POST":
+			secret := vaultSecret{}
+			json.NewDecoder(r.Body).Decode(&secret)
+			secrets[name] = secret
+			w.Write([]byte(`{"data":{"version":1}}`))
+		case "DELETE":
+			delete(secrets, name)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewVault(server.URL, "token", "", "lxd")
+	require.NoError(t, err)
+	testBackend(t, backend)
+
+	backend, err = NewVault(server.URL, "wrong", "", "lxd")
+	require.NoError(t, err)
+	_, err = backend.Get("storage/key")
+	assert.EqualError(t, err, "Vault request failed: permission denied")
+
+	_, err = NewVault("vault:8200", "token", "", "lxd")
+	assert.Error(t, err)
+}
@@ -0,0 +1,173 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// vault stores the secrets in a HashiCorp Vault KV version 2 secrets engine. Each secret is a
+// Vault secret holding the base64 encoded value under the "value" key.
+type vault struct {
+	address string
+	token   string
+	mount   string
+	prefix  string
+
+	client *http.Client
+}
+
+// vaultSecret is the payload of KV version 2 reads and writes.
+type vaultSecret struct {
+	Data map[string]string `json:"data"`
+}
+
+// vaultErrors is the payload of Vault error responses.
+type vaultErrors struct {
+	Errors []string `json:"errors"`
+}
+
+// NewVault returns a backend storing the secrets under prefix in the KV version 2 secrets engine
+// mounted at mount on the Vault server at address, authenticating with token.
+func NewVault(address string, token string, mount string, prefix string) (Backend, error) {
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("Invalid Vault address %q", address)
+	}
+
+	if token == "" {
+		return nil, fmt.Errorf("A Vault token is required")
+	}
+
+	if mount == "" {
+		mount = "secret"
+	}
+
+	return &vault{
+		address: strings.TrimSuffix(address, "/"),
+		token:   token,
+		mount:   strings.Trim(mount, "/"),
+		prefix:  strings.Trim(prefix, "/"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// url returns the URL of the secret under the given KV version 2 endpoint (data or metadata).
+func (v *vault) url(endpoint string, name string) string {
+	return fmt.Sprintf("%s/v1/%s", v.address, path.Join(v.mount, endpoint, v.prefix, name))
+}
+
+// request sends a request to Vault, returning the HTTP status code along with the response body.
+func (v *vault) request(method string, url string, body interface{}) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return -1, nil, err
+		}
+
+		reader = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	req.Header.Set("X-Vault-Token", v.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return -1, nil, fmt.Errorf("Failed to contact Vault: %v", err)
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound {
+		errs := vaultErrors{}
+		json.Unmarshal(content, &errs)
+		if len(errs.Errors) > 0 {
+			return resp.StatusCode, nil, fmt.Errorf("Vault request failed: %s", strings.Join(errs.Errors, ", "))
+		}
+
+		return resp.StatusCode, nil, fmt.Errorf("Vault request failed: %s", resp.Status)
+	}
+
+	return resp.StatusCode, content, nil
+}
+
+// Get returns the value of the secret or ErrNotFound.
+func (v *vault) Get(name string) ([]byte, error) {
+	err := ValidName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	code, content, err := v.request("GET", v.url("data", name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if code == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
+	resp := struct {
+		Data vaultSecret `json:"data"`
+	}{}
+
+	err = json.Unmarshal(content, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Vault response: %v", err)
+	}
+
+	encoded, ok := resp.Data.Data["value"]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	value, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid value for secret %q: %v", name, err)
+	}
+
+	return value, nil
+}
+
+// Put creates or replaces the secret.
+func (v *vault) Put(name string, value []byte) error {
+	err := ValidName(name)
+	if err != nil {
+		return err
+	}
+
+	secret := vaultSecret{Data: map[string]string{"value": base64.StdEncoding.EncodeToString(value)}}
+	_, _, err = v.request("POST", v.url("data", name), secret)
+	return err
+}
+
+// Delete removes the secret along with all its versions, if it exists.
+func (v *vault) Delete(name string) error {
+	err := ValidName(name)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = v.request("DELETE", v.url("metadata", name), nil)
+	return err
+}
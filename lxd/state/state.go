@@ -6,11 +6,13 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/lxc/lxd/lxd/bgp"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/endpoints"
 	"github.com/lxc/lxd/lxd/events"
 	"github.com/lxc/lxd/lxd/firewall"
 	"github.com/lxc/lxd/lxd/maas"
+	"github.com/lxc/lxd/lxd/secrets"
 	"github.com/lxc/lxd/lxd/sys"
 )
 
@@ -38,11 +40,17 @@ type State struct {
 
 	// Firewall instance
 	Firewall firewall.Firewall
+
+	// BGP server
+	BGP *bgp.Server
+
+	// Secrets backend
+	Secrets secrets.Backend
 }
 
 // NewState returns a new State object with the given database and operating
 // system components.
-func NewState(node *db.Node, cluster *db.Cluster, maas *maas.Controller, os *sys.OS, endpoints *endpoints.Endpoints, events *events.Server, devlxdEvents *events.Server, firewall firewall.Firewall, proxy func(req *http.Request) (*url.URL, error)) *State {
+func NewState(node *db.Node, cluster *db.Cluster, maas *maas.Controller, os *sys.OS, endpoints *endpoints.Endpoints, events *events.Server, devlxdEvents *events.Server, firewall firewall.Firewall, proxy func(req *http.Request) (*url.URL, error), bgp *bgp.Server, secrets secrets.Backend) *State {
 	return &State{
 		Node:         node,
 		Cluster:      cluster,
@@ -53,5 +61,7 @@ func NewState(node *db.Node, cluster *db.Cluster, maas *maas.Controller, os *sys
 		Events:       events,
 		Firewall:     firewall,
 		Proxy:        proxy,
+		BGP:          bgp,
+		Secrets:      secrets,
 	}
 }
@@ -3,10 +3,12 @@
 package state
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/firewall"
+	"github.com/lxc/lxd/lxd/secrets"
 	"github.com/lxc/lxd/lxd/sys"
 )
 
@@ -26,7 +28,7 @@ func NewTestState(t *testing.T) (*State, func()) {
 		osCleanup()
 	}
 
-	state := NewState(node, cluster, nil, os, nil, nil, nil, firewall.New(), nil)
+	state := NewState(node, cluster, nil, os, nil, nil, nil, firewall.New(), nil, nil, secrets.NewFile(filepath.Join(os.VarDir, "secrets")))
 
 	return state, cleanup
 }
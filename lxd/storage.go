@@ -108,7 +108,7 @@ const (
 	storageTypeZfs
 )
 
-var supportedStoragePoolDrivers = []string{"btrfs", "ceph", "cephfs", "dir", "lvm", "zfs"}
+var supportedStoragePoolDrivers = []string{"btrfs", "ceph", "cephfs", "dir", "iscsi", "lvm", "zfs"}
 
 func storageTypeToString(sType storageType) (string, error) {
 	switch sType {
@@ -166,6 +166,7 @@ type storage interface {
 	StoragePoolMount() (bool, error)
 	StoragePoolUmount() (bool, error)
 	StoragePoolResources() (*api.ResourcesStoragePool, error)
+	StoragePoolHealth() storageDrivers.Health
 	StoragePoolUpdate(writable *api.StoragePoolPut, changedConfig []string) error
 	GetStoragePoolWritable() api.StoragePoolPut
 	SetStoragePoolWritable(writable *api.StoragePoolPut)
@@ -935,7 +936,8 @@ func storageRootFSApplyQuota(state *state.State, inst device.Instance, size stri
 		storageIsReady := c.storage.ContainerStorageReady(c)
 
 		// If we cannot apply the quota now, then return false as needs to be applied on next boot.
-		if (storageTypeName == "lvm" || storageTypeName == "ceph") && c.IsRunning() || !storageIsReady {
+		// LVM volumes can be grown online, shrinking is deferred by the driver itself.
+		if storageTypeName == "ceph" && c.IsRunning() || !storageIsReady {
 			return storagePools.ErrRunningQuotaResizeNotSupported
 		}
 
@@ -945,7 +947,9 @@ func storageRootFSApplyQuota(state *state.State, inst device.Instance, size stri
 		}
 
 		err = c.storage.StorageEntitySetQuota(storagePoolVolumeTypeContainer, newSizeBytes, c)
-		if err != nil {
+		if err == storagePools.ErrRunningQuotaResizeNotSupported {
+			return err
+		} else if err != nil {
 			return errors.Wrap(err, "Set storage quota")
 		}
 	}
@@ -142,6 +142,11 @@ func (b *lxdBackend) GetResources() (*api.ResourcesStoragePool, error) {
 	return b.driver.GetResources()
 }
 
+// Health returns the health of the pool.
+func (b *lxdBackend) Health() drivers.Health {
+	return b.driver.Health()
+}
+
 // Update updates the pool config.
 func (b *lxdBackend) Update(driverOnly bool, newDesc string, newConfig map[string]string, op *operations.Operation) error {
 	logger := logging.AddContext(b.logger, log.Ctx{"newDesc": newDesc, "newConfig": newConfig})
@@ -600,7 +605,7 @@ func (b *lxdBackend) CreateInstanceFromCopy(inst instance.Instance, src instance
 		// Negotiate the migration type to use.
 		offeredTypes := srcPool.MigrationTypes(contentType, false)
 		offerHeader := migration.TypesToHeader(offeredTypes...)
-		migrationType, err := migration.MatchTypes(offerHeader, migration.MigrationFSType_RSYNC, b.MigrationTypes(contentType, false))
+		migrationType, err := migration.MatchTypes(offerHeader, MigrationFallbackType(contentType), b.MigrationTypes(contentType, false))
 		if err != nil {
 			return fmt.Errorf("Failed to negotiate copy migration type: %v", err)
 		}
@@ -741,7 +746,7 @@ func (b *lxdBackend) RefreshInstance(inst instance.Instance, src instance.Instan
 		// Negotiate the migration type to use.
 		offeredTypes := srcPool.MigrationTypes(contentType, true)
 		offerHeader := migration.TypesToHeader(offeredTypes...)
-		migrationType, err := migration.MatchTypes(offerHeader, migration.MigrationFSType_RSYNC, b.MigrationTypes(contentType, true))
+		migrationType, err := migration.MatchTypes(offerHeader, MigrationFallbackType(contentType), b.MigrationTypes(contentType, true))
 		if err != nil {
 			return fmt.Errorf("Failed to negotiate copy migration type: %v", err)
 		}
@@ -1231,6 +1236,11 @@ func (b *lxdBackend) UpdateInstance(inst instance.Instance, newDesc string, newC
 			return fmt.Errorf("Instance volume 'block.filesystem' property cannot be changed")
 		}
 
+		// Check that the volume's security.encryption property isn't being changed.
+		if shared.IsTrue(curVol.Config["security.encryption"]) != shared.IsTrue(newConfig["security.encryption"]) {
+			return fmt.Errorf("Instance volume 'security.encryption' property cannot be changed")
+		}
+
 		curVol := b.newVolume(volType, contentType, volStorageName, curVol.Config)
 		if !userOnly {
 			err = b.driver.UpdateVolume(curVol, changedConfig)
@@ -1458,13 +1468,17 @@ func (b *lxdBackend) GetInstanceDisk(inst instance.Instance) (string, error) {
 		return "", err
 	}
 
+	// Get the root disk device config, encrypted volumes have a different disk location.
+	rootDiskConf, err := b.instanceRootVolumeConfig(inst)
+	if err != nil {
+		return "", err
+	}
+
 	contentType := InstanceContentType(inst)
 	volStorageName := project.Prefix(inst.Project(), inst.Name())
 
 	// Get the volume.
-	// There's no need to pass config as it's not needed when getting the
-	// location of the disk block device.
-	vol := b.newVolume(volType, contentType, volStorageName, nil)
+	vol := b.newVolume(volType, contentType, volStorageName, rootDiskConf)
 
 	// Get the location of the disk block device.
 	diskPath, err := b.driver.GetVolumeDiskPath(vol)
@@ -1823,7 +1837,7 @@ func (b *lxdBackend) EnsureImage(fingerprint string, op *operations.Operation) e
 		return err
 	}
 
-	err = VolumeDBCreate(b.state, "default", b.name, fingerprint, "", db.StoragePoolVolumeTypeNameImage, false, nil)
+	err = VolumeDBCreate(b.state, "default", b.name, fingerprint, "", db.StoragePoolVolumeTypeNameImage, false, nil, contentType)
 	if err != nil {
 		return err
 	}
@@ -1905,20 +1919,20 @@ func (b *lxdBackend) UpdateImage(fingerprint, newDesc string, newConfig map[stri
 }
 
 // CreateCustomVolume creates an empty custom volume.
-func (b *lxdBackend) CreateCustomVolume(volName, desc string, config map[string]string, op *operations.Operation) error {
-	logger := logging.AddContext(b.logger, log.Ctx{"volName": volName, "desc": desc, "config": config})
+func (b *lxdBackend) CreateCustomVolume(volName, desc string, config map[string]string, contentType drivers.ContentType, op *operations.Operation) error {
+	logger := logging.AddContext(b.logger, log.Ctx{"volName": volName, "desc": desc, "config": config, "contentType": contentType})
 	logger.Debug("CreateCustomVolume started")
 	defer logger.Debug("CreateCustomVolume finished")
 
 	// Validate config.
-	vol := b.newVolume(drivers.VolumeTypeCustom, drivers.ContentTypeFS, volName, config)
+	vol := b.newVolume(drivers.VolumeTypeCustom, contentType, volName, config)
 	err := b.driver.ValidateVolume(vol, false)
 	if err != nil {
 		return err
 	}
 
 	// Create database entry for new storage volume.
-	err = VolumeDBCreate(b.state, "default", b.name, volName, desc, db.StoragePoolVolumeTypeNameCustom, false, vol.Config())
+	err = VolumeDBCreate(b.state, "default", b.name, volName, desc, db.StoragePoolVolumeTypeNameCustom, false, vol.Config(), contentType)
 	if err != nil {
 		return err
 	}
@@ -1987,6 +2001,11 @@ func (b *lxdBackend) CreateCustomVolumeFromCopy(volName, desc string, config map
 		desc = srcVolRow.Description
 	}
 
+	contentType, err := VolumeContentTypeNameToContentType(srcVolRow.ContentType)
+	if err != nil {
+		return err
+	}
+
 	// If we are copying snapshots, retrieve a list of snapshots from source volume.
 	snapshotNames := []string{}
 	if !srcVolOnly {
@@ -2015,8 +2034,8 @@ func (b *lxdBackend) CreateCustomVolumeFromCopy(volName, desc string, config map
 			}
 		}()
 
-		vol := b.newVolume(drivers.VolumeTypeCustom, drivers.ContentTypeFS, volName, config)
-		srcVol := b.newVolume(drivers.VolumeTypeCustom, drivers.ContentTypeFS, srcVolName, srcVolRow.Config)
+		vol := b.newVolume(drivers.VolumeTypeCustom, contentType, volName, config)
+		srcVol := b.newVolume(drivers.VolumeTypeCustom, contentType, srcVolName, srcVolRow.Config)
 
 		// Check the supplied config and remove any fields not relevant for pool type.
 		err := b.driver.ValidateVolume(vol, true)
@@ -2025,7 +2044,7 @@ func (b *lxdBackend) CreateCustomVolumeFromCopy(volName, desc string, config map
 		}
 
 		// Create database entry for new storage volume.
-		err = VolumeDBCreate(b.state, "default", b.name, volName, desc, db.StoragePoolVolumeTypeNameCustom, false, vol.Config())
+		err = VolumeDBCreate(b.state, "default", b.name, volName, desc, db.StoragePoolVolumeTypeNameCustom, false, vol.Config(), contentType)
 		if err != nil {
 			return err
 		}
@@ -2037,7 +2056,7 @@ func (b *lxdBackend) CreateCustomVolumeFromCopy(volName, desc string, config map
 				newSnapshotName := drivers.GetSnapshotVolumeName(volName, snapName)
 
 				// Create database entry for new storage volume snapshot.
-				err = VolumeDBCreate(b.state, "default", b.name, newSnapshotName, desc, db.StoragePoolVolumeTypeNameCustom, true, vol.Config())
+				err = VolumeDBCreate(b.state, "default", b.name, newSnapshotName, desc, db.StoragePoolVolumeTypeNameCustom, true, vol.Config(), contentType)
 				if err != nil {
 					return err
 				}
@@ -2063,9 +2082,13 @@ func (b *lxdBackend) CreateCustomVolumeFromCopy(volName, desc string, config map
 	aEnd, bEnd := memorypipe.NewPipePair()
 
 	// Negotiate the migration type to use.
-	offeredTypes := srcPool.MigrationTypes(drivers.ContentTypeFS, false)
+	offeredTypes := srcPool.MigrationTypes(contentType, false)
+	if len(offeredTypes) <= 0 {
+		return fmt.Errorf("No source migration types available for %q content", contentType)
+	}
+
 	offerHeader := migration.TypesToHeader(offeredTypes...)
-	migrationType, err := migration.MatchTypes(offerHeader, migration.MigrationFSType_RSYNC, b.MigrationTypes(drivers.ContentTypeFS, false))
+	migrationType, err := migration.MatchTypes(offerHeader, MigrationFallbackType(contentType), b.MigrationTypes(contentType, false))
 	if err != nil {
 		return fmt.Errorf("Failed to neogotiate copy migration type: %v", err)
 	}
@@ -2117,6 +2140,213 @@ func (b *lxdBackend) CreateCustomVolumeFromCopy(volName, desc string, config map
 	return nil
 }
 
+// RefreshCustomVolume updates an existing custom volume to match another custom volume.
+// Only the source snapshots missing from the target are transferred and the target snapshots
+// which no longer exist on the source are removed. Drivers that can't refresh same-pool volumes
+// natively fall back to syncing through the migration system using rsync.
+func (b *lxdBackend) RefreshCustomVolume(volName, desc string, config map[string]string, srcPoolName, srcVolName string, srcVolOnly bool, op *operations.Operation) error {
+	logger := logging.AddContext(b.logger, log.Ctx{"volName": volName, "desc": desc, "config": config, "srcPoolName": srcPoolName, "srcVolName": srcVolName, "srcVolOnly": srcVolOnly})
+	logger.Debug("RefreshCustomVolume started")
+	defer logger.Debug("RefreshCustomVolume finished")
+
+	// Setup the source pool backend instance.
+	var srcPool *lxdBackend
+	if b.name == srcPoolName {
+		srcPool = b // Source and target are in the same pool so share pool var.
+	} else {
+		// Source is in a different pool to target, so load the pool.
+		tmpPool, err := GetPoolByName(b.state, srcPoolName)
+		if err != nil {
+			return err
+		}
+
+		// Convert to lxdBackend so we can access driver.
+		tmpBackend, ok := tmpPool.(*lxdBackend)
+		if !ok {
+			return fmt.Errorf("Pool is not an lxdBackend")
+		}
+
+		srcPool = tmpBackend
+	}
+
+	// Check source volume exists and is custom type.
+	_, srcVolRow, err := b.state.Cluster.StoragePoolNodeVolumeGetTypeByProject("default", srcVolName, db.StoragePoolVolumeTypeCustom, srcPool.ID())
+	if err != nil {
+		if err == db.ErrNoSuchObject {
+			return fmt.Errorf("Source volume doesn't exist")
+		}
+
+		return err
+	}
+
+	// Check target volume exists, there is nothing to refresh otherwise.
+	_, volRow, err := b.state.Cluster.StoragePoolNodeVolumeGetTypeByProject("default", volName, db.StoragePoolVolumeTypeCustom, b.ID())
+	if err != nil {
+		if err == db.ErrNoSuchObject {
+			return b.CreateCustomVolumeFromCopy(volName, desc, config, srcPoolName, srcVolName, srcVolOnly, op)
+		}
+
+		return err
+	}
+
+	if volRow.ContentType != srcVolRow.ContentType {
+		return fmt.Errorf("Source and target volumes must have the same content type")
+	}
+
+	contentType, err := VolumeContentTypeNameToContentType(srcVolRow.ContentType)
+	if err != nil {
+		return err
+	}
+
+	// Work out which snapshots need to be transferred and which ones need to be removed.
+	snapshotNames := []string{}
+	if !srcVolOnly {
+		srcSnapshots, err := VolumeSnapshotsGet(b.state, srcPoolName, srcVolName, db.StoragePoolVolumeTypeCustom)
+		if err != nil {
+			return err
+		}
+
+		snapshots, err := VolumeSnapshotsGet(b.state, b.name, volName, db.StoragePoolVolumeTypeCustom)
+		if err != nil {
+			return err
+		}
+
+		srcSnapNames := []string{}
+		for _, srcSnapshot := range srcSnapshots {
+			_, snapName, _ := shared.InstanceGetParentAndSnapshotName(srcSnapshot.Name)
+			srcSnapNames = append(srcSnapNames, snapName)
+		}
+
+		targetSnapNames := []string{}
+		for _, snapshot := range snapshots {
+			_, snapName, _ := shared.InstanceGetParentAndSnapshotName(snapshot.Name)
+			if !shared.StringInSlice(snapName, srcSnapNames) {
+				err = b.DeleteCustomVolumeSnapshot(snapshot.Name, op)
+				if err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			targetSnapNames = append(targetSnapNames, snapName)
+		}
+
+		for _, snapName := range srcSnapNames {
+			if !shared.StringInSlice(snapName, targetSnapNames) {
+				snapshotNames = append(snapshotNames, snapName)
+			}
+		}
+	}
+
+	// If the source and target are in the same pool then let the driver refresh the volume
+	// directly as it may be able to do so without copying the data. Block volumes always go
+	// through the migration system which only sends the changed chunks of their disk.
+	if srcPool == b && contentType != drivers.ContentTypeBlock {
+		logger.Debug("RefreshCustomVolume same-pool mode detected")
+
+		vol := b.newVolume(drivers.VolumeTypeCustom, contentType, volName, volRow.Config)
+		srcVol := b.newVolume(drivers.VolumeTypeCustom, contentType, srcVolName, srcVolRow.Config)
+
+		// Create slice to record DB volumes created if revert needed later.
+		revertDBVolumes := []string{}
+		revert := func() {
+			// Remove any DB volume rows created if we are reverting.
+			for _, volName := range revertDBVolumes {
+				b.state.Cluster.StoragePoolVolumeDelete("default", volName, db.StoragePoolVolumeTypeCustom, b.ID())
+			}
+		}
+
+		srcSnapVols := []drivers.Volume{}
+		for _, snapName := range snapshotNames {
+			newSnapshotName := drivers.GetSnapshotVolumeName(volName, snapName)
+
+			// Create database entry for new storage volume snapshot.
+			err = VolumeDBCreate(b.state, "default", b.name, newSnapshotName, volRow.Description, db.StoragePoolVolumeTypeNameCustom, true, vol.Config(), contentType)
+			if err != nil {
+				revert()
+				return err
+			}
+
+			revertDBVolumes = append(revertDBVolumes, newSnapshotName)
+			srcSnapVols = append(srcSnapVols, b.newVolume(drivers.VolumeTypeCustom, contentType, drivers.GetSnapshotVolumeName(srcVolName, snapName), srcVolRow.Config))
+		}
+
+		err = b.driver.RefreshVolume(vol, srcVol, srcSnapVols, op)
+		if err == nil {
+			return nil
+		}
+
+		revert()
+		if err != drivers.ErrNotImplemented {
+			return err
+		}
+
+		logger.Debug("RefreshCustomVolume falling back to migration mode")
+	}
+
+	// Use the migration system, with rsync only sending the differences.
+	aEnd, bEnd := memorypipe.NewPipePair()
+
+	// Negotiate the migration type to use.
+	offeredTypes := srcPool.MigrationTypes(contentType, true)
+	if len(offeredTypes) <= 0 {
+		return fmt.Errorf("No source migration types available for %q content", contentType)
+	}
+
+	offerHeader := migration.TypesToHeader(offeredTypes...)
+	migrationType, err := migration.MatchTypes(offerHeader, MigrationFallbackType(contentType), b.MigrationTypes(contentType, true))
+	if err != nil {
+		return fmt.Errorf("Failed to negotiate refresh migration type: %v", err)
+	}
+
+	// Run sender and receiver in separate go routines to prevent deadlocks.
+	aEndErrCh := make(chan error, 1)
+	bEndErrCh := make(chan error, 1)
+	go func() {
+		err := srcPool.MigrateCustomVolume(aEnd, migration.VolumeSourceArgs{
+			Name:          srcVolName,
+			Snapshots:     snapshotNames,
+			MigrationType: migrationType,
+			TrackProgress: true, // Do use a progress tracker on sender.
+		}, op)
+
+		aEndErrCh <- err
+	}()
+
+	go func() {
+		err := b.CreateCustomVolumeFromMigration(bEnd, migration.VolumeTargetArgs{
+			Name:          volName,
+			Description:   volRow.Description,
+			Config:        volRow.Config,
+			Snapshots:     snapshotNames,
+			MigrationType: migrationType,
+			TrackProgress: false, // Do not use a progress tracker on receiver.
+			Refresh:       true,
+		}, op)
+
+		bEndErrCh <- err
+	}()
+
+	// Capture errors from the sender and receiver from their result channels.
+	errs := []error{}
+	aEndErr := <-aEndErrCh
+	if aEndErr != nil {
+		errs = append(errs, aEndErr)
+	}
+
+	bEndErr := <-bEndErrCh
+	if bEndErr != nil {
+		errs = append(errs, bEndErr)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("Refresh custom volume from copy failed: %v", errs)
+	}
+
+	return nil
+}
+
 // MigrateCustomVolume sends a volume for migration.
 func (b *lxdBackend) MigrateCustomVolume(conn io.ReadWriteCloser, args migration.VolumeSourceArgs, op *operations.Operation) error {
 	logger := logging.AddContext(b.logger, log.Ctx{"volName": args.Name, "args": args})
@@ -2155,20 +2385,32 @@ func (b *lxdBackend) CreateCustomVolumeFromMigration(conn io.ReadWriteCloser, ar
 		return err
 	}
 
-	// Create database entry for new storage volume.
-	err = VolumeDBCreate(b.state, "default", b.name, args.Name, args.Description, db.StoragePoolVolumeTypeNameCustom, false, vol.Config())
-	if err != nil {
-		return err
-	}
+	if args.Refresh {
+		// The volume being refreshed must already exist.
+		_, _, err = b.state.Cluster.StoragePoolNodeVolumeGetTypeByProject("default", args.Name, db.StoragePoolVolumeTypeCustom, b.ID())
+		if err != nil {
+			if err == db.ErrNoSuchObject {
+				return fmt.Errorf("Volume to refresh doesn't exist")
+			}
 
-	revertDBVolumes = append(revertDBVolumes, args.Name)
+			return err
+		}
+	} else {
+		// Create database entry for new storage volume.
+		err = VolumeDBCreate(b.state, "default", b.name, args.Name, args.Description, db.StoragePoolVolumeTypeNameCustom, false, vol.Config(), drivers.ContentTypeFS)
+		if err != nil {
+			return err
+		}
+
+		revertDBVolumes = append(revertDBVolumes, args.Name)
+	}
 
 	if len(args.Snapshots) > 0 {
 		for _, snapName := range args.Snapshots {
 			newSnapshotName := drivers.GetSnapshotVolumeName(args.Name, snapName)
 
 			// Create database entry for new storage volume snapshot.
-			err = VolumeDBCreate(b.state, "default", b.name, newSnapshotName, args.Description, db.StoragePoolVolumeTypeNameCustom, true, vol.Config())
+			err = VolumeDBCreate(b.state, "default", b.name, newSnapshotName, args.Description, db.StoragePoolVolumeTypeNameCustom, true, vol.Config(), drivers.ContentTypeFS)
 			if err != nil {
 				return err
 			}
@@ -2298,13 +2540,6 @@ func (b *lxdBackend) UpdateCustomVolume(volName, newDesc string, newConfig map[s
 		return fmt.Errorf("Volume name cannot be a snapshot")
 	}
 
-	// Validate config.
-	newVol := b.newVolume(drivers.VolumeTypeCustom, drivers.ContentTypeFS, volName, newConfig)
-	err := b.driver.ValidateVolume(newVol, false)
-	if err != nil {
-		return err
-	}
-
 	// Get current config to compare what has changed.
 	_, curVol, err := b.state.Cluster.StoragePoolNodeVolumeGetTypeByProject("default", volName, db.StoragePoolVolumeTypeCustom, b.ID())
 	if err != nil {
@@ -2315,6 +2550,18 @@ func (b *lxdBackend) UpdateCustomVolume(volName, newDesc string, newConfig map[s
 		return err
 	}
 
+	contentType, err := VolumeContentTypeNameToContentType(curVol.ContentType)
+	if err != nil {
+		return err
+	}
+
+	// Validate config.
+	newVol := b.newVolume(drivers.VolumeTypeCustom, contentType, volName, newConfig)
+	err = b.driver.ValidateVolume(newVol, false)
+	if err != nil {
+		return err
+	}
+
 	// Apply config changes if there are any.
 	changedConfig, userOnly := b.detectChangedConfig(curVol.Config, newConfig)
 	if len(changedConfig) != 0 {
@@ -2323,7 +2570,12 @@ func (b *lxdBackend) UpdateCustomVolume(volName, newDesc string, newConfig map[s
 			return fmt.Errorf("Custom volume 'block.filesystem' property cannot be changed")
 		}
 
-		curVol := b.newVolume(drivers.VolumeTypeCustom, drivers.ContentTypeFS, volName, curVol.Config)
+		// Check that the volume's security.encryption property isn't being changed.
+		if shared.IsTrue(curVol.Config["security.encryption"]) != shared.IsTrue(newConfig["security.encryption"]) {
+			return fmt.Errorf("Custom volume 'security.encryption' property cannot be changed")
+		}
+
+		curVol := b.newVolume(drivers.VolumeTypeCustom, contentType, volName, curVol.Config)
 		if !userOnly {
 			err = b.driver.UpdateVolume(curVol, changedConfig)
 			if err != nil {
@@ -2405,8 +2657,18 @@ func (b *lxdBackend) DeleteCustomVolume(volName string, op *operations.Operation
 		}
 	}
 
+	_, volume, err := b.state.Cluster.StoragePoolNodeVolumeGetTypeByProject("default", volName, db.StoragePoolVolumeTypeCustom, b.id)
+	if err != nil {
+		return err
+	}
+
+	contentType, err := VolumeContentTypeNameToContentType(volume.ContentType)
+	if err != nil {
+		return err
+	}
+
 	// There's no need to pass config as it's not needed when deleting a volume.
-	vol := b.newVolume(drivers.VolumeTypeCustom, drivers.ContentTypeFS, volName, nil)
+	vol := b.newVolume(drivers.VolumeTypeCustom, contentType, volName, nil)
 
 	// Delete the volume from the storage device. Must come after snapshots are removed.
 	err = b.driver.DeleteVolume(vol, op)
@@ -2443,7 +2705,12 @@ func (b *lxdBackend) MountCustomVolume(volName string, op *operations.Operation)
 		return false, err
 	}
 
-	vol := b.newVolume(drivers.VolumeTypeCustom, drivers.ContentTypeFS, volName, volume.Config)
+	contentType, err := VolumeContentTypeNameToContentType(volume.ContentType)
+	if err != nil {
+		return false, err
+	}
+
+	vol := b.newVolume(drivers.VolumeTypeCustom, contentType, volName, volume.Config)
 
 	return b.driver.MountVolume(vol, op)
 }
@@ -2459,11 +2726,32 @@ func (b *lxdBackend) UnmountCustomVolume(volName string, op *operations.Operatio
 		return false, err
 	}
 
-	vol := b.newVolume(drivers.VolumeTypeCustom, drivers.ContentTypeFS, volName, volume.Config)
+	contentType, err := VolumeContentTypeNameToContentType(volume.ContentType)
+	if err != nil {
+		return false, err
+	}
+
+	vol := b.newVolume(drivers.VolumeTypeCustom, contentType, volName, volume.Config)
 
 	return b.driver.UnmountVolume(vol, op)
 }
 
+// GetCustomVolumeDisk returns the location of the disk of a block custom volume.
+func (b *lxdBackend) GetCustomVolumeDisk(volName string) (string, error) {
+	_, volume, err := b.state.Cluster.StoragePoolNodeVolumeGetTypeByProject("default", volName, db.StoragePoolVolumeTypeCustom, b.id)
+	if err != nil {
+		return "", err
+	}
+
+	if volume.ContentType != db.StoragePoolVolumeContentTypeNameBlock {
+		return "", fmt.Errorf("Custom volume %q is not a block volume", volName)
+	}
+
+	vol := b.newVolume(drivers.VolumeTypeCustom, drivers.ContentTypeBlock, volName, volume.Config)
+
+	return b.driver.GetVolumeDiskPath(vol)
+}
+
 // CreateCustomVolumeSnapshot creates a snapshot of a custom volume.
 func (b *lxdBackend) CreateCustomVolumeSnapshot(volName string, newSnapshotName string, op *operations.Operation) error {
 	logger := logging.AddContext(b.logger, log.Ctx{"volName": volName, "newSnapshotName": newSnapshotName})
@@ -2500,8 +2788,13 @@ func (b *lxdBackend) CreateCustomVolumeSnapshot(volName string, newSnapshotName
 		return err
 	}
 
+	contentType, err := VolumeContentTypeNameToContentType(parentVol.ContentType)
+	if err != nil {
+		return err
+	}
+
 	// Create database entry for new storage volume snapshot.
-	err = VolumeDBCreate(b.state, "default", b.name, fullSnapshotName, parentVol.Description, db.StoragePoolVolumeTypeNameCustom, true, parentVol.Config)
+	err = VolumeDBCreate(b.state, "default", b.name, fullSnapshotName, parentVol.Description, db.StoragePoolVolumeTypeNameCustom, true, parentVol.Config, contentType)
 	if err != nil {
 		return err
 	}
@@ -2513,7 +2806,7 @@ func (b *lxdBackend) CreateCustomVolumeSnapshot(volName string, newSnapshotName
 		}
 	}()
 
-	vol := b.newVolume(drivers.VolumeTypeCustom, drivers.ContentTypeFS, fullSnapshotName, parentVol.Config)
+	vol := b.newVolume(drivers.VolumeTypeCustom, contentType, fullSnapshotName, parentVol.Config)
 
 	// Create the snapshot on the storage device.
 	err = b.driver.CreateVolumeSnapshot(vol, op)
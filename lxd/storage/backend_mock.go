@@ -44,6 +44,10 @@ func (b *mockBackend) GetResources() (*api.ResourcesStoragePool, error) {
 	return nil, nil
 }
 
+func (b *mockBackend) Health() drivers.Health {
+	return drivers.Health{Status: drivers.HealthOnline}
+}
+
 func (b *mockBackend) Delete(localOnly bool, op *operations.Operation) error {
 	return nil
 }
@@ -164,7 +168,7 @@ func (b *mockBackend) UpdateImage(fingerprint, newDesc string, newConfig map[str
 	return nil
 }
 
-func (b *mockBackend) CreateCustomVolume(volName, desc string, config map[string]string, op *operations.Operation) error {
+func (b *mockBackend) CreateCustomVolume(volName, desc string, config map[string]string, contentType drivers.ContentType, op *operations.Operation) error {
 	return nil
 }
 
@@ -172,6 +176,10 @@ func (b *mockBackend) CreateCustomVolumeFromCopy(volName, desc string, config ma
 	return nil
 }
 
+func (b *mockBackend) RefreshCustomVolume(volName, desc string, config map[string]string, srcPoolName, srcVolName string, srcVolOnly bool, op *operations.Operation) error {
+	return nil
+}
+
 func (b *mockBackend) RenameCustomVolume(volName string, newName string, op *operations.Operation) error {
 	return nil
 }
@@ -204,6 +212,10 @@ func (b *mockBackend) UnmountCustomVolume(volName string, op *operations.Operati
 	return true, nil
 }
 
+func (b *mockBackend) GetCustomVolumeDisk(volName string) (string, error) {
+	return "", nil
+}
+
 func (b *mockBackend) CreateCustomVolumeSnapshot(volName string, newSnapshotName string, op *operations.Operation) error {
 	return nil
 }
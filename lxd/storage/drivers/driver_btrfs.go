@@ -93,13 +93,13 @@ func (d *btrfs) Create() error {
 		}
 
 		// Format the file.
-		_, err = makeFSType(d.config["source"], "btrfs", &mkfsOptions{Label: d.name})
+		_, err = MakeFSType(d.config["source"], "btrfs", &MkfsOptions{Label: d.name})
 		if err != nil {
 			return fmt.Errorf("Failed to format sparse file: %v", err)
 		}
 	} else if shared.IsBlockdevPath(d.config["source"]) {
 		// Format the block device.
-		_, err := makeFSType(d.config["source"], "btrfs", &mkfsOptions{Label: d.name})
+		_, err := MakeFSType(d.config["source"], "btrfs", &MkfsOptions{Label: d.name})
 		if err != nil {
 			return fmt.Errorf("Failed to format block device: %v", err)
 		}
@@ -233,7 +233,15 @@ func (d *btrfs) Validate(config map[string]string) error {
 
 // Update applies any driver changes required from a configuration change.
 func (d *btrfs) Update(changedConfig map[string]string) error {
-	// We only care about btrfs.mount_options.
+	size, ok := changedConfig["size"]
+	if ok && size != "" {
+		err := d.growLoop(size)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Otherwise we only care about btrfs.mount_options.
 	val, ok := changedConfig["btrfs.mount_options"]
 	if !ok {
 		return nil
@@ -252,6 +260,32 @@ func (d *btrfs) Update(changedConfig map[string]string) error {
 	return nil
 }
 
+// growLoop grows the loop file backing the pool and the filesystem on it to size.
+func (d *btrfs) growLoop(size string) error {
+	loopPath := filepath.Join(shared.VarPath("disks"), fmt.Sprintf("%s.img", d.name))
+	if d.config["source"] != loopPath {
+		return fmt.Errorf("Only loop backed btrfs pools can be resized")
+	}
+
+	sizeBytes, err := units.ParseByteSizeString(size)
+	if err != nil {
+		return err
+	}
+
+	err = GrowLoopFile(loopPath, sizeBytes)
+	if err != nil {
+		return err
+	}
+
+	_, err = shared.RunCommand("btrfs", "filesystem", "resize", "max", GetPoolMountPath(d.name))
+	if err != nil {
+		return fmt.Errorf("Failed to grow the btrfs filesystem: %v", err)
+	}
+
+	d.config["size"] = size
+	return nil
+}
+
 // Mount mounts the storage pool.
 func (d *btrfs) Mount() (bool, error) {
 	// Check if already mounted.
@@ -341,8 +375,27 @@ func (d *btrfs) GetResources() (*api.ResourcesStoragePool, error) {
 	return d.vfsGetResources()
 }
 
+// Health returns the health of the storage pool.
+func (d *btrfs) Health() Health {
+	if !shared.IsMountPoint(GetPoolMountPath(d.name)) {
+		return Health{Status: HealthOffline, Message: "Pool isn't mounted"}
+	}
+
+	// Look for missing devices in multi-device filesystems.
+	out, err := shared.RunCommand("btrfs", "filesystem", "show", GetPoolMountPath(d.name))
+	if err == nil && strings.Contains(out, "devices missing") {
+		return Health{Status: HealthDegraded, Message: "Some devices are missing from the btrfs filesystem"}
+	}
+
+	return d.vfsHealth()
+}
+
 // MigrationType returns the type of transfer methods to be used when doing migrations between pools in preference order.
 func (d *btrfs) MigrationTypes(contentType ContentType, refresh bool) []migration.Type {
+	if contentType == ContentTypeBlock {
+		return d.common.MigrationTypes(contentType, refresh)
+	}
+
 	if contentType != ContentTypeFS {
 		return nil
 	}
@@ -354,7 +407,7 @@ func (d *btrfs) MigrationTypes(contentType ContentType, refresh bool) []migratio
 		return []migration.Type{
 			{
 				FSType:   migration.MigrationFSType_RSYNC,
-				Features: []string{"xattrs", "delete", "compress", "bidirectional"},
+				Features: d.rsyncFeatures("xattrs", "delete", "compress", "bidirectional", "zstd"),
 			},
 		}
 	}
@@ -365,7 +418,7 @@ func (d *btrfs) MigrationTypes(contentType ContentType, refresh bool) []migratio
 		},
 		{
 			FSType:   migration.MigrationFSType_RSYNC,
-			Features: []string{"xattrs", "delete", "compress", "bidirectional"},
+			Features: d.rsyncFeatures("xattrs", "delete", "compress", "bidirectional", "zstd"),
 		},
 	}
 }
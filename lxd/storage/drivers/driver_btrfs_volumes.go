@@ -55,7 +55,12 @@ func (d *btrfs) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Op
 	// If we are creating a block volume, resize it to the requested size or the default.
 	// We expect the filler function to have converted the qcow2 image to raw into the rootBlockPath.
 	if vol.contentType == ContentTypeBlock {
-		err := ensureVolumeBlockFile(vol, rootBlockPath)
+		err := ensureVolumeBlockFile(rootBlockPath, vol.ExpandedConfig("size"))
+		if err != nil {
+			return err
+		}
+	} else {
+		err = d.applyVolumeQuota(vol, op)
 		if err != nil {
 			return err
 		}
@@ -201,6 +206,12 @@ func (d *btrfs) CreateVolumeFromCopy(vol Volume, srcVol Volume, copySnapshots bo
 		return err
 	}
 
+	// The new subvolume gets its own qgroup, so the size limit needs applying again.
+	err = d.applyVolumeQuota(vol, op)
+	if err != nil {
+		return err
+	}
+
 	// If we're not copying any snapshots, we're done here.
 	if !copySnapshots || srcVol.IsSnapshot() {
 		return nil
@@ -239,6 +250,11 @@ func (d *btrfs) CreateVolumeFromCopy(vol Volume, srcVol Volume, copySnapshots bo
 
 // CreateVolumeFromMigration creates a volume being sent via a migration.
 func (d *btrfs) CreateVolumeFromMigration(vol Volume, conn io.ReadWriteCloser, volTargetArgs migration.VolumeTargetArgs, preFiller *VolumeFiller, op *operations.Operation) error {
+	// Block volumes are sent as rsync followed by the changed chunks of the disk image.
+	if vol.contentType == ContentTypeBlock && volTargetArgs.MigrationType.FSType == migration.MigrationFSType_BLOCK_AND_RSYNC {
+		return genericCreateVolumeFromMigration(d, nil, vol, conn, volTargetArgs, preFiller, op)
+	}
+
 	if vol.contentType != ContentTypeFS {
 		return fmt.Errorf("Content type not supported")
 	}
@@ -293,12 +309,77 @@ func (d *btrfs) CreateVolumeFromMigration(vol Volume, conn io.ReadWriteCloser, v
 		return err
 	}
 
-	return nil
+	return d.applyVolumeQuota(vol, op)
 }
 
 // RefreshVolume provides same-pool volume and specific snapshots syncing functionality.
+// As both volumes live on the same filesystem, the missing snapshots and the main volume are
+// re-created as subvolume snapshots of the source rather than copied over with rsync.
 func (d *btrfs) RefreshVolume(vol Volume, srcVol Volume, srcSnapshots []Volume, op *operations.Operation) error {
-	return genericCopyVolume(d, nil, vol, srcVol, srcSnapshots, op)
+	if vol.contentType != ContentTypeFS || srcVol.contentType != ContentTypeFS {
+		return fmt.Errorf("Content type not supported")
+	}
+
+	revertSnaps := []string{}
+	defer func() {
+		// Remove any snapshots created if we are reverting.
+		for _, snapPath := range revertSnaps {
+			d.deleteSubvolume(snapPath, true)
+		}
+	}()
+
+	if len(srcSnapshots) > 0 && !srcVol.IsSnapshot() {
+		err := createParentSnapshotDirIfMissing(d.name, vol.volType, vol.name)
+		if err != nil {
+			return err
+		}
+
+		for _, srcSnapshot := range srcSnapshots {
+			_, snapName, _ := shared.InstanceGetParentAndSnapshotName(srcSnapshot.name)
+			dstSnapshot := GetVolumeMountPath(d.name, vol.volType, GetSnapshotVolumeName(vol.name, snapName))
+
+			err = d.snapshotSubvolume(srcSnapshot.MountPath(), dstSnapshot, true, false)
+			if err != nil {
+				return err
+			}
+
+			revertSnaps = append(revertSnaps, dstSnapshot)
+		}
+	}
+
+	// Replace the main volume with a fresh snapshot of the source.
+	volPath := vol.MountPath()
+	tmpPath := fmt.Sprintf("%s.refresh", volPath)
+	err := d.snapshotSubvolume(srcVol.MountPath(), tmpPath, false, true)
+	if err != nil {
+		return err
+	}
+
+	if d.isSubvolume(volPath) {
+		err = d.deleteSubvolume(volPath, true)
+		if err != nil {
+			d.deleteSubvolume(tmpPath, true)
+			return err
+		}
+	}
+
+	err = os.Rename(tmpPath, volPath)
+	if err != nil {
+		return err
+	}
+
+	err = vol.EnsureMountPath()
+	if err != nil {
+		return err
+	}
+
+	err = d.applyVolumeQuota(vol, op)
+	if err != nil {
+		return err
+	}
+
+	revertSnaps = nil
+	return nil
 }
 
 // DeleteVolume deletes a volume of the storage device. If any snapshots of the volume remain then
@@ -348,14 +429,14 @@ func (d *btrfs) ValidateVolume(vol Volume, removeUnknownKeys bool) error {
 
 // UpdateVolume applies config changes to the volume.
 func (d *btrfs) UpdateVolume(vol Volume, changedConfig map[string]string) error {
-	if vol.contentType != ContentTypeFS {
-		return fmt.Errorf("Content type not supported")
-	}
-
 	if vol.volType != VolumeTypeCustom {
 		return fmt.Errorf("Volume type not supported")
 	}
 
+	if _, changed := changedConfig["size"]; !changed {
+		return nil
+	}
+
 	return d.SetVolumeQuota(vol, vol.config["size"], nil)
 }
 
@@ -372,6 +453,16 @@ func (d *btrfs) GetVolumeUsage(vol Volume) (int64, error) {
 
 // SetVolumeQuota sets the quota on the volume.
 func (d *btrfs) SetVolumeQuota(vol Volume, size string, op *operations.Operation) error {
+	// For block volumes, resize the disk image file.
+	if vol.contentType == ContentTypeBlock {
+		rootBlockPath, err := d.GetVolumeDiskPath(vol)
+		if err != nil {
+			return err
+		}
+
+		return ensureVolumeBlockFile(rootBlockPath, size)
+	}
+
 	volPath := vol.MountPath()
 
 	// Convert to bytes.
@@ -451,6 +542,15 @@ func (d *btrfs) SetVolumeQuota(vol Volume, size string, op *operations.Operation
 	return nil
 }
 
+// applyVolumeQuota applies the size limit explicitly set on a filesystem volume, if any.
+func (d *btrfs) applyVolumeQuota(vol Volume, op *operations.Operation) error {
+	if vol.contentType != ContentTypeFS || vol.config["size"] == "" {
+		return nil
+	}
+
+	return d.SetVolumeQuota(vol, vol.config["size"], op)
+}
+
 // GetVolumeDiskPath returns the location and file format of a disk volume.
 func (d *btrfs) GetVolumeDiskPath(vol Volume) (string, error) {
 	return d.vfsGetVolumeDiskPath(vol)
@@ -475,6 +575,11 @@ func (d *btrfs) RenameVolume(vol Volume, newVolName string, op *operations.Opera
 
 // MigrateVolume sends a volume for migration.
 func (d *btrfs) MigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs migration.VolumeSourceArgs, op *operations.Operation) error {
+	// Block volumes are sent as rsync followed by the changed chunks of the disk image.
+	if vol.contentType == ContentTypeBlock && volSrcArgs.MigrationType.FSType == migration.MigrationFSType_BLOCK_AND_RSYNC {
+		return d.vfsMigrateVolume(vol, conn, volSrcArgs, op)
+	}
+
 	if vol.contentType != ContentTypeFS {
 		return fmt.Errorf("Content type not supported")
 	}
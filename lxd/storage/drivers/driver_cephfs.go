@@ -267,6 +267,20 @@ func (d *cephfs) GetResources() (*api.ResourcesStoragePool, error) {
 	return d.vfsGetResources()
 }
 
+// Health returns the health of the storage pool.
+func (d *cephfs) Health() Health {
+	if !shared.IsMountPoint(GetPoolMountPath(d.name)) {
+		return Health{Status: HealthOffline, Message: "Pool isn't mounted"}
+	}
+
+	health := CephHealth(d.config["cephfs.cluster_name"], d.config["cephfs.user.name"])
+	if health.Status != HealthOnline {
+		return health
+	}
+
+	return d.vfsHealth()
+}
+
 // MigrationTypes returns the supported migration types and options supported by the driver.
 func (d *cephfs) MigrationTypes(contentType ContentType, refresh bool) []migration.Type {
 	if contentType != ContentTypeFS {
@@ -277,7 +291,7 @@ func (d *cephfs) MigrationTypes(contentType ContentType, refresh bool) []migrati
 	return []migration.Type{
 		{
 			FSType:   migration.MigrationFSType_RSYNC,
-			Features: []string{"delete", "compress", "bidirectional"},
+			Features: d.rsyncFeatures("delete", "compress", "bidirectional", "zstd"),
 		},
 	}
 }
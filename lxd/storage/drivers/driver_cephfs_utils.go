@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/lxc/lxd/lxd/secrets"
 	"github.com/lxc/lxd/shared"
 )
 
@@ -59,7 +60,17 @@ func (d *cephfs) getConfig(clusterName string, userName string) ([]string, strin
 	// Parse the CEPH keyring.
 	cephKeyring, err := os.Open(fmt.Sprintf("/etc/ceph/%v.client.%v.keyring", clusterName, userName))
 	if err != nil {
-		return nil, "", err
+		// Fallback to the key stored in the secrets backend.
+		if !os.IsNotExist(err) || d.state == nil || d.state.Secrets == nil {
+			return nil, "", err
+		}
+
+		cephSecret, err := secrets.CephKey(d.state.Secrets, clusterName, userName)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return cephMon, cephSecret, nil
 	}
 
 	var cephSecret string
@@ -94,6 +94,15 @@ func (d *common) validateVolume(vol Volume, driverRules map[string]func(value st
 // MigrationType returns the type of transfer methods to be used when doing migrations between pools
 // in preference order.
 func (d *common) MigrationTypes(contentType ContentType, refresh bool) []migration.Type {
+	if contentType == ContentTypeBlock {
+		return []migration.Type{
+			{
+				FSType:   migration.MigrationFSType_BLOCK_AND_RSYNC,
+				Features: d.rsyncFeatures("xattrs", "delete", "compress", "bidirectional", "zstd"),
+			},
+		}
+	}
+
 	if contentType != ContentTypeFS {
 		return nil
 	}
@@ -101,11 +110,29 @@ func (d *common) MigrationTypes(contentType ContentType, refresh bool) []migrati
 	return []migration.Type{
 		{
 			FSType:   migration.MigrationFSType_RSYNC,
-			Features: []string{"xattrs", "delete", "compress", "bidirectional"},
+			Features: d.rsyncFeatures("xattrs", "delete", "compress", "bidirectional", "zstd"),
 		},
 	}
 }
 
+// rsyncFeatures returns the supplied rsync migration features, without the compression ones when
+// it's disabled through the pool's rsync.compression setting. Features are only used when supported
+// by both ends, so either pool can turn compression off.
+func (d *common) rsyncFeatures(features ...string) []string {
+	if d.config["rsync.compression"] == "" || shared.IsTrue(d.config["rsync.compression"]) {
+		return features
+	}
+
+	result := []string{}
+	for _, feature := range features {
+		if !shared.StringInSlice(feature, []string{"compress", "zstd"}) {
+			result = append(result, feature)
+		}
+	}
+
+	return result
+}
+
 // Name returns the pool name.
 func (d *common) Name() string {
 	return d.name
@@ -149,6 +176,22 @@ func (d *common) vfsGetResources() (*api.ResourcesStoragePool, error) {
 	return &res, nil
 }
 
+// vfsHealth is a generic Health implementation for drivers that mount the pool on the host.
+func (d *common) vfsHealth() Health {
+	path := GetPoolMountPath(d.name)
+
+	if !shared.PathExists(path) {
+		return Health{Status: HealthOffline, Message: fmt.Sprintf("Pool path %q doesn't exist", path)}
+	}
+
+	_, err := shared.Statvfs(path)
+	if err != nil {
+		return Health{Status: HealthOffline, Message: fmt.Sprintf("Failed accessing pool path %q: %v", path, err)}
+	}
+
+	return Health{Status: HealthOnline}
+}
+
 // vfsRenameVolume is a generic RenameVolume implementation for VFS-only drivers.
 func (d *common) vfsRenameVolume(vol Volume, newVolName string, op *operations.Operation) error {
 	// Rename the volume itself.
@@ -229,10 +272,31 @@ func (d *common) vfsRenameVolumeSnapshot(snapVol Volume, newSnapshotName string,
 	return nil
 }
 
-// vfsMigrateVolume is a generic MigrateVolume implementation for VFS-only drivers.
+// vfsMigrateVolume is a generic MigrateVolume implementation for VFS-only drivers. Block volumes
+// have their files other than the disk image sent with rsync, followed by the chunks of the disk
+// image which differ from the receiver's copy.
 func (d *common) vfsMigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs migration.VolumeSourceArgs, op *operations.Operation) error {
 	bwlimit := d.config["rsync.bwlimit"]
 
+	sendVolume := func(v Volume, mountPath string, wrapper *ioprogress.ProgressTracker) error {
+		path := shared.AddSlash(mountPath)
+		if v.contentType != ContentTypeBlock {
+			return rsync.Send(v.name, path, conn, wrapper, volSrcArgs.MigrationType.Features, bwlimit, d.state.OS.ExecPath)
+		}
+
+		diskPath, err := d.vfsGetVolumeDiskPath(v)
+		if err != nil {
+			return err
+		}
+
+		err = rsync.Send(v.name, path, conn, wrapper, volSrcArgs.MigrationType.Features, bwlimit, d.state.OS.ExecPath, "/"+filepath.Base(diskPath))
+		if err != nil {
+			return err
+		}
+
+		return migration.SendBlockDelta(conn, diskPath, wrapper)
+	}
+
 	for _, snapName := range volSrcArgs.Snapshots {
 		snapshot, err := vol.NewSnapshot(snapName)
 		if err != nil {
@@ -246,8 +310,7 @@ func (d *common) vfsMigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArg
 				wrapper = migration.ProgressTracker(op, "fs_progress", snapshot.name)
 			}
 
-			path := shared.AddSlash(mountPath)
-			return rsync.Send(snapshot.name, path, conn, wrapper, volSrcArgs.MigrationType.Features, bwlimit, d.state.OS.ExecPath)
+			return sendVolume(snapshot, mountPath, wrapper)
 		}, op)
 		if err != nil {
 			return err
@@ -261,8 +324,7 @@ func (d *common) vfsMigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArg
 			wrapper = migration.ProgressTracker(op, "fs_progress", vol.name)
 		}
 
-		path := shared.AddSlash(mountPath)
-		return rsync.Send(vol.name, path, conn, wrapper, volSrcArgs.MigrationType.Features, bwlimit, d.state.OS.ExecPath)
+		return sendVolume(vol, mountPath, wrapper)
 	}, op)
 }
 
@@ -129,3 +129,18 @@ func (d *dir) Unmount() (bool, error) {
 func (d *dir) GetResources() (*api.ResourcesStoragePool, error) {
 	return d.vfsGetResources()
 }
+
+// Health returns the health of the storage pool.
+func (d *dir) Health() Health {
+	if !shared.PathExists(d.config["source"]) {
+		return Health{Status: HealthOffline, Message: fmt.Sprintf("Source path %q doesn't exist", d.config["source"])}
+	}
+
+	// Check the source is still bind-mounted onto the pool path.
+	path := GetPoolMountPath(d.name)
+	if d.config["source"] != path && !sameMount(d.config["source"], path) {
+		return Health{Status: HealthOffline, Message: fmt.Sprintf("Source path %q isn't mounted", d.config["source"])}
+	}
+
+	return d.vfsHealth()
+}
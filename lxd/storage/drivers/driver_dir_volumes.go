@@ -30,12 +30,37 @@ func (d *dir) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Oper
 
 	// Create sparse loopback file if volume is block.
 	rootBlockPath := ""
+	fillPath := ""
 	if vol.contentType == ContentTypeBlock {
 		// We expect the filler to copy the VM image into this path.
-		rootBlockPath, err = d.GetVolumeDiskPath(vol)
+		rootBlockPath, err = d.vfsGetVolumeDiskPath(vol)
 		if err != nil {
 			return err
 		}
+
+		fillPath = rootBlockPath
+
+		// For encrypted volumes, the filler writes into the unlocked device instead.
+		if luksEnabled(vol) {
+			err = ensureVolumeBlockFile(rootBlockPath, vol.ExpandedConfig("size"))
+			if err != nil {
+				return err
+			}
+
+			err = d.luksFormat(rootBlockPath)
+			if err != nil {
+				return err
+			}
+			revert.Add(func() { d.luksDeleteKey(rootBlockPath) })
+
+			_, err = d.luksOpen(vol, rootBlockPath)
+			if err != nil {
+				return err
+			}
+			defer luksClose(vol)
+
+			fillPath = luksMapperPath(vol)
+		}
 	} else {
 		revertFunc, err := d.setupInitialQuota(vol)
 		if err != nil {
@@ -50,7 +75,7 @@ func (d *dir) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Oper
 	// Run the volume filler function if supplied.
 	if filler != nil && filler.Fill != nil {
 		d.logger.Debug("Running filler function")
-		err = filler.Fill(volPath, rootBlockPath)
+		err = filler.Fill(volPath, fillPath)
 		if err != nil {
 			return err
 		}
@@ -59,7 +84,7 @@ func (d *dir) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Oper
 	// If we are creating a block volume, resize it to the requested size or the default.
 	// We expect the filler function to have converted the qcow2 image to raw into the rootBlockPath.
 	if vol.contentType == ContentTypeBlock {
-		err := ensureVolumeBlockFile(vol, rootBlockPath)
+		err := ensureVolumeBlockFile(rootBlockPath, vol.ExpandedConfig("size"))
 		if err != nil {
 			return err
 		}
@@ -117,12 +142,9 @@ func (d *dir) CreateVolumeFromCopy(vol Volume, srcVol Volume, copySnapshots bool
 
 // CreateVolumeFromMigration creates a volume being sent via a migration.
 func (d *dir) CreateVolumeFromMigration(vol Volume, conn io.ReadWriteCloser, volTargetArgs migration.VolumeTargetArgs, preFiller *VolumeFiller, op *operations.Operation) error {
-	if vol.contentType != ContentTypeFS {
-		return fmt.Errorf("Content type not supported")
-	}
-
-	if volTargetArgs.MigrationType.FSType != migration.MigrationFSType_RSYNC {
-		return fmt.Errorf("Migration type not supported")
+	err := d.checkMigrationType(vol, volTargetArgs.MigrationType)
+	if err != nil {
+		return err
 	}
 
 	return genericCreateVolumeFromMigration(d, d.setupInitialQuota, vol, conn, volTargetArgs, preFiller, op)
@@ -152,6 +174,27 @@ func (d *dir) DeleteVolume(vol Volume, op *operations.Operation) error {
 		return nil
 	}
 
+	// Remove the key of encrypted volumes, their snapshots sharing it are already gone. The
+	// volume config isn't available here so look at the disk image itself.
+	if vol.contentType == ContentTypeBlock {
+		rootBlockPath, err := d.vfsGetVolumeDiskPath(vol)
+		if err != nil {
+			return err
+		}
+
+		if luksIsEncrypted(rootBlockPath) {
+			_, err = luksClose(vol)
+			if err != nil {
+				return err
+			}
+
+			err = d.luksDeleteKey(rootBlockPath)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	// Get the volume ID for the volume, which is used to remove project quota.
 	volID, err := d.getVolID(vol.volType, vol.name)
 	if err != nil {
@@ -187,16 +230,22 @@ func (d *dir) HasVolume(vol Volume) bool {
 
 // ValidateVolume validates the supplied volume config. Optionally removes invalid keys from the volume's config.
 func (d *dir) ValidateVolume(vol Volume, removeUnknownKeys bool) error {
-	return d.validateVolume(vol, nil, removeUnknownKeys)
+	rules := map[string]func(value string) error{
+		// Note: security.encryption should not be modifiable after volume created. This is
+		// checked in the relevant volume update functions.
+		"security.encryption": shared.IsBool,
+	}
+
+	return d.validateVolume(vol, rules, removeUnknownKeys)
 }
 
 // UpdateVolume applies config changes to the volume.
 func (d *dir) UpdateVolume(vol Volume, changedConfig map[string]string) error {
-	if vol.contentType != ContentTypeFS {
-		return fmt.Errorf("Content type not supported")
-	}
-
 	if _, changed := changedConfig["size"]; changed {
+		if vol.contentType == ContentTypeBlock {
+			return d.SetVolumeQuota(vol, changedConfig["size"], nil)
+		}
+
 		volID, err := d.getVolID(vol.volType, vol.name)
 		if err != nil {
 			return err
@@ -239,6 +288,17 @@ func (d *dir) GetVolumeUsage(vol Volume) (int64, error) {
 
 // SetVolumeQuota sets the quota on the volume.
 func (d *dir) SetVolumeQuota(vol Volume, size string, op *operations.Operation) error {
+	// For block volumes, resize the disk image file. Unlocked encrypted volumes pick up the new
+	// size the next time they're unlocked.
+	if vol.contentType == ContentTypeBlock {
+		rootBlockPath, err := d.vfsGetVolumeDiskPath(vol)
+		if err != nil {
+			return err
+		}
+
+		return ensureVolumeBlockFile(rootBlockPath, size)
+	}
+
 	volPath := vol.MountPath()
 
 	volID, err := d.getVolID(vol.volType, vol.name)
@@ -249,20 +309,40 @@ func (d *dir) SetVolumeQuota(vol Volume, size string, op *operations.Operation)
 	return d.setQuota(volPath, volID, size)
 }
 
-// GetVolumeDiskPath returns the location of a disk volume.
+// GetVolumeDiskPath returns the location of a disk volume. For encrypted volumes, this is the
+// unlocked device.
 func (d *dir) GetVolumeDiskPath(vol Volume) (string, error) {
+	if luksEnabled(vol) {
+		return luksMapperPath(vol), nil
+	}
+
 	return d.vfsGetVolumeDiskPath(vol)
 }
 
 // MountVolume simulates mounting a volume. As dir driver doesn't have volumes to mount it returns
-// false indicating that there is no need to issue an unmount.
+// false indicating that there is no need to issue an unmount. Encrypted block volumes are unlocked
+// instead.
 func (d *dir) MountVolume(vol Volume, op *operations.Operation) (bool, error) {
+	if luksEnabled(vol) {
+		rootBlockPath, err := d.vfsGetVolumeDiskPath(vol)
+		if err != nil {
+			return false, err
+		}
+
+		return d.luksOpen(vol, rootBlockPath)
+	}
+
 	return false, nil
 }
 
 // UnmountVolume simulates unmounting a volume. As dir driver doesn't have volumes to unmount it
-// returns false indicating the volume was already unmounted.
+// returns false indicating the volume was already unmounted. Encrypted block volumes are locked
+// again instead.
 func (d *dir) UnmountVolume(vol Volume, op *operations.Operation) (bool, error) {
+	if luksEnabled(vol) {
+		return luksClose(vol)
+	}
+
 	return false, nil
 }
 
@@ -273,15 +353,36 @@ func (d *dir) RenameVolume(vol Volume, newVolName string, op *operations.Operati
 
 // MigrateVolume sends a volume for migration.
 func (d *dir) MigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs migration.VolumeSourceArgs, op *operations.Operation) error {
-	if vol.contentType != ContentTypeFS {
-		return fmt.Errorf("Content type not supported")
+	err := d.checkMigrationType(vol, volSrcArgs.MigrationType)
+	if err != nil {
+		return err
 	}
 
-	if volSrcArgs.MigrationType.FSType != migration.MigrationFSType_RSYNC {
-		return fmt.Errorf("Migration type not supported")
+	return d.vfsMigrateVolume(vol, conn, volSrcArgs, op)
+}
+
+// checkMigrationType checks that the volume can be migrated with the given migration type.
+// Filesystem volumes use rsync and block volumes rsync followed by the disk image chunks.
+// Encrypted volumes can't be migrated as the key can't be carried over.
+func (d *dir) checkMigrationType(vol Volume, migrationType migration.Type) error {
+	switch vol.contentType {
+	case ContentTypeFS:
+		if migrationType.FSType != migration.MigrationFSType_RSYNC {
+			return fmt.Errorf("Migration type not supported")
+		}
+	case ContentTypeBlock:
+		if migrationType.FSType != migration.MigrationFSType_BLOCK_AND_RSYNC {
+			return fmt.Errorf("Migration type not supported")
+		}
+
+		if luksEnabled(vol) {
+			return fmt.Errorf("Migration of encrypted volumes isn't supported")
+		}
+	default:
+		return fmt.Errorf("Content type not supported")
 	}
 
-	return d.vfsMigrateVolume(vol, conn, volSrcArgs, op)
+	return nil
 }
 
 // BackupVolume copies a volume (and optionally its snapshots) to a specified target path.
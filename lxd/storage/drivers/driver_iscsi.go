@@ -0,0 +1,220 @@
+package drivers
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/migration"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var iscsiVersion string
+var iscsiLoaded bool
+
+type iscsi struct {
+	common
+}
+
+// load is used to run one-time action per-driver rather than per-pool.
+func (d *iscsi) load() error {
+	if iscsiLoaded {
+		return nil
+	}
+
+	// Validate the required binaries.
+	for _, tool := range []string{"iscsiadm", "blockdev"} {
+		_, err := exec.LookPath(tool)
+		if err != nil {
+			return fmt.Errorf("Required tool '%s' is missing", tool)
+		}
+	}
+
+	// Detect and record the version.
+	if iscsiVersion == "" {
+		out, err := shared.RunCommand("iscsiadm", "--version")
+		if err != nil {
+			return err
+		}
+
+		iscsiVersion = strings.TrimPrefix(strings.TrimSpace(out), "iscsiadm version ")
+	}
+
+	iscsiLoaded = true
+	return nil
+}
+
+// Info returns the pool driver information.
+func (d *iscsi) Info() Info {
+	return Info{
+		Name:                  "iscsi",
+		Version:               iscsiVersion,
+		OptimizedImages:       false,
+		PreservesInodes:       false,
+		Remote:                true,
+		VolumeTypes:           []VolumeType{VolumeTypeCustom, VolumeTypeContainer},
+		BlockBacking:          true,
+		RunningQuotaResize:    false,
+		RunningSnapshotFreeze: true,
+	}
+}
+
+// Create is called during pool creation and is effectively using an empty driver struct.
+// WARNING: The Create() function cannot rely on any of the struct attributes being set.
+func (d *iscsi) Create() error {
+	// Config validation.
+	if d.config["source"] == "" {
+		return fmt.Errorf("Missing required source portals")
+	}
+
+	err := d.Validate(d.config)
+	if err != nil {
+		return err
+	}
+
+	// Set default properties if missing.
+	if d.config["iscsi.target.path"] == "" {
+		d.config["iscsi.target.path"] = fmt.Sprintf("/var/lib/lxd-iscsi/%s", d.name)
+	}
+
+	if d.config["iscsi.target.iqn"] == "" {
+		d.config["iscsi.target.iqn"] = fmt.Sprintf("iqn.2020-03.org.linuxcontainers.lxd:%s", iscsiEscape(d.name))
+	}
+
+	// Check that the target is reachable and manageable.
+	_, err = d.targetRun("targetcli", "ls", "/iscsi", "1")
+	if err != nil {
+		return fmt.Errorf("Failed to reach targetcli on the iSCSI target: %v", err)
+	}
+
+	// Create the directory holding the backing files of the volumes.
+	targetPath := d.config["iscsi.target.path"]
+	_, err = d.targetRun("mkdir", "-p", targetPath)
+	if err != nil {
+		return fmt.Errorf("Failed to create %q on the iSCSI target: %v", targetPath, err)
+	}
+
+	// Check that the existing path is empty.
+	out, err := d.targetRun("ls", "-A", targetPath)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(out) != "" {
+		return fmt.Errorf("Only empty target paths can be used as a LXD storage pool")
+	}
+
+	return nil
+}
+
+// Delete removes the storage pool from the storage device.
+func (d *iscsi) Delete(op *operations.Operation) error {
+	// On delete, wipe everything in the directory.
+	err := wipeDirectory(GetPoolMountPath(d.name))
+	if err != nil {
+		return err
+	}
+
+	// Remove the directory of the backing files, volumes left behind make this fail.
+	targetPath := d.config["iscsi.target.path"]
+	_, err = d.targetRun("rm", "-d", "-f", targetPath)
+	if err != nil {
+		return fmt.Errorf("Failed to remove %q on the iSCSI target: %v", targetPath, err)
+	}
+
+	return nil
+}
+
+// Validate checks that all provide keys are supported and that no conflicting or missing configuration is present.
+func (d *iscsi) Validate(config map[string]string) error {
+	if config["source"] != "" {
+		_, err := iscsiParsePortals(config["source"])
+		if err != nil {
+			return err
+		}
+	}
+
+	if config["iscsi.multipath"] != "" {
+		err := shared.IsBool(config["iscsi.multipath"])
+		if err != nil {
+			return fmt.Errorf("Invalid value for iscsi.multipath: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Update applies any driver changes required from a configuration change.
+func (d *iscsi) Update(changedConfig map[string]string) error {
+	return nil
+}
+
+// Mount is a no-op as the volumes are attached individually.
+func (d *iscsi) Mount() (bool, error) {
+	return false, nil
+}
+
+// Unmount is a no-op as the volumes are detached individually.
+func (d *iscsi) Unmount() (bool, error) {
+	return false, nil
+}
+
+// GetResources returns the pool resource usage information, which is the usage of the filesystem
+// holding the backing files on the target.
+func (d *iscsi) GetResources() (*api.ResourcesStoragePool, error) {
+	out, err := d.targetRun("df", "-B1", "--output=size,used", d.config["iscsi.target.path"])
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("Unexpected output of df on the iSCSI target: %q", out)
+	}
+
+	total, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	res := api.ResourcesStoragePool{}
+	res.Space.Total = total
+	res.Space.Used = used
+
+	return &res, nil
+}
+
+// Health returns the health of the storage pool.
+func (d *iscsi) Health() Health {
+	targetPath := d.config["iscsi.target.path"]
+
+	_, err := d.targetRun("test", "-d", targetPath)
+	if err != nil {
+		return Health{Status: HealthOffline, Message: fmt.Sprintf("Target path %q isn't reachable: %v", targetPath, err)}
+	}
+
+	return Health{Status: HealthOnline}
+}
+
+// MigrationTypes returns the supported migration types and options supported by the driver.
+func (d *iscsi) MigrationTypes(contentType ContentType, refresh bool) []migration.Type {
+	if contentType != ContentTypeFS {
+		return nil
+	}
+
+	return []migration.Type{
+		{
+			FSType:   migration.MigrationFSType_RSYNC,
+			Features: d.rsyncFeatures("xattrs", "delete", "compress", "bidirectional", "zstd"),
+		},
+	}
+}
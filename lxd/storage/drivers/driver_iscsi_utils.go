@@ -0,0 +1,489 @@
+package drivers
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/shared"
+)
+
+// iscsiDefaultPort is the port used for portals which don't specify one.
+const iscsiDefaultPort = "3260"
+
+// iscsiParsePortals parses a comma separated list of <address>[:<port>] portals.
+func iscsiParsePortals(source string) ([]string, error) {
+	portals := []string{}
+
+	for _, entry := range strings.Split(source, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		host, port, err := net.SplitHostPort(entry)
+		if err != nil {
+			host = strings.Trim(entry, "[]")
+			port = iscsiDefaultPort
+		}
+
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("Invalid iSCSI portal %q, must be an IP address with an optional port", entry)
+		}
+
+		_, err = strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid port of iSCSI portal %q", entry)
+		}
+
+		portals = append(portals, net.JoinHostPort(host, port))
+	}
+
+	if len(portals) == 0 {
+		return nil, fmt.Errorf("No iSCSI portal in %q", source)
+	}
+
+	return portals, nil
+}
+
+// iscsiEscape escapes a name for use in target names, keeping lowercase letters, digits and dashes
+// and encoding everything else as a dot followed by the hexadecimal value of the byte.
+func iscsiEscape(name string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' {
+			b.WriteByte(c)
+			continue
+		}
+
+		fmt.Fprintf(&b, ".%02x", c)
+	}
+
+	return b.String()
+}
+
+// iscsiUnescape reverses iscsiEscape.
+func iscsiUnescape(name string) (string, error) {
+	var b strings.Builder
+
+	for i := 0; i < len(name); i++ {
+		if name[i] != '.' {
+			b.WriteByte(name[i])
+			continue
+		}
+
+		if i+2 >= len(name) {
+			return "", fmt.Errorf("Invalid escaped name %q", name)
+		}
+
+		c, err := strconv.ParseUint(name[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("Invalid escaped name %q", name)
+		}
+
+		b.WriteByte(byte(c))
+		i += 2
+	}
+
+	return b.String(), nil
+}
+
+// lunName returns the name of the backstore and the suffix of the target of the volume. Snapshots
+// are separated from their parent with two dots, which the escaping never produces.
+func (d *iscsi) lunName(vol Volume) string {
+	prefix := "custom"
+	if vol.volType == VolumeTypeContainer {
+		prefix = "container"
+	}
+
+	parentName, snapName, isSnap := shared.InstanceGetParentAndSnapshotName(vol.name)
+	if !isSnap {
+		return fmt.Sprintf("%s-%s", prefix, iscsiEscape(vol.name))
+	}
+
+	return fmt.Sprintf("%s-%s..%s", prefix, iscsiEscape(parentName), iscsiEscape(snapName))
+}
+
+// volumeIQN returns the IQN of the target exporting the volume.
+func (d *iscsi) volumeIQN(vol Volume) string {
+	return fmt.Sprintf("%s:%s", d.config["iscsi.target.iqn"], d.lunName(vol))
+}
+
+// volumeFile returns the path of the backing file of the volume on the target.
+func (d *iscsi) volumeFile(vol Volume) string {
+	return filepath.Join(d.config["iscsi.target.path"], fmt.Sprintf("%s.img", d.lunName(vol)))
+}
+
+// targetRun runs a command on the target, through SSH if iscsi.target.host is set.
+func (d *iscsi) targetRun(args ...string) (string, error) {
+	host := d.config["iscsi.target.host"]
+	if host == "" {
+		return shared.RunCommand(args[0], args[1:]...)
+	}
+
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, fmt.Sprintf("'%s'", strings.Replace(arg, "'", `'\''`, -1)))
+	}
+
+	return shared.RunCommand("ssh", "-o", "BatchMode=yes", host, "--", strings.Join(quoted, " "))
+}
+
+// targetHas returns true if the path exists in the targetcli configuration tree.
+func (d *iscsi) targetHas(path string) bool {
+	_, err := d.targetRun("targetcli", "ls", path, "1")
+	return err == nil
+}
+
+// initiatorName returns the IQN of the local initiator.
+func (d *iscsi) initiatorName() (string, error) {
+	f, err := os.Open("/etc/iscsi/initiatorname.iscsi")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		if strings.HasPrefix(line, "InitiatorName=") {
+			return strings.TrimPrefix(line, "InitiatorName="), nil
+		}
+	}
+
+	return "", fmt.Errorf("Couldn't find the initiator name in /etc/iscsi/initiatorname.iscsi")
+}
+
+// exportVolume exports the backing file of the volume as the only LUN of its own target, with
+// access granted to the local initiator. Parts which are already set up are left alone.
+func (d *iscsi) exportVolume(vol Volume) error {
+	lun := d.lunName(vol)
+	iqn := d.volumeIQN(vol)
+
+	initiator, err := d.initiatorName()
+	if err != nil {
+		return err
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	// Only remove the export on failure if it was created here, another member may be using it.
+	created := false
+	revert.Add(func() {
+		if created {
+			d.unexportVolume(vol)
+		}
+	})
+
+	changed := false
+
+	if !d.targetHas(fmt.Sprintf("/backstores/fileio/%s", lun)) {
+		_, err = d.targetRun("targetcli", "/backstores/fileio", "create", fmt.Sprintf("name=%s", lun), fmt.Sprintf("file_or_dev=%s", d.volumeFile(vol)))
+		if err != nil {
+			return fmt.Errorf("Failed to create backstore %q: %v", lun, err)
+		}
+
+		created = true
+		changed = true
+	}
+
+	if !d.targetHas(fmt.Sprintf("/iscsi/%s", iqn)) {
+		_, err = d.targetRun("targetcli", "/iscsi", "create", iqn)
+		if err != nil {
+			return fmt.Errorf("Failed to create target %q: %v", iqn, err)
+		}
+
+		created = true
+
+		_, err = d.targetRun("targetcli", fmt.Sprintf("/iscsi/%s/tpg1/luns", iqn), "create", fmt.Sprintf("/backstores/fileio/%s", lun))
+		if err != nil {
+			return fmt.Errorf("Failed to create LUN of target %q: %v", iqn, err)
+		}
+
+		changed = true
+	}
+
+	if !d.targetHas(fmt.Sprintf("/iscsi/%s/tpg1/acls/%s", iqn, initiator)) {
+		_, err = d.targetRun("targetcli", fmt.Sprintf("/iscsi/%s/tpg1/acls", iqn), "create", initiator)
+		if err != nil {
+			return fmt.Errorf("Failed to grant %q access to target %q: %v", initiator, iqn, err)
+		}
+
+		changed = true
+	}
+
+	if changed {
+		_, err = d.targetRun("targetcli", "saveconfig")
+		if err != nil {
+			return err
+		}
+	}
+
+	revert.Success()
+	return nil
+}
+
+// unexportVolume removes the target and backstore of the volume. This drops the sessions of all
+// the initiators, so it must only be used on detached volumes.
+func (d *iscsi) unexportVolume(vol Volume) error {
+	lun := d.lunName(vol)
+	iqn := d.volumeIQN(vol)
+
+	if d.hasSessions(vol) {
+		return fmt.Errorf("Volume %q is still attached by another cluster member", vol.name)
+	}
+
+	if d.targetHas(fmt.Sprintf("/iscsi/%s", iqn)) {
+		_, err := d.targetRun("targetcli", "/iscsi", "delete", iqn)
+		if err != nil {
+			return fmt.Errorf("Failed to delete target %q: %v", iqn, err)
+		}
+	}
+
+	if d.targetHas(fmt.Sprintf("/backstores/fileio/%s", lun)) {
+		_, err := d.targetRun("targetcli", "/backstores/fileio", "delete", lun)
+		if err != nil {
+			return fmt.Errorf("Failed to delete backstore %q: %v", lun, err)
+		}
+	}
+
+	_, err := d.targetRun("targetcli", "saveconfig")
+	return err
+}
+
+// hasSessions returns true if an initiator is logged into the target of the volume.
+func (d *iscsi) hasSessions(vol Volume) bool {
+	// The info of the ACLs starts with the name of the initiator of an active session, and with
+	// "No active iSCSI Session" otherwise.
+	out, err := d.targetRun("sh", "-c", fmt.Sprintf("cat /sys/kernel/config/target/iscsi/%s/tpgt_1/acls/*/info", d.volumeIQN(vol)))
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(out, "InitiatorName:")
+}
+
+// multipath returns true if the volumes should be attached through all the portals and combined
+// with dm-multipath.
+func (d *iscsi) multipath(portals []string) bool {
+	if len(portals) < 2 {
+		return false
+	}
+
+	return d.config["iscsi.multipath"] == "" || shared.IsTrue(d.config["iscsi.multipath"])
+}
+
+// pathDevice returns the path of the device of the volume attached through the portal.
+func (d *iscsi) pathDevice(vol Volume, portal string) string {
+	return fmt.Sprintf("/dev/disk/by-path/ip-%s-iscsi-%s-lun-0", portal, d.volumeIQN(vol))
+}
+
+// multipathDevice returns the path of the multipath device combining the paths of the volume,
+// empty if there is none.
+func (d *iscsi) multipathDevice(vol Volume, portals []string) string {
+	for _, portal := range portals {
+		pathDev := d.pathDevice(vol, portal)
+		if !shared.PathExists(pathDev) {
+			continue
+		}
+
+		wwid, err := shared.RunCommand("/lib/udev/scsi_id", "-g", "-u", "-d", pathDev)
+		if err != nil {
+			continue
+		}
+
+		mpathDev := fmt.Sprintf("/dev/disk/by-id/dm-uuid-mpath-%s", strings.TrimSpace(wwid))
+		if shared.PathExists(mpathDev) {
+			return mpathDev
+		}
+	}
+
+	return ""
+}
+
+// volumeDevice returns the path of the device of the attached volume, empty if it isn't attached.
+func (d *iscsi) volumeDevice(vol Volume) (string, error) {
+	portals, err := iscsiParsePortals(d.config["source"])
+	if err != nil {
+		return "", err
+	}
+
+	if d.multipath(portals) {
+		mpathDev := d.multipathDevice(vol, portals)
+		if mpathDev != "" {
+			return mpathDev, nil
+		}
+	}
+
+	for _, portal := range portals {
+		pathDev := d.pathDevice(vol, portal)
+		if shared.PathExists(pathDev) {
+			return pathDev, nil
+		}
+	}
+
+	return "", nil
+}
+
+// attachVolume logs into the target of the volume and returns the path of its device. When using
+// multipath, all the portals are logged into and the multipath device is returned.
+func (d *iscsi) attachVolume(vol Volume) (string, error) {
+	portals, err := iscsiParsePortals(d.config["source"])
+	if err != nil {
+		return "", err
+	}
+
+	useMultipath := d.multipath(portals)
+	if useMultipath {
+		_, err := exec.LookPath("multipath")
+		if err != nil {
+			return "", fmt.Errorf("Required tool '%s' is missing", "multipath")
+		}
+	} else {
+		portals = portals[:1]
+	}
+
+	err = d.exportVolume(vol)
+	if err != nil {
+		return "", err
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	revert.Add(func() { d.detachVolume(vol) })
+
+	iqn := d.volumeIQN(vol)
+	pathDevs := []string{}
+
+	for _, portal := range portals {
+		pathDev := d.pathDevice(vol, portal)
+
+		if !shared.PathExists(pathDev) {
+			_, err = shared.RunCommand("iscsiadm", "-m", "node", "-T", iqn, "-p", portal, "-o", "new")
+			if err != nil {
+				return "", fmt.Errorf("Failed to add iSCSI node %q through %s: %v", iqn, portal, err)
+			}
+
+			_, err = shared.RunCommand("iscsiadm", "-m", "node", "-T", iqn, "-p", portal, "--login")
+			if err != nil {
+				return "", fmt.Errorf("Failed to log into iSCSI target %q through %s: %v", iqn, portal, err)
+			}
+
+			if !tryExists(pathDev) {
+				return "", fmt.Errorf("Device of iSCSI target %q through %s didn't show up", iqn, portal)
+			}
+		}
+
+		pathDevs = append(pathDevs, pathDev)
+	}
+
+	if !useMultipath {
+		revert.Success()
+		return pathDevs[0], nil
+	}
+
+	wwid, err := shared.RunCommand("/lib/udev/scsi_id", "-g", "-u", "-d", pathDevs[0])
+	if err != nil {
+		return "", fmt.Errorf("Failed to get the WWID of %s: %v", pathDevs[0], err)
+	}
+
+	mpathDev := fmt.Sprintf("/dev/disk/by-id/dm-uuid-mpath-%s", strings.TrimSpace(wwid))
+	if !shared.PathExists(mpathDev) {
+		_, err = shared.RunCommand("multipath", pathDevs[0])
+		if err != nil {
+			return "", fmt.Errorf("Failed to set up multipath device for %s: %v", pathDevs[0], err)
+		}
+
+		if !tryExists(mpathDev) {
+			return "", fmt.Errorf("Multipath device of iSCSI target %q didn't show up", iqn)
+		}
+	}
+
+	revert.Success()
+	return mpathDev, nil
+}
+
+// detachVolume flushes the multipath device of the volume if any, and logs out of its target.
+func (d *iscsi) detachVolume(vol Volume) error {
+	portals, err := iscsiParsePortals(d.config["source"])
+	if err != nil {
+		return err
+	}
+
+	iqn := d.volumeIQN(vol)
+
+	mpathDev := d.multipathDevice(vol, portals)
+	if mpathDev != "" {
+		dmPath, err := filepath.EvalSymlinks(mpathDev)
+		if err != nil {
+			return err
+		}
+
+		dmName, err := ioutil.ReadFile(fmt.Sprintf("/sys/block/%s/dm/name", filepath.Base(dmPath)))
+		if err != nil {
+			return err
+		}
+
+		_, err = shared.RunCommand("blockdev", "--flushbufs", mpathDev)
+		if err != nil {
+			return err
+		}
+
+		_, err = shared.RunCommand("multipath", "-f", strings.TrimSpace(string(dmName)))
+		if err != nil {
+			return fmt.Errorf("Failed to remove multipath device %s: %v", mpathDev, err)
+		}
+	}
+
+	for _, portal := range portals {
+		pathDev := d.pathDevice(vol, portal)
+
+		if shared.PathExists(pathDev) {
+			_, err = shared.RunCommand("blockdev", "--flushbufs", pathDev)
+			if err != nil {
+				return err
+			}
+
+			_, err = shared.RunCommand("iscsiadm", "-m", "node", "-T", iqn, "-p", portal, "--logout")
+			if err != nil {
+				return fmt.Errorf("Failed to log out of iSCSI target %q through %s: %v", iqn, portal, err)
+			}
+		}
+
+		// Forget about the node, it doesn't exist if it was never logged into through this portal.
+		shared.RunCommand("iscsiadm", "-m", "node", "-T", iqn, "-p", portal, "-o", "delete")
+	}
+
+	return nil
+}
+
+// volumeFilesystem returns the filesystem of the attached volume.
+func (d *iscsi) volumeFilesystem(devPath string) (string, error) {
+	fsType, err := shared.RunCommand("blkid", "-s", "TYPE", "-o", "value", devPath)
+	if err != nil {
+		return "", fmt.Errorf("Failed to detect the filesystem of %s: %v", devPath, err)
+	}
+
+	return strings.TrimSpace(fsType), nil
+}
+
+// volumeSize returns the size of the backing file of the volume.
+func (d *iscsi) volumeSize(vol Volume) (int64, error) {
+	out, err := d.targetRun("stat", "-c", "%s", d.volumeFile(vol))
+	if err != nil {
+		return -1, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+}
@@ -0,0 +1,40 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test iscsiEscape and iscsiUnescape
+func TestISCSIEscape(t *testing.T) {
+	for _, name := range []string{"c1", "default_c1", "Foo.bar", "snap 0", ""} {
+		escaped := iscsiEscape(name)
+		assert.Regexp(t, "^[a-z0-9.-]*$", escaped)
+
+		unescaped, err := iscsiUnescape(escaped)
+		assert.NoError(t, err)
+		assert.Equal(t, name, unescaped)
+	}
+
+	assert.Equal(t, "default.5fc1", iscsiEscape("default_c1"))
+
+	_, err := iscsiUnescape("c1.5")
+	assert.Error(t, err)
+}
+
+// Test iscsiParsePortals
+func TestISCSIParsePortals(t *testing.T) {
+	portals, err := iscsiParsePortals("192.0.2.10, 192.0.2.11:3261,[2001:db8::1],2001:db8::2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"192.0.2.10:3260", "192.0.2.11:3261", "[2001:db8::1]:3260", "[2001:db8::2]:3260"}, portals)
+
+	_, err = iscsiParsePortals("san1:3260")
+	assert.Error(t, err)
+
+	_, err = iscsiParsePortals("192.0.2.10:99999")
+	assert.Error(t, err)
+
+	_, err = iscsiParsePortals(",")
+	assert.Error(t, err)
+}
@@ -0,0 +1,679 @@
+package drivers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/migration"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/units"
+)
+
+// CreateVolume creates a new storage volume on the target, formats it and can optionally fill it
+// by executing the supplied filler function.
+func (d *iscsi) CreateVolume(vol Volume, filler *VolumeFiller, op *operations.Operation) error {
+	if vol.volType != VolumeTypeContainer && vol.volType != VolumeTypeCustom {
+		return fmt.Errorf("Volume type not supported")
+	}
+
+	if vol.contentType != ContentTypeFS {
+		return fmt.Errorf("Content type not supported")
+	}
+
+	size := vol.ExpandedConfig("size")
+	if size == "" {
+		size = defaultBlockSize
+	}
+
+	sizeBytes, err := units.ParseByteSizeString(size)
+	if err != nil {
+		return err
+	}
+
+	volFile := d.volumeFile(vol)
+	if d.HasVolume(vol) {
+		return fmt.Errorf("Backing file %q already exists on the iSCSI target", volFile)
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	// Create the sparse backing file on the target.
+	_, err = d.targetRun("truncate", "-s", fmt.Sprintf("%d", sizeBytes), volFile)
+	if err != nil {
+		return fmt.Errorf("Failed to create backing file %q on the iSCSI target: %v", volFile, err)
+	}
+	revert.Add(func() { d.targetRun("rm", "-f", volFile) })
+	revert.Add(func() { d.unexportVolume(vol) })
+
+	volPath := vol.MountPath()
+	err = vol.EnsureMountPath()
+	if err != nil {
+		return err
+	}
+	revert.Add(func() { os.RemoveAll(volPath) })
+
+	// Format the volume.
+	devPath, err := d.attachVolume(vol)
+	if err != nil {
+		return err
+	}
+
+	fsType := BlockFilesystem(vol.config, vol.poolConfig)
+
+	msg, err := MakeFSType(devPath, fsType, nil)
+	if err != nil {
+		d.detachVolume(vol)
+		return fmt.Errorf("Failed to create the %s filesystem: %v (%s)", fsType, err, msg)
+	}
+
+	err = d.detachVolume(vol)
+	if err != nil {
+		return err
+	}
+
+	// Run the volume filler function if supplied.
+	if filler != nil && filler.Fill != nil {
+		err = vol.MountTask(func(mountPath string, op *operations.Operation) error {
+			d.logger.Debug("Running filler function")
+			return filler.Fill(mountPath, "")
+		}, op)
+		if err != nil {
+			return err
+		}
+	}
+
+	revert.Success()
+	return nil
+}
+
+// CreateVolumeFromBackup re-creates a volume from its exported state.
+func (d *iscsi) CreateVolumeFromBackup(vol Volume, snapshots []string, srcData io.ReadSeeker, optimizedStorage bool, op *operations.Operation) (func(vol Volume) error, func(), error) {
+	return nil, nil, ErrNotImplemented
+}
+
+// CreateVolumeFromCopy provides same-pool volume copying functionality.
+func (d *iscsi) CreateVolumeFromCopy(vol Volume, srcVol Volume, copySnapshots bool, op *operations.Operation) error {
+	var err error
+	var srcSnapshots []Volume
+
+	if copySnapshots && !srcVol.IsSnapshot() {
+		// Get the list of snapshots from the source.
+		srcSnapshots, err = srcVol.Snapshots(op)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The copy gets a new filesystem of its own, so the copies don't share a filesystem UUID.
+	err = d.CreateVolume(vol, nil, op)
+	if err != nil {
+		return err
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	revert.Add(func() { d.DeleteVolume(vol, op) })
+
+	// Run the generic copy.
+	err = genericCopyVolume(d, nil, vol, srcVol, srcSnapshots, op)
+	if err != nil {
+		return err
+	}
+
+	revert.Success()
+	return nil
+}
+
+// CreateVolumeFromMigration creates a volume being sent via a migration.
+func (d *iscsi) CreateVolumeFromMigration(vol Volume, conn io.ReadWriteCloser, volTargetArgs migration.VolumeTargetArgs, preFiller *VolumeFiller, op *operations.Operation) error {
+	err := d.checkMigrationType(vol, volTargetArgs.MigrationType)
+	if err != nil {
+		return err
+	}
+
+	return genericCreateVolumeFromMigration(d, nil, vol, conn, volTargetArgs, preFiller, op)
+}
+
+// RefreshVolume provides same-pool volume and specific snapshots syncing functionality.
+func (d *iscsi) RefreshVolume(vol Volume, srcVol Volume, srcSnapshots []Volume, op *operations.Operation) error {
+	return genericCopyVolume(d, nil, vol, srcVol, srcSnapshots, op)
+}
+
+// DeleteVolume deletes a volume of the storage device. If any snapshots of the volume remain then
+// this function will return an error.
+func (d *iscsi) DeleteVolume(vol Volume, op *operations.Operation) error {
+	snapshots, err := d.VolumeSnapshots(vol, op)
+	if err != nil {
+		return err
+	}
+
+	if len(snapshots) > 0 {
+		return fmt.Errorf("Cannot remove a volume that has snapshots")
+	}
+
+	_, err = d.UnmountVolume(vol, op)
+	if err != nil {
+		return err
+	}
+
+	err = d.unexportVolume(vol)
+	if err != nil {
+		return err
+	}
+
+	volFile := d.volumeFile(vol)
+	_, err = d.targetRun("rm", "-f", volFile)
+	if err != nil {
+		return fmt.Errorf("Failed to remove backing file %q on the iSCSI target: %v", volFile, err)
+	}
+
+	err = os.RemoveAll(vol.MountPath())
+	if err != nil {
+		return err
+	}
+
+	// Although the volume snapshot directory should already be removed, lets remove it here
+	// to just in case the top-level directory is left.
+	err = deleteParentSnapshotDirIfEmpty(d.name, vol.volType, vol.name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// HasVolume indicates whether a specific volume exists on the storage pool.
+func (d *iscsi) HasVolume(vol Volume) bool {
+	_, err := d.targetRun("test", "-f", d.volumeFile(vol))
+	return err == nil
+}
+
+// ValidateVolume validates the supplied volume config. Optionally removes invalid keys from the volume's config.
+func (d *iscsi) ValidateVolume(vol Volume, removeUnknownKeys bool) error {
+	rules := map[string]func(value string) error{
+		"block.filesystem": func(value string) error {
+			return ValidateBlockFilesystem("iscsi", value)
+		},
+	}
+
+	return d.validateVolume(vol, rules, removeUnknownKeys)
+}
+
+// UpdateVolume applies config changes to the volume.
+func (d *iscsi) UpdateVolume(vol Volume, changedConfig map[string]string) error {
+	if _, changed := changedConfig["size"]; changed {
+		return d.SetVolumeQuota(vol, changedConfig["size"], nil)
+	}
+
+	return nil
+}
+
+// GetVolumeUsage returns the disk space used by the volume. This is the usage of the filesystem
+// when mounted and the space allocated to the backing file on the target otherwise.
+func (d *iscsi) GetVolumeUsage(vol Volume) (int64, error) {
+	volPath := vol.MountPath()
+	if shared.IsMountPoint(volPath) {
+		st, err := shared.Statvfs(volPath)
+		if err != nil {
+			return -1, err
+		}
+
+		return int64((st.Blocks - st.Bfree) * uint64(st.Bsize)), nil
+	}
+
+	out, err := d.targetRun("du", "-B1", d.volumeFile(vol))
+	if err != nil {
+		return -1, err
+	}
+
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return -1, fmt.Errorf("Unexpected output of du on the iSCSI target: %q", out)
+	}
+
+	return strconv.ParseInt(fields[0], 10, 64)
+}
+
+// SetVolumeQuota grows the backing file of the unmounted volume and its filesystem.
+func (d *iscsi) SetVolumeQuota(vol Volume, size string, op *operations.Operation) error {
+	// Volumes always have a size, so there is no quota to remove.
+	if size == "" {
+		return nil
+	}
+
+	sizeBytes, err := units.ParseByteSizeString(size)
+	if err != nil {
+		return err
+	}
+
+	oldSizeBytes, err := d.volumeSize(vol)
+	if err != nil {
+		return err
+	}
+
+	if sizeBytes == oldSizeBytes {
+		return nil
+	}
+
+	if sizeBytes < oldSizeBytes {
+		return fmt.Errorf("Volumes cannot be shrunk as LXD can't check that their content fits in the new size")
+	}
+
+	if shared.IsMountPoint(vol.MountPath()) {
+		return fmt.Errorf("Volume must be unmounted to be resized")
+	}
+
+	// The backstore only picks up the new size when re-created.
+	err = d.unexportVolume(vol)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.targetRun("truncate", "-s", fmt.Sprintf("%d", sizeBytes), d.volumeFile(vol))
+	if err != nil {
+		return err
+	}
+
+	return vol.MountTask(func(mountPath string, op *operations.Operation) error {
+		devPath, err := d.volumeDevice(vol)
+		if err != nil {
+			return err
+		}
+
+		fsType, err := d.volumeFilesystem(devPath)
+		if err != nil {
+			return err
+		}
+
+		switch fsType {
+		case "xfs":
+			_, err = shared.RunCommand("xfs_growfs", mountPath)
+		default:
+			_, err = shared.RunCommand("resize2fs", devPath)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to grow the %s filesystem: %v", fsType, err)
+		}
+
+		return nil
+	}, op)
+}
+
+// GetVolumeDiskPath returns the location of a disk volume.
+func (d *iscsi) GetVolumeDiskPath(vol Volume) (string, error) {
+	return "", ErrNotImplemented
+}
+
+// MountVolume attaches the volume and mounts its filesystem.
+func (d *iscsi) MountVolume(vol Volume, op *operations.Operation) (bool, error) {
+	volPath := vol.MountPath()
+	if shared.IsMountPoint(volPath) {
+		return false, nil
+	}
+
+	err := vol.EnsureMountPath()
+	if err != nil {
+		return false, err
+	}
+
+	err = d.mountVolume(vol, false)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// MountVolumeSnapshot attaches the snapshot and mounts its filesystem read-only.
+func (d *iscsi) MountVolumeSnapshot(snapVol Volume, op *operations.Operation) (bool, error) {
+	snapPath := snapVol.MountPath()
+	if shared.IsMountPoint(snapPath) {
+		return false, nil
+	}
+
+	parentName, _, _ := shared.InstanceGetParentAndSnapshotName(snapVol.name)
+	err := createParentSnapshotDirIfMissing(d.name, snapVol.volType, parentName)
+	if err != nil {
+		return false, err
+	}
+
+	err = snapVol.EnsureMountPath()
+	if err != nil {
+		return false, err
+	}
+
+	err = d.mountVolume(snapVol, true)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// mountVolume attaches the volume and mounts its filesystem on its mount path.
+func (d *iscsi) mountVolume(vol Volume, readonly bool) error {
+	revert := revert.New()
+	defer revert.Fail()
+
+	devPath, err := d.attachVolume(vol)
+	if err != nil {
+		return err
+	}
+	revert.Add(func() { d.detachVolume(vol) })
+
+	fsType, err := d.volumeFilesystem(devPath)
+	if err != nil {
+		return err
+	}
+
+	mountFlags, mountOptions := resolveMountOptions(BlockMountOptions(vol.config, vol.poolConfig))
+
+	// Snapshots are copied from mounted volumes, make sure their journal isn't replayed.
+	if readonly {
+		mountFlags |= unix.MS_RDONLY
+
+		extraOption := "noload"
+		if fsType == "xfs" {
+			extraOption = "norecovery,nouuid"
+		}
+
+		if mountOptions == "" {
+			mountOptions = extraOption
+		} else {
+			mountOptions = fmt.Sprintf("%s,%s", mountOptions, extraOption)
+		}
+	}
+
+	err = TryMount(devPath, vol.MountPath(), fsType, mountFlags, mountOptions)
+	if err != nil {
+		return err
+	}
+
+	revert.Success()
+	return nil
+}
+
+// UnmountVolume unmounts the volume and detaches it.
+func (d *iscsi) UnmountVolume(vol Volume, op *operations.Operation) (bool, error) {
+	volPath := vol.MountPath()
+	if !shared.IsMountPoint(volPath) {
+		return false, nil
+	}
+
+	_, err := forceUnmount(volPath)
+	if err != nil {
+		return false, err
+	}
+
+	err = d.detachVolume(vol)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// UnmountVolumeSnapshot unmounts the snapshot and detaches it.
+func (d *iscsi) UnmountVolumeSnapshot(snapVol Volume, op *operations.Operation) (bool, error) {
+	return d.UnmountVolume(snapVol, op)
+}
+
+// RenameVolume renames a volume and its snapshots.
+func (d *iscsi) RenameVolume(vol Volume, newVolName string, op *operations.Operation) error {
+	if shared.IsMountPoint(vol.MountPath()) {
+		return fmt.Errorf("Volume must be unmounted to be renamed")
+	}
+
+	snapshots, err := d.VolumeSnapshots(vol, op)
+	if err != nil {
+		return err
+	}
+
+	newVol := NewVolume(d, d.name, vol.volType, vol.contentType, newVolName, vol.config, vol.poolConfig)
+
+	// The targets are named after the volumes, so the volume and its snapshots are exported
+	// again under their new names when next attached.
+	renames := [][2]Volume{{vol, newVol}}
+	for _, snapName := range snapshots {
+		snapVol, err := vol.NewSnapshot(snapName)
+		if err != nil {
+			return err
+		}
+
+		newSnapVol, err := newVol.NewSnapshot(snapName)
+		if err != nil {
+			return err
+		}
+
+		renames = append(renames, [2]Volume{snapVol, newSnapVol})
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	for _, rename := range renames {
+		oldFile := d.volumeFile(rename[0])
+		newFile := d.volumeFile(rename[1])
+
+		err = d.unexportVolume(rename[0])
+		if err != nil {
+			return err
+		}
+
+		_, err = d.targetRun("mv", oldFile, newFile)
+		if err != nil {
+			return fmt.Errorf("Failed to rename backing file %q on the iSCSI target: %v", oldFile, err)
+		}
+		revert.Add(func() { d.targetRun("mv", newFile, oldFile) })
+	}
+
+	if shared.PathExists(vol.MountPath()) {
+		err = d.vfsRenameVolume(vol, newVolName, op)
+		if err != nil {
+			return err
+		}
+	}
+
+	revert.Success()
+	return nil
+}
+
+// MigrateVolume sends a volume for migration.
+func (d *iscsi) MigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs migration.VolumeSourceArgs, op *operations.Operation) error {
+	err := d.checkMigrationType(vol, volSrcArgs.MigrationType)
+	if err != nil {
+		return err
+	}
+
+	return d.vfsMigrateVolume(vol, conn, volSrcArgs, op)
+}
+
+// checkMigrationType checks that the volume can be migrated with the given migration type.
+func (d *iscsi) checkMigrationType(vol Volume, migrationType migration.Type) error {
+	if vol.contentType != ContentTypeFS {
+		return fmt.Errorf("Content type not supported")
+	}
+
+	if migrationType.FSType != migration.MigrationFSType_RSYNC {
+		return fmt.Errorf("Migration type not supported")
+	}
+
+	return nil
+}
+
+// BackupVolume copies a volume (and optionally its snapshots) to a specified target path.
+// This driver does not support optimized backups.
+func (d *iscsi) BackupVolume(vol Volume, targetPath string, optimized bool, snapshots bool, op *operations.Operation) error {
+	return d.vfsBackupVolume(vol, targetPath, snapshots, op)
+}
+
+// CreateVolumeSnapshot creates a snapshot of a volume by copying its backing file on the target.
+func (d *iscsi) CreateVolumeSnapshot(snapVol Volume, op *operations.Operation) error {
+	parentName, _, _ := shared.InstanceGetParentAndSnapshotName(snapVol.name)
+	parentVol := NewVolume(d, d.name, snapVol.volType, snapVol.contentType, parentName, snapVol.config, snapVol.poolConfig)
+	snapPath := snapVol.MountPath()
+
+	// Create the parent directory.
+	err := createParentSnapshotDirIfMissing(d.name, snapVol.volType, parentName)
+	if err != nil {
+		return err
+	}
+
+	// Create snapshot directory.
+	err = snapVol.EnsureMountPath()
+	if err != nil {
+		return err
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	revert.Add(func() { os.RemoveAll(snapPath) })
+
+	// Freeze the filesystem of a mounted volume so that the copy is consistent.
+	parentPath := parentVol.MountPath()
+	if shared.IsMountPoint(parentPath) {
+		_, err = shared.RunCommand("fsfreeze", "--freeze", parentPath)
+		if err != nil {
+			return err
+		}
+		defer shared.RunCommand("fsfreeze", "--unfreeze", parentPath)
+	}
+
+	snapFile := d.volumeFile(snapVol)
+	_, err = d.targetRun("cp", "--sparse=always", "--reflink=auto", d.volumeFile(parentVol), snapFile)
+	if err != nil {
+		d.targetRun("rm", "-f", snapFile)
+		return fmt.Errorf("Failed to copy backing file on the iSCSI target: %v", err)
+	}
+
+	revert.Success()
+	return nil
+}
+
+// DeleteVolumeSnapshot removes a snapshot from the storage device. The volName and snapshotName
+// must be bare names and should not be in the format "volume/snapshot".
+func (d *iscsi) DeleteVolumeSnapshot(snapVol Volume, op *operations.Operation) error {
+	_, err := d.UnmountVolumeSnapshot(snapVol, op)
+	if err != nil {
+		return err
+	}
+
+	err = d.unexportVolume(snapVol)
+	if err != nil {
+		return err
+	}
+
+	snapFile := d.volumeFile(snapVol)
+	_, err = d.targetRun("rm", "-f", snapFile)
+	if err != nil {
+		return fmt.Errorf("Failed to remove backing file %q on the iSCSI target: %v", snapFile, err)
+	}
+
+	err = os.RemoveAll(snapVol.MountPath())
+	if err != nil {
+		return err
+	}
+
+	parentName, _, _ := shared.InstanceGetParentAndSnapshotName(snapVol.name)
+
+	// Remove the parent snapshot directory if this is the last snapshot being removed.
+	err = deleteParentSnapshotDirIfEmpty(d.name, snapVol.volType, parentName)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// VolumeSnapshots returns a list of snapshots for the volume, from the backing files on the target.
+func (d *iscsi) VolumeSnapshots(vol Volume, op *operations.Operation) ([]string, error) {
+	out, err := d.targetRun("ls", "-1", d.config["iscsi.target.path"])
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf("%s..", d.lunName(vol))
+	snapshots := []string{}
+
+	for _, fileName := range strings.Split(out, "\n") {
+		fileName = strings.TrimSpace(fileName)
+		if !strings.HasPrefix(fileName, prefix) || !strings.HasSuffix(fileName, ".img") {
+			continue
+		}
+
+		snapName, err := iscsiUnescape(strings.TrimSuffix(strings.TrimPrefix(fileName, prefix), ".img"))
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, snapName)
+	}
+
+	return snapshots, nil
+}
+
+// RestoreVolume restores a volume from a snapshot.
+func (d *iscsi) RestoreVolume(vol Volume, snapshotName string, op *operations.Operation) error {
+	snapVol, err := vol.NewSnapshot(snapshotName)
+	if err != nil {
+		return err
+	}
+
+	if !d.HasVolume(snapVol) {
+		return fmt.Errorf("Snapshot not found")
+	}
+
+	if shared.IsMountPoint(vol.MountPath()) {
+		return fmt.Errorf("Volume must be unmounted to be restored")
+	}
+
+	// The backstore must be re-created as the size of the volume may change.
+	err = d.unexportVolume(vol)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.targetRun("cp", "--sparse=always", "--reflink=auto", d.volumeFile(snapVol), d.volumeFile(vol))
+	if err != nil {
+		return fmt.Errorf("Failed to restore backing file on the iSCSI target: %v", err)
+	}
+
+	return nil
+}
+
+// RenameVolumeSnapshot renames a volume snapshot.
+func (d *iscsi) RenameVolumeSnapshot(snapVol Volume, newSnapshotName string, op *operations.Operation) error {
+	parentName, _, _ := shared.InstanceGetParentAndSnapshotName(snapVol.name)
+	newSnapVol := NewVolume(d, d.name, snapVol.volType, snapVol.contentType, GetSnapshotVolumeName(parentName, newSnapshotName), snapVol.config, snapVol.poolConfig)
+
+	_, err := d.UnmountVolumeSnapshot(snapVol, op)
+	if err != nil {
+		return err
+	}
+
+	err = d.unexportVolume(snapVol)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.targetRun("mv", d.volumeFile(snapVol), d.volumeFile(newSnapVol))
+	if err != nil {
+		return fmt.Errorf("Failed to rename backing file on the iSCSI target: %v", err)
+	}
+
+	if shared.PathExists(snapVol.MountPath()) {
+		return d.vfsRenameVolumeSnapshot(snapVol, newSnapshotName, op)
+	}
+
+	return nil
+}
@@ -19,3 +19,16 @@ type VolumeFiller struct {
 
 	Fingerprint string // If the Filler will unpack an image, it should be this fingerprint.
 }
+
+// Pool health states.
+const (
+	HealthOnline   = "online"
+	HealthDegraded = "degraded"
+	HealthOffline  = "offline"
+)
+
+// Health represents the health of a storage pool.
+type Health struct {
+	Status  string // One of HealthOnline, HealthDegraded or HealthOffline.
+	Message string // Description of the problem if not online.
+}
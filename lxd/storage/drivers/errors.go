@@ -2,10 +2,13 @@ package drivers
 
 import (
 	"fmt"
+	"net/http"
+
+	"github.com/lxc/lxd/shared/api"
 )
 
 // ErrNotImplemented is the "Not implemented" error
-var ErrNotImplemented = fmt.Errorf("Not implemented")
+var ErrNotImplemented error = api.NewTypedError(http.StatusInternalServerError, api.ErrorTypeNotSupported, "Not implemented")
 
 // ErrUnknownDriver is the "Unknown driver" error
 var ErrUnknownDriver = fmt.Errorf("Unknown driver")
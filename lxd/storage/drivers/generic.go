@@ -123,6 +123,33 @@ func genericCreateVolumeFromMigration(d Driver, applyQuota func(vol Volume) (fun
 		d.DeleteVolume(vol, op)
 	}()
 
+	// Block volumes are received into a newly created, empty, disk image unless refreshing. Later
+	// transfers are deltas against the previously received snapshot.
+	refresh := volTargetArgs.Refresh
+	recvVolume := func(path string, wrapper *ioprogress.ProgressTracker) error {
+		err := rsync.Recv(path, conn, wrapper, volTargetArgs.MigrationType.Features)
+		if err != nil {
+			return err
+		}
+
+		if vol.contentType != ContentTypeBlock {
+			return nil
+		}
+
+		diskPath, err := d.GetVolumeDiskPath(vol)
+		if err != nil {
+			return err
+		}
+
+		err = migration.RecvBlockDelta(conn, diskPath, refresh, wrapper)
+		if err != nil {
+			return err
+		}
+
+		refresh = true
+		return nil
+	}
+
 	// Ensure the volume is mounted.
 	err := vol.MountTask(func(mountPath string, op *operations.Operation) error {
 		path := shared.AddSlash(mountPath)
@@ -136,7 +163,7 @@ func genericCreateVolumeFromMigration(d Driver, applyQuota func(vol Volume) (fun
 			}
 
 			d.Logger().Debug("Receiving volume", log.Ctx{"volume": vol.name, "snapshot": snapName, "path": path})
-			err := rsync.Recv(path, conn, wrapper, volTargetArgs.MigrationType.Features)
+			err := recvVolume(path, wrapper)
 			if err != nil {
 				return err
 			}
@@ -155,7 +182,7 @@ func genericCreateVolumeFromMigration(d Driver, applyQuota func(vol Volume) (fun
 		}
 
 		// Apply quotas.
-		if applyQuota != nil {
+		if applyQuota != nil && vol.contentType != ContentTypeBlock {
 			_, err := applyQuota(vol)
 			if err != nil {
 				return err
@@ -169,7 +196,7 @@ func genericCreateVolumeFromMigration(d Driver, applyQuota func(vol Volume) (fun
 		}
 
 		d.Logger().Debug("Receiving volume", log.Ctx{"volume": vol.name, "path": path})
-		err := rsync.Recv(path, conn, wrapper, volTargetArgs.MigrationType.Features)
+		err := recvVolume(path, wrapper)
 		if err != nil {
 			return err
 		}
@@ -181,7 +208,7 @@ func genericCreateVolumeFromMigration(d Driver, applyQuota func(vol Volume) (fun
 			}
 
 			d.Logger().Debug("Receiving volume (final stage)", log.Ctx{"vol": vol.name, "path": path})
-			err = rsync.Recv(path, conn, wrapper, volTargetArgs.MigrationType.Features)
+			err = recvVolume(path, wrapper)
 			if err != nil {
 				return err
 			}
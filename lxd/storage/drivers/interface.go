@@ -38,6 +38,7 @@ type Driver interface {
 	// Unmount unmounts a storage pool if needed, returns true if unmounted, false if was not mounted.
 	Unmount() (bool, error)
 	GetResources() (*api.ResourcesStoragePool, error)
+	Health() Health
 	Validate(config map[string]string) error
 	Update(changedConfig map[string]string) error
 
@@ -9,6 +9,7 @@ var drivers = map[string]func() driver{
 	"dir":    func() driver { return &dir{} },
 	"cephfs": func() driver { return &cephfs{} },
 	"btrfs":  func() driver { return &btrfs{} },
+	"iscsi":  func() driver { return &iscsi{} },
 }
 
 // Load returns a Driver for an existing low-level storage pool.
@@ -195,6 +195,25 @@ func hasFilesystem(path string, fsType int64) bool {
 	return true
 }
 
+// CephHealth returns the health of a ceph cluster as reported by "ceph health".
+func CephHealth(clusterName string, userName string) Health {
+	out, err := shared.RunCommand("ceph", "--name", fmt.Sprintf("client.%s", userName), "--cluster", clusterName, "health")
+	if err != nil {
+		return Health{Status: HealthOffline, Message: fmt.Sprintf("Failed to query ceph cluster health: %v", err)}
+	}
+
+	out = strings.TrimSpace(out)
+	fields := strings.SplitN(out, " ", 2)
+	switch fields[0] {
+	case "HEALTH_OK":
+		return Health{Status: HealthOnline}
+	case "HEALTH_WARN":
+		return Health{Status: HealthDegraded, Message: out}
+	default:
+		return Health{Status: HealthOffline, Message: out}
+	}
+}
+
 // GetPoolMountPath returns the mountpoint of the given pool.
 // {LXD_DIR}/storage-pools/<pool>
 func GetPoolMountPath(poolName string) string {
@@ -279,8 +298,7 @@ func createSparseFile(filePath string, sizeBytes int64) error {
 }
 
 // ensureVolumeBlockFile creates or resizes the raw block file for a volume.
-func ensureVolumeBlockFile(vol Volume, path string) error {
-	blockSize := vol.ExpandedConfig("size")
+func ensureVolumeBlockFile(path string, blockSize string) error {
 	if blockSize == "" {
 		blockSize = defaultBlockSize
 	}
@@ -291,6 +309,19 @@ func ensureVolumeBlockFile(vol Volume, path string) error {
 	}
 
 	if shared.PathExists(path) {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		if fi.Size() == blockSizeBytes {
+			return nil
+		}
+
+		if blockSizeBytes < fi.Size() {
+			return fmt.Errorf("Block volumes cannot be shrunk as LXD can't check that their content fits in the new size")
+		}
+
 		_, err = shared.RunCommand("qemu-img", "resize", "-f", "raw", path, fmt.Sprintf("%d", blockSizeBytes))
 		if err != nil {
 			return fmt.Errorf("Failed resizing disk image %s to size %s: %v", path, blockSize, err)
@@ -308,19 +339,19 @@ func ensureVolumeBlockFile(vol Volume, path string) error {
 	return nil
 }
 
-// mkfsOptions represents options for filesystem creation.
-type mkfsOptions struct {
+// MkfsOptions represents options for filesystem creation.
+type MkfsOptions struct {
 	Label string
 }
 
-// makeFSType creates the provided filesystem.
-func makeFSType(path string, fsType string, options *mkfsOptions) (string, error) {
+// MakeFSType creates the provided filesystem.
+func MakeFSType(path string, fsType string, options *MkfsOptions) (string, error) {
 	var err error
 	var msg string
 
 	fsOptions := options
 	if fsOptions == nil {
-		fsOptions = &mkfsOptions{}
+		fsOptions = &MkfsOptions{}
 	}
 
 	cmd := []string{fmt.Sprintf("mkfs.%s", fsType), path}
@@ -0,0 +1,72 @@
+package drivers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// DefaultBlockFilesystem is the filesystem block backed volumes are formatted with by default.
+const DefaultBlockFilesystem = "ext4"
+
+// blockFilesystems lists the filesystems each block backed driver can format its volumes with.
+// Snapshots and clones of zfs and iscsi volumes keep the filesystem UUID of their origin and are
+// mounted alongside it, which btrfs can't cope with.
+var blockFilesystems = map[string][]string{
+	"ceph":  {"btrfs", "ext4", "xfs"},
+	"iscsi": {"ext4", "xfs"},
+	"lvm":   {"btrfs", "ext4", "xfs"},
+	"zfs":   {"ext4", "xfs"},
+}
+
+// BlockFilesystem returns the filesystem a block backed volume is formatted with. The volume's
+// block.filesystem key takes precedence over the pool's volume.block.filesystem key.
+func BlockFilesystem(volConfig map[string]string, poolConfig map[string]string) string {
+	if volConfig["block.filesystem"] != "" {
+		return volConfig["block.filesystem"]
+	}
+
+	if poolConfig["volume.block.filesystem"] != "" {
+		return poolConfig["volume.block.filesystem"]
+	}
+
+	return DefaultBlockFilesystem
+}
+
+// BlockMountOptions returns the mount options of a block backed volume. The volume's
+// block.mount_options key takes precedence over the pool's volume.block.mount_options key.
+// The returned string needs to be split into flags and data with resolveMountOptions.
+func BlockMountOptions(volConfig map[string]string, poolConfig map[string]string) string {
+	if volConfig["block.mount_options"] != "" {
+		return volConfig["block.mount_options"]
+	}
+
+	if poolConfig["volume.block.mount_options"] != "" {
+		return poolConfig["volume.block.mount_options"]
+	}
+
+	if BlockFilesystem(volConfig, poolConfig) == "btrfs" {
+		return "user_subvol_rm_allowed,discard"
+	}
+
+	return "discard"
+}
+
+// ValidateBlockFilesystem checks that the driver can format its volumes with the filesystem.
+func ValidateBlockFilesystem(driverName string, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	supported, ok := blockFilesystems[driverName]
+	if !ok {
+		return fmt.Errorf("The %s storage driver doesn't support block filesystems", driverName)
+	}
+
+	if !shared.StringInSlice(value, supported) {
+		return fmt.Errorf("Filesystem %q isn't supported by the %s storage driver, supported filesystems are: %s", value, driverName, strings.Join(supported, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test BlockFilesystem and BlockMountOptions
+func TestBlockFilesystem(t *testing.T) {
+	// Test defaults.
+	assert.Equal(t, "ext4", BlockFilesystem(nil, nil))
+	assert.Equal(t, "discard", BlockMountOptions(nil, nil))
+
+	// Test pool defaults.
+	poolConfig := map[string]string{"volume.block.filesystem": "btrfs"}
+	assert.Equal(t, "btrfs", BlockFilesystem(nil, poolConfig))
+	assert.Equal(t, "user_subvol_rm_allowed,discard", BlockMountOptions(nil, poolConfig))
+
+	// Test volume config overriding the pool defaults.
+	poolConfig["volume.block.mount_options"] = "noatime"
+	volConfig := map[string]string{"block.filesystem": "xfs"}
+	assert.Equal(t, "xfs", BlockFilesystem(volConfig, poolConfig))
+	assert.Equal(t, "noatime", BlockMountOptions(volConfig, poolConfig))
+
+	volConfig["block.mount_options"] = "discard,nodev"
+	assert.Equal(t, "discard,nodev", BlockMountOptions(volConfig, poolConfig))
+}
+
+// Test ValidateBlockFilesystem
+func TestValidateBlockFilesystem(t *testing.T) {
+	assert.NoError(t, ValidateBlockFilesystem("lvm", ""))
+	assert.NoError(t, ValidateBlockFilesystem("lvm", "btrfs"))
+	assert.NoError(t, ValidateBlockFilesystem("ceph", "xfs"))
+	assert.NoError(t, ValidateBlockFilesystem("zfs", "ext4"))
+
+	assert.Error(t, ValidateBlockFilesystem("zfs", "btrfs"))
+	assert.Error(t, ValidateBlockFilesystem("iscsi", "btrfs"))
+	assert.Error(t, ValidateBlockFilesystem("lvm", "ntfs"))
+	assert.Error(t, ValidateBlockFilesystem("dir", "ext4"))
+}
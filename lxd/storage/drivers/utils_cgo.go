@@ -9,6 +9,7 @@ import (
 	"unsafe"
 
 	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
 )
 
 /*
@@ -345,3 +346,48 @@ func UnsetAutoclearOnLoopDev(loopFd int) error {
 
 	return nil
 }
+
+// GrowLoopFile grows the sparse file at path to size and, if the file is attached to a loop
+// device, has the kernel pick up the new capacity of the device. Loop files can't be shrunk.
+func GrowLoopFile(path string, size int64) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if size < fi.Size() {
+		return fmt.Errorf("Loop file %q can't be shrunk from %d to %d bytes", path, fi.Size(), size)
+	}
+
+	if size == fi.Size() {
+		return nil
+	}
+
+	err = os.Truncate(path, size)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to grow loop file %q", path)
+	}
+
+	cLoopDev := C.malloc(C.size_t(C.LO_NAME_SIZE))
+	if cLoopDev == nil {
+		return fmt.Errorf("Failed to allocate memory in C")
+	}
+	defer C.free(cLoopDev)
+
+	cSource := C.CString(path)
+	defer C.free(unsafe.Pointer(cSource))
+	loopFd, _ := C.find_associated_loop_device(cSource, (*C.char)(cLoopDev))
+	if loopFd < 0 {
+		return nil
+	}
+
+	loopF := os.NewFile(uintptr(loopFd), C.GoString((*C.char)(cLoopDev)))
+	defer loopF.Close()
+
+	err = unix.IoctlSetInt(int(loopF.Fd()), unix.LOOP_SET_CAPACITY, 0)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to refresh the capacity of loop device %q", loopF.Name())
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package drivers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pborman/uuid"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/secrets"
+	"github.com/lxc/lxd/shared"
+)
+
+// luksKeySize is the size in bytes of the randomly generated volume passphrases.
+const luksKeySize = 64
+
+// luksEnabled indicates whether the volume is a block volume encrypted with LUKS. The pool default
+// is copied into the volume config on creation so that changing it doesn't affect existing volumes.
+func luksEnabled(vol Volume) bool {
+	return vol.contentType == ContentTypeBlock && shared.IsTrue(vol.config["security.encryption"])
+}
+
+// luksMapperName returns the device-mapper name used for the unlocked volume.
+func luksMapperName(vol Volume) string {
+	return fmt.Sprintf("lxd_%s_%s_%s", vol.pool, vol.volType, strings.Replace(vol.name, "/", "-", -1))
+}
+
+// luksMapperPath returns the path of the unlocked volume's block device.
+func luksMapperPath(vol Volume) string {
+	return filepath.Join("/dev/mapper", luksMapperName(vol))
+}
+
+// luksSecretName returns the name of the secret holding the key of a LUKS device.
+func luksSecretName(luksUUID string) string {
+	return fmt.Sprintf("storage/luks/%s", luksUUID)
+}
+
+// luksSecrets returns the secrets backend used to store the keys.
+func (d *common) luksSecrets() (secrets.Backend, error) {
+	if d.state == nil || d.state.Secrets == nil {
+		return nil, fmt.Errorf("No secrets backend available to store encryption keys")
+	}
+
+	return d.state.Secrets, nil
+}
+
+// luksKeyHook returns the command configured through storage.encryption_key_hook, if any.
+func (d *common) luksKeyHook() (string, error) {
+	if d.state == nil {
+		return "", nil
+	}
+
+	var hook string
+	err := d.state.Node.Transaction(func(tx *db.NodeTx) error {
+		config, err := node.ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+
+		hook = config.StorageEncryptionKeyHook()
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hook, nil
+}
+
+// luksKey returns the passphrase of the LUKS device with the given UUID, generating and storing a
+// new one if create is true. Keys are stored in the secrets backend unless an external key hook is
+// configured, in which case the hook is called with the action (create, get or delete) and the
+// UUID as arguments and is expected to print the passphrase on its standard output.
+func (d *common) luksKey(luksUUID string, create bool) ([]byte, error) {
+	action := "get"
+	if create {
+		action = "create"
+	}
+
+	hook, err := d.luksKeyHook()
+	if err != nil {
+		return nil, err
+	}
+
+	if hook != "" {
+		out, err := shared.RunCommand(hook, action, luksUUID)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to %s encryption key through hook: %v", action, err)
+		}
+
+		key := strings.TrimSpace(out)
+		if key == "" {
+			return nil, fmt.Errorf("Encryption key hook returned an empty key")
+		}
+
+		return []byte(key), nil
+	}
+
+	backend, err := d.luksSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	if !create {
+		key, err := backend.Get(luksSecretName(luksUUID))
+		if err != nil {
+			if err == secrets.ErrNotFound {
+				return nil, fmt.Errorf("No encryption key found for volume with LUKS UUID %s", luksUUID)
+			}
+
+			return nil, err
+		}
+
+		return key, nil
+	}
+
+	buf := make([]byte, luksKeySize)
+	_, err = rand.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	key := []byte(hex.EncodeToString(buf))
+	err = backend.Put(luksSecretName(luksUUID), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// luksDeleteKey removes the key of the LUKS device at devPath.
+func (d *common) luksDeleteKey(devPath string) error {
+	luksUUID, err := luksGetUUID(devPath)
+	if err != nil {
+		return err
+	}
+
+	return d.luksRemoveKey(luksUUID)
+}
+
+// luksRemoveKey removes the key of the LUKS device with the given UUID.
+func (d *common) luksRemoveKey(luksUUID string) error {
+	hook, err := d.luksKeyHook()
+	if err != nil {
+		return err
+	}
+
+	if hook != "" {
+		_, err := shared.RunCommand(hook, "delete", luksUUID)
+		if err != nil {
+			return fmt.Errorf("Failed to delete encryption key through hook: %v", err)
+		}
+
+		return nil
+	}
+
+	backend, err := d.luksSecrets()
+	if err != nil {
+		return err
+	}
+
+	return backend.Delete(luksSecretName(luksUUID))
+}
+
+// luksIsEncrypted indicates whether the device or file at devPath has a LUKS header.
+func luksIsEncrypted(devPath string) bool {
+	if !shared.PathExists(devPath) {
+		return false
+	}
+
+	_, err := shared.RunCommand("cryptsetup", "isLuks", devPath)
+	return err == nil
+}
+
+// luksGetUUID returns the UUID of the LUKS device at devPath.
+func luksGetUUID(devPath string) (string, error) {
+	out, err := shared.RunCommand("cryptsetup", "luksUUID", devPath)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+// luksFormat initialises a LUKS2 header on devPath using a newly generated key.
+func (d *common) luksFormat(devPath string) error {
+	luksUUID := uuid.NewRandom().String()
+
+	key, err := d.luksKey(luksUUID, true)
+	if err != nil {
+		return err
+	}
+
+	err = shared.RunCommandWithFds(strings.NewReader(string(key)), nil, "cryptsetup", "luksFormat", "--batch-mode", "--type", "luks2", "--uuid", luksUUID, "--key-file", "-", devPath)
+	if err != nil {
+		d.luksRemoveKey(luksUUID)
+		return fmt.Errorf("Failed to encrypt %s: %v", devPath, err)
+	}
+
+	return nil
+}
+
+// luksOpen unlocks the LUKS device at devPath for the volume. Backing files are attached to a
+// loop device by cryptsetup. Returns true if the volume was unlocked by this call.
+func (d *common) luksOpen(vol Volume, devPath string) (bool, error) {
+	if shared.PathExists(luksMapperPath(vol)) {
+		return false, nil
+	}
+
+	luksUUID, err := luksGetUUID(devPath)
+	if err != nil {
+		return false, err
+	}
+
+	key, err := d.luksKey(luksUUID, false)
+	if err != nil {
+		return false, err
+	}
+
+	err = shared.RunCommandWithFds(strings.NewReader(string(key)), nil, "cryptsetup", "open", "--type", "luks2", "--key-file", "-", devPath, luksMapperName(vol))
+	if err != nil {
+		return false, fmt.Errorf("Failed to unlock %s: %v", devPath, err)
+	}
+
+	return true, nil
+}
+
+// luksClose locks the volume again. Returns true if the volume was unlocked.
+func luksClose(vol Volume) (bool, error) {
+	if !shared.PathExists(luksMapperPath(vol)) {
+		return false, nil
+	}
+
+	_, err := shared.TryRunCommand("cryptsetup", "close", luksMapperName(vol))
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -2,13 +2,16 @@ package storage
 
 import (
 	"fmt"
+	"net/http"
+
+	"github.com/lxc/lxd/shared/api"
 )
 
 // ErrNilValue is the "Nil value provided" error
 var ErrNilValue = fmt.Errorf("Nil value provided")
 
 // ErrNotImplemented is the "Not implemented" error
-var ErrNotImplemented = fmt.Errorf("Not implemented")
+var ErrNotImplemented error = api.NewTypedError(http.StatusInternalServerError, api.ErrorTypeNotSupported, "Not implemented")
 
 // ErrRunningQuotaResizeNotSupported is the "Running quota resize not supported" error.
-var ErrRunningQuotaResizeNotSupported = fmt.Errorf("Running quota resize not supported")
+var ErrRunningQuotaResizeNotSupported error = api.NewTypedError(http.StatusInternalServerError, api.ErrorTypeNotSupported, "Running quota resize not supported")
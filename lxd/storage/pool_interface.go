@@ -19,6 +19,7 @@ type Pool interface {
 	Driver() drivers.Driver
 
 	GetResources() (*api.ResourcesStoragePool, error)
+	Health() drivers.Health
 	Delete(localOnly bool, op *operations.Operation) error
 	Update(driverOnly bool, newDesc string, newConfig map[string]string, op *operations.Operation) error
 
@@ -61,14 +62,16 @@ type Pool interface {
 	UpdateImage(fingerprint, newDesc string, newConfig map[string]string, op *operations.Operation) error
 
 	// Custom volumes.
-	CreateCustomVolume(volName, desc string, config map[string]string, op *operations.Operation) error
+	CreateCustomVolume(volName, desc string, config map[string]string, contentType drivers.ContentType, op *operations.Operation) error
 	CreateCustomVolumeFromCopy(volName, desc string, config map[string]string, srcPoolName, srcVolName string, srcVolOnly bool, op *operations.Operation) error
+	RefreshCustomVolume(volName, desc string, config map[string]string, srcPoolName, srcVolName string, srcVolOnly bool, op *operations.Operation) error
 	UpdateCustomVolume(volName, newDesc string, newConfig map[string]string, op *operations.Operation) error
 	RenameCustomVolume(volName string, newVolName string, op *operations.Operation) error
 	DeleteCustomVolume(volName string, op *operations.Operation) error
 	GetCustomVolumeUsage(volName string) (int64, error)
 	MountCustomVolume(volName string, op *operations.Operation) (bool, error)
 	UnmountCustomVolume(volName string, op *operations.Operation) (bool, error)
+	GetCustomVolumeDisk(volName string) (string, error)
 
 	// Custom volume snapshots.
 	CreateCustomVolumeSnapshot(volName string, newSnapshotName string, op *operations.Operation) error
@@ -3,12 +3,16 @@ package storage
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/storage/drivers"
 	"github.com/lxc/lxd/shared"
@@ -168,7 +172,8 @@ func GrowFileSystem(fsType string, devPath string, mntpoint string) error {
 	case "ext4":
 		msg, err = shared.TryRunCommand("resize2fs", devPath)
 	case "xfs":
-		msg, err = shared.TryRunCommand("xfs_growfs", devPath)
+		// xfs can only be grown while mounted and expects the mount point.
+		msg, err = shared.TryRunCommand("xfs_growfs", mntpoint)
 	case "btrfs":
 		msg, err = shared.TryRunCommand("btrfs", "filesystem", "resize", "max", mntpoint)
 	default:
@@ -185,7 +190,11 @@ func GrowFileSystem(fsType string, devPath string, mntpoint string) error {
 	return nil
 }
 
-// ShrinkFileSystem shrinks a filesystem if it is supported.
+// ShrinkFileSystem shrinks a filesystem if it is supported, ahead of the block device holding it
+// being reduced to byteSize. Ext4 filesystems must be unmounted, they are checked first and are
+// refused if they can't fit in the new size. Btrfs filesystems are shrunk while mounted. XFS
+// filesystems can't be shrunk at all, so the device can only be reduced down to the size of the
+// filesystem, which is smaller than the device if growing the filesystem previously failed.
 func ShrinkFileSystem(fsType string, devPath string, mntpoint string, byteSize int64) error {
 	strSize := fmt.Sprintf("%dK", byteSize/1024)
 
@@ -193,27 +202,128 @@ func ShrinkFileSystem(fsType string, devPath string, mntpoint string, byteSize i
 	case "": // if not specified, default to ext4
 		fallthrough
 	case "ext4":
-		_, err := shared.TryRunCommand("e2fsck", "-f", "-y", devPath)
+		// Exit code 1 means that errors were found and corrected.
+		_, err := shared.RunCommand("e2fsck", "-f", "-y", devPath)
+		if err != nil && fileSystemCheckExitCode(err) != 1 {
+			return fmt.Errorf(`Filesystem check of "%s" failed, not shrinking it: %v`, devPath, err)
+		}
+
+		minSize, err := ext4MinimumSize(devPath)
 		if err != nil {
 			return err
 		}
 
+		if byteSize < minSize {
+			return fmt.Errorf(`The ext4 filesystem on "%s" can't be shrunk below %s`, devPath, units.GetByteSizeString(minSize, 0))
+		}
+
 		_, err = shared.TryRunCommand("resize2fs", devPath, strSize)
+		if err != nil {
+			return fmt.Errorf(`Could not shrink underlying ext4 filesystem for "%s": %v`, devPath, err)
+		}
+	case "xfs":
+		fsSize, err := xfsSize(devPath)
 		if err != nil {
 			return err
 		}
+
+		if byteSize < fsSize {
+			return fmt.Errorf(`XFS filesystems can't be shrunk, the one on "%s" needs %s`, devPath, units.GetByteSizeString(fsSize, 0))
+		}
 	case "btrfs":
 		_, err := shared.TryRunCommand("btrfs", "filesystem", "resize", strSize, mntpoint)
 		if err != nil {
-			return err
+			return fmt.Errorf(`Could not shrink underlying btrfs filesystem for "%s": %v`, devPath, err)
 		}
 	default:
 		return fmt.Errorf(`Shrinking not supported for filesystem type "%s"`, fsType)
 	}
 
+	logger.Debugf(`Shrunk underlying %s filesystem for "%s"`, fsType, devPath)
 	return nil
 }
 
+// fileSystemCheckExitCode returns the exit code of a failed filesystem check, or -1 if it couldn't
+// be run.
+func fileSystemCheckExitCode(err error) int {
+	runErr, ok := err.(shared.RunError)
+	if !ok {
+		return -1
+	}
+
+	exitError, ok := runErr.Err.(*exec.ExitError)
+	if !ok {
+		return -1
+	}
+
+	waitStatus := exitError.Sys().(syscall.WaitStatus)
+	return waitStatus.ExitStatus()
+}
+
+// ext4MinimumSize returns the size in bytes an unmounted ext4 filesystem can be shrunk to.
+func ext4MinimumSize(devPath string) (int64, error) {
+	out, err := shared.RunCommand("resize2fs", "-P", devPath)
+	if err != nil {
+		return -1, fmt.Errorf(`Failed to get the minimum size of the filesystem on "%s": %v`, devPath, err)
+	}
+
+	blocks, err := fileSystemField(out, "Estimated minimum size of the filesystem:")
+	if err != nil {
+		return -1, err
+	}
+
+	out, err = shared.RunCommand("dumpe2fs", "-h", devPath)
+	if err != nil {
+		return -1, fmt.Errorf(`Failed to get the block size of the filesystem on "%s": %v`, devPath, err)
+	}
+
+	blockSize, err := fileSystemField(out, "Block size:")
+	if err != nil {
+		return -1, err
+	}
+
+	return blocks * blockSize, nil
+}
+
+// xfsSize returns the size in bytes of an unmounted XFS filesystem.
+func xfsSize(devPath string) (int64, error) {
+	out, err := shared.RunCommand("xfs_db", "-r", "-c", "sb 0", "-c", "print dblocks", "-c", "print blocksize", devPath)
+	if err != nil {
+		return -1, fmt.Errorf(`Failed to get the size of the filesystem on "%s": %v`, devPath, err)
+	}
+
+	blocks, err := fileSystemField(out, "dblocks =")
+	if err != nil {
+		return -1, err
+	}
+
+	blockSize, err := fileSystemField(out, "blocksize =")
+	if err != nil {
+		return -1, err
+	}
+
+	return blocks * blockSize, nil
+}
+
+// fileSystemField returns the integer following the given prefix in the output of a filesystem tool.
+func fileSystemField(out string, prefix string) (int64, error) {
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+
+		value, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, prefix)), 10, 64)
+		if err != nil {
+			return -1, fmt.Errorf(`Invalid value for "%s" in filesystem information: %v`, prefix, err)
+		}
+
+		return value, nil
+	}
+
+	return -1, fmt.Errorf(`Missing "%s" in filesystem information`, prefix)
+}
+
 // GetStorageResource returns the available resources of a given path.
 func GetStorageResource(path string) (*api.ResourcesStoragePool, error) {
 	st, err := shared.Statvfs(path)
@@ -267,6 +377,41 @@ func VolumeTypeToDBType(volType drivers.VolumeType) (int, error) {
 	return -1, fmt.Errorf("Invalid storage volume type")
 }
 
+// VolumeContentTypeToDBContentType converts volume content type to internal code.
+func VolumeContentTypeToDBContentType(contentType drivers.ContentType) (int, error) {
+	switch contentType {
+	case drivers.ContentTypeBlock:
+		return db.StoragePoolVolumeContentTypeBlock, nil
+	case drivers.ContentTypeFS:
+		return db.StoragePoolVolumeContentTypeFS, nil
+	}
+
+	return -1, fmt.Errorf("Invalid volume content type")
+}
+
+// VolumeContentTypeNameToContentType converts an API volume content type name to a volume
+// content type. An empty name is treated as a filesystem volume.
+func VolumeContentTypeNameToContentType(contentTypeName string) (drivers.ContentType, error) {
+	switch contentTypeName {
+	case "", db.StoragePoolVolumeContentTypeNameFS:
+		return drivers.ContentTypeFS, nil
+	case db.StoragePoolVolumeContentTypeNameBlock:
+		return drivers.ContentTypeBlock, nil
+	}
+
+	return "", fmt.Errorf("Invalid volume content type name")
+}
+
+// MigrationFallbackType returns the migration type all drivers support for the content type, used
+// when the source and target don't have a better type in common.
+func MigrationFallbackType(contentType drivers.ContentType) migration.MigrationFSType {
+	if contentType == drivers.ContentTypeBlock {
+		return migration.MigrationFSType_BLOCK_AND_RSYNC
+	}
+
+	return migration.MigrationFSType_RSYNC
+}
+
 // InstanceTypeToVolumeType converts instance type to volume type.
 func InstanceTypeToVolumeType(instType instancetype.Type) (drivers.VolumeType, error) {
 	switch instType {
@@ -280,13 +425,19 @@ func InstanceTypeToVolumeType(instType instancetype.Type) (drivers.VolumeType, e
 }
 
 // VolumeDBCreate creates a volume in the database.
-func VolumeDBCreate(s *state.State, project, poolName, volumeName, volumeDescription, volumeTypeName string, snapshot bool, volumeConfig map[string]string) error {
+func VolumeDBCreate(s *state.State, project, poolName, volumeName, volumeDescription, volumeTypeName string, snapshot bool, volumeConfig map[string]string, contentType drivers.ContentType) error {
 	// Convert the volume type name to our internal integer representation.
 	volumeType, err := VolumeTypeNameToType(volumeTypeName)
 	if err != nil {
 		return err
 	}
 
+	// Convert the content type to our internal integer representation.
+	volumeContentType, err := VolumeContentTypeToDBContentType(contentType)
+	if err != nil {
+		return err
+	}
+
 	// Load storage pool the volume will be attached to.
 	poolID, poolStruct, err := s.Cluster.StoragePoolGet(poolName)
 	if err != nil {
@@ -317,7 +468,7 @@ func VolumeDBCreate(s *state.State, project, poolName, volumeName, volumeDescrip
 	}
 
 	// Create the database entry for the storage volume.
-	_, err = s.Cluster.StoragePoolVolumeCreate(project, volumeName, volumeDescription, volumeType, snapshot, poolID, volumeConfig)
+	_, err = s.Cluster.StoragePoolVolumeCreate(project, volumeName, volumeDescription, volumeType, snapshot, poolID, volumeConfig, volumeContentType)
 	if err != nil {
 		return fmt.Errorf("Error inserting %s of type %s into database: %s", poolName, volumeTypeName, err)
 	}
@@ -327,9 +478,9 @@ func VolumeDBCreate(s *state.State, project, poolName, volumeName, volumeDescrip
 
 // SupportedPoolTypes the types of pools supported.
 // Deprecated: this is being replaced with drivers.SupportedDrivers()
-var SupportedPoolTypes = []string{"btrfs", "ceph", "cephfs", "dir", "lvm", "zfs"}
+var SupportedPoolTypes = []string{"btrfs", "ceph", "cephfs", "dir", "iscsi", "lvm", "zfs"}
 
-// StorageVolumeConfigKeys config validation for btrfs, ceph, cephfs, dir, lvm, zfs types.
+// StorageVolumeConfigKeys config validation for btrfs, ceph, cephfs, dir, iscsi, lvm, zfs types.
 // Deprecated: these are being moved to the per-storage-driver implementations.
 var StorageVolumeConfigKeys = map[string]func(value string) ([]string, error){
 	"block.filesystem": func(value string) ([]string, error) {
@@ -338,10 +489,13 @@ var StorageVolumeConfigKeys = map[string]func(value string) ([]string, error){
 			return nil, err
 		}
 
-		return []string{"ceph", "lvm"}, nil
+		return []string{"ceph", "iscsi", "lvm", "zfs"}, nil
 	},
 	"block.mount_options": func(value string) ([]string, error) {
-		return []string{"ceph", "lvm"}, shared.IsAny(value)
+		return []string{"ceph", "iscsi", "lvm", "zfs"}, shared.IsAny(value)
+	},
+	"security.encryption": func(value string) ([]string, error) {
+		return []string{"dir"}, shared.IsBool(value)
 	},
 	"security.shifted": func(value string) ([]string, error) {
 		return SupportedPoolTypes, shared.IsBool(value)
@@ -367,6 +521,17 @@ var StorageVolumeConfigKeys = map[string]func(value string) ([]string, error){
 	"volatile.idmap.next": func(value string) ([]string, error) {
 		return SupportedPoolTypes, shared.IsAny(value)
 	},
+	"volatile.uuid": func(value string) ([]string, error) {
+		return SupportedPoolTypes, shared.IsAny(value)
+	},
+	"zfs.block_mode": func(value string) ([]string, error) {
+		err := shared.IsBool(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{"zfs"}, nil
+	},
 	"zfs.remove_snapshots": func(value string) ([]string, error) {
 		err := shared.IsBool(value)
 		if err != nil {
@@ -423,6 +588,21 @@ func VolumeValidateConfig(s *state.State, name string, config map[string]string,
 			if config["zfs.remove_snapshots"] != "" {
 				return fmt.Errorf("the key volume.zfs.remove_snapshots cannot be used with non zfs storage volumes")
 			}
+
+			if config["zfs.block_mode"] != "" {
+				return fmt.Errorf("the key zfs.block_mode cannot be used with non zfs storage volumes")
+			}
+		}
+
+		if shared.IsTrue(config["security.encryption"]) {
+			return fmt.Errorf("Volume encryption isn't supported by the %s storage driver", parentPool.Driver)
+		}
+
+		if key == "block.filesystem" {
+			err := drivers.ValidateBlockFilesystem(parentPool.Driver, val)
+			if err != nil {
+				return err
+			}
 		}
 
 		if parentPool.Driver == "dir" {
@@ -441,23 +621,13 @@ func VolumeValidateConfig(s *state.State, name string, config map[string]string,
 
 // VolumeFillDefault fills default settings into a volume config.
 func VolumeFillDefault(name string, config map[string]string, parentPool *api.StoragePool) error {
-	if parentPool.Driver == "lvm" || parentPool.Driver == "ceph" {
-		if config["block.filesystem"] == "" {
-			config["block.filesystem"] = parentPool.Config["volume.block.filesystem"]
-		}
-		if config["block.filesystem"] == "" {
-			// Unchangeable volume property: Set unconditionally.
-			config["block.filesystem"] = "ext4"
-		}
-
-		if config["block.mount_options"] == "" {
-			config["block.mount_options"] = parentPool.Config["volume.block.mount_options"]
-		}
-		if config["block.mount_options"] == "" {
-			// Unchangeable volume property: Set unconditionally.
-			config["block.mount_options"] = "discard"
-		}
+	if parentPool.Driver == "lvm" || parentPool.Driver == "ceph" || parentPool.Driver == "iscsi" {
+		// Unchangeable volume properties: Set unconditionally.
+		config["block.mount_options"] = drivers.BlockMountOptions(config, parentPool.Config)
+		config["block.filesystem"] = drivers.BlockFilesystem(config, parentPool.Config)
+	}
 
+	if parentPool.Driver == "lvm" || parentPool.Driver == "ceph" {
 		// Does the pool request a default size for new storage volumes?
 		if config["size"] == "0" || config["size"] == "" {
 			config["size"] = parentPool.Config["volume.size"]
@@ -476,6 +646,12 @@ func VolumeFillDefault(name string, config map[string]string, parentPool *api.St
 		}
 	}
 
+	// Encryption can't be changed on existing volumes, so record the pool default in the volume
+	// config rather than following it.
+	if config["security.encryption"] == "" && parentPool.Config["volume.security.encryption"] != "" {
+		config["security.encryption"] = parentPool.Config["volume.security.encryption"]
+	}
+
 	return nil
 }
 
@@ -531,6 +707,7 @@ func validateVolumeCommonRules(vol drivers.Volume) map[string]func(string) error
 	rules := map[string]func(string) error{
 		"volatile.idmap.last": shared.IsAny,
 		"volatile.idmap.next": shared.IsAny,
+		"volatile.uuid":       shared.IsAny,
 
 		// Note: size should not be modifiable for non-custom volumes and should be checked
 		// in the relevant volume update functions.
@@ -147,6 +147,14 @@ func (s *storageCeph) StoragePoolCreate() error {
 		s.OSDPoolName = s.pool.Name
 	}
 
+	// Validate the data pool upfront, it must already exist as erasure coded pools need a profile.
+	if s.OSDDataPoolName != "" {
+		err := cephOSDDataPoolValidate(s.ClusterName, s.OSDDataPoolName, s.UserName)
+		if err != nil {
+			return err
+		}
+	}
+
 	if !cephOSDPoolExists(s.ClusterName, s.OSDPoolName, s.UserName) {
 		logger.Debugf(`CEPH OSD storage pool "%s" does not exist`, s.OSDPoolName)
 
@@ -188,6 +196,12 @@ func (s *storageCeph) StoragePoolCreate() error {
 			}
 		}
 
+		// RBD keeps the metadata of its volumes in omap objects, which erasure coded pools
+		// don't support.
+		if cephOSDPoolIsErasureCoded(s.ClusterName, s.OSDPoolName, s.UserName) {
+			return fmt.Errorf(`CEPH OSD storage pool "%s" is erasure coded and can't hold RBD metadata, use a replicated pool with "ceph.osd.data_pool_name" set to the erasure coded pool instead`, s.OSDPoolName)
+		}
+
 		// Use existing osd pool
 		msg, err := shared.RunCommand("ceph", "--name", fmt.Sprintf("client.%s", s.UserName), "--cluster", s.ClusterName, "osd", "pool", "get", s.OSDPoolName, "pg_num")
 		if err != nil {
@@ -373,7 +387,7 @@ func (s *storageCeph) StoragePoolVolumeCreate() error {
 	RBDFilesystem := s.getRBDFilesystem()
 	logger.Debugf(`Retrieved filesystem type "%s" of RBD storage volume "%s" on storage pool "%s"`, RBDFilesystem, s.volume.Name, s.pool.Name)
 
-	output, err := makeFSType(RBDDevPath, RBDFilesystem, nil)
+	output, err := storageDrivers.MakeFSType(RBDDevPath, RBDFilesystem, nil)
 	if err != nil {
 		logger.Errorf(`Failed to create filesystem type "%s" on device path "%s" for RBD storage volume "%s" on storage pool "%s": %v (%s)`, RBDFilesystem, RBDDevPath, s.volume.Name, s.pool.Name, err, output)
 		return err
@@ -2106,7 +2120,7 @@ func (s *storageCeph) ImageCreate(fingerprint string, tracker *ioprogress.Progre
 
 		// get filesystem
 		RBDFilesystem := s.getRBDFilesystem()
-		output, err := makeFSType(RBDDevPath, RBDFilesystem, nil)
+		output, err := storageDrivers.MakeFSType(RBDDevPath, RBDFilesystem, nil)
 		if err != nil {
 			logger.Errorf(`Failed to create filesystem "%s" for RBD storage volume for image "%s" on storage pool "%s": %v (%s)`, RBDFilesystem, fingerprint, s.pool.Name, err, output)
 			return err
@@ -2514,6 +2528,10 @@ func (s *storageCeph) StoragePoolResources() (*api.ResourcesStoragePool, error)
 	return &res, nil
 }
 
+func (s *storageCeph) StoragePoolHealth() storageDrivers.Health {
+	return storageDrivers.CephHealth(s.ClusterName, s.UserName)
+}
+
 func (s *storageCeph) StoragePoolVolumeCopy(source *api.StorageVolumeSource) error {
 	logger.Infof("Copying RBD storage volume \"%s\" on storage pool \"%s\" as \"%s\" to storage pool \"%s\"", source.Name, source.Pool, s.volume.Name, s.pool.Name)
 	successMsg := fmt.Sprintf("Copied RBD storage volume \"%s\" on storage pool \"%s\" as \"%s\" to storage pool \"%s\"", source.Name, source.Pool, s.volume.Name, s.pool.Name)
@@ -45,6 +45,59 @@ func cephOSDPoolExists(ClusterName string, poolName string, userName string) boo
 	return true
 }
 
+// cephOSDPoolGet returns the value of a property of an OSD pool.
+func cephOSDPoolGet(clusterName string, poolName string, userName string, key string) (string, error) {
+	msg, err := shared.RunCommand(
+		"ceph",
+		"--name", fmt.Sprintf("client.%s", userName),
+		"--cluster", clusterName,
+		"osd",
+		"pool",
+		"get",
+		poolName,
+		key)
+	if err != nil {
+		return "", err
+	}
+
+	idx := strings.Index(msg, fmt.Sprintf("%s:", key))
+	if idx == -1 {
+		return "", fmt.Errorf(`Failed to parse "%s" of CEPH osd storage pool "%s"`, key, poolName)
+	}
+
+	return strings.TrimSpace(msg[idx+len(key)+1:]), nil
+}
+
+// cephOSDPoolIsErasureCoded returns whether an OSD pool is erasure coded. Only erasure coded pools
+// have an erasure code profile.
+func cephOSDPoolIsErasureCoded(clusterName string, poolName string, userName string) bool {
+	_, err := cephOSDPoolGet(clusterName, poolName, userName, "erasure_code_profile")
+	return err == nil
+}
+
+// cephOSDDataPoolValidate checks that an existing OSD pool can hold the data objects of RBD
+// storage volumes. Erasure coded pools must allow overwrites, which RBD relies on.
+func cephOSDDataPoolValidate(clusterName string, dataPoolName string, userName string) error {
+	if !cephOSDPoolExists(clusterName, dataPoolName, userName) {
+		return fmt.Errorf(`CEPH OSD data pool "%s" doesn't exist in cluster "%s"`, dataPoolName, clusterName)
+	}
+
+	if !cephOSDPoolIsErasureCoded(clusterName, dataPoolName, userName) {
+		return nil
+	}
+
+	overwrites, err := cephOSDPoolGet(clusterName, dataPoolName, userName, "allow_ec_overwrites")
+	if err != nil {
+		return err
+	}
+
+	if !shared.IsTrue(overwrites) {
+		return fmt.Errorf(`Erasure coded CEPH OSD data pool "%s" must have "allow_ec_overwrites" enabled to hold RBD storage volumes`, dataPoolName)
+	}
+
+	return nil
+}
+
 // cephOSDPoolDestroy destroys an OSD pool.
 // - A call to cephOSDPoolDestroy will destroy a pool including any storage
 //   volumes that still exist in the pool.
@@ -608,14 +661,22 @@ func cephRBDSnapshotDelete(clusterName string, poolName string,
 // operations is similar to creating an empty RBD storage volume and rsyncing
 // the contents of the source RBD storage volume into it.
 func cephRBDVolumeCopy(clusterName string, oldVolumeName string,
-	newVolumeName string, userName string) error {
-	_, err := shared.RunCommand(
-		"rbd",
+	newVolumeName string, userName string, dataPoolName string) error {
+	cmd := []string{
 		"--id", userName,
 		"--cluster", clusterName,
+	}
+
+	if dataPoolName != "" {
+		cmd = append(cmd, "--data-pool", dataPoolName)
+	}
+
+	cmd = append(cmd,
 		"cp",
 		oldVolumeName,
 		newVolumeName)
+
+	_, err := shared.RunCommand("rbd", cmd...)
 	if err != nil {
 		return err
 	}
@@ -712,15 +773,7 @@ func (s *storageCeph) getRBDSize() (string, error) {
 // getRBDFilesystem returns the filesystem the RBD storage volume is supposed to
 // be created with
 func (s *storageCeph) getRBDFilesystem() string {
-	if s.volume.Config["block.filesystem"] != "" {
-		return s.volume.Config["block.filesystem"]
-	}
-
-	if s.pool.Config["volume.block.filesystem"] != "" {
-		return s.pool.Config["volume.block.filesystem"]
-	}
-
-	return "ext4"
+	return storageDrivers.BlockFilesystem(s.volume.Config, s.pool.Config)
 }
 
 // getRBDMountOptions returns the mount options the storage volume is supposed
@@ -729,19 +782,7 @@ func (s *storageCeph) getRBDFilesystem() string {
 // helper (currently named "LXDResolveMountoptions") which will take on the job
 // of splitting it into appropriate flags and string options.
 func (s *storageCeph) getRBDMountOptions() string {
-	if s.volume.Config["block.mount_options"] != "" {
-		return s.volume.Config["block.mount_options"]
-	}
-
-	if s.pool.Config["volume.block.mount_options"] != "" {
-		return s.pool.Config["volume.block.mount_options"]
-	}
-
-	if s.getRBDFilesystem() == "btrfs" {
-		return "user_subvol_rm_allowed,discard"
-	}
-
-	return "discard"
+	return storageDrivers.BlockMountOptions(s.volume.Config, s.pool.Config)
 }
 
 // copyWithoutSnapshotsFull creates a non-sparse copy of a container
@@ -766,7 +807,7 @@ func (s *storageCeph) copyWithoutSnapshotsFull(target instance.Instance, source
 	}
 
 	err := cephRBDVolumeCopy(s.ClusterName, oldVolumeName, newVolumeName,
-		s.UserName)
+		s.UserName, s.OSDDataPoolName)
 	if err != nil {
 		logger.Debugf(`Failed to create full RBD copy "%s" to "%s": %s`, source.Name(), target.Name(), err)
 		return err
@@ -1481,6 +1522,9 @@ func (s *storageCeph) rbdShrink(path string, size int64, fsType string,
 	var err error
 	var msg string
 
+	// RBD volumes are resized in MiB, round up so the filesystem isn't shrunk to less than that.
+	size = ((size + 1024*1024 - 1) / (1024 * 1024)) * 1024 * 1024
+
 	cleanupFunc, err := shrinkVolumeFilesystem(s, volumeType, fsType, path, fsMntPoint, size, data)
 	if cleanupFunc != nil {
 		defer cleanupFunc()
@@ -1772,7 +1816,7 @@ func (s *storageCeph) doContainerCreate(projectName, name string, privileged boo
 
 	// get filesystem
 	RBDFilesystem := s.getRBDFilesystem()
-	output, err := makeFSType(RBDDevPath, RBDFilesystem, nil)
+	output, err := storageDrivers.MakeFSType(RBDDevPath, RBDFilesystem, nil)
 	if err != nil {
 		logger.Errorf(`Failed to create filesystem type "%s" on device path "%s" for RBD storage volume for container "%s" on storage pool "%s": %v (%s)`, RBDFilesystem, RBDDevPath, name, s.pool.Name, err, output)
 		return err
@@ -1998,7 +2042,7 @@ func (s *storageCeph) copyVolumeWithoutSnapshotsFull(source *api.StorageVolumeSo
 
 	newVolumeName := fmt.Sprintf("%s/custom_%s", s.OSDPoolName, s.volume.Name)
 
-	err := cephRBDVolumeCopy(s.ClusterName, oldVolumeName, newVolumeName, s.UserName)
+	err := cephRBDVolumeCopy(s.ClusterName, oldVolumeName, newVolumeName, s.UserName, s.OSDDataPoolName)
 	if err != nil {
 		logger.Errorf("Failed to create non-sparse copy of RBD storage volume \"%s\" on storage pool \"%s\": %s", source.Name, source.Pool, err)
 		return err
@@ -2083,14 +2083,6 @@ func (s *storageLvm) StorageEntitySetQuota(volumeType int, size int64, data inte
 	case storagePoolVolumeTypeContainer:
 		c = data.(instance.Instance)
 		ctName := c.Name()
-		if c.IsRunning() {
-			msg := fmt.Sprintf(`Cannot resize LVM storage volume `+
-				`for container "%s" when it is running`,
-				ctName)
-			logger.Errorf(msg)
-			return fmt.Errorf(msg)
-		}
-
 		ctLvmName := containerNameToLVName(ctName)
 		lvDevPath = getLvmDevPath("default", poolName, storagePoolVolumeAPIEndpointContainers, ctLvmName)
 		mountpoint = driver.GetContainerMountPoint(c.Project(), s.pool.Name, ctName)
@@ -2112,6 +2104,12 @@ func (s *storageLvm) StorageEntitySetQuota(volumeType int, size int64, data inte
 	}
 
 	if size < oldSize {
+		// Growing is done online but shrinking requires the filesystem to be
+		// unmounted, so defer it until the container is next started.
+		if c != nil && c.IsRunning() {
+			return driver.ErrRunningQuotaResizeNotSupported
+		}
+
 		err = s.lvReduce(lvDevPath, size, fsType, mountpoint, volumeType, data)
 	} else if size > oldSize {
 		err = s.lvExtend(lvDevPath, size, fsType, mountpoint, volumeType, data)
@@ -2206,6 +2204,21 @@ func (s *storageLvm) StoragePoolResources() (*api.ResourcesStoragePool, error) {
 	return &res, nil
 }
 
+func (s *storageLvm) StoragePoolHealth() storageDrivers.Health {
+	out, err := shared.TryRunCommand("vgs", "--noheadings", "-o", "vg_attr", s.vgName)
+	if err != nil {
+		return storageDrivers.Health{Status: storageDrivers.HealthOffline, Message: fmt.Sprintf("Failed to find volume group %q: %v", s.vgName, err)}
+	}
+
+	// The fourth attribute character is "p" when physical volumes are missing.
+	attr := strings.TrimSpace(out)
+	if len(attr) > 3 && attr[3] == 'p' {
+		return storageDrivers.Health{Status: storageDrivers.HealthDegraded, Message: fmt.Sprintf("Volume group %q has missing physical volumes", s.vgName)}
+	}
+
+	return storageDrivers.Health{Status: storageDrivers.HealthOnline}
+}
+
 func (s *storageLvm) StoragePoolVolumeCopy(source *api.StorageVolumeSource) error {
 	logger.Infof("Copying LVM storage volume \"%s\" on storage pool \"%s\" as \"%s\" to storage pool \"%s\"", source.Name, source.Pool, s.volume.Name, s.pool.Name)
 	successMsg := fmt.Sprintf("Copied LVM storage volume \"%s\" on storage pool \"%s\" as \"%s\" to storage pool \"%s\"", source.Name, source.Pool, s.volume.Name, s.pool.Name)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 
@@ -12,6 +13,7 @@ import (
 
 	lxd "github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/response"
 	storagePools "github.com/lxc/lxd/lxd/storage"
@@ -69,6 +71,12 @@ func storagePoolsGet(d *Daemon, r *http.Request) response.Response {
 			}
 			pl.UsedBy = poolUsedBy
 
+			if pl.Status == "Created" {
+				health := storagePoolHealthGet(d.State(), pool)
+				pl.Health = health.Status
+				pl.HealthMessage = health.Message
+			}
+
 			resultMap = append(resultMap, *pl)
 		}
 	}
@@ -94,6 +102,8 @@ func storagePoolsPost(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	configTranslateKeys(config.EntityStoragePool, req.Config)
+
 	// Sanity checks.
 	if req.Name == "" {
 		return response.BadRequest(fmt.Errorf("No name provided"))
@@ -309,6 +319,12 @@ func storagePoolGet(d *Daemon, r *http.Request) response.Response {
 	}
 	pool.UsedBy = poolUsedBy
 
+	if pool.Status == "Created" {
+		health := storagePoolHealthGet(d.State(), poolName)
+		pool.Health = health.Status
+		pool.HealthMessage = health.Message
+	}
+
 	targetNode := queryParam(r, "target")
 
 	clustered, err := cluster.Enabled(d.db)
@@ -345,11 +361,17 @@ func storagePoolPut(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	configTranslateKeys(config.EntityStoragePool, req.Config)
+
 	clustered, err := cluster.Enabled(d.db)
 	if err != nil {
 		return response.SmartError(err)
 	}
 
+	if clustered && queryParam(r, "target") != "" {
+		return storagePoolPutMember(d, r, poolName, req, false)
+	}
+
 	config := dbInfo.Config
 	if clustered {
 		err := storagePoolValidateClusterConfig(req.Config)
@@ -380,6 +402,12 @@ func storagePoolPut(d *Daemon, r *http.Request) response.Response {
 		config = storagePoolClusterFillWithNodeConfig(dbInfo.Config, config)
 	}
 
+	// Look for the volumes following the changed volume defaults, if requested.
+	volumes, propagate, resp := storagePoolVolumesToPropagate(d, r, poolName, dbInfo.Config, config)
+	if resp != nil {
+		return resp
+	}
+
 	// Notify the other nodes, unless this is itself a notification.
 	if clustered && !isClusterNotification(r) {
 		cert := d.endpoints.NetworkCert()
@@ -388,6 +416,10 @@ func storagePoolPut(d *Daemon, r *http.Request) response.Response {
 			return response.SmartError(err)
 		}
 		err = notifier(func(client lxd.InstanceServer) error {
+			if propagate {
+				return client.UpdateStoragePoolPropagate(poolName, req, r.Header.Get("If-Match"))
+			}
+
 			return client.UpdateStoragePool(poolName, req, r.Header.Get("If-Match"))
 		})
 		if err != nil {
@@ -401,6 +433,13 @@ func storagePoolPut(d *Daemon, r *http.Request) response.Response {
 		return response.InternalError(err)
 	}
 
+	if propagate {
+		err = storagePoolPropagateVolumeDefaults(d.State(), poolName, volumes)
+		if err != nil {
+			return response.SmartError(err)
+		}
+	}
+
 	return response.EmptySyncResponse
 }
 
@@ -420,11 +459,17 @@ func storagePoolPatch(d *Daemon, r *http.Request) response.Response {
 		return response.BadRequest(err)
 	}
 
+	configTranslateKeys(config.EntityStoragePool, req.Config)
+
 	clustered, err := cluster.Enabled(d.db)
 	if err != nil {
 		return response.SmartError(err)
 	}
 
+	if clustered && queryParam(r, "target") != "" {
+		return storagePoolPutMember(d, r, poolName, req, true)
+	}
+
 	config := dbInfo.Config
 	if clustered {
 		err := storagePoolValidateClusterConfig(req.Config)
@@ -467,6 +512,12 @@ func storagePoolPatch(d *Daemon, r *http.Request) response.Response {
 		config = storagePoolClusterFillWithNodeConfig(dbInfo.Config, config)
 	}
 
+	// Look for the volumes following the changed volume defaults, if requested.
+	volumes, propagate, resp := storagePoolVolumesToPropagate(d, r, poolName, dbInfo.Config, config)
+	if resp != nil {
+		return resp
+	}
+
 	// Notify the other nodes, unless this is itself a notification.
 	if clustered && !isClusterNotification(r) {
 		cert := d.endpoints.NetworkCert()
@@ -475,6 +526,10 @@ func storagePoolPatch(d *Daemon, r *http.Request) response.Response {
 			return response.SmartError(err)
 		}
 		err = notifier(func(client lxd.InstanceServer) error {
+			if propagate {
+				return client.UpdateStoragePoolPropagate(poolName, req, r.Header.Get("If-Match"))
+			}
+
 			return client.UpdateStoragePool(poolName, req, r.Header.Get("If-Match"))
 		})
 		if err != nil {
@@ -488,9 +543,110 @@ func storagePoolPatch(d *Daemon, r *http.Request) response.Response {
 		return response.InternalError(err)
 	}
 
+	if propagate {
+		err = storagePoolPropagateVolumeDefaults(d.State(), poolName, volumes)
+		if err != nil {
+			return response.SmartError(err)
+		}
+	}
+
+	return response.EmptySyncResponse
+}
+
+// This helper handles PUT and PATCH requests targeting a specific cluster member, which can only
+// change the member-specific size of loop backed pools.
+func storagePoolPutMember(d *Daemon, r *http.Request, poolName string, req api.StoragePoolPut, patch bool) response.Response {
+	resp := ForwardedResponseIfTargetIsRemote(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	_, dbInfo, err := d.cluster.StoragePoolGet(poolName)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Validate the ETag, which covers the member-specific keys of targeted requests.
+	etag := []interface{}{dbInfo.Name, dbInfo.Driver, dbInfo.Config}
+
+	err = util.EtagCheck(r, etag)
+	if err != nil {
+		return response.PreconditionFailed(err)
+	}
+
+	keys := []string{}
+	for key := range req.Config {
+		keys = append(keys, key)
+	}
+
+	if !patch {
+		if req.Description != dbInfo.Description {
+			return response.BadRequest(fmt.Errorf("Only the size can be changed on a specific member"))
+		}
+
+		for key := range dbInfo.Config {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		if key != "size" && req.Config[key] != dbInfo.Config[key] {
+			return response.BadRequest(fmt.Errorf("Only the size can be changed on a specific member"))
+		}
+	}
+
+	if req.Config["size"] == "" || req.Config["size"] == dbInfo.Config["size"] {
+		return response.EmptySyncResponse
+	}
+
+	config := util.CopyConfig(dbInfo.Config)
+	config["size"] = req.Config["size"]
+
+	err = storagePoolValidateConfig(poolName, dbInfo.Driver, config, dbInfo.Config)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	err = storagePoolUpdate(d.State(), poolName, dbInfo.Description, config, true)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
 	return response.EmptySyncResponse
 }
 
+// This helper handles the propagate query parameter of PUT and PATCH requests. With
+// "propagate=true" it returns the custom volumes following the volume defaults changed by the
+// request, which should be updated along with the pool. With "propagate=dry-run" it returns a
+// response listing those volumes, without changing anything.
+func storagePoolVolumesToPropagate(d *Daemon, r *http.Request, poolName string, oldConfig map[string]string, newConfig map[string]string) (map[string]*api.StorageVolume, bool, response.Response) {
+	mode := r.FormValue("propagate")
+	if mode == "" {
+		return nil, false, nil
+	}
+
+	if mode != "dry-run" && !shared.IsTrue(mode) {
+		return nil, false, response.BadRequest(fmt.Errorf("Invalid propagate value %q", mode))
+	}
+
+	volumes, err := storagePoolVolumesInheritingDefaults(d.State(), poolName, oldConfig, newConfig)
+	if err != nil {
+		return nil, false, response.SmartError(err)
+	}
+
+	if mode == "dry-run" {
+		urls := []string{}
+		for name := range volumes {
+			urls = append(urls, fmt.Sprintf("/%s/storage-pools/%s/volumes/custom/%s", version.APIVersion, poolName, name))
+		}
+		sort.Strings(urls)
+
+		return nil, false, response.SyncResponse(true, urls)
+	}
+
+	return volumes, true, nil
+}
+
 // This helper makes sure that, when clustered, we're not changing
 // node-specific values.
 //
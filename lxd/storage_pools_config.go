@@ -7,6 +7,7 @@ import (
 
 	"golang.org/x/sys/unix"
 
+	"github.com/lxc/lxd/lxd/storage/drivers"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/units"
 )
@@ -19,28 +20,56 @@ func updateStoragePoolError(unchangeable []string, driverName string) error {
 var changeableStoragePoolProperties = map[string][]string{
 	"btrfs": {
 		"rsync.bwlimit",
-		"btrfs.mount_options"},
+		"rsync.compression",
+		"btrfs.mount_options",
+		"size",
+		"size.grow_max",
+		"size.grow_step",
+		"size.grow_threshold"},
 
 	"ceph": {
+		"rsync.bwlimit",
+		"rsync.compression",
 		"volume.block.filesystem",
 		"volume.block.mount_options",
 		"volume.size"},
 
 	"cephfs": {
-		"rsync.bwlimit"},
+		"rsync.bwlimit",
+		"rsync.compression"},
 
 	"dir": {
-		"rsync.bwlimit"},
+		"rsync.bwlimit",
+		"rsync.compression"},
+
+	"iscsi": {
+		"iscsi.multipath",
+		"rsync.bwlimit",
+		"rsync.compression",
+		"volume.block.filesystem",
+		"volume.block.mount_options",
+		"volume.size"},
 
 	"lvm": {
 		"lvm.thinpool_name",
+		"rsync.bwlimit",
+		"rsync.compression",
 		"lvm.vg_name",
 		"volume.block.filesystem",
 		"volume.block.mount_options",
 		"volume.size"},
 
 	"zfs": {
-		"rsync_bwlimit",
+		"rsync.bwlimit",
+		"rsync.compression",
+		"size",
+		"size.grow_max",
+		"size.grow_step",
+		"size.grow_threshold",
+		"volume.block.filesystem",
+		"volume.block.mount_options",
+		"volume.size",
+		"volume.zfs.block_mode",
 		"volume.zfs.remove_snapshots",
 		"volume.zfs.use_refquota",
 		"zfs.clone_copy"},
@@ -75,22 +104,26 @@ var storagePoolConfigKeys = map[string]func(value string) error{
 	"cephfs.path":         shared.IsAny,
 	"cephfs.user.name":    shared.IsAny,
 
+	// valid drivers: iscsi
+	"iscsi.multipath":   shared.IsBool,
+	"iscsi.target.host": shared.IsAny,
+	"iscsi.target.iqn":  shared.IsAny,
+	"iscsi.target.path": shared.IsAny,
+
 	// valid drivers: lvm
 	"lvm.thinpool_name": shared.IsAny,
 	"lvm.use_thinpool":  shared.IsBool,
 	"lvm.vg_name":       shared.IsAny,
 
 	// valid drivers: btrfs, lvm, zfs
-	"size": func(value string) error {
-		if value == "" {
-			return nil
-		}
+	"size": storagePoolValidateSize,
 
-		_, err := units.ParseByteSizeString(value)
-		return err
-	},
+	// valid drivers: btrfs, zfs
+	"size.grow_max":       storagePoolValidateSize,
+	"size.grow_step":      storagePoolValidateSize,
+	"size.grow_threshold": storagePoolValidateGrowThreshold,
 
-	// valid drivers: btrfs, dir, lvm, zfs
+	// valid drivers: btrfs, dir, iscsi, lvm, zfs
 	"source": shared.IsAny,
 
 	// Using it as an indicator whether we created the pool or are just
@@ -101,13 +134,13 @@ var storagePoolConfigKeys = map[string]func(value string) error{
 	"volatile.pool.pristine":  shared.IsAny,
 	"volatile.initial_source": shared.IsAny,
 
-	// valid drivers: ceph, lvm
+	// valid drivers: ceph, iscsi, lvm, zfs
 	"volume.block.filesystem": func(value string) error {
 		return shared.IsOneOf(value, []string{"btrfs", "ext4", "xfs"})
 	},
 	"volume.block.mount_options": shared.IsAny,
 
-	// valid drivers: ceph, lvm
+	// valid drivers: ceph, iscsi, lvm, zfs
 	"volume.size": func(value string) error {
 		if value == "" {
 			return nil
@@ -117,7 +150,11 @@ var storagePoolConfigKeys = map[string]func(value string) error{
 		return err
 	},
 
+	// valid drivers: dir
+	"volume.security.encryption": shared.IsBool,
+
 	// valid drivers: zfs
+	"volume.zfs.block_mode":       shared.IsBool,
 	"volume.zfs.remove_snapshots": shared.IsBool,
 	"volume.zfs.use_refquota":     shared.IsBool,
 
@@ -125,6 +162,8 @@ var storagePoolConfigKeys = map[string]func(value string) error{
 	"zfs.clone_copy": shared.IsBool,
 	"zfs.pool_name":  shared.IsAny,
 	"rsync.bwlimit":  shared.IsAny,
+
+	"rsync.compression": shared.IsBool,
 }
 
 func storagePoolValidateConfig(name string, driver string, config map[string]string, oldConfig map[string]string) error {
@@ -150,6 +189,23 @@ func storagePoolValidateConfig(name string, driver string, config map[string]str
 		}
 	}
 
+	// Loop backed pools can only grow.
+	if oldConfig != nil && oldConfig["size"] != "" && config["size"] != "" && config["size"] != oldConfig["size"] {
+		oldSize, err := units.ParseByteSizeString(oldConfig["size"])
+		if err != nil {
+			return err
+		}
+
+		newSize, err := units.ParseByteSizeString(config["size"])
+		if err != nil {
+			return err
+		}
+
+		if newSize < oldSize {
+			return fmt.Errorf("The size of a storage pool can't be reduced")
+		}
+	}
+
 	// Check whether the config properties for the driver container sane
 	// values.
 	for key, val := range config {
@@ -164,30 +220,55 @@ func storagePoolValidateConfig(name string, driver string, config map[string]str
 		}
 
 		prfx := strings.HasPrefix
-		if driver == "dir" || driver == "ceph" || driver == "cephfs" {
+		if driver == "dir" || driver == "ceph" || driver == "cephfs" || driver == "iscsi" {
 			if key == "size" {
 				return fmt.Errorf("the key %s cannot be used with %s storage pools", key, strings.ToUpper(driver))
 			}
 		}
 
+		if driver != "btrfs" && driver != "zfs" {
+			if prfx(key, "size.") {
+				return fmt.Errorf("the key %s cannot be used with %s storage pools", key, strings.ToUpper(driver))
+			}
+		}
+
 		if driver != "lvm" {
 			if prfx(key, "lvm.") {
 				return fmt.Errorf("the key %s cannot be used with %s storage pools", key, strings.ToUpper(driver))
 			}
 		}
 
-		if driver != "lvm" && driver != "ceph" {
+		if driver != "lvm" && driver != "ceph" && driver != "iscsi" && driver != "zfs" {
 			if prfx(key, "volume.block.") || key == "volume.size" {
 				return fmt.Errorf("the key %s cannot be used with %s storage pools", key, strings.ToUpper(driver))
 			}
 		}
 
+		if driver != "dir" {
+			if key == "volume.security.encryption" {
+				return fmt.Errorf("the key %s cannot be used with %s storage pools", key, strings.ToUpper(driver))
+			}
+		}
+
 		if driver != "zfs" {
 			if prfx(key, "volume.zfs.") || prfx(key, "zfs.") {
 				return fmt.Errorf("the key %s cannot be used with %s storage pools", key, strings.ToUpper(driver))
 			}
 		}
 
+		if driver != "iscsi" {
+			if prfx(key, "iscsi.") {
+				return fmt.Errorf("the key %s cannot be used with %s storage pools", key, strings.ToUpper(driver))
+			}
+		}
+
+		if key == "volume.block.filesystem" {
+			err := drivers.ValidateBlockFilesystem(driver, val)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Validate storage pool config keys.
 		validator, ok := storagePoolConfigKeys[key]
 		if !ok {
@@ -204,7 +285,7 @@ func storagePoolValidateConfig(name string, driver string, config map[string]str
 }
 
 func storagePoolFillDefault(name string, driver string, config map[string]string) error {
-	if driver == "dir" || driver == "ceph" || driver == "cephfs" {
+	if driver == "dir" || driver == "ceph" || driver == "cephfs" || driver == "iscsi" {
 		if config["size"] != "" {
 			return fmt.Errorf(`The "size" property does not apply `+
 				`to %s storage pools`, driver)
@@ -247,7 +328,7 @@ func storagePoolFillDefault(name string, driver string, config map[string]string
 		}
 	}
 
-	if driver == "btrfs" || driver == "ceph" || driver == "cephfs" || driver == "lvm" || driver == "zfs" {
+	if driver == "btrfs" || driver == "ceph" || driver == "cephfs" || driver == "iscsi" || driver == "lvm" || driver == "zfs" {
 		if config["volume.size"] != "" {
 			_, err := units.ParseByteSizeString(config["volume.size"])
 			if err != nil {
@@ -258,3 +339,25 @@ func storagePoolFillDefault(name string, driver string, config map[string]string
 
 	return nil
 }
+
+func storagePoolValidateSize(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, err := units.ParseByteSizeString(value)
+	return err
+}
+
+func storagePoolValidateGrowThreshold(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	threshold, err := strconv.Atoi(value)
+	if err != nil || threshold < 1 || threshold > 99 {
+		return fmt.Errorf("Invalid value %q, must be a percentage between 1 and 99", value)
+	}
+
+	return nil
+}
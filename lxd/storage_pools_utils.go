@@ -1,16 +1,28 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/pkg/errors"
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/state"
 	storagePools "github.com/lxc/lxd/lxd/storage"
 	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/lxd/warnings"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/units"
 	"github.com/lxc/lxd/shared/version"
 )
 
@@ -90,6 +102,95 @@ func storagePoolUpdate(state *state.State, name, newDescription string, newConfi
 	return nil
 }
 
+// storagePoolVolumesInheritingDefaults returns the new configuration of the custom volumes on this
+// node whose config follows the volume.* defaults of the pool changed between oldConfig and
+// newConfig. A volume follows a default when it doesn't set the key or sets it to the previous
+// default. Defaults being unset, volume.block.filesystem and volume.security.encryption, which
+// can't change on existing volumes, aren't propagated.
+func storagePoolVolumesInheritingDefaults(state *state.State, poolName string, oldConfig map[string]string, newConfig map[string]string) (map[string]*api.StorageVolume, error) {
+	keys := []string{}
+	for key, value := range newConfig {
+		if !strings.HasPrefix(key, "volume.") || key == "volume.block.filesystem" || key == "volume.security.encryption" {
+			continue
+		}
+
+		if value == "" || value == oldConfig[key] {
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	volumes := map[string]*api.StorageVolume{}
+	if len(keys) == 0 {
+		return volumes, nil
+	}
+
+	poolID, err := state.Cluster.StoragePoolGetID(poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := state.Cluster.StoragePoolNodeVolumesGetType(db.StoragePoolVolumeTypeCustom, poolID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		if shared.IsSnapshot(name) {
+			continue
+		}
+
+		_, volume, err := state.Cluster.StoragePoolNodeVolumeGetType(name, db.StoragePoolVolumeTypeCustom, poolID)
+		if err != nil {
+			return nil, err
+		}
+
+		changed := false
+		config := util.CopyConfig(volume.Config)
+		for _, key := range keys {
+			volKey := strings.TrimPrefix(key, "volume.")
+			if config[volKey] != oldConfig[key] {
+				continue
+			}
+
+			config[volKey] = newConfig[key]
+			changed = true
+		}
+
+		if changed {
+			volume.Config = config
+			volumes[name] = volume
+		}
+	}
+
+	return volumes, nil
+}
+
+// storagePoolPropagateVolumeDefaults applies the new configuration returned by
+// storagePoolVolumesInheritingDefaults to the volumes.
+func storagePoolPropagateVolumeDefaults(state *state.State, poolName string, volumes map[string]*api.StorageVolume) error {
+	pool, err := storagePools.GetPoolByName(state, poolName)
+	legacy := err == storageDrivers.ErrUnknownDriver
+	if err != nil && !legacy {
+		return err
+	}
+
+	for name, volume := range volumes {
+		if legacy {
+			err = storagePoolVolumeUpdate(state, poolName, name, db.StoragePoolVolumeTypeCustom, volume.Description, volume.Config)
+		} else {
+			err = pool.UpdateCustomVolume(name, volume.Description, volume.Config, nil)
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "Failed to apply the new defaults to volume %q", name)
+		}
+	}
+
+	return nil
+}
+
 // Report all LXD objects that are currently using the given storage pool.
 // Volumes of type "custom" are not reported.
 // /1.0/containers/alp1
@@ -151,6 +252,199 @@ func storagePoolUsedByGet(state *state.State, project string, poolID int64, pool
 	return poolUsedBy, err
 }
 
+// storagePoolHealthGet returns the health of the storage pool on the local node.
+func storagePoolHealthGet(state *state.State, poolName string) storageDrivers.Health {
+	pool, err := storagePools.GetPoolByName(state, poolName)
+	if err != storageDrivers.ErrUnknownDriver {
+		if err != nil {
+			return storageDrivers.Health{Status: storageDrivers.HealthOffline, Message: err.Error()}
+		}
+
+		return pool.Health()
+	}
+
+	// Fallback to old storage layer.
+	s, err := storagePoolInit(state, poolName)
+	if err != nil {
+		return storageDrivers.Health{Status: storageDrivers.HealthOffline, Message: err.Error()}
+	}
+
+	return s.StoragePoolHealth()
+}
+
+// storagePoolsHealthCheckTask periodically checks the health of the local storage pools, logs any
+// changes in their state and records warnings for unhealthy pools.
+func storagePoolsHealthCheckTask(d *Daemon) (task.Func, task.Schedule) {
+	lastStatus := map[string]string{}
+
+	f := func(ctx context.Context) {
+		pools, err := d.cluster.StoragePools()
+		if err != nil {
+			if err != db.ErrNoSuchObject {
+				logger.Error("Failed to load storage pools", log.Ctx{"err": err})
+			}
+
+			return
+		}
+
+		for _, poolName := range pools {
+			poolID, pool, err := d.cluster.StoragePoolGet(poolName)
+			if err != nil || pool.Status != "Created" {
+				continue
+			}
+
+			health := storagePoolHealthGet(d.State(), poolName)
+			if lastStatus[poolName] == health.Status {
+				continue
+			}
+
+			if health.Status == storageDrivers.HealthOnline {
+				if lastStatus[poolName] != "" {
+					logger.Info("Storage pool is back online", log.Ctx{"pool": poolName})
+				}
+
+				err = warnings.ResolveLocalNodeByTypeAndEntity(d.cluster, db.WarningStoragePoolUnhealthy, db.WarningEntityTypeStoragePool, poolID)
+			} else {
+				logger.Warn("Storage pool isn't healthy", log.Ctx{"pool": poolName, "status": health.Status, "message": health.Message})
+
+				msg := fmt.Sprintf("Storage pool %q is %s", poolName, health.Status)
+				if health.Message != "" {
+					msg = fmt.Sprintf("%s: %s", msg, health.Message)
+				}
+
+				err = warnings.UpsertLocalNode(d.cluster, "", db.WarningEntityTypeStoragePool, poolID, db.WarningStoragePoolUnhealthy, msg)
+			}
+
+			if err != nil {
+				logger.Error("Failed to record storage pool warning", log.Ctx{"pool": poolName, "err": err})
+				continue
+			}
+
+			lastStatus[poolName] = health.Status
+		}
+	}
+
+	return f, task.Every(5 * time.Minute)
+}
+
+// storagePoolResources returns the space used by the given local storage pool.
+func storagePoolResources(state *state.State, poolName string) (*api.ResourcesStoragePool, error) {
+	pool, err := storagePools.GetPoolByName(state, poolName)
+	if err != storageDrivers.ErrUnknownDriver {
+		if err != nil {
+			return nil, err
+		}
+
+		return pool.GetResources()
+	}
+
+	// Fallback to old storage layer.
+	s, err := storagePoolInit(state, poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.StoragePoolCheck()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.StoragePoolResources()
+}
+
+// storagePoolsAutoGrowTask periodically checks the usage of the local loop backed storage pools
+// which have size.grow_threshold set, and grows those above the threshold by size.grow_step, up
+// to size.grow_max.
+func storagePoolsAutoGrowTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		pools, err := d.cluster.StoragePools()
+		if err != nil {
+			if err != db.ErrNoSuchObject {
+				logger.Error("Failed to load storage pools", log.Ctx{"err": err})
+			}
+
+			return
+		}
+
+		for _, poolName := range pools {
+			_, pool, err := d.cluster.StoragePoolGet(poolName)
+			if err != nil || pool.Status != "Created" {
+				continue
+			}
+
+			if !shared.StringInSlice(pool.Driver, []string{"btrfs", "zfs"}) || pool.Config["size.grow_threshold"] == "" || pool.Config["size"] == "" {
+				continue
+			}
+
+			if pool.Config["source"] != filepath.Join(shared.VarPath("disks"), fmt.Sprintf("%s.img", poolName)) {
+				continue
+			}
+
+			err = storagePoolAutoGrow(d.State(), pool)
+			if err != nil {
+				logger.Error("Failed to grow storage pool", log.Ctx{"pool": poolName, "err": err})
+			}
+		}
+	}
+
+	return f, task.Every(time.Minute)
+}
+
+// storagePoolAutoGrow grows the given loop backed storage pool by size.grow_step if its usage is
+// above size.grow_threshold.
+func storagePoolAutoGrow(state *state.State, pool *api.StoragePool) error {
+	threshold, err := strconv.ParseUint(pool.Config["size.grow_threshold"], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	res, err := storagePoolResources(state, pool.Name)
+	if err != nil {
+		return err
+	}
+
+	if res.Space.Total == 0 || res.Space.Used*100/res.Space.Total < threshold {
+		return nil
+	}
+
+	size, err := units.ParseByteSizeString(pool.Config["size"])
+	if err != nil {
+		return err
+	}
+
+	step := int64(5 * 1024 * 1024 * 1024)
+	if pool.Config["size.grow_step"] != "" {
+		step, err = units.ParseByteSizeString(pool.Config["size.grow_step"])
+		if err != nil {
+			return err
+		}
+	}
+
+	newSize := size + step
+	if pool.Config["size.grow_max"] != "" {
+		maxSize, err := units.ParseByteSizeString(pool.Config["size.grow_max"])
+		if err != nil {
+			return err
+		}
+
+		if size >= maxSize {
+			logger.Warn("Storage pool is above its growth threshold but already at its maximum size", log.Ctx{"pool": pool.Name, "size": pool.Config["size"]})
+			return nil
+		}
+
+		if newSize > maxSize {
+			newSize = maxSize
+		}
+	}
+
+	config := util.CopyConfig(pool.Config)
+	config["size"] = fmt.Sprintf("%dB", newSize)
+
+	logger.Info("Growing storage pool", log.Ctx{"pool": pool.Name, "size": config["size"]})
+
+	return storagePoolUpdate(state, pool.Name, pool.Description, config, true)
+}
+
 func profilesUsingPoolGetNames(db *db.Cluster, project string, poolName string) ([]string, error) {
 	usedBy := []string{}
 
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/state"
 	storagePools "github.com/lxc/lxd/lxd/storage"
+	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
 	"github.com/lxc/lxd/shared/api"
 )
 
@@ -43,6 +45,12 @@ func (s *storageShared) GetState() *state.State {
 	return s.s
 }
 
+// StoragePoolHealth returns the health of the storage pool. Drivers that can
+// detect degraded states override this.
+func (s *storageShared) StoragePoolHealth() storageDrivers.Health {
+	return storageDrivers.Health{Status: storageDrivers.HealthOnline}
+}
+
 func (s *storageShared) GetStoragePoolWritable() api.StoragePoolPut {
 	return s.pool.Writable()
 }
@@ -68,7 +76,7 @@ func (s *storageShared) createImageDbPoolVolume(fingerprint string) error {
 	}
 
 	// Create a db entry for the storage volume of the image.
-	_, err = s.s.Cluster.StoragePoolVolumeCreate("default", fingerprint, "", storagePoolVolumeTypeImage, false, s.poolID, volumeConfig)
+	_, err = s.s.Cluster.StoragePoolVolumeCreate("default", fingerprint, "", storagePoolVolumeTypeImage, false, s.poolID, volumeConfig, db.StoragePoolVolumeContentTypeFS)
 	if err != nil {
 		// Try to delete the db entry on error.
 		s.deleteImageDbPoolVolume(fingerprint)
@@ -8,17 +8,37 @@ import (
 
 	"github.com/lxc/lxd/lxd/instance"
 	driver "github.com/lxc/lxd/lxd/storage"
-	"github.com/lxc/lxd/shared"
-	"github.com/lxc/lxd/shared/logger"
 )
 
+// shrinkVolumeFilesystem shrinks the filesystem of a volume ahead of its block device being
+// reduced to byteSize. Btrfs filesystems are mounted for the resize, other filesystems are
+// unmounted. The returned function restores the previous mount state.
 func shrinkVolumeFilesystem(s storage, volumeType int, fsType string, devPath string, mntpoint string, byteSize int64, data interface{}) (func() (bool, error), error) {
 	var cleanupFunc func() (bool, error)
 	switch fsType {
-	case "xfs":
-		logger.Errorf("XFS filesystems cannot be shrunk: dump, mkfs, and restore are required")
-		return nil, fmt.Errorf("xfs filesystems cannot be shrunk: dump, mkfs, and restore are required")
 	case "btrfs":
+		switch volumeType {
+		case storagePoolVolumeTypeContainer:
+			c := data.(instance.Instance)
+			ourMount, err := c.StorageStart()
+			if err != nil {
+				return nil, err
+			}
+			if ourMount {
+				cleanupFunc = c.StorageStop
+			}
+		case storagePoolVolumeTypeCustom:
+			ourMount, err := s.StoragePoolVolumeMount()
+			if err != nil {
+				return nil, err
+			}
+			if ourMount {
+				cleanupFunc = s.StoragePoolVolumeUmount
+			}
+		default:
+			return nil, fmt.Errorf(`Resizing not implemented for storage volume type %d`, volumeType)
+		}
+	case "xfs":
 		fallthrough
 	case "": // if not specified, default to ext4
 		fallthrough
@@ -53,38 +73,6 @@ func shrinkVolumeFilesystem(s storage, volumeType int, fsType string, devPath st
 	return cleanupFunc, err
 }
 
-// MkfsOptions represents options for filesystem creation.
-type mkfsOptions struct {
-	Label string
-}
-
-// MakeFSType creates the provided filesystem.
-func makeFSType(path string, fsType string, options *mkfsOptions) (string, error) {
-	var err error
-	var msg string
-
-	fsOptions := options
-	if fsOptions == nil {
-		fsOptions = &mkfsOptions{}
-	}
-
-	cmd := []string{fmt.Sprintf("mkfs.%s", fsType), path}
-	if fsOptions.Label != "" {
-		cmd = append(cmd, "-L", fsOptions.Label)
-	}
-
-	if fsType == "ext4" {
-		cmd = append(cmd, "-E", "nodiscard,lazy_itable_init=0,lazy_journal_init=0")
-	}
-
-	msg, err = shared.TryRunCommand(cmd[0], cmd[1:]...)
-	if err != nil {
-		return msg, err
-	}
-
-	return "", nil
-}
-
 // mountOption represents an individual mount option.
 type mountOption struct {
 	capture bool
@@ -29,14 +29,14 @@ import (
 var storagePoolVolumesCmd = APIEndpoint{
 	Path: "storage-pools/{name}/volumes",
 
-	Get:  APIEndpointAction{Handler: storagePoolVolumesGet, AccessHandler: AllowAuthenticated},
+	Get:  APIEndpointAction{Handler: storagePoolVolumesGet, AccessHandler: AllowProjectAuthenticated},
 	Post: APIEndpointAction{Handler: storagePoolVolumesPost},
 }
 
 var storagePoolVolumesTypeCmd = APIEndpoint{
 	Path: "storage-pools/{name}/volumes/{type}",
 
-	Get:  APIEndpointAction{Handler: storagePoolVolumesTypeGet, AccessHandler: AllowAuthenticated},
+	Get:  APIEndpointAction{Handler: storagePoolVolumesTypeGet, AccessHandler: AllowProjectAuthenticated},
 	Post: APIEndpointAction{Handler: storagePoolVolumesTypePost},
 }
 
@@ -44,7 +44,7 @@ var storagePoolVolumeTypeContainerCmd = APIEndpoint{
 	Path: "storage-pools/{pool}/volumes/container/{name:.*}",
 
 	Delete: APIEndpointAction{Handler: storagePoolVolumeTypeContainerDelete},
-	Get:    APIEndpointAction{Handler: storagePoolVolumeTypeContainerGet, AccessHandler: AllowAuthenticated},
+	Get:    APIEndpointAction{Handler: storagePoolVolumeTypeContainerGet, AccessHandler: AllowProjectAuthenticated},
 	Patch:  APIEndpointAction{Handler: storagePoolVolumeTypeContainerPatch},
 	Post:   APIEndpointAction{Handler: storagePoolVolumeTypeContainerPost},
 	Put:    APIEndpointAction{Handler: storagePoolVolumeTypeContainerPut},
@@ -54,7 +54,7 @@ var storagePoolVolumeTypeVMCmd = APIEndpoint{
 	Path: "storage-pools/{pool}/volumes/virtual-machine/{name:.*}",
 
 	Delete: APIEndpointAction{Handler: storagePoolVolumeTypeVMDelete},
-	Get:    APIEndpointAction{Handler: storagePoolVolumeTypeVMGet, AccessHandler: AllowAuthenticated},
+	Get:    APIEndpointAction{Handler: storagePoolVolumeTypeVMGet, AccessHandler: AllowProjectAuthenticated},
 	Patch:  APIEndpointAction{Handler: storagePoolVolumeTypeVMPatch},
 	Post:   APIEndpointAction{Handler: storagePoolVolumeTypeVMPost},
 	Put:    APIEndpointAction{Handler: storagePoolVolumeTypeVMPut},
@@ -64,7 +64,7 @@ var storagePoolVolumeTypeCustomCmd = APIEndpoint{
 	Path: "storage-pools/{pool}/volumes/custom/{name}",
 
 	Delete: APIEndpointAction{Handler: storagePoolVolumeTypeCustomDelete},
-	Get:    APIEndpointAction{Handler: storagePoolVolumeTypeCustomGet, AccessHandler: AllowAuthenticated},
+	Get:    APIEndpointAction{Handler: storagePoolVolumeTypeCustomGet, AccessHandler: AllowProjectAuthenticated},
 	Patch:  APIEndpointAction{Handler: storagePoolVolumeTypeCustomPatch},
 	Post:   APIEndpointAction{Handler: storagePoolVolumeTypeCustomPost},
 	Put:    APIEndpointAction{Handler: storagePoolVolumeTypeCustomPut},
@@ -74,7 +74,7 @@ var storagePoolVolumeTypeImageCmd = APIEndpoint{
 	Path: "storage-pools/{pool}/volumes/image/{name}",
 
 	Delete: APIEndpointAction{Handler: storagePoolVolumeTypeImageDelete},
-	Get:    APIEndpointAction{Handler: storagePoolVolumeTypeImageGet, AccessHandler: AllowAuthenticated},
+	Get:    APIEndpointAction{Handler: storagePoolVolumeTypeImageGet, AccessHandler: AllowProjectAuthenticated},
 	Patch:  APIEndpointAction{Handler: storagePoolVolumeTypeImagePatch},
 	Post:   APIEndpointAction{Handler: storagePoolVolumeTypeImagePost},
 	Put:    APIEndpointAction{Handler: storagePoolVolumeTypeImagePut},
@@ -253,6 +253,15 @@ func storagePoolVolumesTypePost(d *Daemon, r *http.Request) response.Response {
 		return resp
 	}
 
+	// Create a custom volume from an exported tarball.
+	if r.Header.Get("Content-Type") == "application/octet-stream" {
+		if mux.Vars(r)["type"] != storagePoolVolumeTypeNameCustom {
+			return response.BadRequest(fmt.Errorf("Only custom volumes can be imported"))
+		}
+
+		return storagePoolVolumeCreateFromBackup(d, mux.Vars(r)["name"], r.Body, r.Header.Get("X-LXD-name"))
+	}
+
 	req := api.StorageVolumesPost{}
 
 	// Parse the request.
@@ -293,7 +302,28 @@ func storagePoolVolumesTypePost(d *Daemon, r *http.Request) response.Response {
 			return response.SmartError(err)
 		}
 
-		return response.Conflict(fmt.Errorf("Volume by that name already exists"))
+		if !req.Source.Refresh {
+			return response.Conflict(fmt.Errorf("Volume by that name already exists"))
+		}
+	} else {
+		// Nothing to refresh, perform a regular copy.
+		req.Source.Refresh = false
+	}
+
+	// Validate the requested content type.
+	contentType, err := storagePools.VolumeContentTypeNameToContentType(req.ContentType)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if contentType == storageDrivers.ContentTypeBlock && req.Source.Type == "migration" {
+		return response.BadRequest(fmt.Errorf("Migration of block custom volumes isn't supported"))
+	}
+
+	// Check the aggregate limits of the project.
+	err = projectLimitsCheckVolume(d.State(), "default", poolName, req.Name, req.Config)
+	if err != nil {
+		return response.BadRequest(err)
 	}
 
 	switch req.Source.Type {
@@ -319,14 +349,31 @@ func doVolumeCreateOrCopy(d *Daemon, poolName string, req *api.StorageVolumesPos
 			return response.SmartError(err)
 		}
 
+		contentType, err := storagePools.VolumeContentTypeNameToContentType(req.ContentType)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+
 		run = func(op *operations.Operation) error {
 			if req.Source.Name == "" {
-				return pool.CreateCustomVolume(req.Name, req.Description, req.Config, op)
+				return pool.CreateCustomVolume(req.Name, req.Description, req.Config, contentType, op)
+			}
+
+			if req.Source.Refresh {
+				return pool.RefreshCustomVolume(req.Name, req.Description, req.Config, req.Source.Pool, req.Source.Name, req.Source.VolumeOnly, op)
 			}
 
 			return pool.CreateCustomVolumeFromCopy(req.Name, req.Description, req.Config, req.Source.Pool, req.Source.Name, req.Source.VolumeOnly, op)
 		}
 	} else {
+		if req.ContentType == db.StoragePoolVolumeContentTypeNameBlock {
+			return response.BadRequest(fmt.Errorf("Block custom volumes aren't supported by this storage driver"))
+		}
+
+		if req.Source.Refresh {
+			return response.BadRequest(fmt.Errorf("Refreshing custom volumes isn't supported by this storage driver"))
+		}
+
 		run = func(op *operations.Operation) error {
 			return storagePoolVolumeCreateInternal(d.State(), poolName, req)
 		}
@@ -403,7 +450,28 @@ func storagePoolVolumesPost(d *Daemon, r *http.Request) response.Response {
 			return response.SmartError(err)
 		}
 
-		return response.Conflict(fmt.Errorf("Volume by that name already exists"))
+		if !req.Source.Refresh {
+			return response.Conflict(fmt.Errorf("Volume by that name already exists"))
+		}
+	} else {
+		// Nothing to refresh, perform a regular copy.
+		req.Source.Refresh = false
+	}
+
+	// Validate the requested content type.
+	contentType, err := storagePools.VolumeContentTypeNameToContentType(req.ContentType)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if contentType == storageDrivers.ContentTypeBlock && req.Source.Type == "migration" {
+		return response.BadRequest(fmt.Errorf("Migration of block custom volumes isn't supported"))
+	}
+
+	// Check the aggregate limits of the project.
+	err = projectLimitsCheckVolume(d.State(), "default", poolName, req.Name, req.Config)
+	if err != nil {
+		return response.BadRequest(err)
 	}
 
 	switch req.Source.Type {
@@ -460,6 +528,7 @@ func doVolumeMigration(d *Daemon, poolName string, req *api.StorageVolumesPost)
 		Secrets:    req.Source.Websockets,
 		Push:       push,
 		VolumeOnly: req.Source.VolumeOnly,
+		Refresh:    req.Source.Refresh,
 	}
 
 	sink, err := NewStorageMigrationSink(&migrationArgs)
@@ -966,6 +1035,23 @@ func storagePoolVolumeTypePut(d *Daemon, r *http.Request, volumeTypeName string)
 		return response.BadRequest(err)
 	}
 
+	// The volume keeps its UUID.
+	if vol.Config["volatile.uuid"] != "" {
+		if req.Config == nil {
+			req.Config = map[string]string{}
+		}
+
+		req.Config["volatile.uuid"] = vol.Config["volatile.uuid"]
+	}
+
+	// Check the aggregate limits of the project.
+	if volumeType == db.StoragePoolVolumeTypeCustom {
+		err = projectLimitsCheckVolume(d.State(), project, poolName, vol.Name, req.Config)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+	}
+
 	// Check if we can load new storage layer for pool driver type.
 	pool, err := storagePools.GetPoolByName(d.State(), poolName)
 	if err != storageDrivers.ErrUnknownDriver {
@@ -1140,6 +1226,19 @@ func storagePoolVolumeTypePatch(d *Daemon, r *http.Request, volumeTypeName strin
 		}
 	}
 
+	// The volume keeps its UUID.
+	if vol.Config["volatile.uuid"] != "" {
+		req.Config["volatile.uuid"] = vol.Config["volatile.uuid"]
+	}
+
+	// Check the aggregate limits of the project.
+	if volumeType == db.StoragePoolVolumeTypeCustom {
+		err = projectLimitsCheckVolume(d.State(), "default", poolName, vol.Name, req.Config)
+		if err != nil {
+			return response.BadRequest(err)
+		}
+	}
+
 	// Check if we can load new storage layer for pool driver type.
 	pool, err := storagePools.GetPoolByName(d.State(), poolName)
 	if err != storageDrivers.ErrUnknownDriver {
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/state"
+	storagePools "github.com/lxc/lxd/lxd/storage"
+	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var storagePoolVolumeTypeCustomExportCmd = APIEndpoint{
+	Path: "storage-pools/{pool}/volumes/custom/{name}/export",
+
+	Get: APIEndpointAction{Handler: storagePoolVolumeTypeCustomExportGet},
+}
+
+// /1.0/storage-pools/{pool}/volumes/custom/{name}/export
+// Download a tarball of the content of a custom storage volume.
+func storagePoolVolumeTypeCustomExportGet(d *Daemon, r *http.Request) response.Response {
+	poolName := mux.Vars(r)["pool"]
+	volumeName := mux.Vars(r)["name"]
+
+	poolID, err := d.cluster.StoragePoolGetID(poolName)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	resp := ForwardedResponseIfTargetIsRemote(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	resp = ForwardedResponseIfVolumeIsRemote(d, r, poolID, volumeName, db.StoragePoolVolumeTypeCustom)
+	if resp != nil {
+		return resp
+	}
+
+	_, volume, err := d.cluster.StoragePoolNodeVolumeGetTypeByProject("default", volumeName, db.StoragePoolVolumeTypeCustom, poolID)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if volume.ContentType == db.StoragePoolVolumeContentTypeNameBlock {
+		return response.BadRequest(fmt.Errorf("Exporting block custom volumes isn't supported"))
+	}
+
+	pool, err := storagePools.GetPoolByName(d.State(), poolName)
+	if err == storageDrivers.ErrUnknownDriver {
+		return response.BadRequest(fmt.Errorf("Exporting custom volumes isn't supported by this storage driver"))
+	} else if err != nil {
+		return response.SmartError(err)
+	}
+
+	path, err := storagePoolVolumeExportTarball(d.State(), pool, volume)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	ent := response.FileResponseEntry{
+		Path: path,
+	}
+
+	return response.FileResponse(r, []response.FileResponseEntry{ent}, nil, true)
+}
+
+// storagePoolVolumeExportTarball writes a tarball of the custom volume and returns its path. The
+// tarball holds the volume's definition in backup/volume.yaml and its content in backup/volume/.
+func storagePoolVolumeExportTarball(s *state.State, pool storagePools.Pool, volume *api.StorageVolume) (string, error) {
+	backupsPath := shared.VarPath("backups")
+	if !shared.PathExists(backupsPath) {
+		err := os.MkdirAll(backupsPath, 0700)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	tmpPath, err := ioutil.TempDir(backupsPath, "lxd_volume_backup_")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpPath)
+
+	data, err := yaml.Marshal(volume)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(filepath.Join(tmpPath, "backup"), 0700)
+	if err != nil {
+		return "", err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(tmpPath, "backup", "volume.yaml"), data, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	ourMount, err := pool.MountCustomVolume(volume.Name, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if ourMount {
+		defer pool.UnmountCustomVolume(volume.Name, nil)
+	}
+
+	tarball, err := ioutil.TempFile(backupsPath, "lxd_volume_export_")
+	if err != nil {
+		return "", err
+	}
+	tarball.Close()
+
+	success := false
+	defer func() {
+		if success {
+			return
+		}
+
+		os.Remove(tarball.Name())
+	}()
+
+	mountPath := storageDrivers.GetVolumeMountPath(pool.Name(), storageDrivers.VolumeTypeCustom, volume.Name)
+	args := []string{"-cf", tarball.Name(), "--numeric-owner", "--xattrs", "-C", tmpPath, "backup", "-C", mountPath, "--transform", "s,^./,backup/volume/,", "."}
+	_, err = shared.RunCommand("tar", args...)
+	if err != nil {
+		return "", err
+	}
+
+	compress, err := cluster.ConfigGetString(s.Cluster, "backups.compression_algorithm")
+	if err != nil {
+		return "", err
+	}
+
+	if compress != "none" {
+		infile, err := os.Open(tarball.Name())
+		if err != nil {
+			return "", err
+		}
+		defer infile.Close()
+
+		compressed, err := os.Create(tarball.Name() + ".compressed")
+		if err != nil {
+			return "", err
+		}
+		compressedName := compressed.Name()
+
+		defer compressed.Close()
+		defer os.Remove(compressedName)
+
+		err = compressFile(compress, infile, compressed)
+		if err != nil {
+			return "", err
+		}
+
+		err = os.Rename(compressedName, tarball.Name())
+		if err != nil {
+			return "", err
+		}
+	}
+
+	success = true
+	return tarball.Name(), nil
+}
+
+// storagePoolVolumeCreateFromBackup creates a custom volume from a tarball produced by
+// storagePoolVolumeExportTarball. The volume keeps its original name unless one is given.
+func storagePoolVolumeCreateFromBackup(d *Daemon, poolName string, data io.Reader, name string) response.Response {
+	// Create temporary file to store uploaded backup data.
+	backupFile, err := ioutil.TempFile("", "lxd_volume_backup_")
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	revert := true
+	defer func() {
+		if revert {
+			os.Remove(backupFile.Name())
+		}
+	}()
+
+	// Stream uploaded backup data into temporary file.
+	_, err = io.Copy(backupFile, data)
+	backupFile.Close()
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	// Parse the volume definition.
+	index, err := shared.RunCommand("tar", "-xOf", backupFile.Name(), "backup/volume.yaml")
+	if err != nil {
+		return response.BadRequest(errors.Wrap(err, "Failed to read the volume definition"))
+	}
+
+	volume := api.StorageVolume{}
+	err = yaml.Unmarshal([]byte(index), &volume)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if name != "" {
+		volume.Name = name
+	}
+
+	if volume.Name == "" {
+		return response.BadRequest(fmt.Errorf("No name provided"))
+	}
+
+	if strings.Contains(volume.Name, "/") {
+		return response.BadRequest(fmt.Errorf("Storage volume names may not contain slashes"))
+	}
+
+	if volume.ContentType == db.StoragePoolVolumeContentTypeNameBlock {
+		return response.BadRequest(fmt.Errorf("Importing block custom volumes isn't supported"))
+	}
+
+	poolID, err := d.cluster.StoragePoolGetID(poolName)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Check if destination volume exists.
+	_, _, err = d.cluster.StoragePoolNodeVolumeGetTypeByProject("default", volume.Name, db.StoragePoolVolumeTypeCustom, poolID)
+	if err != db.ErrNoSuchObject {
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		return response.Conflict(fmt.Errorf("Volume by that name already exists"))
+	}
+
+	pool, err := storagePools.GetPoolByName(d.State(), poolName)
+	if err == storageDrivers.ErrUnknownDriver {
+		return response.BadRequest(fmt.Errorf("Importing custom volumes isn't supported by this storage driver"))
+	} else if err != nil {
+		return response.SmartError(err)
+	}
+
+	run := func(op *operations.Operation) error {
+		defer os.Remove(backupFile.Name())
+
+		err := pool.CreateCustomVolume(volume.Name, volume.Description, volume.Config, storageDrivers.ContentTypeFS, op)
+		if err != nil {
+			return err
+		}
+
+		success := false
+		defer func() {
+			if success {
+				return
+			}
+
+			pool.DeleteCustomVolume(volume.Name, op)
+		}()
+
+		ourMount, err := pool.MountCustomVolume(volume.Name, op)
+		if err != nil {
+			return err
+		}
+
+		if ourMount {
+			defer pool.UnmountCustomVolume(volume.Name, op)
+		}
+
+		mountPath := storageDrivers.GetVolumeMountPath(pool.Name(), storageDrivers.VolumeTypeCustom, volume.Name)
+		_, err = shared.RunCommand("tar", "-xf", backupFile.Name(), "--numeric-owner", "--xattrs-include=*", "-C", mountPath, "--strip-components=2", "backup/volume")
+		if err != nil {
+			return errors.Wrap(err, "Failed to unpack the volume")
+		}
+
+		success = true
+		return nil
+	}
+
+	resources := map[string][]string{}
+	resources["storage_volumes"] = []string{volume.Name}
+
+	op, err := operations.OperationCreate(d.State(), "", operations.OperationClassTask, db.OperationVolumeCreate, resources, nil, run, nil, nil)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	revert = false
+	return operations.OperationResponse(op)
+}
@@ -39,6 +39,13 @@ var changeableStoragePoolVolumeProperties = map[string][]string{
 		"size",
 	},
 
+	"iscsi": {
+		"block.mount_options",
+		"security.shifted",
+		"security.unmapped",
+		"size",
+	},
+
 	"lvm": {
 		"block.mount_options",
 		"security.shifted",
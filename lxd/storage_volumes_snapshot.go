@@ -22,7 +22,7 @@ import (
 var storagePoolVolumeSnapshotsTypeCmd = APIEndpoint{
 	Path: "storage-pools/{pool}/volumes/{type}/{name}/snapshots",
 
-	Get:  APIEndpointAction{Handler: storagePoolVolumeSnapshotsTypeGet, AccessHandler: AllowAuthenticated},
+	Get:  APIEndpointAction{Handler: storagePoolVolumeSnapshotsTypeGet, AccessHandler: AllowProjectAuthenticated},
 	Post: APIEndpointAction{Handler: storagePoolVolumeSnapshotsTypePost},
 }
 
@@ -30,7 +30,7 @@ var storagePoolVolumeSnapshotTypeCmd = APIEndpoint{
 	Path: "storage-pools/{pool}/volumes/{type}/{name}/snapshots/{snapshotName}",
 
 	Delete: APIEndpointAction{Handler: storagePoolVolumeSnapshotTypeDelete},
-	Get:    APIEndpointAction{Handler: storagePoolVolumeSnapshotTypeGet, AccessHandler: AllowAuthenticated},
+	Get:    APIEndpointAction{Handler: storagePoolVolumeSnapshotTypeGet, AccessHandler: AllowProjectAuthenticated},
 	Post:   APIEndpointAction{Handler: storagePoolVolumeSnapshotTypePost},
 	Put:    APIEndpointAction{Handler: storagePoolVolumeSnapshotTypePut},
 }
@@ -239,6 +239,7 @@ func storagePoolVolumeSnapshotsTypeGet(d *Daemon, r *http.Request) response.Resp
 			tmp.Config = vol.Config
 			tmp.Description = vol.Description
 			tmp.Name = vol.Name
+			tmp.UUID = vol.UUID
 
 			resultMap = append(resultMap, tmp)
 		}
@@ -392,6 +393,7 @@ func storagePoolVolumeSnapshotTypeGet(d *Daemon, r *http.Request) response.Respo
 	snapshot.Config = volume.Config
 	snapshot.Description = volume.Description
 	snapshot.Name = snapshotName
+	snapshot.UUID = volume.UUID
 
 	etag := []interface{}{snapshot.Name, snapshot.Description, snapshot.Config}
 
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/response"
+	storagePools "github.com/lxc/lxd/lxd/storage"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+var storagePoolVolumeTypeStateCmd = APIEndpoint{
+	Path: "storage-pools/{pool}/volumes/{type}/{name}/state",
+
+	Get: APIEndpointAction{Handler: storagePoolVolumeTypeStateGet, AccessHandler: AllowProjectAuthenticated},
+}
+
+// /1.0/storage-pools/{pool}/volumes/{type}/{name}/state
+// Get the IO counters of the running instances using the storage volume.
+func storagePoolVolumeTypeStateGet(d *Daemon, r *http.Request) response.Response {
+	project := projectParam(r)
+	poolName := mux.Vars(r)["pool"]
+	volumeTypeName := mux.Vars(r)["type"]
+	volumeName := mux.Vars(r)["name"]
+
+	// Convert the volume type name to our internal integer representation.
+	volumeType, err := storagePools.VolumeTypeNameToType(volumeTypeName)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	// Check that the storage volume type is valid.
+	if !shared.IntInSlice(volumeType, supportedVolumeTypes) {
+		return response.BadRequest(fmt.Errorf("Invalid storage volume type %s", volumeTypeName))
+	}
+
+	poolID, err := d.cluster.StoragePoolGetID(poolName)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	resp := ForwardedResponseIfTargetIsRemote(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	resp = ForwardedResponseIfVolumeIsRemote(d, r, poolID, volumeName, volumeType)
+	if resp != nil {
+		return resp
+	}
+
+	// Check that the storage volume exists.
+	_, _, err = d.cluster.StoragePoolNodeVolumeGetTypeByProject(project, volumeName, volumeType, poolID)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	insts, err := instanceLoadNodeProjectAll(d.State(), project, instancetype.Any)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	state := api.StorageVolumeState{
+		Instances: map[string]api.StorageVolumeStateIO{},
+	}
+
+	storagePoolVolumesIOStats(insts, func(inst instance.Instance, pool string, volType string, volName string, stats api.StorageVolumeStateIO) {
+		if pool != poolName || volType != volumeTypeName || volName != volumeName {
+			return
+		}
+
+		instStats := state.Instances[inst.Name()]
+		storageVolumeStateIOAdd(&instStats, stats)
+		state.Instances[inst.Name()] = instStats
+		storageVolumeStateIOAdd(&state.IO, stats)
+	})
+
+	return response.SyncResponse(true, state)
+}
+
+// storagePoolVolumesIOStats calls cb with the IO counters of each storage volume used by the
+// running instances, identified by pool, volume type and volume name. Instances whose counters
+// can't be retrieved are skipped.
+func storagePoolVolumesIOStats(insts []instance.Instance, cb func(inst instance.Instance, pool string, volType string, volName string, stats api.StorageVolumeStateIO)) {
+	for _, inst := range insts {
+		if !inst.IsRunning() {
+			continue
+		}
+
+		disks, err := inst.DiskIOStats()
+		if err != nil {
+			logger.Debug("Failed to get disk IO counters", log.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+			continue
+		}
+
+		for devName, stats := range disks {
+			dev, ok := inst.ExpandedDevices()[devName]
+			if !ok || dev["pool"] == "" {
+				continue
+			}
+
+			if dev["path"] == "/" {
+				cb(inst, dev["pool"], inst.Type().String(), inst.Name(), stats)
+			} else {
+				cb(inst, dev["pool"], storagePoolVolumeTypeNameCustom, dev["source"], stats)
+			}
+		}
+	}
+}
+
+// storageVolumeStateIOAdd adds the counters of stats to total.
+func storageVolumeStateIOAdd(total *api.StorageVolumeStateIO, stats api.StorageVolumeStateIO) {
+	total.ReadBytes += stats.ReadBytes
+	total.ReadOps += stats.ReadOps
+	total.WriteBytes += stats.WriteBytes
+	total.WriteOps += stats.WriteOps
+}
@@ -8,8 +8,10 @@ import (
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/state"
 	storagePools "github.com/lxc/lxd/lxd/storage"
+	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/units"
 	"github.com/lxc/lxd/shared/version"
 )
 
@@ -154,6 +156,31 @@ func storagePoolVolumeUpdate(state *state.State, poolName string, volumeName str
 		}
 	}
 
+	// Confirm that no instances are running when shrinking the volume, as its filesystem must
+	// be unmounted to be shrunk.
+	if volumeType == storagePoolVolumeTypeCustom && shared.StringInSlice("size", changedConfig) && oldConfig["size"] != "" && newConfig["size"] != "" {
+		oldSize, err := units.ParseByteSizeString(oldConfig["size"])
+		if err != nil {
+			return err
+		}
+
+		newSize, err := units.ParseByteSizeString(newConfig["size"])
+		if err != nil {
+			return err
+		}
+
+		if newSize < oldSize {
+			ctsUsingVolume, err := storagePoolVolumeUsedByRunningInstancesWithProfilesGet(state, poolName, volumeName, storagePoolVolumeTypeNameCustom, true)
+			if err != nil {
+				return err
+			}
+
+			if len(ctsUsingVolume) != 0 {
+				return fmt.Errorf("Cannot shrink the volume while running containers are using it")
+			}
+		}
+	}
+
 	// Apply config changes if there are any
 	if len(changedConfig) != 0 {
 		newWritable.Description = newDescription
@@ -526,7 +553,7 @@ func storagePoolVolumeDBCreateInternal(state *state.State, poolName string, vol
 	}
 
 	// Create database entry for new storage volume.
-	err := storagePools.VolumeDBCreate(state, "default", poolName, volumeName, volumeDescription, volumeTypeName, false, volumeConfig)
+	err := storagePools.VolumeDBCreate(state, "default", poolName, volumeName, volumeDescription, volumeTypeName, false, volumeConfig, storageDrivers.ContentTypeFS)
 	if err != nil {
 		return nil, err
 	}
@@ -635,7 +662,7 @@ func storagePoolVolumeSnapshotCopyInternal(state *state.State, poolName string,
 
 func storagePoolVolumeSnapshotDBCreateInternal(state *state.State, dbArgs *db.StorageVolumeArgs) (storage, error) {
 	// Create database entry for new storage volume.
-	err := storagePools.VolumeDBCreate(state, "default", dbArgs.PoolName, dbArgs.Name, dbArgs.Description, dbArgs.TypeName, true, dbArgs.Config)
+	err := storagePools.VolumeDBCreate(state, "default", dbArgs.PoolName, dbArgs.Name, dbArgs.Description, dbArgs.TypeName, true, dbArgs.Config, storageDrivers.ContentTypeFS)
 	if err != nil {
 		return nil, err
 	}
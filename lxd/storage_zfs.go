@@ -22,6 +22,7 @@ import (
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/rsync"
 	driver "github.com/lxc/lxd/lxd/storage"
+	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -649,10 +650,42 @@ func (s *storageZfs) StoragePoolUpdate(writable *api.StoragePoolPut, changedConf
 	// "volume.zfs.remove_snapshots" requires no on-disk modifications.
 	// "volume.zfs.use_refquota" requires no on-disk modifications.
 
+	if shared.StringInSlice("size", changedConfig) && writable.Config["size"] != "" {
+		err := s.zfsPoolGrowLoop(writable.Config["size"])
+		if err != nil {
+			return err
+		}
+	}
+
 	logger.Infof(`Updated ZFS storage pool "%s"`, s.pool.Name)
 	return nil
 }
 
+// zfsPoolGrowLoop grows the loop file backing the zpool to size and expands the zpool onto it.
+func (s *storageZfs) zfsPoolGrowLoop(size string) error {
+	loopPath := filepath.Join(shared.VarPath("disks"), fmt.Sprintf("%s.img", s.pool.Name))
+	if s.pool.Config["source"] != loopPath {
+		return fmt.Errorf("Only loop backed ZFS pools can be resized")
+	}
+
+	sizeBytes, err := units.ParseByteSizeString(size)
+	if err != nil {
+		return err
+	}
+
+	err = storageDrivers.GrowLoopFile(loopPath, sizeBytes)
+	if err != nil {
+		return err
+	}
+
+	output, err := shared.RunCommand("zpool", "online", "-e", s.getOnDiskPoolName(), loopPath)
+	if err != nil {
+		return fmt.Errorf("Failed to expand the zpool: %s", strings.TrimSpace(output))
+	}
+
+	return nil
+}
+
 func (s *storageZfs) StoragePoolVolumeUpdate(writable *api.StorageVolumePut, changedConfig []string) error {
 	if writable.Restore != "" {
 		logger.Infof(`Restoring ZFS storage volume "%s" from snapshot "%s"`,
@@ -793,7 +826,11 @@ func (s *storageZfs) ContainerUmount(c instance.Instance, path string) (bool, er
 	var imgerr error
 	ourUmount := false
 	if shared.IsMountPoint(containerPoolVolumeMntPoint) {
-		imgerr = zfsUmount(s.getOnDiskPoolName(), fs, containerPoolVolumeMntPoint)
+		if zfsIsBlockVolume(s.getOnDiskPoolName(), fs) {
+			imgerr = storageDrivers.TryUnmount(containerPoolVolumeMntPoint, 0)
+		} else {
+			imgerr = zfsUmount(s.getOnDiskPoolName(), fs, containerPoolVolumeMntPoint)
+		}
 		ourUmount = true
 	}
 
@@ -845,6 +882,11 @@ func (s *storageZfs) ContainerCreate(container instance.Instance) error {
 func (s *storageZfs) ContainerCreateFromImage(container instance.Instance, fingerprint string, tracker *ioprogress.ProgressTracker) error {
 	logger.Debugf("Creating ZFS storage volume for container \"%s\" on storage pool \"%s\"", s.volume.Name, s.pool.Name)
 
+	// Block mode volumes can't be cloned from the image dataset.
+	if s.isBlockMode() {
+		return s.containerCreateFromImageBlock(container, fingerprint)
+	}
+
 	containerPath := container.Path()
 	containerName := container.Name()
 	volumeName := project.Prefix(container.Project(), containerName)
@@ -920,6 +962,41 @@ func (s *storageZfs) ContainerCreateFromImage(container instance.Instance, finge
 	return nil
 }
 
+func (s *storageZfs) containerCreateFromImageBlock(container instance.Instance, fingerprint string) error {
+	err := s.ContainerCreate(container)
+	if err != nil {
+		return err
+	}
+
+	revert := true
+	defer func() {
+		if !revert {
+			return
+		}
+		s.ContainerDelete(container)
+	}()
+
+	ourMount, err := s.ContainerMount(container)
+	if err != nil {
+		return err
+	}
+	if ourMount {
+		defer s.ContainerUmount(container, container.Path())
+	}
+
+	imagePath := shared.VarPath("images", fingerprint)
+	containerPoolVolumeMntPoint := driver.GetContainerMountPoint(container.Project(), s.pool.Name, container.Name())
+	err = driver.ImageUnpack(imagePath, containerPoolVolumeMntPoint, "", true, s.s.OS.RunningInUserNS, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to unpack image \"%s\" into ZFS volume \"%s\"", fingerprint, containerPoolVolumeMntPoint)
+	}
+
+	revert = false
+
+	logger.Debugf("Created ZFS storage volume for container \"%s\" on storage pool \"%s\"", s.volume.Name, s.pool.Name)
+	return nil
+}
+
 func (s *storageZfs) ContainerDelete(container instance.Instance) error {
 	err := s.doContainerDelete(container.Project(), container.Name())
 	if err != nil {
@@ -1837,7 +1914,11 @@ func (s *storageZfs) ContainerSnapshotStart(container instance.Instance) (bool,
 		return false, err
 	}
 
-	err = zfsMount(poolName, destFs)
+	if zfsIsBlockVolume(poolName, destFs) {
+		err = zfsBlockMount(poolName, destFs, snapshotMntPoint, s.getZfsBlockMountOptions())
+	} else {
+		err = zfsMount(poolName, destFs)
+	}
 	if err != nil {
 		return false, err
 	}
@@ -1852,6 +1933,14 @@ func (s *storageZfs) ContainerSnapshotStop(container instance.Instance) (bool, e
 	cName, sName, _ := shared.InstanceGetParentAndSnapshotName(container.Name())
 	destFs := fmt.Sprintf("snapshots/%s/%s", project.Prefix(container.Project(), cName), sName)
 
+	snapshotMntPoint := driver.GetSnapshotMountPoint(container.Project(), s.pool.Name, container.Name())
+	if zfsIsBlockVolume(s.getOnDiskPoolName(), destFs) && shared.IsMountPoint(snapshotMntPoint) {
+		err := storageDrivers.TryUnmount(snapshotMntPoint, 0)
+		if err != nil {
+			return false, err
+		}
+	}
+
 	err := zfsPoolVolumeDestroy(s.getOnDiskPoolName(), destFs)
 	if err != nil {
 		return false, err
@@ -2765,6 +2854,11 @@ func (s *storageZfs) StorageEntitySetQuota(volumeType int, size int64, data inte
 		fs = fmt.Sprintf("custom/%s", s.volume.Name)
 	}
 
+	poolName := s.getOnDiskPoolName()
+	if c != nil && zfsIsBlockVolume(poolName, fs) {
+		return s.setBlockQuota(c, fs, size)
+	}
+
 	property := "quota"
 
 	if s.pool.Config["volume.zfs.use_refquota"] != "" {
@@ -2778,7 +2872,6 @@ func (s *storageZfs) StorageEntitySetQuota(volumeType int, size int64, data inte
 		property = "refquota"
 	}
 
-	poolName := s.getOnDiskPoolName()
 	var err error
 	if size > 0 {
 		err = zfsPoolVolumeSet(poolName, fs, property, fmt.Sprintf("%d", size))
@@ -2794,6 +2887,69 @@ func (s *storageZfs) StorageEntitySetQuota(volumeType int, size int64, data inte
 	return nil
 }
 
+// setBlockQuota resizes the zvol backing a block mode container volume and
+// grows or shrinks its filesystem. Shrinking requires the container to be
+// stopped.
+func (s *storageZfs) setBlockQuota(c instance.Instance, fs string, size int64) error {
+	poolName := s.getOnDiskPoolName()
+
+	volSize, err := zfsFilesystemEntityPropertyGet(poolName, fs, "volsize")
+	if err != nil {
+		return err
+	}
+
+	oldSize, err := strconv.ParseInt(volSize, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	size = zfsBlockVolSizeAlign(size)
+	if size == oldSize || size <= 0 {
+		return nil
+	}
+
+	devPath := zfsBlockDevPath(poolName, fs)
+	fsType, err := shared.RunCommand("blkid", "-s", "TYPE", "-o", "value", devPath)
+	if err != nil {
+		return err
+	}
+
+	mountpoint := driver.GetContainerMountPoint(c.Project(), s.pool.Name, c.Name())
+
+	if size < oldSize {
+		// The filesystem must be unmounted to be shrunk, so defer it until the
+		// container is next started.
+		if c.IsRunning() {
+			return driver.ErrRunningQuotaResizeNotSupported
+		}
+
+		cleanupFunc, err := shrinkVolumeFilesystem(s, storagePoolVolumeTypeContainer, strings.TrimSpace(fsType), devPath, mountpoint, size, c)
+		if cleanupFunc != nil {
+			defer cleanupFunc()
+		}
+		if err != nil {
+			return err
+		}
+
+		return zfsPoolVolumeSet(poolName, fs, "volsize", fmt.Sprintf("%d", size))
+	}
+
+	err = zfsPoolVolumeSet(poolName, fs, "volsize", fmt.Sprintf("%d", size))
+	if err != nil {
+		return err
+	}
+
+	ourMount, err := s.ContainerMount(c)
+	if err != nil {
+		return err
+	}
+	if ourMount {
+		defer s.ContainerUmount(c, c.Path())
+	}
+
+	return driver.GrowFileSystem(strings.TrimSpace(fsType), devPath, mountpoint)
+}
+
 func (s *storageZfs) StoragePoolResources() (*api.ResourcesStoragePool, error) {
 	poolName := s.getOnDiskPoolName()
 
@@ -2830,6 +2986,25 @@ func (s *storageZfs) StoragePoolResources() (*api.ResourcesStoragePool, error) {
 	return &res, nil
 }
 
+func (s *storageZfs) StoragePoolHealth() storageDrivers.Health {
+	zpoolName := strings.Split(s.getOnDiskPoolName(), "/")[0]
+
+	out, err := shared.RunCommand("zpool", "list", "-H", "-o", "health", zpoolName)
+	if err != nil {
+		return storageDrivers.Health{Status: storageDrivers.HealthOffline, Message: fmt.Sprintf("Failed to get health of ZFS pool %q: %v", zpoolName, err)}
+	}
+
+	state := strings.TrimSpace(out)
+	switch state {
+	case "ONLINE":
+		return storageDrivers.Health{Status: storageDrivers.HealthOnline}
+	case "DEGRADED":
+		return storageDrivers.Health{Status: storageDrivers.HealthDegraded, Message: fmt.Sprintf("ZFS pool %q is degraded", zpoolName)}
+	default:
+		return storageDrivers.Health{Status: storageDrivers.HealthOffline, Message: fmt.Sprintf("ZFS pool %q is in state %s", zpoolName, state)}
+	}
+}
+
 func (s *storageZfs) doCrossPoolStorageVolumeCopy(source *api.StorageVolumeSource) error {
 	successMsg := fmt.Sprintf("Copied ZFS storage volume \"%s\" on storage pool \"%s\" as \"%s\" to storage pool \"%s\"", source.Name, source.Pool, s.volume.Name, s.pool.Name)
 	// setup storage for the source volume
@@ -18,6 +18,7 @@ import (
 	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/units"
 )
 
 // zfsIsEnabled returns whether zfs backend is supported.
@@ -177,14 +178,15 @@ func zfsPoolApplyDefaults(dataset string) error {
 }
 
 func zfsPoolVolumeClone(project, pool string, source string, name string, dest string, mountpoint string) error {
-	_, err := shared.RunCommand(
-		"zfs",
-		"clone",
-		"-p",
-		"-o", fmt.Sprintf("mountpoint=%s", mountpoint),
-		"-o", "canmount=noauto",
-		fmt.Sprintf("%s/%s@%s", pool, source, name),
-		fmt.Sprintf("%s/%s", pool, dest))
+	args := []string{"clone", "-p"}
+
+	// Clones of zvols are zvols themselves and have no mountpoint.
+	if !zfsIsBlockVolume(pool, source) {
+		args = append(args, "-o", fmt.Sprintf("mountpoint=%s", mountpoint), "-o", "canmount=noauto")
+	}
+
+	args = append(args, fmt.Sprintf("%s/%s@%s", pool, source, name), fmt.Sprintf("%s/%s", pool, dest))
+	_, err := shared.RunCommand("zfs", args...)
 	if err != nil {
 		logger.Errorf("zfs clone failed: %v", err)
 		return errors.Wrap(err, "Failed to clone the filesystem")
@@ -683,7 +685,14 @@ func (s *storageZfs) doContainerMount(projectName, name string, privileged bool)
 	}
 
 	ourMount := false
-	if !shared.IsMountPoint(containerPoolVolumeMntPoint) {
+	if !shared.IsMountPoint(containerPoolVolumeMntPoint) && zfsIsBlockVolume(s.getOnDiskPoolName(), fs) {
+		err := zfsBlockMount(s.getOnDiskPoolName(), fs, containerPoolVolumeMntPoint, s.getZfsBlockMountOptions())
+		if err != nil {
+			logger.Errorf("Failed to mount ZFS volume \"%s\" onto \"%s\": %v", fs, containerPoolVolumeMntPoint, err)
+			return false, errors.Wrapf(err, "Failed to mount ZFS volume \"%s\" onto \"%s\"", fs, containerPoolVolumeMntPoint)
+		}
+		ourMount = true
+	} else if !shared.IsMountPoint(containerPoolVolumeMntPoint) {
 		source := fmt.Sprintf("%s/%s", s.getOnDiskPoolName(), fs)
 		zfsMountOptions := fmt.Sprintf("rw,zfsutil,mntpoint=%s", containerPoolVolumeMntPoint)
 		mounterr := storageDrivers.TryMount(source, containerPoolVolumeMntPoint, "zfs", 0, zfsMountOptions)
@@ -714,6 +723,14 @@ func (s *storageZfs) doContainerDelete(projectName, name string) error {
 	containerPoolVolumeMntPoint := driver.GetContainerMountPoint(projectName, s.pool.Name, containerName)
 
 	if zfsFilesystemEntityExists(poolName, fs) {
+		blockMode := zfsIsBlockVolume(poolName, fs)
+		if blockMode && shared.IsMountPoint(containerPoolVolumeMntPoint) {
+			err := storageDrivers.TryUnmount(containerPoolVolumeMntPoint, 0)
+			if err != nil {
+				return err
+			}
+		}
+
 		removable := true
 		snaps, err := zfsPoolListSnapshots(poolName, fs)
 		if err != nil {
@@ -750,9 +767,11 @@ func (s *storageZfs) doContainerDelete(projectName, name string) error {
 				return err
 			}
 		} else {
-			err := zfsPoolVolumeSet(poolName, fs, "mountpoint", "none")
-			if err != nil {
-				return err
+			if !blockMode {
+				err := zfsPoolVolumeSet(poolName, fs, "mountpoint", "none")
+				if err != nil {
+					return err
+				}
 			}
 
 			err = zfsPoolVolumeRename(poolName, fs, fmt.Sprintf("deleted/containers/%s", uuid.NewRandom().String()), true)
@@ -803,20 +822,34 @@ func (s *storageZfs) doContainerCreate(projectName, name string, privileged bool
 	dataset := fmt.Sprintf("%s/%s", poolName, fs)
 	containerPoolVolumeMntPoint := driver.GetContainerMountPoint(projectName, s.pool.Name, containerName)
 
-	// Create volume.
-	msg, err := zfsPoolVolumeCreate(dataset, "mountpoint=none", "canmount=noauto")
-	if err != nil {
-		logger.Errorf("Failed to create ZFS storage volume for container \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, msg)
-		return err
-	}
+	if s.isBlockMode() {
+		// Create a formatted zvol rather than a dataset.
+		size, err := s.getZfsBlockVolumeSize()
+		if err != nil {
+			return err
+		}
 
-	// Set mountpoint.
-	err = zfsPoolVolumeSet(poolName, fs, "mountpoint", containerPoolVolumeMntPoint)
-	if err != nil {
-		return err
+		err = zfsBlockVolumeCreate(poolName, fs, size, s.getZfsBlockFilesystem())
+		if err != nil {
+			logger.Errorf("Failed to create ZFS storage volume for container \"%s\" on storage pool \"%s\": %v", s.volume.Name, s.pool.Name, err)
+			return err
+		}
+	} else {
+		// Create volume.
+		msg, err := zfsPoolVolumeCreate(dataset, "mountpoint=none", "canmount=noauto")
+		if err != nil {
+			logger.Errorf("Failed to create ZFS storage volume for container \"%s\" on storage pool \"%s\": %s", s.volume.Name, s.pool.Name, msg)
+			return err
+		}
+
+		// Set mountpoint.
+		err = zfsPoolVolumeSet(poolName, fs, "mountpoint", containerPoolVolumeMntPoint)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = driver.CreateContainerMountpoint(containerPoolVolumeMntPoint, containerPath, privileged)
+	err := driver.CreateContainerMountpoint(containerPoolVolumeMntPoint, containerPath, privileged)
 	if err != nil {
 		return err
 	}
@@ -837,3 +870,124 @@ func zfsIdmapSetSkipper(dir string, absPath string, fi os.FileInfo) bool {
 
 	return false
 }
+
+// zfsBlockVolBlockSize is the alignment applied to zvol sizes, volsize must be
+// a multiple of the volume's block size.
+const zfsBlockVolBlockSize = 16 * 1024
+
+// isBlockMode returns whether new container volumes should be backed by a
+// zvol rather than a dataset.
+func (s *storageZfs) isBlockMode() bool {
+	if s.volume.Config["zfs.block_mode"] != "" {
+		return shared.IsTrue(s.volume.Config["zfs.block_mode"])
+	}
+
+	return shared.IsTrue(s.pool.Config["volume.zfs.block_mode"])
+}
+
+func (s *storageZfs) getZfsBlockFilesystem() string {
+	return storageDrivers.BlockFilesystem(s.volume.Config, s.pool.Config)
+}
+
+func (s *storageZfs) getZfsBlockMountOptions() string {
+	return storageDrivers.BlockMountOptions(s.volume.Config, s.pool.Config)
+}
+
+func (s *storageZfs) getZfsBlockVolumeSize() (int64, error) {
+	sz, err := units.ParseByteSizeString(s.volume.Config["size"])
+	if err != nil {
+		return -1, err
+	}
+
+	if sz == 0 && s.pool.Config["volume.size"] != "" {
+		sz, err = units.ParseByteSizeString(s.pool.Config["volume.size"])
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	// Safety net: Set to default value.
+	if sz == 0 {
+		sz, _ = units.ParseByteSizeString("10GB")
+	}
+
+	return zfsBlockVolSizeAlign(sz), nil
+}
+
+// zfsBlockVolSizeAlign rounds a size down to a valid zvol size.
+func zfsBlockVolSizeAlign(size int64) int64 {
+	return (size / zfsBlockVolBlockSize) * zfsBlockVolBlockSize
+}
+
+// zfsIsBlockVolume returns whether the entity is a zvol rather than a dataset.
+func zfsIsBlockVolume(pool string, path string) bool {
+	entityType, err := zfsFilesystemEntityPropertyGet(pool, path, "type")
+	if err != nil {
+		return false
+	}
+
+	return entityType == "volume"
+}
+
+// zfsBlockDevPath returns the device node of a zvol.
+func zfsBlockDevPath(pool string, path string) string {
+	return fmt.Sprintf("/dev/zvol/%s/%s", pool, path)
+}
+
+// zfsBlockVolumeCreate creates a zvol of the given size and formats it.
+func zfsBlockVolumeCreate(pool string, path string, size int64, fsType string) error {
+	msg, err := shared.RunCommand(
+		"zfs",
+		"create",
+		"-p",
+		"-V", fmt.Sprintf("%d", size),
+		fmt.Sprintf("%s/%s", pool, path))
+	if err != nil {
+		logger.Errorf("zfs create failed: %s", msg)
+		return errors.Wrap(err, "Failed to create the ZFS volume")
+	}
+
+	devPath := zfsBlockDevPath(pool, path)
+	err = zfsBlockWaitDevice(devPath)
+	if err != nil {
+		return err
+	}
+
+	msg, err = storageDrivers.MakeFSType(devPath, fsType, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to create %s filesystem on ZFS volume \"%s\": %s", fsType, devPath, msg)
+	}
+
+	return nil
+}
+
+// zfsBlockWaitDevice waits for udev to create the device node of a zvol.
+func zfsBlockWaitDevice(devPath string) error {
+	for i := 0; i < 50; i++ {
+		if shared.PathExists(devPath) {
+			return nil
+		}
+
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	return fmt.Errorf("Timeout waiting for ZFS volume device \"%s\"", devPath)
+}
+
+// zfsBlockMount mounts the filesystem of a zvol onto the given mountpoint.
+func zfsBlockMount(pool string, path string, mountpoint string, mountOptions string) error {
+	devPath := zfsBlockDevPath(pool, path)
+	err := zfsBlockWaitDevice(devPath)
+	if err != nil {
+		return err
+	}
+
+	// Clones keep the filesystem of their origin, so detect it from the device.
+	fsType, err := shared.RunCommand("blkid", "-s", "TYPE", "-o", "value", devPath)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to detect filesystem of ZFS volume \"%s\"", devPath)
+	}
+
+	mountFlags, mountOptions := resolveMountOptions(mountOptions)
+	return storageDrivers.TryMount(devPath, mountpoint, strings.TrimSpace(fsType), mountFlags, mountOptions)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/cgroup"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/lxd/warnings"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+)
+
+var warningsCmd = APIEndpoint{
+	Path: "warnings",
+
+	Get: APIEndpointAction{Handler: warningsGet, AccessHandler: AllowAuthenticated},
+}
+
+var warningCmd = APIEndpoint{
+	Path: "warnings/{uuid}",
+
+	Get:    APIEndpointAction{Handler: warningGet, AccessHandler: AllowAuthenticated},
+	Put:    APIEndpointAction{Handler: warningPut},
+	Patch:  APIEndpointAction{Handler: warningPut},
+	Delete: APIEndpointAction{Handler: warningDelete},
+}
+
+// warningToAPI converts a database warning into its API representation.
+func warningToAPI(tx *db.ClusterTx, w db.Warning) (api.Warning, error) {
+	entityURL, err := tx.WarningEntityURL(w)
+	if err != nil {
+		return api.Warning{}, err
+	}
+
+	return api.Warning{
+		WarningPut: api.WarningPut{
+			Status: db.WarningStatusNames[w.Status],
+		},
+		UUID:        w.UUID,
+		Location:    w.Node,
+		Project:     w.Project,
+		Type:        db.WarningTypeNames[w.TypeCode],
+		Count:       w.Count,
+		FirstSeenAt: w.FirstSeenDate,
+		LastSeenAt:  w.LastSeenDate,
+		LastMessage: w.LastMessage,
+		Severity:    db.WarningSeverityNames[w.TypeCode.Severity()],
+		EntityURL:   entityURL,
+	}, nil
+}
+
+func warningsGet(d *Daemon, r *http.Request) response.Response {
+	recursion := util.IsRecursionRequest(r)
+
+	filter := db.WarningFilter{
+		Project: queryParam(r, "project"),
+		Node:    queryParam(r, "target"),
+	}
+
+	status := queryParam(r, "status")
+	if status != "" {
+		code, ok := db.WarningStatusFromName(status)
+		if !ok {
+			return response.BadRequest(fmt.Errorf("Invalid warning status %q", status))
+		}
+
+		filter.Status = code
+	}
+
+	resultString := []string{}
+	resultMap := []api.Warning{}
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		dbWarnings, err := tx.Warnings(filter)
+		if err != nil {
+			return err
+		}
+
+		for _, w := range dbWarnings {
+			if !recursion {
+				resultString = append(resultString, fmt.Sprintf("/%s/warnings/%s", version.APIVersion, w.UUID))
+				continue
+			}
+
+			warning, err := warningToAPI(tx, w)
+			if err != nil {
+				return err
+			}
+
+			resultMap = append(resultMap, warning)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if !recursion {
+		return response.SyncResponse(true, resultString)
+	}
+
+	return response.SyncResponse(true, resultMap)
+}
+
+func warningGet(d *Daemon, r *http.Request) response.Response {
+	uuid := mux.Vars(r)["uuid"]
+
+	var warning api.Warning
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		w, err := tx.WarningByUUID(uuid)
+		if err != nil {
+			return err
+		}
+
+		warning, err = warningToAPI(tx, w)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponseETag(true, warning, warning.Status)
+}
+
+func warningPut(d *Daemon, r *http.Request) response.Response {
+	uuid := mux.Vars(r)["uuid"]
+
+	req := api.WarningPut{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	status, ok := db.WarningStatusFromName(req.Status)
+	if !ok {
+		return response.BadRequest(fmt.Errorf("Invalid warning status %q", req.Status))
+	}
+
+	// Only acknowledgement and re-opening are allowed, resolution is up to the component that raised it.
+	if status == db.WarningStatusResolved {
+		return response.BadRequest(fmt.Errorf("Warnings can only be resolved by LXD itself"))
+	}
+
+	var current db.Warning
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		current, err = tx.WarningByUUID(uuid)
+		return err
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = util.EtagCheck(r, db.WarningStatusNames[current.Status])
+	if err != nil {
+		return response.PreconditionFailed(err)
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.WarningStatusUpdate(uuid, status)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+func warningDelete(d *Daemon, r *http.Request) response.Response {
+	uuid := mux.Vars(r)["uuid"]
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.WarningDelete(uuid)
+	})
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+// pruneResolvedWarningsTask removes resolved warnings once they've been
+// resolved for more than a day.
+func pruneResolvedWarningsTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			return tx.WarningsPruneResolved(time.Now().Add(-24 * time.Hour))
+		})
+		if err != nil {
+			logger.Error("Failed to prune resolved warnings", log.Ctx{"err": err})
+		}
+	}
+
+	return f, task.Daily()
+}
+
+// warningsRecordSystem records warnings for kernel features missing on the
+// local node and resolves those that are now available.
+func warningsRecordSystem(d *Daemon) error {
+	cgroupWarnings := []struct {
+		resource cgroup.Resource
+		typeCode db.WarningType
+		message  string
+	}{
+		{cgroup.Blkio, db.WarningMissingCGroupBlkio, "I/O limits will be ignored"},
+		{cgroup.BlkioWeight, db.WarningMissingCGroupBlkioWeight, "I/O weight limits will be ignored"},
+		{cgroup.CPU, db.WarningMissingCGroupCPUController, "CPU time limits will be ignored"},
+		{cgroup.CPUAcct, db.WarningMissingCGroupCPUacctController, "CPU accounting will not be available"},
+		{cgroup.CPUSet, db.WarningMissingCGroupCPUsetController, "CPU pinning will be ignored"},
+		{cgroup.Devices, db.WarningMissingCGroupDevicesController, "Device access control won't work"},
+		{cgroup.Freezer, db.WarningMissingCGroupFreezerController, "Pausing/resuming containers won't work"},
+		{cgroup.Memory, db.WarningMissingCGroupMemoryController, "Memory limits will be ignored"},
+		{cgroup.NetPrio, db.WarningMissingCGroupNetPrioController, "Network limits will be ignored"},
+		{cgroup.Pids, db.WarningMissingCGroupPidsController, "Process limits will be ignored"},
+		{cgroup.MemorySwap, db.WarningMissingCGroupMemorySwapAccounting, "Swap limits will be ignored"},
+	}
+
+	for _, w := range cgroupWarnings {
+		var err error
+		if d.os.CGInfo.Supports(w.resource, nil) {
+			err = warnings.ResolveLocalNodeByType(d.cluster, w.typeCode)
+		} else {
+			err = warnings.UpsertLocalNode(d.cluster, "", db.WarningEntityTypeNone, 0, w.typeCode, w.message)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.os.AppArmorAvailable {
+		return warnings.ResolveLocalNodeByType(d.cluster, db.WarningAppArmorNotAvailable)
+	}
+
+	return warnings.UpsertLocalNode(d.cluster, "", db.WarningEntityTypeNone, 0, db.WarningAppArmorNotAvailable, "AppArmor isn't available on this system")
+}
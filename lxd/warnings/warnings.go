@@ -0,0 +1,39 @@
+// +build linux,cgo,!agent
+
+package warnings
+
+import (
+	"github.com/lxc/lxd/lxd/db"
+)
+
+// UpsertLocalNode records a warning for the local node, or bumps the counter
+// of the matching existing warning.
+func UpsertLocalNode(cluster *db.Cluster, project string, entityTypeCode int, entityID int64, typeCode db.WarningType, message string) error {
+	return cluster.Transaction(func(tx *db.ClusterTx) error {
+		node, err := tx.NodeName()
+		if err != nil {
+			return err
+		}
+
+		return tx.WarningUpsert(node, project, entityTypeCode, entityID, typeCode, message)
+	})
+}
+
+// ResolveLocalNodeByType marks all warnings of the given type on the local
+// node as resolved.
+func ResolveLocalNodeByType(cluster *db.Cluster, typeCode db.WarningType) error {
+	return ResolveLocalNodeByTypeAndEntity(cluster, typeCode, -1, 0)
+}
+
+// ResolveLocalNodeByTypeAndEntity marks all warnings of the given type about
+// the given entity on the local node as resolved.
+func ResolveLocalNodeByTypeAndEntity(cluster *db.Cluster, typeCode db.WarningType, entityTypeCode int, entityID int64) error {
+	return cluster.Transaction(func(tx *db.ClusterTx) error {
+		node, err := tx.NodeName()
+		if err != nil {
+			return err
+		}
+
+		return tx.WarningsResolve(node, typeCode, entityTypeCode, entityID)
+	})
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"time"
+)
+
+// AuditEntry represents a state-changing API request recorded in the audit log.
+//
+// API extension: audit_log
+type AuditEntry struct {
+	ID         int64          `json:"id" yaml:"id"`
+	Date       time.Time      `json:"date" yaml:"date"`
+	Location   string         `json:"location" yaml:"location"`
+	Method     string         `json:"method" yaml:"method"`
+	URL        string         `json:"url" yaml:"url"`
+	Project    string         `json:"project" yaml:"project"`
+	Requestor  AuditRequestor `json:"requestor" yaml:"requestor"`
+	StatusCode int            `json:"status_code" yaml:"status_code"`
+}
+
+// AuditRequestor represents the identity behind an API request.
+//
+// API extension: audit_log
+type AuditRequestor struct {
+	Protocol string `json:"protocol" yaml:"protocol"`
+	Username string `json:"username" yaml:"username"`
+	Address  string `json:"address" yaml:"address"`
+}
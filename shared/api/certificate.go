@@ -1,5 +1,10 @@
 package api
 
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
 // CertificatesPost represents the fields of a new LXD certificate
 type CertificatesPost struct {
 	CertificatePut `yaml:",inline"`
@@ -14,6 +19,10 @@ type CertificatesPost struct {
 type CertificatePut struct {
 	Name string `json:"name" yaml:"name"`
 	Type string `json:"type" yaml:"type"`
+
+	// API extension: certificate_project
+	Restricted bool     `json:"restricted" yaml:"restricted"`
+	Projects   []string `json:"projects" yaml:"projects"`
 }
 
 // Certificate represents a LXD certificate
@@ -28,3 +37,30 @@ type Certificate struct {
 func (cert *Certificate) Writable() CertificatePut {
 	return cert.CertificatePut
 }
+
+// CertificateAddToken represents the fields contained within an encoded certificate add token
+//
+// API extension: certificate_token
+type CertificateAddToken struct {
+	// The name of the new client
+	ClientName string `json:"client_name" yaml:"client_name"`
+
+	// The fingerprint of the network certificate
+	Fingerprint string `json:"fingerprint" yaml:"fingerprint"`
+
+	// The addresses of the server
+	Addresses []string `json:"addresses" yaml:"addresses"`
+
+	// The random join secret
+	Secret string `json:"secret" yaml:"secret"`
+}
+
+// String encodes the certificate add token as JSON and then base64
+func (t *CertificateAddToken) String() string {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return ""
+	}
+
+	return base64.StdEncoding.EncodeToString(data)
+}
@@ -48,16 +48,64 @@ type ClusterMemberPost struct {
 	ServerName string `json:"server_name" yaml:"server_name"`
 }
 
+// ClusterMemberPut represents the the modifiable fields of a LXD cluster member
+//
+// API extension: clustering_roles_assign
+type ClusterMemberPut struct {
+	// API extension: clustering_roles
+	Roles []string `json:"roles" yaml:"roles"`
+}
+
 // ClusterMember represents the a LXD node in the cluster.
 //
 // API extension: clustering
 type ClusterMember struct {
+	ClusterMemberPut `yaml:",inline"`
+
 	ServerName string `json:"server_name" yaml:"server_name"`
 	URL        string `json:"url" yaml:"url"`
 	Database   bool   `json:"database" yaml:"database"`
 	Status     string `json:"status" yaml:"status"`
 	Message    string `json:"message" yaml:"message"`
+}
 
-	// API extension: clustering_roles
-	Roles []string `json:"roles" yaml:"roles"`
+// Writable converts a full ClusterMember struct into a ClusterMemberPut struct (filters read-only fields).
+func (member *ClusterMember) Writable() ClusterMemberPut {
+	return member.ClusterMemberPut
+}
+
+// ClusterUpgrade represents the progress of a rolling upgrade of the cluster.
+//
+// API extension: clustering_upgrade
+type ClusterUpgrade struct {
+	// Mode is either "auto" or "manual", see the cluster.upgrade_mode key
+	Mode string `json:"mode" yaml:"mode"`
+
+	// Version the members are being upgraded to
+	Schema        int `json:"schema" yaml:"schema"`
+	APIExtensions int `json:"api_extensions" yaml:"api_extensions"`
+
+	Members []ClusterUpgradeMember `json:"members" yaml:"members"`
+}
+
+// ClusterUpgradeMember represents the version of a cluster member.
+//
+// API extension: clustering_upgrade
+type ClusterUpgradeMember struct {
+	ServerName    string `json:"server_name" yaml:"server_name"`
+	Schema        int    `json:"schema" yaml:"schema"`
+	APIExtensions int    `json:"api_extensions" yaml:"api_extensions"`
+
+	// Status is "up-to-date" once all members run the same version,
+	// otherwise "blocked" for upgraded members waiting for the others and
+	// "outdated" for members still running an older version
+	Status string `json:"status" yaml:"status"`
+}
+
+// ClusterUpgradePost represents the fields required to upgrade a cluster
+// member.
+//
+// API extension: clustering_upgrade
+type ClusterUpgradePost struct {
+	ServerName string `json:"server_name" yaml:"server_name"`
 }
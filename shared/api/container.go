@@ -73,6 +73,9 @@ type Container struct {
 
 	// API extension: clustering
 	Location string `json:"location" yaml:"location"`
+
+	// API extension: entity_uuid
+	UUID string `json:"uuid" yaml:"uuid"`
 }
 
 // ContainerFull is a combination of Container, ContainerState and CotnainerSnapshot
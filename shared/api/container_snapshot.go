@@ -44,6 +44,9 @@ type ContainerSnapshot struct {
 	LastUsedAt      time.Time                    `json:"last_used_at" yaml:"last_used_at"`
 	Name            string                       `json:"name" yaml:"name"`
 	Stateful        bool                         `json:"stateful" yaml:"stateful"`
+
+	// API extension: entity_uuid
+	UUID string `json:"uuid" yaml:"uuid"`
 }
 
 // Writable converts a full ContainerSnapshot struct into a ContainerSnapshotPut struct
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+)
+
+// ErrorType is a machine-readable identifier for the cause of an error
+//
+// API extension: error_types
+type ErrorType string
+
+// List of error types
+const (
+	// ErrorTypeQuotaExceeded is used when a size, space or count limit is reached
+	ErrorTypeQuotaExceeded ErrorType = "quota_exceeded"
+
+	// ErrorTypeProjectRestricted is used when the client isn't allowed to use a project
+	ErrorTypeProjectRestricted ErrorType = "project_restricted"
+
+	// ErrorTypeNotSupported is used when a driver doesn't support the requested feature
+	ErrorTypeNotSupported ErrorType = "not_supported"
+
+	// ErrorTypeInstanceBusy is used when another operation is running on the instance
+	ErrorTypeInstanceBusy ErrorType = "instance_busy"
+)
+
+// TypedError represents an error returned by the LXD API along with its type
+//
+// API extension: error_types
+type TypedError struct {
+	// HTTP status code of the response
+	Code int
+
+	Type    ErrorType
+	Message string
+}
+
+// Error returns the error message
+func (e *TypedError) Error() string {
+	return e.Message
+}
+
+// NewTypedError returns an error of the given type, reported with the given HTTP status code
+func NewTypedError(code int, errorType ErrorType, format string, args ...interface{}) *TypedError {
+	return &TypedError{
+		Code:    code,
+		Type:    errorType,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// ErrorTypeOf returns the type of an API error, looking through wrapped errors, or an empty
+// type if err isn't one
+func ErrorTypeOf(err error) ErrorType {
+	for err != nil {
+		apiErr, ok := err.(*TypedError)
+		if ok {
+			return apiErr.Type
+		}
+
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+
+		err = cause.Cause()
+	}
+
+	return ""
+}
@@ -133,3 +133,13 @@ type ImageMetadataTemplate struct {
 	Template   string            `json:"template" yaml:"template"`
 	Properties map[string]string `json:"properties" yaml:"properties"`
 }
+
+// ImageUpload represents a resumable image upload
+//
+// API extension: image_upload_resumable
+type ImageUpload struct {
+	ID string `json:"id" yaml:"id"`
+
+	// Number of bytes received so far, the next chunk must be sent at this offset
+	Offset int64 `json:"offset" yaml:"offset"`
+}
@@ -78,6 +78,13 @@ type Instance struct {
 	LastUsedAt      time.Time                    `json:"last_used_at" yaml:"last_used_at"`
 	Location        string                       `json:"location" yaml:"location"`
 	Type            string                       `json:"type" yaml:"type"`
+
+	// API extension: instance_config_origin
+	ExpandedConfigOrigin  map[string]string `json:"expanded_config_origin,omitempty" yaml:"expanded_config_origin,omitempty"`
+	ExpandedDevicesOrigin map[string]string `json:"expanded_devices_origin,omitempty" yaml:"expanded_devices_origin,omitempty"`
+
+	// API extension: entity_uuid
+	UUID string `json:"uuid" yaml:"uuid"`
 }
 
 // InstanceFull is a combination of Instance, InstanceBackup, InstanceState and InstanceSnapshot.
@@ -134,4 +141,7 @@ type InstanceSource struct {
 	ContainerOnly bool              `json:"container_only,omitempty" yaml:"container_only,omitempty"` // Deprecated, use InstanceOnly.
 	Refresh       bool              `json:"refresh,omitempty" yaml:"refresh,omitempty"`
 	Project       string            `json:"project,omitempty" yaml:"project,omitempty"`
+
+	// API extension: instance_copy_convert
+	Convert bool `json:"convert,omitempty" yaml:"convert,omitempty"`
 }
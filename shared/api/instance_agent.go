@@ -0,0 +1,27 @@
+package api
+
+// InstanceAgentPost represents a command forwarded to the agent of a virtual machine.
+//
+// API extension: instance_agent_commands
+type InstanceAgentPost struct {
+	// One of "ping", "fsfreeze-status" or "guest-exec"
+	Command string `json:"command" yaml:"command"`
+
+	// Command line, environment and working directory of guest-exec
+	Exec        []string          `json:"exec" yaml:"exec"`
+	Environment map[string]string `json:"environment" yaml:"environment"`
+	Cwd         string            `json:"cwd" yaml:"cwd"`
+}
+
+// InstanceAgentResult represents the result of a command run by the agent of a virtual machine.
+//
+// API extension: instance_agent_commands
+type InstanceAgentResult struct {
+	// "ok" for ping, "frozen" or "thawed" for fsfreeze-status and "exited" for guest-exec
+	Status string `json:"status" yaml:"status"`
+
+	// Outcome of guest-exec
+	ExitCode int    `json:"exit_code" yaml:"exit_code"`
+	Stdout   string `json:"stdout" yaml:"stdout"`
+	Stderr   string `json:"stderr" yaml:"stderr"`
+}
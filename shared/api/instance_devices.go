@@ -0,0 +1,23 @@
+package api
+
+// InstanceDevicesValidatePost represents a proposed set of devices for an instance.
+//
+// API extension: instance_devices_validate
+type InstanceDevicesValidatePost struct {
+	// Full (expanded) set of devices, including the ones coming from profiles
+	Devices map[string]map[string]string `json:"devices" yaml:"devices"`
+}
+
+// InstanceDevicesValidate represents the changes a proposed set of devices would make to an
+// instance.
+//
+// API extension: instance_devices_validate
+type InstanceDevicesValidate struct {
+	// Names of the devices which would be added, removed or updated in place
+	Added   []string `json:"added" yaml:"added"`
+	Removed []string `json:"removed" yaml:"removed"`
+	Updated []string `json:"updated" yaml:"updated"`
+
+	// Names of the added or removed devices which can't be changed while the instance is running
+	RestartRequired []string `json:"restart_required" yaml:"restart_required"`
+}
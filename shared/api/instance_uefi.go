@@ -0,0 +1,14 @@
+package api
+
+// InstanceUEFIVarsPost represents an action on the UEFI variable store of a virtual machine.
+//
+// API extension: instance_uefi_vars
+type InstanceUEFIVarsPost struct {
+	// Either "reset" or "enroll"
+	Action string `json:"action" yaml:"action"`
+
+	// PEM encoded certificates of the Secure Boot keys to enroll
+	PK  string   `json:"pk" yaml:"pk"`
+	KEK []string `json:"kek" yaml:"kek"`
+	DB  []string `json:"db" yaml:"db"`
+}
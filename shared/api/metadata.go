@@ -0,0 +1,20 @@
+package api
+
+// MetadataConfiguration represents the config keys known to the server, keyed by entity
+// ("instance", "network" or "storage-pool") and name.
+//
+// API extension: metadata_configuration
+type MetadataConfiguration struct {
+	Configs map[string]map[string]MetadataConfigurationKey `json:"configs" yaml:"configs"`
+}
+
+// MetadataConfigurationKey represents a config key. Names may contain "*" segments matching any
+// segment, a trailing one matching any number of segments.
+//
+// API extension: metadata_configuration
+type MetadataConfigurationKey struct {
+	Type        string   `json:"type" yaml:"type"`
+	Default     string   `json:"default" yaml:"default"`
+	Description string   `json:"description" yaml:"description"`
+	RenamedFrom []string `json:"renamed_from,omitempty" yaml:"renamed_from,omitempty"`
+}
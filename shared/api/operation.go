@@ -21,3 +21,13 @@ type Operation struct {
 	// API extension: operation_location
 	Location string `json:"location" yaml:"location"`
 }
+
+// OperationTransferProgress represents the progress of a single volume transfer
+// within an operation, as found in the "transfers" metadata key.
+//
+// API extension: operation_transfer_progress
+type OperationTransferProgress struct {
+	Stage            string `json:"stage" yaml:"stage"`
+	TransferredBytes int64  `json:"transferred_bytes" yaml:"transferred_bytes"`
+	Rate             int64  `json:"rate" yaml:"rate"`
+}
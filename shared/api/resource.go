@@ -12,6 +12,10 @@ type Resources struct {
 	// API extension: resources_v2
 	Network ResourcesNetwork `json:"network" yaml:"network"`
 	Storage ResourcesStorage `json:"storage" yaml:"storage"`
+
+	// API extension: resources_usb_pci
+	USB ResourcesUSB `json:"usb" yaml:"usb"`
+	PCI ResourcesPCI `json:"pci" yaml:"pci"`
 }
 
 // ResourcesCPU represents the cpu resources available on the system
@@ -22,6 +26,20 @@ type ResourcesCPU struct {
 
 	Sockets []ResourcesCPUSocket `json:"sockets" yaml:"sockets"`
 	Total   uint64               `json:"total" yaml:"total"`
+
+	// API extension: instances_sev
+	SEV *ResourcesCPUSEV `json:"sev,omitempty" yaml:"sev,omitempty"`
+}
+
+// ResourcesCPUSEV represents the AMD SEV memory encryption support of the system
+// API extension: instances_sev
+type ResourcesCPUSEV struct {
+	Supported bool `json:"supported" yaml:"supported"`
+	ES        bool `json:"es" yaml:"es"`
+	SNP       bool `json:"snp" yaml:"snp"`
+
+	CBitPosition    uint64 `json:"cbit_position" yaml:"cbit_position"`
+	ReducedPhysBits uint64 `json:"reduced_phys_bits" yaml:"reduced_phys_bits"`
 }
 
 // ResourcesCPUSocket represents a CPU socket on the system
@@ -90,6 +108,9 @@ type ResourcesGPUCard struct {
 	VendorID  string `json:"vendor_id,omitempty" yaml:"vendor_id,omitempty"`
 	Product   string `json:"product,omitempty" yaml:"product,omitempty"`
 	ProductID string `json:"product_id,omitempty" yaml:"product_id,omitempty"`
+
+	// API extension: resources_usb_pci
+	Mdev map[string]ResourcesGPUCardMdev `json:"mdev,omitempty" yaml:"mdev,omitempty"`
 }
 
 // ResourcesGPUCardDRM represents the Linux DRM configuration of the GPU
@@ -116,6 +137,16 @@ type ResourcesGPUCardSRIOV struct {
 	VFs []ResourcesGPUCard `json:"vfs" yaml:"vfs"`
 }
 
+// ResourcesGPUCardMdev represents the mediated device type of a GPU
+// API extension: resources_usb_pci
+type ResourcesGPUCardMdev struct {
+	API         string   `json:"api" yaml:"api"`
+	Available   uint64   `json:"available" yaml:"available"`
+	Name        string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Devices     []string `json:"devices" yaml:"devices"`
+}
+
 // ResourcesGPUCardNvidia represents additional information for NVIDIA GPUs
 // API extension: resources_gpu
 type ResourcesGPUCardNvidia struct {
@@ -269,6 +300,59 @@ type ResourcesMemoryNode struct {
 
 	Used  uint64 `json:"used" yaml:"used"`
 	Total uint64 `json:"total" yaml:"total"`
+
+	// Distances to the NUMA nodes, ordered by node number
+	// API extension: resources_usb_pci
+	Distances []uint64 `json:"distances,omitempty" yaml:"distances,omitempty"`
+}
+
+// ResourcesUSB represents the USB devices available on the system
+// API extension: resources_usb_pci
+type ResourcesUSB struct {
+	Devices []ResourcesUSBDevice `json:"devices" yaml:"devices"`
+	Total   uint64               `json:"total" yaml:"total"`
+}
+
+// ResourcesUSBDevice represents a USB device
+// API extension: resources_usb_pci
+type ResourcesUSBDevice struct {
+	BusAddress    uint64 `json:"bus_address" yaml:"bus_address"`
+	DeviceAddress uint64 `json:"device_address" yaml:"device_address"`
+
+	Vendor    string `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	VendorID  string `json:"vendor_id" yaml:"vendor_id"`
+	Product   string `json:"product,omitempty" yaml:"product,omitempty"`
+	ProductID string `json:"product_id" yaml:"product_id"`
+	Serial    string `json:"serial,omitempty" yaml:"serial,omitempty"`
+
+	Driver string  `json:"driver,omitempty" yaml:"driver,omitempty"`
+	Speed  float64 `json:"speed" yaml:"speed"`
+}
+
+// ResourcesPCI represents the PCI devices available on the system
+// API extension: resources_usb_pci
+type ResourcesPCI struct {
+	Devices []ResourcesPCIDevice `json:"devices" yaml:"devices"`
+	Total   uint64               `json:"total" yaml:"total"`
+}
+
+// ResourcesPCIDevice represents a PCI device
+// API extension: resources_usb_pci
+type ResourcesPCIDevice struct {
+	Driver        string `json:"driver,omitempty" yaml:"driver,omitempty"`
+	DriverVersion string `json:"driver_version,omitempty" yaml:"driver_version,omitempty"`
+
+	NUMANode   uint64 `json:"numa_node" yaml:"numa_node"`
+	PCIAddress string `json:"pci_address" yaml:"pci_address"`
+	Class      string `json:"class,omitempty" yaml:"class,omitempty"`
+
+	Vendor    string `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	VendorID  string `json:"vendor_id,omitempty" yaml:"vendor_id,omitempty"`
+	Product   string `json:"product,omitempty" yaml:"product,omitempty"`
+	ProductID string `json:"product_id,omitempty" yaml:"product_id,omitempty"`
+
+	// IOMMU group of the device, unset when the IOMMU isn't enabled
+	IOMMUGroup *uint64 `json:"iommu_group,omitempty" yaml:"iommu_group,omitempty"`
 }
 
 // ResourcesStoragePool represents the resources available to a given storage pool
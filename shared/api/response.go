@@ -19,6 +19,9 @@ type ResponseRaw struct {
 	Code  int    `json:"error_code" yaml:"error_code"`
 	Error string `json:"error" yaml:"error"`
 
+	// API extension: error_types
+	ErrorType ErrorType `json:"error_type" yaml:"error_type"`
+
 	Metadata interface{} `json:"metadata" yaml:"metadata"`
 }
 
@@ -37,6 +40,9 @@ type Response struct {
 	Code  int    `json:"error_code" yaml:"error_code"`
 	Error string `json:"error" yaml:"error"`
 
+	// API extension: error_types
+	ErrorType ErrorType `json:"error_type" yaml:"error_type"`
+
 	// Valid for Sync and Error responses
 	Metadata json.RawMessage `json:"metadata" yaml:"metadata"`
 }
@@ -23,6 +23,10 @@ type StoragePool struct {
 	// API extension: clustering
 	Status    string   `json:"status" yaml:"status"`
 	Locations []string `json:"locations" yaml:"locations"`
+
+	// API extension: storage_pool_health
+	Health        string `json:"health" yaml:"health"`
+	HealthMessage string `json:"health_message" yaml:"health_message"`
 }
 
 // StoragePoolPut represents the modifiable fields of a LXD storage pool.
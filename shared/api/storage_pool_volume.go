@@ -11,6 +11,9 @@ type StorageVolumesPost struct {
 
 	// API extension: storage_api_local_volume_handling
 	Source StorageVolumeSource `json:"source" yaml:"source"`
+
+	// API extension: custom_block_volumes
+	ContentType string `json:"content_type" yaml:"content_type"`
 }
 
 // StorageVolumePost represents the fields required to rename a LXD storage pool volume
@@ -52,6 +55,12 @@ type StorageVolume struct {
 
 	// API extension: clustering
 	Location string `json:"location" yaml:"location"`
+
+	// API extension: custom_block_volumes
+	ContentType string `json:"content_type" yaml:"content_type"`
+
+	// API extension: entity_uuid
+	UUID string `json:"uuid" yaml:"uuid"`
 }
 
 // StorageVolumePut represents the modifiable fields of a LXD storage volume.
@@ -83,6 +92,30 @@ type StorageVolumeSource struct {
 
 	// API extension: storage_api_volume_snapshots
 	VolumeOnly bool `json:"volume_only" yaml:"volume_only"`
+
+	// API extension: custom_volume_refresh
+	Refresh bool `json:"refresh" yaml:"refresh"`
+}
+
+// StorageVolumeState represents the live state of a LXD storage volume.
+//
+// API extension: storage_volume_state
+type StorageVolumeState struct {
+	// Totals over all the running instances using the volume
+	IO StorageVolumeStateIO `json:"io" yaml:"io"`
+
+	// Counters of each running instance using the volume, keyed by instance name
+	Instances map[string]StorageVolumeStateIO `json:"instances" yaml:"instances"`
+}
+
+// StorageVolumeStateIO represents the IO counters of a LXD storage volume.
+//
+// API extension: storage_volume_state
+type StorageVolumeStateIO struct {
+	ReadBytes  uint64 `json:"read_bytes" yaml:"read_bytes"`
+	ReadOps    uint64 `json:"read_ops" yaml:"read_ops"`
+	WriteBytes uint64 `json:"write_bytes" yaml:"write_bytes"`
+	WriteOps   uint64 `json:"write_ops" yaml:"write_ops"`
 }
 
 // Writable converts a full StorageVolume struct into a StorageVolumePut struct
@@ -21,6 +21,9 @@ type StorageVolumeSnapshot struct {
 	Name        string            `json:"name" yaml:"name"`
 	Config      map[string]string `json:"config" yaml:"config"`
 	Description string            `json:"description" yaml:"description"`
+
+	// API extension: entity_uuid
+	UUID string `json:"uuid" yaml:"uuid"`
 }
 
 // StorageVolumeSnapshotPut represents the modifiable fields of a LXD storage volume
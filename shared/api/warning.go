@@ -0,0 +1,35 @@
+package api
+
+import (
+	"time"
+)
+
+// Warning represents a warning entry.
+//
+// API extension: warnings
+type Warning struct {
+	WarningPut `yaml:",inline"`
+
+	UUID        string    `json:"uuid" yaml:"uuid"`
+	Location    string    `json:"location" yaml:"location"`
+	Project     string    `json:"project" yaml:"project"`
+	Type        string    `json:"type" yaml:"type"`
+	Count       int       `json:"count" yaml:"count"`
+	FirstSeenAt time.Time `json:"first_seen_at" yaml:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at" yaml:"last_seen_at"`
+	LastMessage string    `json:"last_message" yaml:"last_message"`
+	Severity    string    `json:"severity" yaml:"severity"`
+	EntityURL   string    `json:"entity_url" yaml:"entity_url"`
+}
+
+// WarningPut represents the modifiable fields of a warning.
+//
+// API extension: warnings
+type WarningPut struct {
+	Status string `json:"status" yaml:"status"`
+}
+
+// Writable converts a full Warning struct into a WarningPut struct (filters read-only fields).
+func (warning *Warning) Writable() WarningPut {
+	return warning.WarningPut
+}
@@ -0,0 +1,288 @@
+// +build linux
+
+// Package filearchive transfers directory trees as tar streams, preserving ownership, modes,
+// timestamps and extended attributes.
+package filearchive
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared/containerwriter"
+)
+
+// Write writes the tree at path to w as a tar stream. Entry names are relative to the parent
+// directory of path, so the first entry is the base name of path itself.
+func Write(w io.Writer, path string) error {
+	path = filepath.Clean(path)
+
+	_, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	offset := len(filepath.Dir(path)) + 1
+	if filepath.Dir(path) == "/" {
+		offset = 1
+	}
+
+	tw := containerwriter.NewContainerTarWriter(w, nil)
+
+	err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Archiving / leaves out the root directory itself.
+		if len(p) <= offset {
+			return nil
+		}
+
+		return tw.WriteFile(offset, p, fi)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// Extract extracts the tar stream read from r into the existing directory at path. Existing files
+// are overwritten and existing directories are merged into. Without preserveOwner, files are left
+// owned by the caller and extended attributes which can't be set are skipped, so that unprivileged
+// callers can extract archives.
+func Extract(r io.Reader, path string, preserveOwner bool) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return fmt.Errorf("Path %q isn't a directory", path)
+	}
+
+	// Directory modes and timestamps are applied last, as extracting their content changes them
+	// and may need write permission.
+	dirs := []*tar.Header{}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("Failed to read the archive: %v", err)
+		}
+
+		name, err := entryName(hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(path, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.Mkdir(target, 0700)
+			if err != nil && !os.IsExist(err) {
+				return err
+			}
+
+			dirs = append(dirs, hdr)
+		case tar.TypeReg:
+			err = extractFile(tr, target)
+		case tar.TypeSymlink:
+			err = replace(target, func() error { return os.Symlink(hdr.Linkname, target) })
+		case tar.TypeLink:
+			var linkName string
+			linkName, err = entryName(hdr.Linkname)
+			if err == nil {
+				err = replace(target, func() error { return os.Link(filepath.Join(path, linkName), target) })
+			}
+		case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			mode := uint32(hdr.Mode & 07777)
+			switch hdr.Typeflag {
+			case tar.TypeChar:
+				mode |= unix.S_IFCHR
+			case tar.TypeBlock:
+				mode |= unix.S_IFBLK
+			default:
+				mode |= unix.S_IFIFO
+			}
+
+			dev := int(unix.Mkdev(uint32(hdr.Devmajor), uint32(hdr.Devminor)))
+			err = replace(target, func() error { return unix.Mknod(target, mode, dev) })
+		default:
+			return fmt.Errorf("Unsupported type of archive entry %q", hdr.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to extract %q: %v", hdr.Name, err)
+		}
+
+		// Hard links share the metadata of their target.
+		if hdr.Typeflag == tar.TypeLink || hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		err = applyMetadata(target, hdr, preserveOwner)
+		if err != nil {
+			return fmt.Errorf("Failed to extract %q: %v", hdr.Name, err)
+		}
+	}
+
+	// Apply the directory metadata deepest first.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		name, _ := entryName(dirs[i].Name)
+
+		err := applyMetadata(filepath.Join(path, name), dirs[i], preserveOwner)
+		if err != nil {
+			return fmt.Errorf("Failed to extract %q: %v", dirs[i].Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Shift copies the tar stream read from r to w, mapping the owner of every entry with shift.
+// Entries whose owner can't be mapped are left out.
+func Shift(r io.Reader, w io.Writer, shift func(uid int64, gid int64) (int64, int64)) error {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("Failed to read the archive: %v", err)
+		}
+
+		uid, gid := shift(int64(hdr.Uid), int64(hdr.Gid))
+		if uid == -1 || gid == -1 {
+			continue
+		}
+
+		hdr.Uid = int(uid)
+		hdr.Gid = int(gid)
+
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return fmt.Errorf("Failed to write the archive: %v", err)
+		}
+
+		_, err = io.Copy(tw, tr)
+		if err != nil {
+			return fmt.Errorf("Failed to write the archive: %v", err)
+		}
+	}
+
+	return tw.Close()
+}
+
+// entryName returns the cleaned relative name of an archive entry, refusing names which would
+// escape the extraction directory.
+func entryName(name string) (string, error) {
+	cleaned := filepath.Clean(name)
+	if filepath.IsAbs(cleaned) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("Invalid archive entry %q", name)
+	}
+
+	return cleaned, nil
+}
+
+// extractFile writes the content of the current archive entry to the file at path.
+func extractFile(r io.Reader, path string) error {
+	fi, err := os.Lstat(path)
+	if err == nil && !fi.Mode().IsRegular() {
+		err = os.Remove(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// replace runs create, removing any non-directory file at path first.
+func replace(path string, create func() error) error {
+	fi, err := os.Lstat(path)
+	if err == nil && !fi.IsDir() {
+		err = os.Remove(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return create()
+}
+
+// applyMetadata sets the ownership, mode, extended attributes and modification time of the
+// archive entry on path.
+func applyMetadata(path string, hdr *tar.Header, preserveOwner bool) error {
+	if preserveOwner {
+		err := os.Lchown(path, hdr.Uid, hdr.Gid)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Symlinks have no mode, extended attributes or settable timestamps of their own.
+	if hdr.Typeflag == tar.TypeSymlink {
+		return nil
+	}
+
+	for key, value := range hdr.Xattrs {
+		err := unix.Lsetxattr(path, key, []byte(value), 0)
+		if err != nil && err != unix.ENOTSUP && preserveOwner {
+			return fmt.Errorf("Failed to set extended attribute %q: %v", key, err)
+		}
+	}
+
+	// The mode is set after the owner, as chown clears the setuid and setgid bits.
+	err := os.Chmod(path, os.FileMode(hdr.Mode&0777)|modeBits(hdr.Mode))
+	if err != nil {
+		return err
+	}
+
+	return os.Chtimes(path, hdr.ModTime, hdr.ModTime)
+}
+
+// modeBits returns the setuid, setgid and sticky bits of a tar mode as os.FileMode bits.
+func modeBits(mode int64) os.FileMode {
+	bits := os.FileMode(0)
+
+	if mode&unix.S_ISUID != 0 {
+		bits |= os.ModeSetuid
+	}
+
+	if mode&unix.S_ISGID != 0 {
+		bits |= os.ModeSetgid
+	}
+
+	if mode&unix.S_ISVTX != 0 {
+		bits |= os.ModeSticky
+	}
+
+	return bits
+}
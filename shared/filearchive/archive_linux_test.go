@@ -0,0 +1,107 @@
+package filearchive
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteExtract(t *testing.T) {
+	src, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+
+	dst, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+
+	tree := filepath.Join(src, "tree")
+	err = os.MkdirAll(filepath.Join(tree, "sub"), 0711)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(tree, "sub", "file"), []byte("content"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Symlink("sub/file", filepath.Join(tree, "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Link(filepath.Join(tree, "sub", "file"), filepath.Join(tree, "hardlink"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.Buffer{}
+	err = Write(&buf, tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Extract(&buf, dst, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dst, "tree", "hardlink"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "content" {
+		t.Errorf("Unexpected content %q", content)
+	}
+
+	target, err := os.Readlink(filepath.Join(dst, "tree", "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if target != "sub/file" {
+		t.Errorf("Unexpected symlink target %q", target)
+	}
+
+	fi, err := os.Stat(filepath.Join(dst, "tree", "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode().Perm() != 0711 {
+		t.Errorf("Unexpected directory mode %v", fi.Mode())
+	}
+
+	fi, err = os.Stat(filepath.Join(dst, "tree", "sub", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode().Perm() != 0640 {
+		t.Errorf("Unexpected file mode %v", fi.Mode())
+	}
+}
+
+func TestEntryName(t *testing.T) {
+	for _, name := range []string{"tree", "tree/sub/", "./tree", "tree/../other"} {
+		_, err := entryName(name)
+		if err != nil {
+			t.Errorf("Valid entry %q refused: %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"", ".", "..", "../tree", "/tree", "tree/../../other"} {
+		_, err := entryName(name)
+		if err == nil {
+			t.Errorf("Invalid entry %q accepted", name)
+		}
+	}
+}
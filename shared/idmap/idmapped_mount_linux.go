@@ -0,0 +1,232 @@
+// +build linux
+// +build cgo
+
+package idmap
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+/*
+#ifndef _GNU_SOURCE
+#define _GNU_SOURCE 1
+#endif
+#include <errno.h>
+#include <fcntl.h>
+#include <linux/types.h>
+#include <sched.h>
+#include <signal.h>
+#include <stdbool.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <sys/syscall.h>
+#include <sys/types.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+#include "../../lxd/include/macro.h"
+#include "../../lxd/include/memory_utils.h"
+
+#ifndef __NR_open_tree
+#define __NR_open_tree 428
+#endif
+
+#ifndef __NR_move_mount
+#define __NR_move_mount 429
+#endif
+
+#ifndef __NR_mount_setattr
+#define __NR_mount_setattr 442
+#endif
+
+#ifndef OPEN_TREE_CLONE
+#define OPEN_TREE_CLONE 1
+#endif
+
+#ifndef OPEN_TREE_CLOEXEC
+#define OPEN_TREE_CLOEXEC O_CLOEXEC
+#endif
+
+#ifndef AT_RECURSIVE
+#define AT_RECURSIVE 0x8000
+#endif
+
+#ifndef MOVE_MOUNT_F_EMPTY_PATH
+#define MOVE_MOUNT_F_EMPTY_PATH 0x00000004
+#endif
+
+#ifndef MOUNT_ATTR_RDONLY
+#define MOUNT_ATTR_RDONLY 0x00000001
+#endif
+
+#ifndef MOUNT_ATTR_IDMAP
+#define MOUNT_ATTR_IDMAP 0x00100000
+#endif
+
+struct lxd_mount_attr {
+	__u64 attr_set;
+	__u64 attr_clr;
+	__u64 propagation;
+	__u64 userns_fd;
+};
+
+static int write_id_map(pid_t pid, const char *type, const char *map)
+{
+	__do_close_prot_errno int fd = -EBADF;
+	char path[PATH_MAX];
+	size_t len = strlen(map);
+	int ret;
+
+	ret = snprintf(path, sizeof(path), "/proc/%d/%s", pid, type);
+	if (ret < 0 || (size_t)ret >= sizeof(path))
+		return -1;
+
+	fd = open(path, O_WRONLY | O_CLOEXEC);
+	if (fd < 0)
+		return -1;
+
+	if (write(fd, map, len) != (ssize_t)len)
+		return -1;
+
+	return 0;
+}
+
+// get_userns_fd returns a file descriptor to a new user namespace with the
+// given uid and gid maps, kept alive by the file descriptor alone.
+static int get_userns_fd(const char *uid_map, const char *gid_map)
+{
+	int sync_pipe[2];
+	char path[PATH_MAX];
+	char c = 0;
+	int fd = -1, ret;
+	pid_t pid;
+
+	if (pipe2(sync_pipe, O_CLOEXEC) < 0)
+		return -1;
+
+	pid = fork();
+	if (pid < 0) {
+		close(sync_pipe[0]);
+		close(sync_pipe[1]);
+		return -1;
+	}
+
+	if (pid == 0) {
+		close(sync_pipe[0]);
+
+		if (unshare(CLONE_NEWUSER) < 0)
+			_exit(EXIT_FAILURE);
+
+		if (write(sync_pipe[1], &c, 1) != 1)
+			_exit(EXIT_FAILURE);
+
+		// Wait to be killed once the namespace has been opened.
+		for (;;)
+			pause();
+	}
+
+	close(sync_pipe[1]);
+	ret = read(sync_pipe[0], &c, 1);
+	close(sync_pipe[0]);
+	if (ret != 1)
+		goto out;
+
+	if (uid_map[0] != '\0' && write_id_map(pid, "uid_map", uid_map) < 0)
+		goto out;
+
+	if (gid_map[0] != '\0' && write_id_map(pid, "gid_map", gid_map) < 0)
+		goto out;
+
+	ret = snprintf(path, sizeof(path), "/proc/%d/ns/user", pid);
+	if (ret < 0 || (size_t)ret >= sizeof(path))
+		goto out;
+
+	fd = open(path, O_RDONLY | O_CLOEXEC);
+
+out:
+	kill(pid, SIGKILL);
+	waitpid(pid, NULL, 0);
+
+	return fd;
+}
+
+static int create_idmapped_mount(const char *src, const char *dst, const char *uid_map, const char *gid_map, bool recursive, bool readonly)
+{
+	__do_close_prot_errno int userns_fd = -EBADF, tree_fd = -EBADF;
+	struct lxd_mount_attr attr = {
+		.attr_set = MOUNT_ATTR_IDMAP,
+	};
+	unsigned int flags = 0;
+	int ret;
+
+	if (recursive)
+		flags = AT_RECURSIVE;
+
+	if (readonly)
+		attr.attr_set |= MOUNT_ATTR_RDONLY;
+
+	userns_fd = get_userns_fd(uid_map, gid_map);
+	if (userns_fd < 0)
+		return -1;
+
+	attr.userns_fd = userns_fd;
+
+	tree_fd = syscall(__NR_open_tree, AT_FDCWD, src, OPEN_TREE_CLONE | OPEN_TREE_CLOEXEC | flags);
+	if (tree_fd < 0)
+		return -1;
+
+	ret = syscall(__NR_mount_setattr, tree_fd, "", AT_EMPTY_PATH | flags, &attr, sizeof(attr));
+	if (ret < 0)
+		return -1;
+
+	return syscall(__NR_move_mount, tree_fd, "", AT_FDCWD, dst, MOVE_MOUNT_F_EMPTY_PATH);
+}
+*/
+import "C"
+
+// CreateIdmappedMount mounts a copy of the tree at srcPath onto dstPath, through an idmapped
+// mount using the user namespace described by set. Files owned by an ID in the namespace (Nsid)
+// appear owned by the matching host ID (Hostid) through the new mount, and IDs which aren't
+// mapped appear as the overflow ID. This requires a kernel with idmapped mount support.
+func CreateIdmappedMount(srcPath string, dstPath string, set *IdmapSet, recursive bool, readonly bool) error {
+	uidMap := []string{}
+	gidMap := []string{}
+	for _, e := range set.Idmap {
+		line := fmt.Sprintf("%d %d %d", e.Nsid, e.Hostid, e.Maprange)
+		if e.Isuid {
+			uidMap = append(uidMap, line)
+		}
+
+		if e.Isgid {
+			gidMap = append(gidMap, line)
+		}
+	}
+
+	csrcPath := C.CString(srcPath)
+	defer C.free(unsafe.Pointer(csrcPath))
+
+	cdstPath := C.CString(dstPath)
+	defer C.free(unsafe.Pointer(cdstPath))
+
+	cuidMap := C.CString(strings.Join(uidMap, "\n"))
+	defer C.free(unsafe.Pointer(cuidMap))
+
+	cgidMap := C.CString(strings.Join(gidMap, "\n"))
+	defer C.free(unsafe.Pointer(cgidMap))
+
+	r, err := C.create_idmapped_mount(csrcPath, cdstPath, cuidMap, cgidMap, C.bool(recursive), C.bool(readonly))
+	if r < 0 {
+		if err == unix.ENOSYS {
+			return fmt.Errorf("Idmapped mounts aren't supported by the kernel")
+		}
+
+		return fmt.Errorf("Failed to create idmapped mount of %q on %q: %v", srcPath, dstPath, err)
+	}
+
+	return nil
+}
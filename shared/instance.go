@@ -133,6 +133,47 @@ func IsDeviceID(value string) error {
 	return nil
 }
 
+// IsCPUSet validates a CPU count or a cpuset style list of IDs and ranges (e.g. "0-3,6").
+func IsCPUSet(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	// Validate the character set
+	match, _ := regexp.MatchString("^[-,0-9]*$", value)
+	if !match {
+		return fmt.Errorf("Invalid CPU limit syntax")
+	}
+
+	// Validate first character
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, ",") {
+		return fmt.Errorf("CPU limit can't start with a separator")
+	}
+
+	// Validate last character
+	if strings.HasSuffix(value, "-") || strings.HasSuffix(value, ",") {
+		return fmt.Errorf("CPU limit can't end with a separator")
+	}
+
+	// Validate the ranges
+	for _, chunk := range strings.Split(value, ",") {
+		fields := strings.Split(chunk, "-")
+		if len(fields) > 2 || fields[0] == "" {
+			return fmt.Errorf("Invalid CPU range %q", chunk)
+		}
+
+		if len(fields) == 2 {
+			low, _ := strconv.Atoi(fields[0])
+			high, err := strconv.Atoi(fields[1])
+			if err != nil || high < low {
+				return fmt.Errorf("Invalid CPU range %q", chunk)
+			}
+		}
+	}
+
+	return nil
+}
+
 // IsRootDiskDevice returns true if the given device representation is configured as root disk for
 // a container. It typically get passed a specific entry of api.Instance.Devices.
 func IsRootDiskDevice(device map[string]string) bool {
@@ -170,39 +211,53 @@ func GetRootDiskDevice(devices map[string]map[string]string) (string, map[string
 	return "", nil, fmt.Errorf("No root device could be found")
 }
 
+// SnapshotScheduleAliases lists the aliases which may be used instead of a cron expression in
+// snapshots.schedule.
+var SnapshotScheduleAliases = []string{"@hourly", "@daily", "@midnight", "@weekly", "@monthly", "@annually", "@yearly"}
+
 // KnownInstanceConfigKeys maps all fully defined, well-known config keys
 // to an appropriate checker function, which validates whether or not a
 // given value is syntactically legal.
 var KnownInstanceConfigKeys = map[string]func(value string) error{
+	"boot.after": func(value string) error {
+		for _, name := range InstanceBootAfter(value) {
+			if strings.Contains(name, "/") {
+				return fmt.Errorf("Invalid instance name %q", name)
+			}
+		}
+
+		return nil
+	},
 	"boot.autostart":             IsBool,
 	"boot.autostart.delay":       IsInt64,
 	"boot.autostart.priority":    IsInt64,
 	"boot.stop.priority":         IsInt64,
 	"boot.host_shutdown_timeout": IsInt64,
 
-	"limits.cpu": func(value string) error {
-		if value == "" {
-			return nil
-		}
-
-		// Validate the character set
-		match, _ := regexp.MatchString("^[-,0-9]*$", value)
-		if !match {
-			return fmt.Errorf("Invalid CPU limit syntax")
-		}
-
-		// Validate first character
-		if strings.HasPrefix(value, "-") || strings.HasPrefix(value, ",") {
-			return fmt.Errorf("CPU limit can't start with a separator")
+	"cluster.affinity": func(value string) error {
+		affinity, antiAffinity := InstanceAffinityGroups(value)
+		for _, group := range append(affinity, antiAffinity...) {
+			match, _ := regexp.MatchString("^[a-zA-Z0-9_.-]+$", group)
+			if !match {
+				return fmt.Errorf("Invalid affinity group name %q", group)
+			}
 		}
 
-		// Validate last character
-		if strings.HasSuffix(value, "-") || strings.HasSuffix(value, ",") {
-			return fmt.Errorf("CPU limit can't end with a separator")
+		for _, group := range affinity {
+			if StringInSlice(group, antiAffinity) {
+				return fmt.Errorf("Group %q can't be used for both affinity and anti-affinity", group)
+			}
 		}
 
 		return nil
 	},
+
+	"cloud-init.network-config": IsAny,
+	"cloud-init.user-data":      IsAny,
+	"cloud-init.vendor-data":    IsAny,
+
+	"limits.cpu":       IsCPUSet,
+	"limits.cpu.nodes": IsCPUSet,
 	"limits.cpu.allowance": func(value string) error {
 		if value == "" {
 			return nil
@@ -274,9 +329,21 @@ var KnownInstanceConfigKeys = map[string]func(value string) error{
 
 	"linux.kernel_modules": IsAny,
 
+	"migration.criu.lazy_pages":               IsBool,
+	"migration.criu.tcp_established":          IsBool,
 	"migration.incremental.memory":            IsBool,
 	"migration.incremental.memory.iterations": IsUint32,
 	"migration.incremental.memory.goal":       IsUint32,
+	"migration.incremental.memory.downtime":   IsUint32,
+	"migration.incremental.memory.bandwidth": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		_, err := units.ParseByteSizeString(value)
+		return err
+	},
+	"migration.stateful": IsBool,
 
 	"nvidia.runtime":             IsBool,
 	"nvidia.driver.capabilities": IsAny,
@@ -295,24 +362,76 @@ var KnownInstanceConfigKeys = map[string]func(value string) error{
 	"security.idmap.isolated": IsBool,
 	"security.idmap.size":     IsUint32,
 
+	"security.apparmor":   IsBool,
 	"security.secureboot": IsBool,
 
-	"security.syscalls.blacklist_default":       IsBool,
-	"security.syscalls.blacklist_compat":        IsBool,
-	"security.syscalls.blacklist":               IsAny,
-	"security.syscalls.intercept.mknod":         IsBool,
-	"security.syscalls.intercept.mount":         IsBool,
-	"security.syscalls.intercept.mount.allowed": IsAny,
-	"security.syscalls.intercept.mount.fuse":    IsAny,
-	"security.syscalls.intercept.mount.shift":   IsBool,
-	"security.syscalls.intercept.setxattr":      IsBool,
-	"security.syscalls.whitelist":               IsAny,
+	"security.sev": IsBool,
+	"security.sev.policy": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		_, err := strconv.ParseUint(value, 0, 32)
+		if err != nil {
+			return fmt.Errorf("Invalid SEV policy %q, must be a 32bit integer", value)
+		}
+
+		return nil
+	},
+
+	"security.syscalls.allow":                 IsAny,
+	"security.syscalls.deny_default":          IsBool,
+	"security.syscalls.deny_compat":           IsBool,
+	"security.syscalls.deny":                  IsAny,
+	"security.syscalls.intercept.bpf":         IsBool,
+	"security.syscalls.intercept.bpf.devices": IsBool,
+	"security.syscalls.intercept.mknod":       IsBool,
+	"security.syscalls.intercept.mount":       IsBool,
+	"security.syscalls.intercept.mount.allowed": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		for _, fs := range strings.Split(value, ",") {
+			if strings.TrimSpace(fs) == "" {
+				return fmt.Errorf("Empty filesystem name in %q", value)
+			}
+		}
+
+		return nil
+	},
+	"security.syscalls.intercept.mount.fuse": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		for _, ent := range strings.Split(value, ",") {
+			fields := strings.Split(ent, "=")
+			if len(fields) != 2 || strings.TrimSpace(fields[0]) == "" || strings.TrimSpace(fields[1]) == "" {
+				return fmt.Errorf("Invalid entry %q, must be of the form 'filesystem=fuse-binary'", ent)
+			}
+		}
+
+		return nil
+	},
+	"security.syscalls.intercept.mount.shift": IsBool,
+	"security.syscalls.intercept.setxattr":    IsBool,
 
 	"snapshots.schedule": func(value string) error {
 		if value == "" {
 			return nil
 		}
 
+		if strings.Contains(value, "@") {
+			for _, alias := range strings.Split(value, ",") {
+				if !StringInSlice(strings.TrimSpace(alias), SnapshotScheduleAliases) {
+					return fmt.Errorf("Unknown schedule alias %q", strings.TrimSpace(alias))
+				}
+			}
+
+			return nil
+		}
+
 		if len(strings.Split(value, " ")) != 5 {
 			return fmt.Errorf("Schedule must be of the form: <minute> <hour> <day-of-month> <month> <day-of-week>")
 		}
@@ -326,6 +445,7 @@ var KnownInstanceConfigKeys = map[string]func(value string) error{
 	},
 	"snapshots.schedule.stopped": IsBool,
 	"snapshots.pattern":          IsAny,
+	"snapshots.quiesce":          IsBool,
 	"snapshots.expiry": func(value string) error {
 		// Validate expression
 		_, err := GetSnapshotExpiry(time.Time{}, value)
@@ -333,22 +453,29 @@ var KnownInstanceConfigKeys = map[string]func(value string) error{
 	},
 
 	// Caller is responsible for full validation of any raw.* value
-	"raw.apparmor": IsAny,
-	"raw.idmap":    IsAny,
-	"raw.lxc":      IsAny,
-	"raw.qemu":     IsAny,
-	"raw.seccomp":  IsAny,
+	"raw.apparmor":  IsAny,
+	"raw.idmap":     IsAny,
+	"raw.lxc":       IsAny,
+	"raw.qemu":      IsAny,
+	"raw.qemu.conf": IsAny,
+	"raw.seccomp":   IsAny,
 
 	"volatile.apply_template":   IsAny,
 	"volatile.base_image":       IsAny,
 	"volatile.last_state.idmap": IsAny,
 	"volatile.last_state.power": IsAny,
+	"volatile.last_state.ready": IsBool,
 	"volatile.idmap.base":       IsAny,
 	"volatile.idmap.current":    IsAny,
 	"volatile.idmap.next":       IsAny,
 	"volatile.apply_quota":      IsAny,
+	"volatile.uuid":             IsAny,
 }
 
+// namespacedSysctlPrefixes lists the prefixes of the sysctls which are namespaced, and so can be
+// set per instance through linux.sysctl.*.
+var namespacedSysctlPrefixes = []string{"net.", "kernel.shm", "kernel.msg", "kernel.sem", "fs.mqueue."}
+
 // ConfigKeyChecker returns a function that will check whether or not
 // a provide value is valid for the associate config key.  Returns an
 // error if the key is not known.  The checker function only performs
@@ -397,6 +524,10 @@ func ConfigKeyChecker(key string) (func(value string) error, error) {
 			return IsAny, nil
 		}
 
+		if strings.HasSuffix(key, ".vgpu.uuid") {
+			return IsAny, nil
+		}
+
 		if strings.HasSuffix(key, "vm.uuid") {
 			return IsAny, nil
 		}
@@ -404,6 +535,36 @@ func ConfigKeyChecker(key string) (func(value string) error, error) {
 		if strings.HasSuffix(key, ".ceph_rbd") {
 			return IsAny, nil
 		}
+
+		if strings.HasSuffix(key, ".pcie_port") {
+			return IsAny, nil
+		}
+
+		if strings.HasSuffix(key, ".last_state.pci.address") || strings.HasSuffix(key, ".last_state.pci.driver") || strings.HasSuffix(key, ".last_state.pci.functions") {
+			return IsAny, nil
+		}
+
+		if strings.HasPrefix(key, "volatile.tasks.") && (strings.HasSuffix(key, ".last_run") || strings.HasSuffix(key, ".last_status")) {
+			return IsAny, nil
+		}
+	}
+
+	if strings.HasPrefix(key, "tasks.") {
+		fields := strings.Split(key, ".")
+		if len(fields) == 3 && fields[1] != "" {
+			switch fields[2] {
+			case "schedule":
+				return KnownInstanceConfigKeys["snapshots.schedule"], nil
+			case "action":
+				return func(value string) error {
+					return IsOneOf(value, []string{"snapshot", "backup", "exec"})
+				}, nil
+			case "retain":
+				return IsUint32, nil
+			case "target", "command":
+				return IsAny, nil
+			}
+		}
 	}
 
 	if strings.HasPrefix(key, "environment.") {
@@ -423,6 +584,17 @@ func ConfigKeyChecker(key string) (func(value string) error, error) {
 		return IsAny, nil
 	}
 
+	if strings.HasPrefix(key, "linux.sysctl.") {
+		sysctl := strings.TrimPrefix(key, "linux.sysctl.")
+		for _, prefix := range namespacedSysctlPrefixes {
+			if strings.HasPrefix(sysctl, prefix) && !(strings.HasSuffix(prefix, ".") && sysctl == prefix) {
+				return IsNotEmpty, nil
+			}
+		}
+
+		return nil, fmt.Errorf("Only namespaced sysctls (%s) can be set: %s", strings.Join(namespacedSysctlPrefixes, "*, ")+"*", key)
+	}
+
 	return nil, fmt.Errorf("Unknown configuration key: %s", key)
 }
 
@@ -436,3 +608,72 @@ func InstanceGetParentAndSnapshotName(name string) (string, string, bool) {
 
 	return fields[0], fields[1], true
 }
+
+// CloudInitFiles lists the cloud-init seed files which can be set through the cloud-init.* config keys.
+var CloudInitFiles = []string{"user-data", "vendor-data", "network-config"}
+
+// CloudInitConfig returns the content of the given cloud-init seed file (e.g. "user-data"). The
+// cloud-init.* config key takes precedence over its legacy user.* equivalent.
+func CloudInitConfig(config map[string]string, file string) string {
+	value := config[fmt.Sprintf("cloud-init.%s", file)]
+	if value != "" {
+		return value
+	}
+
+	return config[fmt.Sprintf("user.%s", file)]
+}
+
+// CloudInitTemplateConfig returns a copy of the config in which the legacy user.* cloud-init keys are
+// replaced by their cloud-init.* equivalents so that existing image templates render them.
+func CloudInitTemplateConfig(config map[string]string) map[string]string {
+	newConfig := make(map[string]string, len(config))
+	for k, v := range config {
+		newConfig[k] = v
+	}
+
+	for _, file := range CloudInitFiles {
+		value := config[fmt.Sprintf("cloud-init.%s", file)]
+		if value != "" {
+			newConfig[fmt.Sprintf("user.%s", file)] = value
+		}
+	}
+
+	return newConfig
+}
+
+// InstanceBootAfter parses the value of the boot.after config key, a comma separated list of the
+// instances to start before this one.
+func InstanceBootAfter(value string) []string {
+	names := []string{}
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// InstanceAffinityGroups parses the value of the cluster.affinity config key, a comma separated list
+// of group names, into the affinity groups and the anti-affinity groups (those prefixed with "!").
+func InstanceAffinityGroups(value string) ([]string, []string) {
+	affinity := []string{}
+	antiAffinity := []string{}
+
+	for _, group := range strings.Split(value, ",") {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+
+		if strings.HasPrefix(group, "!") {
+			antiAffinity = append(antiAffinity, strings.TrimPrefix(group, "!"))
+		} else {
+			affinity = append(affinity, group)
+		}
+	}
+
+	return affinity, antiAffinity
+}
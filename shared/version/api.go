@@ -180,6 +180,94 @@ var APIExtensions = []string{
 	"container_disk_ceph",
 	"virtual-machines",
 	"image_profiles",
+	"custom_block_volumes",
+	"storage_pool_health",
+	"warnings",
+	"operation_transfer_progress",
+	"instances_sev",
+	"exec_recording",
+	"network_nftables_filtering",
+	"storage_zfs_block_mode",
+	"instance_cloud_init",
+	"instance_config_origin",
+	"clustering_roles_assign",
+	"event_hub",
+	"disk_io_bus_nvme",
+	"network_bgp",
+	"usb_class",
+	"gpu_mdev",
+	"vm_import",
+	"vm_templates",
+	"container_syscall_intercept_bpf_devices",
+	"firewall_driver_nftables",
+	"gpu_sharing",
+	"snapshots_quiesce",
+	"instance_agent_commands",
+	"clustering_image_auto_update",
+	"image_upload_resumable",
+	"storage_rsync_compression",
+	"migration_zstd",
+	"certificate_token",
+	"certificate_project",
+	"clustering_affinity",
+	"network_mtu_live_update",
+	"container_nic_ipvlan_mode",
+	"network_https_addresses",
+	"vm_network_state_counters",
+	"proxy_port_mapping",
+	"custom_volume_export",
+	"vm_apparmor",
+	"limits_cpu_nodes",
+	"vm_hugepages_backend",
+	"raw_qemu_conf",
+	"instance_ready_state",
+	"custom_volume_refresh",
+	"vm_stateful",
+	"container_criu_features",
+	"instance_operation_locks",
+	"error_types",
+	"resources_usb_pci",
+	"storage_volume_defaults_propagation",
+	"instance_boot_after",
+	"storage_volume_encryption",
+	"secrets_backend",
+	"https_trusted_proxy",
+	"storage_block_delta_migration",
+	"projects_idmap_isolated",
+	"vm_nic_p2p",
+	"instance_devices_validate",
+	"storage_volume_state",
+	"network_ipv6_ra",
+	"maas_network_dual_stack",
+	"instance_tasks",
+	"migration_pre_copy_tuning",
+	"device_pci",
+	"instance_nic_stable_naming",
+	"instance_linux_sysctl",
+	"cgroup2_limits",
+	"projects_limits",
+	"images_remote_cache_policy",
+	"storage_driver_iscsi",
+	"storage_block_filesystem_validation",
+	"instance_file_archive",
+	"instance_sftp",
+	"audit_log",
+	"clustering_upgrade",
+	"storage_pool_loop_resize",
+	"disk_raw_idmap",
+	"network_vhost_user",
+	"instance_uefi_vars",
+	"gpu_vm_passthrough",
+	"entity_uuid",
+	"network_dns_native",
+	"projects_networks",
+	"nic_routed_routes",
+	"images_mirror",
+	"vm_boot_priority",
+	"snapshot_schedule_aliases",
+	"instance_copy_convert",
+	"metadata_configuration",
+	"security_syscalls_allow_deny",
 }
 
 // APIExtensionsCount returns the number of available API extensions.